@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/WuKongIM/WuKongIM/internal/server"
+	"github.com/WuKongIM/WuKongIM/pkg/network"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// adminClient 运维命令使用的http api客户端
+// 接口会自动转发到对应的节点处理，所以可以连接集群中的任意节点
+type adminClient struct {
+	outputPrinter
+	server string // api地址，默认为本机的httpAddr
+	token  string // 管理者token，默认为配置中的managerToken
+	apiKey string // api key（X-Api-Key）
+}
+
+// outputPrinter 按table或json格式输出命令结果
+type outputPrinter struct {
+	output string // 输出格式 table或json
+}
+
+// bindFlags 给命令添加连接和输出相关的参数
+func (a *adminClient) bindFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&a.server, "server", "", "api address of any node, e.g. http://127.0.0.1:5001 (default: httpAddr in config)")
+	cmd.PersistentFlags().StringVar(&a.token, "token", "", "manager token (default: managerToken in config)")
+	cmd.PersistentFlags().StringVar(&a.apiKey, "api-key", "", "api key, sent as the X-Api-Key header")
+	cmd.PersistentFlags().StringVarP(&a.output, "output", "o", outputTable, "output format: table or json")
+}
+
+func (a *adminClient) baseUrl() string {
+	if strings.TrimSpace(a.server) != "" {
+		return strings.TrimSuffix(strings.TrimSpace(a.server), "/")
+	}
+	return localApiUrl(serverOpts)
+}
+
+// 本机的api地址
+func localApiUrl(opts *server.Options) string {
+	host, port, err := net.SplitHostPort(opts.HTTPAddr)
+	if err != nil {
+		return "http://127.0.0.1:5001"
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "127.0.0.1"
+	}
+	return fmt.Sprintf("http://%s", net.JoinHostPort(host, port))
+}
+
+func (a *adminClient) headers() map[string]string {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+	token := a.token
+	if token == "" {
+		token = serverOpts.ManagerToken
+	}
+	if token != "" {
+		headers["token"] = token
+	}
+	if a.apiKey != "" {
+		headers[server.ApiKeyHeader] = a.apiKey
+	}
+	return headers
+}
+
+func (a *adminClient) get(path string, query map[string]string) ([]byte, error) {
+	resp, err := network.Get(a.baseUrl()+path, query, a.headers())
+	if err != nil {
+		return nil, err
+	}
+	return a.handleResponse(resp.StatusCode, resp.Body)
+}
+
+func (a *adminClient) post(path string, body interface{}) ([]byte, error) {
+	resp, err := network.Post(a.baseUrl()+path, []byte(wkutil.ToJSON(body)), a.headers())
+	if err != nil {
+		return nil, err
+	}
+	return a.handleResponse(resp.StatusCode, resp.Body)
+}
+
+func (a *adminClient) handleResponse(statusCode int, body string) ([]byte, error) {
+	if statusCode == http.StatusOK {
+		return []byte(body), nil
+	}
+	if statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden {
+		return nil, fmt.Errorf("permission denied (status %d), check --token or --api-key", statusCode)
+	}
+	var errResp struct {
+		Msg string `json:"msg"`
+	}
+	if err := json.Unmarshal([]byte(body), &errResp); err == nil && errResp.Msg != "" {
+		return nil, errors.New(errResp.Msg)
+	}
+	return nil, fmt.Errorf("request failed, status: %d, body: %s", statusCode, body)
+}
+
+func (a *outputPrinter) jsonOutput() bool {
+	return a.output == outputJSON
+}
+
+func (a *outputPrinter) checkOutput() error {
+	if a.output != outputTable && a.output != outputJSON {
+		return fmt.Errorf("unsupported output format: %s", a.output)
+	}
+	return nil
+}
+
+// printJSON 格式化输出接口返回的json
+func (a *outputPrinter) printJSON(body []byte) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		fmt.Println(string(body))
+		return nil
+	}
+	fmt.Println(out.String())
+	return nil
+}
+
+// printValue 以json格式输出对象
+func (a *outputPrinter) printValue(v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func (a *outputPrinter) printTable(header []string, rows [][]string) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	_ = w.Flush()
+}
+
+// printResult 输出写操作的结果
+func (a *outputPrinter) printResult(body []byte, msg string) error {
+	if a.jsonOutput() {
+		return a.printJSON(body)
+	}
+	fmt.Println(msg)
+	return nil
+}
+
+func uint64sToString(vs []uint64) string {
+	strs := make([]string, 0, len(vs))
+	for _, v := range vs {
+		strs = append(strs, fmt.Sprintf("%d", v))
+	}
+	return strings.Join(strs, ",")
+}
+
+func boolIntToString(v int) string {
+	if v == 1 {
+		return "yes"
+	}
+	return "no"
+}
+
+// 解析频道参数 <channel_id> <channel_type>
+func parseChannelArgs(args []string) (string, uint8, error) {
+	channelId := strings.TrimSpace(args[0])
+	if channelId == "" {
+		return "", 0, errors.New("channel_id is empty")
+	}
+	channelType := wkutil.ParseUint8(args[1])
+	if channelType == 0 {
+		return "", 0, fmt.Errorf("invalid channel_type: %s", args[1])
+	}
+	return channelId, channelType, nil
+}
+
+// 集群频道接口的路径
+func clusterChannelPath(channelId string, channelType uint8, sub string) string {
+	return fmt.Sprintf("/cluster/channels/%s/%d/%s", url.PathEscape(channelId), channelType, sub)
+}
@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// 频道的运维命令
+type channelCMD struct {
+	ctx    *WuKongIMContext
+	client *adminClient
+}
+
+func newChannelCMD(ctx *WuKongIMContext) *channelCMD {
+	return &channelCMD{
+		ctx:    ctx,
+		client: &adminClient{},
+	}
+}
+
+func (ch *channelCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "channel",
+		Short: "channel administration (info, subscribers, denylist)",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return ch.client.checkOutput()
+		},
+	}
+	ch.client.bindFlags(cmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "info <channel_id> <channel_type>",
+		Short: "show the channel info",
+		Args:  cobra.ExactArgs(2),
+		RunE:  ch.info,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "subscribers <channel_id> <channel_type>",
+		Short: "list the subscribers of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ch.uids(args, "subscribers")
+		},
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "denylist <channel_id> <channel_type>",
+		Short: "list the denylist of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return ch.uids(args, "denylist")
+		},
+	})
+	return cmd
+}
+
+// 频道信息（/cluster/channels）
+type channelInfo struct {
+	Slot              uint32 `json:"slot"`
+	ChannelId         string `json:"channel_id"`
+	ChannelType       uint8  `json:"channel_type"`
+	Ban               int    `json:"ban"`
+	Disband           int    `json:"disband"`
+	SubscriberCount   int    `json:"subscriber_count"`
+	AllowlistCount    int    `json:"allowlist_count"`
+	DenylistCount     int    `json:"denylist_count"`
+	LastMsgSeq        uint64 `json:"last_msg_seq"`
+	LastMsgTimeFormat string `json:"last_msg_time_format"`
+	CreatedAtFormat   string `json:"created_at_format"`
+	UpdatedAtFormat   string `json:"updated_at_format"`
+}
+
+func (ch *channelCMD) info(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	body, err := ch.client.get("/cluster/channels", map[string]string{
+		"channel_id":   channelId,
+		"channel_type": fmt.Sprintf("%d", channelType),
+	})
+	if err != nil {
+		return err
+	}
+	var resp struct {
+		Data []*channelInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	var info *channelInfo
+	for _, d := range resp.Data {
+		if d.ChannelId == channelId && d.ChannelType == channelType {
+			info = d
+			break
+		}
+	}
+	if info == nil {
+		return errors.New("channel not found")
+	}
+	if ch.client.jsonOutput() {
+		return ch.client.printValue(info)
+	}
+	ch.client.printTable([]string{"CHANNEL", "TYPE", "SLOT", "BAN", "DISBAND", "SUBSCRIBERS", "ALLOWLIST", "DENYLIST", "LAST_SEQ", "LAST_MSG", "CREATED", "UPDATED"}, [][]string{{
+		info.ChannelId,
+		fmt.Sprintf("%d", info.ChannelType),
+		fmt.Sprintf("%d", info.Slot),
+		boolIntToString(info.Ban),
+		boolIntToString(info.Disband),
+		fmt.Sprintf("%d", info.SubscriberCount),
+		fmt.Sprintf("%d", info.AllowlistCount),
+		fmt.Sprintf("%d", info.DenylistCount),
+		fmt.Sprintf("%d", info.LastMsgSeq),
+		info.LastMsgTimeFormat,
+		info.CreatedAtFormat,
+		info.UpdatedAtFormat,
+	}})
+	return nil
+}
+
+// 获取频道的uid列表（订阅者、黑名单）
+func (ch *channelCMD) uids(args []string, sub string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	body, err := ch.client.get(clusterChannelPath(channelId, channelType, sub), nil)
+	if err != nil {
+		return err
+	}
+	if ch.client.jsonOutput() {
+		return ch.client.printJSON(body)
+	}
+	var uids []string
+	if err := json.Unmarshal(body, &uids); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(uids))
+	for _, uid := range uids {
+		rows = append(rows, []string{uid})
+	}
+	ch.client.printTable([]string{"UID"}, rows)
+	return nil
+}
@@ -0,0 +1,323 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	cluster "github.com/WuKongIM/WuKongIM/pkg/cluster/clusterserver"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/spf13/cobra"
+)
+
+// 集群的运维命令
+type clusterCMD struct {
+	ctx    *WuKongIMContext
+	client *adminClient
+
+	from uint64 // 迁移的原节点
+	to   uint64 // 迁移的目标节点
+}
+
+func newClusterCMD(ctx *WuKongIMContext) *clusterCMD {
+	return &clusterCMD{
+		ctx:    ctx,
+		client: &adminClient{},
+	}
+}
+
+func (c *clusterCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cluster",
+		Short: "cluster administration (nodes, slots, channel replicas, migration)",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return c.client.checkOutput()
+		},
+	}
+	c.client.bindFlags(cmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "nodes",
+		Short: "list the nodes of the cluster",
+		Args:  cobra.NoArgs,
+		RunE:  c.nodes,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "slots",
+		Short: "list all slots of the cluster",
+		Args:  cobra.NoArgs,
+		RunE:  c.slots,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "channel <channel_id> <channel_type>",
+		Short: "show the cluster config and replicas of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE:  c.channel,
+	})
+
+	migrateCmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "migrate a slot or channel replica from one node to another",
+	}
+	migrateSlotCmd := &cobra.Command{
+		Use:   "slot <slot_id>",
+		Short: "migrate a slot replica",
+		Args:  cobra.ExactArgs(1),
+		RunE:  c.migrateSlot,
+	}
+	migrateChannelCmd := &cobra.Command{
+		Use:   "channel <channel_id> <channel_type>",
+		Short: "migrate a channel replica",
+		Args:  cobra.ExactArgs(2),
+		RunE:  c.migrateChannel,
+	}
+	for _, sub := range []*cobra.Command{migrateSlotCmd, migrateChannelCmd} {
+		sub.Flags().Uint64Var(&c.from, "from", 0, "source node id")
+		sub.Flags().Uint64Var(&c.to, "to", 0, "target node id")
+		_ = sub.MarkFlagRequired("from")
+		_ = sub.MarkFlagRequired("to")
+		migrateCmd.AddCommand(sub)
+	}
+	cmd.AddCommand(migrateCmd)
+
+	transferCmd := &cobra.Command{
+		Use:   "leader-transfer",
+		Short: "transfer the leader of a slot or channel to another replica",
+	}
+	transferSlotCmd := &cobra.Command{
+		Use:   "slot <slot_id>",
+		Short: "transfer the slot leader",
+		Args:  cobra.ExactArgs(1),
+		RunE:  c.transferSlotLeader,
+	}
+	transferChannelCmd := &cobra.Command{
+		Use:   "channel <channel_id> <channel_type>",
+		Short: "transfer the channel leader",
+		Args:  cobra.ExactArgs(2),
+		RunE:  c.transferChannelLeader,
+	}
+	for _, sub := range []*cobra.Command{transferSlotCmd, transferChannelCmd} {
+		sub.Flags().Uint64Var(&c.to, "to", 0, "node id of the new leader, must be a replica")
+		_ = sub.MarkFlagRequired("to")
+		transferCmd.AddCommand(sub)
+	}
+	cmd.AddCommand(transferCmd)
+
+	return cmd
+}
+
+func (c *clusterCMD) nodes(cmd *cobra.Command, args []string) error {
+	body, err := c.client.get("/cluster/nodes", nil)
+	if err != nil {
+		return err
+	}
+	if c.client.jsonOutput() {
+		return c.client.printJSON(body)
+	}
+	var resp cluster.NodeConfigTotal
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resp.Data))
+	for _, n := range resp.Data {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", n.Id),
+			boolIntToString(n.IsLeader),
+			boolIntToString(n.Online),
+			n.ClusterAddr,
+			n.ApiServerAddr,
+			fmt.Sprintf("%d", n.SlotCount),
+			fmt.Sprintf("%d", n.SlotLeaderCount),
+			fmt.Sprintf("%d", n.Term),
+			n.Uptime,
+			n.AppVersion,
+			n.StatusFormat,
+		})
+	}
+	c.client.printTable([]string{"ID", "LEADER", "ONLINE", "CLUSTER_ADDR", "API_ADDR", "SLOTS", "SLOT_LEADERS", "TERM", "UPTIME", "VERSION", "STATUS"}, rows)
+	return nil
+}
+
+func (c *clusterCMD) slots(cmd *cobra.Command, args []string) error {
+	body, err := c.client.get("/cluster/allslot", nil)
+	if err != nil {
+		return err
+	}
+	if c.client.jsonOutput() {
+		return c.client.printJSON(body)
+	}
+	var resp cluster.SlotRespTotal
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resp.Data))
+	for _, st := range resp.Data {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", st.Id),
+			fmt.Sprintf("%d", st.LeaderId),
+			fmt.Sprintf("%d", st.Term),
+			uint64sToString(st.Replicas),
+			fmt.Sprintf("%d", st.ChannelCount),
+			fmt.Sprintf("%d", st.LogIndex),
+			st.StatusFormat,
+		})
+	}
+	c.client.printTable([]string{"ID", "LEADER", "TERM", "REPLICAS", "CHANNELS", "LOG_INDEX", "STATUS"}, rows)
+	return nil
+}
+
+// 频道副本信息（/cluster/channels/:channel_id/:channel_type/replicas）
+type channelReplica struct {
+	ReplicaId         uint64 `json:"replica_id"`
+	RoleFormat        string `json:"role_format"`
+	Running           int    `json:"running"`
+	LastMsgSeq        uint64 `json:"last_msg_seq"`
+	LastMsgTimeFormat string `json:"last_msg_time_format"`
+}
+
+func (c *clusterCMD) channel(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	cfg, err := c.channelClusterConfig(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	body, err := c.client.get(clusterChannelPath(channelId, channelType, "replicas"), nil)
+	if err != nil {
+		return err
+	}
+	var replicas []*channelReplica
+	if err := json.Unmarshal(body, &replicas); err != nil {
+		return err
+	}
+	if c.client.jsonOutput() {
+		return c.client.printValue(map[string]interface{}{
+			"config":   cfg,
+			"replicas": replicas,
+		})
+	}
+
+	c.client.printTable([]string{"CHANNEL", "TYPE", "SLOT", "SLOT_LEADER", "LEADER", "TERM", "REPLICAS", "LAST_SEQ", "STATUS", "MIGRATE"}, [][]string{{
+		cfg.ChannelId,
+		fmt.Sprintf("%d", cfg.ChannelType),
+		fmt.Sprintf("%d", cfg.SlotId),
+		fmt.Sprintf("%d", cfg.SlotLeaderId),
+		fmt.Sprintf("%d", cfg.LeaderId),
+		fmt.Sprintf("%d", cfg.Term),
+		uint64sToString(cfg.Replicas),
+		fmt.Sprintf("%d", cfg.LastMessageSeq),
+		cfg.StatusFormat,
+		fmt.Sprintf("%d->%d", cfg.MigrateFrom, cfg.MigrateTo),
+	}})
+	fmt.Println()
+	rows := make([][]string, 0, len(replicas))
+	for _, r := range replicas {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", r.ReplicaId),
+			r.RoleFormat,
+			boolIntToString(r.Running),
+			fmt.Sprintf("%d", r.LastMsgSeq),
+			r.LastMsgTimeFormat,
+		})
+	}
+	c.client.printTable([]string{"REPLICA", "ROLE", "RUNNING", "LAST_SEQ", "LAST_MSG"}, rows)
+	return nil
+}
+
+func (c *clusterCMD) channelClusterConfig(channelId string, channelType uint8) (*cluster.ChannelClusterConfigResp, error) {
+	body, err := c.client.get(clusterChannelPath(channelId, channelType, "config"), nil)
+	if err != nil {
+		return nil, err
+	}
+	var cfg *cluster.ChannelClusterConfigResp
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *clusterCMD) slotClusterConfig(slotId uint32) (*cluster.SlotClusterConfigResp, error) {
+	body, err := c.client.get(fmt.Sprintf("/cluster/slots/%d/config", slotId), nil)
+	if err != nil {
+		return nil, err
+	}
+	var cfg *cluster.SlotClusterConfigResp
+	if err := json.Unmarshal(body, &cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func (c *clusterCMD) migrateSlot(cmd *cobra.Command, args []string) error {
+	slotId := wkutil.ParseUint32(args[0])
+	return c.requestSlotMigrate(slotId, c.from, c.to)
+}
+
+func (c *clusterCMD) migrateChannel(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	return c.requestChannelMigrate(channelId, channelType, c.from, c.to)
+}
+
+// 领导转移就是从领导迁移到其他副本
+func (c *clusterCMD) transferSlotLeader(cmd *cobra.Command, args []string) error {
+	slotId := wkutil.ParseUint32(args[0])
+	cfg, err := c.slotClusterConfig(slotId)
+	if err != nil {
+		return err
+	}
+	if cfg.LeaderId == c.to {
+		return fmt.Errorf("node %d is already the leader of slot %d", c.to, slotId)
+	}
+	if !wkutil.ArrayContainsUint64(cfg.Replicas, c.to) {
+		return fmt.Errorf("node %d is not a replica of slot %d, replicas: %s", c.to, slotId, uint64sToString(cfg.Replicas))
+	}
+	return c.requestSlotMigrate(slotId, cfg.LeaderId, c.to)
+}
+
+func (c *clusterCMD) transferChannelLeader(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	cfg, err := c.channelClusterConfig(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if cfg.LeaderId == 0 {
+		return errors.New("channel has no leader")
+	}
+	if cfg.LeaderId == c.to {
+		return fmt.Errorf("node %d is already the leader of the channel", c.to)
+	}
+	if !wkutil.ArrayContainsUint64(cfg.Replicas, c.to) {
+		return fmt.Errorf("node %d is not a replica of the channel, replicas: %s", c.to, uint64sToString(cfg.Replicas))
+	}
+	return c.requestChannelMigrate(channelId, channelType, cfg.LeaderId, c.to)
+}
+
+func (c *clusterCMD) requestSlotMigrate(slotId uint32, from, to uint64) error {
+	body, err := c.client.post(fmt.Sprintf("/cluster/slots/%d/migrate", slotId), map[string]interface{}{
+		"migrate_from": from,
+		"migrate_to":   to,
+	})
+	if err != nil {
+		return err
+	}
+	return c.client.printResult(body, fmt.Sprintf("slot %d: migrating from node %d to node %d", slotId, from, to))
+}
+
+func (c *clusterCMD) requestChannelMigrate(channelId string, channelType uint8, from, to uint64) error {
+	body, err := c.client.post(clusterChannelPath(channelId, channelType, "migrate"), map[string]interface{}{
+		"migrate_from": from,
+		"migrate_to":   to,
+	})
+	if err != nil {
+		return err
+	}
+	return c.client.printResult(body, fmt.Sprintf("channel %s(%d): migrating from node %d to node %d", channelId, channelType, from, to))
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/server"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/spf13/cobra"
+)
+
+// 数据库的离线维护（需要在服务停止时执行）
+type dbCMD struct {
+	ctx *WuKongIMContext
+
+	shardNum int    // 新的分区数量
+	out      string // 重新分区后的数据目录
+	swap     bool   // 完成后是否替换原数据
+
+	inspect dbInspectFlags // 离线查看和修复的参数
+}
+
+func newDbCMD(ctx *WuKongIMContext) *dbCMD {
+	return &dbCMD{
+		ctx: ctx,
+	}
+}
+
+func (d *dbCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "db",
+		Short: "offline maintenance of the node database (server must be stopped)",
+	}
+	reshardCmd := &cobra.Command{
+		Use:   "reshard",
+		Short: "rewrite the database into a new shard count and verify counts and checksums",
+		RunE:  d.reshard,
+	}
+	reshardCmd.Flags().IntVar(&d.shardNum, "shards", 0, "new shard count")
+	reshardCmd.Flags().StringVar(&d.out, "out", "", "output data dir (default: {dataDir}/db-reshard)")
+	reshardCmd.Flags().BoolVar(&d.swap, "swap", false, "replace the database with the resharded one after verification, the old one is kept as a backup")
+	_ = reshardCmd.MarkFlagRequired("shards")
+	cmd.AddCommand(reshardCmd)
+	cmd.AddCommand(d.inspectCMD())
+	cmd.AddCommand(d.repairCMD())
+	return cmd
+}
+
+func (d *dbCMD) reshard(cmd *cobra.Command, args []string) error {
+	if d.shardNum <= 0 {
+		return errors.New("shards must be greater than 0")
+	}
+	srcDir := path.Join(serverOpts.DataDir, "db")
+	out := d.out
+	if out == "" {
+		out = path.Join(serverOpts.DataDir, "db-reshard")
+	}
+
+	opts := []wkdb.Option{wkdb.WithMemTableSize(serverOpts.Db.MemTableSize)}
+	if serverOpts.Encryption.On {
+		fs, _, err := server.NewEncryptFS(serverOpts)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, wkdb.WithEncryptFS(fs))
+	}
+
+	start := time.Now()
+	result, err := wkdb.Reshard(srcDir, out, d.shardNum, opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("resharded %d -> %d shards in %s, %d keys, checksum %x\n", result.SrcShardNum, result.ShardNum, time.Since(start).Round(time.Millisecond), result.KeyCount, result.Checksum)
+	for i, count := range result.ShardKeyCounts {
+		fmt.Printf("  shard%03d: %d keys\n", i, count)
+	}
+
+	if !d.swap {
+		fmt.Printf("resharded data is in %s, move %s to %s and set db.shardNum to %d to use it\n", out, path.Join(out, "wukongimdb"), path.Join(srcDir, "wukongimdb"), d.shardNum)
+		return nil
+	}
+	backup := path.Join(srcDir, fmt.Sprintf("wukongimdb.bak.%d", time.Now().Unix()))
+	if err = os.Rename(path.Join(srcDir, "wukongimdb"), backup); err != nil {
+		return err
+	}
+	if err = os.Rename(path.Join(out, "wukongimdb"), path.Join(srcDir, "wukongimdb")); err != nil {
+		return err
+	}
+	_ = os.Remove(out)
+	fmt.Printf("database replaced, the old one is backed up to %s, set db.shardNum to %d before starting the server\n", backup, d.shardNum)
+	return nil
+}
@@ -0,0 +1,742 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/internal/server"
+	cluster "github.com/WuKongIM/WuKongIM/pkg/cluster/clusterserver"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/spf13/cobra"
+)
+
+// 离线查看数据的参数
+type dbInspectFlags struct {
+	outputPrinter
+	limit    int    // 查询数量
+	startSeq uint64 // 开始的消息序号或日志下标（包含）
+	endSeq   uint64 // 结束的消息序号或日志下标（不包含） 0表示不限制
+	slotId   int    // 槽ID -1表示不限制
+	fromSeq  uint64 // 从此消息序号开始截断（包含）
+	index    uint64 // 重置后的应用下标
+	yes      bool   // 确认执行修复，否则只打印将要执行的操作
+}
+
+func (d *dbCMD) inspectCMD() *cobra.Command {
+	f := &d.inspect
+	cmd := &cobra.Command{
+		Use:   "inspect",
+		Short: "dump the data of a stopped node (read only)",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return f.checkOutput()
+		},
+	}
+	cmd.PersistentFlags().StringVarP(&f.output, "output", "o", outputTable, "output format: table or json")
+
+	channelsCmd := &cobra.Command{
+		Use:   "channels",
+		Short: "list channels, newest first",
+		Args:  cobra.NoArgs,
+		RunE:  d.inspectChannels,
+	}
+	channelsCmd.Flags().IntVar(&f.limit, "limit", 100, "max number of channels")
+	cmd.AddCommand(channelsCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "channel <channel_id> <channel_type>",
+		Short: "show the channel info, log state and cluster config of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE:  d.inspectChannel,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "subscribers <channel_id> <channel_type>",
+		Short: "list the subscribers of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE:  d.inspectSubscribers,
+	})
+
+	messagesCmd := &cobra.Command{
+		Use:   "messages <channel_id> <channel_type>",
+		Short: "list the messages of a channel by seq",
+		Args:  cobra.ExactArgs(2),
+		RunE:  d.inspectMessages,
+	}
+	messagesCmd.Flags().Uint64Var(&f.startSeq, "start", 0, "start message seq (inclusive), 0 means the last messages")
+	messagesCmd.Flags().Uint64Var(&f.endSeq, "end", 0, "end message seq (exclusive), 0 means no limit")
+	messagesCmd.Flags().IntVar(&f.limit, "limit", 100, "max number of messages")
+	cmd.AddCommand(messagesCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "conversations <uid>",
+		Short: "list the conversations of a user",
+		Args:  cobra.ExactArgs(1),
+		RunE:  d.inspectConversations,
+	})
+
+	configsCmd := &cobra.Command{
+		Use:   "channel-configs",
+		Short: "list channel cluster configs",
+		Args:  cobra.NoArgs,
+		RunE:  d.inspectChannelConfigs,
+	}
+	configsCmd.Flags().IntVar(&f.slotId, "slot", -1, "only list the configs of the slot")
+	configsCmd.Flags().IntVar(&f.limit, "limit", 100, "max number of configs")
+	cmd.AddCommand(configsCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "leader-terms <channel_id> <channel_type>",
+		Short: "list the leader term start indexes of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE:  d.inspectLeaderTerms,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "slot <slot_id>",
+		Short: "show the applied index, last index and leader terms of a slot",
+		Args:  cobra.ExactArgs(1),
+		RunE:  d.inspectSlot,
+	})
+
+	slotLogsCmd := &cobra.Command{
+		Use:   "slot-logs <slot_id>",
+		Short: "list the logs of a slot",
+		Args:  cobra.ExactArgs(1),
+		RunE:  d.inspectSlotLogs,
+	}
+	slotLogsCmd.Flags().Uint64Var(&f.startSeq, "start", 0, "start log index (inclusive), 0 means the last logs")
+	slotLogsCmd.Flags().Uint64Var(&f.endSeq, "end", 0, "end log index (exclusive), 0 means no limit")
+	slotLogsCmd.Flags().IntVar(&f.limit, "limit", 100, "max number of logs")
+	cmd.AddCommand(slotLogsCmd)
+
+	return cmd
+}
+
+func (d *dbCMD) repairCMD() *cobra.Command {
+	f := &d.inspect
+	cmd := &cobra.Command{
+		Use:   "repair",
+		Short: "repair the data of a stopped node, only prints the plan unless --yes is given",
+	}
+	cmd.PersistentFlags().BoolVar(&f.yes, "yes", false, "apply the repair")
+
+	truncateCmd := &cobra.Command{
+		Use:   "truncate-channel <channel_id> <channel_type>",
+		Short: "delete the messages of a channel from --from (inclusive), the replica resyncs them from the leader on start",
+		Args:  cobra.ExactArgs(2),
+		RunE:  d.repairTruncateChannel,
+	}
+	truncateCmd.Flags().Uint64Var(&f.fromSeq, "from", 0, "first message seq to delete")
+	_ = truncateCmd.MarkFlagRequired("from")
+	cmd.AddCommand(truncateCmd)
+
+	resetCmd := &cobra.Command{
+		Use:   "reset-applied",
+		Short: "reset the applied index of a channel or slot, logs after it are applied again on start",
+	}
+	resetChannelCmd := &cobra.Command{
+		Use:   "channel <channel_id> <channel_type>",
+		Short: "reset the applied index of a channel",
+		Args:  cobra.ExactArgs(2),
+		RunE:  d.repairResetChannelApplied,
+	}
+	resetSlotCmd := &cobra.Command{
+		Use:   "slot <slot_id>",
+		Short: "reset the applied index of a slot",
+		Args:  cobra.ExactArgs(1),
+		RunE:  d.repairResetSlotApplied,
+	}
+	for _, sub := range []*cobra.Command{resetChannelCmd, resetSlotCmd} {
+		sub.Flags().Uint64Var(&f.index, "index", 0, "new applied index, must not be greater than the last index")
+		_ = sub.MarkFlagRequired("index")
+		resetCmd.AddCommand(sub)
+	}
+	cmd.AddCommand(resetCmd)
+
+	return cmd
+}
+
+func inspectEncryptFS() (*encryption.FS, error) {
+	if !serverOpts.Encryption.On {
+		return nil, nil
+	}
+	fs, _, err := server.NewEncryptFS(serverOpts)
+	return fs, err
+}
+
+// 打开节点的数据库，readOnly为true时以只读方式打开
+func openInspectDB(readOnly bool) (wkdb.DB, error) {
+	if trace.GlobalTrace == nil {
+		trace.SetGlobalTrace(trace.New(context.Background(), trace.NewOptions()))
+	}
+	dataDir := path.Join(serverOpts.DataDir, "db")
+	shardNum, err := wkdb.ShardNumOf(dataDir)
+	if err != nil {
+		return nil, err
+	}
+	if shardNum == 0 {
+		return nil, fmt.Errorf("no database found in %s", dataDir)
+	}
+	opts := []wkdb.Option{
+		wkdb.WithDir(dataDir),
+		wkdb.WithNodeId(serverOpts.Cluster.NodeId),
+		wkdb.WithShardNum(shardNum),
+		wkdb.WithSlotCount(int(serverOpts.Cluster.SlotCount)),
+		wkdb.WithMemTableSize(serverOpts.Db.MemTableSize),
+	}
+	fs, err := inspectEncryptFS()
+	if err != nil {
+		return nil, err
+	}
+	if fs != nil {
+		opts = append(opts, wkdb.WithEncryptFS(fs))
+	}
+	if readOnly {
+		opts = append(opts, wkdb.WithReadOnly())
+	}
+	db := wkdb.NewWukongDB(wkdb.NewOptions(opts...))
+	if err = db.Open(); err != nil {
+		return nil, fmt.Errorf("open database error, make sure the server is stopped: %w", err)
+	}
+	return db, nil
+}
+
+// 打开节点的槽日志存储，readOnly为true时以只读方式打开
+func openInspectSlotStorage(readOnly bool) (*cluster.PebbleShardLogStorage, error) {
+	fs, err := inspectEncryptFS()
+	if err != nil {
+		return nil, err
+	}
+	dir := path.Join(serverOpts.DataDir, "cluster", "logdb")
+	shardNum := uint32(serverOpts.Db.SlotShardNum)
+	var storage *cluster.PebbleShardLogStorage
+	if readOnly {
+		storage = cluster.NewReadOnlyPebbleShardLogStorage(dir, shardNum, fs)
+	} else {
+		storage = cluster.NewPebbleShardLogStorage(dir, shardNum, fs)
+	}
+	if err = storage.Open(); err != nil {
+		return nil, fmt.Errorf("open slot storage error, make sure the server is stopped: %w", err)
+	}
+	return storage, nil
+}
+
+func (d *dbCMD) inspectChannels(cmd *cobra.Command, args []string) error {
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	channels, err := db.SearchChannels(wkdb.ChannelSearchReq{Limit: d.inspect.limit})
+	if err != nil {
+		return err
+	}
+	if len(channels) > d.inspect.limit {
+		channels = channels[:d.inspect.limit]
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(channels)
+	}
+	rows := make([][]string, 0, len(channels))
+	for _, ch := range channels {
+		rows = append(rows, []string{
+			ch.ChannelId,
+			fmt.Sprintf("%d", ch.ChannelType),
+			fmt.Sprintf("%t", ch.Ban),
+			fmt.Sprintf("%t", ch.Disband),
+			fmt.Sprintf("%d", ch.SubscriberCount),
+			fmt.Sprintf("%d", ch.LastMsgSeq),
+			formatTimePtr(ch.CreatedAt),
+		})
+	}
+	d.inspect.printTable([]string{"CHANNEL", "TYPE", "BAN", "DISBAND", "SUBSCRIBERS", "LAST_SEQ", "CREATED"}, rows)
+	return nil
+}
+
+// 频道的存储状态
+type inspectChannelResult struct {
+	Info          wkdb.ChannelInfo            `json:"info"`
+	LastMsgSeq    uint64                      `json:"last_msg_seq"`
+	LastMsgTerm   uint32                      `json:"last_msg_term"`
+	AppliedIndex  uint64                      `json:"applied_index"`
+	ClusterConfig *wkdb.ChannelClusterConfig  `json:"cluster_config,omitempty"`
+	LeaderTerms   []wkdb.LeaderTermStartIndex `json:"leader_terms"`
+}
+
+func (d *dbCMD) inspectChannel(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	result := &inspectChannelResult{}
+	result.Info, err = db.GetChannel(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	logStorage := clusterstore.NewMessageShardLogStorage(db)
+	shardNo := wkutil.ChannelToKey(channelId, channelType)
+	result.LastMsgSeq, result.LastMsgTerm, err = logStorage.LastIndexAndTerm(shardNo)
+	if err != nil {
+		return err
+	}
+	result.AppliedIndex, err = logStorage.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	cfg, err := db.GetChannelClusterConfig(channelId, channelType)
+	if err != nil && err != wkdb.ErrNotFound {
+		return err
+	}
+	if err == nil {
+		result.ClusterConfig = &cfg
+	}
+	result.LeaderTerms, err = db.LeaderTermStartIndexes(shardNo)
+	if err != nil {
+		return err
+	}
+
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(result)
+	}
+	d.inspect.printTable([]string{"CHANNEL", "TYPE", "SUBSCRIBERS", "LAST_SEQ", "LAST_TERM", "APPLIED", "CREATED", "UPDATED"}, [][]string{{
+		channelId,
+		fmt.Sprintf("%d", channelType),
+		fmt.Sprintf("%d", result.Info.SubscriberCount),
+		fmt.Sprintf("%d", result.LastMsgSeq),
+		fmt.Sprintf("%d", result.LastMsgTerm),
+		fmt.Sprintf("%d", result.AppliedIndex),
+		formatTimePtr(result.Info.CreatedAt),
+		formatTimePtr(result.Info.UpdatedAt),
+	}})
+	if result.ClusterConfig != nil {
+		fmt.Println()
+		d.inspect.printTable(channelConfigHeader, [][]string{channelConfigRow(*result.ClusterConfig)})
+	}
+	fmt.Println()
+	d.inspect.printTable([]string{"TERM", "START_INDEX"}, leaderTermRows(result.LeaderTerms))
+	return nil
+}
+
+func (d *dbCMD) inspectSubscribers(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	members, err := db.GetSubscribers(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(members)
+	}
+	rows := make([][]string, 0, len(members))
+	for _, m := range members {
+		rows = append(rows, []string{
+			m.Uid,
+			fmt.Sprintf("%d", m.Role),
+			fmt.Sprintf("%d", m.MuteUntil),
+			formatTimePtr(m.CreatedAt),
+		})
+	}
+	d.inspect.printTable([]string{"UID", "ROLE", "MUTE_UNTIL", "CREATED"}, rows)
+	return nil
+}
+
+func (d *dbCMD) inspectMessages(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var messages []wkdb.Message
+	if d.inspect.startSeq == 0 {
+		messages, err = db.LoadLastMsgsWithEnd(channelId, channelType, d.inspect.endSeq, d.inspect.limit)
+	} else {
+		messages, err = db.LoadNextRangeMsgs(channelId, channelType, d.inspect.startSeq, d.inspect.endSeq, d.inspect.limit)
+	}
+	if err != nil {
+		return err
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(messages)
+	}
+	rows := make([][]string, 0, len(messages))
+	for _, m := range messages {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", m.MessageSeq),
+			fmt.Sprintf("%d", m.MessageID),
+			fmt.Sprintf("%d", m.Term),
+			m.ClientMsgNo,
+			m.FromUID,
+			wkutil.ToyyyyMMddHHmmss(time.Unix(int64(m.Timestamp), 0)),
+			fmt.Sprintf("%d", len(m.Payload)),
+		})
+	}
+	d.inspect.printTable([]string{"SEQ", "MESSAGE_ID", "TERM", "CLIENT_MSG_NO", "FROM", "TIME", "PAYLOAD_BYTES"}, rows)
+	return nil
+}
+
+func (d *dbCMD) inspectConversations(cmd *cobra.Command, args []string) error {
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conversations, err := db.GetConversations(args[0])
+	if err != nil {
+		return err
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(conversations)
+	}
+	rows := make([][]string, 0, len(conversations))
+	for _, c := range conversations {
+		rows = append(rows, []string{
+			c.ChannelId,
+			fmt.Sprintf("%d", c.ChannelType),
+			fmt.Sprintf("%d", c.Type),
+			fmt.Sprintf("%d", c.UnreadCount),
+			fmt.Sprintf("%d", c.ReadToMsgSeq),
+			formatTimePtr(c.UpdatedAt),
+		})
+	}
+	d.inspect.printTable([]string{"CHANNEL", "TYPE", "CONVERSATION_TYPE", "UNREAD", "READ_TO_SEQ", "UPDATED"}, rows)
+	return nil
+}
+
+var channelConfigHeader = []string{"CHANNEL", "TYPE", "LEADER", "TERM", "REPLICAS", "LEARNERS", "MIGRATE", "STATUS", "CONF_VERSION"}
+
+func channelConfigRow(cfg wkdb.ChannelClusterConfig) []string {
+	return []string{
+		cfg.ChannelId,
+		fmt.Sprintf("%d", cfg.ChannelType),
+		fmt.Sprintf("%d", cfg.LeaderId),
+		fmt.Sprintf("%d", cfg.Term),
+		uint64sToString(cfg.Replicas),
+		uint64sToString(cfg.Learners),
+		fmt.Sprintf("%d->%d", cfg.MigrateFrom, cfg.MigrateTo),
+		fmt.Sprintf("%d", cfg.Status),
+		fmt.Sprintf("%d", cfg.ConfVersion),
+	}
+}
+
+func (d *dbCMD) inspectChannelConfigs(cmd *cobra.Command, args []string) error {
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var configs []wkdb.ChannelClusterConfig
+	if d.inspect.slotId >= 0 {
+		configs, err = db.GetChannelClusterConfigWithSlotId(uint32(d.inspect.slotId))
+	} else {
+		configs, err = db.GetChannelClusterConfigs(0, d.inspect.limit)
+	}
+	if err != nil {
+		return err
+	}
+	if len(configs) > d.inspect.limit {
+		configs = configs[:d.inspect.limit]
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(configs)
+	}
+	rows := make([][]string, 0, len(configs))
+	for _, cfg := range configs {
+		rows = append(rows, channelConfigRow(cfg))
+	}
+	d.inspect.printTable(channelConfigHeader, rows)
+	return nil
+}
+
+func leaderTermRows(indexes []wkdb.LeaderTermStartIndex) [][]string {
+	rows := make([][]string, 0, len(indexes))
+	for _, idx := range indexes {
+		rows = append(rows, []string{fmt.Sprintf("%d", idx.Term), fmt.Sprintf("%d", idx.StartIndex)})
+	}
+	return rows
+}
+
+func (d *dbCMD) inspectLeaderTerms(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	db, err := openInspectDB(true)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	indexes, err := db.LeaderTermStartIndexes(wkutil.ChannelToKey(channelId, channelType))
+	if err != nil {
+		return err
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(indexes)
+	}
+	d.inspect.printTable([]string{"TERM", "START_INDEX"}, leaderTermRows(indexes))
+	return nil
+}
+
+// 槽的存储状态
+type inspectSlotResult struct {
+	SlotId       uint32                      `json:"slot_id"`
+	LastIndex    uint64                      `json:"last_index"`
+	LastTerm     uint32                      `json:"last_term"`
+	AppliedIndex uint64                      `json:"applied_index"`
+	LeaderTerms  []wkdb.LeaderTermStartIndex `json:"leader_terms"`
+}
+
+func (d *dbCMD) inspectSlot(cmd *cobra.Command, args []string) error {
+	slotId := wkutil.ParseUint32(args[0])
+	storage, err := openInspectSlotStorage(true)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	shardNo := cluster.SlotIdToKey(slotId)
+	result := &inspectSlotResult{SlotId: slotId}
+	result.LastIndex, result.LastTerm, err = storage.LastIndexAndTerm(shardNo)
+	if err != nil {
+		return err
+	}
+	result.AppliedIndex, err = storage.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	result.LeaderTerms, err = storage.LeaderTermStartIndexes(shardNo)
+	if err != nil {
+		return err
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(result)
+	}
+	d.inspect.printTable([]string{"SLOT", "LAST_INDEX", "LAST_TERM", "APPLIED"}, [][]string{{
+		fmt.Sprintf("%d", slotId),
+		fmt.Sprintf("%d", result.LastIndex),
+		fmt.Sprintf("%d", result.LastTerm),
+		fmt.Sprintf("%d", result.AppliedIndex),
+	}})
+	fmt.Println()
+	d.inspect.printTable([]string{"TERM", "START_INDEX"}, leaderTermRows(result.LeaderTerms))
+	return nil
+}
+
+func (d *dbCMD) inspectSlotLogs(cmd *cobra.Command, args []string) error {
+	slotId := wkutil.ParseUint32(args[0])
+	storage, err := openInspectSlotStorage(true)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	shardNo := cluster.SlotIdToKey(slotId)
+	var logs []replica.Log
+	if d.inspect.startSeq == 0 {
+		logs, err = storage.GetLogsInReverseOrder(shardNo, 0, d.inspect.endSeq, d.inspect.limit)
+		for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+			logs[i], logs[j] = logs[j], logs[i]
+		}
+	} else {
+		end := d.inspect.startSeq + uint64(d.inspect.limit)
+		if d.inspect.endSeq != 0 && d.inspect.endSeq < end {
+			end = d.inspect.endSeq
+		}
+		logs, err = storage.Logs(shardNo, d.inspect.startSeq, end, 0)
+	}
+	if err != nil {
+		return err
+	}
+
+	resps := make([]*cluster.LogResp, 0, len(logs))
+	for _, log := range logs {
+		resp, err := cluster.NewLogRespFromLog(log, cluster.LogTypeSlot)
+		if err != nil { // 损坏的日志也需要展示出来
+			resp = &cluster.LogResp{
+				Id:         log.Id,
+				Index:      log.Index,
+				Term:       log.Term,
+				Cmd:        "unknown",
+				Content:    fmt.Sprintf("decode error: %s, raw: %x", err, log.Data),
+				TimeFormat: wkutil.ToyyyyMMddHHmmss(log.Time),
+			}
+		}
+		resps = append(resps, resp)
+	}
+	if d.inspect.jsonOutput() {
+		return d.inspect.printValue(resps)
+	}
+	rows := make([][]string, 0, len(resps))
+	for _, resp := range resps {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", resp.Index),
+			fmt.Sprintf("%d", resp.Term),
+			resp.TimeFormat,
+			resp.Cmd,
+			resp.Content,
+		})
+	}
+	d.inspect.printTable([]string{"INDEX", "TERM", "TIME", "CMD", "DATA"}, rows)
+	return nil
+}
+
+// 截断频道的日志，与在线修复副本时的截断步骤一致：
+// 先回退应用下标，再删除消息，最后删除比剩余最后一条消息的任期大的领导任期记录
+func (d *dbCMD) repairTruncateChannel(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	fromSeq := d.inspect.fromSeq
+	if fromSeq == 0 {
+		return errors.New("--from must be greater than 0")
+	}
+	db, err := openInspectDB(!d.inspect.yes)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	logStorage := clusterstore.NewMessageShardLogStorage(db)
+	shardNo := wkutil.ChannelToKey(channelId, channelType)
+	lastSeq, err := logStorage.LastIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if fromSeq > lastSeq {
+		return fmt.Errorf("--from %d is greater than the last message seq %d, nothing to truncate", fromSeq, lastSeq)
+	}
+	appliedIdx, err := logStorage.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("channel %s(%d): delete messages %d-%d (%d messages), last message seq %d -> %d\n", channelId, channelType, fromSeq, lastSeq, lastSeq-fromSeq+1, lastSeq, fromSeq-1)
+	if appliedIdx >= fromSeq {
+		fmt.Printf("channel %s(%d): applied index %d -> %d\n", channelId, channelType, appliedIdx, fromSeq-1)
+	}
+	if !d.inspect.yes {
+		fmt.Println("dry run, re-run with --yes to apply")
+		return nil
+	}
+
+	if appliedIdx >= fromSeq {
+		if err = logStorage.SetAppliedIndex(shardNo, fromSeq-1); err != nil {
+			return err
+		}
+	}
+	if err = logStorage.TruncateLogTo(shardNo, fromSeq); err != nil {
+		return err
+	}
+	_, lastTerm, err := logStorage.LastIndexAndTerm(shardNo)
+	if err != nil {
+		return err
+	}
+	if err = logStorage.DeleteLeaderTermStartIndexGreaterThanTerm(shardNo, lastTerm); err != nil {
+		return err
+	}
+	fmt.Println("done")
+	return nil
+}
+
+func (d *dbCMD) repairResetChannelApplied(cmd *cobra.Command, args []string) error {
+	channelId, channelType, err := parseChannelArgs(args)
+	if err != nil {
+		return err
+	}
+	db, err := openInspectDB(!d.inspect.yes)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	logStorage := clusterstore.NewMessageShardLogStorage(db)
+	shardNo := wkutil.ChannelToKey(channelId, channelType)
+	lastSeq, err := logStorage.LastIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if d.inspect.index > lastSeq {
+		return fmt.Errorf("--index %d is greater than the last message seq %d", d.inspect.index, lastSeq)
+	}
+	appliedIdx, err := logStorage.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("channel %s(%d): applied index %d -> %d\n", channelId, channelType, appliedIdx, d.inspect.index)
+	if !d.inspect.yes {
+		fmt.Println("dry run, re-run with --yes to apply")
+		return nil
+	}
+	if err = logStorage.SetAppliedIndex(shardNo, d.inspect.index); err != nil {
+		return err
+	}
+	fmt.Println("done")
+	return nil
+}
+
+func (d *dbCMD) repairResetSlotApplied(cmd *cobra.Command, args []string) error {
+	slotId := wkutil.ParseUint32(args[0])
+	storage, err := openInspectSlotStorage(!d.inspect.yes)
+	if err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	shardNo := cluster.SlotIdToKey(slotId)
+	lastIdx, err := storage.LastIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if d.inspect.index > lastIdx {
+		return fmt.Errorf("--index %d is greater than the last log index %d", d.inspect.index, lastIdx)
+	}
+	appliedIdx, err := storage.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("slot %d: applied index %d -> %d\n", slotId, appliedIdx, d.inspect.index)
+	if !d.inspect.yes {
+		fmt.Println("dry run, re-run with --yes to apply")
+		return nil
+	}
+	if err = storage.SetAppliedIndex(shardNo, d.inspect.index); err != nil {
+		return err
+	}
+	fmt.Println("done")
+	return nil
+}
+
+func formatTimePtr(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return wkutil.ToyyyyMMddHHmmss(*t)
+}
@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/WuKongIM/WuKongIM/internal/server"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
+	"github.com/spf13/cobra"
+)
+
+// 静态数据加密的密钥管理以及数据迁移（migrate、decrypt需要在服务停止时执行）
+type encryptionCMD struct {
+	ctx *WuKongIMContext
+}
+
+func newEncryptionCMD(ctx *WuKongIMContext) *encryptionCMD {
+	return &encryptionCMD{
+		ctx: ctx,
+	}
+}
+
+func (e *encryptionCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "encryption",
+		Short: "manage encryption at rest keys and data",
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:   "keys",
+		Short: "list encryption keys",
+		RunE:  e.keys,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "rotate",
+		Short: "generate a new active key, new files are encrypted with it after the server restarts",
+		RunE:  e.rotate,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "remove-key [id]",
+		Short: "remove an old key (run migrate first so that no file uses it)",
+		Args:  cobra.ExactArgs(1),
+		RunE:  e.removeKey,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "migrate",
+		Short: "encrypt plaintext files and re-encrypt files of old keys with the active key (server must be stopped)",
+		RunE:  e.migrate,
+	})
+	cmd.AddCommand(&cobra.Command{
+		Use:   "decrypt",
+		Short: "decrypt all data files before turning off encryption (server must be stopped)",
+		RunE:  e.decrypt,
+	})
+	return cmd
+}
+
+func (e *encryptionCMD) provider() (*encryption.FS, *encryption.FileKeyProvider, error) {
+	if !serverOpts.Encryption.On {
+		return nil, nil, errors.New("encryption is not on, please set encryption.on to true")
+	}
+	return server.NewEncryptFS(serverOpts)
+}
+
+func (e *encryptionCMD) keys(cmd *cobra.Command, args []string) error {
+	_, provider, err := e.provider()
+	if err != nil {
+		return err
+	}
+	active, err := provider.ActiveKey()
+	if err != nil {
+		return err
+	}
+	for _, id := range provider.Keys() {
+		if id == active.Id {
+			fmt.Printf("%s (active)\n", id)
+		} else {
+			fmt.Println(id)
+		}
+	}
+	return nil
+}
+
+func (e *encryptionCMD) rotate(cmd *cobra.Command, args []string) error {
+	_, provider, err := e.provider()
+	if err != nil {
+		return err
+	}
+	key, err := provider.Rotate()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("new active key: %s\n", key.Id)
+	return nil
+}
+
+func (e *encryptionCMD) removeKey(cmd *cobra.Command, args []string) error {
+	fs, provider, err := e.provider()
+	if err != nil {
+		return err
+	}
+	if err = server.RemoveEncryptionKey(fs, provider, serverOpts, args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("key %s removed\n", args[0])
+	return nil
+}
+
+func (e *encryptionCMD) migrate(cmd *cobra.Command, args []string) error {
+	fs, _, err := e.provider()
+	if err != nil {
+		return err
+	}
+	count, err := server.EncryptDataDirs(fs, serverOpts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d files encrypted\n", count)
+	return nil
+}
+
+func (e *encryptionCMD) decrypt(cmd *cobra.Command, args []string) error {
+	fs, _, err := e.provider()
+	if err != nil {
+		return err
+	}
+	count, err := server.DecryptDataDirs(fs, serverOpts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d files decrypted\n", count)
+	return nil
+}
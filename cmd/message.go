@@ -0,0 +1,156 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	cluster "github.com/WuKongIM/WuKongIM/pkg/cluster/clusterserver"
+	"github.com/spf13/cobra"
+)
+
+// 消息的运维命令
+type messageCMD struct {
+	ctx    *WuKongIMContext
+	client *adminClient
+
+	clientMsgNo string        // 客户端消息编号
+	channelId   string        // 频道ID
+	channelType uint8         // 频道类型
+	limit       int           // 查询数量
+	since       time.Duration // 查询多久内的轨迹
+}
+
+func newMessageCMD(ctx *WuKongIMContext) *messageCMD {
+	return &messageCMD{
+		ctx:    ctx,
+		client: &adminClient{},
+	}
+}
+
+func (m *messageCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "message",
+		Short: "message administration (lookup, trace)",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return m.client.checkOutput()
+		},
+	}
+	m.client.bindFlags(cmd)
+
+	getCmd := &cobra.Command{
+		Use:   "get [message_id]",
+		Short: "look up messages by message id or client msg no",
+		Args:  cobra.MaximumNArgs(1),
+		RunE:  m.get,
+	}
+	getCmd.Flags().StringVar(&m.clientMsgNo, "client-msg-no", "", "client msg no of the message")
+	getCmd.Flags().StringVar(&m.channelId, "channel-id", "", "channel id of the message")
+	getCmd.Flags().Uint8Var(&m.channelType, "channel-type", 0, "channel type of the message")
+	getCmd.Flags().IntVar(&m.limit, "limit", 20, "max number of messages")
+	cmd.AddCommand(getCmd)
+
+	traceCmd := &cobra.Command{
+		Use:   "trace <client_msg_no>",
+		Short: "show the delivery trace of a message",
+		Args:  cobra.ExactArgs(1),
+		RunE:  m.trace,
+	}
+	traceCmd.Flags().DurationVar(&m.since, "since", time.Hour, "how far back to search the trace logs")
+	cmd.AddCommand(traceCmd)
+
+	return cmd
+}
+
+// 消息（/cluster/messages）
+type messageInfo struct {
+	MessageId       string `json:"message_id"`
+	MessageSeq      uint32 `json:"message_seq"`
+	ClientMsgNo     string `json:"client_msg_no"`
+	TimestampFormat string `json:"timestamp_format"`
+	ChannelId       string `json:"channel_id"`
+	ChannelType     uint8  `json:"channel_type"`
+	FromUid         string `json:"from_uid"`
+	Payload         []byte `json:"payload"`
+}
+
+func (m *messageCMD) get(cmd *cobra.Command, args []string) error {
+	query := map[string]string{
+		"limit": fmt.Sprintf("%d", m.limit),
+	}
+	if len(args) > 0 {
+		query["message_id"] = args[0]
+	}
+	if m.clientMsgNo != "" {
+		query["client_msg_no"] = m.clientMsgNo
+	}
+	if query["message_id"] == "" && query["client_msg_no"] == "" {
+		return errors.New("message_id or --client-msg-no is required")
+	}
+	if m.channelId != "" {
+		query["channel_id"] = m.channelId
+		query["channel_type"] = fmt.Sprintf("%d", m.channelType)
+	}
+
+	body, err := m.client.get("/cluster/messages", query)
+	if err != nil {
+		return err
+	}
+	if m.client.jsonOutput() {
+		return m.client.printJSON(body)
+	}
+	var resp struct {
+		Data []*messageInfo `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resp.Data))
+	for _, msg := range resp.Data {
+		rows = append(rows, []string{
+			msg.MessageId,
+			fmt.Sprintf("%d", msg.MessageSeq),
+			msg.ClientMsgNo,
+			msg.ChannelId,
+			fmt.Sprintf("%d", msg.ChannelType),
+			msg.FromUid,
+			msg.TimestampFormat,
+			string(msg.Payload),
+		})
+	}
+	m.client.printTable([]string{"MESSAGE_ID", "SEQ", "CLIENT_MSG_NO", "CHANNEL", "TYPE", "FROM", "TIME", "PAYLOAD"}, rows)
+	return nil
+}
+
+func (m *messageCMD) trace(cmd *cobra.Command, args []string) error {
+	body, err := m.client.get("/cluster/message/trace", map[string]string{
+		"client_msg_no": args[0],
+		"since":         fmt.Sprintf("%d", int64(m.since.Seconds())),
+	})
+	if err != nil {
+		return err
+	}
+	if m.client.jsonOutput() {
+		return m.client.printJSON(body)
+	}
+	var trace cluster.Trace
+	if err := json.Unmarshal(body, &trace); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(trace.Nodes))
+	for _, node := range trace.Nodes {
+		if node.Time == "" { // 未经过的环节
+			continue
+		}
+		rows = append(rows, []string{
+			node.Name,
+			fmt.Sprintf("%d", node.NodeId),
+			node.Time,
+			time.Duration(node.Duration).String(),
+			node.Description,
+		})
+	}
+	m.client.printTable([]string{"SPAN", "NODE", "TIME", "DURATION", "DESCRIPTION"}, rows)
+	return nil
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/spf13/cobra"
+)
+
+type reloadCMD struct {
+	ctx *WuKongIMContext
+}
+
+func newReloadCMD(ctx *WuKongIMContext) *reloadCMD {
+	return &reloadCMD{
+		ctx: ctx,
+	}
+}
+
+func (r *reloadCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "reload",
+		Short: "reload the config file of the WuKongIM server (send SIGHUP)",
+		RunE:  r.run,
+	}
+	return cmd
+}
+
+func (r *reloadCMD) run(cmd *cobra.Command, args []string) error {
+	strb, err := os.ReadFile(path.Join(".", pidfile))
+	if err != nil {
+		return err
+	}
+
+	pid := wkutil.ParseInt(string(strb))
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	err = process.Signal(syscall.SIGHUP)
+	if err != nil {
+		return err
+	}
+	fmt.Println("WuKongIM server config reloading, see the server log for the result")
+	return nil
+}
@@ -10,10 +10,12 @@ import (
 	"net"
 	"os"
 	"os/exec"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/internal/server"
@@ -140,7 +142,19 @@ func cmdRun() error {
 			}
 		}
 
-		select {}
+		// 收到SIGHUP信号时重新加载配置
+		sigC := make(chan os.Signal, 1)
+		signal.Notify(sigC, syscall.SIGHUP)
+		for range sigC {
+			result, err := s.ReloadConfig()
+			if err != nil {
+				wklog.Error("reload config error", zap.Error(err))
+				continue
+			}
+			if len(result.RestartRequired) > 0 {
+				wklog.Warn("some config changes require a restart to take effect", zap.Strings("keys", result.RestartRequired))
+			}
+		}
 
 	}
 	return nil
@@ -261,6 +275,13 @@ func addCommand(cmd CMD) {
 func Execute() {
 	ctx := &WuKongIMContext{}
 	addCommand(newStopCMD(ctx))
+	addCommand(newReloadCMD(ctx))
+	addCommand(newEncryptionCMD(ctx))
+	addCommand(newDbCMD(ctx))
+	addCommand(newClusterCMD(ctx))
+	addCommand(newUserCMD(ctx))
+	addCommand(newChannelCMD(ctx))
+	addCommand(newMessageCMD(ctx))
 	if err := rootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/WuKongIM/WuKongIM/internal/server"
+	"github.com/spf13/cobra"
+)
+
+// 用户的运维命令
+type userCMD struct {
+	ctx    *WuKongIMContext
+	client *adminClient
+
+	token       string // 用户token
+	deviceFlag  int    // 设备标识
+	deviceLevel int    // 设备等级
+	expire      int64  // token有效期（秒）
+}
+
+func newUserCMD(ctx *WuKongIMContext) *userCMD {
+	return &userCMD{
+		ctx:    ctx,
+		client: &adminClient{},
+	}
+}
+
+func (u *userCMD) CMD() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "user",
+		Short: "user administration (token, quit, online status)",
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return u.client.checkOutput()
+		},
+	}
+	u.client.bindFlags(cmd)
+
+	tokenCmd := &cobra.Command{
+		Use:   "token <uid>",
+		Short: "set the token of a user device",
+		Args:  cobra.ExactArgs(1),
+		RunE:  u.updateToken,
+	}
+	tokenCmd.Flags().StringVar(&u.token, "user-token", "", "token of the user")
+	tokenCmd.Flags().IntVar(&u.deviceFlag, "device-flag", 0, "device flag: 0.app 1.web 2.pc")
+	tokenCmd.Flags().IntVar(&u.deviceLevel, "device-level", 1, "device level: 0.slave 1.master")
+	tokenCmd.Flags().Int64Var(&u.expire, "expire", 0, "token ttl in seconds, 0 means never expire")
+	_ = tokenCmd.MarkFlagRequired("user-token")
+	cmd.AddCommand(tokenCmd)
+
+	quitCmd := &cobra.Command{
+		Use:   "quit <uid>",
+		Short: "force the devices of a user to quit",
+		Args:  cobra.ExactArgs(1),
+		RunE:  u.quit,
+	}
+	quitCmd.Flags().IntVar(&u.deviceFlag, "device-flag", -1, "device flag: 0.app 1.web 2.pc -1.all devices")
+	cmd.AddCommand(quitCmd)
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "online <uid>...",
+		Short: "show the online devices of users",
+		Args:  cobra.MinimumNArgs(1),
+		RunE:  u.online,
+	})
+	return cmd
+}
+
+func (u *userCMD) updateToken(cmd *cobra.Command, args []string) error {
+	uid := args[0]
+	body, err := u.client.post("/user/token", map[string]interface{}{
+		"uid":          uid,
+		"token":        u.token,
+		"device_flag":  u.deviceFlag,
+		"device_level": u.deviceLevel,
+		"expire":       u.expire,
+	})
+	if err != nil {
+		return err
+	}
+	return u.client.printResult(body, fmt.Sprintf("token of user %s (device flag %d) updated", uid, u.deviceFlag))
+}
+
+func (u *userCMD) quit(cmd *cobra.Command, args []string) error {
+	uid := args[0]
+	body, err := u.client.post("/user/device_quit", map[string]interface{}{
+		"uid":         uid,
+		"device_flag": u.deviceFlag,
+	})
+	if err != nil {
+		return err
+	}
+	return u.client.printResult(body, fmt.Sprintf("devices of user %s (device flag %d) quit", uid, u.deviceFlag))
+}
+
+func (u *userCMD) online(cmd *cobra.Command, args []string) error {
+	body, err := u.client.post("/user/onlinestatus", args)
+	if err != nil {
+		return err
+	}
+	if u.client.jsonOutput() {
+		return u.client.printJSON(body)
+	}
+	var resps []*server.OnlinestatusResp
+	if err := json.Unmarshal(body, &resps); err != nil {
+		return err
+	}
+	rows := make([][]string, 0, len(resps))
+	for _, resp := range resps {
+		rows = append(rows, []string{
+			resp.UID,
+			fmt.Sprintf("%d", resp.DeviceFlag),
+			boolIntToString(resp.Online),
+		})
+	}
+	u.client.printTable([]string{"UID", "DEVICE_FLAG", "ONLINE"}, rows)
+	return nil
+}
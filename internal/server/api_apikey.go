@@ -0,0 +1,396 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// ApiKeyAPI api key管理相关api
+type ApiKeyAPI struct {
+	s *Server
+	wklog.Log
+}
+
+func NewApiKeyAPI(s *Server) *ApiKeyAPI {
+	return &ApiKeyAPI{
+		s:   s,
+		Log: wklog.NewWKLog("ApiKeyAPI"),
+	}
+}
+
+// Route 路由
+func (a *ApiKeyAPI) Route(r *wkhttp.WKHttp) {
+	r.POST("/apikey/create", a.create) // 创建api key
+	r.POST("/apikey/update", a.update) // 更新api key的授权范围、过期时间、禁用状态
+	r.POST("/apikey/rotate", a.rotate) // 轮换api key的密钥
+	r.POST("/apikey/delete", a.delete) // 删除api key
+	r.GET("/apikey/list", a.list)      // 获取api key列表及当前节点的调用统计
+}
+
+func (a *ApiKeyAPI) create(c *wkhttp.Context) {
+	var req apiKeyCreateReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err := a.checkGrant(c, req.Scopes); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if a.forwardToLeaderIfNeed(c, bodyBytes) {
+		return
+	}
+
+	_, err = a.s.store.GetApiKey(req.Name)
+	if err == nil {
+		c.ResponseError(errors.New("api key已存在！"))
+		return
+	}
+	if err != wkdb.ErrNotFound {
+		a.Error("获取api key失败！", zap.Error(err), zap.String("name", req.Name))
+		c.ResponseError(errors.New("获取api key失败！"))
+		return
+	}
+
+	secret, err := newApiKeySecret()
+	if err != nil {
+		a.Error("生成api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("生成api key失败！"))
+		return
+	}
+	now := time.Now()
+	apiKey := wkdb.ApiKey{
+		Name:      req.Name,
+		KeyHash:   hashApiKey(secret),
+		Scopes:    req.Scopes,
+		ExpireAt:  tokenExpireAt(now, req.Expire),
+		CreatedAt: &now,
+		UpdatedAt: &now,
+	}
+	if err = a.s.store.AddOrUpdateApiKey(apiKey); err != nil {
+		a.Error("保存api key失败！", zap.Error(err), zap.String("name", req.Name))
+		c.ResponseError(errors.New("保存api key失败！"))
+		return
+	}
+	a.s.apiKeyManager.notifyChanged()
+	a.Info("api key created", zap.String("name", req.Name), zap.Strings("scopes", req.Scopes), zap.String("operator", a.operator(c)))
+
+	c.JSON(http.StatusOK, newApiKeyResp(apiKey, secret, nil))
+}
+
+func (a *ApiKeyAPI) update(c *wkhttp.Context) {
+	var req apiKeyUpdateReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if req.Scopes != nil {
+		if err := a.checkGrant(c, req.Scopes); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	if a.forwardToLeaderIfNeed(c, bodyBytes) {
+		return
+	}
+
+	apiKey, ok := a.getApiKey(c, req.Name)
+	if !ok {
+		return
+	}
+	// 禁用、延期等操作同样不能作用于超出自身权限的api key
+	if err := a.checkGrant(c, apiKey.Scopes); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	now := time.Now()
+	if req.Scopes != nil {
+		apiKey.Scopes = req.Scopes
+	}
+	if req.Disabled != nil {
+		apiKey.Disabled = *req.Disabled
+	}
+	if req.Expire != nil {
+		apiKey.ExpireAt = tokenExpireAt(now, *req.Expire)
+	}
+	apiKey.UpdatedAt = &now
+	if err = a.s.store.AddOrUpdateApiKey(apiKey); err != nil {
+		a.Error("保存api key失败！", zap.Error(err), zap.String("name", req.Name))
+		c.ResponseError(errors.New("保存api key失败！"))
+		return
+	}
+	a.s.apiKeyManager.notifyChanged()
+	a.Info("api key updated", zap.String("name", req.Name), zap.Strings("scopes", apiKey.Scopes), zap.Bool("disabled", apiKey.Disabled), zap.String("operator", a.operator(c)))
+	c.ResponseOK()
+}
+
+func (a *ApiKeyAPI) rotate(c *wkhttp.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		c.ResponseError(errors.New("name不能为空！"))
+		return
+	}
+	if a.forwardToLeaderIfNeed(c, bodyBytes) {
+		return
+	}
+
+	apiKey, ok := a.getApiKey(c, req.Name)
+	if !ok {
+		return
+	}
+	if err := a.checkGrant(c, apiKey.Scopes); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	secret, err := newApiKeySecret()
+	if err != nil {
+		a.Error("生成api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("生成api key失败！"))
+		return
+	}
+	now := time.Now()
+	apiKey.KeyHash = hashApiKey(secret)
+	apiKey.UpdatedAt = &now
+	if err = a.s.store.AddOrUpdateApiKey(apiKey); err != nil {
+		a.Error("保存api key失败！", zap.Error(err), zap.String("name", req.Name))
+		c.ResponseError(errors.New("保存api key失败！"))
+		return
+	}
+	a.s.apiKeyManager.notifyChanged()
+	a.Info("api key rotated", zap.String("name", req.Name), zap.String("operator", a.operator(c)))
+
+	c.JSON(http.StatusOK, newApiKeyResp(apiKey, secret, nil))
+}
+
+func (a *ApiKeyAPI) delete(c *wkhttp.Context) {
+	var req struct {
+		Name string `json:"name"`
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		c.ResponseError(errors.New("name不能为空！"))
+		return
+	}
+	if a.forwardToLeaderIfNeed(c, bodyBytes) {
+		return
+	}
+	apiKey, ok := a.getApiKey(c, req.Name)
+	if !ok {
+		return
+	}
+	if err := a.checkGrant(c, apiKey.Scopes); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if err = a.s.store.RemoveApiKey(req.Name); err != nil {
+		a.Error("删除api key失败！", zap.Error(err), zap.String("name", req.Name))
+		c.ResponseError(errors.New("删除api key失败！"))
+		return
+	}
+	a.s.apiKeyManager.notifyChanged()
+	a.Info("api key deleted", zap.String("name", req.Name), zap.String("operator", a.operator(c)))
+	c.ResponseOK()
+}
+
+func (a *ApiKeyAPI) list(c *wkhttp.Context) {
+	nodeId := wkutil.ParseUint64(c.Query("node_id"))
+	if nodeId > 0 && nodeId != a.s.opts.Cluster.NodeId {
+		node, err := a.s.cluster.NodeInfoById(nodeId)
+		if err != nil {
+			c.ResponseError(err)
+			return
+		}
+		if node == nil {
+			c.ResponseError(errors.New("node not found"))
+			return
+		}
+		c.ForwardWithBody(fmt.Sprintf("%s%s", node.ApiServerAddr, c.Request.URL.Path), nil)
+		return
+	}
+
+	entries, err := a.s.apiKeyManager.getApiKeys()
+	if err != nil {
+		a.Error("获取api key失败！", zap.Error(err))
+		c.ResponseError(errors.New("获取api key失败！"))
+		return
+	}
+	resps := make([]*apiKeyResp, 0, len(entries))
+	for _, entry := range entries {
+		resps = append(resps, newApiKeyResp(entry.apiKey, "", entry.stats))
+	}
+	sort.Slice(resps, func(i, j int) bool {
+		return resps[i].Name < resps[j].Name
+	})
+	c.JSON(http.StatusOK, resps)
+}
+
+func (a *ApiKeyAPI) getApiKey(c *wkhttp.Context, name string) (wkdb.ApiKey, bool) {
+	apiKey, err := a.s.store.GetApiKey(name)
+	if err != nil {
+		if err == wkdb.ErrNotFound {
+			c.ResponseError(errors.New("api key不存在！"))
+			return apiKey, false
+		}
+		a.Error("获取api key失败！", zap.Error(err), zap.String("name", name))
+		c.ResponseError(errors.New("获取api key失败！"))
+		return apiKey, false
+	}
+	return apiKey, true
+}
+
+// checkGrant 调用者只能授予自己拥有的权限，也只能修改、轮换、删除不超出自身权限的api key
+func (a *ApiKeyAPI) checkGrant(c *wkhttp.Context, scopes []string) error {
+	permissions, err := auth.ParseScopes(scopes)
+	if err != nil {
+		return err
+	}
+	caller := getApiCaller(c)
+	if caller == nil || caller.manager {
+		return nil
+	}
+	if !caller.permissions.Contains(permissions) {
+		return errors.New("不能授予超出自身权限的授权范围！")
+	}
+	return nil
+}
+
+func (a *ApiKeyAPI) operator(c *wkhttp.Context) string {
+	caller := getApiCaller(c)
+	if caller == nil {
+		return ""
+	}
+	return caller.name
+}
+
+// forwardToLeaderIfNeed api key的变更需要在存储api key的槽领导节点上执行
+func (a *ApiKeyAPI) forwardToLeaderIfNeed(c *wkhttp.Context, bodyBytes []byte) bool {
+	nodeInfo, err := a.s.cluster.SlotLeaderNodeInfo(clusterstore.ApiKeySlotId)
+	if err != nil {
+		a.Error("获取slot所在节点失败！", zap.Error(err), zap.Uint32("slotId", clusterstore.ApiKeySlotId))
+		c.ResponseError(errors.New("获取slot所在节点失败！"))
+		return true
+	}
+	if nodeInfo.Id == a.s.opts.Cluster.NodeId {
+		return false
+	}
+	a.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", nodeInfo.ApiServerAddr, c.Request.URL.Path)))
+	c.ForwardWithBody(fmt.Sprintf("%s%s", nodeInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+	return true
+}
+
+type apiKeyCreateReq struct {
+	Name   string   `json:"name"`   // 名称（唯一）
+	Scopes []string `json:"scopes"` // 授权范围，例如 message:send channel:write user:token read
+	Expire int64    `json:"expire"` // 过期时间（单位秒），0表示永不过期
+}
+
+func (r apiKeyCreateReq) Check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name不能为空！")
+	}
+	if strings.Contains(r.Name, ",") {
+		return errors.New("name不能包含逗号！")
+	}
+	if len(r.Scopes) == 0 {
+		return errors.New("scopes不能为空！")
+	}
+	if r.Expire < 0 {
+		return errors.New("expire不能小于0！")
+	}
+	return nil
+}
+
+type apiKeyUpdateReq struct {
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes,omitempty"`   // 为空表示不修改
+	Disabled *bool    `json:"disabled,omitempty"` // 为空表示不修改
+	Expire   *int64   `json:"expire,omitempty"`   // 过期时间（单位秒），0表示永不过期，为空表示不修改
+}
+
+func (r apiKeyUpdateReq) Check() error {
+	if strings.TrimSpace(r.Name) == "" {
+		return errors.New("name不能为空！")
+	}
+	if r.Scopes != nil && len(r.Scopes) == 0 {
+		return errors.New("scopes不能为空！")
+	}
+	if r.Expire != nil && *r.Expire < 0 {
+		return errors.New("expire不能小于0！")
+	}
+	return nil
+}
+
+type apiKeyResp struct {
+	Name        string     `json:"name"`
+	Key         string     `json:"key,omitempty"` // 明文密钥，只在创建和轮换时返回
+	Scopes      []string   `json:"scopes"`
+	Disabled    bool       `json:"disabled"`
+	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+	CallCount   int64      `json:"call_count"`             // 当前节点的调用次数
+	DeniedCount int64      `json:"denied_count"`           // 当前节点的越权调用次数
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"` // 当前节点最后调用时间
+	LastPath    string     `json:"last_path,omitempty"`    // 当前节点最后调用的接口
+	LastIP      string     `json:"last_ip,omitempty"`      // 当前节点最后调用的ip
+}
+
+func newApiKeyResp(apiKey wkdb.ApiKey, secret string, stats *apiKeyStats) *apiKeyResp {
+	resp := &apiKeyResp{
+		Name:      apiKey.Name,
+		Key:       secret,
+		Scopes:    apiKey.Scopes,
+		Disabled:  apiKey.Disabled,
+		ExpireAt:  apiKey.ExpireAt,
+		CreatedAt: apiKey.CreatedAt,
+		UpdatedAt: apiKey.UpdatedAt,
+	}
+	if stats != nil {
+		resp.CallCount = stats.callCount.Load()
+		resp.DeniedCount = stats.deniedCount.Load()
+		if lastUsedAt := stats.lastUsedAt.Load(); lastUsedAt > 0 {
+			t := time.Unix(0, lastUsedAt)
+			resp.LastUsedAt = &t
+		}
+		resp.LastPath = stats.lastPath.Load()
+		resp.LastIP = stats.lastIP.Load()
+	}
+	return resp
+}
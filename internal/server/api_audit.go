@@ -0,0 +1,116 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// AuditAPI 审计日志相关api
+type AuditAPI struct {
+	s *Server
+	wklog.Log
+}
+
+func NewAuditAPI(s *Server) *AuditAPI {
+	return &AuditAPI{
+		s:   s,
+		Log: wklog.NewWKLog("AuditAPI"),
+	}
+}
+
+// Route 路由
+func (a *AuditAPI) Route(r *wkhttp.WKHttp) {
+	r.GET("/audit/logs", a.logs) // 查询审计日志
+}
+
+// logs 查询审计日志（按时间倒序）
+// 参数: action, actor, target, result, source_ip, start(开始时间 秒), end(结束时间 秒), offset_id(分页，返回比此id更早的日志), limit
+func (a *AuditAPI) logs(c *wkhttp.Context) {
+	// 管理端登录的用户需要有审计日志的读权限（http api已在认证中间件校验权限）
+	if c.Username() != "" && !a.s.opts.Auth.HasPermissionWithContext(c, resource.Audit.Log, auth.ActionRead) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+
+	limit := wkutil.ParseInt(c.Query("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	req := wkdb.AuditLogSearchReq{
+		Action:   strings.TrimSpace(c.Query("action")),
+		Actor:    strings.TrimSpace(c.Query("actor")),
+		Target:   strings.TrimSpace(c.Query("target")),
+		Result:   strings.TrimSpace(c.Query("result")),
+		SourceIp: strings.TrimSpace(c.Query("source_ip")),
+		OffsetId: wkutil.ParseUint64(c.Query("offset_id")),
+		Limit:    limit,
+	}
+	if start := wkutil.ParseInt64(c.Query("start")); start > 0 {
+		req.StartAt = time.Unix(start, 0).UnixNano()
+	}
+	if end := wkutil.ParseInt64(c.Query("end")); end > 0 {
+		req.EndAt = time.Unix(end, 0).UnixNano()
+	}
+
+	logs, err := a.s.auditManager.search(req)
+	if err != nil {
+		a.Error("search audit logs failed", zap.Error(err))
+		c.ResponseError(errors.New("查询审计日志失败！"))
+		return
+	}
+	resps := make([]*auditLogResp, 0, len(logs))
+	for _, log := range logs {
+		resps = append(resps, newAuditLogResp(log))
+	}
+	c.JSON(http.StatusOK, resps)
+}
+
+type auditLogResp struct {
+	Id        string `json:"id"` // 雪花id超出js的数字精度，使用字符串返回
+	NodeId    uint64 `json:"node_id"`
+	Action    string `json:"action"`
+	Actor     string `json:"actor"`
+	ActorType string `json:"actor_type"`
+	SourceIp  string `json:"source_ip"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Target    string `json:"target"`
+	Result    string `json:"result"`
+	Status    int    `json:"status"`
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt int64  `json:"created_at"` // 操作时间（秒）
+}
+
+func newAuditLogResp(log wkdb.AuditLog) *auditLogResp {
+	resp := &auditLogResp{
+		Id:        wkutil.Uint64ToString(log.Id),
+		NodeId:    log.NodeId,
+		Action:    log.Action,
+		Actor:     log.Actor,
+		ActorType: log.ActorType,
+		SourceIp:  log.SourceIp,
+		Method:    log.Method,
+		Path:      log.Path,
+		Target:    log.Target,
+		Result:    log.Result,
+		Status:    log.Status,
+		Reason:    log.Reason,
+	}
+	if log.CreatedAt != nil {
+		resp.CreatedAt = log.CreatedAt.Unix()
+	}
+	return resp
+}
@@ -44,6 +44,8 @@ func (ch *ChannelAPI) Route(r *wkhttp.WKHttp) {
 	//################### 订阅者 ###################// 删除频道
 	r.POST("/channel/subscriber_add", ch.addSubscriber)       // 添加订阅者
 	r.POST("/channel/subscriber_remove", ch.removeSubscriber) // 移除订阅者
+	r.POST("/channel/subscriber_update", ch.updateSubscriber) // 更新订阅者的角色、禁言、加入来源和自定义属性
+	r.GET("/channel/subscriber", ch.getSubscriber)            // 获取单个订阅者
 
 	r.POST("/tmpchannel/subscriber_set", ch.setTmpSubscriber) // 临时频道设置订阅者
 
@@ -136,6 +138,10 @@ func (ch *ChannelAPI) updateOrAddChannelInfo(c *wkhttp.Context) {
 		c.ResponseError(errors.New("数据格式有误！"))
 		return
 	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
 
 	if ch.s.opts.ClusterOn() {
 		leaderInfo, err := ch.s.cluster.SlotLeaderOfChannel(req.ChannelID, req.ChannelType) // 获取频道的领导节点
@@ -212,6 +218,20 @@ func (ch *ChannelAPI) addSubscriber(c *wkhttp.Context) {
 		c.ResponseError(errors.New("查询频道失败！"))
 		return
 	}
+	// 一个频道只能有一个群主
+	if wkdb.MemberRole(req.Role) == wkdb.MemberRoleOwner {
+		allMembers, err := ch.s.store.GetSubscribers(req.ChannelId, req.ChannelType)
+		if err != nil {
+			ch.Error("获取所有订阅者失败！", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取所有订阅者失败！"))
+			return
+		}
+		if err := req.checkOwner(allMembers); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+
 	if !exist { // 如果没有频道则创建
 		channelInfo := wkdb.NewChannelInfo(req.ChannelId, req.ChannelType)
 		err = ch.s.store.AddChannelInfo(channelInfo)
@@ -232,8 +252,16 @@ func (ch *ChannelAPI) addSubscriber(c *wkhttp.Context) {
 }
 
 func (ch *ChannelAPI) addSubscriberWithReq(req subscriberAddReq) error {
-	var err error
 	existSubscribers := make([]string, 0)
+	members, err := ch.s.store.GetSubscribers(req.ChannelId, req.ChannelType)
+	if err != nil {
+		ch.Error("获取所有订阅者失败！", zap.Error(err))
+		return err
+	}
+	oldSubscribers := make([]string, 0, len(members))
+	for _, member := range members {
+		oldSubscribers = append(oldSubscribers, member.Uid)
+	}
 	if req.Reset == 1 {
 		err = ch.s.store.RemoveAllSubscriber(req.ChannelId, req.ChannelType)
 		if err != nil {
@@ -241,14 +269,7 @@ func (ch *ChannelAPI) addSubscriberWithReq(req subscriberAddReq) error {
 			return err
 		}
 	} else {
-		members, err := ch.s.store.GetSubscribers(req.ChannelId, req.ChannelType)
-		if err != nil {
-			ch.Error("获取所有订阅者失败！", zap.Error(err))
-			return err
-		}
-		for _, member := range members {
-			existSubscribers = append(existSubscribers, member.Uid)
-		}
+		existSubscribers = oldSubscribers
 	}
 	newSubscribers := make([]string, 0, len(req.Subscribers))
 	for _, subscriber := range req.Subscribers {
@@ -259,6 +280,22 @@ func (ch *ChannelAPI) addSubscriberWithReq(req subscriberAddReq) error {
 			newSubscribers = append(newSubscribers, subscriber)
 		}
 	}
+
+	// 记录订阅者的加入/离开，重置时仍在频道内的订阅者保留原来的加入记录
+	joins := make([]string, 0, len(newSubscribers))
+	for _, subscriber := range newSubscribers {
+		if !wkutil.ArrayContains(oldSubscribers, subscriber) {
+			joins = append(joins, subscriber)
+		}
+	}
+	leaves := make([]string, 0)
+	if req.Reset == 1 {
+		for _, subscriber := range oldSubscribers {
+			if !wkutil.ArrayContains(req.Subscribers, subscriber) {
+				leaves = append(leaves, subscriber)
+			}
+		}
+	}
 	if len(newSubscribers) > 0 {
 		lastMsgSeq, err := ch.s.store.GetLastMsgSeq(req.ChannelId, req.ChannelType)
 		if err != nil {
@@ -272,9 +309,12 @@ func (ch *ChannelAPI) addSubscriberWithReq(req subscriberAddReq) error {
 		updatedAt := time.Now()
 		for _, subscriber := range newSubscribers {
 			members = append(members, wkdb.Member{
-				Uid:       subscriber,
-				CreatedAt: &createdAt,
-				UpdatedAt: &updatedAt,
+				Uid:        subscriber,
+				Role:       wkdb.MemberRole(req.Role),
+				JoinSource: req.JoinSource,
+				Attributes: req.Attributes,
+				CreatedAt:  &createdAt,
+				UpdatedAt:  &updatedAt,
 			})
 		}
 		err = ch.s.store.AddSubscribers(req.ChannelId, req.ChannelType, members)
@@ -307,6 +347,13 @@ func (ch *ChannelAPI) addSubscriberWithReq(req subscriberAddReq) error {
 
 	}
 
+	if len(joins) > 0 || len(leaves) > 0 {
+		if err = ch.recordSubscriberHistories(req.ChannelId, req.ChannelType, joins, leaves); err != nil {
+			ch.Error("记录订阅者的加入/离开失败！", zap.Error(err))
+			return err
+		}
+	}
+
 	// 重新制止tag
 	err = ch.remakeReceiverTag(req.ChannelId, req.ChannelType)
 	if err != nil {
@@ -316,6 +363,135 @@ func (ch *ChannelAPI) addSubscriberWithReq(req subscriberAddReq) error {
 	return nil
 }
 
+// recordSubscriberHistories 记录订阅者加入/离开时的消息序号，用于控制历史消息的可见范围
+// 消息序号由频道领导按日志顺序分配，保证与并发发送的消息顺序一致
+func (ch *ChannelAPI) recordSubscriberHistories(channelId string, channelType uint8, joins []string, leaves []string) error {
+	timeoutCtx, cancel := context.WithTimeout(ch.s.ctx, time.Second*5)
+	seq, err := ch.s.cluster.NextMessageSeqOfChannel(timeoutCtx, channelId, channelType)
+	cancel()
+	if err != nil {
+		return err
+	}
+	return ch.s.store.RecordSubscriberHistories(channelId, channelType, seq, joins, leaves)
+}
+
+func (ch *ChannelAPI) updateSubscriber(c *wkhttp.Context) {
+	var req subscriberUpdateReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		c.ResponseError(errors.Wrap(err, "数据格式有误！"))
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	if ch.s.opts.ClusterOn() {
+		leaderInfo, err := ch.s.cluster.SlotLeaderOfChannel(req.ChannelId, req.ChannelType) // 获取频道的领导节点
+		if err != nil {
+			ch.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		leaderIsSelf := leaderInfo.Id == ch.s.opts.Cluster.NodeId
+		if !leaderIsSelf {
+			ch.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+			return
+		}
+	}
+
+	// 一个频道只能有一个群主，转让群主需要先将原群主设置为其他角色
+	if req.Role != nil && wkdb.MemberRole(*req.Role) == wkdb.MemberRoleOwner {
+		allMembers, err := ch.s.store.GetSubscribers(req.ChannelId, req.ChannelType)
+		if err != nil {
+			ch.Error("获取所有订阅者失败！", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取所有订阅者失败！"))
+			return
+		}
+		if err := req.checkOwner(allMembers); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+
+	now := time.Now()
+	members := make([]wkdb.Member, 0, len(req.Uids))
+	notExists := make([]string, 0)
+	for _, uid := range wkutil.RemoveRepeatedElement(req.Uids) {
+		member, err := ch.s.store.GetSubscriber(req.ChannelId, req.ChannelType, uid)
+		if err != nil {
+			if err == wkdb.ErrNotFound {
+				notExists = append(notExists, uid)
+				continue
+			}
+			ch.Error("获取订阅者失败！", zap.Error(err), zap.String("uid", uid))
+			c.ResponseError(errors.New("获取订阅者失败！"))
+			return
+		}
+		req.apply(&member, now)
+		members = append(members, member)
+	}
+	if len(notExists) > 0 {
+		c.ResponseError(fmt.Errorf("订阅者不存在：%s", strings.Join(notExists, ",")))
+		return
+	}
+
+	if err = ch.s.store.UpdateSubscribers(req.ChannelId, req.ChannelType, members); err != nil {
+		ch.Error("更新订阅者失败！", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+		c.ResponseError(errors.New("更新订阅者失败！"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (ch *ChannelAPI) getSubscriber(c *wkhttp.Context) {
+	channelId := c.Query("channel_id")
+	channelType := wkutil.ParseUint8(c.Query("channel_type"))
+	uid := c.Query("uid")
+	if strings.TrimSpace(channelId) == "" || strings.TrimSpace(uid) == "" {
+		c.ResponseError(errors.New("channel_id和uid不能为空！"))
+		return
+	}
+
+	if ch.s.opts.ClusterOn() {
+		leaderInfo, err := ch.s.cluster.SlotLeaderOfChannel(channelId, channelType) // 获取频道的领导节点
+		if err != nil {
+			ch.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", channelId), zap.Uint8("channelType", channelType))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		leaderIsSelf := leaderInfo.Id == ch.s.opts.Cluster.NodeId
+		if !leaderIsSelf {
+			ch.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), nil)
+			return
+		}
+	}
+
+	member, err := ch.s.store.GetSubscriber(channelId, channelType, uid)
+	if err != nil {
+		if err == wkdb.ErrNotFound {
+			c.ResponseError(errors.New("订阅者不存在！"))
+			return
+		}
+		ch.Error("获取订阅者失败！", zap.Error(err))
+		c.ResponseError(errors.New("获取订阅者失败！"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"uid":         member.Uid,
+		"role":        member.Role,
+		"mute_until":  member.MuteUntil,
+		"muted":       member.IsMuted(time.Now()),
+		"join_source": member.JoinSource,
+		"attributes":  member.Attributes,
+		"created_at":  member.CreatedAt,
+		"updated_at":  member.UpdatedAt,
+	})
+}
+
 func (ch *ChannelAPI) remakeReceiverTag(channelId string, channelType uint8) error {
 	channelKey := wkutil.ChannelToKey(channelId, channelType)
 	channel := ch.s.channelReactor.reactorSub(channelKey).channel(channelKey)
@@ -408,6 +584,20 @@ func (ch *ChannelAPI) removeSubscriber(c *wkhttp.Context) {
 		}
 	}
 
+	// 记录订阅者的离开
+	leaves := make([]string, 0, len(req.Subscribers))
+	for _, subscriber := range wkutil.RemoveRepeatedElement(req.Subscribers) {
+		exist, err := ch.s.store.ExistSubscriber(req.ChannelId, req.ChannelType, subscriber)
+		if err != nil {
+			ch.Error("查询订阅者失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		if exist {
+			leaves = append(leaves, subscriber)
+		}
+	}
+
 	err = ch.s.store.RemoveSubscribers(req.ChannelId, req.ChannelType, req.Subscribers)
 	if err != nil {
 		ch.Error("移除订阅者失败！", zap.Error(err))
@@ -415,6 +605,14 @@ func (ch *ChannelAPI) removeSubscriber(c *wkhttp.Context) {
 		return
 	}
 
+	if len(leaves) > 0 {
+		if err = ch.recordSubscriberHistories(req.ChannelId, req.ChannelType, nil, leaves); err != nil {
+			ch.Error("记录订阅者的离开失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+	}
+
 	err = ch.remakeReceiverTag(req.ChannelId, req.ChannelType)
 	if err != nil {
 		ch.Error("创建接收者标签失败！", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
@@ -899,26 +1097,50 @@ func (ch *ChannelAPI) syncMessages(c *wkhttp.Context) {
 			c.ResponseError(errors.New("获取频道所在节点失败！"))
 			return
 		}
-		leaderIsSelf := leaderInfo.Id == ch.s.opts.Cluster.NodeId
-
-		if !leaderIsSelf {
+		if !ch.localReadable(fakeChannelID, req.ChannelType, leaderInfo.Id) {
 			ch.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
 			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
 			return
 		}
 	}
-	if req.StartMessageSeq == 0 && req.EndMessageSeq == 0 {
-		messages, err = ch.s.store.LoadLastMsgs(fakeChannelID, req.ChannelType, limit)
-	} else if req.PullMode == PullModeUp { // 向上拉取
-		messages, err = ch.s.store.LoadNextRangeMsgs(fakeChannelID, req.ChannelType, req.StartMessageSeq, req.EndMessageSeq, limit)
+	// 根据频道的历史消息可见性限制拉取范围
+	visibleRange, err := ch.s.getHistoryRange(req.LoginUID, fakeChannelID, req.ChannelType)
+	if err != nil {
+		ch.Error("获取历史消息可见范围失败！", zap.Error(err), zap.Any("req", req))
+		c.ResponseError(err)
+		return
+	}
+	if visibleRange.unlimited() {
+		if req.StartMessageSeq == 0 && req.EndMessageSeq == 0 {
+			messages, err = ch.s.store.LoadLastMsgs(fakeChannelID, req.ChannelType, limit)
+		} else if req.PullMode == PullModeUp { // 向上拉取
+			messages, err = ch.s.store.LoadNextRangeMsgs(fakeChannelID, req.ChannelType, req.StartMessageSeq, req.EndMessageSeq, limit)
+		} else {
+			messages, err = ch.s.store.LoadPrevRangeMsgs(fakeChannelID, req.ChannelType, req.StartMessageSeq, req.EndMessageSeq, limit)
+		}
+	} else if req.PullMode == PullModeUp && (req.StartMessageSeq != 0 || req.EndMessageSeq != 0) { // 向上拉取
+		if start, end, ok := visibleRange.nextRange(req.StartMessageSeq, req.EndMessageSeq); ok {
+			messages, err = ch.s.store.LoadNextRangeMsgs(fakeChannelID, req.ChannelType, start, end, limit)
+		}
 	} else {
-		messages, err = ch.s.store.LoadPrevRangeMsgs(fakeChannelID, req.ChannelType, req.StartMessageSeq, req.EndMessageSeq, limit)
+		if start, end, ok := visibleRange.prevRange(req.StartMessageSeq, req.EndMessageSeq); ok {
+			messages, err = ch.s.loadPrevRangeMsgs(fakeChannelID, req.ChannelType, start, end, limit)
+		}
 	}
 	if err != nil {
 		ch.Error("获取消息失败！", zap.Error(err), zap.Any("req", req))
 		c.ResponseError(err)
 		return
 	}
+	// 过滤掉用户自己删除的消息，是否有更多数据按照过滤前的数量判断
+	loadedCount := len(messages)
+	messages, err = ch.s.filterMessages(req.LoginUID, fakeChannelID, req.ChannelType, &visibleRange, messages)
+	if err != nil {
+		ch.Error("过滤消息失败！", zap.Error(err), zap.Any("req", req))
+		c.ResponseError(err)
+		return
+	}
+
 	messageResps := make([]*MessageResp, 0, len(messages))
 	if len(messages) > 0 {
 		for _, message := range messages {
@@ -926,9 +1148,11 @@ func (ch *ChannelAPI) syncMessages(c *wkhttp.Context) {
 			messageResp.from(message, ch.s)
 			messageResps = append(messageResps, messageResp)
 		}
+		// 流消息带上已组装的流
+		ch.s.fillMessageStreams(fakeChannelID, req.ChannelType, messageResps)
 	}
 	var more bool = true // 是否有更多数据
-	if len(messageResps) < limit {
+	if loadedCount < limit {
 		more = false
 	}
 	if len(messageResps) > 0 {
@@ -957,6 +1181,25 @@ func (ch *ChannelAPI) syncMessages(c *wkhttp.Context) {
 	})
 }
 
+// localReadable 频道的读请求是否可以由本节点处理
+// 本节点是频道领导，或者开启了追随者一致性读并且本节点的频道副本已经追上领导的提交下标
+func (ch *ChannelAPI) localReadable(channelId string, channelType uint8, leaderId uint64) bool {
+	if leaderId == ch.s.opts.Cluster.NodeId {
+		return true
+	}
+	if !ch.s.opts.Cluster.FollowerReadOn {
+		return false
+	}
+	timeoutCtx, cancel := context.WithTimeout(ch.s.ctx, ch.s.opts.Cluster.ReadIndexTimeout)
+	defer cancel()
+	readable, err := ch.s.cluster.WaitLocalReadableOfChannel(timeoutCtx, channelId, channelType)
+	if err != nil {
+		ch.Debug("follower read failed, forward to leader", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		return false
+	}
+	return readable
+}
+
 func (ch *ChannelAPI) getChannelMaxMessageSeq(c *wkhttp.Context) {
 	channelId := c.Query("channel_id")
 	channelType := wkutil.StringToUint8(c.Query("channel_type"))
@@ -978,7 +1221,7 @@ func (ch *ChannelAPI) getChannelMaxMessageSeq(c *wkhttp.Context) {
 		return
 	}
 
-	if leaderInfo.Id != ch.s.opts.Cluster.NodeId {
+	if !ch.localReadable(channelId, channelType, leaderInfo.Id) {
 		c.Forward(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path))
 		return
 	}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+// ConfigAPI 配置相关api（只对当前节点生效，可通过node_id转发到指定节点）
+type ConfigAPI struct {
+	s *Server
+	wklog.Log
+}
+
+func NewConfigAPI(s *Server) *ConfigAPI {
+	return &ConfigAPI{
+		s:   s,
+		Log: wklog.NewWKLog("ConfigAPI"),
+	}
+}
+
+// Route 路由
+func (a *ConfigAPI) Route(r *wkhttp.WKHttp) {
+	r.POST("/config/reload", a.reload) // 重新加载配置文件
+}
+
+func (a *ConfigAPI) reload(c *wkhttp.Context) {
+	if a.s.forwardToNodeIfNeed(c, nil) {
+		return
+	}
+	result, err := a.s.ReloadConfig()
+	if err != nil {
+		a.Error("reload config failed", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -36,11 +36,12 @@ func NewConversationAPI(s *Server) *ConversationAPI {
 // Route 路由
 func (s *ConversationAPI) Route(r *wkhttp.WKHttp) {
 	// r.GET("/conversations", s.conversationsList)                    // 获取会话列表 （此接口作废，使用/conversation/sync）
-	r.POST("/conversations/clearUnread", s.clearConversationUnread) // 清空会话未读数量
-	r.POST("/conversations/setUnread", s.setConversationUnread)     // 设置会话未读数量
-	r.POST("/conversations/delete", s.deleteConversation)           // 删除会话
-	r.POST("/conversation/sync", s.syncUserConversation)            // 同步会话
-	r.POST("/conversation/syncMessages", s.syncRecentMessages)      // 同步会话最近消息
+	r.POST("/conversations/clearUnread", s.clearConversationUnread)   // 清空会话未读数量
+	r.POST("/conversations/setUnread", s.setConversationUnread)       // 设置会话未读数量
+	r.POST("/conversations/delete", s.deleteConversation)             // 删除会话
+	r.POST("/conversations/clearHistory", s.clearConversationHistory) // 清空会话的历史消息（只对自己生效）
+	r.POST("/conversation/sync", s.syncUserConversation)              // 同步会话
+	r.POST("/conversation/syncMessages", s.syncRecentMessages)        // 同步会话最近消息
 }
 
 // // Get a list of recent conversations
@@ -160,6 +161,63 @@ func (s *ConversationAPI) clearConversationUnread(c *wkhttp.Context) {
 	c.ResponseOK()
 }
 
+// 清空会话的历史消息，只对自己生效
+func (s *ConversationAPI) clearConversationHistory(c *wkhttp.Context) {
+	var req clearConversationHistoryReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	fakeChannelId := req.ChannelID
+	if req.ChannelType == wkproto.ChannelTypePerson {
+		fakeChannelId = GetFakeChannelIDWith(req.UID, req.ChannelID)
+	}
+
+	if s.s.opts.ClusterOn() {
+		leaderInfo, err := s.s.cluster.SlotLeaderOfChannel(fakeChannelId, req.ChannelType) // 获取频道的领导节点
+		if err != nil {
+			s.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", fakeChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		leaderIsSelf := leaderInfo.Id == s.s.opts.Cluster.NodeId
+		if !leaderIsSelf {
+			s.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+			return
+		}
+	}
+
+	clearedToSeq := req.MessageSeq
+	if clearedToSeq == 0 {
+		clearedToSeq, err = s.s.store.GetLastMsgSeq(fakeChannelId, req.ChannelType)
+		if err != nil {
+			s.Error("Failed to query last message", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+	}
+	if clearedToSeq == 0 {
+		c.ResponseOK()
+		return
+	}
+
+	err = s.s.store.ClearMessages(fakeChannelId, req.ChannelType, req.UID, clearedToSeq)
+	if err != nil {
+		s.Error("清空历史消息失败！", zap.Error(err), zap.String("uid", req.UID), zap.String("channelId", fakeChannelId), zap.Uint8("channelType", req.ChannelType))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
 func (s *ConversationAPI) setConversationUnread(c *wkhttp.Context) {
 	var req struct {
 		UID         string `json:"uid"`
@@ -344,7 +402,7 @@ func (s *ConversationAPI) syncUserConversation(c *wkhttp.Context) {
 	)
 
 	// ==================== 获取用户活跃的最近会话 ====================
-	conversations, err := s.s.store.GetLastConversations(req.UID, wkdb.ConversationTypeChat, 0, s.s.opts.Conversation.UserMaxCount)
+	conversations, err := s.s.store.GetLastConversations(req.UID, wkdb.ConversationTypeChat, 0, s.s.opts.Hot().Conversation.UserMaxCount)
 	if err != nil && err != wkdb.ErrNotFound {
 		s.Error("获取conversation失败！", zap.Error(err), zap.String("uid", req.UID))
 		c.ResponseError(errors.New("获取conversation失败！"))
@@ -423,6 +481,7 @@ func (s *ConversationAPI) syncUserConversation(c *wkhttp.Context) {
 				continue
 			}
 			resp := newSyncUserConversationResp(conversation)
+			visibilityChanged := false // 客户端上次同步后是否删除或清空过消息
 
 			for _, channelRecentMessage := range channelRecentMessages {
 				if conversation.ChannelId == channelRecentMessage.ChannelId && conversation.ChannelType == channelRecentMessage.ChannelType {
@@ -439,17 +498,27 @@ func (s *ConversationAPI) syncUserConversation(c *wkhttp.Context) {
 					}
 
 					resp.Recents = channelRecentMessage.Messages
+
+					// 将删除和清空记录同步给用户的其他设备
+					if channelRecentMessage.VisibilityVersion > req.Version {
+						visibilityChanged = true
+						resp.ClearedToSeq = channelRecentMessage.ClearedToSeq
+						resp.HiddenMessageIds = channelRecentMessage.HiddenMessageIds
+						if channelRecentMessage.VisibilityVersion > resp.Version {
+							resp.Version = channelRecentMessage.VisibilityVersion
+						}
+					}
 					break
 				}
 			}
 
 			msgSeq := channelLastMsgMap[fmt.Sprintf("%s-%d", conversation.ChannelId, conversation.ChannelType)]
 
-			if msgSeq != 0 && msgSeq >= uint64(resp.LastMsgSeq) {
+			if msgSeq != 0 && msgSeq >= uint64(resp.LastMsgSeq) && !visibilityChanged {
 				continue
 			}
 
-			if len(resp.Recents) > 0 {
+			if len(resp.Recents) > 0 || visibilityChanged {
 				resps = append(resps, resp)
 			}
 		}
@@ -607,23 +676,39 @@ func (s *Server) getRecentMessages(uid string, msgCount int, channels []*channel
 	if len(channels) > 0 {
 		var (
 			recentMessages []wkdb.Message
+			visibleRange   historyRange
 			err            error
 		)
 		for _, channel := range channels {
 			fakeChannelID := channel.ChannelId
 			msgSeq := channel.LastMsgSeq
 			messageResps := MessageRespSlice{}
+
+			// 根据频道的历史消息可见性限制消息范围
+			visibleRange, err = s.getHistoryRange(uid, fakeChannelID, channel.ChannelType)
+			if err != nil {
+				s.Error("获取历史消息可见范围失败！", zap.Error(err), zap.String("uid", uid), zap.String("fakeChannelID", fakeChannelID), zap.Uint8("channelType", channel.ChannelType))
+				return nil, err
+			}
 			if orderByLast {
 
 				if msgSeq > 0 {
 					msgSeq = msgSeq - 1 // 这里减1的目的是为了获取到最后一条消息
 				}
 
-				recentMessages, err = s.store.LoadLastMsgsWithEnd(fakeChannelID, channel.ChannelType, msgSeq, msgCount)
+				recentMessages = nil
+				if start, end, ok := visibleRange.prevRange(0, msgSeq); ok {
+					recentMessages, err = s.loadPrevRangeMsgs(fakeChannelID, channel.ChannelType, start, end, msgCount)
+				}
 				if err != nil {
 					s.Error("查询最近消息失败！", zap.Error(err), zap.String("uid", uid), zap.String("fakeChannelID", fakeChannelID), zap.Uint8("channelType", channel.ChannelType), zap.Uint64("LastMsgSeq", channel.LastMsgSeq))
 					return nil, err
 				}
+				recentMessages, err = s.filterMessages(uid, fakeChannelID, channel.ChannelType, &visibleRange, recentMessages)
+				if err != nil {
+					s.Error("过滤最近消息失败！", zap.Error(err), zap.String("uid", uid), zap.String("fakeChannelID", fakeChannelID), zap.Uint8("channelType", channel.ChannelType))
+					return nil, err
+				}
 				if len(recentMessages) > 0 {
 					for _, recentMessage := range recentMessages {
 						messageResp := &MessageResp{}
@@ -633,11 +718,19 @@ func (s *Server) getRecentMessages(uid string, msgCount int, channels []*channel
 				}
 				sort.Sort(sort.Reverse(messageResps))
 			} else {
-				recentMessages, err = s.store.LoadNextRangeMsgs(fakeChannelID, channel.ChannelType, msgSeq, 0, msgCount)
+				recentMessages = nil
+				if start, end, ok := visibleRange.nextRange(msgSeq, 0); ok {
+					recentMessages, err = s.store.LoadNextRangeMsgs(fakeChannelID, channel.ChannelType, start, end, msgCount)
+				}
 				if err != nil {
 					s.Error("查询最近消息失败！", zap.Error(err), zap.String("uid", uid), zap.String("fakeChannelID", fakeChannelID), zap.Uint8("channelType", channel.ChannelType), zap.Uint64("LastMsgSeq", channel.LastMsgSeq))
 					return nil, err
 				}
+				recentMessages, err = s.filterMessages(uid, fakeChannelID, channel.ChannelType, &visibleRange, recentMessages)
+				if err != nil {
+					s.Error("过滤最近消息失败！", zap.Error(err), zap.String("uid", uid), zap.String("fakeChannelID", fakeChannelID), zap.Uint8("channelType", channel.ChannelType))
+					return nil, err
+				}
 				if len(recentMessages) > 0 {
 					for _, recentMessage := range recentMessages {
 						messageResp := &MessageResp{}
@@ -648,9 +741,12 @@ func (s *Server) getRecentMessages(uid string, msgCount int, channels []*channel
 			}
 
 			channelRecentMessages = append(channelRecentMessages, &channelRecentMessage{
-				ChannelId:   channel.ChannelId,
-				ChannelType: channel.ChannelType,
-				Messages:    messageResps,
+				ChannelId:         channel.ChannelId,
+				ChannelType:       channel.ChannelType,
+				Messages:          messageResps,
+				ClearedToSeq:      visibleRange.visibility.ClearedToSeq,
+				HiddenMessageIds:  visibleRange.visibility.HiddenMessageIds,
+				VisibilityVersion: visibleRange.visibility.UpdatedAt,
 			})
 		}
 	}
@@ -0,0 +1,285 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/keylock"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// E2eeAPI 端到端加密公钥目录相关API（服务端只保存公钥，不参与消息加解密）
+type E2eeAPI struct {
+	wklog.Log
+	s          *Server
+	prekeyLock *keylock.KeyLock // 一次性预共享公钥锁，保证每个一次性公钥只被获取一次
+}
+
+// NewE2eeAPI NewE2eeAPI
+func NewE2eeAPI(s *Server) *E2eeAPI {
+	return &E2eeAPI{
+		Log:        wklog.NewWKLog("E2eeAPI"),
+		s:          s,
+		prekeyLock: keylock.NewKeyLock(),
+	}
+}
+
+// Route 路由配置
+func (e *E2eeAPI) Route(r *wkhttp.WKHttp) {
+	r.POST("/user/e2ee/keys_upload", e.keysUpload) // 上传设备公钥包
+	r.POST("/user/e2ee/keys", e.keys)              // 获取用户设备的公钥包（会消耗一次性预共享公钥）
+	r.GET("/user/e2ee/keys_count", e.keysCount)    // 获取设备剩余的一次性预共享公钥数量
+	r.POST("/user/e2ee/keys_remove", e.keysRemove) // 删除设备公钥包
+}
+
+// 上传设备公钥包
+func (e *E2eeAPI) keysUpload(c *wkhttp.Context) {
+	var req E2eeKeysUploadReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		e.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	if e.s.opts.ClusterOn() && e.forwardToUserLeaderIfNeed(c, req.UID, bodyBytes) {
+		return
+	}
+
+	lockKey := e2eeLockKey(req.UID, req.DeviceId)
+	e.prekeyLock.Lock(lockKey)
+	defer e.prekeyLock.Unlock(lockKey)
+
+	existBundle, err := e.s.store.GetE2eeKeyBundle(req.UID, req.DeviceId)
+	if err != nil && err != wkdb.ErrNotFound {
+		e.Error("获取公钥包失败！", zap.Error(err), zap.String("uid", req.UID), zap.String("deviceId", req.DeviceId))
+		c.ResponseError(err)
+		return
+	}
+
+	bundle := req.toBundle(time.Now().UnixNano())
+	err = e.s.store.SaveE2eeKeyBundle(bundle, req.toOnetimePrekeys())
+	if err != nil {
+		e.Error("保存公钥包失败！", zap.Error(err), zap.String("uid", req.UID), zap.String("deviceId", req.DeviceId))
+		c.ResponseError(err)
+		return
+	}
+
+	// 新设备或身份公钥变更，通知用户和上层应用
+	if existBundle.DeviceId == "" || !bytes.Equal(existBundle.IdentityKey, bundle.IdentityKey) {
+		e.notifyKeyChange(req.UID, req.DeviceId, E2eeKeyChangeTypeIdentity)
+	}
+
+	c.ResponseOK()
+}
+
+// 获取用户设备的公钥包，每个设备附带一个一次性预共享公钥（获取后删除）
+func (e *E2eeAPI) keys(c *wkhttp.Context) {
+	var req struct {
+		UID      string `json:"uid"`       // 用户uid
+		DeviceId string `json:"device_id"` // 设备ID 为空表示获取用户所有设备
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		e.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" {
+		c.ResponseError(errors.New("uid不能为空！"))
+		return
+	}
+
+	if e.s.opts.ClusterOn() && e.forwardToUserLeaderIfNeed(c, req.UID, bodyBytes) {
+		return
+	}
+
+	var bundles []wkdb.E2eeKeyBundle
+	if req.DeviceId != "" {
+		bundle, err := e.s.store.GetE2eeKeyBundle(req.UID, req.DeviceId)
+		if err != nil && err != wkdb.ErrNotFound {
+			e.Error("获取公钥包失败！", zap.Error(err), zap.String("uid", req.UID), zap.String("deviceId", req.DeviceId))
+			c.ResponseError(err)
+			return
+		}
+		if err == nil {
+			bundles = append(bundles, bundle)
+		}
+	} else {
+		bundles, err = e.s.store.GetE2eeKeyBundles(req.UID)
+		if err != nil {
+			e.Error("获取公钥包失败！", zap.Error(err), zap.String("uid", req.UID))
+			c.ResponseError(err)
+			return
+		}
+	}
+
+	resps := make([]*E2eeKeyBundleResp, 0, len(bundles))
+	for _, bundle := range bundles {
+		prekey, err := e.takeOnetimePrekey(bundle.Uid, bundle.DeviceId)
+		if err != nil {
+			e.Error("获取一次性预共享公钥失败！", zap.Error(err), zap.String("uid", bundle.Uid), zap.String("deviceId", bundle.DeviceId))
+			c.ResponseError(err)
+			return
+		}
+		resps = append(resps, newE2eeKeyBundleResp(bundle, prekey))
+	}
+	c.JSON(http.StatusOK, resps)
+}
+
+// 获取并删除设备的一个一次性预共享公钥，没有剩余时返回nil（客户端只使用签名预共享公钥建立会话）
+func (e *E2eeAPI) takeOnetimePrekey(uid string, deviceId string) (*wkdb.E2eePrekey, error) {
+	lockKey := e2eeLockKey(uid, deviceId)
+	e.prekeyLock.Lock(lockKey)
+	defer e.prekeyLock.Unlock(lockKey)
+
+	prekeys, err := e.s.store.GetE2eeOnetimePrekeys(uid, deviceId, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(prekeys) == 0 {
+		return nil, nil
+	}
+	prekey := prekeys[0]
+	if err = e.s.store.RemoveE2eeOnetimePrekeys(uid, deviceId, []uint32{prekey.KeyId}); err != nil {
+		return nil, err
+	}
+	return &prekey, nil
+}
+
+// 获取设备剩余的一次性预共享公钥数量（客户端据此补充上传）
+func (e *E2eeAPI) keysCount(c *wkhttp.Context) {
+	uid := c.Query("uid")
+	deviceId := c.Query("device_id")
+	if strings.TrimSpace(uid) == "" || strings.TrimSpace(deviceId) == "" {
+		c.ResponseError(errors.New("uid和device_id不能为空！"))
+		return
+	}
+
+	if e.s.opts.ClusterOn() && e.forwardToUserLeaderIfNeed(c, uid, nil) {
+		return
+	}
+
+	count, err := e.s.store.GetE2eeOnetimePrekeyCount(uid, deviceId)
+	if err != nil {
+		e.Error("获取一次性预共享公钥数量失败！", zap.Error(err), zap.String("uid", uid), zap.String("deviceId", deviceId))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"count": count,
+	})
+}
+
+// 删除设备公钥包（比如设备注销）
+func (e *E2eeAPI) keysRemove(c *wkhttp.Context) {
+	var req struct {
+		UID      string `json:"uid"`       // 用户uid
+		DeviceId string `json:"device_id"` // 设备ID
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		e.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" || strings.TrimSpace(req.DeviceId) == "" {
+		c.ResponseError(errors.New("uid和device_id不能为空！"))
+		return
+	}
+
+	if e.s.opts.ClusterOn() && e.forwardToUserLeaderIfNeed(c, req.UID, bodyBytes) {
+		return
+	}
+
+	lockKey := e2eeLockKey(req.UID, req.DeviceId)
+	e.prekeyLock.Lock(lockKey)
+	defer e.prekeyLock.Unlock(lockKey)
+
+	_, err = e.s.store.GetE2eeKeyBundle(req.UID, req.DeviceId)
+	if err != nil {
+		if err == wkdb.ErrNotFound {
+			c.ResponseOK()
+			return
+		}
+		e.Error("获取公钥包失败！", zap.Error(err), zap.String("uid", req.UID), zap.String("deviceId", req.DeviceId))
+		c.ResponseError(err)
+		return
+	}
+
+	if err = e.s.store.RemoveE2eeKeyBundle(req.UID, req.DeviceId); err != nil {
+		e.Error("删除公钥包失败！", zap.Error(err), zap.String("uid", req.UID), zap.String("deviceId", req.DeviceId))
+		c.ResponseError(err)
+		return
+	}
+	e.notifyKeyChange(req.UID, req.DeviceId, E2eeKeyChangeTypeRemove)
+
+	c.ResponseOK()
+}
+
+// 通知设备公钥变更：给用户发送cmd消息（同步到用户的其他设备），并触发webhook事件（上层应用可以据此通知用户的联系人）
+func (e *E2eeAPI) notifyKeyChange(uid string, deviceId string, changeType E2eeKeyChangeType) {
+	notify := E2eeKeyChangeNotify{
+		UID:        uid,
+		DeviceId:   deviceId,
+		ChangeType: changeType,
+		Timestamp:  time.Now().Unix(),
+	}
+
+	e.s.webhook.TriggerEvent(&Event{
+		Event: EventE2eeKeyChange,
+		Data:  notify,
+	})
+
+	payload := []byte(wkutil.ToJSON(map[string]interface{}{
+		"cmd":   CMDE2eeKeyChange,
+		"param": notify,
+	}))
+	clientMsgNo := fmt.Sprintf("%s0", wkutil.GenUUID())
+	_, err := sendMessageToChannel(e.s, MessageSendReq{
+		Header: MessageHeader{
+			SyncOnce: 1,
+		},
+		FromUID:     e.s.opts.SystemUID,
+		ChannelID:   uid,
+		ChannelType: wkproto.ChannelTypePerson,
+		Payload:     payload,
+	}, uid, wkproto.ChannelTypePerson, clientMsgNo, wkproto.StreamFlagIng)
+	if err != nil {
+		e.Warn("发送公钥变更通知失败！", zap.Error(err), zap.String("uid", uid), zap.String("deviceId", deviceId))
+	}
+}
+
+// 如果当前节点不是用户所在槽的领导节点，则转发请求给领导节点 返回true表示已转发
+func (e *E2eeAPI) forwardToUserLeaderIfNeed(c *wkhttp.Context, uid string, bodyBytes []byte) bool {
+	leaderInfo, err := e.s.cluster.SlotLeaderOfChannel(uid, wkproto.ChannelTypePerson) // 获取频道的领导节点
+	if err != nil {
+		e.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", uid), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+		c.ResponseError(errors.New("获取频道所在节点失败！"))
+		return true
+	}
+	if leaderInfo.Id == e.s.opts.Cluster.NodeId {
+		return false
+	}
+	e.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+	c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+	return true
+}
+
+func e2eeLockKey(uid string, deviceId string) string {
+	return fmt.Sprintf("%s-%s", uid, deviceId)
+}
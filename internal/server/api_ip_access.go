@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+// IPAccessAPI IP访问控制相关api（规则只对当前节点生效，可通过node_id转发到指定节点）
+type IPAccessAPI struct {
+	s *Server
+	wklog.Log
+}
+
+func NewIPAccessAPI(s *Server) *IPAccessAPI {
+	return &IPAccessAPI{
+		s:   s,
+		Log: wklog.NewWKLog("IPAccessAPI"),
+	}
+}
+
+// Route 路由
+func (a *IPAccessAPI) Route(r *wkhttp.WKHttp) {
+	r.GET("/ipaccess/rules", a.rules)     // 获取规则及命中统计
+	r.POST("/ipaccess/rules", a.setRules) // 替换指定监听的规则
+	r.POST("/ipaccess/reload", a.reload)  // 从配置文件重新加载规则
+}
+
+func (a *IPAccessAPI) rules(c *wkhttp.Context) {
+	if a.s.forwardToNodeIfNeed(c, nil) {
+		return
+	}
+	c.JSON(http.StatusOK, a.s.ipAccess.stats())
+}
+
+func (a *IPAccessAPI) setRules(c *wkhttp.Context) {
+	var req struct {
+		Listener string   `json:"listener"` // tcp, ws, wss, api, manager
+		Allow    []string `json:"allow"`
+		Deny     []string `json:"deny"`
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		a.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if a.s.forwardToNodeIfNeed(c, bodyBytes) {
+		return
+	}
+	if !isIPAccessListener(req.Listener) {
+		c.ResponseError(fmt.Errorf("不支持的监听类型[%s]！", req.Listener))
+		return
+	}
+	err = a.s.ipAccess.setRules(req.Listener, IPAccessConfig{
+		Allow: req.Allow,
+		Deny:  req.Deny,
+	})
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	a.Info("ip access rules updated", zap.String("listener", req.Listener), zap.Strings("allow", req.Allow), zap.Strings("deny", req.Deny))
+	c.ResponseOK()
+}
+
+func (a *IPAccessAPI) reload(c *wkhttp.Context) {
+	if a.s.forwardToNodeIfNeed(c, nil) {
+		return
+	}
+	if err := a.s.ReloadIPAccess(); err != nil {
+		a.Error("reload ip access config failed", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	a.Info("ip access rules reloaded")
+	c.ResponseOK()
+}
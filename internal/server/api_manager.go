@@ -3,9 +3,13 @@ package server
 import (
 	"errors"
 	"net/http"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/gin-gonic/gin"
@@ -13,15 +17,20 @@ import (
 	"go.uber.org/zap"
 )
 
+// 管理端用户密码的最小长度
+const managerPasswordMinLen = 8
+
 type ManagerAPI struct {
 	s *Server
 	wklog.Log
+	oidc *managerOIDC
 }
 
 func NewManagerAPI(s *Server) *ManagerAPI {
 	return &ManagerAPI{
-		s:   s,
-		Log: wklog.NewWKLog("ManagerAPI"),
+		s:    s,
+		Log:  wklog.NewWKLog("ManagerAPI"),
+		oidc: newManagerOIDC(s),
 	}
 }
 
@@ -29,6 +38,17 @@ func NewManagerAPI(s *Server) *ManagerAPI {
 func (m *ManagerAPI) Route(r *wkhttp.WKHttp) {
 
 	r.POST("/manager/login", m.login) // 登录
+
+	r.POST("/manager/password", m.changePassword) // 修改当前用户的密码
+
+	// 管理端用户管理
+	r.GET("/manager/users", m.userList)             // 用户列表
+	r.POST("/manager/users/create", m.userCreate)   // 创建用户
+	r.POST("/manager/users/update", m.userUpdate)   // 修改用户的角色、密码、禁用状态
+	r.POST("/manager/users/delete", m.userDelete)   // 删除用户
+	r.GET("/manager/roles", m.roles)                // 角色及其权限
+	r.GET("/manager/oidc/login", m.oidc.login)      // OIDC登录（跳转到OIDC提供者）
+	r.GET("/manager/oidc/callback", m.oidcCallback) // OIDC登录回调
 }
 
 func (m *ManagerAPI) login(c *wkhttp.Context) {
@@ -57,11 +77,30 @@ func (m *ManagerAPI) login(c *wkhttp.Context) {
 		return
 	}
 
+	// 先校验配置文件中的用户，再校验集群中存储的用户
 	if m.s.opts.Auth.Auth(req.Username, req.Password) != nil {
-		c.ResponseError(errors.New("用户名或密码错误"))
+		if _, err := m.s.managerUserManager.authenticate(req.Username, req.Password); err != nil {
+			if err == ErrManagerUserDisabled {
+				c.ResponseError(errors.New("用户已被禁用"))
+				return
+			}
+			c.ResponseError(errors.New("用户名或密码错误"))
+			return
+		}
+	}
+
+	resp, err := m.issueToken(req.Username)
+	if err != nil {
+		m.Error("jwtToken.SignedString", zap.Error(err))
+		c.ResponseError(err)
 		return
 	}
+	c.JSON(http.StatusOK, resp)
 
+}
+
+// issueToken 签发管理端的jwt
+func (m *ManagerAPI) issueToken(username string) (gin.H, error) {
 	nw := time.Now()
 	expire := nw.Add(m.s.opts.Jwt.Expire).Unix()
 
@@ -69,26 +108,291 @@ func (m *ManagerAPI) login(c *wkhttp.Context) {
 		"iss":      m.s.opts.Jwt.Issuer, // 发行者
 		"exp":      expire,              // 过期时间
 		"iat":      nw.Unix(),           // 发行时间
-		"username": req.Username,        // 用户名
+		"username": username,            // 用户名
 	})
 	tokenStr, err := jwtToken.SignedString([]byte(m.s.opts.Jwt.Secret))
 	if err != nil {
-		m.Error("jwtToken.SignedString", zap.Error(err))
-		c.ResponseError(err)
-		return
+		return nil, err
 	}
 
 	persmissionStr := ""
-	persmissions := m.s.opts.Auth.Persmissions(req.Username)
+	persmissions := m.s.opts.Auth.Persmissions(username)
 	if len(persmissions) > 0 {
 		persmissionStr = persmissions.Format()
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"username":    req.Username,
+	return gin.H{
+		"username":    username,
 		"token":       tokenStr,
 		"exp":         expire,
 		"permissions": persmissionStr,
+	}, nil
+}
+
+func (m *ManagerAPI) changePassword(c *wkhttp.Context) {
+	var req struct {
+		OldPassword string `json:"old_password"`
+		NewPassword string `json:"new_password"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if len(req.NewPassword) < managerPasswordMinLen {
+		c.ResponseError(errors.New("新密码长度不能小于8位"))
+		return
+	}
+	username := c.Username()
+	user, err := m.s.managerUserManager.authenticate(username, req.OldPassword)
+	if err != nil {
+		c.ResponseError(errors.New("原密码错误或用户不支持修改密码"))
+		return
+	}
+	if user.PasswordHash, err = hashManagerPassword(req.NewPassword); err != nil {
+		m.Error("hash password failed", zap.Error(err))
+		c.ResponseError(errors.New("修改密码失败"))
+		return
+	}
+	if err = m.saveUser(user); err != nil {
+		c.ResponseError(errors.New("修改密码失败"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (m *ManagerAPI) userList(c *wkhttp.Context) {
+	if !m.s.opts.Auth.HasPermissionWithContext(c, resource.Manager.User, auth.ActionRead) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	if err := m.s.managerUserManager.reload(); err != nil {
+		m.Error("reload manager users failed", zap.Error(err))
+		c.ResponseError(errors.New("获取用户失败"))
+		return
+	}
+	users, err := m.s.managerUserManager.getUsers()
+	if err != nil {
+		m.Error("get manager users failed", zap.Error(err))
+		c.ResponseError(errors.New("获取用户失败"))
+		return
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Username < users[j].Username
 	})
+	c.JSON(http.StatusOK, users)
+}
 
+func (m *ManagerAPI) userCreate(c *wkhttp.Context) {
+	if !m.s.opts.Auth.HasPermissionWithContext(c, resource.Manager.User, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Role     string `json:"role"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if err := m.checkUsername(req.Username); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	if len(req.Password) < managerPasswordMinLen {
+		c.ResponseError(errors.New("密码长度不能小于8位"))
+		return
+	}
+	if !auth.Role(req.Role).Valid() {
+		c.ResponseError(errors.New("角色只能是viewer、operator、admin"))
+		return
+	}
+	if err := m.s.managerUserManager.reload(); err != nil {
+		m.Error("reload manager users failed", zap.Error(err))
+		c.ResponseError(errors.New("获取用户失败"))
+		return
+	}
+	if _, ok := m.s.managerUserManager.getUser(req.Username); ok {
+		c.ResponseError(errors.New("用户已存在"))
+		return
+	}
+	passwordHash, err := hashManagerPassword(req.Password)
+	if err != nil {
+		m.Error("hash password failed", zap.Error(err))
+		c.ResponseError(errors.New("创建用户失败"))
+		return
+	}
+	now := time.Now()
+	if err = m.saveUser(wkdb.ManagerUser{
+		Username:     req.Username,
+		PasswordHash: passwordHash,
+		Role:         req.Role,
+		Source:       ManagerUserSourceLocal,
+		CreatedAt:    &now,
+		UpdatedAt:    &now,
+	}); err != nil {
+		c.ResponseError(errors.New("创建用户失败"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (m *ManagerAPI) userUpdate(c *wkhttp.Context) {
+	if !m.s.opts.Auth.HasPermissionWithContext(c, resource.Manager.User, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Username string  `json:"username"`
+		Password *string `json:"password,omitempty"` // 为空表示不修改
+		Role     *string `json:"role,omitempty"`     // 为空表示不修改
+		Disabled *bool   `json:"disabled,omitempty"` // 为空表示不修改
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := m.s.managerUserManager.reload(); err != nil {
+		m.Error("reload manager users failed", zap.Error(err))
+		c.ResponseError(errors.New("获取用户失败"))
+		return
+	}
+	user, ok := m.s.managerUserManager.getUser(req.Username)
+	if !ok {
+		c.ResponseError(errors.New("用户不存在"))
+		return
+	}
+	self := req.Username == c.Username()
+	if req.Password != nil {
+		if user.Source != ManagerUserSourceLocal {
+			c.ResponseError(errors.New("OIDC用户不支持设置密码"))
+			return
+		}
+		if len(*req.Password) < managerPasswordMinLen {
+			c.ResponseError(errors.New("密码长度不能小于8位"))
+			return
+		}
+		passwordHash, err := hashManagerPassword(*req.Password)
+		if err != nil {
+			m.Error("hash password failed", zap.Error(err))
+			c.ResponseError(errors.New("修改用户失败"))
+			return
+		}
+		user.PasswordHash = passwordHash
+	}
+	if req.Role != nil {
+		if !auth.Role(*req.Role).Valid() {
+			c.ResponseError(errors.New("角色只能是viewer、operator、admin"))
+			return
+		}
+		if self && *req.Role != user.Role {
+			c.ResponseError(errors.New("不能修改自己的角色"))
+			return
+		}
+		user.Role = *req.Role
+	}
+	if req.Disabled != nil {
+		if self && *req.Disabled {
+			c.ResponseError(errors.New("不能禁用自己"))
+			return
+		}
+		user.Disabled = *req.Disabled
+	}
+	now := time.Now()
+	user.UpdatedAt = &now
+	if err := m.saveUser(user); err != nil {
+		c.ResponseError(errors.New("修改用户失败"))
+		return
+	}
+	c.ResponseOK()
+}
+
+func (m *ManagerAPI) userDelete(c *wkhttp.Context) {
+	if !m.s.opts.Auth.HasPermissionWithContext(c, resource.Manager.User, auth.ActionWrite) {
+		c.ResponseStatus(http.StatusUnauthorized)
+		return
+	}
+	var req struct {
+		Username string `json:"username"`
+	}
+	if err := c.BindJSON(&req); err != nil {
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if req.Username == c.Username() {
+		c.ResponseError(errors.New("不能删除自己"))
+		return
+	}
+	if err := m.s.store.RemoveManagerUser(req.Username); err != nil {
+		m.Error("remove manager user failed", zap.Error(err), zap.String("username", req.Username))
+		c.ResponseError(errors.New("删除用户失败"))
+		return
+	}
+	m.s.managerUserManager.notifyChanged()
+	c.ResponseOK()
+}
+
+func (m *ManagerAPI) roles(c *wkhttp.Context) {
+	roles := []auth.Role{auth.RoleViewer, auth.RoleOperator, auth.RoleAdmin}
+	resps := make([]gin.H, 0, len(roles))
+	for _, role := range roles {
+		resps = append(resps, gin.H{
+			"role":        role,
+			"permissions": role.Permissions().Format(),
+		})
+	}
+	c.JSON(http.StatusOK, resps)
+}
+
+func (m *ManagerAPI) oidcCallback(c *wkhttp.Context) {
+	username, err := m.oidc.callback(c)
+	if err != nil {
+		m.Warn("oidc login failed", zap.Error(err))
+		c.JSON(http.StatusForbidden, gin.H{
+			"msg":    err.Error(),
+			"status": http.StatusForbidden,
+		})
+		return
+	}
+	resp, err := m.issueToken(username)
+	if err != nil {
+		m.Error("jwtToken.SignedString", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if redirect := m.s.opts.Manager.OIDC.SuccessRedirect; redirect != "" {
+		c.Redirect(http.StatusFound, oidcSuccessRedirectUrl(redirect, resp))
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+func (m *ManagerAPI) checkUsername(username string) error {
+	if username == "" {
+		return errors.New("用户名不能为空")
+	}
+	if len(username) > 64 {
+		return errors.New("用户名长度不能超过64位")
+	}
+	if username == m.s.opts.ManagerUID {
+		return errors.New("用户名不能为管理员uid")
+	}
+	for _, user := range m.s.opts.Auth.Users {
+		if user.Username == username {
+			return errors.New("用户名与配置文件中的用户冲突")
+		}
+	}
+	return nil
+}
+
+// saveUser 保存用户并通知各节点刷新缓存
+func (m *ManagerAPI) saveUser(user wkdb.ManagerUser) error {
+	if err := m.s.store.AddOrUpdateManagerUser(user); err != nil {
+		m.Error("save manager user failed", zap.Error(err), zap.String("username", user.Username))
+		return err
+	}
+	m.s.managerUserManager.notifyChanged()
+	return nil
 }
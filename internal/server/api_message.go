@@ -49,6 +49,8 @@ func (m *MessageAPI) Route(r *wkhttp.WKHttp) {
 
 	r.POST("/message", m.searchMessage) // 搜索单条消息
 
+	r.POST("/message/hide", m.hideMessages) // 删除消息（只对自己生效）
+
 }
 
 func (m *MessageAPI) send(c *wkhttp.Context) {
@@ -593,6 +595,22 @@ func (m *MessageAPI) searchMessages(c *wkhttp.Context) {
 		}
 	}
 
+	// 根据频道的历史消息可见性过滤消息
+	if strings.TrimSpace(req.LoginUid) != "" {
+		visibleRange, err := m.s.getHistoryRange(req.LoginUid, fakeChannelId, req.ChannelType)
+		if err != nil {
+			m.Error("获取历史消息可见范围失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		messages, err = m.s.filterMessages(req.LoginUid, fakeChannelId, req.ChannelType, &visibleRange, messages)
+		if err != nil {
+			m.Error("过滤消息失败！", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+	}
+
 	resps := make([]*MessageResp, 0, len(messages))
 	if len(messages) > 0 {
 		for _, message := range messages {
@@ -600,6 +618,8 @@ func (m *MessageAPI) searchMessages(c *wkhttp.Context) {
 			resp.from(message, m.s)
 			resps = append(resps, resp)
 		}
+		// 流消息带上已组装的流
+		m.s.fillMessageStreams(fakeChannelId, req.ChannelType, resps)
 	}
 	c.JSON(http.StatusOK, &syncMessageResp{
 		Messages: resps,
@@ -669,6 +689,22 @@ func (m *MessageAPI) searchMessage(c *wkhttp.Context) {
 		return
 	}
 
+	// 根据频道的历史消息可见性过滤消息
+	if len(messages) > 0 && strings.TrimSpace(req.LoginUid) != "" {
+		visibleRange, err := m.s.getHistoryRange(req.LoginUid, fakeChannelId, req.ChannelType)
+		if err != nil {
+			m.Error("获取历史消息可见范围失败！", zap.Error(err), zap.String("req", wkutil.ToJSON(req)))
+			c.ResponseError(err)
+			return
+		}
+		messages, err = m.s.filterMessages(req.LoginUid, fakeChannelId, req.ChannelType, &visibleRange, messages)
+		if err != nil {
+			m.Error("过滤消息失败！", zap.Error(err), zap.String("req", wkutil.ToJSON(req)))
+			c.ResponseError(err)
+			return
+		}
+	}
+
 	if len(messages) == 0 {
 		m.Info("消息不存在！", zap.String("req", wkutil.ToJSON(req)))
 		c.ResponseStatus(http.StatusNotFound)
@@ -679,3 +715,68 @@ func (m *MessageAPI) searchMessage(c *wkhttp.Context) {
 	resp.from(messages[0], m.s)
 	c.JSON(http.StatusOK, resp)
 }
+
+// 删除消息，只对自己生效，用户的所有设备同步时都不会再返回这些消息
+func (m *MessageAPI) hideMessages(c *wkhttp.Context) {
+	var req messageHideReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		m.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(errors.New("数据格式有误！"))
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	fakeChannelId := req.ChannelId
+	if req.ChannelType == wkproto.ChannelTypePerson {
+		fakeChannelId = GetFakeChannelIDWith(req.LoginUid, req.ChannelId)
+	}
+
+	if m.s.opts.ClusterOn() {
+		leaderInfo, err := m.s.cluster.SlotLeaderOfChannel(fakeChannelId, req.ChannelType) // 获取频道的领导节点
+		if err != nil {
+			m.Error("获取频道所在节点失败！!", zap.Error(err), zap.String("channelID", fakeChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		leaderIsSelf := leaderInfo.Id == m.s.opts.Cluster.NodeId
+		if !leaderIsSelf {
+			m.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+			return
+		}
+	}
+
+	// 查询消息序号，隐藏记录按消息序号存储，清空历史消息时一起删除
+	hiddenMessages := make([]wkdb.HiddenMessage, 0, len(req.MessageIds))
+	for _, messageId := range req.MessageIds {
+		messages, err := m.s.store.SearchMessages(wkdb.MessageSearchReq{
+			ChannelId:   fakeChannelId,
+			ChannelType: req.ChannelType,
+			MessageId:   messageId,
+		})
+		if err != nil {
+			m.Error("查询消息失败！", zap.Error(err), zap.Int64("messageId", messageId))
+			c.ResponseError(err)
+			return
+		}
+		if len(messages) == 0 || messages[0].ChannelID != fakeChannelId || messages[0].ChannelType != req.ChannelType { // 消息不存在或不属于此频道
+			continue
+		}
+		hiddenMessages = append(hiddenMessages, wkdb.HiddenMessage{
+			MessageId:  messageId,
+			MessageSeq: uint64(messages[0].MessageSeq),
+		})
+	}
+
+	err = m.s.store.HideMessages(fakeChannelId, req.ChannelType, req.LoginUid, hiddenMessages)
+	if err != nil {
+		m.Error("删除消息失败！", zap.Error(err), zap.String("uid", req.LoginUid), zap.String("channelId", fakeChannelId), zap.Uint8("channelType", req.ChannelType))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
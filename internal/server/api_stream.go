@@ -10,6 +10,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 	"github.com/gin-gonic/gin"
@@ -31,8 +32,9 @@ func NewStreamAPI(s *Server) *StreamAPI {
 
 // Route route
 func (s *StreamAPI) Route(r *wkhttp.WKHttp) {
-	r.POST("/stream/start", s.start) // 流消息开始
-	r.POST("/stream/end", s.end)     // 流消息结束
+	r.POST("/stream/start", s.start)   // 流消息开始
+	r.POST("/stream/end", s.end)       // 流消息结束
+	r.POST("/stream/chunks", s.chunks) // 获取流消息的分片
 }
 
 func (s *StreamAPI) start(c *wkhttp.Context) {
@@ -102,10 +104,10 @@ func (s *StreamAPI) start(c *wkhttp.Context) {
 	}
 	result := results[0]
 
-	// 添加流元数据
+	// 添加流元数据（频道为消息实际存储的频道，流分片也存储在此频道所在的槽）
 	streamMeta := &wkdb.StreamMeta{
 		StreamNo:    streamNo,
-		ChannelId:   channelId,
+		ChannelId:   fakeChannelId,
 		ChannelType: channelType,
 		FromUid:     req.FromUid,
 		ClientMsgNo: clientMsgNo,
@@ -144,7 +146,221 @@ func (s *StreamAPI) start(c *wkhttp.Context) {
 }
 
 func (s *StreamAPI) end(c *wkhttp.Context) {
+	var req streamEndReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.StreamNo) == "" {
+		c.ResponseError(errors.New("stream_no不能为空！"))
+		return
+	}
+	if strings.TrimSpace(req.ChannelId) == "" {
+		c.ResponseError(errors.New("channel_id不能为空！"))
+		return
+	}
+	if strings.TrimSpace(req.FromUid) == "" {
+		req.FromUid = s.s.opts.SystemUID
+	}
+	fakeChannelId := req.ChannelId
+	if req.ChannelType == wkproto.ChannelTypePerson {
+		fakeChannelId = GetFakeChannelIDWith(req.ChannelId, req.FromUid)
+	}
+
+	if s.s.opts.ClusterOn() {
+		leaderInfo, err := s.s.cluster.SlotLeaderOfChannel(fakeChannelId, req.ChannelType) // 获取频道的槽领导节点
+		if err != nil {
+			s.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelId", fakeChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		if leaderInfo.Id != s.s.opts.Cluster.NodeId {
+			s.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+			return
+		}
+	}
+
+	streamMeta, err := s.s.store.GetStreamMeta(req.StreamNo)
+	if err != nil {
+		s.Error("获取流元数据失败！", zap.Error(err), zap.String("streamNo", req.StreamNo))
+		c.ResponseError(err)
+		return
+	}
+	if streamMeta == nil {
+		c.ResponseError(errors.New("流不存在！"))
+		return
+	}
+	if streamMeta.End == 0 {
+		streamMeta.End = 1
+		streamMeta.EndAt = time.Now().Unix()
+		err = s.s.store.AddStreamMeta(streamMeta)
+		if err != nil {
+			s.Error("更新流元数据失败！", zap.Error(err), zap.String("streamNo", req.StreamNo))
+			c.ResponseError(err)
+			return
+		}
+	}
+	// 流已结束，不再补发（其他节点的缓存空闲超时后移除）
+	s.s.streamCatchUp.end(req.StreamNo)
+
+	c.ResponseOK()
+}
+
+// 获取流消息的分片
+func (s *StreamAPI) chunks(c *wkhttp.Context) {
+	var req streamChunksReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	fakeChannelId := req.ChannelId
+	if req.ChannelType == wkproto.ChannelTypePerson && strings.TrimSpace(req.LoginUid) != "" {
+		fakeChannelId = GetFakeChannelIDWith(req.LoginUid, req.ChannelId)
+	}
+
+	if s.s.opts.ClusterOn() {
+		leaderInfo, err := s.s.cluster.SlotLeaderOfChannel(fakeChannelId, req.ChannelType) // 获取频道的槽领导节点
+		if err != nil {
+			s.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelId", fakeChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.ResponseError(errors.New("获取频道所在节点失败！"))
+			return
+		}
+		if leaderInfo.Id != s.s.opts.Cluster.NodeId {
+			s.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+			c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+			return
+		}
+	}
+
+	resp, err := s.s.getStreamChunks(req.StreamNo, req.StartStreamSeq, req.Limit)
+	if err != nil {
+		s.Error("获取流分片失败！", zap.Error(err), zap.String("streamNo", req.StreamNo))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 获取本节点存储的流消息分片
+// startStreamSeq 返回大于此序号的分片 limit 为0表示不限制
+func (s *Server) getStreamChunks(streamNo string, startStreamSeq uint64, limit int) (*streamChunksResp, error) {
+	streamMeta, err := s.store.GetStreamMeta(streamNo)
+	if err != nil {
+		return nil, err
+	}
+	streams, err := s.store.GetStreams(streamNo)
+	if err != nil {
+		return nil, err
+	}
+	resp := &streamChunksResp{
+		StreamNo: streamNo,
+		Chunks:   make([]*StreamItemResp, 0, len(streams)),
+	}
+	if streamMeta != nil {
+		resp.from(streamMeta)
+	}
+	for _, stream := range streams {
+		if stream.StreamId <= startStreamSeq {
+			continue
+		}
+		if limit > 0 && len(resp.Chunks) >= limit {
+			resp.More = 1
+			break
+		}
+		resp.Chunks = append(resp.Chunks, &StreamItemResp{
+			StreamSeq: stream.StreamId,
+			Payload:   stream.Payload,
+		})
+	}
+	return resp, nil
+}
+
+// 请求频道所在槽的领导节点批量获取流消息分片（同一个频道的流都存储在同一个槽上）
+func (s *Server) requestStreamChunks(fakeChannelId string, channelType uint8, streamNos []string) ([]*streamChunksResp, error) {
+	if s.opts.ClusterOn() {
+		leaderInfo, err := s.cluster.SlotLeaderOfChannel(fakeChannelId, channelType)
+		if err != nil {
+			return nil, err
+		}
+		if leaderInfo.Id != s.opts.Cluster.NodeId {
+			timeoutCtx, cancel := context.WithTimeout(s.ctx, s.opts.Cluster.ReqTimeout)
+			defer cancel()
+			resp, err := s.cluster.RequestWithContext(timeoutCtx, leaderInfo.Id, "/wk/getStreamChunks", []byte(wkutil.ToJSON(streamNos)))
+			if err != nil {
+				return nil, err
+			}
+			if resp.Status != proto.StatusOK {
+				return nil, fmt.Errorf("get stream chunks failed, status: %d err:%s", resp.Status, string(resp.Body))
+			}
+			var results []*streamChunksResp
+			if err = wkutil.ReadJSONByByte(resp.Body, &results); err != nil {
+				return nil, err
+			}
+			return results, nil
+		}
+	}
+	return s.getStreamChunksBatch(streamNos)
+}
 
+// 获取本节点存储的多个流的全部分片
+func (s *Server) getStreamChunksBatch(streamNos []string) ([]*streamChunksResp, error) {
+	results := make([]*streamChunksResp, 0, len(streamNos))
+	for _, streamNo := range streamNos {
+		result, err := s.getStreamChunks(streamNo, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// fillMessageStreams 给流消息带上已组装的流（同步消息时使用）
+func (s *Server) fillMessageStreams(fakeChannelId string, channelType uint8, messageResps []*MessageResp) {
+	if !s.opts.Stream.StoreOn {
+		return
+	}
+	streamNos := make([]string, 0)
+	for _, messageResp := range messageResps {
+		if messageResp.StreamNo == "" || wkutil.ArrayContains(streamNos, messageResp.StreamNo) {
+			continue
+		}
+		streamNos = append(streamNos, messageResp.StreamNo)
+	}
+	if len(streamNos) == 0 {
+		return
+	}
+	results, err := s.requestStreamChunks(fakeChannelId, channelType, streamNos)
+	if err != nil {
+		s.Warn("获取流分片失败！", zap.Error(err), zap.Strings("streamNos", streamNos), zap.String("channelId", fakeChannelId), zap.Uint8("channelType", channelType))
+		return
+	}
+	resultMap := make(map[string]*streamChunksResp, len(results))
+	for _, result := range results {
+		resultMap[result.StreamNo] = result
+	}
+	for _, messageResp := range messageResps {
+		if messageResp.StreamNo == "" {
+			continue
+		}
+		result := resultMap[messageResp.StreamNo]
+		if result == nil {
+			continue
+		}
+		messageResp.Streams = result.Chunks
+		if result.End == 1 {
+			messageResp.StreamFlag = wkproto.StreamFlagEnd
+		}
+	}
 }
 
 type streamStartReq struct {
@@ -158,6 +374,55 @@ type streamStartReq struct {
 
 type streamEndReq struct {
 	StreamNo    string `json:"stream_no"`    // 消息流编号
+	FromUid     string `json:"from_uid"`     // 发送者UID（个人频道需要，和流开始时一致）
 	ChannelId   string `json:"channel_id"`   // 频道ID
 	ChannelType uint8  `json:"channel_type"` // 频道类型
 }
+
+type streamChunksReq struct {
+	LoginUid       string `json:"login_uid"`        // 当前登录用户的uid（个人频道需要）
+	StreamNo       string `json:"stream_no"`        // 消息流编号
+	ChannelId      string `json:"channel_id"`       // 频道ID
+	ChannelType    uint8  `json:"channel_type"`     // 频道类型
+	StartStreamSeq uint64 `json:"start_stream_seq"` // 返回大于此流序号的分片，客户端传入已收到的最大流序号即可补齐
+	Limit          int    `json:"limit"`            // 分片数量限制，0表示不限制
+}
+
+func (r streamChunksReq) check() error {
+	if strings.TrimSpace(r.StreamNo) == "" {
+		return errors.New("stream_no不能为空！")
+	}
+	if strings.TrimSpace(r.ChannelId) == "" {
+		return errors.New("channel_id不能为空！")
+	}
+	return nil
+}
+
+type streamChunksResp struct {
+	StreamNo    string            `json:"stream_no"`     // 消息流编号
+	ChannelId   string            `json:"channel_id"`    // 频道ID
+	ChannelType uint8             `json:"channel_type"`  // 频道类型
+	FromUid     string            `json:"from_uid"`      // 发送者UID
+	ClientMsgNo string            `json:"client_msg_no"` // 客户端消息编号
+	MessageId   int64             `json:"message_id"`    // 流所属的消息ID
+	MessageSeq  int64             `json:"message_seq"`   // 流所属的消息序号
+	End         int               `json:"end"`           // 流是否已结束 1.已结束
+	More        int               `json:"more"`          // 是否还有更多分片
+	Chunks      []*StreamItemResp `json:"chunks"`        // 分片（按流序号有序）
+}
+
+func (r *streamChunksResp) from(streamMeta *wkdb.StreamMeta) {
+	r.ChannelId = streamMeta.ChannelId
+	r.ChannelType = streamMeta.ChannelType
+	r.FromUid = streamMeta.FromUid
+	r.ClientMsgNo = streamMeta.ClientMsgNo
+	r.MessageId = streamMeta.MessageId
+	r.MessageSeq = streamMeta.MessageSeq
+	r.End = int(streamMeta.End)
+}
+
+// StreamItemResp 流消息分片
+type StreamItemResp struct {
+	StreamSeq uint64 `json:"stream_seq"` // 流序号
+	Payload   []byte `json:"payload"`    // 分片内容
+}
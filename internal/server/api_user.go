@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/keylock"
 	"github.com/WuKongIM/WuKongIM/pkg/network"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
@@ -22,14 +24,16 @@ import (
 // UserAPI 用户相关API
 type UserAPI struct {
 	wklog.Log
-	s *Server
+	s               *Server
+	deviceTokenLock *keylock.KeyLock // 设备token锁，保证token的更新、轮换和吊销是原子的
 }
 
 // NewUserAPI NewUserAPI
 func NewUserAPI(s *Server) *UserAPI {
 	return &UserAPI{
-		Log: wklog.NewWKLog("UserAPI"),
-		s:   s,
+		Log:             wklog.NewWKLog("UserAPI"),
+		s:               s,
+		deviceTokenLock: keylock.NewKeyLock(),
 	}
 }
 
@@ -37,6 +41,8 @@ func NewUserAPI(s *Server) *UserAPI {
 func (u *UserAPI) Route(r *wkhttp.WKHttp) {
 
 	r.POST("/user/token", u.updateToken)                  // 更新用户token
+	r.POST("/user/token_rotate", u.rotateToken)           // 轮换用户token
+	r.POST("/user/token_revoke", u.revokeToken)           // 吊销用户token
 	r.POST("/user/device_quit", u.deviceQuit)             // 强制设备退出
 	r.POST("/user/onlinestatus", u.getOnlineStatus)       // 获取用户在线状态
 	r.POST("/user/systemuids_add", u.systemUidsAdd)       // 添加系统uid
@@ -46,6 +52,9 @@ func (u *UserAPI) Route(r *wkhttp.WKHttp) {
 	r.POST("/user/systemuids_add_to_cache", u.systemUidsAddToCache)           // 仅仅添加系统账号至缓存
 	r.POST("/user/systemuids_remove_from_cache", u.systemUidsRemoveFromCache) // 仅仅从缓存中移除系统账号
 
+	r.POST("/user/presence", u.getPresence)                // 获取用户的在线状态和最后在线时间
+	r.POST("/user/presence_privacy", u.setPresencePrivacy) // 设置用户最后在线时间的可见范围
+
 }
 
 // 强制设备退出
@@ -76,11 +85,11 @@ func (u *UserAPI) deviceQuit(c *wkhttp.Context) {
 	}
 
 	if req.DeviceFlag == -1 {
-		_ = u.quitUserDevice(req.UID, wkproto.APP)
-		_ = u.quitUserDevice(req.UID, wkproto.WEB)
-		_ = u.quitUserDevice(req.UID, wkproto.PC)
+		_ = u.quitUserDevice(req.UID, wkproto.APP, true)
+		_ = u.quitUserDevice(req.UID, wkproto.WEB, true)
+		_ = u.quitUserDevice(req.UID, wkproto.PC, true)
 	} else {
-		_ = u.quitUserDevice(req.UID, wkproto.DeviceFlag(req.DeviceFlag))
+		_ = u.quitUserDevice(req.UID, wkproto.DeviceFlag(req.DeviceFlag), true)
 	}
 
 	c.ResponseOK()
@@ -88,7 +97,12 @@ func (u *UserAPI) deviceQuit(c *wkhttp.Context) {
 }
 
 // 这里清空token 让设备去重新登录 空token是不让登录的
-func (u *UserAPI) quitUserDevice(uid string, deviceFlag wkproto.DeviceFlag) error {
+// disconnect 是否断开设备当前的连接
+func (u *UserAPI) quitUserDevice(uid string, deviceFlag wkproto.DeviceFlag, disconnect bool) error {
+
+	lockKey := deviceTokenLockKey(uid, deviceFlag)
+	u.deviceTokenLock.Lock(lockKey)
+	defer u.deviceTokenLock.Unlock(lockKey)
 
 	device, err := u.s.store.GetDevice(uid, deviceFlag)
 	if err != nil {
@@ -114,19 +128,164 @@ func (u *UserAPI) quitUserDevice(uid string, deviceFlag wkproto.DeviceFlag) erro
 		u.Error("清空用户token失败！", zap.Error(err), zap.String("uid", uid), zap.Uint8("deviceFlag", deviceFlag.ToUint8()))
 		return err
 	}
+	if disconnect {
+		u.kickDeviceConns(uid, deviceFlag, "", time.Second*2)
+	}
+
+	return nil
+}
+
+// 踢掉指定设备的所有连接
+func (u *UserAPI) kickDeviceConns(uid string, deviceFlag wkproto.DeviceFlag, reason string, closeDelay time.Duration) {
 	oldConns := u.s.userReactor.getConnsByDeviceFlag(uid, deviceFlag)
-	if len(oldConns) > 0 {
-		for _, oldConn := range oldConns {
-			_ = u.s.userReactor.writePacket(oldConn, &wkproto.DisconnectPacket{
-				ReasonCode: wkproto.ReasonConnectKick,
-			})
-			u.s.timingWheel.AfterFunc(time.Second*2, func() {
-				oldConn.close()
-			})
+	for _, oldConn := range oldConns {
+		u.Debug("踢掉设备连接", zap.String("uid", uid), zap.Int64("id", oldConn.connId), zap.String("deviceFlag", deviceFlag.String()))
+		_ = u.s.userReactor.writePacket(oldConn, &wkproto.DisconnectPacket{
+			ReasonCode: wkproto.ReasonConnectKick,
+			Reason:     reason,
+		})
+		u.s.timingWheel.AfterFunc(closeDelay, func(cn *connContext) func() {
+			return func() {
+				cn.close()
+			}
+		}(oldConn))
+	}
+}
+
+// 轮换用户token (旧token匹配时才替换为新token)
+func (u *UserAPI) rotateToken(c *wkhttp.Context) {
+	var req RotateTokenReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	if u.forwardToUserLeaderIfNeed(c, req.UID, bodyBytes) {
+		return
+	}
+
+	lockKey := deviceTokenLockKey(req.UID, req.DeviceFlag)
+	u.deviceTokenLock.Lock(lockKey)
+	defer u.deviceTokenLock.Unlock(lockKey)
+
+	device, err := u.s.store.GetDevice(req.UID, req.DeviceFlag)
+	if err != nil && err != wkdb.ErrNotFound {
+		u.Error("获取设备信息失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
+		c.ResponseError(err)
+		return
+	}
+	if wkdb.IsEmptyDevice(device) || device.Token == "" {
+		c.ResponseError(errors.New("设备token不存在！"))
+		return
+	}
+	if req.OldToken != "" && req.OldToken != device.Token {
+		c.ResponseError(errors.New("旧token不匹配！"))
+		return
+	}
+
+	updatedAt := time.Now()
+	err = u.s.store.UpdateDevice(wkdb.Device{
+		Id:            device.Id,
+		Uid:           req.UID,
+		DeviceFlag:    uint64(req.DeviceFlag),
+		DeviceLevel:   device.DeviceLevel,
+		Token:         req.Token,
+		TokenExpireAt: tokenExpireAt(updatedAt, req.Expire),
+		Scopes:        req.Scopes,
+		UpdatedAt:     &updatedAt,
+	})
+	if err != nil {
+		u.Error("轮换设备token失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
+		c.ResponseError(err)
+		return
+	}
+
+	if req.Disconnect {
+		u.kickDeviceConns(req.UID, req.DeviceFlag, "token已轮换", time.Second*2)
+	}
+
+	c.ResponseOK()
+}
+
+// 吊销用户token
+func (u *UserAPI) revokeToken(c *wkhttp.Context) {
+	var req struct {
+		UID        string `json:"uid"`         // 用户uid
+		DeviceFlag int    `json:"device_flag"` // 设备flag 这里 -1 为用户所有的设备
+		Disconnect bool   `json:"disconnect"`  // 是否断开设备当前的连接
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if strings.TrimSpace(req.UID) == "" {
+		c.ResponseError(errors.New("uid不能为空！"))
+		return
+	}
+
+	if u.forwardToUserLeaderIfNeed(c, req.UID, bodyBytes) {
+		return
+	}
+
+	var deviceFlags []wkproto.DeviceFlag
+	if req.DeviceFlag == -1 {
+		deviceFlags = []wkproto.DeviceFlag{wkproto.APP, wkproto.WEB, wkproto.PC}
+	} else {
+		deviceFlags = []wkproto.DeviceFlag{wkproto.DeviceFlag(req.DeviceFlag)}
+	}
+	for _, deviceFlag := range deviceFlags {
+		device, err := u.s.store.GetDevice(req.UID, deviceFlag)
+		if err != nil && err != wkdb.ErrNotFound {
+			u.Error("获取设备信息失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", deviceFlag.ToUint8()))
+			c.ResponseError(err)
+			return
 		}
+		if wkdb.IsEmptyDevice(device) {
+			continue
+		}
+		if err = u.quitUserDevice(req.UID, deviceFlag, req.Disconnect); err != nil {
+			c.ResponseError(err)
+			return
+		}
+	}
+	c.ResponseOK()
+}
+
+// 如果当前节点不是用户所在槽的领导节点，则转发请求给领导节点 返回true表示已转发
+func (u *UserAPI) forwardToUserLeaderIfNeed(c *wkhttp.Context, uid string, bodyBytes []byte) bool {
+	leaderInfo, err := u.s.cluster.SlotLeaderOfChannel(uid, wkproto.ChannelTypePerson) // 获取频道的领导节点
+	if err != nil {
+		u.Error("获取频道所在节点失败！", zap.Error(err), zap.String("channelID", uid), zap.Uint8("channelType", wkproto.ChannelTypePerson))
+		c.ResponseError(errors.New("获取频道所在节点失败！"))
+		return true
+	}
+	if leaderInfo.Id == u.s.opts.Cluster.NodeId {
+		return false
 	}
+	u.Debug("转发请求：", zap.String("url", fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path)))
+	c.ForwardWithBody(fmt.Sprintf("%s%s", leaderInfo.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+	return true
+}
 
-	return nil
+func deviceTokenLockKey(uid string, deviceFlag wkproto.DeviceFlag) string {
+	return fmt.Sprintf("%s-%d", uid, deviceFlag)
+}
+
+// 根据有效期（秒）计算token的过期时间 expire<=0 表示永不过期
+func tokenExpireAt(now time.Time, expire int64) *time.Time {
+	if expire <= 0 {
+		return nil
+	}
+	expireAt := now.Add(time.Duration(expire) * time.Second)
+	return &expireAt
 }
 
 func (u *UserAPI) getOnlineStatus(c *wkhttp.Context) {
@@ -248,6 +407,53 @@ func (u *UserAPI) getOnlineConns(uids []string) []*OnlinestatusResp {
 	return onlineStatusResps
 }
 
+// 获取用户的在线状态（所有设备聚合后的状态）和最后在线时间
+func (u *UserAPI) getPresence(c *wkhttp.Context) {
+	var req PresenceQueryReq
+	if err := c.BindJSON(&req); err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+	infos, err := u.s.presence.query(req.ViewerUID, req.UIDs)
+	if err != nil {
+		u.Error("获取用户在线状态失败！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, infos)
+}
+
+// 设置用户最后在线时间的可见范围
+func (u *UserAPI) setPresencePrivacy(c *wkhttp.Context) {
+	var req PresencePrivacyReq
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		u.Error("数据格式有误！", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if err := req.Check(); err != nil {
+		c.ResponseError(err)
+		return
+	}
+
+	if u.forwardToUserLeaderIfNeed(c, req.UID, bodyBytes) {
+		return
+	}
+
+	if err := u.s.presence.setLastSeenPrivacy(req.UID, req.LastSeenPrivacy); err != nil {
+		u.Error("设置最后在线时间的可见范围失败！", zap.Error(err), zap.String("uid", req.UID))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
 // 更新用户的token
 func (u *UserAPI) updateToken(c *wkhttp.Context) {
 	var req UpdateTokenReq
@@ -321,6 +527,10 @@ func (u *UserAPI) updateToken(c *wkhttp.Context) {
 		}
 	}
 
+	lockKey := deviceTokenLockKey(req.UID, req.DeviceFlag)
+	u.deviceTokenLock.Lock(lockKey)
+	defer u.deviceTokenLock.Unlock(lockKey)
+
 	device, err := u.s.store.GetDevice(req.UID, req.DeviceFlag)
 	if err != nil && err != wkdb.ErrNotFound {
 		u.Error("获取设备信息失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
@@ -332,13 +542,15 @@ func (u *UserAPI) updateToken(c *wkhttp.Context) {
 	if wkdb.IsEmptyDevice(device) {
 		createdAt := time.Now()
 		err = u.s.store.AddDevice(wkdb.Device{
-			Id:          u.s.store.NextPrimaryKey(),
-			Uid:         req.UID,
-			DeviceFlag:  uint64(req.DeviceFlag),
-			DeviceLevel: uint8(req.DeviceLevel),
-			Token:       req.Token,
-			CreatedAt:   &createdAt,
-			UpdatedAt:   &createdAt,
+			Id:            u.s.store.NextPrimaryKey(),
+			Uid:           req.UID,
+			DeviceFlag:    uint64(req.DeviceFlag),
+			DeviceLevel:   uint8(req.DeviceLevel),
+			Token:         req.Token,
+			TokenExpireAt: tokenExpireAt(createdAt, req.Expire),
+			Scopes:        req.Scopes,
+			CreatedAt:     &createdAt,
+			UpdatedAt:     &createdAt,
 		})
 		if err != nil {
 			u.Error("添加设备失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
@@ -348,12 +560,14 @@ func (u *UserAPI) updateToken(c *wkhttp.Context) {
 	} else {
 		updatedAt := time.Now()
 		err = u.s.store.UpdateDevice(wkdb.Device{
-			Id:          device.Id,
-			Uid:         req.UID,
-			DeviceFlag:  uint64(req.DeviceFlag),
-			DeviceLevel: uint8(req.DeviceLevel),
-			Token:       req.Token,
-			UpdatedAt:   &updatedAt,
+			Id:            device.Id,
+			Uid:           req.UID,
+			DeviceFlag:    uint64(req.DeviceFlag),
+			DeviceLevel:   uint8(req.DeviceLevel),
+			Token:         req.Token,
+			TokenExpireAt: tokenExpireAt(updatedAt, req.Expire),
+			Scopes:        req.Scopes,
+			UpdatedAt:     &updatedAt,
 		})
 		if err != nil {
 			u.Error("更新设备失败！", zap.Error(err), zap.String("uid", req.UID), zap.Uint8("deviceFlag", req.DeviceFlag.ToUint8()))
@@ -364,20 +578,7 @@ func (u *UserAPI) updateToken(c *wkhttp.Context) {
 
 	if req.DeviceLevel == wkproto.DeviceLevelMaster {
 		// 如果存在旧连接，则发起踢出请求
-		oldConns := u.s.userReactor.getConnsByDeviceFlag(req.UID, req.DeviceFlag)
-		if len(oldConns) > 0 {
-			for _, oldConn := range oldConns {
-				u.Debug("更新Token时，存在旧连接！", zap.String("uid", req.UID), zap.Int64("id", oldConn.connId), zap.String("deviceFlag", req.DeviceFlag.String()))
-				_ = u.s.userReactor.writePacket(oldConn, &wkproto.DisconnectPacket{
-					ReasonCode: wkproto.ReasonConnectKick,
-					Reason:     "账号在其他设备上登录",
-				})
-
-				u.s.timingWheel.AfterFunc(time.Second*10, func() {
-					oldConn.close()
-				})
-			}
-		}
+		u.kickDeviceConns(req.UID, req.DeviceFlag, "账号在其他设备上登录", time.Second*10)
 	}
 
 	// // 创建或更新个人频道
@@ -614,6 +815,8 @@ type UpdateTokenReq struct {
 	Token       string              `json:"token"`        // 用户的token
 	DeviceFlag  wkproto.DeviceFlag  `json:"device_flag"`  // 设备标识  0.app 1.web
 	DeviceLevel wkproto.DeviceLevel `json:"device_level"` // 设备等级 0.为从设备 1.为主设备
+	Expire      int64               `json:"expire"`       // token有效期（单位秒） 0表示永不过期
+	Scopes      []string            `json:"scopes"`       // token的授权范围（connect、message:send） 为空表示不限制
 }
 
 // Check 检查输入
@@ -627,6 +830,12 @@ func (u UpdateTokenReq) Check() error {
 	if u.Token == "" {
 		return errors.New("token不能为空！")
 	}
+	if u.Expire < 0 {
+		return errors.New("expire不能小于0！")
+	}
+	if err := checkTokenScopes(u.Scopes); err != nil {
+		return err
+	}
 
 	if IsSpecialChar(u.UID) {
 		return errors.New("uid不能包含特殊字符！")
@@ -640,6 +849,31 @@ func (u UpdateTokenReq) Check() error {
 	return nil
 }
 
+// RotateTokenReq 轮换token请求
+type RotateTokenReq struct {
+	UID        string             `json:"uid"`         // 用户唯一uid
+	DeviceFlag wkproto.DeviceFlag `json:"device_flag"` // 设备标识
+	OldToken   string             `json:"old_token"`   // 旧token（不为空时必须与当前token一致才会轮换）
+	Token      string             `json:"token"`       // 新token
+	Expire     int64              `json:"expire"`      // 新token有效期（单位秒） 0表示永不过期
+	Scopes     []string           `json:"scopes"`      // 新token的授权范围
+	Disconnect bool               `json:"disconnect"`  // 是否断开设备当前的连接
+}
+
+// Check 检查输入
+func (r RotateTokenReq) Check() error {
+	if r.UID == "" {
+		return errors.New("uid不能为空！")
+	}
+	if r.Token == "" {
+		return errors.New("token不能为空！")
+	}
+	if r.Expire < 0 {
+		return errors.New("expire不能小于0！")
+	}
+	return checkTokenScopes(r.Scopes)
+}
+
 type OnlinestatusResp struct {
 	UID        string `json:"uid"`         // 在线用户uid
 	DeviceFlag uint8  `json:"device_flag"` // 设备标记 0. APP 1.web
@@ -79,8 +79,8 @@ func (v *VarzAPI) Settings(c *wkhttp.Context) {
 
 	setting := &SystemSetting{}
 	setting.Logger.TraceOn = wkutil.BoolToInt(v.s.opts.Logger.TraceOn)
-	setting.Logger.LokiOn = wkutil.BoolToInt(v.s.opts.LokiOn())
-	setting.PrometheusOn = wkutil.BoolToInt(v.s.opts.PrometheusOn())
+	setting.Logger.LokiOn = wkutil.BoolToInt(v.s.opts.Hot().LokiOn())
+	setting.PrometheusOn = wkutil.BoolToInt(v.s.opts.Hot().PrometheusOn())
 
 	c.JSON(http.StatusOK, setting)
 }
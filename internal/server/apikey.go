@@ -0,0 +1,411 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+const (
+	// ApiKeyHeader api key的请求头
+	ApiKeyHeader = "X-Api-Key"
+	// api key的前缀，方便识别泄露的密钥
+	apiKeyPrefix = "wk_"
+
+	// 请求上下文中保存调用者的key
+	ctxKeyApiCaller = "apiCaller"
+)
+
+// apiCaller http api的调用者
+type apiCaller struct {
+	name        string                 // api key名称，使用ManagerToken调用时为管理员uid
+	manager     bool                   // 是否是通过ManagerToken调用
+	permissions auth.PermissionConfigs // 调用者的权限
+}
+
+// apiKeyEntry 缓存中的api key
+type apiKeyEntry struct {
+	apiKey      wkdb.ApiKey
+	permissions auth.PermissionConfigs
+	stats       *apiKeyStats
+}
+
+// apiKeyStats api key在当前节点的调用统计
+type apiKeyStats struct {
+	callCount   atomic.Int64
+	deniedCount atomic.Int64
+	lastUsedAt  atomic.Int64
+	lastPath    atomic.String
+	lastIP      atomic.String
+}
+
+// apiKeyManager api key管理，api key存储在固定槽位上，各节点缓存一份用于认证
+type apiKeyManager struct {
+	s *Server
+	wklog.Log
+	auditLog wklog.Log
+
+	mu      sync.RWMutex
+	entries map[string]*apiKeyEntry // key为api key的hash值
+	stats   map[string]*apiKeyStats // key为api key的名称（key更新后统计数据保留）
+	loaded  atomic.Bool
+
+	refreshTimer *timingwheel.Timer
+}
+
+func newApiKeyManager(s *Server) *apiKeyManager {
+	return &apiKeyManager{
+		s:        s,
+		Log:      wklog.NewWKLog("apiKeyManager"),
+		auditLog: wklog.NewWKLog("apiKeyAudit"),
+		entries:  make(map[string]*apiKeyEntry),
+		stats:    make(map[string]*apiKeyStats),
+	}
+}
+
+func (m *apiKeyManager) start() error {
+	// 定时刷新，防止错过变更通知
+	m.refreshTimer = m.s.Schedule(m.s.opts.ApiKey.RefreshInterval, func() {
+		if err := m.reload(); err != nil {
+			m.Warn("refresh api keys failed", zap.Error(err))
+		}
+	})
+	return nil
+}
+
+func (m *apiKeyManager) stop() {
+	if m.refreshTimer != nil {
+		m.refreshTimer.Stop()
+	}
+}
+
+// loadIfNeed 第一次使用时加载api key
+func (m *apiKeyManager) loadIfNeed() error {
+	if m.loaded.Load() {
+		return nil
+	}
+	return m.reload()
+}
+
+// reload 从存储api key的槽领导节点重新加载
+func (m *apiKeyManager) reload() error {
+	apiKeys, err := m.getOrRequestApiKeys()
+	if err != nil {
+		return err
+	}
+	entries := make(map[string]*apiKeyEntry, len(apiKeys))
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, apiKey := range apiKeys {
+		permissions, err := auth.ParseScopes(apiKey.Scopes)
+		if err != nil {
+			m.Warn("invalid api key scopes, ignore it", zap.String("name", apiKey.Name), zap.Strings("scopes", apiKey.Scopes), zap.Error(err))
+			continue
+		}
+		stats := m.stats[apiKey.Name]
+		if stats == nil {
+			stats = &apiKeyStats{}
+			m.stats[apiKey.Name] = stats
+		}
+		entries[apiKey.KeyHash] = &apiKeyEntry{
+			apiKey:      apiKey,
+			permissions: permissions,
+			stats:       stats,
+		}
+	}
+	m.entries = entries
+	m.loaded.Store(true)
+	return nil
+}
+
+func (m *apiKeyManager) getOrRequestApiKeys() ([]wkdb.ApiKey, error) {
+	nodeInfo, err := m.s.cluster.SlotLeaderNodeInfo(clusterstore.ApiKeySlotId)
+	if err != nil {
+		return nil, err
+	}
+	if nodeInfo.Id == m.s.opts.Cluster.NodeId {
+		return m.s.store.GetApiKeys()
+	}
+	timeoutCtx, cancel := context.WithTimeout(m.s.ctx, m.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	resp, err := m.s.cluster.RequestWithContext(timeoutCtx, nodeInfo.Id, "/wk/getApiKeys", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		return nil, fmt.Errorf("request api keys failed, status: %d err:%s", resp.Status, string(resp.Body))
+	}
+	var apiKeys apiKeysResp
+	if err = apiKeys.Unmarshal(resp.Body); err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+// notifyChanged 通知所有在线节点重新加载api key
+func (m *apiKeyManager) notifyChanged() {
+	if err := m.reload(); err != nil {
+		m.Warn("reload api keys failed", zap.Error(err))
+	}
+	for _, node := range m.s.clusterServer.GetConfig().Nodes {
+		if node.Id == m.s.opts.Cluster.NodeId || !node.Online {
+			continue
+		}
+		go func(nodeId uint64) {
+			timeoutCtx, cancel := context.WithTimeout(m.s.ctx, m.s.opts.Cluster.ReqTimeout)
+			defer cancel()
+			resp, err := m.s.cluster.RequestWithContext(timeoutCtx, nodeId, "/wk/apiKeysChanged", nil)
+			if err != nil {
+				m.Warn("notify api keys changed failed", zap.Uint64("nodeId", nodeId), zap.Error(err))
+				return
+			}
+			if resp.Status != proto.StatusOK {
+				m.Warn("notify api keys changed failed", zap.Uint64("nodeId", nodeId), zap.String("err", string(resp.Body)))
+			}
+		}(node.Id)
+	}
+}
+
+// authenticate 通过明文api key获取缓存中的api key
+func (m *apiKeyManager) authenticate(key string) (*apiKeyEntry, error) {
+	if err := m.loadIfNeed(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	entry := m.entries[hashApiKey(key)]
+	m.mu.RUnlock()
+	if entry == nil {
+		return nil, errors.New("invalid api key")
+	}
+	if entry.apiKey.Disabled {
+		return nil, errors.New("api key disabled")
+	}
+	if entry.apiKey.Expired(time.Now()) {
+		return nil, errors.New("api key expired")
+	}
+	return entry, nil
+}
+
+// getApiKeys 获取缓存中的api key
+func (m *apiKeyManager) getApiKeys() ([]*apiKeyEntry, error) {
+	if err := m.loadIfNeed(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	entries := make([]*apiKeyEntry, 0, len(m.entries))
+	for _, entry := range m.entries {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// acceptManagerJwt 集群接口是否接受管理端的jwt
+func (m *apiKeyManager) acceptManagerJwt() bool {
+	secret := strings.TrimSpace(m.s.opts.Jwt.Secret)
+	return m.s.opts.Auth.On && secret != "" && secret != defaultJwtSecret
+}
+
+// authMiddleware http api的认证中间件
+// 1. 请求头token等于ManagerToken时拥有所有权限（兼容旧版本）
+// 2. 请求头X-Api-Key为有效的api key时，校验api key是否拥有请求接口的权限
+// 3. 没有配置ManagerToken并且没有开启apiKey.on时，不做认证
+func (m *apiKeyManager) authMiddleware() wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		managerToken := strings.TrimSpace(m.s.opts.ManagerToken)
+		if managerToken != "" && c.GetHeader("token") == managerToken {
+			c.Set(ctxKeyApiCaller, &apiCaller{name: m.s.opts.ManagerUID, manager: true})
+			c.Set("username", m.s.opts.ManagerUID)
+			c.Next()
+			return
+		}
+
+		// 管理端转发过来的集群接口请求，使用管理端用户的权限
+		// 只有开启了管理端认证并且配置了非默认的jwt secret时才接受jwt，否则任何人都能用默认secret伪造jwt
+		if strings.HasPrefix(c.Request.URL.Path, "/cluster") && m.acceptManagerJwt() {
+			if username := managerJwtUsername(c, m.s.opts.Jwt.Secret); username != "" {
+				if !m.s.opts.Auth.HasUser(username) {
+					c.AbortWithStatus(http.StatusUnauthorized)
+					return
+				}
+				c.Set("username", username)
+				c.Next()
+				return
+			}
+		}
+
+		key := strings.TrimSpace(c.GetHeader(ApiKeyHeader))
+		if key == "" {
+			if managerToken == "" && !m.s.opts.ApiKey.On {
+				c.Next()
+				return
+			}
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		entry, err := m.authenticate(key)
+		if err != nil {
+			m.auditLog.Info("api key rejected", zap.String("path", c.Request.URL.Path), zap.String("ip", m.clientIP(c)), zap.Error(err))
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		path := c.FullPath()
+		if path == "" {
+			path = c.Request.URL.Path
+		}
+		rs, action := apiPermissionOf(c.Request.Method, path)
+		stats := entry.stats
+		stats.callCount.Inc()
+		stats.lastUsedAt.Store(time.Now().UnixNano())
+		stats.lastPath.Store(path)
+		stats.lastIP.Store(m.clientIP(c))
+
+		if !entry.permissions.HasPermission(rs, action) {
+			stats.deniedCount.Inc()
+			m.auditLog.Info("api key permission denied", zap.String("name", entry.apiKey.Name), zap.String("method", c.Request.Method), zap.String("path", path), zap.String("resource", string(rs)), zap.String("action", string(action)), zap.String("ip", m.clientIP(c)))
+			c.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+		c.Set(ctxKeyApiCaller, &apiCaller{name: entry.apiKey.Name, permissions: entry.permissions})
+		c.Set(wkhttp.ContextKeyPermissionChecked, true)
+		c.Next()
+		m.auditLog.Info("api key call", zap.String("name", entry.apiKey.Name), zap.String("method", c.Request.Method), zap.String("path", path), zap.Int("status", c.Writer.Status()), zap.String("ip", m.clientIP(c)))
+	}
+}
+
+func (m *apiKeyManager) clientIP(c *wkhttp.Context) string {
+	ip := m.s.ipAccess.clientIP(c.RemoteIP(), c.GetHeader("X-Forwarded-For"), c.GetHeader("X-Real-IP"))
+	if ip == nil {
+		return ""
+	}
+	return ip.String()
+}
+
+// getApiCaller 获取http请求的调用者，没有认证时返回nil
+func getApiCaller(c *wkhttp.Context) *apiCaller {
+	v, ok := c.Get(ctxKeyApiCaller)
+	if !ok {
+		return nil
+	}
+	return v.(*apiCaller)
+}
+
+// apiPermission 接口需要的权限
+type apiPermission struct {
+	prefix   string
+	resource resource.Id
+}
+
+// 接口路径前缀对应的资源，按顺序匹配
+var apiPermissions = []apiPermission{
+	{prefix: "/user/token", resource: resource.Api.UserToken},
+	{prefix: "/user/systemuids", resource: resource.Api.System},
+	{prefix: "/user", resource: resource.Api.User},
+	{prefix: "/channel/messagesync", resource: resource.Api.Message},
+	{prefix: "/channel", resource: resource.Api.Channel},
+	{prefix: "/tmpchannel", resource: resource.Api.Channel},
+	{prefix: "/conversation", resource: resource.Api.Conversation},
+	{prefix: "/message", resource: resource.Api.Message},
+	{prefix: "/stream", resource: resource.Api.Stream},
+	{prefix: "/route", resource: resource.Api.Route},
+	{prefix: "/apikey", resource: resource.Api.ApiKey},
+	{prefix: "/audit", resource: resource.Api.System},
+}
+
+// 使用POST请求但是只读取数据的接口
+var apiReadPaths = map[string]bool{
+	"/channel/messagesync":       true,
+	"/conversation/sync":         true,
+	"/conversation/syncMessages": true,
+	"/messages":                  true,
+	"/message":                   true,
+	"/message/sync":              true,
+	"/user/onlinestatus":         true,
+	"/user/presence":             true,
+	"/route/batch":               true,
+}
+
+// apiPermissionOf 获取接口需要的资源和操作权限，没有匹配的接口按系统资源处理
+func apiPermissionOf(method string, path string) (resource.Id, auth.Action) {
+	action := auth.ActionWrite
+	if method == http.MethodGet || method == http.MethodHead || apiReadPaths[path] {
+		action = auth.ActionRead
+	}
+	for _, p := range apiPermissions {
+		if strings.HasPrefix(path, p.prefix) {
+			return p.resource, action
+		}
+	}
+	return resource.Api.System, action
+}
+
+// newApiKeySecret 生成api key的明文
+func newApiKeySecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+func hashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+type apiKeysResp []wkdb.ApiKey
+
+func (a apiKeysResp) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(a)))
+	for _, apiKey := range a {
+		data, err := apiKey.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		enc.WriteBinary(data)
+	}
+	return enc.Bytes(), nil
+}
+
+func (a *apiKeysResp) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		data, err := dec.Binary()
+		if err != nil {
+			return err
+		}
+		var apiKey wkdb.ApiKey
+		if err = apiKey.Unmarshal(data); err != nil {
+			return err
+		}
+		*a = append(*a, apiKey)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApiPermissionOf(t *testing.T) {
+	rs, action := apiPermissionOf("POST", "/message/send")
+	assert.Equal(t, resource.Api.Message, rs)
+	assert.Equal(t, auth.ActionWrite, action)
+
+	rs, action = apiPermissionOf("POST", "/channel/messagesync")
+	assert.Equal(t, resource.Api.Message, rs)
+	assert.Equal(t, auth.ActionRead, action)
+
+	rs, action = apiPermissionOf("POST", "/message/syncack")
+	assert.Equal(t, resource.Api.Message, rs)
+	assert.Equal(t, auth.ActionWrite, action)
+
+	rs, action = apiPermissionOf("POST", "/user/token_rotate")
+	assert.Equal(t, resource.Api.UserToken, rs)
+	assert.Equal(t, auth.ActionWrite, action)
+
+	rs, action = apiPermissionOf("GET", "/varz")
+	assert.Equal(t, resource.Api.System, rs)
+	assert.Equal(t, auth.ActionRead, action)
+}
+
+func TestApiKeyScopes(t *testing.T) {
+	permissions, err := auth.ParseScopes([]string{"message:send", "user:token", "channel:read"})
+	assert.NoError(t, err)
+
+	assert.True(t, permissions.HasPermission(apiPermissionOf("POST", "/message/send")))
+	assert.True(t, permissions.HasPermission(apiPermissionOf("POST", "/user/token")))
+	assert.True(t, permissions.HasPermission(apiPermissionOf("GET", "/channel/whitelist")))
+	assert.False(t, permissions.HasPermission(apiPermissionOf("POST", "/channel/subscriber_add")))
+	assert.False(t, permissions.HasPermission(apiPermissionOf("POST", "/apikey/create")))
+
+	readonly, err := auth.ParseScopes([]string{"read"})
+	assert.NoError(t, err)
+	assert.True(t, readonly.HasPermission(apiPermissionOf("POST", "/conversation/sync")))
+	assert.False(t, readonly.HasPermission(apiPermissionOf("POST", "/message/send")))
+	assert.False(t, readonly.Contains(permissions))
+
+	all, err := auth.ParseScopes([]string{"*"})
+	assert.NoError(t, err)
+	assert.True(t, all.Contains(permissions))
+
+	_, err = auth.ParseScopes([]string{"message:delete"})
+	assert.Error(t, err)
+}
+
+func TestApiKeyCheckGrant(t *testing.T) {
+	a := &ApiKeyAPI{}
+	newCtx := func(scopes ...string) *wkhttp.Context {
+		permissions, err := auth.ParseScopes(scopes)
+		assert.NoError(t, err)
+		c := &wkhttp.Context{Context: &gin.Context{}}
+		c.Set(ctxKeyApiCaller, &apiCaller{name: "test", permissions: permissions})
+		return c
+	}
+
+	c := newCtx("apikey:write", "message:send")
+	assert.NoError(t, a.checkGrant(c, []string{"message:send"}))
+	assert.Error(t, a.checkGrant(c, []string{"*"}))             // 更大权限的api key
+	assert.Error(t, a.checkGrant(c, []string{"channel:write"})) // 不相交的权限
+
+	c = newCtx("*")
+	assert.NoError(t, a.checkGrant(c, []string{"*"}))
+}
+
+func TestApiKeysRespMarshal(t *testing.T) {
+	now := time.Now()
+	resp := apiKeysResp{
+		{Name: "test1", KeyHash: hashApiKey("key1"), Scopes: []string{"read"}, CreatedAt: &now},
+		{Name: "test2", KeyHash: hashApiKey("key2"), Scopes: []string{"message:send", "user:token"}, Disabled: true, ExpireAt: &now},
+	}
+	data, err := resp.Marshal()
+	assert.NoError(t, err)
+
+	var resp2 apiKeysResp
+	err = resp2.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(resp2))
+	assert.Equal(t, resp[1].Scopes, resp2[1].Scopes)
+	assert.True(t, resp2[1].Disabled)
+	assert.Equal(t, now.UnixNano(), resp2[1].ExpireAt.UnixNano())
+	assert.Nil(t, resp2[0].ExpireAt)
+}
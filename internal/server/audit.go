@@ -0,0 +1,415 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/bwmarrin/snowflake"
+	"github.com/gin-gonic/gin"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+)
+
+const (
+	AuditActorApiKey       = "apiKey"       // 通过api key调用
+	AuditActorManagerToken = "managerToken" // 通过ManagerToken调用
+	AuditActorManager      = "manager"      // 管理员（后台登录用户）
+	AuditActorAnonymous    = "anonymous"    // 未认证
+
+	AuditResultSuccess = "success"
+	AuditResultFailure = "failure"
+)
+
+const (
+	auditMaxTargetLen   = 1024 // 操作对象的最大长度
+	auditMaxReasonLen   = 512  // 失败原因的最大长度
+	auditMaxCaptureBody = 1024 // 失败时捕获的响应体最大长度
+)
+
+// auditRoute 需要审计的接口
+type auditRoute struct {
+	action     string   // 操作名
+	fields     []string // 请求体中作为操作对象的字段（路径参数会自动加入）
+	actorField string   // 请求体中作为操作者的字段（未认证的接口，比如登录）
+}
+
+// 需要审计的接口，key为 method + 路由路径
+var auditRoutes = map[string]auditRoute{
+	// 频道
+	"POST /channel/delete":            {action: "channel.delete", fields: []string{"channel_id", "channel_type"}},
+	"POST /channel/subscriber_update": {action: "channel.subscriber_update", fields: []string{"channel_id", "channel_type", "uids", "role", "mute_until", "mute_seconds"}},
+	"POST /channel/blacklist_add":     {action: "channel.blacklist_add", fields: []string{"channel_id", "channel_type", "uids"}},
+	"POST /channel/blacklist_set":     {action: "channel.blacklist_set", fields: []string{"channel_id", "channel_type", "uids"}},
+	"POST /channel/blacklist_remove":  {action: "channel.blacklist_remove", fields: []string{"channel_id", "channel_type", "uids"}},
+	"POST /channel/whitelist_add":     {action: "channel.whitelist_add", fields: []string{"channel_id", "channel_type", "uids"}},
+	"POST /channel/whitelist_set":     {action: "channel.whitelist_set", fields: []string{"channel_id", "channel_type", "uids"}},
+	"POST /channel/whitelist_remove":  {action: "channel.whitelist_remove", fields: []string{"channel_id", "channel_type", "uids"}},
+
+	// 用户
+	"POST /user/device_quit":          {action: "user.device_quit", fields: []string{"uid", "device_flag"}},
+	"POST /user/token_rotate":         {action: "user.token_rotate", fields: []string{"uid", "device_flag"}},
+	"POST /user/token_revoke":         {action: "user.token_revoke", fields: []string{"uid", "device_flag"}},
+	"POST /user/e2ee/keys_upload":     {action: "user.e2ee_keys_upload", fields: []string{"uid", "device_id"}},
+	"POST /user/e2ee/keys_remove":     {action: "user.e2ee_keys_remove", fields: []string{"uid", "device_id"}},
+	"POST /user/systemuids_add":       {action: "system.uids_add", fields: []string{"uids"}},
+	"POST /user/systemuids_remove":    {action: "system.uids_remove", fields: []string{"uids"}},
+	"POST /apikey/create":             {action: "apikey.create", fields: []string{"name", "scopes", "expire"}},
+	"POST /apikey/update":             {action: "apikey.update", fields: []string{"name", "scopes", "disabled", "expire"}},
+	"POST /apikey/rotate":             {action: "apikey.rotate", fields: []string{"name"}},
+	"POST /apikey/delete":             {action: "apikey.delete", fields: []string{"name"}},
+	"POST /ipaccess/rules":            {action: "ipaccess.set_rules", fields: []string{"listener", "allow", "deny"}},
+	"POST /ipaccess/reload":           {action: "ipaccess.reload"},
+	"POST /manager/login":             {action: "manager.login", actorField: "username"},
+	"POST /manager/password":          {action: "manager.password"},
+	"POST /manager/users/create":      {action: "manager.user_create", fields: []string{"username", "role"}},
+	"POST /manager/users/update":      {action: "manager.user_update", fields: []string{"username", "role", "disabled"}},
+	"POST /manager/users/delete":      {action: "manager.user_delete", fields: []string{"username"}},
+	"POST /cluster/slots/:id/migrate": {action: "slot.migrate", fields: []string{"migrate_from", "migrate_to"}},
+
+	// 分布式频道
+	"POST /cluster/channels/:channel_id/:channel_type/migrate": {action: "channel.migrate", fields: []string{"migrate_from", "migrate_to"}},
+	"POST /cluster/channels/:channel_id/:channel_type/start":   {action: "channel.start"},
+	"POST /cluster/channels/:channel_id/:channel_type/stop":    {action: "channel.stop"},
+}
+
+// auditManager 审计日志管理，审计日志通过slot 0复制到各个副本，只追加不修改
+type auditManager struct {
+	s *Server
+	wklog.Log
+	idGen   *snowflake.Node
+	logC    chan wkdb.AuditLog
+	stopper *syncutil.Stopper
+	// 提交失败时的兜底日志，保证审计记录不会静默丢失
+	fallbackLog wklog.Log
+}
+
+func newAuditManager(s *Server) *auditManager {
+	idGen, _ := snowflake.NewNode(int64(s.opts.Cluster.NodeId))
+	queueSize := s.opts.Audit.QueueSize
+	if queueSize <= 0 {
+		queueSize = 1
+	}
+	return &auditManager{
+		s:           s,
+		Log:         wklog.NewWKLog("auditManager"),
+		idGen:       idGen,
+		logC:        make(chan wkdb.AuditLog, queueSize),
+		stopper:     syncutil.NewStopper(),
+		fallbackLog: wklog.NewWKLog("audit"),
+	}
+}
+
+func (a *auditManager) start() error {
+	if !a.s.opts.Audit.On {
+		return nil
+	}
+	a.stopper.RunWorker(a.loop)
+	return nil
+}
+
+func (a *auditManager) stop() {
+	a.stopper.Stop()
+}
+
+// record 记录审计日志（异步批量提交）
+func (a *auditManager) record(log wkdb.AuditLog) {
+	if !a.s.opts.Audit.On {
+		return
+	}
+	if log.Id == 0 {
+		log.Id = uint64(a.idGen.Generate().Int64())
+	}
+	if log.NodeId == 0 {
+		log.NodeId = a.s.opts.Cluster.NodeId
+	}
+	if log.CreatedAt == nil {
+		now := time.Now()
+		log.CreatedAt = &now
+	}
+	log.Target = truncateString(log.Target, auditMaxTargetLen)
+	log.Reason = truncateString(log.Reason, auditMaxReasonLen)
+	select {
+	case a.logC <- log:
+	default:
+		a.Warn("audit queue is full", zap.Int("queueSize", cap(a.logC)))
+		a.writeFallback(log)
+	}
+}
+
+func (a *auditManager) loop() {
+	flushCount := a.s.opts.Audit.FlushCount
+	if flushCount <= 0 {
+		flushCount = 100
+	}
+	tk := time.NewTicker(a.s.opts.Audit.FlushInterval)
+	defer tk.Stop()
+
+	logs := make([]wkdb.AuditLog, 0, flushCount)
+	for {
+		select {
+		case log := <-a.logC:
+			logs = append(logs, log)
+			if len(logs) >= flushCount {
+				a.flush(logs)
+				logs = logs[:0]
+			}
+		case <-tk.C:
+			if len(logs) > 0 {
+				a.flush(logs)
+				logs = logs[:0]
+			}
+		case <-a.stopper.ShouldStop():
+			// 提交剩余的审计日志
+		drain:
+			for {
+				select {
+				case log := <-a.logC:
+					logs = append(logs, log)
+				default:
+					break drain
+				}
+			}
+			if len(logs) > 0 {
+				a.flush(logs)
+			}
+			return
+		}
+	}
+}
+
+func (a *auditManager) flush(logs []wkdb.AuditLog) {
+	if err := a.s.store.AppendAuditLogs(logs); err != nil {
+		a.Error("append audit logs failed", zap.Error(err), zap.Int("count", len(logs)))
+		for _, log := range logs {
+			a.writeFallback(log)
+		}
+		return
+	}
+	if a.s.opts.Audit.WebhookOn {
+		// logs会被复用，这里需要复制一份
+		resps := make([]*auditLogResp, 0, len(logs))
+		for _, log := range logs {
+			resps = append(resps, newAuditLogResp(log))
+		}
+		a.s.webhook.TriggerEvent(&Event{
+			Event: EventAuditLog,
+			Data:  resps,
+		})
+	}
+}
+
+// search 搜索审计日志，审计日志存储在固定槽位上，从槽领导查询
+func (a *auditManager) search(req wkdb.AuditLogSearchReq) ([]wkdb.AuditLog, error) {
+	nodeInfo, err := a.s.cluster.SlotLeaderNodeInfo(clusterstore.AuditLogSlotId)
+	if err != nil {
+		return nil, err
+	}
+	if nodeInfo.Id == a.s.opts.Cluster.NodeId {
+		return a.s.store.SearchAuditLogs(req)
+	}
+	timeoutCtx, cancel := context.WithTimeout(a.s.ctx, a.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	resp, err := a.s.cluster.RequestWithContext(timeoutCtx, nodeInfo.Id, "/wk/searchAuditLogs", []byte(wkutil.ToJSON(req)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		return nil, fmt.Errorf("search audit logs failed, status: %d err:%s", resp.Status, string(resp.Body))
+	}
+	var logs wkdb.AuditLogs
+	if err = logs.Unmarshal(resp.Body); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
+
+func (a *auditManager) writeFallback(log wkdb.AuditLog) {
+	a.fallbackLog.Warn("audit log not persisted",
+		zap.Uint64("id", log.Id),
+		zap.String("action", log.Action),
+		zap.String("actor", log.Actor),
+		zap.String("actorType", log.ActorType),
+		zap.String("sourceIp", log.SourceIp),
+		zap.String("target", log.Target),
+		zap.String("result", log.Result),
+		zap.Int("status", log.Status),
+		zap.String("reason", log.Reason),
+	)
+}
+
+// middleware 审计中间件，需要放在认证中间件之前，这样认证失败的请求也会被记录
+// 请求被转发到其他节点处理时，由实际处理的节点记录，当前节点不再记录
+func (a *auditManager) middleware() wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		if !a.s.opts.Audit.On {
+			c.Next()
+			return
+		}
+		route, ok := auditRoutes[c.Request.Method+" "+c.FullPath()]
+		if !ok {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			body, _ = io.ReadAll(c.Request.Body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		writer := &auditResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		if c.Forwarded() {
+			return
+		}
+
+		bodyMap := map[string]interface{}{}
+		if len(body) > 0 {
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			_ = dec.Decode(&bodyMap)
+		}
+
+		actor, actorType := a.actorOf(c, route, bodyMap)
+		result, status, reason := auditResultOf(writer.Status(), writer.body.Bytes())
+		a.record(wkdb.AuditLog{
+			Action:    route.action,
+			Actor:     actor,
+			ActorType: actorType,
+			SourceIp:  a.s.apiKeyManager.clientIP(c),
+			Method:    c.Request.Method,
+			Path:      c.Request.URL.Path,
+			Target:    auditTargetOf(c.Params, route.fields, bodyMap),
+			Result:    result,
+			Status:    status,
+			Reason:    reason,
+		})
+	}
+}
+
+// actorOf 获取操作者
+func (a *auditManager) actorOf(c *wkhttp.Context, route auditRoute, bodyMap map[string]interface{}) (string, string) {
+	if caller := getApiCaller(c); caller != nil {
+		if caller.manager {
+			return caller.name, AuditActorManagerToken
+		}
+		return caller.name, AuditActorApiKey
+	}
+	if username := c.GetString("username"); username != "" {
+		return username, AuditActorManager
+	}
+	if route.actorField != "" {
+		if v, ok := bodyMap[route.actorField]; ok {
+			return auditFormatValue(v), AuditActorManager
+		}
+	}
+	// 管理端的请求转发到其他节点的http api时，通过jwt识别管理员
+	if username := managerJwtUsername(c, a.s.opts.Jwt.Secret); username != "" {
+		return username, AuditActorManager
+	}
+	return "", AuditActorAnonymous
+}
+
+// auditTargetOf 操作对象，格式为 key=value key=value
+func auditTargetOf(params gin.Params, fields []string, bodyMap map[string]interface{}) string {
+	parts := make([]string, 0, len(params)+len(fields))
+	for _, param := range params {
+		parts = append(parts, fmt.Sprintf("%s=%s", param.Key, param.Value))
+	}
+	for _, field := range fields {
+		v, ok := bodyMap[field]
+		if !ok || v == nil {
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s=%s", field, auditFormatValue(v)))
+	}
+	return strings.Join(parts, " ")
+}
+
+func auditFormatValue(v interface{}) string {
+	switch vv := v.(type) {
+	case string:
+		return vv
+	case []interface{}:
+		items := make([]string, 0, len(vv))
+		for _, item := range vv {
+			items = append(items, auditFormatValue(item))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	default:
+		return fmt.Sprintf("%v", vv)
+	}
+}
+
+// auditResultOf 根据响应获取操作结果，部分接口http状态码为200，但在响应体的status中返回错误码
+func auditResultOf(httpStatus int, body []byte) (result string, status int, reason string) {
+	status = httpStatus
+	var resp struct {
+		Status *int   `json:"status"`
+		Msg    string `json:"msg"`
+		Error  string `json:"error"`
+	}
+	if len(body) > 0 {
+		_ = json.Unmarshal(body, &resp)
+	}
+	if status < http.StatusBadRequest && resp.Status != nil && *resp.Status >= http.StatusBadRequest {
+		status = *resp.Status
+	}
+	if status >= http.StatusBadRequest {
+		reason = resp.Msg
+		if reason == "" {
+			reason = resp.Error
+		}
+		if reason == "" {
+			reason = http.StatusText(status)
+		}
+		return AuditResultFailure, status, reason
+	}
+	return AuditResultSuccess, status, ""
+}
+
+func truncateString(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	return strings.ToValidUTF8(s[:max], "")
+}
+
+// auditResponseWriter 捕获响应体的前部分，用于获取失败原因
+type auditResponseWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditResponseWriter) Write(b []byte) (int, error) {
+	w.capture(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *auditResponseWriter) WriteString(s string) (int, error) {
+	w.capture([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *auditResponseWriter) capture(b []byte) {
+	if remain := auditMaxCaptureBody - w.body.Len(); remain > 0 {
+		if len(b) > remain {
+			b = b[:remain]
+		}
+		w.body.Write(b)
+	}
+}
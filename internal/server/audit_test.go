@@ -0,0 +1,43 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditTargetOf(t *testing.T) {
+	params := gin.Params{{Key: "channel_id", Value: "g1"}, {Key: "channel_type", Value: "2"}}
+	var bodyMap map[string]interface{}
+	err := json.Unmarshal([]byte(`{"migrate_from":1001,"migrate_to":1002,"uids":["u1","u2"],"password":"pwd"}`), &bodyMap)
+	assert.NoError(t, err)
+
+	target := auditTargetOf(params, []string{"migrate_from", "migrate_to", "uids", "not_exist"}, bodyMap)
+	assert.Equal(t, "channel_id=g1 channel_type=2 migrate_from=1001 migrate_to=1002 uids=[u1,u2]", target)
+}
+
+func TestAuditResultOf(t *testing.T) {
+	result, status, reason := auditResultOf(http.StatusOK, []byte(`{"status":200}`))
+	assert.Equal(t, AuditResultSuccess, result)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "", reason)
+
+	result, status, reason = auditResultOf(http.StatusBadRequest, []byte(`{"msg":"频道不存在！","status":400}`))
+	assert.Equal(t, AuditResultFailure, result)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "频道不存在！", reason)
+
+	// http状态码为200，响应体中返回错误码
+	result, status, _ = auditResultOf(http.StatusOK, []byte(`{"status":401}`))
+	assert.Equal(t, AuditResultFailure, result)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	// 认证失败没有响应体
+	result, status, reason = auditResultOf(http.StatusUnauthorized, nil)
+	assert.Equal(t, AuditResultFailure, result)
+	assert.Equal(t, http.StatusUnauthorized, status)
+	assert.Equal(t, http.StatusText(http.StatusUnauthorized), reason)
+}
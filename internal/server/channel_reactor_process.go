@@ -89,6 +89,7 @@ func (r *channelReactor) processInit(req *initReq) {
 		return
 	}
 
+	var channelInfo *wkdb.ChannelInfo
 	if leaderNode.Id == r.s.opts.Cluster.NodeId { // 只有领导才需要makeReceiverTag
 		_, err = req.ch.makeReceiverTag()
 		if err != nil {
@@ -101,13 +102,34 @@ func (r *channelReactor) processInit(req *initReq) {
 			})
 			return
 		}
+
+		// 领导节点负责权限判断，需要加载频道基础信息（封禁、发言模式、端到端加密等）
+		realChannelId := req.ch.channelId
+		if r.opts.IsCmdChannel(realChannelId) {
+			realChannelId = r.opts.CmdChannelConvertOrginalChannel(realChannelId)
+		}
+		info, err := r.s.store.GetChannel(realChannelId, req.ch.channelType)
+		if err != nil && err != wkdb.ErrNotFound {
+			r.Error("processInit: GetChannel failed", zap.Error(err))
+			sub.step(req.ch, &ChannelAction{
+				UniqueNo:   req.ch.uniqueNo,
+				ActionType: ChannelActionInitResp,
+				LeaderId:   leaderNode.Id,
+				Reason:     ReasonError,
+			})
+			return
+		}
+		if err == nil {
+			channelInfo = &info
+		}
 	}
 
 	sub.step(req.ch, &ChannelAction{
-		UniqueNo:   req.ch.uniqueNo,
-		ActionType: ChannelActionInitResp,
-		LeaderId:   leaderNode.Id,
-		Reason:     ReasonSuccess,
+		UniqueNo:    req.ch.uniqueNo,
+		ActionType:  ChannelActionInitResp,
+		LeaderId:    leaderNode.Id,
+		ChannelInfo: channelInfo,
+		Reason:      ReasonSuccess,
 	})
 }
 
@@ -501,6 +523,13 @@ func (r *channelReactor) processPermission(req *permissionReq) {
 			continue
 		}
 
+		// 端到端加密的频道只接受客户端signal加密的消息
+		if req.ch.info.E2ee && !msg.SendPacket.Setting.IsSet(wkproto.SettingSignal) {
+			r.MessageTrace("权限验证失败", msg.SendPacket.ClientMsgNo, "processPermission", zap.String("reasonCode", ReasonE2eeRequired.String()), zap.Error(errors.New("e2ee required")))
+			req.messages[i].ReasonCode = ReasonE2eeRequired
+			continue
+		}
+
 		if _, ok := fromUidMap[msg.FromUid]; ok { // 已经判断过权限
 			req.messages[i].ReasonCode = fromUidMap[msg.FromUid]
 			continue
@@ -508,7 +537,7 @@ func (r *channelReactor) processPermission(req *permissionReq) {
 
 		r.MessageTrace("权限验证", msg.SendPacket.ClientMsgNo, "processPermission")
 
-		reasonCode, err := r.hasPermission(req.ch.channelId, req.ch.channelType, msg.FromUid, req.ch)
+		reasonCode, err := r.hasPermission(req.ch.channelId, req.ch.channelType, msg.FromUid, req.ch.info)
 		if err != nil {
 			r.Error("hasPermission error", zap.Error(err))
 			req.messages[i].ReasonCode = wkproto.ReasonSystemError
@@ -534,7 +563,7 @@ func (r *channelReactor) processPermission(req *permissionReq) {
 	})
 }
 
-func (r *channelReactor) hasPermission(channelId string, channelType uint8, fromUid string, ch *channel) (wkproto.ReasonCode, error) {
+func (r *channelReactor) hasPermission(channelId string, channelType uint8, fromUid string, channelInfo wkdb.ChannelInfo) (wkproto.ReasonCode, error) {
 
 	realFakeChannelId := channelId
 	if r.opts.IsCmdChannel(channelId) {
@@ -580,8 +609,6 @@ func (r *channelReactor) hasPermission(channelId string, channelType uint8, from
 		return reasonCode, nil
 	}
 
-	channelInfo := ch.info
-
 	if channelInfo.Ban { // 频道被封禁
 		return wkproto.ReasonBan, nil
 	}
@@ -601,17 +628,27 @@ func (r *channelReactor) hasPermission(channelId string, channelType uint8, from
 	}
 
 	// 判断是否是订阅者
-	isSubscriber, err := r.s.store.ExistSubscriber(realFakeChannelId, channelType, fromUid)
+	member, err := r.s.store.GetSubscriber(realFakeChannelId, channelType, fromUid)
 	if err != nil {
-		r.Error("ExistSubscriber error", zap.Error(err))
+		if err == wkdb.ErrNotFound {
+			return wkproto.ReasonSubscriberNotExist, nil
+		}
+		r.Error("GetSubscriber error", zap.Error(err))
 		return wkproto.ReasonSystemError, err
 	}
-	if !isSubscriber {
-		return wkproto.ReasonSubscriberNotExist, nil
+
+	// 群主不受禁言和发言模式的限制
+	if member.Role != wkdb.MemberRoleOwner {
+		if member.IsMuted(time.Now()) { // 成员被禁言
+			return ReasonMemberMuted, nil
+		}
+		if channelInfo.SendMode == wkdb.SendModeAdmin && member.Role != wkdb.MemberRoleAdmin { // 只允许管理员发言
+			return ReasonOnlyAdminSend, nil
+		}
 	}
 
 	// 判断是否在白名单内
-	if !r.opts.WhitelistOffOfPerson || channelType != wkproto.ChannelTypePerson { // 如果不是个人频道或者个人频道白名单开关打开，则判断是否在白名单内
+	if !r.opts.Hot().WhitelistOffOfPerson || channelType != wkproto.ChannelTypePerson { // 如果不是个人频道或者个人频道白名单开关打开，则判断是否在白名单内
 		hasAllowlist, err := r.s.store.HasAllowlist(realFakeChannelId, channelType)
 		if err != nil {
 			r.Error("HasAllowlist error", zap.Error(err))
@@ -679,7 +716,7 @@ func (r *channelReactor) allowSend(from, to string) (wkproto.ReasonCode, error)
 		return wkproto.ReasonInBlacklist, nil
 	}
 
-	if !r.opts.WhitelistOffOfPerson {
+	if !r.opts.Hot().WhitelistOffOfPerson {
 		// 判断是否在白名单内
 		isAllowlist, err := r.s.store.ExistAllowlist(to, wkproto.ChannelTypePerson, from)
 		if err != nil {
@@ -873,7 +910,7 @@ func (r *channelReactor) processStorage(req *storageReq) {
 		}
 	}
 
-	if r.opts.WebhookOn() && reason == ReasonSuccess {
+	if r.opts.Hot().WebhookOn() && reason == ReasonSuccess {
 		// 赋值messageeq
 		for i, msg := range messages {
 			for _, cmsg := range req.messages {
@@ -885,11 +922,13 @@ func (r *channelReactor) processStorage(req *storageReq) {
 			}
 		}
 
-		// 将消息存储到webhook的推送队列内
-		err := r.s.store.AppendMessageOfNotifyQueue(messages)
-		if err != nil {
-			r.Error("AppendMessageOfNotifyQueue error", zap.Error(err))
-			reason = ReasonError
+		// 将消息存储到webhook的推送队列内（端到端加密频道的消息内容对服务端不透明，不推送给第三方做内容处理）
+		if !req.ch.info.E2ee {
+			err := r.s.store.AppendMessageOfNotifyQueue(messages)
+			if err != nil {
+				r.Error("AppendMessageOfNotifyQueue error", zap.Error(err))
+				reason = ReasonError
+			}
 		}
 	}
 	// 返回存储结果
@@ -1127,6 +1166,7 @@ func (r *channelReactor) processDelivers(reqs []*deliverReq) {
 
 func (r *channelReactor) processDeliver(req *deliverReq) {
 	lastIndex := req.messages[len(req.messages)-1].Index // 最后一条消息的index
+	reqMessages := req.messages
 
 	deliverMessages := make([]ReactorChannelMessage, 0, len(req.messages))
 	for _, msg := range req.messages {
@@ -1141,20 +1181,32 @@ func (r *channelReactor) processDeliver(req *deliverReq) {
 
 	req.messages = deliverMessages
 
+	// 分配流序号
+	if req.isStream && len(deliverMessages) > 0 {
+		r.allocStreamSeqs(req)
+	}
+
 	if len(deliverMessages) > 0 {
 		for _, deliverMessage := range deliverMessages {
 			r.MessageTrace("投递消息", deliverMessage.SendPacket.ClientMsgNo, "processDeliver", zap.Int("batchCount", len(deliverMessages)))
 		}
 		// 投递消息
 		r.handleDeliver(req)
+
+		// 存储流消息分片
+		if req.isStream && r.opts.Stream.StoreOn {
+			r.storeStreams(req)
+		}
 	}
 
 	sub := r.reactorSub(req.ch.key)
 	reason := ReasonSuccess
 
 	actionType := ChannelActionDeliverResp
+	var respMessages []ReactorChannelMessage
 	if req.isStream {
 		actionType = ChannelActionStreamDeliverResp
+		respMessages = reqMessages // 流消息需要根据消息找到对应的流
 	}
 
 	sub.step(req.ch, &ChannelAction{
@@ -1162,9 +1214,41 @@ func (r *channelReactor) processDeliver(req *deliverReq) {
 		ActionType: actionType,
 		Index:      lastIndex,
 		Reason:     reason,
+		Messages:   respMessages,
 	})
 }
 
+// 给流消息分配流内的序号（流序号需要在存储和投递前分配）
+func (r *channelReactor) allocStreamSeqs(req *deliverReq) {
+	for i, msg := range req.messages {
+		streamSeq, err := r.s.streamSeqs.next(msg.SendPacket.StreamNo)
+		if err != nil {
+			r.Error("alloc stream seq failed", zap.Error(err), zap.String("streamNo", msg.SendPacket.StreamNo), zap.String("channelId", req.channelId), zap.Uint8("channelType", req.channelType))
+			continue
+		}
+		req.messages[i].StreamSeq = streamSeq
+	}
+}
+
+// 存储流消息分片
+func (r *channelReactor) storeStreams(req *deliverReq) {
+	streams := make([]*wkdb.Stream, 0, len(req.messages))
+	for _, msg := range req.messages {
+		if msg.StreamSeq == 0 { // 没有分配到流序号
+			continue
+		}
+		streams = append(streams, &wkdb.Stream{
+			StreamNo: msg.SendPacket.StreamNo,
+			StreamId: msg.StreamSeq,
+			Payload:  msg.SendPacket.Payload,
+		})
+	}
+	err := r.s.store.AddStreams(req.channelId, req.channelType, streams)
+	if err != nil {
+		r.Error("store streams failed", zap.Error(err), zap.String("channelId", req.channelId), zap.Uint8("channelType", req.channelType), zap.Int("count", len(streams)))
+	}
+}
+
 func (r *channelReactor) handleDeliver(req *deliverReq) {
 	r.s.deliverManager.deliver(req)
 }
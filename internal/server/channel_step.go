@@ -21,6 +21,9 @@ func (c *channel) step(a *ChannelAction) error {
 		if a.Reason == ReasonSuccess {
 			c.initTick = c.opts.Reactor.Channel.ProcessIntervalTick // 立即处理下个逻辑
 			c.status = channelStatusInitialized
+			if a.ChannelInfo != nil {
+				c.info = *a.ChannelInfo
+			}
 			if a.LeaderId == c.r.opts.Cluster.NodeId {
 				c.becomeLeader()
 			} else {
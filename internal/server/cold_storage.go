@@ -0,0 +1,180 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+)
+
+const (
+	ColdStorageTypeLocal = "local" // 本地目录
+	ColdStorageTypeS3    = "s3"    // S3兼容的对象存储
+)
+
+// S3Config S3兼容的对象存储配置（aws s3、minio、阿里云oss等）
+type S3Config struct {
+	Endpoint  string // 服务地址 为空表示aws s3
+	Region    string // 区域
+	Bucket    string // 存储桶
+	Prefix    string // 对象key的前缀
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 是否使用path风格的地址（minio等自建服务一般需要开启）
+}
+
+// 根据配置创建冷存储
+func newColdBlobStore(opts *Options) (wkdb.BlobStore, error) {
+	switch strings.ToLower(strings.TrimSpace(opts.ColdStorage.Type)) {
+	case "", ColdStorageTypeLocal:
+		return wkdb.NewLocalBlobStore(opts.ColdStorage.Dir), nil
+	case ColdStorageTypeS3:
+		cfg := opts.ColdStorage.S3
+		if strings.TrimSpace(cfg.Bucket) == "" {
+			return nil, fmt.Errorf("coldStorage.s3.bucket is required")
+		}
+		client, err := wkdb.NewAwsS3Client(wkdb.S3Options{
+			Endpoint:  cfg.Endpoint,
+			Region:    cfg.Region,
+			AccessKey: cfg.AccessKey,
+			SecretKey: cfg.SecretKey,
+			PathStyle: cfg.PathStyle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return wkdb.NewS3BlobStore(client, cfg.Bucket, cfg.Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown coldStorage.type: %s", opts.ColdStorage.Type)
+	}
+}
+
+// 冷存储
+//
+// 每个节点定时检查本节点上的频道，按时间（OffloadAge）和本地保留数量（KeepCount）计算出需要转存到的消息seq，
+// 将频道较早的消息打包成消息段转存到冷存储。转存只影响本节点的存储，不需要和其他副本协调，
+// 加载消息时冷存储的消息会被透明获取，所以副本之间转存的进度不同不影响消息的读取和同步。
+type coldStorageManager struct {
+	s       *Server
+	stopper *syncutil.Stopper
+	wklog.Log
+}
+
+func newColdStorageManager(s *Server) *coldStorageManager {
+	return &coldStorageManager{
+		s:       s,
+		stopper: syncutil.NewStopper(),
+		Log:     wklog.NewWKLog("coldStorageManager"),
+	}
+}
+
+func (c *coldStorageManager) start() error {
+	if !c.s.opts.ColdStorage.On {
+		return nil
+	}
+	c.stopper.RunWorker(c.loop)
+	return nil
+}
+
+func (c *coldStorageManager) stop() {
+	c.stopper.Stop()
+}
+
+func (c *coldStorageManager) loop() {
+	tk := time.NewTicker(c.s.opts.ColdStorage.CheckInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			c.check()
+		case <-c.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+// 检查本节点上所有频道需要转存的消息
+func (c *coldStorageManager) check() {
+	var channels []retentionChannel
+	err := c.s.store.IterateMessageChannels(func(channelId string, channelType uint8, lastMsgSeq uint64) bool {
+		channels = append(channels, retentionChannel{
+			channelId:   channelId,
+			channelType: channelType,
+			lastMsgSeq:  lastMsgSeq,
+		})
+		return true
+	})
+	if err != nil {
+		c.Error("IterateMessageChannels failed", zap.Error(err))
+		return
+	}
+
+	for _, ch := range channels {
+		select {
+		case <-c.stopper.ShouldStop():
+			return
+		default:
+		}
+		if err := c.checkChannel(ch); err != nil {
+			c.Warn("offload channel messages failed", zap.Error(err), zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType))
+		}
+	}
+}
+
+func (c *coldStorageManager) checkChannel(ch retentionChannel) error {
+	offloadSeq, err := c.offloadSeqOf(ch)
+	if err != nil {
+		return err
+	}
+	if offloadSeq == 0 {
+		return nil
+	}
+	offloadedSeq, err := c.s.store.OffloadMessagesTo(ch.channelId, ch.channelType, offloadSeq)
+	if err != nil {
+		return err
+	}
+	if offloadedSeq > 0 {
+		c.Debug("offload messages", zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType), zap.Uint64("offloadedSeq", offloadedSeq))
+	}
+	return nil
+}
+
+// 计算频道需要转存到的消息seq（包含），不需要转存返回0
+func (c *coldStorageManager) offloadSeqOf(ch retentionChannel) (uint64, error) {
+	return coldOffloadSeq(c.s.opts, ch.lastMsgSeq, func(timestamp int64) (uint64, error) {
+		return c.s.store.GetMessageSeqBeforeTimestamp(ch.channelId, ch.channelType, timestamp)
+	})
+}
+
+// 按时间得到需要转存的消息seq，再用本地保留的数量限制（本地至少保留KeepCount条最新的消息）
+func coldOffloadSeq(opts *Options, lastMsgSeq uint64, seqBeforeTimestamp func(timestamp int64) (uint64, error)) (uint64, error) {
+	var (
+		offloadSeq uint64
+		keepSeq    uint64 // 按保留数量最多转存到的seq
+		keepCount  = opts.ColdStorage.KeepCount
+	)
+	if keepCount > 0 {
+		if lastMsgSeq <= uint64(keepCount) {
+			return 0, nil
+		}
+		keepSeq = lastMsgSeq - uint64(keepCount)
+	}
+
+	if opts.ColdStorage.OffloadAge > 0 {
+		seq, err := seqBeforeTimestamp(time.Now().Add(-opts.ColdStorage.OffloadAge).Unix())
+		if err != nil {
+			return 0, err
+		}
+		offloadSeq = seq
+		if keepCount > 0 && offloadSeq > keepSeq {
+			offloadSeq = keepSeq
+		}
+	} else {
+		offloadSeq = keepSeq
+	}
+	return offloadSeq, nil
+}
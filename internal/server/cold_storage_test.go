@@ -0,0 +1,40 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColdOffloadSeq(t *testing.T) {
+	opts := NewOptions()
+	ageSeq := uint64(800)
+	seqBeforeTimestamp := func(timestamp int64) (uint64, error) {
+		return ageSeq, nil
+	}
+
+	// 只按时间转存
+	opts.ColdStorage.OffloadAge = time.Hour
+	opts.ColdStorage.KeepCount = 0
+	seq, err := coldOffloadSeq(opts, 1000, seqBeforeTimestamp)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(800), seq)
+
+	// 本地至少保留的数量限制了按时间转存的位置
+	opts.ColdStorage.KeepCount = 500
+	seq, err = coldOffloadSeq(opts, 1000, seqBeforeTimestamp)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(500), seq)
+
+	// 消息数量不超过保留数量
+	seq, err = coldOffloadSeq(opts, 300, seqBeforeTimestamp)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), seq)
+
+	// 只按数量转存
+	opts.ColdStorage.OffloadAge = 0
+	seq, err = coldOffloadSeq(opts, 1000, seqBeforeTimestamp)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(500), seq)
+}
@@ -9,6 +9,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 	"github.com/sendgrid/rest"
 	"go.uber.org/zap"
 )
@@ -20,8 +21,44 @@ const (
 	ClusterMsgTypeNodePing ClusterMsgType = 1001
 	// 节点Pong
 	ClusterMsgTypeNodePong ClusterMsgType = 1002
+	// 用户在线状态变化
+	ClusterMsgTypePresence ClusterMsgType = 1003
+	// 临时信号转发给频道领导节点
+	ClusterMsgTypeEphemeralForward ClusterMsgType = 1004
+	// 临时信号投递给接收者所在节点
+	ClusterMsgTypeEphemeralDeliver ClusterMsgType = 1005
 )
 
+// 服务端扩展的原因码（从100开始，避免与wkproto内置的原因码冲突）
+const (
+	// ReasonTokenExpired token已过期
+	ReasonTokenExpired wkproto.ReasonCode = 100 + iota
+	// ReasonMemberMuted 发送者在频道内被禁言
+	ReasonMemberMuted
+	// ReasonOnlyAdminSend 频道只允许群主和管理员发言
+	ReasonOnlyAdminSend
+	// ReasonE2eeRequired 频道开启了端到端加密，只接受客户端加密的消息
+	ReasonE2eeRequired
+)
+
+// 设备token的授权范围（token没有设置授权范围时拥有所有权限）
+const (
+	// TokenScopeConnect 建立长连接
+	TokenScopeConnect = "connect"
+	// TokenScopeMessageSend 通过长连接发送消息（包括临时信号）
+	TokenScopeMessageSend = "message:send"
+)
+
+// 检查设备token的授权范围是否合法
+func checkTokenScopes(scopes []string) error {
+	for _, scope := range scopes {
+		if scope != TokenScopeConnect && scope != TokenScopeMessageSend {
+			return fmt.Errorf("scope[%s] is invalid", scope)
+		}
+	}
+	return nil
+}
+
 type channelRole int
 
 const (
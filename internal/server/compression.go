@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// 负载压缩
+//
+// 客户端在CONNECT包的clientKey之后追加扩展字段（string：按优先级逗号分隔的压缩算法，例如 "zstd,snappy"）请求负载压缩，
+// 老版本服务端解码时会忽略多出的字节。服务端选中算法后在CONNACK包尾追加扩展字段（string：选中的压缩算法）告知客户端，
+// 没有选中或客户端没有请求时CONNACK保持原样。
+// 协商成功后，负载达到阈值的RecvPacket会先压缩再加密，并在Setting中标记SettingPayloadCompressed，客户端解密后需要再解压。
+
+// SettingPayloadCompressed RecvPacket的负载已压缩（使用连接协商的压缩算法）
+const SettingPayloadCompressed wkproto.Setting = 1 << 1
+
+var errFrameHeaderIllegal = errors.New("frame header is illegal")
+
+// 解析包的固定头，返回固定头长度和剩余长度
+func decodeFrameHeader(data []byte) (int, int, error) {
+	if len(data) < 2 {
+		return 0, 0, errFrameHeaderIllegal
+	}
+	var remainingLength int
+	var multiplier uint
+	for i := 1; i < len(data) && i <= 4; i++ {
+		digit := data[i]
+		remainingLength |= int(digit&0x7f) << multiplier
+		if digit&0x80 == 0 {
+			return i + 1, remainingLength, nil
+		}
+		multiplier += 7
+	}
+	return 0, 0, errFrameHeaderIllegal
+}
+
+func encodeRemainingLength(length int) []byte {
+	ret := make([]byte, 0, 4)
+	for {
+		digit := byte(length % 0x80)
+		length /= 0x80
+		if length > 0 {
+			digit |= 0x80
+		}
+		ret = append(ret, digit)
+		if length == 0 {
+			break
+		}
+	}
+	return ret
+}
+
+// appendFrameExtension 在编码好的包尾追加扩展字段，并修正剩余长度
+func appendFrameExtension(frameData []byte, ext []byte) ([]byte, error) {
+	if len(ext) == 0 {
+		return frameData, nil
+	}
+	headerLen, remainingLength, err := decodeFrameHeader(frameData)
+	if err != nil {
+		return nil, err
+	}
+	if headerLen+remainingLength > len(frameData) {
+		return nil, errFrameHeaderIllegal
+	}
+	body := frameData[headerLen : headerLen+remainingLength]
+	newRemainingLength := encodeRemainingLength(remainingLength + len(ext))
+
+	result := make([]byte, 0, 1+len(newRemainingLength)+len(body)+len(ext))
+	result = append(result, frameData[0])
+	result = append(result, newRemainingLength...)
+	result = append(result, body...)
+	result = append(result, ext...)
+	return result, nil
+}
+
+// 获取包尾的扩展字段，baseBodySize为不含扩展字段时包体的大小
+func frameExtension(frameData []byte, baseBodySize int) []byte {
+	headerLen, remainingLength, err := decodeFrameHeader(frameData)
+	if err != nil {
+		return nil
+	}
+	if remainingLength <= baseBodySize || headerLen+remainingLength > len(frameData) {
+		return nil
+	}
+	return frameData[headerLen+baseBodySize : headerLen+remainingLength]
+}
+
+// 连接包不含扩展字段时包体的大小
+func connectBodySize(c *wkproto.ConnectPacket) int {
+	size := 0
+	size += wkproto.VersionByteSize
+	size += wkproto.DeviceFlagByteSize
+	size += len(c.DeviceID) + wkproto.StringFixLenByteSize
+	size += len(c.UID) + wkproto.StringFixLenByteSize
+	size += len(c.Token) + wkproto.StringFixLenByteSize
+	size += wkproto.ClientTimestampByteSize
+	size += len(c.ClientKey) + wkproto.StringFixLenByteSize
+	return size
+}
+
+// 编码连接包的扩展字段
+func encodeConnectExtension(compression string) []byte {
+	if compression == "" {
+		return nil
+	}
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(compression)
+	return enc.Bytes()
+}
+
+// 解码连接包的扩展字段，返回客户端请求的压缩算法
+func decodeConnectExtension(frameData []byte, connectPacket *wkproto.ConnectPacket) string {
+	ext := frameExtension(frameData, connectBodySize(connectPacket))
+	if len(ext) == 0 {
+		return ""
+	}
+	compression, err := wkproto.NewDecoder(ext).String()
+	if err != nil {
+		return ""
+	}
+	return compression
+}
+
+// 编码连接回执的扩展字段
+func encodeConnackExtension(compression string) []byte {
+	return encodeConnectExtension(compression)
+}
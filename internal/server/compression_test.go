@@ -0,0 +1,75 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectExtension(t *testing.T) {
+	connectPacket := &wkproto.ConnectPacket{
+		Version:         wkproto.LatestVersion,
+		ClientKey:       "clientKey",
+		DeviceID:        "device1",
+		DeviceFlag:      wkproto.APP,
+		ClientTimestamp: 1234,
+		UID:             "u1",
+		Token:           "token",
+	}
+	data, err := defaultWkproto.EncodeFrame(connectPacket, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, "", decodeConnectExtension(data, connectPacket))
+
+	// 追加扩展字段后，老的解码逻辑依然可以解码
+	data, err = appendFrameExtension(data, encodeConnectExtension("zstd,snappy"))
+	assert.NoError(t, err)
+	packet, size, err := defaultWkproto.DecodeFrame(data, wkproto.LatestVersion)
+	assert.NoError(t, err)
+	assert.Equal(t, len(data), size)
+	resultPacket := packet.(*wkproto.ConnectPacket)
+	assert.Equal(t, connectPacket.UID, resultPacket.UID)
+	assert.Equal(t, connectPacket.ClientKey, resultPacket.ClientKey)
+	assert.Equal(t, "zstd,snappy", decodeConnectExtension(data, resultPacket))
+
+	// 扩展字段随连接包在节点间转发
+	m := &ReactorUserMessage{
+		ConnId:      1,
+		InPacket:    connectPacket,
+		Compression: "snappy",
+	}
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	err = m.MarshalWithEncoder(enc)
+	assert.NoError(t, err)
+
+	m2 := &ReactorUserMessage{}
+	err = m2.UnmarshalWithDecoder(wkproto.NewDecoder(enc.Bytes()))
+	assert.NoError(t, err)
+	assert.Equal(t, "snappy", m2.Compression)
+	assert.Equal(t, connectPacket.UID, m2.InPacket.(*wkproto.ConnectPacket).UID)
+}
+
+func TestUserAuthResultCompression(t *testing.T) {
+	result := &UserAuthResult{
+		ReasonCode:   wkproto.ReasonSuccess,
+		Uid:          "u1",
+		ProtoVersion: wkproto.LatestVersion,
+		Compression:  wkutil.CompressionZstd,
+	}
+	data, err := result.Marshal()
+	assert.NoError(t, err)
+
+	result2 := &UserAuthResult{}
+	err = result2.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, wkutil.CompressionZstd, result2.Compression)
+
+	// 兼容老版本节点（没有compression和scopes字段）
+	result3 := &UserAuthResult{}
+	err = result3.Unmarshal(data[:len(data)-len(wkutil.CompressionZstd)-2-2])
+	assert.NoError(t, err)
+	assert.Equal(t, "", result3.Compression)
+	assert.Equal(t, "u1", result3.Uid)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/pkg/promtail"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.uber.org/zap"
+)
+
+// ConfigReloadResult 重新加载配置的结果
+type ConfigReloadResult struct {
+	Applied         []string `json:"applied"`          // 已生效的配置项
+	RestartRequired []string `json:"restart_required"` // 与启动时相比发生了变化，但需要重启才能生效的配置项
+}
+
+// 可以热更新（无需重启）的配置项
+type hotSetting struct {
+	key string                       // 配置文件中的key，同时匹配以 key. 开头的子配置项
+	get func(o *Options) interface{} // 获取配置值，用来判断是否发生变化
+	set func(dst, src *Options)      // 将src的配置值复制到dst（dst为还没有发布的配置快照）
+}
+
+var hotSettings = []hotSetting{
+	{
+		key: "logger.level",
+		get: func(o *Options) interface{} { return o.Logger.Level },
+		set: func(dst, src *Options) { dst.Logger.Level = src.Logger.Level },
+	},
+	{
+		key: "logger.loki",
+		get: func(o *Options) interface{} { return o.Logger.Loki },
+		set: func(dst, src *Options) { dst.Logger.Loki = src.Logger.Loki },
+	},
+	{
+		key: "webhook.httpAddr",
+		get: func(o *Options) interface{} { return o.Webhook.HTTPAddr },
+		set: func(dst, src *Options) { dst.Webhook.HTTPAddr = src.Webhook.HTTPAddr },
+	},
+	{
+		key: "webhook.grpcAddr",
+		get: func(o *Options) interface{} { return o.Webhook.GRPCAddr },
+		set: func(dst, src *Options) { dst.Webhook.GRPCAddr = src.Webhook.GRPCAddr },
+	},
+	{
+		key: "webhook.msgNotifyEventPushInterval",
+		get: func(o *Options) interface{} { return o.Webhook.MsgNotifyEventPushInterval },
+		set: func(dst, src *Options) {
+			dst.Webhook.MsgNotifyEventPushInterval = src.Webhook.MsgNotifyEventPushInterval
+		},
+	},
+	{
+		key: "webhook.msgNotifyEventCountPerPush",
+		get: func(o *Options) interface{} { return o.Webhook.MsgNotifyEventCountPerPush },
+		set: func(dst, src *Options) {
+			dst.Webhook.MsgNotifyEventCountPerPush = src.Webhook.MsgNotifyEventCountPerPush
+		},
+	},
+	{
+		key: "webhook.msgNotifyEventRetryMaxCount",
+		get: func(o *Options) interface{} { return o.Webhook.MsgNotifyEventRetryMaxCount },
+		set: func(dst, src *Options) {
+			dst.Webhook.MsgNotifyEventRetryMaxCount = src.Webhook.MsgNotifyEventRetryMaxCount
+		},
+	},
+	{
+		key: "tokenAuthOn",
+		get: func(o *Options) interface{} { return o.TokenAuthOn },
+		set: func(dst, src *Options) { dst.TokenAuthOn = src.TokenAuthOn },
+	},
+	{
+		key: "whitelistOffOfPerson",
+		get: func(o *Options) interface{} { return o.WhitelistOffOfPerson },
+		set: func(dst, src *Options) { dst.WhitelistOffOfPerson = src.WhitelistOffOfPerson },
+	},
+	{
+		key: "conversation.userMaxCount",
+		get: func(o *Options) interface{} { return o.Conversation.UserMaxCount },
+		set: func(dst, src *Options) { dst.Conversation.UserMaxCount = src.Conversation.UserMaxCount },
+	},
+	{
+		key: "conversation.cacheExpire",
+		get: func(o *Options) interface{} { return o.Conversation.CacheExpire },
+		set: func(dst, src *Options) { dst.Conversation.CacheExpire = src.Conversation.CacheExpire },
+	},
+	{
+		key: "ipAccess",
+		get: func(o *Options) interface{} { return o.IPAccess },
+		set: func(dst, src *Options) { dst.IPAccess = src.IPAccess },
+	},
+	{
+		key: "trace.prometheusApiUrl",
+		get: func(o *Options) interface{} { return o.Trace.PrometheusApiUrl },
+		set: func(dst, src *Options) { dst.Trace.PrometheusApiUrl = src.Trace.PrometheusApiUrl },
+	},
+}
+
+// 配置项是否可以热更新
+func isHotSettingKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, setting := range hotSettings {
+		hotKey := strings.ToLower(setting.key)
+		if key == hotKey || strings.HasPrefix(key, hotKey+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// ReloadConfig 重新读取配置文件，使可以热更新的配置立即生效，并返回需要重启才能生效的配置项
+func (s *Server) ReloadConfig() (*ConfigReloadResult, error) {
+	s.configReloadLock.Lock()
+	defer s.configReloadLock.Unlock()
+
+	newOpts, changedKeys, err := s.opts.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ConfigReloadResult{
+		Applied:         make([]string, 0),
+		RestartRequired: make([]string, 0),
+	}
+	for _, key := range changedKeys {
+		if !isHotSettingKey(key) {
+			result.RestartRequired = append(result.RestartRequired, key)
+		}
+	}
+
+	applied := make(map[string]bool)
+	appliedSettings := make([]hotSetting, 0)
+	current := s.opts.Hot()
+	for _, setting := range hotSettings {
+		if reflect.DeepEqual(setting.get(current), setting.get(newOpts)) {
+			continue
+		}
+		applied[setting.key] = true
+		appliedSettings = append(appliedSettings, setting)
+		result.Applied = append(result.Applied, setting.key)
+	}
+	// 新的配置整体作为快照发布，读取方通过Hot()获取，不会读到写了一半的配置
+	snapshot := s.hotSnapshot(newOpts, appliedSettings)
+	s.opts.hot.Store(snapshot)
+
+	if applied["logger.level"] {
+		wklog.SetLevel(snapshot.Logger.Level)
+	}
+
+	if applied["webhook.httpAddr"] || applied["webhook.grpcAddr"] || applied["webhook.msgNotifyEventPushInterval"] {
+		if err := s.webhook.reload(applied["webhook.grpcAddr"]); err != nil {
+			s.Error("reload webhook failed", zap.Error(err))
+			return result, err
+		}
+	}
+	if applied["ipAccess"] {
+		if err := s.ipAccess.loadFromOptions(); err != nil {
+			s.Error("reload ip access rules failed", zap.Error(err))
+			return result, err
+		}
+	}
+	if applied["logger.loki"] {
+		if err := s.reloadLoki(); err != nil {
+			s.Error("reload loki failed", zap.Error(err))
+			return result, err
+		}
+	}
+	if applied["trace.prometheusApiUrl"] {
+		s.trace.SetPrometheusApiUrl(snapshot.Trace.PrometheusApiUrl)
+	}
+
+	s.Info("config reloaded", zap.Strings("applied", result.Applied), zap.Strings("restartRequired", result.RestartRequired))
+	return result, nil
+}
+
+// ReloadIPAccess 只重新加载配置文件中的IP访问控制配置，其他热更新的配置保持不变
+func (s *Server) ReloadIPAccess() error {
+	s.configReloadLock.Lock()
+	defer s.configReloadLock.Unlock()
+
+	if s.opts.hasConfigFile() {
+		newOpts, _, err := s.opts.Reload()
+		if err != nil {
+			return err
+		}
+		for _, setting := range hotSettings {
+			if setting.key == "ipAccess" {
+				s.opts.hot.Store(s.hotSnapshot(newOpts, []hotSetting{setting}))
+				break
+			}
+		}
+	}
+	return s.ipAccess.loadFromOptions()
+}
+
+// 以当前生效的配置为基础，只将src中指定的热更新配置项复制过来得到新的配置快照，需要重启才能生效的配置保持不变
+func (s *Server) hotSnapshot(src *Options, settings []hotSetting) *Options {
+	snapshot := *s.opts.Hot()
+	for _, setting := range settings {
+		setting.set(&snapshot, src)
+	}
+	return &snapshot
+}
+
+// 按新的loki配置重建日志收集
+func (s *Server) reloadLoki() (err error) {
+	defer func() {
+		if r := recover(); r != nil { // loki地址有误时会panic
+			err = fmt.Errorf("create promtail failed: %v", r)
+		}
+	}()
+	opts := s.opts.Hot()
+	s.clusterServer.SetLoki(opts.Logger.Loki.Url, opts.Logger.Loki.Job)
+	if s.promtailServer != nil {
+		s.promtailServer.Stop()
+		s.promtailServer = nil
+	}
+	if opts.LokiOn() {
+		s.promtailServer = promtail.New(&promtail.Options{
+			NodeId:  s.opts.Cluster.NodeId,
+			Url:     opts.Logger.Loki.Url,
+			LogDir:  s.opts.Logger.Dir,
+			Address: s.opts.External.APIUrl,
+			Job:     opts.Logger.Loki.Job,
+		})
+		return s.promtailServer.Start()
+	}
+	return nil
+}
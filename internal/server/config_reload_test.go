@@ -0,0 +1,162 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestOptionsReload(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "wk.yaml")
+	writeConfig := func(content string) {
+		err := os.WriteFile(cfgFile, []byte(content), 0o600)
+		assert.NoError(t, err)
+	}
+	writeConfig(`
+rootDir: ` + dir + `
+external:
+  ip: 127.0.0.1
+cluster:
+  nodeId: 1
+  slotCount: 64
+logger:
+  level: 2
+webhook:
+  httpAddr: http://127.0.0.1:6979/webhook
+`)
+	vp := viper.New()
+	vp.SetConfigFile(cfgFile)
+	err := vp.ReadInConfig()
+	assert.NoError(t, err)
+	opts := NewOptions()
+	opts.ConfigureWithViper(vp)
+	assert.Equal(t, zapcore.InfoLevel, opts.Logger.Level)
+
+	writeConfig(`
+rootDir: ` + dir + `
+external:
+  ip: 127.0.0.1
+cluster:
+  nodeId: 1
+  slotCount: 128
+logger:
+  level: 1
+webhook:
+  httpAddr: http://127.0.0.1:6980/webhook
+`)
+	newOpts, changedKeys, err := opts.Reload()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cluster.slotcount", "logger.level", "webhook.httpaddr"}, changedKeys)
+	assert.Equal(t, zapcore.DebugLevel, newOpts.Logger.Level)
+	assert.Equal(t, "http://127.0.0.1:6980/webhook", newOpts.Webhook.HTTPAddr)
+	assert.Equal(t, 128, newOpts.Cluster.SlotCount)
+
+	// 当前配置不会被修改
+	assert.Equal(t, "http://127.0.0.1:6979/webhook", opts.Webhook.HTTPAddr)
+
+	assert.False(t, isHotSettingKey("cluster.slotcount"))
+	assert.True(t, isHotSettingKey("logger.level"))
+	assert.True(t, isHotSettingKey("webhook.httpaddr"))
+	assert.True(t, isHotSettingKey("ipaccess.api.allow"))
+	assert.True(t, isHotSettingKey("logger.loki.url"))
+
+	// 配置有误时返回错误
+	writeConfig(`
+rootDir: ` + dir + `
+cluster:
+  nodeId: 1
+  role: unknown
+`)
+	_, _, err = opts.Reload()
+	assert.Error(t, err)
+}
+
+func TestReloadConfigHotSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "wk.yaml")
+	writeConfig := func(content string) {
+		err := os.WriteFile(cfgFile, []byte(content), 0o600)
+		assert.NoError(t, err)
+	}
+	writeConfig(`
+rootDir: ` + dir + `
+external:
+  ip: 127.0.0.1
+tokenAuthOn: false
+conversation:
+  userMaxCount: 100
+`)
+	vp := viper.New()
+	vp.SetConfigFile(cfgFile)
+	err := vp.ReadInConfig()
+	assert.NoError(t, err)
+	opts := NewOptions()
+	opts.ConfigureWithViper(vp)
+	s := &Server{opts: opts, Log: wklog.NewWKLog("test")}
+
+	// 重新加载的同时读取热更新的配置（go test -race）
+	stopC := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stopC:
+				return
+			default:
+				hot := s.opts.Hot()
+				_ = hot.TokenAuthOn
+				_ = hot.Conversation.UserMaxCount
+			}
+		}
+	}()
+
+	writeConfig(`
+rootDir: ` + dir + `
+external:
+  ip: 127.0.0.1
+tokenAuthOn: true
+conversation:
+  userMaxCount: 200
+httpAddr: 0.0.0.0:6001
+userMsgQueueMaxSize: 100
+`)
+	for i := 0; i < 10; i++ {
+		result, err := s.ReloadConfig()
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"httpaddr", "usermsgqueuemaxsize"}, result.RestartRequired)
+	}
+	close(stopC)
+	wg.Wait()
+
+	assert.True(t, s.opts.Hot().TokenAuthOn)
+	assert.Equal(t, 200, s.opts.Hot().Conversation.UserMaxCount)
+	// 需要重启才能生效的配置不会进入快照
+	assert.Equal(t, opts.HTTPAddr, s.opts.Hot().HTTPAddr)
+	assert.Equal(t, 0, s.opts.Hot().UserMsgQueueMaxSize)
+	// 当前配置不会被修改
+	assert.False(t, s.opts.TokenAuthOn)
+	assert.Equal(t, 100, s.opts.Conversation.UserMaxCount)
+
+	// 只重新加载ip访问控制时其他热更新的配置保持不变
+	writeConfig(`
+rootDir: ` + dir + `
+external:
+  ip: 127.0.0.1
+tokenAuthOn: false
+httpAddr: 0.0.0.0:6001
+`)
+	s.ipAccess = newIPAccessManager(s)
+	assert.NoError(t, s.ReloadIPAccess())
+	assert.True(t, s.opts.Hot().TokenAuthOn)
+	assert.Equal(t, 200, s.opts.Hot().Conversation.UserMaxCount)
+	assert.Equal(t, opts.HTTPAddr, s.opts.Hot().HTTPAddr)
+}
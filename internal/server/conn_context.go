@@ -38,6 +38,8 @@ type connInfo struct {
 	aesKey       []byte
 	aesIV        []byte
 	protoVersion uint8
+	compression  string   // 协商的负载压缩算法，为空表示不压缩
+	scopes       []string // 连接所用token的授权范围，为空表示不限制
 
 	closed atomic.Bool
 
@@ -58,6 +60,8 @@ type connContext struct {
 
 	lastActivity atomic.Int64 // 最后活动时间
 
+	away atomic.Bool // 是否处于离开状态（客户端通过SUB包设置，用于聚合用户的在线状态）
+
 	wklog.Log
 }
 
@@ -115,7 +119,8 @@ func (c *connContext) addOtherPacket(packet wkproto.Frame) {
 	})
 }
 
-func (c *connContext) addConnectPacket(packet *wkproto.ConnectPacket) {
+// compression 客户端在连接包扩展字段中请求的压缩算法
+func (c *connContext) addConnectPacket(packet *wkproto.ConnectPacket, compression string) {
 	// 保持活动
 	c.keepActivity()
 
@@ -131,10 +136,11 @@ func (c *connContext) addConnectPacket(packet *wkproto.ConnectPacket) {
 	// 预先分配一个长度为1的切片，避免在创建UserAction时动态分配内存
 	messages := make([]ReactorUserMessage, 1)
 	messages[0] = ReactorUserMessage{
-		ConnId:     c.connId,
-		DeviceId:   c.deviceId,
-		InPacket:   packet,
-		FromNodeId: c.subReactor.r.s.opts.Cluster.NodeId,
+		ConnId:      c.connId,
+		DeviceId:    c.deviceId,
+		InPacket:    packet,
+		FromNodeId:  c.subReactor.r.s.opts.Cluster.NodeId,
+		Compression: compression,
 	}
 	err := c.subReactor.stepNoWait(c.uid, UserAction{
 		ActionType: UserActionConnect,
@@ -180,11 +186,38 @@ func (c *connContext) addSendPacket(packet *wkproto.SendPacket) {
 		return
 	}
 
+	// token没有发送消息的授权
+	if !c.hasScope(TokenScopeMessageSend) {
+		c.Warn("addSendPacket failed, token has no message:send scope", zap.String("uid", c.uid), zap.String("deviceId", c.deviceId))
+		sendack := &wkproto.SendackPacket{
+			Framer:      packet.Framer,
+			MessageID:   messageId,
+			ClientSeq:   packet.ClientSeq,
+			ClientMsgNo: packet.ClientMsgNo,
+			ReasonCode:  wkproto.ReasonNotAllowSend,
+		}
+		_ = c.writeDirectlyPacket(sendack)
+		return
+	}
+
 	// 提案发送至频道
 	_ = c.subReactor.proposeSend(c, messageId, packet)
 
 }
 
+// hasScope 连接所用的token是否拥有指定的授权范围（未设置授权范围的token拥有所有权限）
+func (c *connContext) hasScope(scope string) bool {
+	if len(c.scopes) == 0 {
+		return true
+	}
+	for _, s := range c.scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *connContext) writePacket(packet wkproto.Frame) error {
 	data, err := c.subReactor.r.s.opts.Proto.EncodeFrame(packet, c.protoVersion)
 	if err != nil {
@@ -226,6 +259,18 @@ func (c *connContext) writeDirectlyPacket(packet wkproto.Frame) error {
 	return c.writeDirectly(data, count)
 }
 
+// 编码连接回执，协商了负载压缩时在包尾追加扩展字段告知客户端
+func (c *connContext) encodeConnack(packet *wkproto.ConnackPacket) ([]byte, error) {
+	data, err := c.subReactor.r.s.opts.Proto.EncodeFrame(packet, c.protoVersion)
+	if err != nil {
+		return nil, err
+	}
+	if packet.ReasonCode == wkproto.ReasonSuccess && c.compression != "" {
+		return appendFrameExtension(data, encodeConnackExtension(c.compression))
+	}
+	return data, nil
+}
+
 // 直接写入连接
 func (c *connContext) writeDirectly(data []byte, recvFrameCount uint32) error {
 
@@ -245,7 +290,24 @@ func (c *connContext) writeDirectly(data []byte, recvFrameCount uint32) error {
 	}
 	conn := c.conn
 	wsConn, wsok := conn.(wknet.IWSConn) // websocket连接
-	if wsok {
+	// json文本协议，将二进制包转换为json后写入
+	if wsok && wsConn.JSONRPC() {
+		state := getJSONRPCState(conn)
+		if state == nil {
+			c.Warn("writeDirectly failed, jsonrpc state is nil", zap.String("conn", c.String()))
+			return errors.New("writeDirectly failed, jsonrpc state is nil")
+		}
+		msgs, err := c.framesToJSONRPC(data, state)
+		if err != nil {
+			c.Warn("Failed to convert the message to jsonrpc", zap.Error(err))
+			return err
+		}
+		for _, msg := range msgs {
+			if err = wsConn.WriteServerText(msg); err != nil {
+				c.Warn("Failed to write the message", zap.Error(err))
+			}
+		}
+	} else if wsok {
 		err := wsConn.WriteServerBinary(data)
 		if err != nil {
 			c.Warn("Failed to write the message", zap.Error(err))
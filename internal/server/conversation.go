@@ -433,7 +433,7 @@ func (c *conversationWorker) cleanUpdate() {
 	for i := 0; i < len(c.updates); {
 
 		udpate := c.updates[i]
-		if !udpate.isUpdateAll() && len(udpate.users) == 0 && time.Since(udpate.activeTime) > c.s.opts.Conversation.CacheExpire {
+		if !udpate.isUpdateAll() && len(udpate.users) == 0 && time.Since(udpate.activeTime) > c.s.opts.Hot().Conversation.CacheExpire {
 			c.updates = append(c.updates[:i], c.updates[i+1:]...)
 		} else {
 			i++
@@ -426,6 +426,36 @@ func (d *deliverr) deliver(req *deliverReq, uids []string) {
 		}
 	}
 
+	// 缓存流消息分片，用于给重连的订阅者补发（其他节点转发过来的投递请求没有isStream标记，需要按消息判断）
+	for _, msg := range req.messages {
+		if msg.SendPacket.StreamNo != "" {
+			d.dm.s.streamCatchUp.add(req, uids)
+			break
+		}
+	}
+
+	d.deliverToConns(req, slices.toConns)
+
+	if len(slices.offlineUids) > 0 { // 有离线用户，发送webhook
+		for _, message := range req.messages {
+			d.dm.s.webhook.notifyOfflineMsg(message, slices.offlineUids)
+		}
+	}
+
+	if d.dm.s.opts.Logger.TraceOn {
+		for _, msg := range req.messages {
+			d.MessageTrace("投递消息完成", msg.SendPacket.ClientMsgNo, "deliverMessageFinished")
+		}
+	}
+
+}
+
+// 投递消息给指定的连接
+func (d *deliverr) deliverToConns(req *deliverReq, toConns []*connContext) {
+	if len(toConns) == 0 {
+		return
+	}
+
 	// payload内容pool
 	payloadBuffer := bytebufferpool.Get()
 	defer bytebufferpool.Put(payloadBuffer)
@@ -442,6 +472,10 @@ func (d *deliverr) deliver(req *deliverReq, uids []string) {
 	aesResultBuffer := bytebufferpool.Get()
 	defer bytebufferpool.Put(aesResultBuffer)
 
+	// 压缩buffer
+	compressBuffer := bytebufferpool.Get()
+	defer bytebufferpool.Put(compressBuffer)
+
 	// 接受包
 	recvPacketBuffer := bytebufferpool.Get()
 	defer bytebufferpool.Put(recvPacketBuffer)
@@ -451,7 +485,7 @@ func (d *deliverr) deliver(req *deliverReq, uids []string) {
 
 	var err error
 
-	for _, conn := range slices.toConns {
+	for _, conn := range toConns {
 		for _, message := range req.messages {
 
 			if conn.uid == message.FromUid && conn.deviceId == message.FromDeviceId { // 自己发的不处理
@@ -475,11 +509,15 @@ func (d *deliverr) deliver(req *deliverReq, uids []string) {
 				NoPersist: sendPacket.GetNoPersist(),
 			}
 			recvPacket.Setting = sendPacket.Setting
+			recvPacket.Setting.Clear(SettingPayloadCompressed)
 			recvPacket.MessageID = message.MessageId
 			recvPacket.MessageSeq = message.MessageSeq
 			recvPacket.ClientMsgNo = sendPacket.ClientMsgNo
 			recvPacket.StreamNo = sendPacket.StreamNo
 			recvPacket.StreamFlag = wkproto.StreamFlagIng
+			if sendPacket.StreamNo != "" {
+				recvPacket.StreamSeq = uint32(message.StreamSeq)
+			}
 			recvPacket.FromUID = fromUid
 			recvPacket.Expire = sendPacket.Expire
 			recvPacket.ChannelID = sendPacket.ChannelID
@@ -498,6 +536,18 @@ func (d *deliverr) deliver(req *deliverReq, uids []string) {
 				recvPacket.RedDot = false
 			}
 
+			// payload压缩（需要在加密前压缩，加密后的内容无法压缩）
+			if conn.compression != "" && len(recvPacket.Payload) >= d.dm.s.opts.Compression.Threshold {
+				compressed, err := wkutil.Compress(conn.compression, compressBuffer.B[:0], recvPacket.Payload)
+				if err != nil {
+					d.Warn("压缩payload失败！", zap.String("compression", conn.compression), zap.Error(err))
+				} else if len(compressed) < len(recvPacket.Payload) { // 压缩后变小才使用
+					compressBuffer.B = compressed
+					recvPacket.Payload = compressed
+					recvPacket.Setting.Set(SettingPayloadCompressed)
+				}
+			}
+
 			// payload内容加密
 			payloadBuffer.Reset()
 			err = encryptMessagePayload(recvPacket.Payload, conn, payloadBuffer)
@@ -561,19 +611,6 @@ func (d *deliverr) deliver(req *deliverReq, uids []string) {
 			}
 		}
 	}
-
-	if len(slices.offlineUids) > 0 { // 有离线用户，发送webhook
-		for _, message := range req.messages {
-			d.dm.s.webhook.notifyOfflineMsg(message, slices.offlineUids)
-		}
-	}
-
-	if d.dm.s.opts.Logger.TraceOn {
-		for _, msg := range req.messages {
-			d.MessageTrace("投递消息完成", msg.SendPacket.ClientMsgNo, "deliverMessageFinished")
-		}
-	}
-
 }
 
 func (d *deliverr) releaseRecvPacket(recvPacket *wkproto.RecvPacket) {
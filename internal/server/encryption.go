@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// NewEncryptFS 根据配置创建静态数据加密的文件系统（密钥文件不存在时自动生成）
+func NewEncryptFS(opts *Options) (*encryption.FS, *encryption.FileKeyProvider, error) {
+	var kms encryption.KMSClient
+	if strings.TrimSpace(opts.Encryption.MasterKey) != "" {
+		masterKey, err := encryption.ParseMasterKey(strings.TrimSpace(opts.Encryption.MasterKey))
+		if err != nil {
+			return nil, nil, err
+		}
+		kms, err = encryption.NewLocalKMS(masterKey)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	// 明文密钥和加密的数据在同一个目录下，拿到数据目录就能解密，相当于没有加密
+	if kms == nil && isSubPath(opts.DataDir, opts.Encryption.KeyFile) {
+		return nil, nil, fmt.Errorf("encryption key file[%s] is stored in plaintext inside the data dir[%s], please set encryption.masterKey or move encryption.keyFile out of the data dir", opts.Encryption.KeyFile, opts.DataDir)
+	}
+	provider, err := encryption.NewFileKeyProvider(opts.Encryption.KeyFile, kms)
+	if err != nil {
+		return nil, nil, err
+	}
+	return encryption.NewFS(vfs.Default, provider), provider, nil
+}
+
+// EncryptedDataDirs 需要加密的数据目录（消息、用户、频道等数据库以及分布式数据）
+func EncryptedDataDirs(opts *Options) []string {
+	return []string{
+		path.Join(opts.DataDir, "db"),
+		path.Join(opts.DataDir, "cluster"),
+	}
+}
+
+// RemoveEncryptionKey 删除旧密钥，数据目录下还有文件使用此密钥时拒绝删除（需要先执行EncryptDataDirs重新加密）
+func RemoveEncryptionKey(fs *encryption.FS, provider *encryption.FileKeyProvider, opts *Options, keyId string) error {
+	for _, dir := range EncryptedDataDirs(opts) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		files, err := fs.FilesWithKey(dir, keyId)
+		if err != nil {
+			return err
+		}
+		if len(files) > 0 {
+			return fmt.Errorf("key[%s] is still used by %d files (e.g. %s), please migrate first", keyId, len(files), files[0])
+		}
+	}
+	return provider.RemoveKey(keyId)
+}
+
+// dir下（包含dir本身）的路径
+func isSubPath(dir, name string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absName)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// EncryptDataDirs 重写数据目录下的明文文件以及不是当前密钥加密的文件，需要在打开数据库之前执行
+func EncryptDataDirs(fs *encryption.FS, opts *Options) (int, error) {
+	return rewriteDataDirs(opts, fs.EncryptDir)
+}
+
+// DecryptDataDirs 将数据目录下加密的文件还原为明文（关闭加密前执行）
+func DecryptDataDirs(fs *encryption.FS, opts *Options) (int, error) {
+	return rewriteDataDirs(opts, fs.DecryptDir)
+}
+
+func rewriteDataDirs(opts *Options, rewrite func(dir string) (int, error)) (int, error) {
+	total := 0
+	for _, dir := range EncryptedDataDirs(opts) {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		count, err := rewrite(dir)
+		total += count
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEncryptFSKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewOptions()
+	opts.DataDir = filepath.Join(dir, "data")
+	opts.Encryption.On = true
+
+	// 明文密钥在数据目录下
+	opts.Encryption.KeyFile = filepath.Join(opts.DataDir, "encryption.key")
+	_, _, err := NewEncryptFS(opts)
+	assert.Error(t, err)
+	_, err = os.Stat(opts.Encryption.KeyFile)
+	assert.True(t, os.IsNotExist(err))
+
+	// 配置了主密钥
+	opts.Encryption.MasterKey = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+	_, _, err = NewEncryptFS(opts)
+	assert.NoError(t, err)
+
+	// 密钥文件在数据目录之外
+	opts.Encryption.MasterKey = ""
+	opts.Encryption.KeyFile = filepath.Join(dir, "keys", "encryption.key")
+	_, _, err = NewEncryptFS(opts)
+	assert.NoError(t, err)
+}
+
+func TestRemoveEncryptionKey(t *testing.T) {
+	dir := t.TempDir()
+	opts := NewOptions()
+	opts.DataDir = filepath.Join(dir, "data")
+	opts.Encryption.On = true
+	opts.Encryption.KeyFile = filepath.Join(dir, "encryption.key")
+
+	fs, provider, err := NewEncryptFS(opts)
+	assert.NoError(t, err)
+	oldKey, err := provider.ActiveKey()
+	assert.NoError(t, err)
+
+	// 用旧密钥加密的文件
+	dbDir := EncryptedDataDirs(opts)[0]
+	assert.NoError(t, os.MkdirAll(dbDir, 0755))
+	f, err := fs.Create(filepath.Join(dbDir, "000001.log"))
+	assert.NoError(t, err)
+	_, err = f.Write([]byte("hello"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Close())
+
+	_, err = provider.Rotate()
+	assert.NoError(t, err)
+
+	// 还有文件使用旧密钥
+	err = RemoveEncryptionKey(fs, provider, opts, oldKey.Id)
+	assert.Error(t, err)
+	assert.Contains(t, provider.Keys(), oldKey.Id)
+
+	// 重新加密后可以删除
+	_, err = EncryptDataDirs(fs, opts)
+	assert.NoError(t, err)
+	assert.NoError(t, RemoveEncryptionKey(fs, provider, opts, oldKey.Id))
+	assert.NotContains(t, provider.Keys(), oldKey.Id)
+}
@@ -0,0 +1,441 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+)
+
+// 临时信号（正在输入、正在录音、正在查看）
+//
+// 客户端发送Setting带SettingEphemeral标记的SendPacket，payload为{"type":"typing|recording|viewing"}。
+// 临时信号不进入频道的消息处理流程（不存储、不计入最近会话、不推送webhook），
+// 发送者所在节点限速并按发送者+频道合并后转发给频道的领导节点，领导节点通过频道的接收者tag
+// 找到订阅者所在的节点，各节点只投递给在线的订阅者。
+// 接收者收到的RecvPacket同样带SettingEphemeral标记（NoPersist，没有红点）。
+
+// SettingEphemeral SendPacket/RecvPacket是临时信号
+const SettingEphemeral wkproto.Setting = 1 << 6
+
+// 处理临时信号路由的协程数量
+const ephemeralWorkerCount = 4
+
+type ephemeralManager struct {
+	s *Server
+
+	mu           sync.Mutex
+	states       map[string]*ephemeralState // 发送者+频道 -> 合并状态
+	senderCounts map[string]int             // 发送者在当前秒内发送的信号数量
+	countSecond  int64                      // senderCounts统计的秒
+
+	signalC chan *ephemeralSignal // 等待路由的信号（本节点发送者的、其他节点转发过来的）
+
+	stopper *syncutil.Stopper
+	wklog.Log
+}
+
+// 发送者在某个频道内的信号合并状态
+type ephemeralState struct {
+	last    []byte           // 最后转发的信号内容
+	lastAt  time.Time        // 最后转发的时间
+	pending *ephemeralSignal // 间隔内等待转发的信号（只保留最新的一个）
+}
+
+func newEphemeralManager(s *Server) *ephemeralManager {
+	return &ephemeralManager{
+		s:            s,
+		states:       make(map[string]*ephemeralState),
+		senderCounts: make(map[string]int),
+		signalC:      make(chan *ephemeralSignal, 1024),
+		stopper:      syncutil.NewStopper(),
+		Log:          wklog.NewWKLog("ephemeralManager"),
+	}
+}
+
+func (e *ephemeralManager) start() error {
+	e.stopper.RunWorker(e.flushLoop)
+	for i := 0; i < ephemeralWorkerCount; i++ {
+		e.stopper.RunWorker(e.signalLoop)
+	}
+	return nil
+}
+
+func (e *ephemeralManager) stop() {
+	e.stopper.Stop()
+}
+
+// ==================================== 接收（发送者连接所在节点） ====================================
+
+// handleSendPacket 处理客户端发送的临时信号，合并后异步转发，立即返回发送回执
+func (e *ephemeralManager) handleSendPacket(conn *connContext, packet *wkproto.SendPacket) {
+	conn.keepActivity()
+
+	reasonCode := e.accept(conn, packet)
+	if reasonCode != wkproto.ReasonSuccess {
+		e.Debug("ephemeral signal rejected", zap.String("uid", conn.uid), zap.String("channelId", packet.ChannelID), zap.Uint8("channelType", packet.ChannelType), zap.String("reasonCode", reasonCode.String()))
+	}
+	err := conn.writePacket(&wkproto.SendackPacket{
+		Framer:      packet.Framer,
+		ClientSeq:   packet.ClientSeq,
+		ClientMsgNo: packet.ClientMsgNo,
+		ReasonCode:  reasonCode,
+	})
+	if err != nil {
+		e.Warn("write sendack failed", zap.Error(err), zap.String("uid", conn.uid))
+	}
+}
+
+func (e *ephemeralManager) accept(conn *connContext, packet *wkproto.SendPacket) wkproto.ReasonCode {
+	if !e.s.opts.Ephemeral.On {
+		return wkproto.ReasonNotAllowSend
+	}
+	if strings.TrimSpace(packet.ChannelID) == "" || IsSpecialChar(packet.ChannelID) {
+		return wkproto.ReasonChannelIDError
+	}
+	if packet.ChannelType == wkproto.ChannelTypePerson && packet.ChannelID == conn.uid {
+		return wkproto.ReasonChannelIDError
+	}
+	if !conn.hasScope(TokenScopeMessageSend) {
+		return wkproto.ReasonNotAllowSend
+	}
+	if !e.allowRate(conn.uid, time.Now().Unix()) {
+		return wkproto.ReasonRateLimit
+	}
+
+	payload, err := e.s.checkAndDecodePayload(packet, conn)
+	if err != nil {
+		return wkproto.ReasonPayloadDecodeError
+	}
+	if len(payload) == 0 || len(payload) > e.s.opts.Ephemeral.MaxPayloadSize {
+		return wkproto.ReasonPayloadDecodeError
+	}
+	var ephemeralPayload EphemeralPayload
+	if err := json.Unmarshal(payload, &ephemeralPayload); err != nil {
+		return wkproto.ReasonPayloadDecodeError
+	}
+	if err := ephemeralPayload.Check(); err != nil {
+		return wkproto.ReasonPayloadDecodeError
+	}
+
+	e.offer(&ephemeralSignal{
+		fromUid:      conn.uid,
+		fromDeviceId: conn.deviceId,
+		channelId:    packet.ChannelID,
+		channelType:  packet.ChannelType,
+		payload:      payload,
+	})
+	return wkproto.ReasonSuccess
+}
+
+// allowRate 发送者在指定的秒内是否还可以发送信号
+func (e *ephemeralManager) allowRate(uid string, second int64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if second != e.countSecond {
+		e.countSecond = second
+		e.senderCounts = make(map[string]int)
+	}
+	if e.senderCounts[uid] >= e.s.opts.Ephemeral.MaxPerSecond {
+		return false
+	}
+	e.senderCounts[uid]++
+	return true
+}
+
+// offer 合并信号：同一发送者在同一频道内的信号，间隔内只转发最新的一个，相同的信号在重复间隔内不再转发
+func (e *ephemeralManager) offer(signal *ephemeralSignal) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	key := fmt.Sprintf("%s@%s", signal.fromUid, wkutil.ChannelToKey(signal.channelId, signal.channelType))
+	state := e.states[key]
+	if state == nil {
+		state = &ephemeralState{}
+		e.states[key] = state
+	}
+	if state.pending != nil { // 已经有等待转发的信号，替换为最新的
+		state.pending = signal
+		return
+	}
+	now := time.Now()
+	if now.Sub(state.lastAt) < e.s.opts.Ephemeral.Interval {
+		state.pending = signal
+		return
+	}
+	e.forward(state, signal, now)
+}
+
+// forward 转发信号（需要在锁内调用）
+func (e *ephemeralManager) forward(state *ephemeralState, signal *ephemeralSignal, now time.Time) {
+	if now.Sub(state.lastAt) < e.s.opts.Ephemeral.RepeatInterval && string(state.last) == string(signal.payload) {
+		return
+	}
+	state.last = signal.payload
+	state.lastAt = now
+	e.addSignal(signal)
+}
+
+// flushLoop 定时转发间隔已到的信号，并清理不活跃的合并状态
+func (e *ephemeralManager) flushLoop() {
+	tick := e.s.opts.Ephemeral.Interval / 2
+	if tick < time.Millisecond*50 {
+		tick = time.Millisecond * 50
+	}
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			e.flush()
+		case <-e.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+func (e *ephemeralManager) flush() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	for key, state := range e.states {
+		if state.pending == nil {
+			if now.Sub(state.lastAt) >= e.s.opts.Ephemeral.RepeatInterval {
+				delete(e.states, key)
+			}
+			continue
+		}
+		if now.Sub(state.lastAt) < e.s.opts.Ephemeral.Interval {
+			continue
+		}
+		signal := state.pending
+		state.pending = nil
+		e.forward(state, signal, now)
+	}
+}
+
+// ==================================== 路由 ====================================
+
+func (e *ephemeralManager) addSignal(signal *ephemeralSignal) {
+	select {
+	case e.signalC <- signal:
+	default:
+		e.Warn("signalC is full, ignore", zap.String("fromUid", signal.fromUid), zap.String("channelId", signal.channelId), zap.Uint8("channelType", signal.channelType))
+	}
+}
+
+func (e *ephemeralManager) signalLoop() {
+	for {
+		select {
+		case signal := <-e.signalC:
+			e.handleSignal(signal)
+		case <-e.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+func (e *ephemeralManager) handleSignal(signal *ephemeralSignal) {
+	if signal.tagKey != "" { // 领导节点转发过来的，投递给本节点的接收者
+		e.deliverByTag(signal)
+		return
+	}
+
+	var (
+		leaderId uint64
+		err      error
+	)
+	if signal.channelType == wkproto.ChannelTypePerson { // 个人频道由接收者的领导节点投递（接收者的连接都在领导节点上）
+		leaderId, err = e.s.cluster.SlotLeaderIdOfChannel(signal.channelId, wkproto.ChannelTypePerson)
+	} else {
+		leader, leaderErr := e.s.cluster.LeaderOfChannelForRead(signal.channelId, signal.channelType)
+		if leader != nil {
+			leaderId = leader.Id
+		}
+		err = leaderErr
+	}
+	if err != nil {
+		e.Warn("get channel leader failed", zap.Error(err), zap.String("channelId", signal.channelId), zap.Uint8("channelType", signal.channelType))
+		return
+	}
+	if leaderId == 0 {
+		e.Warn("channel leader not found", zap.String("channelId", signal.channelId), zap.Uint8("channelType", signal.channelType))
+		return
+	}
+
+	if leaderId != e.s.opts.Cluster.NodeId {
+		err = e.s.cluster.Send(leaderId, &proto.Message{
+			MsgType: uint32(ClusterMsgTypeEphemeralForward),
+			Content: signal.Marshal(),
+		})
+		if err != nil {
+			e.Warn("forward ephemeral signal failed", zap.Error(err), zap.Uint64("leaderId", leaderId), zap.String("channelId", signal.channelId))
+		}
+		return
+	}
+
+	if signal.channelType == wkproto.ChannelTypePerson {
+		e.deliverPerson(signal)
+	} else {
+		e.deliverChannel(signal)
+	}
+}
+
+// deliverPerson 个人频道（本节点是接收者的领导节点）
+func (e *ephemeralManager) deliverPerson(signal *ephemeralSignal) {
+	if !e.hasPermission(signal, GetFakeChannelIDWith(signal.fromUid, signal.channelId), wkdb.ChannelInfo{}) {
+		return
+	}
+	e.deliverLocal(signal, []string{signal.channelId})
+}
+
+// deliverChannel 非个人频道（本节点是频道的领导节点），按频道的接收者tag投递到订阅者所在的节点
+func (e *ephemeralManager) deliverChannel(signal *ephemeralSignal) {
+	// 与普通消息相同的发送权限（订阅者、黑名单、禁言、只允许管理员发言等）
+	realChannelId := signal.channelId
+	if e.s.opts.IsCmdChannel(realChannelId) {
+		realChannelId = e.s.opts.CmdChannelConvertOrginalChannel(realChannelId)
+	}
+	info, err := e.s.store.GetChannel(realChannelId, signal.channelType)
+	if err != nil && err != wkdb.ErrNotFound {
+		e.Warn("GetChannel failed", zap.Error(err), zap.String("channelId", signal.channelId), zap.Uint8("channelType", signal.channelType))
+		return
+	}
+	if !e.hasPermission(signal, signal.channelId, info) {
+		return
+	}
+
+	ch := e.s.channelReactor.loadOrCreateChannel(signal.channelId, signal.channelType)
+	tg := e.s.tagManager.getReceiverTag(ch.receiverTagKey.Load())
+	if tg == nil {
+		tg, err = ch.makeReceiverTag()
+		if err != nil {
+			e.Warn("makeReceiverTag failed", zap.Error(err), zap.String("channelId", signal.channelId), zap.Uint8("channelType", signal.channelType))
+			return
+		}
+	}
+
+	var data []byte
+	for _, nodeUser := range tg.users {
+		if nodeUser.nodeId == e.s.opts.Cluster.NodeId {
+			e.deliverLocal(signal, nodeUser.uids)
+			continue
+		}
+		if data == nil {
+			data = (&ephemeralSignal{
+				fromUid:      signal.fromUid,
+				fromDeviceId: signal.fromDeviceId,
+				channelId:    signal.channelId,
+				channelType:  signal.channelType,
+				tagKey:       tg.key,
+				leaderId:     e.s.opts.Cluster.NodeId,
+				payload:      signal.payload,
+			}).Marshal()
+		}
+		err = e.s.cluster.Send(nodeUser.nodeId, &proto.Message{
+			MsgType: uint32(ClusterMsgTypeEphemeralDeliver),
+			Content: data,
+		})
+		if err != nil {
+			e.Warn("deliver ephemeral signal failed", zap.Error(err), zap.Uint64("nodeId", nodeUser.nodeId), zap.String("channelId", signal.channelId))
+		}
+	}
+}
+
+// deliverByTag 投递领导节点转发过来的信号，本节点没有tag时向领导节点请求
+func (e *ephemeralManager) deliverByTag(signal *ephemeralSignal) {
+	tg := e.s.tagManager.getReceiverTag(signal.tagKey)
+	if tg == nil {
+		resp, err := e.s.deliverManager.nextDeliver().requestNodeChannelTag(signal.leaderId, &tagReq{
+			channelId:   signal.channelId,
+			channelType: signal.channelType,
+			tagKey:      signal.tagKey,
+			nodeId:      e.s.opts.Cluster.NodeId,
+		})
+		if err != nil {
+			e.Warn("requestNodeChannelTag failed", zap.Error(err), zap.String("tagKey", signal.tagKey), zap.String("channelId", signal.channelId))
+			return
+		}
+		tg = e.s.tagManager.addOrUpdateReceiverTag(resp.tagKey, []*nodeUsers{
+			{
+				uids:   resp.uids,
+				nodeId: e.s.opts.Cluster.NodeId,
+			},
+		}, signal.channelId, signal.channelType)
+	}
+	for _, nodeUser := range tg.users {
+		if nodeUser.nodeId == e.s.opts.Cluster.NodeId {
+			e.deliverLocal(signal, nodeUser.uids)
+			break
+		}
+	}
+}
+
+// deliverLocal 投递给本节点在线的接收者（不包含发送者自己）
+func (e *ephemeralManager) deliverLocal(signal *ephemeralSignal, uids []string) {
+	conns := make([]*connContext, 0, len(uids))
+	for _, uid := range uids {
+		if uid == signal.fromUid {
+			continue
+		}
+		for _, conn := range e.s.userReactor.getConns(uid) {
+			if !conn.isAuth.Load() || conn.isClosed() {
+				continue
+			}
+			conns = append(conns, conn)
+		}
+	}
+	if len(conns) == 0 {
+		return
+	}
+
+	sendPacket := &wkproto.SendPacket{
+		Framer: wkproto.Framer{
+			NoPersist: true,
+			RedDot:    false,
+		},
+		Setting:     SettingEphemeral,
+		ClientMsgNo: wkutil.GenUUID(),
+		ChannelID:   signal.channelId,
+		ChannelType: signal.channelType,
+		Payload:     signal.payload,
+	}
+	req := &deliverReq{
+		channelId:   signal.channelId,
+		channelType: signal.channelType,
+		messages: []ReactorChannelMessage{
+			{
+				FromUid:      signal.fromUid,
+				FromDeviceId: signal.fromDeviceId,
+				MessageId:    e.s.channelReactor.messageIDGen.Generate().Int64(),
+				SendPacket:   sendPacket,
+			},
+		},
+	}
+	e.s.deliverManager.nextDeliver().deliverToConns(req, conns)
+}
+
+// hasPermission 发送者是否可以在频道内发送临时信号，复用普通消息的权限判断
+func (e *ephemeralManager) hasPermission(signal *ephemeralSignal, channelId string, info wkdb.ChannelInfo) bool {
+	reasonCode, err := e.s.channelReactor.hasPermission(channelId, signal.channelType, signal.fromUid, info)
+	if err != nil {
+		e.Warn("hasPermission failed", zap.Error(err), zap.String("fromUid", signal.fromUid), zap.String("channelId", channelId), zap.Uint8("channelType", signal.channelType))
+		return false
+	}
+	if reasonCode != wkproto.ReasonSuccess {
+		e.Debug("ephemeral signal no permission", zap.String("fromUid", signal.fromUid), zap.String("channelId", channelId), zap.String("reasonCode", reasonCode.String()))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,130 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEphemeralSignal(payload string) *ephemeralSignal {
+	return &ephemeralSignal{
+		fromUid:     "u1",
+		channelId:   "g1",
+		channelType: wkproto.ChannelTypeGroup,
+		payload:     []byte(payload),
+	}
+}
+
+func TestEphemeralCoalesce(t *testing.T) {
+	opts := NewOptions()
+	opts.Ephemeral.Interval = time.Millisecond * 50
+	opts.Ephemeral.RepeatInterval = time.Millisecond * 500
+	e := newEphemeralManager(&Server{opts: opts})
+
+	// 第一个信号立即转发
+	e.offer(newTestEphemeralSignal(`{"type":"typing"}`))
+	assert.Equal(t, 1, len(e.signalC))
+
+	// 间隔内的信号合并为最新的一个
+	e.offer(newTestEphemeralSignal(`{"type":"typing"}`))
+	e.offer(newTestEphemeralSignal(`{"type":"recording"}`))
+	e.flush()
+	assert.Equal(t, 1, len(e.signalC))
+
+	time.Sleep(time.Millisecond * 60)
+	e.flush()
+	assert.Equal(t, 2, len(e.signalC))
+	<-e.signalC
+	assert.Equal(t, `{"type":"recording"}`, string((<-e.signalC).payload))
+
+	// 相同的信号在重复间隔内不再转发
+	time.Sleep(time.Millisecond * 60)
+	e.offer(newTestEphemeralSignal(`{"type":"recording"}`))
+	assert.Equal(t, 0, len(e.signalC))
+
+	// 其他频道的信号不受影响
+	signal := newTestEphemeralSignal(`{"type":"recording"}`)
+	signal.channelId = "g2"
+	e.offer(signal)
+	assert.Equal(t, 1, len(e.signalC))
+}
+
+func TestEphemeralAllowRate(t *testing.T) {
+	opts := NewOptions()
+	opts.Ephemeral.MaxPerSecond = 2
+	e := newEphemeralManager(&Server{opts: opts})
+
+	assert.True(t, e.allowRate("u1", 100))
+	assert.True(t, e.allowRate("u1", 100))
+	assert.False(t, e.allowRate("u1", 100))
+	assert.True(t, e.allowRate("u2", 100))
+
+	// 下一秒重新计数
+	assert.True(t, e.allowRate("u1", 101))
+}
+
+func TestEphemeralSignalMarshal(t *testing.T) {
+	signal := &ephemeralSignal{
+		fromUid:      "u1",
+		fromDeviceId: "d1",
+		channelId:    "g1",
+		channelType:  wkproto.ChannelTypeGroup,
+		tagKey:       "tag1",
+		leaderId:     2,
+		payload:      []byte(`{"type":"typing"}`),
+	}
+	signal2 := &ephemeralSignal{}
+	err := signal2.Unmarshal(signal.Marshal())
+	assert.NoError(t, err)
+	assert.Equal(t, signal, signal2)
+}
+
+func TestEphemeralPayloadCheck(t *testing.T) {
+	assert.NoError(t, EphemeralPayload{Type: EphemeralTypeTyping}.Check())
+	assert.NoError(t, EphemeralPayload{Type: EphemeralTypeViewing}.Check())
+	assert.Error(t, EphemeralPayload{Type: "unknown"}.Check())
+}
+
+func TestEphemeralPermission(t *testing.T) {
+	s := NewTestServer(t)
+	err := s.Start()
+	assert.NoError(t, err)
+	defer func() {
+		_ = s.Stop()
+	}()
+
+	s.MustWaitAllSlotsReady(time.Second * 10)
+
+	// 只允许管理员发言的频道
+	err = s.store.AddChannelInfo(wkdb.ChannelInfo{ChannelId: "g1", ChannelType: wkproto.ChannelTypeGroup, SendMode: wkdb.SendModeAdmin})
+	assert.NoError(t, err)
+	err = s.store.AddSubscribers("g1", wkproto.ChannelTypeGroup, []wkdb.Member{
+		{Uid: "u1"},
+		{Uid: "u2", Role: wkdb.MemberRoleAdmin},
+	})
+	assert.NoError(t, err)
+
+	// 禁言的成员
+	err = s.store.AddSubscribers("g2", wkproto.ChannelTypeGroup, []wkdb.Member{
+		{Uid: "u1", MuteUntil: time.Now().Add(time.Hour).Unix()},
+		{Uid: "u2"},
+	})
+	assert.NoError(t, err)
+
+	hasPermission := func(fromUid, channelId string) bool {
+		info, err := s.store.GetChannel(channelId, wkproto.ChannelTypeGroup)
+		if err != nil && err != wkdb.ErrNotFound {
+			t.Fatal(err)
+		}
+		signal := &ephemeralSignal{fromUid: fromUid, channelId: channelId, channelType: wkproto.ChannelTypeGroup}
+		return s.ephemeral.hasPermission(signal, channelId, info)
+	}
+	assert.False(t, hasPermission("u1", "g1"))
+	assert.True(t, hasPermission("u2", "g1"))
+	assert.False(t, hasPermission("u3", "g1")) // 不是订阅者
+	assert.False(t, hasPermission("u1", "g2"))
+	assert.True(t, hasPermission("u2", "g2"))
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// historyRange 用户在频道内可见的消息范围 [startSeq,endSeq)，以及用户自己隐藏的消息
+type historyRange struct {
+	startSeq   uint64                    // 第一条可见的消息序号（包含），0表示不限制
+	endSeq     uint64                    // 第一条不可见的消息序号（不包含），0表示不限制
+	gaps       []wkdb.SubscriberSeqRange // 范围内离开期间不可见的消息 [LeaveSeq,JoinSeq)
+	visibility wkdb.MessageVisibility    // 用户对自己删除和清空的消息
+}
+
+// 不限制的可见范围
+var unlimitedHistoryRange = historyRange{}
+
+// 没有任何可见消息的范围
+var emptyHistoryRange = historyRange{startSeq: 1, endSeq: 1}
+
+func (r historyRange) unlimited() bool {
+	return r.startSeq <= 1 && r.endSeq == 0 && len(r.gaps) == 0
+}
+
+// empty 没有任何可见的消息
+func (r historyRange) empty() bool {
+	return r.endSeq != 0 && r.endSeq <= max(r.startSeq, 1) // 消息序号从1开始
+}
+
+func (r historyRange) contains(seq uint64) bool {
+	if r.empty() {
+		return false
+	}
+	if seq < r.startSeq {
+		return false
+	}
+	if r.endSeq != 0 && seq >= r.endSeq {
+		return false
+	}
+	for _, gap := range r.gaps {
+		if seq >= gap.LeaveSeq && seq < gap.JoinSeq {
+			return false
+		}
+	}
+	return true
+}
+
+// filter 过滤掉不可见的消息
+func (r historyRange) filter(messages []wkdb.Message) []wkdb.Message {
+	if r.unlimited() && len(r.visibility.HiddenMessageIds) == 0 {
+		return messages
+	}
+	visibles := make([]wkdb.Message, 0, len(messages))
+	for _, message := range messages {
+		if r.contains(uint64(message.MessageSeq)) && r.visibility.Visible(message.MessageID, uint64(message.MessageSeq)) {
+			visibles = append(visibles, message)
+		}
+	}
+	return visibles
+}
+
+// filterMessages 过滤掉不可见的消息，用户隐藏的消息只按拉取到的消息序号范围加载
+func (s *Server) filterMessages(uid string, channelId string, channelType uint8, r *historyRange, messages []wkdb.Message) ([]wkdb.Message, error) {
+	if len(messages) > 0 && r.visibility.UpdatedAt != 0 { // UpdatedAt为0表示用户没有删除或清空过消息
+		minSeq, maxSeq := uint64(messages[0].MessageSeq), uint64(messages[0].MessageSeq)
+		for _, message := range messages[1:] {
+			minSeq = min(minSeq, uint64(message.MessageSeq))
+			maxSeq = max(maxSeq, uint64(message.MessageSeq))
+		}
+		hiddenMessageIds, err := s.store.GetHiddenMessageIds(channelId, channelType, uid, minSeq, maxSeq)
+		if err != nil {
+			return nil, err
+		}
+		r.visibility.HiddenMessageIds = hiddenMessageIds
+	}
+	return r.filter(messages), nil
+}
+
+// nextRange 将向上拉取的范围（包含start，不包含end，end为0表示不限制）限制在可见范围内，ok为false表示没有可见的消息
+func (r historyRange) nextRange(start, end uint64) (uint64, uint64, bool) {
+	if r.empty() {
+		return 0, 0, false
+	}
+	if start < r.startSeq {
+		start = r.startSeq
+	}
+	if r.endSeq != 0 && (end == 0 || end > r.endSeq) {
+		end = r.endSeq
+	}
+	if end != 0 && start >= end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// prevRange 将向下拉取的范围（包含start，不包含end，start为0表示从最新的消息开始，end为0表示不限制）限制在可见范围内，ok为false表示没有可见的消息
+func (r historyRange) prevRange(start, end uint64) (uint64, uint64, bool) {
+	if r.empty() {
+		return 0, 0, false
+	}
+	if r.endSeq != 0 && (start == 0 || start >= r.endSeq) {
+		start = r.endSeq - 1
+	}
+	if r.startSeq > 1 && end < r.startSeq-1 {
+		end = r.startSeq - 1
+	}
+	if start != 0 && end != 0 && start <= end {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// getHistoryRange 获取用户在频道内可见的历史消息范围（包含用户自己清空和隐藏的消息）
+func (s *Server) getHistoryRange(uid string, channelId string, channelType uint8) (historyRange, error) {
+	r, err := s.getJoinedHistoryRange(uid, channelId, channelType)
+	if err != nil {
+		return r, err
+	}
+	visibility, err := s.store.GetMessageVisibility(channelId, channelType, uid)
+	if err != nil {
+		return r, err
+	}
+	r.visibility = visibility
+	if visibility.ClearedToSeq > 0 && r.startSeq <= visibility.ClearedToSeq {
+		r.startSeq = visibility.ClearedToSeq + 1
+	}
+	return r, nil
+}
+
+// getJoinedHistoryRange 根据频道的历史消息可见性和订阅者的加入/离开记录获取可见的消息范围
+func (s *Server) getJoinedHistoryRange(uid string, channelId string, channelType uint8) (historyRange, error) {
+	if channelType == wkproto.ChannelTypePerson {
+		return unlimitedHistoryRange, nil
+	}
+	if s.systemUIDManager.SystemUID(uid) {
+		return unlimitedHistoryRange, nil
+	}
+	channelInfo, err := s.store.GetChannel(channelId, channelType)
+	if err != nil {
+		if err == wkdb.ErrNotFound {
+			return unlimitedHistoryRange, nil
+		}
+		return unlimitedHistoryRange, err
+	}
+	if channelInfo.HistoryVisibility == wkdb.HistoryVisibilityAll {
+		return unlimitedHistoryRange, nil
+	}
+	history, err := s.store.GetSubscriberHistory(channelId, channelType, uid)
+	if err != nil {
+		if err != wkdb.ErrNotFound {
+			return unlimitedHistoryRange, err
+		}
+		// 没有加入记录的订阅者是在记录功能之前加入的，可以看到所有消息
+		exist, err := s.store.ExistSubscriber(channelId, channelType, uid)
+		if err != nil {
+			return unlimitedHistoryRange, err
+		}
+		if exist {
+			return unlimitedHistoryRange, nil
+		}
+		return emptyHistoryRange, nil
+	}
+	return newHistoryRange(channelInfo, history), nil
+}
+
+// newHistoryRange 通过订阅者每次加入/离开的范围计算可见范围，多次加入时离开期间的消息不可见
+func newHistoryRange(channelInfo wkdb.ChannelInfo, history wkdb.SubscriberHistory) historyRange {
+	ranges := make([]wkdb.SubscriberSeqRange, 0, len(history.PrevRanges)+1)
+	ranges = append(ranges, history.PrevRanges...)
+	ranges = append(ranges, wkdb.SubscriberSeqRange{JoinSeq: history.JoinSeq, LeaveSeq: history.LeaveSeq})
+
+	var r historyRange
+	for i, sr := range ranges {
+		// 每次加入都可以看到加入前的最后N条消息
+		if channelInfo.HistoryVisibility == wkdb.HistoryVisibilityLastN {
+			count := uint64(channelInfo.HistoryVisibleCount)
+			if sr.JoinSeq > count {
+				sr.JoinSeq -= count
+			} else {
+				sr.JoinSeq = 0
+			}
+		}
+		if i == 0 {
+			r.startSeq = sr.JoinSeq
+		} else if r.endSeq < sr.JoinSeq { // 与上一个范围不连续
+			r.gaps = append(r.gaps, wkdb.SubscriberSeqRange{LeaveSeq: r.endSeq, JoinSeq: sr.JoinSeq})
+		}
+		r.endSeq = sr.LeaveSeq
+	}
+	return r
+}
+
+// loadPrevRangeMsgs 向下加载消息，start为0表示从最新的消息开始
+func (s *Server) loadPrevRangeMsgs(channelId string, channelType uint8, start, end uint64, limit int) ([]wkdb.Message, error) {
+	if start == 0 {
+		lastMsgSeq, err := s.store.GetLastMsgSeq(channelId, channelType)
+		if err != nil {
+			return nil, err
+		}
+		if lastMsgSeq <= end {
+			return nil, nil
+		}
+		start = lastMsgSeq
+	}
+	return s.store.LoadPrevRangeMsgs(channelId, channelType, start, end, limit)
+}
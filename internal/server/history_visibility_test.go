@@ -0,0 +1,110 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewHistoryRange(t *testing.T) {
+	sinceJoin := wkdb.ChannelInfo{HistoryVisibility: wkdb.HistoryVisibilitySinceJoin}
+	r := newHistoryRange(sinceJoin, wkdb.SubscriberHistory{JoinSeq: 11})
+	assert.False(t, r.contains(10))
+	assert.True(t, r.contains(11))
+	assert.True(t, r.contains(1000))
+
+	// 离开后的消息不可见
+	r = newHistoryRange(sinceJoin, wkdb.SubscriberHistory{JoinSeq: 11, LeaveSeq: 21})
+	assert.True(t, r.contains(20))
+	assert.False(t, r.contains(21))
+
+	// 加入前的最后N条消息可见
+	lastN := wkdb.ChannelInfo{HistoryVisibility: wkdb.HistoryVisibilityLastN, HistoryVisibleCount: 5}
+	r = newHistoryRange(lastN, wkdb.SubscriberHistory{JoinSeq: 11})
+	assert.False(t, r.contains(5))
+	assert.True(t, r.contains(6))
+	r = newHistoryRange(lastN, wkdb.SubscriberHistory{JoinSeq: 3})
+	assert.True(t, r.unlimited())
+
+	// 在空频道加入并离开
+	r = newHistoryRange(sinceJoin, wkdb.SubscriberHistory{JoinSeq: 1, LeaveSeq: 1})
+	assert.True(t, r.empty())
+
+	// 重新加入后，之前加入期间的消息仍然可见，离开期间的消息不可见
+	r = newHistoryRange(sinceJoin, wkdb.SubscriberHistory{JoinSeq: 41, PrevRanges: []wkdb.SubscriberSeqRange{{JoinSeq: 11, LeaveSeq: 21}}})
+	assert.False(t, r.contains(10))
+	assert.True(t, r.contains(11))
+	assert.True(t, r.contains(20))
+	assert.False(t, r.contains(21))
+	assert.False(t, r.contains(40))
+	assert.True(t, r.contains(41))
+	assert.False(t, r.unlimited())
+
+	// 加入前N条消息覆盖了离开期间的消息
+	lastN.HistoryVisibleCount = 30
+	r = newHistoryRange(lastN, wkdb.SubscriberHistory{JoinSeq: 41, PrevRanges: []wkdb.SubscriberSeqRange{{JoinSeq: 31, LeaveSeq: 35}}})
+	assert.True(t, r.contains(1))
+	assert.True(t, r.contains(36))
+	assert.True(t, r.unlimited())
+}
+
+func TestHistoryRangeClamp(t *testing.T) {
+	r := historyRange{startSeq: 11, endSeq: 21}
+
+	// 向上拉取
+	start, end, ok := r.nextRange(1, 0)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(11), start)
+	assert.Equal(t, uint64(21), end)
+
+	start, end, ok = r.nextRange(15, 18)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(15), start)
+	assert.Equal(t, uint64(18), end)
+
+	_, _, ok = r.nextRange(21, 0)
+	assert.False(t, ok)
+
+	// 向下拉取
+	start, end, ok = r.prevRange(0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(20), start)
+	assert.Equal(t, uint64(10), end)
+
+	start, end, ok = r.prevRange(15, 12)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(15), start)
+	assert.Equal(t, uint64(12), end)
+
+	_, _, ok = r.prevRange(10, 0)
+	assert.False(t, ok)
+
+	// 没有离开时从最新的消息开始
+	r = historyRange{startSeq: 11}
+	start, end, ok = r.prevRange(0, 0)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), start)
+	assert.Equal(t, uint64(10), end)
+
+	messages := []wkdb.Message{{RecvPacket: wkproto.RecvPacket{MessageSeq: 10}}, {RecvPacket: wkproto.RecvPacket{MessageSeq: 11}}}
+	assert.Len(t, r.filter(messages), 1)
+}
+
+func TestHistoryRangeFilterHidden(t *testing.T) {
+	r := historyRange{
+		visibility: wkdb.MessageVisibility{
+			ClearedToSeq:     10,
+			HiddenMessageIds: []int64{1012},
+		},
+	}
+	messages := []wkdb.Message{
+		{RecvPacket: wkproto.RecvPacket{MessageID: 1010, MessageSeq: 10}},
+		{RecvPacket: wkproto.RecvPacket{MessageID: 1011, MessageSeq: 11}},
+		{RecvPacket: wkproto.RecvPacket{MessageID: 1012, MessageSeq: 12}},
+	}
+	visibles := r.filter(messages)
+	assert.Len(t, visibles, 1)
+	assert.Equal(t, int64(1011), visibles[0].MessageID)
+}
@@ -0,0 +1,349 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// IP访问控制的监听类型
+const (
+	IPAccessListenerTCP     = "tcp"     // tcp长连接
+	IPAccessListenerWS      = "ws"      // websocket长连接
+	IPAccessListenerWSS     = "wss"     // websocket(tls)长连接
+	IPAccessListenerAPI     = "api"     // http api
+	IPAccessListenerManager = "manager" // 管理端
+)
+
+// IPAccessListeners 所有支持IP访问控制的监听
+var IPAccessListeners = []string{IPAccessListenerTCP, IPAccessListenerWS, IPAccessListenerWSS, IPAccessListenerAPI, IPAccessListenerManager}
+
+const (
+	ipAccessActionAllow = "allow"
+	ipAccessActionDeny  = "deny"
+	// 配置了允许列表但是没有命中任何规则时，记录在此规则下
+	ipAccessRuleDefault = "default"
+)
+
+// IPAccessConfig 单个监听的IP访问控制配置
+type IPAccessConfig struct {
+	Allow []string `json:"allow"` // 允许访问的CIDR（或单个IP）列表，为空表示不限制
+	Deny  []string `json:"deny"`  // 拒绝访问的CIDR（或单个IP）列表，优先级高于Allow
+}
+
+type ipAccessRule struct {
+	rule  string
+	ipNet *net.IPNet
+	hits  atomic.Int64
+}
+
+type ipAccessList struct {
+	allows     []*ipAccessRule
+	denies     []*ipAccessRule
+	defaultHit atomic.Int64 // 没有命中允许列表而被拒绝的次数
+}
+
+// ipAccessManager 按监听管理IP的允许/拒绝列表
+type ipAccessManager struct {
+	mu             sync.RWMutex
+	lists          map[string]*ipAccessList
+	trustedProxies []*net.IPNet
+	s              *Server
+	wklog.Log
+}
+
+func newIPAccessManager(s *Server) *ipAccessManager {
+	return &ipAccessManager{
+		lists: make(map[string]*ipAccessList),
+		s:     s,
+		Log:   wklog.NewWKLog("ipAccessManager"),
+	}
+}
+
+// loadFromOptions 从配置中加载规则
+func (m *ipAccessManager) loadFromOptions() error {
+	opts := m.s.opts.Hot()
+	cfgs := map[string]IPAccessConfig{
+		IPAccessListenerTCP:     opts.IPAccess.TCP,
+		IPAccessListenerWS:      opts.IPAccess.WS,
+		IPAccessListenerWSS:     opts.IPAccess.WSS,
+		IPAccessListenerAPI:     opts.IPAccess.API,
+		IPAccessListenerManager: opts.IPAccess.Manager,
+	}
+	trustedProxies, err := parseIPNets(opts.IPAccess.TrustedProxies)
+	if err != nil {
+		return err
+	}
+	lists := make(map[string]*ipAccessList, len(cfgs))
+	for listener, cfg := range cfgs {
+		list, err := newIPAccessList(cfg)
+		if err != nil {
+			return fmt.Errorf("%s: %w", listener, err)
+		}
+		lists[listener] = list
+	}
+
+	m.mu.Lock()
+	m.lists = lists
+	m.trustedProxies = trustedProxies
+	m.mu.Unlock()
+	return nil
+}
+
+// setRules 替换指定监听的规则（命中次数会被重置）
+func (m *ipAccessManager) setRules(listener string, cfg IPAccessConfig) error {
+	if !isIPAccessListener(listener) {
+		return fmt.Errorf("unknown listener: %s", listener)
+	}
+	list, err := newIPAccessList(cfg)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.lists[listener] = list
+	m.mu.Unlock()
+	return nil
+}
+
+// allow 判断ip是否允许访问指定的监听
+func (m *ipAccessManager) allow(listener string, ip net.IP) bool {
+	m.mu.RLock()
+	list := m.lists[listener]
+	m.mu.RUnlock()
+	if list == nil || (len(list.allows) == 0 && len(list.denies) == 0) {
+		return true
+	}
+	if ip == nil { // 无法识别客户端地址的情况下，配置了允许列表则拒绝
+		if len(list.allows) > 0 {
+			list.defaultHit.Inc()
+			m.s.trace.Metrics.App().IPAccessRuleHitAdd(listener, ipAccessActionDeny, ipAccessRuleDefault, 1)
+			return false
+		}
+		return true
+	}
+
+	for _, rule := range list.denies {
+		if rule.ipNet.Contains(ip) {
+			rule.hits.Inc()
+			m.s.trace.Metrics.App().IPAccessRuleHitAdd(listener, ipAccessActionDeny, rule.rule, 1)
+			return false
+		}
+	}
+	if len(list.allows) == 0 {
+		return true
+	}
+	for _, rule := range list.allows {
+		if rule.ipNet.Contains(ip) {
+			rule.hits.Inc()
+			m.s.trace.Metrics.App().IPAccessRuleHitAdd(listener, ipAccessActionAllow, rule.rule, 1)
+			return true
+		}
+	}
+	list.defaultHit.Inc()
+	m.s.trace.Metrics.App().IPAccessRuleHitAdd(listener, ipAccessActionDeny, ipAccessRuleDefault, 1)
+	return false
+}
+
+// allowConn 判断长连接是否允许访问（地址为经过代理协议解析后的真实地址）
+func (m *ipAccessManager) allowConn(conn wknet.Conn) bool {
+	return m.allow(connIPAccessListener(conn), addrIP(conn.RemoteAddr()))
+}
+
+// middleware http的IP访问控制中间件
+func (m *ipAccessManager) middleware(listener string) wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		ip := m.clientIP(c.RemoteIP(), c.GetHeader("X-Forwarded-For"), c.GetHeader("X-Real-IP"))
+		if !m.allow(listener, ip) {
+			m.Debug("ip access denied", zap.String("listener", listener), zap.String("ip", ip.String()), zap.String("path", c.Request.URL.Path))
+			c.AbortWithStatus(403)
+			return
+		}
+		c.Next()
+	}
+}
+
+// clientIP 获取http请求的真实客户端IP，只有直连地址是可信代理时才读取转发头
+func (m *ipAccessManager) clientIP(remoteIP string, forwardedFor string, realIP string) net.IP {
+	ip := net.ParseIP(strings.TrimSpace(remoteIP))
+	if ip == nil {
+		return nil
+	}
+	m.mu.RLock()
+	trustedProxies := m.trustedProxies
+	m.mu.RUnlock()
+	if !containsIP(trustedProxies, ip) {
+		return ip
+	}
+	if strings.TrimSpace(forwardedFor) != "" {
+		// 从右往左找到第一个不是可信代理的地址
+		items := strings.Split(forwardedFor, ",")
+		for i := len(items) - 1; i >= 0; i-- {
+			forwardIP := net.ParseIP(strings.TrimSpace(items[i]))
+			if forwardIP == nil {
+				break
+			}
+			ip = forwardIP
+			if !containsIP(trustedProxies, forwardIP) {
+				break
+			}
+		}
+		return ip
+	}
+	if forwardIP := net.ParseIP(strings.TrimSpace(realIP)); forwardIP != nil {
+		return forwardIP
+	}
+	return ip
+}
+
+// IPAccessRuleStat 规则及命中统计
+type IPAccessRuleStat struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Hits   int64  `json:"hits"`
+}
+
+// IPAccessListenerStat 监听的规则统计
+type IPAccessListenerStat struct {
+	Listener    string              `json:"listener"`
+	Rules       []*IPAccessRuleStat `json:"rules"`
+	DefaultDeny int64               `json:"default_deny"` // 未命中允许列表被拒绝的次数
+}
+
+func (m *ipAccessManager) stats() []*IPAccessListenerStat {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	stats := make([]*IPAccessListenerStat, 0, len(IPAccessListeners))
+	for _, listener := range IPAccessListeners {
+		stat := &IPAccessListenerStat{
+			Listener: listener,
+			Rules:    make([]*IPAccessRuleStat, 0),
+		}
+		list := m.lists[listener]
+		if list != nil {
+			for _, rule := range list.denies {
+				stat.Rules = append(stat.Rules, &IPAccessRuleStat{Rule: rule.rule, Action: ipAccessActionDeny, Hits: rule.hits.Load()})
+			}
+			for _, rule := range list.allows {
+				stat.Rules = append(stat.Rules, &IPAccessRuleStat{Rule: rule.rule, Action: ipAccessActionAllow, Hits: rule.hits.Load()})
+			}
+			stat.DefaultDeny = list.defaultHit.Load()
+		}
+		stats = append(stats, stat)
+	}
+	return stats
+}
+
+func newIPAccessList(cfg IPAccessConfig) (*ipAccessList, error) {
+	list := &ipAccessList{}
+	for _, rule := range cfg.Allow {
+		r, err := newIPAccessRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		list.allows = append(list.allows, r)
+	}
+	for _, rule := range cfg.Deny {
+		r, err := newIPAccessRule(rule)
+		if err != nil {
+			return nil, err
+		}
+		list.denies = append(list.denies, r)
+	}
+	return list, nil
+}
+
+func newIPAccessRule(rule string) (*ipAccessRule, error) {
+	ipNet, err := parseIPNet(rule)
+	if err != nil {
+		return nil, err
+	}
+	return &ipAccessRule{
+		rule:  ipNet.String(),
+		ipNet: ipNet,
+	}, nil
+}
+
+// parseIPNet 解析CIDR，单个IP会被当成/32（IPv6为/128）
+func parseIPNet(s string) (*net.IPNet, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid ip or cidr: %s", s)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+	_, ipNet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ip or cidr: %s", s)
+	}
+	return ipNet, nil
+}
+
+func parseIPNets(items []string) ([]*net.IPNet, error) {
+	ipNets := make([]*net.IPNet, 0, len(items))
+	for _, item := range items {
+		ipNet, err := parseIPNet(item)
+		if err != nil {
+			return nil, err
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return ipNets, nil
+}
+
+func containsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func isIPAccessListener(listener string) bool {
+	for _, l := range IPAccessListeners {
+		if l == listener {
+			return true
+		}
+	}
+	return false
+}
+
+func connIPAccessListener(conn wknet.Conn) string {
+	switch conn.(type) {
+	case *wknet.WSSConn:
+		return IPAccessListenerWSS
+	case *wknet.WSConn:
+		return IPAccessListenerWS
+	default:
+		return IPAccessListenerTCP
+	}
+}
+
+func addrIP(addr net.Addr) net.IP {
+	if addr == nil {
+		return nil
+	}
+	switch a := addr.(type) {
+	case *net.TCPAddr:
+		return a.IP
+	case *net.UDPAddr:
+		return a.IP
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		host = addr.String()
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIPAccessManager(t *testing.T) *ipAccessManager {
+	opts := NewOptions()
+	opts.IPAccess.TrustedProxies = []string{"10.0.0.0/8"}
+	opts.IPAccess.API = IPAccessConfig{
+		Allow: []string{"192.168.1.0/24", "172.16.0.1"},
+		Deny:  []string{"192.168.1.100"},
+	}
+	opts.IPAccess.TCP = IPAccessConfig{
+		Deny: []string{"1.2.3.0/24"},
+	}
+	s := &Server{
+		opts:  opts,
+		trace: trace.New(context.Background(), trace.NewOptions()),
+	}
+	m := newIPAccessManager(s)
+	err := m.loadFromOptions()
+	assert.NoError(t, err)
+	return m
+}
+
+func TestIPAccessAllow(t *testing.T) {
+	m := newTestIPAccessManager(t)
+
+	// 允许列表
+	assert.True(t, m.allow(IPAccessListenerAPI, net.ParseIP("192.168.1.2")))
+	assert.True(t, m.allow(IPAccessListenerAPI, net.ParseIP("172.16.0.1")))
+	assert.False(t, m.allow(IPAccessListenerAPI, net.ParseIP("172.16.0.2")))
+	// 拒绝优先
+	assert.False(t, m.allow(IPAccessListenerAPI, net.ParseIP("192.168.1.100")))
+	// 仅有拒绝列表
+	assert.False(t, m.allow(IPAccessListenerTCP, net.ParseIP("1.2.3.4")))
+	assert.True(t, m.allow(IPAccessListenerTCP, net.ParseIP("1.2.4.4")))
+	// 没有配置规则
+	assert.True(t, m.allow(IPAccessListenerWS, net.ParseIP("1.2.3.4")))
+
+	stats := m.stats()
+	for _, stat := range stats {
+		if stat.Listener != IPAccessListenerAPI {
+			continue
+		}
+		assert.Equal(t, int64(1), stat.DefaultDeny)
+		for _, rule := range stat.Rules {
+			switch rule.Rule {
+			case "192.168.1.0/24", "172.16.0.1/32", "192.168.1.100/32":
+				assert.Equal(t, int64(1), rule.Hits)
+			}
+		}
+	}
+
+	// 运行时替换规则
+	err := m.setRules(IPAccessListenerWS, IPAccessConfig{Deny: []string{"1.2.3.4"}})
+	assert.NoError(t, err)
+	assert.False(t, m.allow(IPAccessListenerWS, net.ParseIP("1.2.3.4")))
+
+	err = m.setRules(IPAccessListenerWS, IPAccessConfig{Deny: []string{"1.2.3.4/abc"}})
+	assert.Error(t, err)
+}
+
+func TestIPAccessClientIP(t *testing.T) {
+	m := newTestIPAccessManager(t)
+
+	// 非可信代理，忽略转发头
+	assert.Equal(t, "8.8.8.8", m.clientIP("8.8.8.8", "192.168.1.2", "").String())
+	// 可信代理，取最右侧的非可信地址
+	assert.Equal(t, "192.168.1.2", m.clientIP("10.0.0.1", "1.1.1.1, 192.168.1.2, 10.0.0.2", "").String())
+	// 可信代理，使用X-Real-IP
+	assert.Equal(t, "192.168.1.3", m.clientIP("10.0.0.1", "", "192.168.1.3").String())
+}
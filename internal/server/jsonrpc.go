@@ -0,0 +1,648 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wknet"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"go.uber.org/zap"
+)
+
+// JSON文本协议（JSON-RPC 2.0风格），面向浏览器和脚本客户端
+// 每条websocket文本消息为一个json对象（保留websocket消息边界，json可以是格式化的多行文本）
+//
+// 客户端请求: connect、send、recvack、ping
+// 服务端响应: connect -> connack结果，send -> sendack结果，ping -> pong结果
+// 服务端通知: recv、disconnect（sendack无法对应请求时也以通知的形式下发）
+//
+// 协议层与二进制协议共用同一套处理流程：请求被转换为对应的二进制包交给userReactor/channelReactor处理，
+// 下发的二进制包在写入连接前再转换为json
+
+const (
+	jsonRPCVersion = "2.0"
+
+	ConnKeyJSONRPC = "jsonRPC" // json文本协议的连接状态
+
+	jsonRPCMethodConnect    = "connect"
+	jsonRPCMethodSend       = "send"
+	jsonRPCMethodRecvack    = "recvack"
+	jsonRPCMethodPing       = "ping"
+	jsonRPCMethodSub        = "sub"
+	jsonRPCMethodRecv       = "recv"
+	jsonRPCMethodSendack    = "sendack"
+	jsonRPCMethodDisconnect = "disconnect"
+
+	jsonRPCErrCodeParse          = -32700 // 解析失败
+	jsonRPCErrCodeInvalidRequest = -32600 // 无效的请求
+	jsonRPCErrCodeMethodNotFound = -32601 // 方法不存在
+	jsonRPCErrCodeInvalidParams  = -32602 // 参数错误
+	jsonRPCErrCodeNotConnected   = -32000 // 未连接
+)
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Id      json.RawMessage `json:"id,omitempty"`
+}
+
+type jsonRPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+	Id      json.RawMessage `json:"id"`
+}
+
+type jsonRPCNotification struct {
+	JSONRPC string      `json:"jsonrpc"`
+	Method  string      `json:"method"`
+	Params  interface{} `json:"params,omitempty"`
+}
+
+type jsonRPCConnectParams struct {
+	UID             string `json:"uid"`
+	Token           string `json:"token"`
+	DeviceId        string `json:"device_id"`
+	DeviceFlag      uint8  `json:"device_flag"`
+	ClientTimestamp int64  `json:"client_timestamp"`
+}
+
+type jsonRPCConnectResult struct {
+	ServerVersion uint8  `json:"server_version"`
+	TimeDiff      int64  `json:"time_diff"`
+	ReasonCode    uint8  `json:"reason_code"`
+	NodeId        uint64 `json:"node_id"`
+}
+
+type jsonRPCSendParams struct {
+	ChannelId   string          `json:"channel_id"`
+	ChannelType uint8           `json:"channel_type"`
+	Payload     json.RawMessage `json:"payload"`
+	ClientSeq   uint64          `json:"client_seq"`
+	ClientMsgNo string          `json:"client_msg_no"`
+	StreamNo    string          `json:"stream_no,omitempty"`
+	Topic       string          `json:"topic,omitempty"`
+	Expire      uint32          `json:"expire,omitempty"`
+	NoPersist   bool            `json:"no_persist,omitempty"`
+	RedDot      *bool           `json:"red_dot,omitempty"` // 默认开启红点
+	SyncOnce    bool            `json:"sync_once,omitempty"`
+	Ephemeral   bool            `json:"ephemeral,omitempty"` // 临时信号（payload为{"type":"typing|recording|viewing"}）
+}
+
+type jsonRPCSendackResult struct {
+	MessageId   string `json:"message_id"`
+	MessageSeq  uint32 `json:"message_seq"`
+	ClientSeq   uint64 `json:"client_seq"`
+	ClientMsgNo string `json:"client_msg_no"`
+	ReasonCode  uint8  `json:"reason_code"`
+}
+
+type jsonRPCRecvackParams struct {
+	MessageId  string `json:"message_id"`
+	MessageSeq uint32 `json:"message_seq"`
+}
+
+type jsonRPCRecvParams struct {
+	MessageId   string          `json:"message_id"`
+	MessageSeq  uint32          `json:"message_seq"`
+	ClientMsgNo string          `json:"client_msg_no"`
+	StreamNo    string          `json:"stream_no,omitempty"`
+	StreamSeq   uint32          `json:"stream_seq,omitempty"`
+	StreamFlag  uint8           `json:"stream_flag,omitempty"`
+	Timestamp   int32           `json:"timestamp"`
+	ChannelId   string          `json:"channel_id"`
+	ChannelType uint8           `json:"channel_type"`
+	Topic       string          `json:"topic,omitempty"`
+	FromUID     string          `json:"from_uid"`
+	Expire      uint32          `json:"expire,omitempty"`
+	RedDot      bool            `json:"red_dot,omitempty"`
+	SyncOnce    bool            `json:"sync_once,omitempty"`
+	NoPersist   bool            `json:"no_persist,omitempty"`
+	Ephemeral   bool            `json:"ephemeral,omitempty"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+type jsonRPCSubParams struct {
+	SubNo       string          `json:"sub_no"`
+	ChannelId   string          `json:"channel_id"`
+	ChannelType uint8           `json:"channel_type"`
+	Action      uint8           `json:"action"` // 0.订阅 1.取消订阅
+	Param       json.RawMessage `json:"param,omitempty"`
+}
+
+type jsonRPCSubackResult struct {
+	SubNo       string `json:"sub_no"`
+	ChannelId   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	Action      uint8  `json:"action"`
+	ReasonCode  uint8  `json:"reason_code"`
+}
+
+type jsonRPCDisconnectParams struct {
+	ReasonCode uint8  `json:"reason_code"`
+	Reason     string `json:"reason"`
+}
+
+// jsonRPCState json文本协议的连接状态，用于关联请求id和响应
+type jsonRPCState struct {
+	mu        sync.Mutex
+	connectId json.RawMessage
+	sendIds   map[uint64]json.RawMessage // clientSeq -> 请求id
+	subIds    map[string]json.RawMessage // subNo -> 请求id
+	pingIds   []json.RawMessage
+	clientSeq uint64 // 客户端未指定clientSeq时自动分配
+}
+
+func newJSONRPCState() *jsonRPCState {
+	return &jsonRPCState{
+		sendIds: make(map[uint64]json.RawMessage),
+		subIds:  make(map[string]json.RawMessage),
+	}
+}
+
+func (j *jsonRPCState) setConnectId(id json.RawMessage) {
+	j.mu.Lock()
+	j.connectId = id
+	j.mu.Unlock()
+}
+
+func (j *jsonRPCState) takeConnectId() json.RawMessage {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id := j.connectId
+	j.connectId = nil
+	return id
+}
+
+// 添加发送请求的id，clientSeq为0时自动分配，返回最终的clientSeq
+func (j *jsonRPCState) addSendId(clientSeq uint64, id json.RawMessage) uint64 {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if clientSeq == 0 {
+		j.clientSeq++
+		clientSeq = j.clientSeq
+	} else if clientSeq > j.clientSeq {
+		j.clientSeq = clientSeq
+	}
+	if len(id) > 0 {
+		j.sendIds[clientSeq] = id
+	}
+	return clientSeq
+}
+
+func (j *jsonRPCState) takeSendId(clientSeq uint64) json.RawMessage {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.sendIds[clientSeq]
+	if ok {
+		delete(j.sendIds, clientSeq)
+	}
+	return id
+}
+
+func (j *jsonRPCState) addPingId(id json.RawMessage) {
+	j.mu.Lock()
+	j.pingIds = append(j.pingIds, id)
+	j.mu.Unlock()
+}
+
+func (j *jsonRPCState) takePingId() json.RawMessage {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if len(j.pingIds) == 0 {
+		return nil
+	}
+	id := j.pingIds[0]
+	j.pingIds = j.pingIds[1:]
+	return id
+}
+
+func (j *jsonRPCState) addSubId(subNo string, id json.RawMessage) {
+	if len(id) == 0 {
+		return
+	}
+	j.mu.Lock()
+	j.subIds[subNo] = id
+	j.mu.Unlock()
+}
+
+func (j *jsonRPCState) takeSubId(subNo string) json.RawMessage {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	id, ok := j.subIds[subNo]
+	if ok {
+		delete(j.subIds, subNo)
+	}
+	return id
+}
+
+func getJSONRPCState(conn wknet.Conn) *jsonRPCState {
+	if conn == nil {
+		return nil
+	}
+	v := conn.Value(ConnKeyJSONRPC)
+	if v == nil {
+		return nil
+	}
+	return v.(*jsonRPCState)
+}
+
+// 处理json文本协议的数据，数据由wknet按websocket消息加上长度头写入
+func (s *Server) onJSONRPCData(conn wknet.Conn, buff []byte) error {
+	offset := 0
+	for offset < len(buff) {
+		msg, size := wknet.DecodeJSONRPCMessage(buff[offset:])
+		if size == 0 {
+			break
+		}
+		offset += size
+		msg = bytes.TrimSpace(msg)
+		if len(msg) == 0 {
+			continue
+		}
+		if !s.handleJSONRPCRequest(conn, msg) {
+			_, _ = conn.Discard(offset)
+			conn.Close()
+			return nil
+		}
+	}
+	if offset > 0 {
+		_, _ = conn.Discard(offset)
+	}
+	return nil
+}
+
+// 处理一条json请求，返回false表示需要关闭连接
+func (s *Server) handleJSONRPCRequest(conn wknet.Conn, data []byte) bool {
+	var req jsonRPCRequest
+	if err := json.Unmarshal(data, &req); err != nil {
+		s.Warn("Failed to decode the jsonrpc request", zap.Error(err))
+		writeJSONRPC(conn, newJSONRPCErrorResponse(nil, jsonRPCErrCodeParse, "parse error"))
+		return true
+	}
+	if req.Method == "" {
+		writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidRequest, "method is empty"))
+		return true
+	}
+
+	var connCtx *connContext
+	if connCtxObj := conn.Context(); connCtxObj != nil {
+		connCtx = connCtxObj.(*connContext)
+	}
+
+	if connCtx == nil || !connCtx.isAuth.Load() {
+		if req.Method != jsonRPCMethodConnect {
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeNotConnected, "please connect first"))
+			return false
+		}
+		if connCtx != nil { // 正在认证中
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidRequest, "connecting"))
+			return true
+		}
+		connectPacket, err := jsonRPCToConnectPacket(req.Params)
+		if err != nil {
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidParams, err.Error()))
+			return false
+		}
+		state := newJSONRPCState()
+		state.setConnectId(req.Id)
+		conn.SetValue(ConnKeyJSONRPC, state)
+		s.handleConnectPacket(conn, connectPacket, "")
+		return true
+	}
+
+	state := getJSONRPCState(conn)
+	if state == nil {
+		return false
+	}
+
+	switch req.Method {
+	case jsonRPCMethodSend:
+		sendPacket, err := jsonRPCToSendPacket(req.Params)
+		if err != nil {
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidParams, err.Error()))
+			return true
+		}
+		// 加密payload并生成msgKey，使其与二进制协议的发送包一致
+		sendPacket.Payload, err = wkutil.AesEncryptPkcs7Base64(sendPacket.Payload, connCtx.aesKey, connCtx.aesIV)
+		if err != nil {
+			s.Warn("Failed to encrypt the payload", zap.Error(err))
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidParams, "encrypt payload failed"))
+			return true
+		}
+		sendPacket.ClientSeq = state.addSendId(sendPacket.ClientSeq, req.Id)
+		msgKey, err := wkutil.AesEncryptPkcs7Base64([]byte(sendPacket.VerityString()), connCtx.aesKey, connCtx.aesIV)
+		if err != nil {
+			s.Warn("Failed to generate the msgKey", zap.Error(err))
+			state.takeSendId(sendPacket.ClientSeq)
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidParams, "generate msgKey failed"))
+			return true
+		}
+		sendPacket.MsgKey = wkutil.MD5Bytes(msgKey)
+		s.handleFrame(connCtx, sendPacket)
+	case jsonRPCMethodRecvack:
+		recvackPacket, err := jsonRPCToRecvackPacket(req.Params)
+		if err != nil {
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidParams, err.Error()))
+			return true
+		}
+		s.handleFrame(connCtx, recvackPacket)
+	case jsonRPCMethodPing:
+		state.addPingId(req.Id)
+		s.handleFrame(connCtx, &wkproto.PingPacket{})
+	case jsonRPCMethodSub:
+		subPacket, err := jsonRPCToSubPacket(req.Params)
+		if err != nil {
+			writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeInvalidParams, err.Error()))
+			return true
+		}
+		state.addSubId(subPacket.SubNo, req.Id)
+		s.handleFrame(connCtx, subPacket)
+	default:
+		writeJSONRPC(conn, newJSONRPCErrorResponse(req.Id, jsonRPCErrCodeMethodNotFound, "method not found"))
+	}
+	return true
+}
+
+func jsonRPCToConnectPacket(params json.RawMessage) (*wkproto.ConnectPacket, error) {
+	var p jsonRPCConnectParams
+	if len(params) == 0 {
+		return nil, errors.New("params is empty")
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	// 文本协议不需要客户端参与密钥协商，这里由服务端代为生成客户端公钥
+	_, clientPubKey := wkutil.GetCurve25519KeypPair()
+	return &wkproto.ConnectPacket{
+		Version:         wkproto.LatestVersion,
+		ClientKey:       base64.StdEncoding.EncodeToString(clientPubKey[:]),
+		DeviceID:        p.DeviceId,
+		DeviceFlag:      wkproto.DeviceFlag(p.DeviceFlag),
+		ClientTimestamp: p.ClientTimestamp,
+		UID:             p.UID,
+		Token:           p.Token,
+	}, nil
+}
+
+func jsonRPCToSendPacket(params json.RawMessage) (*wkproto.SendPacket, error) {
+	var p jsonRPCSendParams
+	if len(params) == 0 {
+		return nil, errors.New("params is empty")
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ChannelId == "" {
+		return nil, errors.New("channel_id is empty")
+	}
+	if len(p.Payload) == 0 {
+		return nil, errors.New("payload is empty")
+	}
+	sendPacket := &wkproto.SendPacket{
+		Framer: wkproto.Framer{
+			NoPersist: p.NoPersist,
+			RedDot:    p.RedDot == nil || *p.RedDot,
+			SyncOnce:  p.SyncOnce,
+		},
+		Expire:      p.Expire,
+		ClientSeq:   p.ClientSeq,
+		ClientMsgNo: p.ClientMsgNo,
+		StreamNo:    p.StreamNo,
+		ChannelID:   p.ChannelId,
+		ChannelType: p.ChannelType,
+		Topic:       p.Topic,
+		Payload:     jsonRPCPayloadToBytes(p.Payload),
+	}
+	if sendPacket.ClientMsgNo == "" {
+		sendPacket.ClientMsgNo = wkutil.GenUUID()
+	}
+	if sendPacket.Topic != "" {
+		sendPacket.Setting = sendPacket.Setting.Set(wkproto.SettingTopic)
+	}
+	if sendPacket.StreamNo != "" {
+		sendPacket.Setting = sendPacket.Setting.Set(wkproto.SettingStream)
+	}
+	if p.Ephemeral {
+		sendPacket.Setting = sendPacket.Setting.Set(SettingEphemeral)
+	}
+	return sendPacket, nil
+}
+
+func jsonRPCToRecvackPacket(params json.RawMessage) (*wkproto.RecvackPacket, error) {
+	var p jsonRPCRecvackParams
+	if len(params) == 0 {
+		return nil, errors.New("params is empty")
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	messageId, err := strconv.ParseInt(p.MessageId, 10, 64)
+	if err != nil {
+		return nil, errors.New("message_id is illegal")
+	}
+	return &wkproto.RecvackPacket{
+		MessageID:  messageId,
+		MessageSeq: p.MessageSeq,
+	}, nil
+}
+
+func jsonRPCToSubPacket(params json.RawMessage) (*wkproto.SubPacket, error) {
+	var p jsonRPCSubParams
+	if len(params) == 0 {
+		return nil, errors.New("params is empty")
+	}
+	if err := json.Unmarshal(params, &p); err != nil {
+		return nil, err
+	}
+	return &wkproto.SubPacket{
+		SubNo:       p.SubNo,
+		ChannelID:   p.ChannelId,
+		ChannelType: p.ChannelType,
+		Action:      wkproto.Action(p.Action),
+		Param:       string(jsonRPCPayloadToBytes(p.Param)), // param可以是json字符串或者json对象
+	}, nil
+}
+
+// payload为json字符串时取字符串内容，其他json值取原始文本
+func jsonRPCPayloadToBytes(payload json.RawMessage) []byte {
+	if len(payload) > 0 && payload[0] == '"' {
+		var str string
+		if err := json.Unmarshal(payload, &str); err == nil {
+			return []byte(str)
+		}
+	}
+	return []byte(payload)
+}
+
+// payload为合法json时原样嵌入，否则作为json字符串
+func jsonRPCPayloadFromBytes(payload []byte) json.RawMessage {
+	if len(payload) > 0 && json.Valid(payload) {
+		return json.RawMessage(payload)
+	}
+	data, _ := json.Marshal(string(payload))
+	return data
+}
+
+// 将下发的二进制包转换为json消息
+func (c *connContext) framesToJSONRPC(data []byte, state *jsonRPCState) ([][]byte, error) {
+	var msgs [][]byte
+	proto := c.subReactor.r.s.opts.Proto
+	offset := 0
+	for offset < len(data) {
+		frame, size, err := proto.DecodeFrame(data[offset:], c.protoVersion)
+		if err != nil {
+			return nil, err
+		}
+		if frame == nil {
+			break
+		}
+		offset += size
+		v := c.frameToJSONRPC(frame, state)
+		if v == nil {
+			continue
+		}
+		msg, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
+
+func (c *connContext) frameToJSONRPC(frame wkproto.Frame, state *jsonRPCState) interface{} {
+	switch packet := frame.(type) {
+	case *wkproto.ConnackPacket:
+		return newJSONRPCResponse(state.takeConnectId(), &jsonRPCConnectResult{
+			ServerVersion: packet.ServerVersion,
+			TimeDiff:      packet.TimeDiff,
+			ReasonCode:    uint8(packet.ReasonCode),
+			NodeId:        packet.NodeId,
+		})
+	case *wkproto.SendackPacket:
+		result := &jsonRPCSendackResult{
+			MessageId:   strconv.FormatInt(packet.MessageID, 10),
+			MessageSeq:  packet.MessageSeq,
+			ClientSeq:   packet.ClientSeq,
+			ClientMsgNo: packet.ClientMsgNo,
+			ReasonCode:  uint8(packet.ReasonCode),
+		}
+		id := state.takeSendId(packet.ClientSeq)
+		if len(id) == 0 {
+			return newJSONRPCNotification(jsonRPCMethodSendack, result)
+		}
+		return newJSONRPCResponse(id, result)
+	case *wkproto.SubackPacket:
+		return newJSONRPCResponse(state.takeSubId(packet.SubNo), &jsonRPCSubackResult{
+			SubNo:       packet.SubNo,
+			ChannelId:   packet.ChannelID,
+			ChannelType: packet.ChannelType,
+			Action:      uint8(packet.Action),
+			ReasonCode:  uint8(packet.ReasonCode),
+		})
+	case *wkproto.PongPacket:
+		id := state.takePingId()
+		if len(id) == 0 {
+			return nil
+		}
+		return newJSONRPCResponse(id, struct{}{})
+	case *wkproto.RecvPacket:
+		payload, err := wkutil.AesDecryptPkcs7Base64(packet.Payload, c.aesKey, c.aesIV)
+		if err != nil {
+			c.Warn("Failed to decrypt the payload", zap.Error(err))
+			return nil
+		}
+		if packet.Setting.IsSet(SettingPayloadCompressed) {
+			if payload, err = wkutil.Decompress(c.compression, payload); err != nil {
+				c.Warn("Failed to decompress the payload", zap.Error(err))
+				return nil
+			}
+		}
+		return newJSONRPCNotification(jsonRPCMethodRecv, &jsonRPCRecvParams{
+			MessageId:   strconv.FormatInt(packet.MessageID, 10),
+			MessageSeq:  packet.MessageSeq,
+			ClientMsgNo: packet.ClientMsgNo,
+			StreamNo:    packet.StreamNo,
+			StreamSeq:   packet.StreamSeq,
+			StreamFlag:  uint8(packet.StreamFlag),
+			Timestamp:   packet.Timestamp,
+			ChannelId:   packet.ChannelID,
+			ChannelType: packet.ChannelType,
+			Topic:       packet.Topic,
+			FromUID:     packet.FromUID,
+			Expire:      packet.Expire,
+			RedDot:      packet.RedDot,
+			SyncOnce:    packet.SyncOnce,
+			NoPersist:   packet.NoPersist,
+			Ephemeral:   packet.Setting.IsSet(SettingEphemeral),
+			Payload:     jsonRPCPayloadFromBytes(payload),
+		})
+	case *wkproto.DisconnectPacket:
+		return newJSONRPCNotification(jsonRPCMethodDisconnect, &jsonRPCDisconnectParams{
+			ReasonCode: uint8(packet.ReasonCode),
+			Reason:     packet.Reason,
+		})
+	}
+	return nil
+}
+
+func newJSONRPCResponse(id json.RawMessage, result interface{}) *jsonRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &jsonRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		Result:  result,
+		Id:      id,
+	}
+}
+
+func newJSONRPCErrorResponse(id json.RawMessage, code int, message string) *jsonRPCResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &jsonRPCResponse{
+		JSONRPC: jsonRPCVersion,
+		Error: &jsonRPCError{
+			Code:    code,
+			Message: message,
+		},
+		Id: id,
+	}
+}
+
+func newJSONRPCNotification(method string, params interface{}) *jsonRPCNotification {
+	return &jsonRPCNotification{
+		JSONRPC: jsonRPCVersion,
+		Method:  method,
+		Params:  params,
+	}
+}
+
+// 直接写入json消息（用于认证前或协议错误时的响应）
+func writeJSONRPC(conn wknet.Conn, v interface{}) {
+	wsConn, ok := conn.(wknet.IWSConn)
+	if !ok {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	if err = wsConn.WriteServerText(data); err != nil {
+		return
+	}
+	_ = conn.WakeWrite()
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONRPCToSendPacket(t *testing.T) {
+	sendPacket, err := jsonRPCToSendPacket(json.RawMessage(`{"channel_id":"u2","channel_type":1,"payload":{"type":1,"content":"hi"},"client_msg_no":"no1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "u2", sendPacket.ChannelID)
+	assert.Equal(t, uint8(1), sendPacket.ChannelType)
+	assert.Equal(t, "no1", sendPacket.ClientMsgNo)
+	assert.Equal(t, `{"type":1,"content":"hi"}`, string(sendPacket.Payload))
+	assert.True(t, sendPacket.RedDot)
+
+	// 字符串payload取字符串内容
+	sendPacket, err = jsonRPCToSendPacket(json.RawMessage(`{"channel_id":"g1","channel_type":2,"payload":"hello","red_dot":false,"topic":"t1"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(sendPacket.Payload))
+	assert.False(t, sendPacket.RedDot)
+	assert.NotEmpty(t, sendPacket.ClientMsgNo)
+	assert.True(t, sendPacket.Setting.IsSet(wkproto.SettingTopic))
+
+	_, err = jsonRPCToSendPacket(json.RawMessage(`{"channel_type":1,"payload":"hello"}`))
+	assert.Error(t, err)
+}
+
+func TestJSONRPCPayloadFromBytes(t *testing.T) {
+	assert.Equal(t, `{"a":1}`, string(jsonRPCPayloadFromBytes([]byte(`{"a":1}`))))
+	assert.Equal(t, `"hello"`, string(jsonRPCPayloadFromBytes([]byte("hello"))))
+}
+
+func TestJSONRPCState(t *testing.T) {
+	state := newJSONRPCState()
+
+	clientSeq := state.addSendId(0, json.RawMessage(`1`))
+	assert.Equal(t, uint64(1), clientSeq)
+	clientSeq = state.addSendId(10, json.RawMessage(`"a"`))
+	assert.Equal(t, uint64(10), clientSeq)
+	clientSeq = state.addSendId(0, json.RawMessage(`2`))
+	assert.Equal(t, uint64(11), clientSeq)
+
+	assert.Equal(t, `"a"`, string(state.takeSendId(10)))
+	assert.Empty(t, state.takeSendId(10))
+
+	state.addPingId(json.RawMessage(`3`))
+	state.addPingId(json.RawMessage(`4`))
+	assert.Equal(t, `3`, string(state.takePingId()))
+	assert.Equal(t, `4`, string(state.takePingId()))
+	assert.Empty(t, state.takePingId())
+}
+
+func TestJSONRPCToSubPacket(t *testing.T) {
+	subPacket, err := jsonRPCToSubPacket(json.RawMessage(`{"sub_no":"s1","channel_type":1,"action":0,"param":{"uids":["u2","u3"]}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, "s1", subPacket.SubNo)
+	assert.Equal(t, wkproto.Subscribe, subPacket.Action)
+	assert.Equal(t, `{"uids":["u2","u3"]}`, subPacket.Param)
+
+	// 字符串param取字符串内容
+	subPacket, err = jsonRPCToSubPacket(json.RawMessage(`{"sub_no":"s2","channel_type":1,"action":1,"param":"{\"status\":\"away\"}"}`))
+	assert.NoError(t, err)
+	assert.Equal(t, wkproto.UnSubscribe, subPacket.Action)
+	assert.Equal(t, `{"status":"away"}`, subPacket.Param)
+
+	state := newJSONRPCState()
+	state.addSubId("s1", json.RawMessage(`5`))
+	assert.Equal(t, `5`, string(state.takeSubId("s1")))
+	assert.Empty(t, state.takeSubId("s1"))
+}
+
+func TestJSONRPCToEphemeralSendPacket(t *testing.T) {
+	sendPacket, err := jsonRPCToSendPacket(json.RawMessage(`{"channel_id":"g1","channel_type":2,"payload":{"type":"typing"},"ephemeral":true}`))
+	assert.NoError(t, err)
+	assert.True(t, sendPacket.Setting.IsSet(SettingEphemeral))
+	assert.Equal(t, `{"type":"typing"}`, string(sendPacket.Payload))
+}
@@ -0,0 +1,336 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"go.uber.org/zap"
+)
+
+// oidc登录state的有效期
+const oidcStateExpire = time.Minute * 10
+
+// 角色的优先级，同时匹配多个角色时取权限最大的
+var roleRank = map[auth.Role]int{
+	auth.RoleViewer:   1,
+	auth.RoleOperator: 2,
+	auth.RoleAdmin:    3,
+}
+
+// oidcDiscovery OIDC提供者的端点信息
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+}
+
+// managerOIDC 管理端的OIDC登录（授权码模式）
+// id_token通过后端直连token端点获取，按OIDC规范使用TLS校验代替签名校验
+type managerOIDC struct {
+	s *Server
+	wklog.Log
+	httpClient *http.Client
+
+	mu        sync.Mutex
+	discovery *oidcDiscovery
+}
+
+func newManagerOIDC(s *Server) *managerOIDC {
+	return &managerOIDC{
+		s:          s,
+		Log:        wklog.NewWKLog("managerOIDC"),
+		httpClient: &http.Client{Timeout: time.Second * 10},
+	}
+}
+
+// login 跳转到OIDC提供者的登录页
+func (o *managerOIDC) login(c *wkhttp.Context) {
+	cfg := o.s.opts.Manager.OIDC
+	if !cfg.On {
+		c.ResponseError(errors.New("没有开启OIDC登录"))
+		return
+	}
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		o.Error("get oidc discovery failed", zap.Error(err))
+		c.ResponseError(errors.New("获取OIDC配置失败"))
+		return
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": "oidc_state",
+		"nonce":   nonce,
+		"exp":     time.Now().Add(oidcStateExpire).Unix(),
+	}).SignedString(o.stateKey())
+	if err != nil {
+		c.ResponseError(err)
+		return
+	}
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", cfg.ClientId)
+	values.Set("redirect_uri", cfg.RedirectUrl)
+	values.Set("scope", strings.Join(cfg.Scopes, " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	c.Redirect(http.StatusFound, fmt.Sprintf("%s?%s", discovery.AuthorizationEndpoint, values.Encode()))
+}
+
+// callback 处理OIDC提供者的回调，返回登录的用户名
+func (o *managerOIDC) callback(c *wkhttp.Context) (string, error) {
+	cfg := o.s.opts.Manager.OIDC
+	if !cfg.On {
+		return "", errors.New("没有开启OIDC登录")
+	}
+	if errMsg := c.Query("error"); errMsg != "" {
+		return "", fmt.Errorf("oidc error: %s %s", errMsg, c.Query("error_description"))
+	}
+	nonce, err := o.verifyState(c.Query("state"))
+	if err != nil {
+		return "", err
+	}
+	code := c.Query("code")
+	if code == "" {
+		return "", errors.New("code不能为空")
+	}
+	discovery, err := o.getDiscovery()
+	if err != nil {
+		return "", err
+	}
+	claims, err := o.exchange(discovery, code)
+	if err != nil {
+		return "", err
+	}
+	if err = o.verifyClaims(discovery, claims, nonce); err != nil {
+		return "", err
+	}
+
+	username, _ := claims[cfg.UsernameClaim].(string)
+	if strings.TrimSpace(username) == "" {
+		return "", fmt.Errorf("id_token中没有%s", cfg.UsernameClaim)
+	}
+	role := oidcRoleOf(claims[cfg.RoleClaim], cfg.RoleMapping, auth.Role(cfg.DefaultRole))
+	if role == "" {
+		return "", fmt.Errorf("用户[%s]没有匹配的角色", username)
+	}
+	if err = o.syncUser(username, role); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+// syncUser 将OIDC用户同步到集群中（每次登录更新角色）
+func (o *managerOIDC) syncUser(username string, role auth.Role) error {
+	for _, user := range o.s.opts.Auth.Users {
+		if user.Username == username {
+			return fmt.Errorf("用户名[%s]与配置文件中的用户冲突", username)
+		}
+	}
+	if err := o.s.managerUserManager.reload(); err != nil {
+		return err
+	}
+	now := time.Now()
+	user, ok := o.s.managerUserManager.getUser(username)
+	if ok {
+		if user.Source != ManagerUserSourceOIDC {
+			return fmt.Errorf("用户名[%s]已被本地用户占用", username)
+		}
+		if user.Disabled {
+			return ErrManagerUserDisabled
+		}
+		if user.Role == string(role) {
+			return nil
+		}
+	} else {
+		user = wkdb.ManagerUser{
+			Username:  username,
+			Source:    ManagerUserSourceOIDC,
+			CreatedAt: &now,
+		}
+	}
+	user.Role = string(role)
+	user.UpdatedAt = &now
+	if err := o.s.store.AddOrUpdateManagerUser(user); err != nil {
+		return err
+	}
+	o.s.managerUserManager.notifyChanged()
+	return nil
+}
+
+// stateKey 签名state的key，由jwt secret派生，保证state不能被当作管理端的jwt使用
+func (o *managerOIDC) stateKey() []byte {
+	mac := hmac.New(sha256.New, []byte(o.s.opts.Jwt.Secret))
+	mac.Write([]byte("wukongim_oidc_state"))
+	return mac.Sum(nil)
+}
+
+func (o *managerOIDC) verifyState(state string) (string, error) {
+	token, err := jwt.Parse(state, func(token *jwt.Token) (interface{}, error) {
+		return o.stateKey(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil || !token.Valid {
+		return "", errors.New("无效的state")
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if claims["purpose"] != "oidc_state" {
+		return "", errors.New("无效的state")
+	}
+	nonce, _ := claims["nonce"].(string)
+	return nonce, nil
+}
+
+// exchange 使用授权码换取id_token，返回id_token中的claims
+func (o *managerOIDC) exchange(discovery *oidcDiscovery, code string) (jwt.MapClaims, error) {
+	cfg := o.s.opts.Manager.OIDC
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", cfg.RedirectUrl)
+	values.Set("client_id", cfg.ClientId)
+	values.Set("client_secret", cfg.ClientSecret)
+	resp, err := o.httpClient.PostForm(discovery.TokenEndpoint, values)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc token endpoint returned %d: %s", resp.StatusCode, string(body))
+	}
+	var tokenResp struct {
+		IdToken string `json:"id_token"`
+	}
+	if err = json.Unmarshal(body, &tokenResp); err != nil {
+		return nil, err
+	}
+	if tokenResp.IdToken == "" {
+		return nil, errors.New("oidc token endpoint returned no id_token")
+	}
+	claims := jwt.MapClaims{}
+	if _, _, err = jwt.NewParser().ParseUnverified(tokenResp.IdToken, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (o *managerOIDC) verifyClaims(discovery *oidcDiscovery, claims jwt.MapClaims, nonce string) error {
+	if iss, _ := claims.GetIssuer(); iss != discovery.Issuer {
+		return fmt.Errorf("id_token issuer mismatch: %s", iss)
+	}
+	aud, _ := claims.GetAudience()
+	audOk := false
+	for _, a := range aud {
+		if a == o.s.opts.Manager.OIDC.ClientId {
+			audOk = true
+			break
+		}
+	}
+	if !audOk {
+		return errors.New("id_token audience mismatch")
+	}
+	exp, _ := claims.GetExpirationTime()
+	if exp == nil || exp.Before(time.Now()) {
+		return errors.New("id_token expired")
+	}
+	if tokenNonce, _ := claims["nonce"].(string); tokenNonce != nonce {
+		return errors.New("id_token nonce mismatch")
+	}
+	return nil
+}
+
+func (o *managerOIDC) getDiscovery() (*oidcDiscovery, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.discovery != nil {
+		return o.discovery, nil
+	}
+	resp, err := o.httpClient.Get(o.s.opts.Manager.OIDC.Issuer + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery returned %d", resp.StatusCode)
+	}
+	var discovery oidcDiscovery
+	if err = json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&discovery); err != nil {
+		return nil, err
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" {
+		return nil, errors.New("oidc discovery missing endpoints")
+	}
+	o.discovery = &discovery
+	return o.discovery, nil
+}
+
+// oidcRoleOf 通过角色claim获取角色，同时匹配多个时取权限最大的，没有匹配时返回默认角色
+func oidcRoleOf(claim interface{}, roleMapping map[string]string, defaultRole auth.Role) auth.Role {
+	var values []string
+	switch v := claim.(type) {
+	case string:
+		values = append(values, v)
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	var role auth.Role
+	for _, value := range values {
+		// 配置的key会被转为小写
+		mapped := auth.Role(roleMapping[strings.ToLower(value)])
+		if mapped.Valid() && roleRank[mapped] > roleRank[role] {
+			role = mapped
+		}
+	}
+	if role == "" {
+		role = defaultRole
+	}
+	return role
+}
+
+// oidcSuccessRedirectUrl 登录成功后的跳转地址
+// token等参数放在url的fragment中，fragment不会发送到服务端，避免token出现在访问日志和Referer中
+func oidcSuccessRedirectUrl(redirect string, resp gin.H) string {
+	values := url.Values{}
+	for k, v := range resp {
+		values.Set(k, fmt.Sprintf("%v", v))
+	}
+	if i := strings.Index(redirect, "#"); i >= 0 {
+		redirect = redirect[:i]
+	}
+	return redirect + "#" + values.Encode()
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCRoleOf(t *testing.T) {
+	roleMapping := map[string]string{
+		"wk-admins": "admin",
+		"wk-ops":    "operator",
+		"wk-dev":    "viewer",
+	}
+	// 多个角色时取权限最大的
+	role := oidcRoleOf([]interface{}{"wk-dev", "WK-Ops", "other"}, roleMapping, "")
+	assert.Equal(t, auth.RoleOperator, role)
+
+	role = oidcRoleOf("wk-admins", roleMapping, "")
+	assert.Equal(t, auth.RoleAdmin, role)
+
+	// 没有匹配时使用默认角色
+	role = oidcRoleOf([]interface{}{"other"}, roleMapping, auth.RoleViewer)
+	assert.Equal(t, auth.RoleViewer, role)
+
+	role = oidcRoleOf(nil, roleMapping, "")
+	assert.Equal(t, auth.Role(""), role)
+}
+
+func TestRolePermissions(t *testing.T) {
+	viewer := auth.RoleViewer.Permissions()
+	assert.True(t, viewer.HasPermission(resource.Data.Message, auth.ActionRead))
+	assert.False(t, viewer.HasPermission(resource.Cluster.Logs, auth.ActionRead))
+	assert.False(t, viewer.HasPermission(resource.Slot.Migrate, auth.ActionWrite))
+
+	operator := auth.RoleOperator.Permissions()
+	assert.True(t, operator.HasPermission(resource.Cluster.Logs, auth.ActionRead))
+	assert.True(t, operator.HasPermission(resource.Slot.Migrate, auth.ActionWrite))
+	assert.False(t, operator.HasPermission(resource.Manager.User, auth.ActionWrite))
+
+	admin := auth.RoleAdmin.Permissions()
+	assert.True(t, admin.HasPermission(resource.Manager.User, auth.ActionWrite))
+}
+
+func TestParseManagerJwt(t *testing.T) {
+	secret := "test_secret"
+	sign := func(method jwt.SigningMethod, claims jwt.MapClaims) string {
+		tokenStr, err := jwt.NewWithClaims(method, claims).SignedString([]byte(secret))
+		assert.NoError(t, err)
+		return tokenStr
+	}
+	exp := time.Now().Add(time.Minute).Unix()
+
+	username, err := parseManagerJwt(sign(jwt.SigningMethodHS256, jwt.MapClaims{"username": "admin", "exp": exp}), secret)
+	assert.NoError(t, err)
+	assert.Equal(t, "admin", username)
+
+	// 没有用户名
+	_, err = parseManagerJwt(sign(jwt.SigningMethodHS256, jwt.MapClaims{"exp": exp}), secret)
+	assert.Error(t, err)
+
+	// 用户名不是字符串
+	_, err = parseManagerJwt(sign(jwt.SigningMethodHS256, jwt.MapClaims{"username": 1, "exp": exp}), secret)
+	assert.Error(t, err)
+
+	// 带purpose的jwt不能作为登录凭证
+	_, err = parseManagerJwt(sign(jwt.SigningMethodHS256, jwt.MapClaims{"username": "admin", "purpose": "oidc_state", "exp": exp}), secret)
+	assert.Error(t, err)
+
+	// 只接受HS256
+	_, err = parseManagerJwt(sign(jwt.SigningMethodHS512, jwt.MapClaims{"username": "admin", "exp": exp}), secret)
+	assert.Error(t, err)
+}
+
+func TestOIDCStateNotManagerJwt(t *testing.T) {
+	opts := NewOptions()
+	opts.Jwt.Secret = "test_secret"
+	o := newManagerOIDC(&Server{opts: opts})
+
+	state, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose": "oidc_state",
+		"nonce":   "nonce",
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}).SignedString(o.stateKey())
+	assert.NoError(t, err)
+
+	nonce, err := o.verifyState(state)
+	assert.NoError(t, err)
+	assert.Equal(t, "nonce", nonce)
+
+	// state使用单独的key签名，不能通过管理端jwt的校验
+	_, err = parseManagerJwt(state, opts.Jwt.Secret)
+	assert.Error(t, err)
+}
+
+func TestOIDCSuccessRedirectUrl(t *testing.T) {
+	redirectUrl := oidcSuccessRedirectUrl("https://example.com/login?from=oidc#old", gin.H{"token": "abc"})
+	assert.Equal(t, "https://example.com/login?from=oidc#token=abc", redirectUrl)
+	assert.False(t, strings.Contains(strings.Split(redirectUrl, "#")[0], "token"))
+}
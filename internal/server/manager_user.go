@@ -0,0 +1,233 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	ManagerUserSourceLocal = "local" // 通过管理端api创建的用户
+	ManagerUserSourceOIDC  = "oidc"  // 通过OIDC登录自动创建的用户
+)
+
+var (
+	ErrManagerUserDisabled = errors.New("manager user disabled")
+)
+
+// managerUserManager 管理端用户管理，用户存储在固定槽位上，各节点缓存一份用于鉴权
+// 实现了auth.UserProvider，配置文件中的auth.users仍然有效并且优先
+type managerUserManager struct {
+	s *Server
+	wklog.Log
+
+	mu     sync.RWMutex
+	users  map[string]wkdb.ManagerUser // key为用户名
+	loaded atomic.Bool
+
+	refreshTimer *timingwheel.Timer
+}
+
+func newManagerUserManager(s *Server) *managerUserManager {
+	return &managerUserManager{
+		s:     s,
+		Log:   wklog.NewWKLog("managerUserManager"),
+		users: make(map[string]wkdb.ManagerUser),
+	}
+}
+
+func (m *managerUserManager) start() error {
+	// 定时刷新，防止错过变更通知
+	m.refreshTimer = m.s.Schedule(m.s.opts.Manager.UserRefreshInterval, func() {
+		if err := m.reload(); err != nil {
+			m.Warn("refresh manager users failed", zap.Error(err))
+		}
+	})
+	return nil
+}
+
+func (m *managerUserManager) stop() {
+	if m.refreshTimer != nil {
+		m.refreshTimer.Stop()
+	}
+}
+
+// Permissions 获取用户的权限（auth.UserProvider）
+func (m *managerUserManager) Permissions(username string) (auth.PermissionConfigs, bool) {
+	user, ok := m.getUser(username)
+	if !ok || user.Disabled {
+		return nil, false
+	}
+	return auth.Role(user.Role).Permissions(), true
+}
+
+// getUser 获取缓存中的用户
+func (m *managerUserManager) getUser(username string) (wkdb.ManagerUser, bool) {
+	if err := m.loadIfNeed(); err != nil {
+		m.Warn("load manager users failed", zap.Error(err))
+		return wkdb.EmptyManagerUser, false
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	user, ok := m.users[username]
+	return user, ok
+}
+
+// getUsers 获取缓存中的所有用户
+func (m *managerUserManager) getUsers() ([]wkdb.ManagerUser, error) {
+	if err := m.loadIfNeed(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	users := make([]wkdb.ManagerUser, 0, len(m.users))
+	for _, user := range m.users {
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+// authenticate 校验用户名和密码
+func (m *managerUserManager) authenticate(username, password string) (wkdb.ManagerUser, error) {
+	user, ok := m.getUser(username)
+	if !ok || user.PasswordHash == "" {
+		return wkdb.EmptyManagerUser, auth.ErrAuthFailed
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return wkdb.EmptyManagerUser, auth.ErrAuthFailed
+	}
+	if user.Disabled {
+		return wkdb.EmptyManagerUser, ErrManagerUserDisabled
+	}
+	return user, nil
+}
+
+// loadIfNeed 第一次使用时加载用户
+func (m *managerUserManager) loadIfNeed() error {
+	if m.loaded.Load() {
+		return nil
+	}
+	return m.reload()
+}
+
+// reload 从存储用户的槽领导节点重新加载
+func (m *managerUserManager) reload() error {
+	users, err := m.getOrRequestUsers()
+	if err != nil {
+		return err
+	}
+	userMap := make(map[string]wkdb.ManagerUser, len(users))
+	for _, user := range users {
+		userMap[user.Username] = user
+	}
+	m.mu.Lock()
+	m.users = userMap
+	m.mu.Unlock()
+	m.loaded.Store(true)
+	return nil
+}
+
+func (m *managerUserManager) getOrRequestUsers() ([]wkdb.ManagerUser, error) {
+	nodeInfo, err := m.s.cluster.SlotLeaderNodeInfo(clusterstore.ManagerUserSlotId)
+	if err != nil {
+		return nil, err
+	}
+	if nodeInfo.Id == m.s.opts.Cluster.NodeId {
+		return m.s.store.GetManagerUsers()
+	}
+	timeoutCtx, cancel := context.WithTimeout(m.s.ctx, m.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	resp, err := m.s.cluster.RequestWithContext(timeoutCtx, nodeInfo.Id, "/wk/getManagerUsers", nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		return nil, fmt.Errorf("request manager users failed, status: %d err:%s", resp.Status, string(resp.Body))
+	}
+	var users managerUsersResp
+	if err = users.Unmarshal(resp.Body); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// notifyChanged 通知所有在线节点重新加载用户
+func (m *managerUserManager) notifyChanged() {
+	if err := m.reload(); err != nil {
+		m.Warn("reload manager users failed", zap.Error(err))
+	}
+	for _, node := range m.s.clusterServer.GetConfig().Nodes {
+		if node.Id == m.s.opts.Cluster.NodeId || !node.Online {
+			continue
+		}
+		go func(nodeId uint64) {
+			timeoutCtx, cancel := context.WithTimeout(m.s.ctx, m.s.opts.Cluster.ReqTimeout)
+			defer cancel()
+			resp, err := m.s.cluster.RequestWithContext(timeoutCtx, nodeId, "/wk/managerUsersChanged", nil)
+			if err != nil {
+				m.Warn("notify manager users changed failed", zap.Uint64("nodeId", nodeId), zap.Error(err))
+				return
+			}
+			if resp.Status != proto.StatusOK {
+				m.Warn("notify manager users changed failed", zap.Uint64("nodeId", nodeId), zap.String("err", string(resp.Body)))
+			}
+		}(node.Id)
+	}
+}
+
+// hashManagerPassword 生成密码的bcrypt值
+func hashManagerPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+type managerUsersResp []wkdb.ManagerUser
+
+func (u managerUsersResp) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(u)))
+	for _, user := range u {
+		data, err := user.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		enc.WriteBinary(data)
+	}
+	return enc.Bytes(), nil
+}
+
+func (u *managerUsersResp) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(count); i++ {
+		data, err := dec.Binary()
+		if err != nil {
+			return err
+		}
+		var user wkdb.ManagerUser
+		if err = user.Unmarshal(data); err != nil {
+			return err
+		}
+		*u = append(*u, user)
+	}
+	return nil
+}
@@ -2,6 +2,8 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -28,6 +30,7 @@ type ReactorChannelMessage struct {
 	IsSystem     bool // 是否是系统发送的消息
 	ReasonCode   wkproto.ReasonCode
 	Index        uint64
+	StreamSeq    uint64 // 流消息在流内的序号（频道领导投递时分配）
 }
 
 func (r *ReactorChannelMessage) Marshal() ([]byte, error) {
@@ -209,6 +212,14 @@ func (rs ReactorChannelMessageSet) Marshal() ([]byte, error) {
 		enc.WriteBinary(packetData)
 	}
 
+	// 消息下标和流序号追加在末尾，兼容老版本的解码
+	for _, r := range rs {
+		enc.WriteUint64(r.Index)
+	}
+	for _, r := range rs {
+		enc.WriteUint64(r.StreamSeq)
+	}
+
 	return enc.Bytes(), nil
 }
 
@@ -256,6 +267,24 @@ func (rs *ReactorChannelMessageSet) Unmarshal(data []byte) error {
 
 		*rs = append(*rs, r)
 	}
+
+	if dec.Len() == 0 { // 老版本没有消息下标
+		return nil
+	}
+	for i := 0; i < int(count); i++ {
+		if (*rs)[len(*rs)-int(count)+i].Index, err = dec.Uint64(); err != nil {
+			return err
+		}
+	}
+
+	if dec.Len() == 0 { // 老版本没有流序号
+		return nil
+	}
+	for i := 0; i < int(count); i++ {
+		if (*rs)[len(*rs)-int(count)+i].StreamSeq, err = dec.Uint64(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -270,6 +299,7 @@ type ReactorUserMessage struct {
 	OutBytes   []byte // 需要输出的字节
 	Index      uint64 // 消息下标
 
+	Compression string // 客户端在连接包扩展字段中请求的压缩算法（仅CONNECT包）
 }
 
 // 这个大小是不准确的，只是一个大概的值，目的是计算传输的数据量
@@ -299,6 +329,12 @@ func (m *ReactorUserMessage) MarshalWithEncoder(encoder *wkproto.Encoder) error
 		if err != nil {
 			return err
 		}
+		if m.Compression != "" && m.InPacket.GetFrameType() == wkproto.CONNECT { // 连接包的扩展字段跟随包数据一起转发
+			packetData, err = appendFrameExtension(packetData, encodeConnectExtension(m.Compression))
+			if err != nil {
+				return err
+			}
+		}
 	}
 	if len(packetData) > 0 {
 		encoder.WriteUint8(1) // 有包数据
@@ -338,6 +374,9 @@ func (m *ReactorUserMessage) UnmarshalWithDecoder(decoder *wkproto.Decoder) erro
 			return err
 		}
 		m.InPacket = packet
+		if connectPacket, ok := packet.(*wkproto.ConnectPacket); ok {
+			m.Compression = decodeConnectExtension(packetData, connectPacket)
+		}
 	} else {
 		frameType, err := decoder.Uint8()
 		if err != nil {
@@ -362,6 +401,8 @@ type ChannelAction struct {
 	Messages   []ReactorChannelMessage
 	LeaderId   uint64 // 频道领导节点ID
 
+	ChannelInfo *wkdb.ChannelInfo // 频道基础信息（初始化返回时有值）
+
 	UniqueNo string
 }
 
@@ -383,7 +424,7 @@ type MessageResp struct {
 	Expire       uint32             `json:"expire"`                // 消息过期时间
 	Timestamp    int32              `json:"timestamp"`             // 服务器消息时间戳(10位，到秒)
 	Payload      []byte             `json:"payload"`               // 消息内容
-	// Streams      []*StreamItemResp  `json:"streams,omitempty"`     // 消息流内容
+	Streams      []*StreamItemResp  `json:"streams,omitempty"`     // 消息流内容（已组装的流分片）
 }
 
 func (m *MessageResp) from(messageD wkdb.Message, s *Server) {
@@ -646,6 +687,51 @@ func (req clearConversationUnreadReq) Check() error {
 	return nil
 }
 
+// clearConversationHistoryReq 清空会话的历史消息（只对自己生效）
+type clearConversationHistoryReq struct {
+	UID         string `json:"uid"`
+	ChannelID   string `json:"channel_id"`
+	ChannelType uint8  `json:"channel_type"`
+	MessageSeq  uint64 `json:"message_seq"` // 清空至的消息序号（包含），0表示清空当前所有消息
+}
+
+func (req clearConversationHistoryReq) Check() error {
+	if req.UID == "" {
+		return errors.New("uid cannot be empty")
+	}
+	if req.ChannelID == "" || req.ChannelType == 0 {
+		return errors.New("channel_id or channel_type cannot be empty")
+	}
+	return nil
+}
+
+// 单次最多隐藏的消息数量
+const maxHideMessageCount = 1000
+
+// messageHideReq 删除消息（只对自己生效）
+type messageHideReq struct {
+	LoginUid    string  `json:"login_uid"`
+	ChannelId   string  `json:"channel_id"`
+	ChannelType uint8   `json:"channel_type"`
+	MessageIds  []int64 `json:"message_ids"`
+}
+
+func (req messageHideReq) Check() error {
+	if strings.TrimSpace(req.LoginUid) == "" {
+		return errors.New("login_uid不能为空！")
+	}
+	if strings.TrimSpace(req.ChannelId) == "" || req.ChannelType == 0 {
+		return errors.New("channel_id或channel_type不能为空！")
+	}
+	if len(req.MessageIds) == 0 {
+		return errors.New("message_ids不能为空！")
+	}
+	if len(req.MessageIds) > maxHideMessageCount {
+		return fmt.Errorf("message_ids不能超过%d个！", maxHideMessageCount)
+	}
+	return nil
+}
+
 type deleteChannelReq struct {
 	UID         string `json:"uid"`
 	ChannelID   string `json:"channel_id"`
@@ -689,16 +775,18 @@ func (c *channelReq) Unmarshal(data []byte) error {
 }
 
 type syncUserConversationResp struct {
-	ChannelId       string         `json:"channel_id"`         // 频道ID
-	ChannelType     uint8          `json:"channel_type"`       // 频道类型
-	Unread          int            `json:"unread"`             // 未读消息
-	Timestamp       int64          `json:"timestamp"`          // 最后一次会话时间
-	LastMsgSeq      uint32         `json:"last_msg_seq"`       // 最后一条消息seq
-	LastClientMsgNo string         `json:"last_client_msg_no"` // 最后一次消息客户端编号
-	OffsetMsgSeq    int64          `json:"offset_msg_seq"`     // 偏移位的消息seq
-	ReadedToMsgSeq  uint32         `json:"readed_to_msg_seq"`  // 已读至的消息seq
-	Version         int64          `json:"version"`            // 数据版本
-	Recents         []*MessageResp `json:"recents"`            // 最近N条消息
+	ChannelId        string         `json:"channel_id"`                   // 频道ID
+	ChannelType      uint8          `json:"channel_type"`                 // 频道类型
+	Unread           int            `json:"unread"`                       // 未读消息
+	Timestamp        int64          `json:"timestamp"`                    // 最后一次会话时间
+	LastMsgSeq       uint32         `json:"last_msg_seq"`                 // 最后一条消息seq
+	LastClientMsgNo  string         `json:"last_client_msg_no"`           // 最后一次消息客户端编号
+	OffsetMsgSeq     int64          `json:"offset_msg_seq"`               // 偏移位的消息seq
+	ReadedToMsgSeq   uint32         `json:"readed_to_msg_seq"`            // 已读至的消息seq
+	Version          int64          `json:"version"`                      // 数据版本
+	Recents          []*MessageResp `json:"recents"`                      // 最近N条消息
+	ClearedToSeq     uint64         `json:"cleared_to_seq,omitempty"`     // 清空至的消息序号（包含），多端同步清空记录
+	HiddenMessageIds []int64        `json:"hidden_message_ids,omitempty"` // 自己删除的消息ID，多端同步删除
 }
 
 func newSyncUserConversationResp(conversation wkdb.Conversation) *syncUserConversationResp {
@@ -726,9 +814,12 @@ type channelRecentMessageReq struct {
 }
 
 type channelRecentMessage struct {
-	ChannelId   string         `json:"channel_id"`
-	ChannelType uint8          `json:"channel_type"`
-	Messages    []*MessageResp `json:"messages"`
+	ChannelId         string         `json:"channel_id"`
+	ChannelType       uint8          `json:"channel_type"`
+	Messages          []*MessageResp `json:"messages"`
+	ClearedToSeq      uint64         `json:"cleared_to_seq,omitempty"`     // 用户清空至的消息序号
+	HiddenMessageIds  []int64        `json:"hidden_message_ids,omitempty"` // 最近消息范围内用户自己删除的消息ID
+	VisibilityVersion int64          `json:"visibility_version,omitempty"` // 用户最后一次删除或清空消息的时间（纳秒）
 }
 
 type MessageRespSlice []*MessageResp
@@ -756,15 +847,18 @@ func (r ChannelCreateReq) Check() error {
 	if IsSpecialChar(r.ChannelID) {
 		return errors.New("频道ID不能包含特殊字符！")
 	}
-	return nil
+	return r.ChannelInfoReq.check()
 }
 
 type subscriberAddReq struct {
-	ChannelId      string   `json:"channel_id"`      // 频道ID
-	ChannelType    uint8    `json:"channel_type"`    // 频道类型
-	Reset          int      `json:"reset"`           // 是否重置订阅者 （0.不重置 1.重置），选择重置，将删除原来的所有成员
-	TempSubscriber int      `json:"temp_subscriber"` //  是否是临时订阅者 (1. 是 0. 否)
-	Subscribers    []string `json:"subscribers"`     // 订阅者
+	ChannelId      string            `json:"channel_id"`      // 频道ID
+	ChannelType    uint8             `json:"channel_type"`    // 频道类型
+	Reset          int               `json:"reset"`           // 是否重置订阅者 （0.不重置 1.重置），选择重置，将删除原来的所有成员
+	TempSubscriber int               `json:"temp_subscriber"` //  是否是临时订阅者 (1. 是 0. 否)
+	Subscribers    []string          `json:"subscribers"`     // 订阅者
+	Role           int               `json:"role"`            // 新增订阅者的角色 0.成员 1.管理员 2.群主
+	JoinSource     string            `json:"join_source"`     // 新增订阅者的加入来源
+	Attributes     map[string]string `json:"attributes"`      // 新增订阅者的自定义属性
 }
 
 func (s subscriberAddReq) Check() error {
@@ -777,9 +871,158 @@ func (s subscriberAddReq) Check() error {
 	if stringArrayIsEmpty(s.Subscribers) {
 		return errors.New("订阅者不能为空！")
 	}
+	if err := checkMemberRole(s.Role, len(s.Subscribers)); err != nil {
+		return err
+	}
+	if err := checkMemberJoinSource(s.JoinSource); err != nil {
+		return err
+	}
+	return checkMemberAttributes(s.Attributes)
+}
+
+// 订阅者自定义属性的限制
+const (
+	memberAttributesMaxCount = 32   // 最多属性数量
+	memberAttributesMaxSize  = 4096 // 属性序列化后的最大字节数
+	memberJoinSourceMaxLen   = 64   // 加入来源的最大长度
+)
+
+func checkMemberRole(role int, count int) error {
+	if role < 0 || !wkdb.MemberRole(role).Valid() {
+		return errors.New("角色只能是0（成员）、1（管理员）、2（群主）！")
+	}
+	if wkdb.MemberRole(role) == wkdb.MemberRoleOwner && count > 1 {
+		return errors.New("群主只能设置一个！")
+	}
 	return nil
 }
 
+func checkMemberJoinSource(joinSource string) error {
+	if len(joinSource) > memberJoinSourceMaxLen {
+		return errors.New("加入来源长度不能超过64！")
+	}
+	return nil
+}
+
+func checkMemberAttributes(attributes map[string]string) error {
+	if len(attributes) > memberAttributesMaxCount {
+		return errors.New("自定义属性数量不能超过32个！")
+	}
+	size := 0
+	for k, v := range attributes {
+		if strings.TrimSpace(k) == "" {
+			return errors.New("自定义属性的key不能为空！")
+		}
+		size += len(k) + len(v)
+	}
+	if size > memberAttributesMaxSize {
+		return errors.New("自定义属性总长度不能超过4096！")
+	}
+	return nil
+}
+
+// subscriberUpdateReq 更新订阅者的角色、禁言等属性，字段为空表示不修改
+type subscriberUpdateReq struct {
+	ChannelId   string             `json:"channel_id"`             // 频道ID
+	ChannelType uint8              `json:"channel_type"`           // 频道类型
+	Uids        []string           `json:"uids"`                   // 需要更新的订阅者
+	Role        *int               `json:"role,omitempty"`         // 角色 0.成员 1.管理员 2.群主
+	MuteUntil   *int64             `json:"mute_until,omitempty"`   // 禁言截止时间（单位秒） 0表示解除禁言
+	MuteSeconds *int64             `json:"mute_seconds,omitempty"` // 禁言时长（单位秒），优先级低于mute_until
+	JoinSource  *string            `json:"join_source,omitempty"`  // 加入来源
+	Attributes  *map[string]string `json:"attributes,omitempty"`   // 自定义属性（整体替换）
+}
+
+func (s subscriberUpdateReq) Check() error {
+	if strings.TrimSpace(s.ChannelId) == "" {
+		return errors.New("频道ID不能为空！")
+	}
+	if s.ChannelType == 0 {
+		return errors.New("频道类型不能为0！")
+	}
+	if s.ChannelType == wkproto.ChannelTypePerson {
+		return errors.New("个人频道不支持设置订阅者属性！")
+	}
+	if stringArrayIsEmpty(s.Uids) {
+		return errors.New("uids不能为空！")
+	}
+	if s.Role != nil {
+		if err := checkMemberRole(*s.Role, len(s.Uids)); err != nil {
+			return err
+		}
+	}
+	if s.MuteUntil != nil && *s.MuteUntil < 0 {
+		return errors.New("mute_until不能小于0！")
+	}
+	if s.MuteSeconds != nil && *s.MuteSeconds < 0 {
+		return errors.New("mute_seconds不能小于0！")
+	}
+	if s.JoinSource != nil {
+		if err := checkMemberJoinSource(*s.JoinSource); err != nil {
+			return err
+		}
+	}
+	if s.Attributes != nil {
+		return checkMemberAttributes(*s.Attributes)
+	}
+	return nil
+}
+
+// checkOwner 设置群主时，一次只能指定一个订阅者且频道中不能已存在其他群主
+func (s subscriberUpdateReq) checkOwner(members []wkdb.Member) error {
+	if s.Role == nil || wkdb.MemberRole(*s.Role) != wkdb.MemberRoleOwner {
+		return nil
+	}
+	return checkSingleOwner(s.Uids, members)
+}
+
+// checkOwner 以群主角色添加订阅者时，一次只能添加一个且频道中不能已存在其他群主（重置订阅者时原成员会被移除，不需要检查）
+func (s subscriberAddReq) checkOwner(members []wkdb.Member) error {
+	if wkdb.MemberRole(s.Role) != wkdb.MemberRoleOwner {
+		return nil
+	}
+	if s.Reset == 1 {
+		members = nil
+	}
+	return checkSingleOwner(s.Subscribers, members)
+}
+
+// checkSingleOwner 一个频道只能有一个群主，转让群主需要先将原群主设置为其他角色
+func checkSingleOwner(uids []string, members []wkdb.Member) error {
+	if len(wkutil.RemoveRepeatedElement(uids)) > 1 {
+		return errors.New("群主只能设置一个！")
+	}
+	for _, member := range members {
+		if member.Role == wkdb.MemberRoleOwner && !wkutil.ArrayContains(uids, member.Uid) {
+			return fmt.Errorf("频道已存在群主[%s]，请先将原群主设置为其他角色！", member.Uid)
+		}
+	}
+	return nil
+}
+
+// apply 将修改应用到订阅者上
+func (s subscriberUpdateReq) apply(member *wkdb.Member, now time.Time) {
+	if s.Role != nil {
+		member.Role = wkdb.MemberRole(*s.Role)
+	}
+	if s.MuteUntil != nil {
+		member.MuteUntil = *s.MuteUntil
+	} else if s.MuteSeconds != nil {
+		if *s.MuteSeconds == 0 {
+			member.MuteUntil = 0
+		} else {
+			member.MuteUntil = now.Unix() + *s.MuteSeconds
+		}
+	}
+	if s.JoinSource != nil {
+		member.JoinSource = *s.JoinSource
+	}
+	if s.Attributes != nil {
+		member.Attributes = *s.Attributes
+	}
+	member.UpdatedAt = &now
+}
+
 type subscriberGetReq struct {
 	ChannelId   string `json:"channel_id"`
 	ChannelType uint8  `json:"channel_type"`
@@ -974,19 +1217,72 @@ type ChannelInfoReq struct {
 	Large       int    `json:"large"`        // 是否是超大群
 	Ban         int    `json:"ban"`          // 是否封禁频道（封禁后此频道所有人都将不能发消息，除了系统账号）
 	Disband     int    `json:"disband"`      // 是否解散频道
+	SendMode    int    `json:"send_mode"`    // 发言模式 0.所有订阅者都可以发言 1.只有群主和管理员可以发言
+	// 历史消息可见性 0.可以看到所有历史消息 1.只能看到加入之后的消息 2.只能看到加入前的最后N条消息以及加入之后的消息
+	HistoryVisibility   int `json:"history_visibility"`
+	HistoryVisibleCount int `json:"history_visible_count"` // 历史消息可见性为2时，加入前可见的消息数量N
+	E2ee                int `json:"e2ee"`                  // 是否开启端到端加密（开启后只接受客户端加密的消息，服务端不处理消息内容）
+	// 消息保留策略 0.使用频道类型或全局配置 -1.不限制
+	RetentionMaxAge   int64 `json:"retention_max_age"`   // 消息保留的最长时间（秒）
+	RetentionMaxCount int64 `json:"retention_max_count"` // 消息保留的最大数量
+	RetentionMaxBytes int64 `json:"retention_max_bytes"` // 消息保留的最大字节数（按消息内容计算）
+}
+
+func (c ChannelInfoReq) checkSendMode() error {
+	if c.SendMode != int(wkdb.SendModeAll) && c.SendMode != int(wkdb.SendModeAdmin) {
+		return errors.New("发言模式只能是0或1！")
+	}
+	return nil
+}
+
+func (c ChannelInfoReq) checkHistoryVisibility() error {
+	if c.HistoryVisibility < 0 || !wkdb.HistoryVisibility(c.HistoryVisibility).Valid() {
+		return errors.New("历史消息可见性只能是0、1或2！")
+	}
+	if c.HistoryVisibleCount < 0 || c.HistoryVisibleCount > math.MaxInt32 {
+		return errors.New("history_visible_count不合法！")
+	}
+	if wkdb.HistoryVisibility(c.HistoryVisibility) == wkdb.HistoryVisibilityLastN && c.HistoryVisibleCount == 0 {
+		return errors.New("历史消息可见性为2时，history_visible_count必须大于0！")
+	}
+	return nil
+}
+
+func (c ChannelInfoReq) checkRetention() error {
+	if c.RetentionMaxAge < -1 || c.RetentionMaxCount < -1 || c.RetentionMaxBytes < -1 {
+		return errors.New("消息保留策略只能是-1、0或正数！")
+	}
+	return nil
+}
+
+func (c ChannelInfoReq) check() error {
+	if err := c.checkSendMode(); err != nil {
+		return err
+	}
+	if err := c.checkRetention(); err != nil {
+		return err
+	}
+	return c.checkHistoryVisibility()
 }
 
 func (c ChannelInfoReq) ToChannelInfo() wkdb.ChannelInfo {
 	createdAt := time.Now()
 	updatedAt := time.Now()
 	return wkdb.ChannelInfo{
-		ChannelId:   c.ChannelID,
-		ChannelType: c.ChannelType,
-		Large:       c.Large == 1,
-		Ban:         c.Ban == 1,
-		Disband:     c.Disband == 1,
-		CreatedAt:   &createdAt,
-		UpdatedAt:   &updatedAt,
+		ChannelId:           c.ChannelID,
+		ChannelType:         c.ChannelType,
+		Large:               c.Large == 1,
+		Ban:                 c.Ban == 1,
+		Disband:             c.Disband == 1,
+		SendMode:            wkdb.SendMode(c.SendMode),
+		HistoryVisibility:   wkdb.HistoryVisibility(c.HistoryVisibility),
+		HistoryVisibleCount: uint32(c.HistoryVisibleCount),
+		E2ee:                c.E2ee == 1,
+		RetentionMaxAge:     c.RetentionMaxAge,
+		RetentionMaxCount:   c.RetentionMaxCount,
+		RetentionMaxBytes:   c.RetentionMaxBytes,
+		CreatedAt:           &createdAt,
+		UpdatedAt:           &updatedAt,
 	}
 }
 
@@ -1036,6 +1332,56 @@ func (a *allowSendReq) Marshal() ([]byte, error) {
 	return enc.Bytes(), nil
 }
 
+type purgeMessagesReq struct {
+	ChannelId   string // 频道ID
+	ChannelType uint8  // 频道类型
+	MessageSeq  uint64 // 清理到的消息seq（包含）
+}
+
+func (p *purgeMessagesReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if p.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if p.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if p.MessageSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *purgeMessagesReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(p.ChannelId)
+	enc.WriteUint8(p.ChannelType)
+	enc.WriteUint64(p.MessageSeq)
+	return enc.Bytes(), nil
+}
+
+type purgeMessagesResp struct {
+	LastMsgSeq uint64 // 副本最新的消息seq
+}
+
+func (p *purgeMessagesResp) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if p.LastMsgSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (p *purgeMessagesResp) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint64(p.LastMsgSeq)
+	return enc.Bytes(), nil
+}
+
 type reactorStreamMessage struct {
 }
 
@@ -1071,3 +1417,401 @@ type readyState struct {
 	willRetry  bool // 将要重试
 	retryTick  int  // 重试计时，超过一定tick数后，将会重试
 }
+
+// E2eeKeysUploadReq 上传设备公钥包（signal协议，二进制字段为base64编码）
+type E2eeKeysUploadReq struct {
+	UID            string              `json:"uid"`             // 用户uid
+	DeviceId       string              `json:"device_id"`       // 设备ID
+	RegistrationId uint32              `json:"registration_id"` // signal注册ID
+	IdentityKey    []byte              `json:"identity_key"`    // 身份公钥
+	SignedPrekey   E2eeSignedPrekeyReq `json:"signed_prekey"`   // 签名预共享公钥
+	OnetimePrekeys []E2eePrekeyReq     `json:"onetime_prekeys"` // 一次性预共享公钥（追加，相同key_id的覆盖）
+}
+
+type E2eeSignedPrekeyReq struct {
+	KeyId     uint32 `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"`
+}
+
+type E2eePrekeyReq struct {
+	KeyId     uint32 `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+}
+
+func (r E2eeKeysUploadReq) Check() error {
+	if strings.TrimSpace(r.UID) == "" {
+		return errors.New("uid不能为空！")
+	}
+	if strings.TrimSpace(r.DeviceId) == "" {
+		return errors.New("device_id不能为空！")
+	}
+	if len(r.IdentityKey) == 0 {
+		return errors.New("identity_key不能为空！")
+	}
+	if len(r.SignedPrekey.PublicKey) == 0 || len(r.SignedPrekey.Signature) == 0 {
+		return errors.New("signed_prekey不能为空！")
+	}
+	for _, prekey := range r.OnetimePrekeys {
+		if len(prekey.PublicKey) == 0 {
+			return errors.New("onetime_prekeys的public_key不能为空！")
+		}
+	}
+	return nil
+}
+
+func (r E2eeKeysUploadReq) toBundle(updatedAt int64) wkdb.E2eeKeyBundle {
+	return wkdb.E2eeKeyBundle{
+		Uid:            r.UID,
+		DeviceId:       r.DeviceId,
+		RegistrationId: r.RegistrationId,
+		IdentityKey:    r.IdentityKey,
+		SignedPrekey: wkdb.E2eeSignedPrekey{
+			KeyId:     r.SignedPrekey.KeyId,
+			PublicKey: r.SignedPrekey.PublicKey,
+			Signature: r.SignedPrekey.Signature,
+		},
+		UpdatedAt: updatedAt,
+	}
+}
+
+func (r E2eeKeysUploadReq) toOnetimePrekeys() []wkdb.E2eePrekey {
+	prekeys := make([]wkdb.E2eePrekey, 0, len(r.OnetimePrekeys))
+	for _, prekey := range r.OnetimePrekeys {
+		prekeys = append(prekeys, wkdb.E2eePrekey{
+			KeyId:     prekey.KeyId,
+			PublicKey: prekey.PublicKey,
+		})
+	}
+	return prekeys
+}
+
+// E2eeKeyBundleResp 设备公钥包
+type E2eeKeyBundleResp struct {
+	UID            string              `json:"uid"`
+	DeviceId       string              `json:"device_id"`
+	RegistrationId uint32              `json:"registration_id"`
+	IdentityKey    []byte              `json:"identity_key"`
+	SignedPrekey   E2eeSignedPrekeyReq `json:"signed_prekey"`
+	OnetimePrekey  *E2eePrekeyReq      `json:"onetime_prekey,omitempty"` // 一次性预共享公钥，已耗尽时为空
+	UpdatedAt      int64               `json:"updated_at"`
+}
+
+func newE2eeKeyBundleResp(bundle wkdb.E2eeKeyBundle, onetimePrekey *wkdb.E2eePrekey) *E2eeKeyBundleResp {
+	resp := &E2eeKeyBundleResp{
+		UID:            bundle.Uid,
+		DeviceId:       bundle.DeviceId,
+		RegistrationId: bundle.RegistrationId,
+		IdentityKey:    bundle.IdentityKey,
+		SignedPrekey: E2eeSignedPrekeyReq{
+			KeyId:     bundle.SignedPrekey.KeyId,
+			PublicKey: bundle.SignedPrekey.PublicKey,
+			Signature: bundle.SignedPrekey.Signature,
+		},
+		UpdatedAt: bundle.UpdatedAt,
+	}
+	if onetimePrekey != nil {
+		resp.OnetimePrekey = &E2eePrekeyReq{
+			KeyId:     onetimePrekey.KeyId,
+			PublicKey: onetimePrekey.PublicKey,
+		}
+	}
+	return resp
+}
+
+// E2eeKeyChangeType 设备公钥变更类型
+type E2eeKeyChangeType string
+
+const (
+	// E2eeKeyChangeTypeIdentity 新设备或身份公钥变更
+	E2eeKeyChangeTypeIdentity E2eeKeyChangeType = "identity"
+	// E2eeKeyChangeTypeRemove 设备公钥包被删除
+	E2eeKeyChangeTypeRemove E2eeKeyChangeType = "remove"
+)
+
+// CMDE2eeKeyChange 设备公钥变更的cmd消息
+const CMDE2eeKeyChange = "e2eeKeyChange"
+
+// E2eeKeyChangeNotify 设备公钥变更通知
+type E2eeKeyChangeNotify struct {
+	UID        string            `json:"uid"`
+	DeviceId   string            `json:"device_id"`
+	ChangeType E2eeKeyChangeType `json:"change_type"`
+	Timestamp  int64             `json:"timestamp"`
+}
+
+// PresenceStatus 用户的在线状态（用户所有设备的连接聚合后的状态）
+type PresenceStatus uint8
+
+const (
+	// PresenceStatusOffline 离线（没有已认证的连接）
+	PresenceStatusOffline PresenceStatus = iota
+	// PresenceStatusOnline 在线（至少有一个连接不处于离开状态）
+	PresenceStatusOnline
+	// PresenceStatusAway 离开（所有连接都处于离开状态，例如app切到了后台）
+	PresenceStatusAway
+)
+
+func (p PresenceStatus) String() string {
+	switch p {
+	case PresenceStatusOffline:
+		return "offline"
+	case PresenceStatusOnline:
+		return "online"
+	case PresenceStatusAway:
+		return "away"
+	}
+	return fmt.Sprintf("unknown[%d]", p)
+}
+
+// 最后在线时间的可见范围（存储在用户信息的LastSeenPrivacy，0表示未设置，按所有人可见处理）
+const (
+	// LastSeenPrivacyEveryone 所有人可见
+	LastSeenPrivacyEveryone uint8 = 1
+	// LastSeenPrivacyContacts 仅联系人可见（用户个人频道白名单内的用户）
+	LastSeenPrivacyContacts uint8 = 2
+	// LastSeenPrivacyNobody 任何人不可见
+	LastSeenPrivacyNobody uint8 = 3
+)
+
+func checkLastSeenPrivacy(privacy uint8) error {
+	if privacy < LastSeenPrivacyEveryone || privacy > LastSeenPrivacyNobody {
+		return fmt.Errorf("last_seen_privacy[%d] is invalid", privacy)
+	}
+	return nil
+}
+
+// CMDPresence 订阅的用户在线状态变化的cmd消息
+const CMDPresence = "presence"
+
+// PresenceInfo 用户的在线状态
+type PresenceInfo struct {
+	UID      string         `json:"uid"`
+	Status   PresenceStatus `json:"status"`              // 0.离线 1.在线 2.离开
+	LastSeen int64          `json:"last_seen,omitempty"` // 最后在线时间（10位时间戳），离线并且对查看者可见时才有值
+}
+
+// PresenceSubParam 客户端订阅在线状态的SUB包参数（SubPacket.Param）
+type PresenceSubParam struct {
+	UIDs   []string `json:"uids,omitempty"`   // 订阅或取消订阅的用户（SubPacket.ChannelID不为空时也会加入）
+	Status string   `json:"status,omitempty"` // 设置当前连接的状态 online 或 away，设置后忽略UIDs
+}
+
+// PresenceQueryReq 查询用户在线状态
+type PresenceQueryReq struct {
+	ViewerUID string   `json:"viewer_uid"` // 查看者，用于判断最后在线时间是否可见，为空表示不做可见范围的限制（服务端查询）
+	UIDs      []string `json:"uids"`
+}
+
+func (p PresenceQueryReq) Check() error {
+	if len(p.UIDs) == 0 {
+		return errors.New("uids不能为空！")
+	}
+	return nil
+}
+
+// PresencePrivacyReq 设置用户最后在线时间的可见范围
+type PresencePrivacyReq struct {
+	UID             string `json:"uid"`
+	LastSeenPrivacy uint8  `json:"last_seen_privacy"` // 1.所有人可见 2.仅联系人可见 3.任何人不可见
+}
+
+func (p PresencePrivacyReq) Check() error {
+	if strings.TrimSpace(p.UID) == "" {
+		return errors.New("uid不能为空！")
+	}
+	return checkLastSeenPrivacy(p.LastSeenPrivacy)
+}
+
+// 用户在线状态的变化（用户的领导节点广播给所有节点）
+type presenceChange struct {
+	uid             string
+	status          PresenceStatus
+	version         int64 // 状态版本（状态变化时的纳秒时间戳），用于丢弃乱序到达的旧状态
+	lastSeen        int64 // 离线时的最后在线时间（10位时间戳）
+	lastSeenPrivacy uint8
+}
+
+func (p *presenceChange) Marshal() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(p.uid)
+	enc.WriteUint8(uint8(p.status))
+	enc.WriteInt64(p.version)
+	enc.WriteInt64(p.lastSeen)
+	enc.WriteUint8(p.lastSeenPrivacy)
+	return enc.Bytes()
+}
+
+func (p *presenceChange) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if p.uid, err = dec.String(); err != nil {
+		return err
+	}
+	var status uint8
+	if status, err = dec.Uint8(); err != nil {
+		return err
+	}
+	p.status = PresenceStatus(status)
+	if p.version, err = dec.Int64(); err != nil {
+		return err
+	}
+	if p.lastSeen, err = dec.Int64(); err != nil {
+		return err
+	}
+	if p.lastSeenPrivacy, err = dec.Uint8(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 连接离开状态的变化（连接所在节点通知用户的领导节点）
+type presenceAwayReq struct {
+	uid    string
+	nodeId uint64 // 连接所在节点
+	connId int64  // 连接在所在节点的id
+	away   bool
+}
+
+func (p *presenceAwayReq) Marshal() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(p.uid)
+	enc.WriteUint64(p.nodeId)
+	enc.WriteInt64(p.connId)
+	enc.WriteUint8(wkutil.BoolToUint8(p.away))
+	return enc.Bytes()
+}
+
+func (p *presenceAwayReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if p.uid, err = dec.String(); err != nil {
+		return err
+	}
+	if p.nodeId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if p.connId, err = dec.Int64(); err != nil {
+		return err
+	}
+	var away uint8
+	if away, err = dec.Uint8(); err != nil {
+		return err
+	}
+	p.away = wkutil.Uint8ToBool(away)
+	return nil
+}
+
+// 查询最后在线时间对哪些查看者可见（联系人可见时需要到用户的领导节点判断）
+type presenceVisibleReq struct {
+	uid     string
+	viewers []string
+}
+
+func (p *presenceVisibleReq) Marshal() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(p.uid)
+	enc.WriteUint32(uint32(len(p.viewers)))
+	for _, viewer := range p.viewers {
+		enc.WriteString(viewer)
+	}
+	return enc.Bytes()
+}
+
+func (p *presenceVisibleReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if p.uid, err = dec.String(); err != nil {
+		return err
+	}
+	var count uint32
+	if count, err = dec.Uint32(); err != nil {
+		return err
+	}
+	p.viewers = make([]string, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var viewer string
+		if viewer, err = dec.String(); err != nil {
+			return err
+		}
+		p.viewers = append(p.viewers, viewer)
+	}
+	return nil
+}
+
+// 临时信号的类型
+const (
+	// EphemeralTypeTyping 正在输入
+	EphemeralTypeTyping = "typing"
+	// EphemeralTypeRecording 正在录音
+	EphemeralTypeRecording = "recording"
+	// EphemeralTypeViewing 正在查看
+	EphemeralTypeViewing = "viewing"
+)
+
+// EphemeralPayload 临时信号的内容（SendPacket.Payload），可以携带其他字段，服务端原样转发
+type EphemeralPayload struct {
+	Type string `json:"type"` // typing.正在输入 recording.正在录音 viewing.正在查看
+}
+
+func (e EphemeralPayload) Check() error {
+	switch e.Type {
+	case EphemeralTypeTyping, EphemeralTypeRecording, EphemeralTypeViewing:
+		return nil
+	}
+	return fmt.Errorf("ephemeral type[%s] is invalid", e.Type)
+}
+
+// 临时信号（发送者所在节点转发给频道领导节点，频道领导节点再转发给接收者所在节点）
+type ephemeralSignal struct {
+	fromUid      string
+	fromDeviceId string
+	channelId    string // 频道id（个人频道为接收者的uid）
+	channelType  uint8
+	tagKey       string // 接收者tag（领导节点转发给接收者所在节点时才有值）
+	leaderId     uint64 // 频道领导节点（领导节点转发给接收者所在节点时才有值）
+	payload      []byte
+}
+
+func (e *ephemeralSignal) Marshal() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(e.fromUid)
+	enc.WriteString(e.fromDeviceId)
+	enc.WriteString(e.channelId)
+	enc.WriteUint8(e.channelType)
+	enc.WriteString(e.tagKey)
+	enc.WriteUint64(e.leaderId)
+	enc.WriteBytes(e.payload)
+	return enc.Bytes()
+}
+
+func (e *ephemeralSignal) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if e.fromUid, err = dec.String(); err != nil {
+		return err
+	}
+	if e.fromDeviceId, err = dec.String(); err != nil {
+		return err
+	}
+	if e.channelId, err = dec.String(); err != nil {
+		return err
+	}
+	if e.channelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if e.tagKey, err = dec.String(); err != nil {
+		return err
+	}
+	if e.leaderId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if e.payload, err = dec.BinaryAll(); err != nil {
+		return err
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package server
 
 import (
 	"testing"
+	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,3 +20,74 @@ func TestSubscriberGetRespMarshal(t *testing.T) {
 	assert.Equal(t, len(resp), len(resp1))
 
 }
+
+func TestSubscriberUpdateReq(t *testing.T) {
+	role := int(wkdb.MemberRoleOwner)
+	req := subscriberUpdateReq{
+		ChannelId:   "g1",
+		ChannelType: 2,
+		Uids:        []string{"u1", "u2"},
+		Role:        &role,
+	}
+	assert.Error(t, req.Check()) // 群主只能设置一个
+
+	role = int(wkdb.MemberRoleAdmin)
+	muteSeconds := int64(60)
+	attributes := map[string]string{"nickname": "n1"}
+	req.MuteSeconds = &muteSeconds
+	req.Attributes = &attributes
+	assert.NoError(t, req.Check())
+
+	now := time.Now()
+	member := wkdb.Member{Uid: "u1", JoinSource: "invite"}
+	req.apply(&member, now)
+	assert.Equal(t, wkdb.MemberRoleAdmin, member.Role)
+	assert.Equal(t, now.Unix()+60, member.MuteUntil)
+	assert.Equal(t, "invite", member.JoinSource)
+	assert.Equal(t, "n1", member.Attributes["nickname"])
+	assert.True(t, member.IsMuted(now))
+
+	// mute_until优先于mute_seconds
+	muteUntil := int64(0)
+	req.MuteUntil = &muteUntil
+	req.apply(&member, now)
+	assert.False(t, member.IsMuted(now))
+}
+
+func TestSubscriberUpdateReqCheckOwner(t *testing.T) {
+	members := []wkdb.Member{
+		{Uid: "u1", Role: wkdb.MemberRoleOwner},
+		{Uid: "u2", Role: wkdb.MemberRoleMember},
+	}
+	role := int(wkdb.MemberRoleOwner)
+	req := subscriberUpdateReq{Uids: []string{"u2"}, Role: &role}
+	assert.Error(t, req.checkOwner(members)) // 已存在其他群主
+
+	req.Uids = []string{"u1"}
+	assert.NoError(t, req.checkOwner(members)) // 更新的是群主自己
+
+	req.Uids = []string{"u1", "u2"}
+	assert.Error(t, req.checkOwner(members)) // 一次设置多个群主
+	assert.Error(t, req.checkOwner(nil))
+
+	role = int(wkdb.MemberRoleAdmin)
+	req.Uids = []string{"u2"}
+	assert.NoError(t, req.checkOwner(members)) // 不是设置群主
+}
+
+func TestSubscriberAddReqCheckOwner(t *testing.T) {
+	members := []wkdb.Member{
+		{Uid: "u1", Role: wkdb.MemberRoleOwner},
+	}
+	req := subscriberAddReq{Subscribers: []string{"u2"}, Role: int(wkdb.MemberRoleOwner)}
+	assert.Error(t, req.checkOwner(members)) // 已存在其他群主
+
+	req.Reset = 1
+	assert.NoError(t, req.checkOwner(members)) // 重置后原群主会被移除
+
+	req.Subscribers = []string{"u2", "u3"}
+	assert.Error(t, req.checkOwner(nil)) // 一次添加多个群主
+
+	req.Role = int(wkdb.MemberRoleMember)
+	assert.NoError(t, req.checkOwner(members))
+}
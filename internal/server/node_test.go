@@ -17,6 +17,8 @@ func TestChannelMessagesSetMarshal(t *testing.T) {
 			ReactorChannelMessage{
 				MessageId:  1,
 				FromConnId: 1,
+				Index:      10,
+				StreamSeq:  3,
 				FromUid:    "test",
 				SendPacket: &wkproto.SendPacket{
 					ChannelID:   "test",
@@ -33,4 +35,6 @@ func TestChannelMessagesSetMarshal(t *testing.T) {
 	err = channelMessages.Unmarshal(data)
 	assert.Nil(t, err)
 	assert.Equal(t, 1, len(channelMessages))
+	assert.Equal(t, uint64(10), channelMessages[0].Messages[0].Index)
+	assert.Equal(t, uint64(3), channelMessages[0].Messages[0].StreamSeq)
 }
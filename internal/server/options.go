@@ -7,8 +7,10 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/auth"
@@ -49,6 +51,9 @@ const (
 	RoleProxy   Role = "proxy"
 )
 
+// 默认的jwt secret，使用默认值时集群接口不接受管理端的jwt
+const defaultJwtSecret = "secret_wukongim"
+
 type Options struct {
 	vp          *viper.Viper // 内部配置对象
 	Mode        Mode         // 模式 debug 测试 release 正式 bench 压力测试
@@ -78,8 +83,10 @@ type Options struct {
 		}
 	}
 	Manager struct {
-		On   bool   // 是否开启监控
-		Addr string // 监控地址 默认为 0.0.0.0:5300
+		On                  bool              // 是否开启监控
+		Addr                string            // 监控地址 默认为 0.0.0.0:5300
+		UserRefreshInterval time.Duration     // 各节点定时刷新管理端用户缓存的间隔
+		OIDC                ManagerOIDCConfig // 管理端OIDC登录
 	}
 	// demo
 	Demo struct {
@@ -187,6 +194,12 @@ type Options struct {
 		SlotReactorSubCount    int // 槽reactor sub的数量
 
 		PongMaxTick int // 节点超过多少tick没有回应心跳就认为是掉线
+
+		FollowerReadOn   bool          // 是否开启追随者一致性读（默认关闭），开启后频道的消息查询等读请求在本节点副本追上领导的提交下标后直接由本节点处理
+		ReadIndexTimeout time.Duration // 追随者一致性读等待的超时时间，超时则转发给领导处理
+
+		ConsistencyCheckInterval time.Duration // 后台副本一致性校验的间隔，为0则关闭后台校验
+		ConsistencyCheckWindow   uint64        // 后台校验频道消息时，只校验每个频道最新的多少条消息
 	}
 
 	Trace struct {
@@ -241,6 +254,84 @@ type Options struct {
 
 	Auth auth.AuthConfig // 认证配置
 
+	ApiKey struct { // http api的访问密钥
+		On              bool          // 是否强制http api使用api key（或ManagerToken）认证
+		RefreshInterval time.Duration // 各节点定时刷新api key缓存的间隔
+	}
+
+	Audit struct { // 审计日志
+		On            bool          // 是否开启审计日志
+		WebhookOn     bool          // 是否通过webhook导出审计日志（事件为audit.log，需要配置webhook地址）
+		FlushInterval time.Duration // 审计日志批量提交的间隔
+		FlushCount    int           // 每次批量提交的最大数量
+		QueueSize     int           // 待提交审计日志的队列大小
+	}
+
+	Compression struct { // 负载压缩，减少移动网络下的流量
+		WSOn       bool     // websocket是否开启permessage-deflate协商
+		On         bool     // 是否允许tcp客户端在CONNECT中协商负载压缩
+		Algorithms []string // 服务端支持的压缩算法（zstd, snappy），客户端请求的算法不在其中则不压缩
+		Threshold  int      // 负载（websocket为消息）达到多少字节才压缩
+	}
+
+	Stream struct { // 流消息
+		StoreOn            bool          // 是否存储流消息的分片，存储后可以通过/stream/chunks获取，同步消息时也会带上已组装的流
+		CatchUpOn          bool          // 是否给重连的订阅者补发进行中的流消息分片
+		CatchUpMaxSize     int           // 每条流在节点上缓存的分片最大字节数，超过后不再缓存（客户端需要通过/stream/chunks获取）
+		CatchUpIdleTimeout time.Duration // 流多久没有新分片就不再补发
+	}
+
+	Presence struct { // 在线状态订阅
+		On                bool // 是否允许客户端通过长连接（SUB包）订阅用户的在线状态
+		SubscribeMaxCount int  // 每个连接最多订阅多少个用户的在线状态
+	}
+
+	Ephemeral struct { // 临时信号（正在输入、正在录音、正在查看），不存储、不计入最近会话、不推送webhook，只投递给在线的订阅者
+		On             bool          // 是否开启临时信号
+		Interval       time.Duration // 同一发送者在同一频道内转发信号的最小间隔，间隔内的信号合并为最新的一个
+		RepeatInterval time.Duration // 相同的信号在此时间内不重复转发（例如客户端每次按键都发送正在输入）
+		MaxPerSecond   int           // 每个发送者每秒最多发送多少个信号，超过的返回速率限制
+		MaxPayloadSize int           // 信号内容的最大字节数
+	}
+
+	Retention struct { // 消息保留策略，频道的策略优先于频道类型的策略，频道类型的策略优先于全局策略
+		On             bool                      // 是否开启过期消息清理
+		CheckInterval  time.Duration             // 多久检查一次频道的过期消息
+		PurgeBatchSize int                       // 每批清理的最大消息数量
+		MaxAge         time.Duration             // 消息保留的最长时间 0表示不限制
+		MaxCount       int64                     // 每个频道保留的最大消息数量 0表示不限制
+		MaxBytes       int64                     // 每个频道保留的最大消息字节数（按消息内容计算） 0表示不限制
+		ChannelTypes   map[uint8]RetentionPolicy // 频道类型的消息保留策略
+	}
+
+	ColdStorage struct { // 冷存储，频道较早的消息打包成消息段转存到对象存储，加载消息时透明获取
+		On              bool          // 是否开启冷存储
+		Type            string        // 存储类型 local: 本地目录 s3: S3兼容的对象存储
+		Dir             string        // 本地目录（Type为local时有效），默认为数据目录下的cold
+		S3              S3Config      // S3兼容的对象存储配置（Type为s3时有效）
+		OffloadAge      time.Duration // 消息超过多久转存到冷存储 0表示不按时间转存
+		KeepCount       int64         // 每个频道本地至少保留的最新消息数量，OffloadAge为0时超过此数量的消息转存到冷存储 0表示不限制
+		SegmentMaxCount int           // 每个消息段的最大消息数量
+		CacheCount      int           // 内存中缓存的消息段数量
+		CheckInterval   time.Duration // 多久检查一次需要转存的频道
+	}
+
+	Encryption struct { // 静态数据加密（消息、用户、频道等数据库，槽位日志以及分布式配置）
+		On             bool   // 是否开启加密
+		KeyFile        string // 密钥文件路径，不存在时自动生成，默认为数据目录下的encryption.key（在数据目录下时必须配置MasterKey）
+		MasterKey      string // 主密钥（base64或hex编码的32字节），不为空时密钥文件中的密钥由主密钥加密
+		MigrateOnStart bool   // 启动时重写数据目录下的明文文件以及不是当前密钥加密的文件
+	}
+
+	IPAccess struct { // IP访问控制，Deny优先于Allow，Allow为空表示不限制
+		TrustedProxies []string       // 可信代理的CIDR列表，只有直连地址为可信代理时才读取X-Forwarded-For/X-Real-IP作为http请求的客户端IP
+		TCP            IPAccessConfig // tcp长连接
+		WS             IPAccessConfig // websocket长连接
+		WSS            IPAccessConfig // websocket(tls)长连接
+		API            IPAccessConfig // http api
+		Manager        IPAccessConfig // 管理端
+	}
+
 	Jwt struct {
 		Secret string        // jwt secret
 		Expire time.Duration // jwt expire
@@ -249,6 +340,9 @@ type Options struct {
 	PprofOn          bool        // 是否开启pprof
 	OldV1Api         string      //旧v1版本的api地址，如果不为空则开启数据迁移任务，将v1的数据迁移到v2
 	MigrateStartStep MigrateStep // 从那步开始迁移，默认顺序是 message,user,channel
+
+	startSettings map[string]string        // 启动时读取到的配置项（扁平化），重新加载配置时用来判断哪些配置项发生了变化
+	hot           *atomic.Pointer[Options] // 热更新后的配置快照，见Hot()（指针，复制配置作为快照时共享）
 }
 
 type MigrateStep string
@@ -268,6 +362,7 @@ func NewOptions(op ...Option) *Options {
 		panic(err)
 	}
 	opts := &Options{
+		hot:                  &atomic.Pointer[Options]{},
 		Proto:                wkproto.New(),
 		HandlePoolSize:       2048,
 		Version:              version.Version,
@@ -383,11 +478,19 @@ func NewOptions(op ...Option) *Options {
 			MsgNotifyEventRetryMaxCount: 5,
 		},
 		Manager: struct {
-			On   bool
-			Addr string
+			On                  bool
+			Addr                string
+			UserRefreshInterval time.Duration
+			OIDC                ManagerOIDCConfig
 		}{
-			On:   true,
-			Addr: "0.0.0.0:5300",
+			On:                  true,
+			Addr:                "0.0.0.0:5300",
+			UserRefreshInterval: time.Minute,
+			OIDC: ManagerOIDCConfig{
+				Scopes:        []string{"openid", "profile", "email"},
+				UsernameClaim: "email",
+				RoleClaim:     "groups",
+			},
 		},
 		Demo: struct {
 			On   bool
@@ -414,6 +517,11 @@ func NewOptions(op ...Option) *Options {
 			ChannelReactorSubCount int
 			SlotReactorSubCount    int
 			PongMaxTick            int
+			FollowerReadOn         bool
+			ReadIndexTimeout       time.Duration
+
+			ConsistencyCheckInterval time.Duration
+			ConsistencyCheckWindow   uint64
 		}{
 			NodeId:                 1001,
 			Addr:                   "tcp://0.0.0.0:11110",
@@ -429,6 +537,11 @@ func NewOptions(op ...Option) *Options {
 			ChannelReactorSubCount: 64,
 			SlotReactorSubCount:    64,
 			PongMaxTick:            30,
+			FollowerReadOn:         false,
+			ReadIndexTimeout:       time.Millisecond * 500,
+
+			ConsistencyCheckInterval: time.Hour,
+			ConsistencyCheckWindow:   1000,
 		},
 		Trace: struct {
 			ServiceName      string
@@ -535,9 +648,112 @@ func NewOptions(op ...Option) *Options {
 			Issuer string
 		}{
 			Expire: time.Hour * 24 * 30,
-			Secret: "secret_wukongim",
+			Secret: defaultJwtSecret,
 			Issuer: "wukongim",
 		},
+		ApiKey: struct {
+			On              bool
+			RefreshInterval time.Duration
+		}{
+			On:              false,
+			RefreshInterval: time.Minute,
+		},
+		Audit: struct {
+			On            bool
+			WebhookOn     bool
+			FlushInterval time.Duration
+			FlushCount    int
+			QueueSize     int
+		}{
+			On:            true,
+			WebhookOn:     false,
+			FlushInterval: time.Millisecond * 500,
+			FlushCount:    100,
+			QueueSize:     10000,
+		},
+		Compression: struct {
+			WSOn       bool
+			On         bool
+			Algorithms []string
+			Threshold  int
+		}{
+			WSOn:       true,
+			On:         true,
+			Algorithms: []string{wkutil.CompressionZstd, wkutil.CompressionSnappy},
+			Threshold:  512,
+		},
+		Stream: struct {
+			StoreOn            bool
+			CatchUpOn          bool
+			CatchUpMaxSize     int
+			CatchUpIdleTimeout time.Duration
+		}{
+			StoreOn:            true,
+			CatchUpOn:          true,
+			CatchUpMaxSize:     1024 * 1024,
+			CatchUpIdleTimeout: time.Minute * 5,
+		},
+		Presence: struct {
+			On                bool
+			SubscribeMaxCount int
+		}{
+			On:                true,
+			SubscribeMaxCount: 5000,
+		},
+		Ephemeral: struct {
+			On             bool
+			Interval       time.Duration
+			RepeatInterval time.Duration
+			MaxPerSecond   int
+			MaxPayloadSize int
+		}{
+			On:             true,
+			Interval:       time.Second,
+			RepeatInterval: time.Second * 3,
+			MaxPerSecond:   20,
+			MaxPayloadSize: 512,
+		},
+		Retention: struct {
+			On             bool
+			CheckInterval  time.Duration
+			PurgeBatchSize int
+			MaxAge         time.Duration
+			MaxCount       int64
+			MaxBytes       int64
+			ChannelTypes   map[uint8]RetentionPolicy
+		}{
+			On:             true,
+			CheckInterval:  time.Minute * 10,
+			PurgeBatchSize: 1000,
+			ChannelTypes:   map[uint8]RetentionPolicy{},
+		},
+		ColdStorage: struct {
+			On              bool
+			Type            string
+			Dir             string
+			S3              S3Config
+			OffloadAge      time.Duration
+			KeepCount       int64
+			SegmentMaxCount int
+			CacheCount      int
+			CheckInterval   time.Duration
+		}{
+			On:              false,
+			Type:            ColdStorageTypeLocal,
+			OffloadAge:      time.Hour * 24 * 30,
+			KeepCount:       10000,
+			SegmentMaxCount: 1000,
+			CacheCount:      64,
+			CheckInterval:   time.Minute * 30,
+		},
+		Encryption: struct {
+			On             bool
+			KeyFile        string
+			MasterKey      string
+			MigrateOnStart bool
+		}{
+			On: false,
+		},
 		MigrateStartStep: MigrateStepMessage,
 	}
 
@@ -562,6 +778,7 @@ func GetHomeDir() (string, error) {
 
 func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.vp = vp
+	o.startSettings = flattenSettings("", vp.AllSettings())
 	// o.ID = o.getInt64("id", o.ID)
 
 	o.RootDir = o.getString("rootDir", o.RootDir)
@@ -595,6 +812,8 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 
 	o.Manager.On = o.getBool("manager.on", o.Manager.On)
 	o.Manager.Addr = o.getString("manager.addr", o.Manager.Addr)
+	o.Manager.UserRefreshInterval = o.getDuration("manager.userRefreshInterval", o.Manager.UserRefreshInterval)
+	o.configureManagerOIDC()
 
 	o.Demo.On = o.getBool("demo.on", o.Demo.On)
 	o.Demo.Addr = o.getString("demo.addr", o.Demo.Addr)
@@ -731,6 +950,10 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 	o.Cluster.ChannelReplicaCount = o.getInt("cluster.channelReplicaCount", o.Cluster.ChannelReplicaCount)
 	o.Cluster.ServerAddr = o.getString("cluster.serverAddr", o.Cluster.ServerAddr)
 	o.Cluster.PongMaxTick = o.getInt("cluster.pongMaxTick", o.Cluster.PongMaxTick)
+	o.Cluster.FollowerReadOn = o.getBool("cluster.followerReadOn", o.Cluster.FollowerReadOn)
+	o.Cluster.ReadIndexTimeout = o.getDuration("cluster.readIndexTimeout", o.Cluster.ReadIndexTimeout)
+	o.Cluster.ConsistencyCheckInterval = o.getDuration("cluster.consistencyCheckInterval", o.Cluster.ConsistencyCheckInterval)
+	o.Cluster.ConsistencyCheckWindow = o.getUint64("cluster.consistencyCheckWindow", o.Cluster.ConsistencyCheckWindow)
 
 	o.Cluster.ReqTimeout = o.getDuration("cluster.reqTimeout", o.Cluster.ReqTimeout)
 	o.Cluster.Seed = o.getString("cluster.seed", o.Cluster.Seed)
@@ -808,6 +1031,81 @@ func (o *Options) ConfigureWithViper(vp *viper.Viper) {
 
 	// =================== auth ===================
 	o.configureAuth()
+
+	// =================== api key ===================
+	o.ApiKey.On = o.getBool("apiKey.on", o.ApiKey.On)
+	o.ApiKey.RefreshInterval = o.getDuration("apiKey.refreshInterval", o.ApiKey.RefreshInterval)
+
+	// =================== audit ===================
+	o.Audit.On = o.getBool("audit.on", o.Audit.On)
+	o.Audit.WebhookOn = o.getBool("audit.webhookOn", o.Audit.WebhookOn)
+	o.Audit.FlushInterval = o.getDuration("audit.flushInterval", o.Audit.FlushInterval)
+	o.Audit.FlushCount = o.getInt("audit.flushCount", o.Audit.FlushCount)
+	o.Audit.QueueSize = o.getInt("audit.queueSize", o.Audit.QueueSize)
+
+	// =================== compression ===================
+	o.Compression.WSOn = o.getBool("compression.wsOn", o.Compression.WSOn)
+	o.Compression.On = o.getBool("compression.on", o.Compression.On)
+	if algorithms := o.getStringSlice("compression.algorithms"); len(algorithms) > 0 {
+		o.Compression.Algorithms = algorithms
+	}
+	o.Compression.Threshold = o.getInt("compression.threshold", o.Compression.Threshold)
+
+	// =================== stream ===================
+	o.Stream.StoreOn = o.getBool("stream.storeOn", o.Stream.StoreOn)
+	o.Stream.CatchUpOn = o.getBool("stream.catchUpOn", o.Stream.CatchUpOn)
+	o.Stream.CatchUpMaxSize = o.getInt("stream.catchUpMaxSize", o.Stream.CatchUpMaxSize)
+	o.Stream.CatchUpIdleTimeout = o.getDuration("stream.catchUpIdleTimeout", o.Stream.CatchUpIdleTimeout)
+
+	// =================== presence ===================
+	o.Presence.On = o.getBool("presence.on", o.Presence.On)
+	o.Presence.SubscribeMaxCount = o.getInt("presence.subscribeMaxCount", o.Presence.SubscribeMaxCount)
+
+	// =================== ephemeral ===================
+	o.Ephemeral.On = o.getBool("ephemeral.on", o.Ephemeral.On)
+	o.Ephemeral.Interval = o.getDuration("ephemeral.interval", o.Ephemeral.Interval)
+	o.Ephemeral.RepeatInterval = o.getDuration("ephemeral.repeatInterval", o.Ephemeral.RepeatInterval)
+	o.Ephemeral.MaxPerSecond = o.getInt("ephemeral.maxPerSecond", o.Ephemeral.MaxPerSecond)
+	o.Ephemeral.MaxPayloadSize = o.getInt("ephemeral.maxPayloadSize", o.Ephemeral.MaxPayloadSize)
+
+	// =================== retention ===================
+	o.Retention.On = o.getBool("retention.on", o.Retention.On)
+	o.Retention.CheckInterval = o.getDuration("retention.checkInterval", o.Retention.CheckInterval)
+	o.Retention.PurgeBatchSize = o.getInt("retention.purgeBatchSize", o.Retention.PurgeBatchSize)
+	o.Retention.MaxAge = o.getDuration("retention.maxAge", o.Retention.MaxAge)
+	o.Retention.MaxCount = o.getInt64("retention.maxCount", o.Retention.MaxCount)
+	o.Retention.MaxBytes = o.getInt64("retention.maxBytes", o.Retention.MaxBytes)
+	o.configureRetentionChannelTypes()
+
+	o.ColdStorage.On = o.getBool("coldStorage.on", o.ColdStorage.On)
+	o.ColdStorage.Type = o.getString("coldStorage.type", o.ColdStorage.Type)
+	o.ColdStorage.Dir = o.getString("coldStorage.dir", o.ColdStorage.Dir)
+	if strings.TrimSpace(o.ColdStorage.Dir) == "" {
+		o.ColdStorage.Dir = filepath.Join(o.DataDir, "cold")
+	}
+	o.ColdStorage.S3.Endpoint = o.getString("coldStorage.s3.endpoint", o.ColdStorage.S3.Endpoint)
+	o.ColdStorage.S3.Region = o.getString("coldStorage.s3.region", o.ColdStorage.S3.Region)
+	o.ColdStorage.S3.Bucket = o.getString("coldStorage.s3.bucket", o.ColdStorage.S3.Bucket)
+	o.ColdStorage.S3.Prefix = o.getString("coldStorage.s3.prefix", o.ColdStorage.S3.Prefix)
+	o.ColdStorage.S3.AccessKey = o.getString("coldStorage.s3.accessKey", o.ColdStorage.S3.AccessKey)
+	o.ColdStorage.S3.SecretKey = o.getString("coldStorage.s3.secretKey", o.ColdStorage.S3.SecretKey)
+	o.ColdStorage.S3.PathStyle = o.getBool("coldStorage.s3.pathStyle", o.ColdStorage.S3.PathStyle)
+	o.ColdStorage.OffloadAge = o.getDuration("coldStorage.offloadAge", o.ColdStorage.OffloadAge)
+	o.ColdStorage.KeepCount = o.getInt64("coldStorage.keepCount", o.ColdStorage.KeepCount)
+	o.ColdStorage.SegmentMaxCount = o.getInt("coldStorage.segmentMaxCount", o.ColdStorage.SegmentMaxCount)
+	o.ColdStorage.CacheCount = o.getInt("coldStorage.cacheCount", o.ColdStorage.CacheCount)
+	o.ColdStorage.CheckInterval = o.getDuration("coldStorage.checkInterval", o.ColdStorage.CheckInterval)
+
+	o.Encryption.On = o.getBool("encryption.on", o.Encryption.On)
+	o.Encryption.KeyFile = o.getString("encryption.keyFile", o.Encryption.KeyFile)
+	if strings.TrimSpace(o.Encryption.KeyFile) == "" {
+		o.Encryption.KeyFile = filepath.Join(o.DataDir, "encryption.key")
+	}
+	o.Encryption.MasterKey = o.getString("encryption.masterKey", o.Encryption.MasterKey)
+	o.Encryption.MigrateOnStart = o.getBool("encryption.migrateOnStart", o.Encryption.MigrateOnStart)
+
+	// =================== ip access ===================
+	o.configureIPAccess()
 	o.DeadlockCheck = o.getBool("deadlockCheck", o.DeadlockCheck)
 
 	// =================== other ===================
@@ -1025,6 +1323,167 @@ func (o *Options) configureLog(vp *viper.Viper) {
 	o.Logger.Loki.Password = o.getString("logger.loki.password", o.Logger.Loki.Password)
 }
 
+// IP访问控制配置
+func (o *Options) configureIPAccess() {
+	o.IPAccess.TrustedProxies = o.getStringSlice("ipAccess.trustedProxies")
+	o.IPAccess.TCP = o.getIPAccessConfig("ipAccess.tcp")
+	o.IPAccess.WS = o.getIPAccessConfig("ipAccess.ws")
+	o.IPAccess.WSS = o.getIPAccessConfig("ipAccess.wss")
+	o.IPAccess.API = o.getIPAccessConfig("ipAccess.api")
+	o.IPAccess.Manager = o.getIPAccessConfig("ipAccess.manager")
+}
+
+// ManagerOIDCConfig 管理端的OIDC登录配置
+type ManagerOIDCConfig struct {
+	On              bool              // 是否开启OIDC登录
+	Issuer          string            // OIDC提供者地址，通过 {issuer}/.well-known/openid-configuration 获取端点
+	ClientId        string            // 客户端id
+	ClientSecret    string            // 客户端密钥
+	RedirectUrl     string            // 回调地址，例如 http://xx.xx.xx.xx:5300/manager/oidc/callback
+	Scopes          []string          // 申请的scope
+	UsernameClaim   string            // 作为用户名的claim
+	RoleClaim       string            // 作为角色的claim（字符串或字符串数组）
+	RoleMapping     map[string]string // RoleClaim的值对应的角色（viewer, operator, admin）
+	DefaultRole     string            // 没有匹配到角色时的默认角色，为空表示拒绝登录
+	SuccessRedirect string            // 登录成功后跳转的地址（token等参数放在url的fragment中），为空则直接返回json
+}
+
+func (o *Options) configureManagerOIDC() {
+	oidc := &o.Manager.OIDC
+	oidc.On = o.getBool("manager.oidc.on", oidc.On)
+	oidc.Issuer = strings.TrimSuffix(o.getString("manager.oidc.issuer", oidc.Issuer), "/")
+	oidc.ClientId = o.getString("manager.oidc.clientId", oidc.ClientId)
+	oidc.ClientSecret = o.getString("manager.oidc.clientSecret", oidc.ClientSecret)
+	oidc.RedirectUrl = o.getString("manager.oidc.redirectUrl", oidc.RedirectUrl)
+	if scopes := o.getStringSlice("manager.oidc.scopes"); len(scopes) > 0 {
+		oidc.Scopes = scopes
+	}
+	oidc.UsernameClaim = o.getString("manager.oidc.usernameClaim", oidc.UsernameClaim)
+	oidc.RoleClaim = o.getString("manager.oidc.roleClaim", oidc.RoleClaim)
+	if roleMapping := o.vp.GetStringMapString("manager.oidc.roleMapping"); len(roleMapping) > 0 {
+		oidc.RoleMapping = roleMapping
+	}
+	oidc.DefaultRole = o.getString("manager.oidc.defaultRole", oidc.DefaultRole)
+	oidc.SuccessRedirect = o.getString("manager.oidc.successRedirect", oidc.SuccessRedirect)
+
+	if oidc.On {
+		if oidc.Issuer == "" || oidc.ClientId == "" || oidc.RedirectUrl == "" {
+			wklog.Panic("manager.oidc.issuer, manager.oidc.clientId and manager.oidc.redirectUrl are required when manager.oidc.on is true")
+		}
+		for claimValue, role := range oidc.RoleMapping {
+			if !auth.Role(role).Valid() {
+				wklog.Panic("invalid manager.oidc.roleMapping role", zap.String("claimValue", claimValue), zap.String("role", role))
+			}
+		}
+		if oidc.DefaultRole != "" && !auth.Role(oidc.DefaultRole).Valid() {
+			wklog.Panic("invalid manager.oidc.defaultRole", zap.String("role", oidc.DefaultRole))
+		}
+	}
+}
+
+// 读取频道类型的消息保留策略，比如 retention.channelTypes.2.maxAge: 720h
+func (o *Options) configureRetentionChannelTypes() {
+	channelTypes := o.vp.GetStringMap("retention.channelTypes")
+	for channelTypeStr := range channelTypes {
+		channelType, err := strconv.ParseUint(channelTypeStr, 10, 8)
+		if err != nil {
+			wklog.Panic("invalid retention.channelTypes", zap.String("channelType", channelTypeStr))
+		}
+		prefix := fmt.Sprintf("retention.channelTypes.%s", channelTypeStr)
+		o.Retention.ChannelTypes[uint8(channelType)] = RetentionPolicy{
+			MaxAge:   o.getDuration(prefix+".maxAge", 0),
+			MaxCount: o.getInt64(prefix+".maxCount", 0),
+			MaxBytes: o.getInt64(prefix+".maxBytes", 0),
+		}
+	}
+}
+
+func (o *Options) getIPAccessConfig(key string) IPAccessConfig {
+	return IPAccessConfig{
+		Allow: o.getStringSlice(key + ".allow"),
+		Deny:  o.getStringSlice(key + ".deny"),
+	}
+}
+
+// Hot 可以热更新的配置项（见hotSettings）需要通过Hot()读取
+//
+// 重新加载配置时不修改当前配置，而是整体替换为新的配置快照，避免和读取并发修改。快照只保证热更新的配置项是最新的，其他配置项仍然读取当前配置
+func (o *Options) Hot() *Options {
+	if hot := o.hot.Load(); hot != nil {
+		return hot
+	}
+	return o
+}
+
+// 是否有配置文件（只有配置文件可以重新加载）
+func (o *Options) hasConfigFile() bool {
+	return o.vp != nil && o.vp.ConfigFileUsed() != ""
+}
+
+// Reload 重新读取配置文件并解析为新的配置（不会修改当前配置），同时返回与启动时相比发生变化的配置项
+func (o *Options) Reload() (newOpts *Options, changedKeys []string, err error) {
+	if o.vp == nil || o.vp.ConfigFileUsed() == "" {
+		return nil, nil, errors.New("no config file used")
+	}
+	vp := viper.New()
+	vp.SetConfigFile(o.vp.ConfigFileUsed())
+	if err := vp.ReadInConfig(); err != nil {
+		return nil, nil, err
+	}
+	vp.SetEnvPrefix("wk")
+	vp.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	vp.AutomaticEnv()
+
+	newOpts = NewOptions()
+	newOpts.Mode = o.Mode
+	newOpts.External.IP = o.External.IP // 沿用当前的外网IP，避免重新获取
+	defer func() {
+		if r := recover(); r != nil { // 配置有误时会panic
+			newOpts = nil
+			changedKeys = nil
+			err = fmt.Errorf("invalid config: %v", r)
+		}
+	}()
+	newOpts.ConfigureWithViper(vp)
+	if err := newOpts.Check(); err != nil {
+		return nil, nil, err
+	}
+	if newOpts.Webhook.MsgNotifyEventPushInterval <= 0 {
+		return nil, nil, errors.New("webhook.msgNotifyEventPushInterval must be greater than 0")
+	}
+
+	for key, value := range newOpts.startSettings {
+		if oldValue, ok := o.startSettings[key]; !ok || oldValue != value {
+			changedKeys = append(changedKeys, key)
+		}
+	}
+	for key := range o.startSettings {
+		if _, ok := newOpts.startSettings[key]; !ok {
+			changedKeys = append(changedKeys, key)
+		}
+	}
+	sort.Strings(changedKeys)
+	return newOpts, changedKeys, nil
+}
+
+// 将嵌套的配置项展开为 a.b.c 形式的key
+func flattenSettings(prefix string, settings map[string]interface{}) map[string]string {
+	result := make(map[string]string)
+	for key, value := range settings {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if sub, ok := value.(map[string]interface{}); ok {
+			for k, v := range flattenSettings(key, sub) {
+				result[k] = v
+			}
+			continue
+		}
+		result[key] = fmt.Sprint(value)
+	}
+	return result
+}
+
 // IsTmpChannel 是否是临时频道
 func (o *Options) IsTmpChannel(channelID string) bool {
 	return strings.HasSuffix(channelID, o.TmpChannel.Suffix)
@@ -0,0 +1,677 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// 用户在线状态订阅
+//
+// 客户端通过SUB包（频道类型为个人频道）订阅一组用户的在线状态，订阅关系保存在客户端连接所在的节点。
+// 用户的在线状态由用户的领导节点（持有用户所有设备的连接）聚合：有不处于离开状态的连接为在线，
+// 连接都处于离开状态为离开，没有已认证的连接为离线。状态发生变化时领导节点广播给所有节点，
+// 各节点再通过cmd消息（presence）推送给本节点订阅了该用户的连接。
+// 用户离线时在用户信息中记录最后在线时间，最后在线时间是否对查看者可见由用户设置的可见范围决定。
+type presenceManager struct {
+	s *Server
+
+	statuses map[string]PresenceStatus // 本节点作为领导的用户的在线状态（不保存离线的，只在处理协程中访问）
+
+	pendingLock sync.Mutex
+	pending     map[string]struct{} // 等待重新计算在线状态的用户
+	pendingC    chan struct{}
+
+	subLock     sync.RWMutex
+	subscribers map[string]map[*connContext]struct{} // 被订阅的uid -> 本节点订阅了的连接
+	connSubs    map[*connContext]map[string]struct{} // 连接 -> 连接订阅的uid
+	versions    map[string]int64                     // 被订阅的uid -> 最后推送的状态版本，用于丢弃乱序到达的旧状态
+
+	stopper *syncutil.Stopper
+	wklog.Log
+}
+
+func newPresenceManager(s *Server) *presenceManager {
+	return &presenceManager{
+		s:           s,
+		statuses:    make(map[string]PresenceStatus),
+		pending:     make(map[string]struct{}),
+		pendingC:    make(chan struct{}, 1),
+		subscribers: make(map[string]map[*connContext]struct{}),
+		connSubs:    make(map[*connContext]map[string]struct{}),
+		versions:    make(map[string]int64),
+		stopper:     syncutil.NewStopper(),
+		Log:         wklog.NewWKLog("presenceManager"),
+	}
+}
+
+func (p *presenceManager) start() error {
+	p.stopper.RunWorker(p.loop)
+	return nil
+}
+
+func (p *presenceManager) stop() {
+	p.stopper.Stop()
+}
+
+// ==================================== 状态聚合（用户的领导节点） ====================================
+
+// refresh 用户的连接发生了变化，重新计算用户的在线状态（异步，同一用户的多次变化会合并）
+func (p *presenceManager) refresh(uid string) {
+	p.pendingLock.Lock()
+	p.pending[uid] = struct{}{}
+	p.pendingLock.Unlock()
+
+	select {
+	case p.pendingC <- struct{}{}:
+	default:
+	}
+}
+
+func (p *presenceManager) loop() {
+	for {
+		select {
+		case <-p.pendingC:
+			p.pendingLock.Lock()
+			uids := p.pending
+			p.pending = make(map[string]struct{})
+			p.pendingLock.Unlock()
+
+			for uid := range uids {
+				p.refreshNow(uid)
+			}
+		case <-p.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+func (p *presenceManager) refreshNow(uid string) {
+	leaderId, err := p.s.cluster.SlotLeaderIdOfChannel(uid, wkproto.ChannelTypePerson)
+	if err != nil {
+		p.Warn("refresh: get user leader failed", zap.Error(err), zap.String("uid", uid))
+		return
+	}
+	if leaderId != p.s.opts.Cluster.NodeId { // 用户的状态只由领导节点计算
+		delete(p.statuses, uid)
+		return
+	}
+
+	status := p.aggregate(uid)
+	if status == p.statuses[uid] {
+		return
+	}
+	if status == PresenceStatusOffline {
+		delete(p.statuses, uid)
+	} else {
+		p.statuses[uid] = status
+	}
+
+	now := time.Now()
+	change := &presenceChange{
+		uid:     uid,
+		status:  status,
+		version: now.UnixNano(),
+	}
+	if status == PresenceStatusOffline {
+		user, err := p.s.store.GetUser(uid)
+		if err != nil && err != wkdb.ErrNotFound {
+			p.Warn("refresh: get user failed", zap.Error(err), zap.String("uid", uid))
+		}
+		change.lastSeen = now.Unix()
+		change.lastSeenPrivacy = user.LastSeenPrivacy
+		go p.saveLastSeen(uid, wkdb.IsEmptyUser(user), now) // 提案比较耗时，不阻塞状态的广播
+	}
+	p.Debug("presence changed", zap.String("uid", uid), zap.String("status", status.String()))
+
+	p.broadcast(change)
+}
+
+// 聚合用户所有连接的状态
+func (p *presenceManager) aggregate(uid string) PresenceStatus {
+	status := PresenceStatusOffline
+	for _, conn := range p.s.userReactor.getConns(uid) {
+		if !conn.isAuth.Load() || conn.isClosed() {
+			continue
+		}
+		if !conn.away.Load() {
+			return PresenceStatusOnline
+		}
+		status = PresenceStatusAway
+	}
+	return status
+}
+
+// 保存用户的最后在线时间
+func (p *presenceManager) saveLastSeen(uid string, notExist bool, lastSeen time.Time) {
+	u := wkdb.User{
+		Uid:       uid,
+		UpdatedAt: &lastSeen,
+		LastSeen:  &lastSeen,
+	}
+	if notExist { // 没有通过/user/token创建的用户（未开启token认证）
+		u.CreatedAt = &lastSeen
+	}
+	if err := p.s.store.UpdateUser(u); err != nil {
+		p.Warn("save last seen failed", zap.Error(err), zap.String("uid", uid))
+	}
+}
+
+// setAway 设置用户领导节点上连接的离开状态，nodeId和connId为连接所在节点以及在所在节点的连接id
+func (p *presenceManager) setAway(uid string, nodeId uint64, connId int64, away bool) error {
+	var conn *connContext
+	for _, c := range p.s.userReactor.getConns(uid) {
+		if nodeId == p.s.opts.Cluster.NodeId {
+			if c.isRealConn && c.connId == connId {
+				conn = c
+				break
+			}
+		} else if c.realNodeId == nodeId && c.proxyConnId == connId {
+			conn = c
+			break
+		}
+	}
+	if conn == nil {
+		return ErrConnNotFound
+	}
+	conn.away.Store(away)
+	p.refresh(uid)
+	return nil
+}
+
+// 客户端设置了当前连接的状态，通知用户的领导节点重新聚合
+func (p *presenceManager) setConnAway(conn *connContext, away bool) {
+	conn.away.Store(away)
+
+	leaderId, err := p.s.cluster.SlotLeaderIdOfChannel(conn.uid, wkproto.ChannelTypePerson)
+	if err != nil {
+		p.Warn("setConnAway: get user leader failed", zap.Error(err), zap.String("uid", conn.uid))
+		return
+	}
+	if leaderId == p.s.opts.Cluster.NodeId { // 本节点的连接即是领导节点上的连接
+		p.refresh(conn.uid)
+		return
+	}
+	req := &presenceAwayReq{
+		uid:    conn.uid,
+		nodeId: p.s.opts.Cluster.NodeId,
+		connId: conn.connId,
+		away:   away,
+	}
+	timeoutCtx, cancel := context.WithTimeout(p.s.ctx, p.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	resp, err := p.s.cluster.RequestWithContext(timeoutCtx, leaderId, "/wk/presenceAway", req.Marshal())
+	if err != nil {
+		p.Warn("setConnAway: request leader failed", zap.Error(err), zap.String("uid", conn.uid), zap.Uint64("leaderId", leaderId))
+		return
+	}
+	if resp.Status != proto.StatusOK {
+		p.Warn("setConnAway: request leader failed", zap.String("uid", conn.uid), zap.Uint64("leaderId", leaderId), zap.Int("status", int(resp.Status)), zap.String("err", string(resp.Body)))
+	}
+}
+
+// ==================================== 状态广播 ====================================
+
+// 广播用户的状态变化给所有节点（包括自己）
+func (p *presenceManager) broadcast(change *presenceChange) {
+	data := change.Marshal()
+	for _, node := range p.s.clusterServer.GetConfig().Nodes {
+		if node.Id == p.s.opts.Cluster.NodeId || !node.Online {
+			continue
+		}
+		err := p.s.cluster.Send(node.Id, &proto.Message{
+			MsgType: uint32(ClusterMsgTypePresence),
+			Content: data,
+		})
+		if err != nil {
+			p.Warn("broadcast presence failed", zap.Error(err), zap.Uint64("nodeId", node.Id), zap.String("uid", change.uid))
+		}
+	}
+	go p.handleChange(change)
+}
+
+// handleChange 推送用户的状态变化给本节点的订阅者
+func (p *presenceManager) handleChange(change *presenceChange) {
+	p.subLock.Lock()
+	subs := p.subscribers[change.uid]
+	if len(subs) == 0 || change.version <= p.versions[change.uid] {
+		p.subLock.Unlock()
+		return
+	}
+	p.versions[change.uid] = change.version
+	conns := make([]*connContext, 0, len(subs))
+	for conn := range subs {
+		conns = append(conns, conn)
+	}
+	p.subLock.Unlock()
+
+	info := &PresenceInfo{
+		UID:    change.uid,
+		Status: change.status,
+	}
+	if change.status != PresenceStatusOffline || change.lastSeenPrivacy == LastSeenPrivacyNobody {
+		p.push(conns, []*PresenceInfo{info})
+		return
+	}
+
+	if change.lastSeenPrivacy != LastSeenPrivacyContacts {
+		info.LastSeen = change.lastSeen
+		p.push(conns, []*PresenceInfo{info})
+		return
+	}
+
+	// 仅联系人可见，需要到用户的领导节点判断哪些订阅者是联系人
+	viewers := make([]string, 0, len(conns))
+	viewerMap := make(map[string]struct{}, len(conns))
+	for _, conn := range conns {
+		if _, ok := viewerMap[conn.uid]; !ok {
+			viewerMap[conn.uid] = struct{}{}
+			viewers = append(viewers, conn.uid)
+		}
+	}
+	visibleViewers, err := p.visibleViewers(change.uid, viewers)
+	if err != nil {
+		p.Warn("get last seen visible viewers failed", zap.Error(err), zap.String("uid", change.uid))
+	}
+	visibleMap := make(map[string]struct{}, len(visibleViewers))
+	for _, viewer := range visibleViewers {
+		visibleMap[viewer] = struct{}{}
+	}
+	visibleConns := make([]*connContext, 0, len(conns))
+	hiddenConns := make([]*connContext, 0, len(conns))
+	for _, conn := range conns {
+		if _, ok := visibleMap[conn.uid]; ok {
+			visibleConns = append(visibleConns, conn)
+		} else {
+			hiddenConns = append(hiddenConns, conn)
+		}
+	}
+	p.push(hiddenConns, []*PresenceInfo{info})
+	p.push(visibleConns, []*PresenceInfo{{
+		UID:      change.uid,
+		Status:   change.status,
+		LastSeen: change.lastSeen,
+	}})
+}
+
+// 以cmd消息推送用户的在线状态给连接（不存储、不计入最近会话）
+func (p *presenceManager) push(conns []*connContext, infos []*PresenceInfo) {
+	if len(conns) == 0 || len(infos) == 0 {
+		return
+	}
+	payload := []byte(wkutil.ToJSON(map[string]interface{}{
+		"cmd":   CMDPresence,
+		"param": infos,
+	}))
+	sendPacket := &wkproto.SendPacket{
+		Framer: wkproto.Framer{
+			NoPersist: true,
+			SyncOnce:  true,
+			RedDot:    false,
+		},
+		ClientMsgNo: fmt.Sprintf("%s0", wkutil.GenUUID()),
+		ChannelID:   p.s.opts.SystemUID,
+		ChannelType: wkproto.ChannelTypePerson,
+		Payload:     payload,
+	}
+	req := &deliverReq{
+		channelId:   p.s.opts.SystemUID,
+		channelType: wkproto.ChannelTypePerson,
+		messages: []ReactorChannelMessage{
+			{
+				FromUid:    p.s.opts.SystemUID,
+				MessageId:  p.s.channelReactor.messageIDGen.Generate().Int64(),
+				SendPacket: sendPacket,
+				IsSystem:   true,
+			},
+		},
+	}
+	p.s.deliverManager.nextDeliver().deliverToConns(req, conns)
+}
+
+// ==================================== 订阅（订阅者连接所在节点） ====================================
+
+// handleSubPacket 处理客户端的SUB包：订阅/取消订阅用户的在线状态，或者设置当前连接的状态
+func (p *presenceManager) handleSubPacket(conn *connContext, packet *wkproto.SubPacket) {
+	uids, reasonCode := p.handleSub(conn, packet)
+
+	err := conn.writePacket(&wkproto.SubackPacket{
+		SubNo:       packet.SubNo,
+		ChannelID:   packet.ChannelID,
+		ChannelType: packet.ChannelType,
+		Action:      packet.Action,
+		ReasonCode:  reasonCode,
+	})
+	if err != nil {
+		p.Warn("write suback failed", zap.Error(err), zap.String("uid", conn.uid))
+		return
+	}
+	if len(uids) > 0 { // 订阅成功后推送一次当前的状态
+		go p.pushSnapshot(conn, uids)
+	}
+}
+
+// 返回需要推送当前状态的uid
+func (p *presenceManager) handleSub(conn *connContext, packet *wkproto.SubPacket) ([]string, wkproto.ReasonCode) {
+	if !p.s.opts.Presence.On {
+		p.Debug("presence subscribe is off", zap.String("uid", conn.uid))
+		return nil, wkproto.ReasonNotSupportChannelType
+	}
+	if packet.ChannelType != wkproto.ChannelTypePerson {
+		return nil, wkproto.ReasonNotSupportChannelType
+	}
+
+	var param PresenceSubParam
+	if strings.TrimSpace(packet.Param) != "" {
+		if err := json.Unmarshal([]byte(packet.Param), &param); err != nil {
+			p.Warn("decode sub param failed", zap.Error(err), zap.String("uid", conn.uid), zap.String("param", packet.Param))
+			return nil, wkproto.ReasonPayloadDecodeError
+		}
+	}
+
+	// 设置当前连接的状态
+	if param.Status != "" {
+		switch param.Status {
+		case PresenceStatusOnline.String():
+			go p.setConnAway(conn, false)
+		case PresenceStatusAway.String():
+			go p.setConnAway(conn, true)
+		default:
+			return nil, wkproto.ReasonPayloadDecodeError
+		}
+		return nil, wkproto.ReasonSuccess
+	}
+
+	uids := make([]string, 0, len(param.UIDs)+1)
+	exists := make(map[string]struct{}, len(param.UIDs)+1)
+	for _, uid := range append(param.UIDs, packet.ChannelID) {
+		uid = strings.TrimSpace(uid)
+		if uid == "" || uid == conn.uid {
+			continue
+		}
+		if IsSpecialChar(uid) {
+			return nil, wkproto.ReasonChannelIDError
+		}
+		if _, ok := exists[uid]; ok {
+			continue
+		}
+		exists[uid] = struct{}{}
+		uids = append(uids, uid)
+	}
+	if len(uids) == 0 {
+		return nil, wkproto.ReasonSuccess
+	}
+
+	if packet.Action == wkproto.UnSubscribe {
+		p.unsubscribe(conn, uids)
+		return nil, wkproto.ReasonSuccess
+	}
+	if err := p.subscribe(conn, uids); err != nil {
+		p.Warn("subscribe presence failed", zap.Error(err), zap.String("uid", conn.uid), zap.Int("count", len(uids)))
+		return nil, wkproto.ReasonRateLimit
+	}
+	return uids, wkproto.ReasonSuccess
+}
+
+// subscribe 连接订阅用户的在线状态，超过每个连接的订阅数量上限时不订阅任何用户
+func (p *presenceManager) subscribe(conn *connContext, uids []string) error {
+	p.subLock.Lock()
+	defer p.subLock.Unlock()
+
+	subs := p.connSubs[conn]
+	newCount := 0
+	for _, uid := range uids {
+		if _, ok := subs[uid]; !ok {
+			newCount++
+		}
+	}
+	if maxCount := p.s.opts.Presence.SubscribeMaxCount; maxCount > 0 && len(subs)+newCount > maxCount {
+		return fmt.Errorf("presence subscribe count exceeds the limit[%d]", maxCount)
+	}
+	if subs == nil {
+		subs = make(map[string]struct{}, len(uids))
+		p.connSubs[conn] = subs
+	}
+	for _, uid := range uids {
+		subs[uid] = struct{}{}
+		conns := p.subscribers[uid]
+		if conns == nil {
+			conns = make(map[*connContext]struct{})
+			p.subscribers[uid] = conns
+		}
+		conns[conn] = struct{}{}
+	}
+	return nil
+}
+
+func (p *presenceManager) unsubscribe(conn *connContext, uids []string) {
+	p.subLock.Lock()
+	defer p.subLock.Unlock()
+
+	subs := p.connSubs[conn]
+	for _, uid := range uids {
+		delete(subs, uid)
+		p.removeSubscriber(uid, conn)
+	}
+	if len(subs) == 0 {
+		delete(p.connSubs, conn)
+	}
+}
+
+// removeConn 连接关闭，移除连接的所有订阅
+func (p *presenceManager) removeConn(conn *connContext) {
+	p.subLock.Lock()
+	defer p.subLock.Unlock()
+
+	for uid := range p.connSubs[conn] {
+		p.removeSubscriber(uid, conn)
+	}
+	delete(p.connSubs, conn)
+}
+
+func (p *presenceManager) removeSubscriber(uid string, conn *connContext) {
+	conns := p.subscribers[uid]
+	delete(conns, conn)
+	if len(conns) == 0 {
+		delete(p.subscribers, uid)
+		delete(p.versions, uid)
+	}
+}
+
+// 推送用户当前的状态给刚订阅的连接
+func (p *presenceManager) pushSnapshot(conn *connContext, uids []string) {
+	infos, err := p.query(conn.uid, uids)
+	if err != nil {
+		p.Warn("query presence failed", zap.Error(err), zap.String("uid", conn.uid))
+		return
+	}
+	p.push([]*connContext{conn}, infos)
+}
+
+// ==================================== 查询 ====================================
+
+// query 查询用户的在线状态，按用户的领导节点分组查询，viewer为查看者（为空表示不限制最后在线时间的可见范围）
+func (p *presenceManager) query(viewer string, uids []string) ([]*PresenceInfo, error) {
+	uidsOfNode := make(map[uint64][]string)
+	for _, uid := range uids {
+		leaderId, err := p.s.cluster.SlotLeaderIdOfChannel(uid, wkproto.ChannelTypePerson)
+		if err != nil {
+			return nil, err
+		}
+		uidsOfNode[leaderId] = append(uidsOfNode[leaderId], uid)
+	}
+
+	var (
+		infos  = make([]*PresenceInfo, 0, len(uids))
+		infoMu sync.Mutex
+	)
+	timeoutCtx, cancel := context.WithTimeout(p.s.ctx, p.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	requestGroup, _ := errgroup.WithContext(timeoutCtx)
+	for nodeId, nodeUids := range uidsOfNode {
+		nodeId, nodeUids := nodeId, nodeUids
+		requestGroup.Go(func() error {
+			var (
+				results []*PresenceInfo
+				err     error
+			)
+			if nodeId == p.s.opts.Cluster.NodeId {
+				results = p.queryLocal(viewer, nodeUids)
+			} else {
+				results, err = p.requestQuery(timeoutCtx, nodeId, viewer, nodeUids)
+				if err != nil {
+					return err
+				}
+			}
+			infoMu.Lock()
+			infos = append(infos, results...)
+			infoMu.Unlock()
+			return nil
+		})
+	}
+	if err := requestGroup.Wait(); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+func (p *presenceManager) requestQuery(ctx context.Context, nodeId uint64, viewer string, uids []string) ([]*PresenceInfo, error) {
+	req := PresenceQueryReq{
+		ViewerUID: viewer,
+		UIDs:      uids,
+	}
+	resp, err := p.s.cluster.RequestWithContext(ctx, nodeId, "/wk/presenceQuery", []byte(wkutil.ToJSON(req)))
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		return nil, fmt.Errorf("query presence failed, status: %d err:%s", resp.Status, string(resp.Body))
+	}
+	var infos []*PresenceInfo
+	if err = wkutil.ReadJSONByByte(resp.Body, &infos); err != nil {
+		return nil, err
+	}
+	return infos, nil
+}
+
+// queryLocal 查询本节点作为领导的用户的在线状态
+func (p *presenceManager) queryLocal(viewer string, uids []string) []*PresenceInfo {
+	infos := make([]*PresenceInfo, 0, len(uids))
+	for _, uid := range uids {
+		info := &PresenceInfo{
+			UID:    uid,
+			Status: p.aggregate(uid),
+		}
+		infos = append(infos, info)
+		if info.Status != PresenceStatusOffline {
+			continue
+		}
+		user, err := p.s.store.GetUser(uid)
+		if err != nil {
+			if err != wkdb.ErrNotFound {
+				p.Warn("queryLocal: get user failed", zap.Error(err), zap.String("uid", uid))
+			}
+			continue
+		}
+		if user.LastSeen != nil && p.lastSeenVisible(uid, user.LastSeenPrivacy, viewer) {
+			info.LastSeen = user.LastSeen.Unix()
+		}
+	}
+	return infos
+}
+
+// lastSeenVisible 用户的最后在线时间对查看者是否可见（需要在用户的领导节点调用）
+func (p *presenceManager) lastSeenVisible(uid string, privacy uint8, viewer string) bool {
+	if viewer == "" || viewer == uid {
+		return true
+	}
+	switch privacy {
+	case LastSeenPrivacyNobody:
+		return false
+	case LastSeenPrivacyContacts:
+		exist, err := p.s.store.ExistAllowlist(uid, wkproto.ChannelTypePerson, viewer)
+		if err != nil {
+			p.Warn("lastSeenVisible: ExistAllowlist failed", zap.Error(err), zap.String("uid", uid), zap.String("viewer", viewer))
+			return false
+		}
+		return exist
+	}
+	return true
+}
+
+// 查询用户的最后在线时间对哪些查看者可见
+func (p *presenceManager) visibleViewers(uid string, viewers []string) ([]string, error) {
+	leaderId, err := p.s.cluster.SlotLeaderIdOfChannel(uid, wkproto.ChannelTypePerson)
+	if err != nil {
+		return nil, err
+	}
+	if leaderId == p.s.opts.Cluster.NodeId {
+		return p.visibleViewersLocal(uid, viewers), nil
+	}
+	req := &presenceVisibleReq{
+		uid:     uid,
+		viewers: viewers,
+	}
+	timeoutCtx, cancel := context.WithTimeout(p.s.ctx, p.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	resp, err := p.s.cluster.RequestWithContext(timeoutCtx, leaderId, "/wk/presenceVisible", req.Marshal())
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		return nil, fmt.Errorf("get visible viewers failed, status: %d err:%s", resp.Status, string(resp.Body))
+	}
+	result := &presenceVisibleReq{}
+	if err = result.Unmarshal(resp.Body); err != nil {
+		return nil, err
+	}
+	return result.viewers, nil
+}
+
+func (p *presenceManager) visibleViewersLocal(uid string, viewers []string) []string {
+	user, err := p.s.store.GetUser(uid)
+	if err != nil && err != wkdb.ErrNotFound {
+		p.Warn("visibleViewersLocal: get user failed", zap.Error(err), zap.String("uid", uid))
+		return nil
+	}
+	visibles := make([]string, 0, len(viewers))
+	for _, viewer := range viewers {
+		if p.lastSeenVisible(uid, user.LastSeenPrivacy, viewer) {
+			visibles = append(visibles, viewer)
+		}
+	}
+	return visibles
+}
+
+// setLastSeenPrivacy 设置用户最后在线时间的可见范围（需要在用户的领导节点调用）
+func (p *presenceManager) setLastSeenPrivacy(uid string, privacy uint8) error {
+	if err := checkLastSeenPrivacy(privacy); err != nil {
+		return err
+	}
+	user, err := p.s.store.GetUser(uid)
+	if err != nil && err != wkdb.ErrNotFound {
+		return err
+	}
+	now := time.Now()
+	u := wkdb.User{
+		Uid:             uid,
+		UpdatedAt:       &now,
+		LastSeenPrivacy: privacy,
+	}
+	if wkdb.IsEmptyUser(user) {
+		u.CreatedAt = &now
+	}
+	return p.s.store.UpdateUser(u)
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPresenceChangeMarshal(t *testing.T) {
+	change := &presenceChange{
+		uid:             "u1",
+		status:          PresenceStatusAway,
+		version:         1700000000000000000,
+		lastSeen:        1700000000,
+		lastSeenPrivacy: LastSeenPrivacyContacts,
+	}
+	change2 := &presenceChange{}
+	err := change2.Unmarshal(change.Marshal())
+	assert.NoError(t, err)
+	assert.Equal(t, change, change2)
+}
+
+func TestPresenceAwayReqMarshal(t *testing.T) {
+	req := &presenceAwayReq{uid: "u1", nodeId: 2, connId: 100, away: true}
+	req2 := &presenceAwayReq{}
+	err := req2.Unmarshal(req.Marshal())
+	assert.NoError(t, err)
+	assert.Equal(t, req, req2)
+}
+
+func TestPresenceVisibleReqMarshal(t *testing.T) {
+	req := &presenceVisibleReq{uid: "u1", viewers: []string{"u2", "u3"}}
+	req2 := &presenceVisibleReq{}
+	err := req2.Unmarshal(req.Marshal())
+	assert.NoError(t, err)
+	assert.Equal(t, req, req2)
+}
+
+func TestPresencePrivacyReqCheck(t *testing.T) {
+	assert.NoError(t, PresencePrivacyReq{UID: "u1", LastSeenPrivacy: LastSeenPrivacyNobody}.Check())
+	assert.Error(t, PresencePrivacyReq{UID: "", LastSeenPrivacy: LastSeenPrivacyEveryone}.Check())
+	assert.Error(t, PresencePrivacyReq{UID: "u1", LastSeenPrivacy: 0}.Check())
+	assert.Error(t, PresencePrivacyReq{UID: "u1", LastSeenPrivacy: 4}.Check())
+}
@@ -33,6 +33,15 @@ func (s *Server) onData(conn wknet.Conn) error {
 			_, _ = conn.Discard(size)
 			buff = buff[size:]
 		}
+		// IP访问控制（代理协议已解析，此时的地址为真实地址，每个连接只检查一次）
+		if !s.checkConnIPAccess(conn) {
+			return nil
+		}
+	}
+
+	// JSON文本协议（浏览器/脚本客户端）
+	if wsConn, ok := conn.(wknet.IWSConn); ok && wsConn.JSONRPC() {
+		return s.onJSONRPCData(conn, buff)
 	}
 
 	data, _ := gnetUnpacket(buff)
@@ -53,7 +62,7 @@ func (s *Server) onData(conn wknet.Conn) error {
 	if !isAuth {
 
 		// 解析连接包
-		packet, _, err := s.opts.Proto.DecodeFrame(data, wkproto.LatestVersion)
+		packet, size, err := s.opts.Proto.DecodeFrame(data, wkproto.LatestVersion)
 		if err != nil {
 			s.Warn("Failed to decode the message,conn will be closed", zap.Error(err))
 			conn.Close()
@@ -70,34 +79,10 @@ func (s *Server) onData(conn wknet.Conn) error {
 			return nil
 		}
 		connectPacket := packet.(*wkproto.ConnectPacket)
-
-		if strings.TrimSpace(connectPacket.UID) == "" {
-			s.Warn("UID is empty,conn will be closed")
-			conn.Close()
-			return nil
-		}
-		if IsSpecialChar(connectPacket.UID) {
-			s.Warn("UID is illegal,conn will be closed", zap.String("uid", connectPacket.UID))
-			conn.Close()
+		compression := decodeConnectExtension(data[:size], connectPacket) // 客户端请求的负载压缩算法
+		if s.handleConnectPacket(conn, connectPacket, compression) == nil {
 			return nil
 		}
-
-		sub := s.userReactor.reactorSub(connectPacket.UID)
-		connInfo := connInfo{
-			connId:       conn.ID(),
-			uid:          connectPacket.UID,
-			deviceId:     connectPacket.DeviceID,
-			deviceFlag:   wkproto.DeviceFlag(connectPacket.DeviceFlag),
-			protoVersion: connectPacket.Version,
-		}
-		connCtx = newConnContext(connInfo, conn, sub)
-		conn.SetContext(connCtx)
-
-		// 添加用户的连接，如果用户不存在则创建
-		s.userReactor.addConnAndCreateUserHandlerIfNotExist(connCtx)
-
-		connCtx.addConnectPacket(connectPacket)
-
 		_, _ = conn.Discard(len(data))
 	} else {
 		offset := 0
@@ -112,19 +97,7 @@ func (s *Server) onData(conn wknet.Conn) error {
 				break
 			}
 			offset += size
-			if frame.GetFrameType() == wkproto.SEND {
-				sendPacket := frame.(*wkproto.SendPacket)
-
-				// 这里需要复制一份新的payload的byte，因为conn.Peek(-1)返回的data是被复用了的，在多线程下会出现数据错乱
-				if len(sendPacket.Payload) > 0 {
-					newPayload := make([]byte, len(sendPacket.Payload))
-					copy(newPayload, sendPacket.Payload)
-					sendPacket.Payload = newPayload
-				}
-				connCtx.addSendPacket(sendPacket)
-			} else {
-				connCtx.addOtherPacket(frame)
-			}
+			s.handleFrame(connCtx, frame)
 		}
 		_, _ = conn.Discard(offset)
 	}
@@ -132,6 +105,61 @@ func (s *Server) onData(conn wknet.Conn) error {
 	return nil
 }
 
+// handleConnectPacket 处理连接包，创建连接上下文并提交认证，返回nil表示连接包不合法（连接已关闭）
+func (s *Server) handleConnectPacket(conn wknet.Conn, connectPacket *wkproto.ConnectPacket, compression string) *connContext {
+	if strings.TrimSpace(connectPacket.UID) == "" {
+		s.Warn("UID is empty,conn will be closed")
+		conn.Close()
+		return nil
+	}
+	if IsSpecialChar(connectPacket.UID) {
+		s.Warn("UID is illegal,conn will be closed", zap.String("uid", connectPacket.UID))
+		conn.Close()
+		return nil
+	}
+
+	sub := s.userReactor.reactorSub(connectPacket.UID)
+	connInfo := connInfo{
+		connId:       conn.ID(),
+		uid:          connectPacket.UID,
+		deviceId:     connectPacket.DeviceID,
+		deviceFlag:   wkproto.DeviceFlag(connectPacket.DeviceFlag),
+		protoVersion: connectPacket.Version,
+	}
+	connCtx := newConnContext(connInfo, conn, sub)
+	conn.SetContext(connCtx)
+
+	// 添加用户的连接，如果用户不存在则创建
+	s.userReactor.addConnAndCreateUserHandlerIfNotExist(connCtx)
+
+	connCtx.addConnectPacket(connectPacket, compression)
+	return connCtx
+}
+
+// handleFrame 处理已认证连接的包
+func (s *Server) handleFrame(connCtx *connContext, frame wkproto.Frame) {
+	if frame.GetFrameType() == wkproto.SEND {
+		sendPacket := frame.(*wkproto.SendPacket)
+
+		// 这里需要复制一份新的payload的byte，因为conn.Peek(-1)返回的data是被复用了的，在多线程下会出现数据错乱
+		if len(sendPacket.Payload) > 0 {
+			newPayload := make([]byte, len(sendPacket.Payload))
+			copy(newPayload, sendPacket.Payload)
+			sendPacket.Payload = newPayload
+		}
+		if sendPacket.Setting.IsSet(SettingEphemeral) { // 临时信号不走消息的处理流程
+			s.ephemeral.handleSendPacket(connCtx, sendPacket)
+			return
+		}
+		connCtx.addSendPacket(sendPacket)
+	} else if frame.GetFrameType() == wkproto.SUB { // 订阅用户的在线状态
+		connCtx.keepActivity()
+		s.presence.handleSubPacket(connCtx, frame.(*wkproto.SubPacket))
+	} else {
+		connCtx.addOtherPacket(frame)
+	}
+}
+
 func gnetUnpacket(buff []byte) ([]byte, error) {
 	// buff, _ := c.Peek(-1)
 	if len(buff) <= 0 {
@@ -0,0 +1,348 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
+	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+)
+
+// RetentionPolicy 消息保留策略 0表示使用上一级的策略 负数表示不限制
+type RetentionPolicy struct {
+	MaxAge   time.Duration // 消息保留的最长时间
+	MaxCount int64         // 保留的最大消息数量
+	MaxBytes int64         // 保留的最大消息字节数（按消息内容计算）
+}
+
+// 是否不限制（没有任何需要清理的条件）
+func (r RetentionPolicy) unlimited() bool {
+	return r.MaxAge <= 0 && r.MaxCount <= 0 && r.MaxBytes <= 0
+}
+
+// 消息保留
+//
+// 频道的领导节点定时检查本节点上的频道，按 频道 > 频道类型 > 全局 的优先级得到频道的消息保留策略，计算出需要清理到的消息seq。
+// 每批先通知频道的其他副本清理（副本只清理自己已有的消息，并返回自己最新的消息seq），再清理领导本地的消息，
+// 领导清理的位置不超过所有副本最新的消息seq，保证落后的副本仍然可以从领导同步到日志。
+// 副本请求失败时本轮不清理此频道，下一轮重新计算，清理是幂等的，所有副本最终会清理到相同的位置。
+type retentionManager struct {
+	s       *Server
+	stopper *syncutil.Stopper
+	wklog.Log
+}
+
+func newRetentionManager(s *Server) *retentionManager {
+	return &retentionManager{
+		s:       s,
+		stopper: syncutil.NewStopper(),
+		Log:     wklog.NewWKLog("retentionManager"),
+	}
+}
+
+func (r *retentionManager) start() error {
+	if !r.s.opts.Retention.On {
+		return nil
+	}
+	r.stopper.RunWorker(r.loop)
+	return nil
+}
+
+func (r *retentionManager) stop() {
+	r.stopper.Stop()
+}
+
+func (r *retentionManager) loop() {
+	tk := time.NewTicker(r.s.opts.Retention.CheckInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			r.check()
+		case <-r.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+type retentionChannel struct {
+	channelId   string
+	channelType uint8
+	lastMsgSeq  uint64
+}
+
+// 检查本节点上所有频道的过期消息
+func (r *retentionManager) check() {
+	var channels []retentionChannel
+	err := r.s.store.IterateMessageChannels(func(channelId string, channelType uint8, lastMsgSeq uint64) bool {
+		channels = append(channels, retentionChannel{
+			channelId:   channelId,
+			channelType: channelType,
+			lastMsgSeq:  lastMsgSeq,
+		})
+		return true
+	})
+	if err != nil {
+		r.Error("IterateMessageChannels failed", zap.Error(err))
+		return
+	}
+
+	for _, ch := range channels {
+		select {
+		case <-r.stopper.ShouldStop():
+			return
+		default:
+		}
+		if err := r.checkChannel(ch); err != nil {
+			r.Warn("check channel retention failed", zap.Error(err), zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType))
+		}
+	}
+}
+
+func (r *retentionManager) checkChannel(ch retentionChannel) error {
+	leaderId, replicas, err := r.s.cluster.ReplicasOfChannel(ch.channelId, ch.channelType)
+	if err != nil {
+		return err
+	}
+	if leaderId != r.s.opts.Cluster.NodeId { // 只有领导节点负责计算清理位置
+		return nil
+	}
+
+	policy, err := r.policyOf(ch.channelId, ch.channelType)
+	if err != nil {
+		return err
+	}
+	if policy.unlimited() {
+		return nil
+	}
+
+	purgeSeq, err := r.purgeSeqOf(ch, policy)
+	if err != nil {
+		return err
+	}
+	firstSeq, err := r.s.store.GetChannelFirstMessageSeq(ch.channelId, ch.channelType)
+	if err != nil {
+		return err
+	}
+	if firstSeq == 0 || purgeSeq < firstSeq {
+		return nil
+	}
+
+	batchSize := uint64(r.s.opts.Retention.PurgeBatchSize)
+	for firstSeq <= purgeSeq {
+		select {
+		case <-r.stopper.ShouldStop():
+			return nil
+		default:
+		}
+		toSeq := purgeSeq
+		if batchSize > 0 && toSeq-firstSeq+1 > batchSize {
+			toSeq = firstSeq + batchSize - 1
+		}
+		toSeq, err = r.alignColdSegment(ch, toSeq, purgeSeq)
+		if err != nil {
+			return err
+		}
+		if toSeq < firstSeq {
+			return nil
+		}
+
+		// 先通知其他副本清理，领导的清理位置不超过副本最新的消息seq
+		for _, replicaId := range replicas {
+			if replicaId == r.s.opts.Cluster.NodeId {
+				continue
+			}
+			replicaLastSeq, err := r.requestPurgeMessages(replicaId, ch.channelId, ch.channelType, toSeq)
+			if err != nil {
+				return err
+			}
+			if replicaLastSeq < toSeq {
+				toSeq = replicaLastSeq
+			}
+		}
+		if toSeq < firstSeq {
+			return nil
+		}
+
+		purgedSeq, err := r.s.store.PurgeMessagesTo(ch.channelId, ch.channelType, toSeq, 0)
+		if err != nil {
+			return err
+		}
+		if purgedSeq == 0 {
+			return nil
+		}
+		r.Debug("purge messages", zap.String("channelId", ch.channelId), zap.Uint8("channelType", ch.channelType), zap.Uint64("firstSeq", firstSeq), zap.Uint64("purgedSeq", purgedSeq))
+		firstSeq = purgedSeq + 1
+	}
+	return nil
+}
+
+// 冷存储的消息段只能整体清理，清理位置落在消息段中间时对齐到消息段的边界（不超过purgeSeq）
+func (r *retentionManager) alignColdSegment(ch retentionChannel, toSeq, purgeSeq uint64) (uint64, error) {
+	segments, err := r.s.store.GetMessageColdSegments(ch.channelId, ch.channelType)
+	if err != nil {
+		return 0, err
+	}
+	for _, segment := range segments {
+		if toSeq < segment.StartSeq || toSeq >= segment.EndSeq {
+			continue
+		}
+		if segment.EndSeq <= purgeSeq {
+			return segment.EndSeq, nil
+		}
+		return segment.StartSeq - 1, nil
+	}
+	return toSeq, nil
+}
+
+// 获取频道的消息保留策略
+func (r *retentionManager) policyOf(channelId string, channelType uint8) (RetentionPolicy, error) {
+	var channelInfo *wkdb.ChannelInfo
+	if channelType != wkproto.ChannelTypePerson { // 个人频道没有频道信息
+		realChannelId := channelId
+		if r.s.opts.IsCmdChannel(realChannelId) {
+			realChannelId = r.s.opts.CmdChannelConvertOrginalChannel(realChannelId)
+		}
+		info, err := r.s.store.GetChannel(realChannelId, channelType)
+		if err != nil && err != wkdb.ErrNotFound {
+			return RetentionPolicy{}, err
+		}
+		if err == nil {
+			channelInfo = &info
+		}
+	}
+	return resolveRetentionPolicy(r.s.opts, channelType, channelInfo), nil
+}
+
+// 计算需要清理到的消息seq（包含），取各个条件中最大的seq，不超过倒数第二条消息
+func (r *retentionManager) purgeSeqOf(ch retentionChannel, policy RetentionPolicy) (uint64, error) {
+	var purgeSeq uint64
+	if policy.MaxCount > 0 && ch.lastMsgSeq > uint64(policy.MaxCount) {
+		purgeSeq = ch.lastMsgSeq - uint64(policy.MaxCount)
+	}
+	if policy.MaxAge > 0 {
+		seq, err := r.s.store.GetMessageSeqBeforeTimestamp(ch.channelId, ch.channelType, time.Now().Add(-policy.MaxAge).Unix())
+		if err != nil {
+			return 0, err
+		}
+		if seq > purgeSeq {
+			purgeSeq = seq
+		}
+	}
+	if policy.MaxBytes > 0 {
+		seq, err := r.s.store.GetMessageSeqExceedSize(ch.channelId, ch.channelType, uint64(policy.MaxBytes))
+		if err != nil {
+			return 0, err
+		}
+		if seq > purgeSeq {
+			purgeSeq = seq
+		}
+	}
+	// 最后一条消息始终保留，副本需要通过它得到最后一条日志的term
+	if ch.lastMsgSeq > 0 && purgeSeq >= ch.lastMsgSeq {
+		purgeSeq = ch.lastMsgSeq - 1
+	}
+	return purgeSeq, nil
+}
+
+// 请求副本清理消息，返回副本最新的消息seq
+func (r *retentionManager) requestPurgeMessages(nodeId uint64, channelId string, channelType uint8, messageSeq uint64) (uint64, error) {
+	req := &purgeMessagesReq{
+		ChannelId:   channelId,
+		ChannelType: channelType,
+		MessageSeq:  messageSeq,
+	}
+	data, err := req.Marshal()
+	if err != nil {
+		return 0, err
+	}
+	timeoutCtx, cancel := context.WithTimeout(r.s.ctx, r.s.opts.Cluster.ReqTimeout)
+	defer cancel()
+	resp, err := r.s.cluster.RequestWithContext(timeoutCtx, nodeId, "/wk/purgeMessages", data)
+	if err != nil {
+		return 0, err
+	}
+	if resp.Status != proto.StatusOK {
+		return 0, fmt.Errorf("purge messages failed, status: %d err:%s", resp.Status, string(resp.Body))
+	}
+	presp := &purgeMessagesResp{}
+	if err = presp.Unmarshal(resp.Body); err != nil {
+		return 0, err
+	}
+	return presp.LastMsgSeq, nil
+}
+
+// 副本清理消息，只清理到自己最新的消息seq
+func (s *Server) handlePurgeMessages(c *wkserver.Context) {
+	req := &purgeMessagesReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("handlePurgeMessages Unmarshal err", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	lastMsgSeq, err := s.store.GetLastMsgSeq(req.ChannelId, req.ChannelType)
+	if err != nil {
+		s.Error("handlePurgeMessages: GetLastMsgSeq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	// 落后的副本同样保留自己最后一条消息
+	purgeSeq := req.MessageSeq
+	if lastMsgSeq > 0 && purgeSeq >= lastMsgSeq {
+		purgeSeq = lastMsgSeq - 1
+	}
+	if purgeSeq > 0 {
+		if _, err = s.store.PurgeMessagesTo(req.ChannelId, req.ChannelType, purgeSeq, 0); err != nil {
+			s.Error("handlePurgeMessages: PurgeMessagesTo failed", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+			c.WriteErr(err)
+			return
+		}
+	}
+	resp := &purgeMessagesResp{
+		LastMsgSeq: lastMsgSeq,
+	}
+	data, err := resp.Marshal()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+// 按 频道 > 频道类型 > 全局 的优先级得到消息保留策略（结果中小于等于0的表示不限制）
+func resolveRetentionPolicy(opts *Options, channelType uint8, channelInfo *wkdb.ChannelInfo) RetentionPolicy {
+	policy := RetentionPolicy{
+		MaxAge:   opts.Retention.MaxAge,
+		MaxCount: opts.Retention.MaxCount,
+		MaxBytes: opts.Retention.MaxBytes,
+	}
+	if typePolicy, ok := opts.Retention.ChannelTypes[channelType]; ok {
+		if typePolicy.MaxAge != 0 {
+			policy.MaxAge = typePolicy.MaxAge
+		}
+		if typePolicy.MaxCount != 0 {
+			policy.MaxCount = typePolicy.MaxCount
+		}
+		if typePolicy.MaxBytes != 0 {
+			policy.MaxBytes = typePolicy.MaxBytes
+		}
+	}
+	if channelInfo != nil {
+		if channelInfo.RetentionMaxAge != 0 {
+			policy.MaxAge = time.Duration(channelInfo.RetentionMaxAge) * time.Second
+		}
+		if channelInfo.RetentionMaxCount != 0 {
+			policy.MaxCount = channelInfo.RetentionMaxCount
+		}
+		if channelInfo.RetentionMaxBytes != 0 {
+			policy.MaxBytes = channelInfo.RetentionMaxBytes
+		}
+	}
+	return policy
+}
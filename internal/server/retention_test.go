@@ -0,0 +1,95 @@
+package server
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveRetentionPolicy(t *testing.T) {
+	opts := NewOptions()
+	opts.Retention.MaxAge = time.Hour * 24
+	opts.Retention.MaxCount = 1000
+	opts.Retention.ChannelTypes[wkproto.ChannelTypeGroup] = RetentionPolicy{
+		MaxCount: -1,
+		MaxBytes: 1024,
+	}
+
+	// 全局策略
+	policy := resolveRetentionPolicy(opts, wkproto.ChannelTypePerson, nil)
+	assert.Equal(t, RetentionPolicy{MaxAge: time.Hour * 24, MaxCount: 1000}, policy)
+
+	// 频道类型的策略覆盖全局策略
+	policy = resolveRetentionPolicy(opts, wkproto.ChannelTypeGroup, nil)
+	assert.Equal(t, RetentionPolicy{MaxAge: time.Hour * 24, MaxCount: -1, MaxBytes: 1024}, policy)
+
+	// 频道的策略覆盖频道类型的策略
+	policy = resolveRetentionPolicy(opts, wkproto.ChannelTypeGroup, &wkdb.ChannelInfo{
+		RetentionMaxAge:   60,
+		RetentionMaxCount: 10,
+		RetentionMaxBytes: -1,
+	})
+	assert.Equal(t, RetentionPolicy{MaxAge: time.Minute, MaxCount: 10, MaxBytes: -1}, policy)
+	assert.False(t, policy.unlimited())
+
+	policy = resolveRetentionPolicy(opts, wkproto.ChannelTypeGroup, &wkdb.ChannelInfo{
+		RetentionMaxAge:   -1,
+		RetentionMaxBytes: -1,
+	})
+	assert.True(t, policy.unlimited())
+}
+
+func TestRetentionKeepLastMessage(t *testing.T) {
+	s := NewTestServer(t)
+	s.opts.Retention.MaxAge = time.Minute
+	err := s.Start()
+	assert.NoError(t, err)
+	defer func() {
+		_ = s.Stop()
+	}()
+
+	s.MustWaitAllSlotsReady(time.Second * 10)
+
+	channelId := "g1"
+	channelType := wkproto.ChannelTypeGroup
+
+	// 所有消息都已过期
+	timestamp := int32(time.Now().Add(-time.Hour).Unix())
+	messages := make([]wkdb.Message, 0, 3)
+	for i := 1; i <= 3; i++ {
+		msg := wkdb.Message{}
+		msg.MessageID = int64(i)
+		msg.ChannelID = channelId
+		msg.ChannelType = channelType
+		msg.FromUID = "u1"
+		msg.Timestamp = timestamp
+		msg.Payload = []byte("hello")
+		messages = append(messages, msg)
+	}
+	_, err = s.store.AppendMessages(context.Background(), channelId, channelType, messages)
+	assert.NoError(t, err)
+
+	shardNo := wkutil.ChannelToKey(channelId, channelType)
+	lastIndex, lastTerm, err := s.store.GetMessageShardLogStorage().LastIndexAndTerm(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), lastIndex)
+
+	err = s.retention.checkChannel(retentionChannel{channelId: channelId, channelType: channelType, lastMsgSeq: lastIndex})
+	assert.NoError(t, err)
+
+	// 只保留最后一条消息
+	firstSeq, err := s.store.GetChannelFirstMessageSeq(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), firstSeq)
+
+	index, term, err := s.store.GetMessageShardLogStorage().LastIndexAndTerm(shardNo)
+	assert.NoError(t, err)
+	assert.Equal(t, lastIndex, index)
+	assert.Equal(t, lastTerm, term)
+	assert.NotZero(t, term)
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/RussellLuo/timingwheel"
@@ -16,6 +17,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
 	"github.com/WuKongIM/WuKongIM/pkg/promtail"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
@@ -57,9 +59,20 @@ type Server struct {
 
 	systemUIDManager *SystemUIDManager // 系统账号管理
 
-	tagManager     *tagManager     // tag管理，用来管理频道订阅者的tag，用于快速查找订阅者所在节点
-	deliverManager *deliverManager // 消息投递管理
-	retryManager   *retryManager   // 消息重试管理
+	tagManager     *tagManager           // tag管理，用来管理频道订阅者的tag，用于快速查找订阅者所在节点
+	deliverManager *deliverManager       // 消息投递管理
+	retryManager   *retryManager         // 消息重试管理
+	streamCatchUp  *streamCatchUpManager // 流消息补发
+	streamSeqs     *streamSeqAllocator   // 流序号分配
+	presence       *presenceManager      // 在线状态订阅
+	ephemeral      *ephemeralManager     // 临时信号
+	ipAccess       *ipAccessManager      // IP访问控制
+	apiKeyManager  *apiKeyManager        // api key管理
+	auditManager   *auditManager         // 审计日志管理
+	retention      *retentionManager     // 消息保留（过期消息清理）
+	coldStorage    *coldStorageManager   // 冷存储
+
+	managerUserManager *managerUserManager // 管理端用户管理
 
 	conversationManager *ConversationManager // 会话管理
 
@@ -69,6 +82,8 @@ type Server struct {
 
 	promtailServer *promtail.Promtail // 日志收集, 负责收集WuKongIM的日志 上报给Loki
 
+	configReloadLock sync.Mutex // 重新加载配置的锁
+
 }
 
 func New(opts *Options) *Server {
@@ -100,6 +115,22 @@ func New(opts *Options) *Server {
 
 	gin.SetMode(opts.GinMode)
 
+	// 静态数据加密
+	var encryptFS *encryption.FS
+	if s.opts.Encryption.On {
+		encryptFS, _, err = NewEncryptFS(s.opts)
+		if err != nil {
+			s.Panic("create encrypt fs error", zap.Error(err))
+		}
+		if s.opts.Encryption.MigrateOnStart {
+			count, err := EncryptDataDirs(encryptFS, s.opts)
+			if err != nil {
+				s.Panic("encrypt data dirs error", zap.Error(err))
+			}
+			s.Info("encrypt data dirs", zap.Int("count", count))
+		}
+	}
+
 	// 初始化存储
 	storeOpts := clusterstore.NewOptions(s.opts.Cluster.NodeId)
 	storeOpts.DataDir = path.Join(s.opts.DataDir, "db")
@@ -108,12 +139,27 @@ func New(opts *Options) *Server {
 	storeOpts.IsCmdChannel = opts.IsCmdChannel
 	storeOpts.Db.ShardNum = s.opts.Db.ShardNum
 	storeOpts.Db.MemTableSize = s.opts.Db.MemTableSize
+	storeOpts.EncryptFS = encryptFS
+	if s.opts.ColdStorage.On {
+		coldStore, err := newColdBlobStore(s.opts)
+		if err != nil {
+			s.Panic("create cold store error", zap.Error(err))
+		}
+		storeOpts.Db.ColdStore = coldStore
+		storeOpts.Db.ColdSegmentMaxCount = s.opts.ColdStorage.SegmentMaxCount
+		storeOpts.Db.ColdSegmentCacheCount = s.opts.ColdStorage.CacheCount
+	}
 	s.store = clusterstore.NewStore(storeOpts)
 
 	// 数据源
 	s.datasource = NewDatasource(s)
 	// 初始化tag管理
 	s.tagManager = newTagManager(s)
+	// 初始化IP访问控制
+	s.ipAccess = newIPAccessManager(s)
+	if err := s.ipAccess.loadFromOptions(); err != nil {
+		s.Panic("load ip access rules error", zap.Error(err))
+	}
 
 	// 初始化长连接引擎
 	s.engine = wknet.NewEngine(
@@ -121,6 +167,8 @@ func New(opts *Options) *Server {
 		wknet.WithWSAddr(s.opts.WSAddr),
 		wknet.WithWSSAddr(s.opts.WSSAddr),
 		wknet.WithWSTLSConfig(s.opts.WSTLSConfig),
+		wknet.WithWSCompression(s.opts.Compression.WSOn),
+		wknet.WithWSCompressThreshold(s.opts.Compression.Threshold),
 		wknet.WithOnReadBytes(func(n int) {
 			trace.GlobalTrace.Metrics.System().ExtranetIncomingAdd(int64(n))
 		}),
@@ -136,6 +184,16 @@ func New(opts *Options) *Server {
 	s.apiServer = NewAPIServer(s)                     // api服务
 	s.managerServer = NewManagerServer(s)             // 管理者的api服务
 	s.retryManager = newRetryManager(s)               // 消息重试管理
+	s.streamCatchUp = newStreamCatchUpManager(s)      // 流消息补发
+	s.streamSeqs = newStreamSeqAllocator(s)           // 流序号分配
+	s.presence = newPresenceManager(s)                // 在线状态订阅
+	s.ephemeral = newEphemeralManager(s)              // 临时信号
+	s.retention = newRetentionManager(s)              // 消息保留
+	s.coldStorage = newColdStorageManager(s)          // 冷存储
+	s.apiKeyManager = newApiKeyManager(s)             // api key管理
+	s.auditManager = newAuditManager(s)               // 审计日志管理
+	s.managerUserManager = newManagerUserManager(s)   // 管理端用户管理
+	s.opts.Auth.Provider = s.managerUserManager       // 管理端用户参与鉴权
 	s.conversationManager = NewConversationManager(s) // 会话管理
 	s.migrateTask = NewMigrateTask(s)                 // 迁移任务
 
@@ -169,6 +227,7 @@ func New(opts *Options) *Server {
 			cluster.WithAppVersion(version.Version),
 			cluster.WithDB(s.store.DB()),
 			cluster.WithSlotDbShardNum(s.opts.Db.SlotShardNum),
+			cluster.WithEncryptFS(encryptFS),
 			cluster.WithOnSlotApply(func(slotId uint32, logs []replica.Log) error {
 
 				return s.store.OnMetaApply(slotId, logs)
@@ -180,6 +239,9 @@ func New(opts *Options) *Server {
 			cluster.WithChannelReactorSubCount(s.opts.Cluster.ChannelReactorSubCount),
 			cluster.WithSlotReactorSubCount(s.opts.Cluster.SlotReactorSubCount),
 			cluster.WithPongMaxTick(s.opts.Cluster.PongMaxTick),
+			cluster.WithReadIndexTimeout(s.opts.Cluster.ReadIndexTimeout),
+			cluster.WithConsistencyCheckInterval(s.opts.Cluster.ConsistencyCheckInterval),
+			cluster.WithConsistencyCheckWindow(s.opts.Cluster.ConsistencyCheckWindow),
 			cluster.WithAuth(s.opts.Auth),
 			cluster.WithServiceName(s.opts.Trace.ServiceName),
 			cluster.WithLokiUrl(s.opts.Logger.Loki.Url),
@@ -264,6 +326,21 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	err = s.apiKeyManager.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.auditManager.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.managerUserManager.start()
+	if err != nil {
+		return err
+	}
+
 	err = s.trace.Start()
 	if err != nil {
 		return err
@@ -318,6 +395,36 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	err = s.streamCatchUp.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.streamSeqs.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.presence.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.ephemeral.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.retention.start()
+	if err != nil {
+		return err
+	}
+
+	err = s.coldStorage.start()
+	if err != nil {
+		return err
+	}
+
 	if s.opts.Conversation.On {
 		err = s.conversationManager.Start()
 		if err != nil {
@@ -332,7 +439,7 @@ func (s *Server) Start() error {
 		s.migrateTask.Run()
 	}
 
-	if s.opts.LokiOn() {
+	if s.promtailServer != nil {
 		err = s.promtailServer.Start()
 		if err != nil {
 			return err
@@ -357,6 +464,20 @@ func (s *Server) Stop() error {
 
 	s.retryManager.stop()
 
+	s.streamCatchUp.stop()
+
+	s.streamSeqs.stop()
+
+	s.presence.stop()
+
+	s.ephemeral.stop()
+
+	s.retention.stop()
+
+	s.coldStorage.stop()
+
+	s.auditManager.stop()
+
 	if s.opts.Conversation.On {
 		s.conversationManager.Stop()
 	}
@@ -383,10 +504,12 @@ func (s *Server) Stop() error {
 	s.timingWheel.Stop()
 
 	s.tagManager.stop()
+	s.apiKeyManager.stop()
+	s.managerUserManager.stop()
 
 	s.webhook.Stop()
 
-	if s.opts.LokiOn() {
+	if s.promtailServer != nil {
 		s.promtailServer.Stop()
 	}
 
@@ -449,9 +572,21 @@ func (s *Server) onConnect(conn wknet.Conn) error {
 	if size > 0 {
 		_, _ = conn.Discard(size)
 	}
+	// IP访问控制（每个连接只检查一次）
+	s.checkConnIPAccess(conn)
 	return nil
 }
 
+// checkConnIPAccess 检查连接是否允许访问（需要在代理协议解析之后调用），不允许的连接直接关闭
+func (s *Server) checkConnIPAccess(conn wknet.Conn) bool {
+	if s.ipAccess.allowConn(conn) {
+		return true
+	}
+	s.Info("ip access denied,conn will be closed", zap.String("remoteAddr", conn.RemoteAddr().String()), zap.Int64("connID", conn.ID()))
+	conn.Close()
+	return false
+}
+
 // 解析代理协议，获取真实IP
 // func (s *Server) handleProxyProto(buff []byte) error {
 // 	remoteAddr, size, err := parseProxyProto(buff)
@@ -471,6 +606,7 @@ func (s *Server) onClose(conn wknet.Conn) {
 	if connCtxObj != nil {
 		connCtx := connCtxObj.(*connContext)
 		s.userReactor.removeConnById(connCtx.uid, connCtx.connId)
+		s.presence.removeConn(connCtx)
 
 		if connCtx.isAuth.Load() {
 			deviceOnlineCount := s.userReactor.getConnCountByDeviceFlag(connCtx.uid, connCtx.deviceFlag)
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
@@ -20,6 +21,10 @@ func (s *Server) handleClusterMessage(fromNodeId uint64, msg *proto.Message) {
 		go s.handleNodePing(fromNodeId, msg)
 	case ClusterMsgTypeNodePong: // 节点Pong
 		go s.handleNodePong(fromNodeId, msg)
+	case ClusterMsgTypePresence: // 用户在线状态变化
+		go s.handlePresenceChange(fromNodeId, msg)
+	case ClusterMsgTypeEphemeralForward, ClusterMsgTypeEphemeralDeliver: // 临时信号
+		s.handleEphemeral(fromNodeId, msg)
 
 	}
 	// switch ClusterMsgType(msg.MsgType) {
@@ -61,6 +66,27 @@ func (s *Server) setClusterRoutes() {
 	// 频道重新创建ReceiverTag
 	s.cluster.Route("/wk/makeReceiverTag", s.handleMakeReceiverTag)
 
+	// 获取api key（存储api key的槽领导节点处理）
+	s.cluster.Route("/wk/getApiKeys", s.handleGetApiKeys)
+	// api key变更通知
+	s.cluster.Route("/wk/apiKeysChanged", s.handleApiKeysChanged)
+	// 搜索审计日志
+	s.cluster.Route("/wk/searchAuditLogs", s.handleSearchAuditLogs)
+	// 获取管理端用户
+	s.cluster.Route("/wk/getManagerUsers", s.handleGetManagerUsers)
+	// 管理端用户变更通知
+	s.cluster.Route("/wk/managerUsersChanged", s.handleManagerUsersChanged)
+	// 清理过期消息（频道领导通知副本）
+	s.cluster.Route("/wk/purgeMessages", s.handlePurgeMessages)
+	// 查询用户的在线状态（用户的领导节点处理）
+	s.cluster.Route("/wk/presenceQuery", s.handlePresenceQuery)
+	// 连接的离开状态变化（用户的领导节点处理）
+	s.cluster.Route("/wk/presenceAway", s.handlePresenceAway)
+	// 用户的最后在线时间对哪些查看者可见（用户的领导节点处理）
+	s.cluster.Route("/wk/presenceVisible", s.handlePresenceVisible)
+	// 批量获取流消息分片（频道所在槽的领导节点处理）
+	s.cluster.Route("/wk/getStreamChunks", s.handleGetStreamChunks)
+
 }
 
 func (s *Server) handleChannelForward(c *wkserver.Context) {
@@ -267,6 +293,8 @@ func (s *Server) handleUserAuthResult(c *wkserver.Context) {
 		connCtx.deviceLevel = authResult.DeviceLevel
 		connCtx.deviceId = authResult.DeviceId
 		connCtx.protoVersion = authResult.ProtoVersion
+		connCtx.compression = authResult.Compression
+		connCtx.scopes = authResult.Scopes
 		connCtx.isAuth.Store(true)
 		connCtx.conn.SetMaxIdle(s.opts.ConnIdleTime)
 		connack := &wkproto.ConnackPacket{
@@ -277,7 +305,13 @@ func (s *Server) handleUserAuthResult(c *wkserver.Context) {
 			NodeId:        s.opts.Cluster.NodeId,
 		}
 		connack.HasServerVersion = authResult.ProtoVersion > 3 // 如果协议版本大于3，就返回serverVersion
-		_ = connCtx.writePacket(connack)
+		data, err := connCtx.encodeConnack(connack)
+		if err != nil {
+			s.Error("encode connack err", zap.Error(err))
+			c.WriteErr(err)
+			return
+		}
+		_ = connCtx.write(data, wkproto.CONNACK)
 	} else {
 		connCtx.isAuth.Store(false)
 		_ = connCtx.writePacket(&wkproto.ConnackPacket{
@@ -541,3 +575,146 @@ func (s *Server) handleMakeReceiverTag(c *wkserver.Context) {
 	}
 	c.WriteOk()
 }
+
+func (s *Server) handleGetApiKeys(c *wkserver.Context) {
+	apiKeys, err := s.store.GetApiKeys()
+	if err != nil {
+		s.Error("handleGetApiKeys: GetApiKeys failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	data, err := apiKeysResp(apiKeys).Marshal()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (s *Server) handleApiKeysChanged(c *wkserver.Context) {
+	if err := s.apiKeyManager.reload(); err != nil {
+		s.Error("handleApiKeysChanged: reload failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (s *Server) handleSearchAuditLogs(c *wkserver.Context) {
+	var req wkdb.AuditLogSearchReq
+	if err := wkutil.ReadJSONByByte(c.Body(), &req); err != nil {
+		s.Error("handleSearchAuditLogs: unmarshal failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	logs, err := s.store.SearchAuditLogs(req)
+	if err != nil {
+		s.Error("handleSearchAuditLogs: SearchAuditLogs failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	data, err := wkdb.AuditLogs(logs).Marshal()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (s *Server) handleGetStreamChunks(c *wkserver.Context) {
+	var streamNos []string
+	if err := wkutil.ReadJSONByByte(c.Body(), &streamNos); err != nil {
+		s.Error("handleGetStreamChunks: unmarshal failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	results, err := s.getStreamChunksBatch(streamNos)
+	if err != nil {
+		s.Error("handleGetStreamChunks: getStreamChunksBatch failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.Write([]byte(wkutil.ToJSON(results)))
+}
+
+func (s *Server) handleGetManagerUsers(c *wkserver.Context) {
+	users, err := s.store.GetManagerUsers()
+	if err != nil {
+		s.Error("handleGetManagerUsers: GetManagerUsers failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	data, err := managerUsersResp(users).Marshal()
+	if err != nil {
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (s *Server) handleManagerUsersChanged(c *wkserver.Context) {
+	if err := s.managerUserManager.reload(); err != nil {
+		s.Error("handleManagerUsersChanged: reload failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.WriteOk()
+}
+
+func (s *Server) handlePresenceChange(fromNodeId uint64, msg *proto.Message) {
+	change := &presenceChange{}
+	if err := change.Unmarshal(msg.Content); err != nil {
+		s.Error("handlePresenceChange Unmarshal err", zap.Error(err), zap.Uint64("fromNodeId", fromNodeId))
+		return
+	}
+	s.presence.handleChange(change)
+}
+
+func (s *Server) handlePresenceQuery(c *wkserver.Context) {
+	var req PresenceQueryReq
+	if err := wkutil.ReadJSONByByte(c.Body(), &req); err != nil {
+		s.Error("handlePresenceQuery: unmarshal failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	infos := s.presence.queryLocal(req.ViewerUID, req.UIDs)
+	c.Write([]byte(wkutil.ToJSON(infos)))
+}
+
+func (s *Server) handlePresenceAway(c *wkserver.Context) {
+	req := &presenceAwayReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("handlePresenceAway: unmarshal failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	if err := s.presence.setAway(req.uid, req.nodeId, req.connId, req.away); err != nil {
+		s.Warn("handlePresenceAway: set away failed", zap.Error(err), zap.String("uid", req.uid), zap.Uint64("nodeId", req.nodeId), zap.Int64("connId", req.connId))
+		c.WriteErrorAndStatus(err, proto.Status(errCodeConnNotFound))
+		return
+	}
+	c.WriteOk()
+}
+
+func (s *Server) handlePresenceVisible(c *wkserver.Context) {
+	req := &presenceVisibleReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("handlePresenceVisible: unmarshal failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	resp := &presenceVisibleReq{
+		uid:     req.uid,
+		viewers: s.presence.visibleViewersLocal(req.uid, req.viewers),
+	}
+	c.Write(resp.Marshal())
+}
+
+func (s *Server) handleEphemeral(fromNodeId uint64, msg *proto.Message) {
+	signal := &ephemeralSignal{}
+	if err := signal.Unmarshal(msg.Content); err != nil {
+		s.Error("handleEphemeral Unmarshal err", zap.Error(err), zap.Uint64("fromNodeId", fromNodeId))
+		return
+	}
+	s.ephemeral.addSignal(signal)
+}
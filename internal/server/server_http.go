@@ -1,13 +1,15 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
-	"strings"
 
 	cluster "github.com/WuKongIM/WuKongIM/pkg/cluster/clusterserver"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/gin-contrib/pprof"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -41,18 +43,14 @@ func NewAPIServer(s *Server) *APIServer {
 // Start 开始
 func (s *APIServer) Start() {
 
-	s.r.Use(func(c *wkhttp.Context) { // 管理者权限判断
-		if strings.TrimSpace(s.s.opts.ManagerToken) == "" {
-			c.Next()
-			return
-		}
-		managerToken := c.GetHeader("token")
-		if managerToken != s.s.opts.ManagerToken {
-			c.AbortWithStatus(http.StatusUnauthorized)
-			return
-		}
-		c.Next()
-	})
+	// IP访问控制
+	s.r.Use(s.s.ipAccess.middleware(IPAccessListenerAPI))
+
+	// 审计日志（需要在认证之前，认证失败的请求也需要记录）
+	s.r.Use(s.s.auditManager.middleware())
+
+	// 管理者token和api key认证
+	s.r.Use(s.s.apiKeyManager.authMiddleware())
 
 	// 跨域
 	s.r.Use(wkhttp.CORSMiddleware())
@@ -95,6 +93,10 @@ func (s *APIServer) setRoutes() {
 	u := NewUserAPI(s.s)
 	u.Route(s.r)
 
+	// 端到端加密公钥API
+	e2ee := NewE2eeAPI(s.s)
+	e2ee.Route(s.r)
+
 	// 频道相关API
 	channel := NewChannelAPI(s.s)
 	channel.Route(s.r)
@@ -115,6 +117,22 @@ func (s *APIServer) setRoutes() {
 	stream := NewStreamAPI(s.s)
 	stream.Route(s.r)
 
+	// ip访问控制api
+	ipAccess := NewIPAccessAPI(s.s)
+	ipAccess.Route(s.r)
+
+	// 配置api
+	config := NewConfigAPI(s.s)
+	config.Route(s.r)
+
+	// api key管理api
+	apiKey := NewApiKeyAPI(s.s)
+	apiKey.Route(s.r)
+
+	// 审计日志api
+	audit := NewAuditAPI(s.s)
+	audit.Route(s.r)
+
 	// 分布式api
 	clusterServer, ok := s.s.cluster.(*cluster.Server)
 	if ok {
@@ -127,6 +145,26 @@ func (s *APIServer) setRoutes() {
 
 }
 
+// forwardToNodeIfNeed 请求参数中的node_id不是当前节点时，将请求转发到指定节点（用于只对当前节点生效的接口）
+func (s *Server) forwardToNodeIfNeed(c *wkhttp.Context, bodyBytes []byte) bool {
+	nodeId := wkutil.ParseUint64(c.Query("node_id"))
+	if nodeId == 0 || nodeId == s.opts.Cluster.NodeId {
+		return false
+	}
+	node, err := s.cluster.NodeInfoById(nodeId)
+	if err != nil {
+		c.ResponseError(err)
+		return true
+	}
+	if node == nil {
+		s.Error("node not found", zap.Uint64("nodeId", nodeId))
+		c.ResponseError(errors.New("node not found"))
+		return true
+	}
+	c.ForwardWithBody(fmt.Sprintf("%s%s", node.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+	return true
+}
+
 func bandwidthMiddleware() wkhttp.HandlerFunc {
 
 	return func(c *wkhttp.Context) {
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"io/fs"
 	"net/http"
@@ -37,6 +38,10 @@ func NewManagerServer(s *Server) *ManagerServer {
 
 func (m *ManagerServer) Start() {
 
+	// IP访问控制
+	m.r.Use(m.s.ipAccess.middleware(IPAccessListenerManager))
+	// 审计日志（需要在认证之前，认证失败的请求也需要记录）
+	m.r.Use(m.s.auditManager.middleware())
 	m.r.Use(wkhttp.CORSMiddleware())
 	// jwt和token认证中间件
 	m.r.Use(m.jwtAndTokenAuthMiddleware())
@@ -90,6 +95,14 @@ func (m *ManagerServer) setRoutes() {
 	manager := NewManagerAPI(m.s)
 	manager.Route(m.r)
 
+	// ip访问控制api
+	ipAccess := NewIPAccessAPI(m.s)
+	ipAccess.Route(m.r)
+
+	// 审计日志api
+	audit := NewAuditAPI(m.s)
+	audit.Route(m.r)
+
 	// // 系统api
 	// system := NewSystemAPI(s.s)
 	// system.Route(s.r)
@@ -112,6 +125,10 @@ func (m *ManagerServer) jwtAndTokenAuthMiddleware() wkhttp.HandlerFunc {
 			c.Next()
 			return
 		}
+		if strings.HasPrefix(fpath, "/manager/oidc/") { // OIDC登录不需要认证
+			c.Next()
+			return
+		}
 		if strings.HasPrefix(fpath, "/web") {
 			c.Next()
 			return
@@ -147,28 +164,60 @@ func (m *ManagerServer) jwtAndTokenAuthMiddleware() wkhttp.HandlerFunc {
 			return
 		}
 
-		jwtToken, err := jwt.ParseWithClaims(authorization, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(m.s.opts.Jwt.Secret), nil
-		})
+		username, err := parseManagerJwt(authorization, m.s.opts.Jwt.Secret)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
 			c.Abort()
 			return
 		}
 
-		if !jwtToken.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid jwt token"})
-			c.Abort()
-			return
-		}
-		mapCaims := jwtToken.Claims.(jwt.MapClaims)
-		if mapCaims["username"] == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid jwt token, username is empty"})
+		// 用户被删除或禁用后，已签发的jwt失效
+		if m.s.opts.Auth.On && !m.s.opts.Auth.HasUser(username) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found or disabled"})
 			c.Abort()
 			return
 		}
 
-		c.Set("username", mapCaims["username"])
+		c.Set("username", username)
 		c.Next()
 	}
 }
+
+// managerJwtUsername 获取请求中管理端jwt的用户名，jwt无效时返回空
+func managerJwtUsername(c *wkhttp.Context, secret string) string {
+	authorization := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if authorization == "" {
+		return ""
+	}
+	username, err := parseManagerJwt(authorization, secret)
+	if err != nil {
+		return ""
+	}
+	return username
+}
+
+// parseManagerJwt 校验管理端签发的jwt并返回用户名
+// 只接受HS256签名，带purpose的jwt（例如oidc的state）不能作为登录凭证
+func parseManagerJwt(tokenStr string, secret string) (string, error) {
+	if strings.TrimSpace(secret) == "" {
+		return "", errors.New("jwt secret is empty")
+	}
+	token, err := jwt.ParseWithClaims(tokenStr, jwt.MapClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", err
+	}
+	if !token.Valid {
+		return "", errors.New("Invalid jwt token")
+	}
+	claims := token.Claims.(jwt.MapClaims)
+	if _, ok := claims["purpose"]; ok {
+		return "", errors.New("Invalid jwt token")
+	}
+	username, _ := claims["username"].(string)
+	if strings.TrimSpace(username) == "" {
+		return "", errors.New("Invalid jwt token, username is empty")
+	}
+	return username, nil
+}
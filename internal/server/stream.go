@@ -58,7 +58,7 @@ func (s *stream) tick() {
 func (s *stream) payloadUnDecryptMessages() []ReactorChannelMessage {
 	s.payloadDecrypting = true
 	s.payloadDecryptingTick = 0
-	msgs := s.msgQueue.sliceWithSize(s.msgQueue.payloadDecryptingIndex, s.msgQueue.lastIndex, 1024*1024*2)
+	msgs := s.msgQueue.sliceWithSize(s.msgQueue.payloadDecryptingIndex+1, s.msgQueue.lastIndex+1, 1024*1024*2)
 	return msgs
 }
 
@@ -110,7 +110,7 @@ func (s *stream) deliverFinish(messages []ReactorChannelMessage) {
 func (s *stream) unDeliverMessages() []ReactorChannelMessage {
 	s.delivering = true
 	s.deliveringTick = 0
-	msgs := s.msgQueue.sliceWithSize(s.msgQueue.deliveringIndex, s.msgQueue.payloadDecryptingIndex, 1024*1024*2)
+	msgs := s.msgQueue.sliceWithSize(s.msgQueue.deliveringIndex+1, s.msgQueue.payloadDecryptingIndex+1, 1024*1024*2)
 	return msgs
 }
 
@@ -118,7 +118,7 @@ func (s *stream) unDeliverMessages() []ReactorChannelMessage {
 func (s *stream) unforwardMessages() []ReactorChannelMessage {
 	s.forwarding = true
 	s.forwardTick = 0
-	msgs := s.msgQueue.sliceWithSize(s.msgQueue.forwardingIndex, s.msgQueue.payloadDecryptingIndex, 1024*1024*2)
+	msgs := s.msgQueue.sliceWithSize(s.msgQueue.forwardingIndex+1, s.msgQueue.payloadDecryptingIndex+1, 1024*1024*2)
 	return msgs
 }
 
@@ -0,0 +1,196 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 流消息补发
+//
+// 节点投递流消息分片给本节点的用户时，缓存进行中的流的分片以及接收者，
+// 接收者的连接认证成功后（断线重连或者中途上线），把缓存的分片补发给新连接，客户端通过 stream_no + stream_seq 去重。
+// 缓存超过 Stream.CatchUpMaxSize 的流不再补发，客户端需要通过 /stream/chunks 获取。
+type streamCatchUpManager struct {
+	mu          sync.Mutex
+	streams     map[string]*catchUpStream      // streamNo -> 进行中的流
+	userStreams map[string]map[string]struct{} // uid -> 参与的streamNo集合
+
+	s          *Server
+	cleanTimer *timingwheel.Timer
+	wklog.Log
+}
+
+type catchUpStream struct {
+	streamNo    string
+	channelId   string
+	channelType uint8
+	tagKey      string
+	messages    []ReactorChannelMessage // 已投递的分片（按流序号有序）
+	messageIds  map[int64]struct{}      // 已缓存分片的消息id，用于去重
+	size        int                     // 已缓存分片的字节数
+	full        bool                    // 超过缓存大小，不再补发
+	uids        map[string]struct{}     // 接收者
+	activeAt    time.Time               // 最后收到分片的时间
+}
+
+func newStreamCatchUpManager(s *Server) *streamCatchUpManager {
+	return &streamCatchUpManager{
+		streams:     make(map[string]*catchUpStream),
+		userStreams: make(map[string]map[string]struct{}),
+		s:           s,
+		Log:         wklog.NewWKLog("streamCatchUpManager"),
+	}
+}
+
+func (m *streamCatchUpManager) start() error {
+	if !m.s.opts.Stream.CatchUpOn {
+		return nil
+	}
+	m.cleanTimer = m.s.Schedule(time.Minute, m.cleanIdle)
+	return nil
+}
+
+func (m *streamCatchUpManager) stop() {
+	if m.cleanTimer != nil {
+		m.cleanTimer.Stop()
+	}
+}
+
+// add 缓存投递给uids的流消息分片
+func (m *streamCatchUpManager) add(req *deliverReq, uids []string) {
+	if !m.s.opts.Stream.CatchUpOn || len(uids) == 0 {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, msg := range req.messages {
+		if msg.SendPacket == nil || msg.SendPacket.StreamNo == "" {
+			continue
+		}
+		streamNo := msg.SendPacket.StreamNo
+		st := m.streams[streamNo]
+		if st == nil {
+			st = &catchUpStream{
+				streamNo:    streamNo,
+				channelId:   req.channelId,
+				channelType: req.channelType,
+				tagKey:      req.tagKey,
+				uids:        make(map[string]struct{}),
+				messageIds:  make(map[int64]struct{}),
+			}
+			m.streams[streamNo] = st
+		}
+		st.activeAt = time.Now()
+		for _, uid := range uids {
+			if _, ok := st.uids[uid]; ok {
+				continue
+			}
+			st.uids[uid] = struct{}{}
+			streamNos := m.userStreams[uid]
+			if streamNos == nil {
+				streamNos = make(map[string]struct{})
+				m.userStreams[uid] = streamNos
+			}
+			streamNos[streamNo] = struct{}{}
+		}
+		if _, ok := st.messageIds[msg.MessageId]; ok || st.full {
+			continue
+		}
+		st.size += len(msg.SendPacket.Payload)
+		if st.size > m.s.opts.Stream.CatchUpMaxSize {
+			m.Debug("stream cache is full, stop catch up", zap.String("streamNo", streamNo), zap.Int("size", st.size))
+			st.full = true
+			st.messages = nil
+			st.messageIds = nil
+			continue
+		}
+		st.messageIds[msg.MessageId] = struct{}{}
+		st.messages = append(st.messages, msg)
+	}
+}
+
+// catchUp 给认证成功的连接补发其参与的进行中的流消息分片
+func (m *streamCatchUpManager) catchUp(conn *connContext) {
+	if !m.s.opts.Stream.CatchUpOn {
+		return
+	}
+	reqs := m.catchUpReqs(conn.uid)
+	if len(reqs) == 0 {
+		return
+	}
+	d := m.s.deliverManager.nextDeliver()
+	for _, req := range reqs {
+		m.Debug("stream catch up", zap.String("uid", conn.uid), zap.String("deviceId", conn.deviceId), zap.String("channelId", req.channelId), zap.Int("chunks", len(req.messages)))
+		d.deliverToConns(req, []*connContext{conn})
+	}
+}
+
+func (m *streamCatchUpManager) catchUpReqs(uid string) []*deliverReq {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	streamNos := m.userStreams[uid]
+	if len(streamNos) == 0 {
+		return nil
+	}
+	reqs := make([]*deliverReq, 0, len(streamNos))
+	for streamNo := range streamNos {
+		st := m.streams[streamNo]
+		if st == nil || st.full || len(st.messages) == 0 || m.idle(st) {
+			continue
+		}
+		messages := make([]ReactorChannelMessage, len(st.messages))
+		copy(messages, st.messages)
+		reqs = append(reqs, &deliverReq{
+			channelId:   st.channelId,
+			channelType: st.channelType,
+			channelKey:  wkutil.ChannelToKey(st.channelId, st.channelType),
+			tagKey:      st.tagKey,
+			messages:    messages,
+			isStream:    true,
+		})
+	}
+	return reqs
+}
+
+// end 流已结束，不再补发
+func (m *streamCatchUpManager) end(streamNo string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.remove(streamNo)
+}
+
+func (m *streamCatchUpManager) cleanIdle() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for streamNo, st := range m.streams {
+		if m.idle(st) {
+			m.remove(streamNo)
+		}
+	}
+}
+
+func (m *streamCatchUpManager) idle(st *catchUpStream) bool {
+	return time.Since(st.activeAt) > m.s.opts.Stream.CatchUpIdleTimeout
+}
+
+func (m *streamCatchUpManager) remove(streamNo string) {
+	st := m.streams[streamNo]
+	if st == nil {
+		return
+	}
+	delete(m.streams, streamNo)
+	for uid := range st.uids {
+		streamNos := m.userStreams[uid]
+		delete(streamNos, streamNo)
+		if len(streamNos) == 0 {
+			delete(m.userStreams, uid)
+		}
+	}
+}
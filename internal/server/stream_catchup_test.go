@@ -0,0 +1,80 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStreamMessage(messageId int64, index uint64, streamNo string, payload string) ReactorChannelMessage {
+	return ReactorChannelMessage{
+		MessageId: messageId,
+		Index:     index,
+		SendPacket: &wkproto.SendPacket{
+			ChannelID:   "g1",
+			ChannelType: wkproto.ChannelTypeGroup,
+			StreamNo:    streamNo,
+			Payload:     []byte(payload),
+		},
+	}
+}
+
+func TestStreamCatchUp(t *testing.T) {
+	opts := NewOptions()
+	m := newStreamCatchUpManager(&Server{opts: opts})
+
+	req := &deliverReq{
+		channelId:   "g1",
+		channelType: wkproto.ChannelTypeGroup,
+		messages: []ReactorChannelMessage{
+			newTestStreamMessage(1, 1, "s1", "hello"),
+			newTestStreamMessage(2, 2, "s1", "world"),
+			{MessageId: 3, SendPacket: &wkproto.SendPacket{Payload: []byte("normal")}}, // 普通消息不缓存
+		},
+	}
+	m.add(req, []string{"u1", "u2"})
+	// 重复投递的分片不重复缓存
+	m.add(&deliverReq{channelId: "g1", channelType: wkproto.ChannelTypeGroup, messages: req.messages[1:2]}, []string{"u1"})
+
+	reqs := m.catchUpReqs("u1")
+	assert.Equal(t, 1, len(reqs))
+	assert.Equal(t, "g1", reqs[0].channelId)
+	assert.True(t, reqs[0].isStream)
+	assert.Equal(t, 2, len(reqs[0].messages))
+	assert.Equal(t, uint64(1), reqs[0].messages[0].Index)
+	assert.Equal(t, uint64(2), reqs[0].messages[1].Index)
+
+	assert.Empty(t, m.catchUpReqs("u3"))
+
+	// 流结束后不再补发
+	m.end("s1")
+	assert.Empty(t, m.catchUpReqs("u1"))
+	assert.Empty(t, m.userStreams)
+}
+
+func TestStreamCatchUpLimit(t *testing.T) {
+	opts := NewOptions()
+	opts.Stream.CatchUpMaxSize = 8
+	opts.Stream.CatchUpIdleTimeout = time.Millisecond * 10
+	m := newStreamCatchUpManager(&Server{opts: opts})
+
+	// 超过缓存大小的流不再补发
+	m.add(&deliverReq{channelId: "g1", channelType: wkproto.ChannelTypeGroup, messages: []ReactorChannelMessage{
+		newTestStreamMessage(1, 1, "s1", "hello"),
+		newTestStreamMessage(2, 2, "s1", "world"),
+	}}, []string{"u1"})
+	assert.Empty(t, m.catchUpReqs("u1"))
+
+	// 空闲超时的流被清理
+	m.add(&deliverReq{channelId: "g1", channelType: wkproto.ChannelTypeGroup, messages: []ReactorChannelMessage{
+		newTestStreamMessage(3, 1, "s2", "hi"),
+	}}, []string{"u1"})
+	assert.Equal(t, 1, len(m.catchUpReqs("u1")))
+	time.Sleep(time.Millisecond * 20)
+	assert.Empty(t, m.catchUpReqs("u1"))
+	m.cleanIdle()
+	assert.Empty(t, m.streams)
+	assert.Empty(t, m.userStreams)
+}
@@ -0,0 +1,80 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RussellLuo/timingwheel"
+)
+
+// 流序号分配
+//
+// 流消息分片的流序号在频道领导上分配，每个流内有序递增。
+// 开启了分片存储时从已存储的最后一个分片序号继续分配（节点重启或者领导切换后不会重复），
+// 没有开启存储时序号只保存在内存中，流结束（空闲超时）后移除。
+type streamSeqAllocator struct {
+	mu      sync.Mutex
+	streams map[string]*streamSeq // streamNo -> 流序号
+
+	s          *Server
+	cleanTimer *timingwheel.Timer
+}
+
+type streamSeq struct {
+	seq      uint64    // 最后分配的流序号
+	activeAt time.Time // 最后分配的时间
+}
+
+func newStreamSeqAllocator(s *Server) *streamSeqAllocator {
+	return &streamSeqAllocator{
+		streams: make(map[string]*streamSeq),
+		s:       s,
+	}
+}
+
+func (a *streamSeqAllocator) start() error {
+	a.cleanTimer = a.s.Schedule(time.Minute, a.cleanIdle)
+	return nil
+}
+
+func (a *streamSeqAllocator) stop() {
+	if a.cleanTimer != nil {
+		a.cleanTimer.Stop()
+	}
+}
+
+// next 分配流的下一个流序号
+func (a *streamSeqAllocator) next(streamNo string) (uint64, error) {
+	var lastId uint64
+	if a.s.opts.Stream.StoreOn {
+		var err error
+		if lastId, err = a.s.store.GetStreamLastId(streamNo); err != nil {
+			return 0, err
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	st := a.streams[streamNo]
+	if st == nil {
+		st = &streamSeq{}
+		a.streams[streamNo] = st
+	}
+	if lastId > st.seq {
+		st.seq = lastId
+	}
+	st.seq++
+	st.activeAt = time.Now()
+	return st.seq, nil
+}
+
+func (a *streamSeqAllocator) cleanIdle() {
+	idleTimeout := a.s.opts.Reactor.Stream.TickInterval * time.Duration(a.s.opts.Reactor.Stream.IdleTimeoutTick)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for streamNo, st := range a.streams {
+		if time.Since(st.activeAt) > idleTimeout {
+			delete(a.streams, streamNo)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
@@ -133,6 +134,7 @@ func (r *userReactor) handleAuth(uid string, msg ReactorUserMessage) (wkproto.Re
 	var (
 		connectPacket = msg.InPacket.(*wkproto.ConnectPacket)
 		devceLevel    wkproto.DeviceLevel
+		scopes        []string
 		isLocalConn   = msg.FromNodeId == r.s.opts.Cluster.NodeId // 是否是本地连接
 	)
 	var connCtx *connContext
@@ -164,7 +166,7 @@ func (r *userReactor) handleAuth(uid string, msg ReactorUserMessage) (wkproto.Re
 			return wkproto.ReasonAuthFail, nil
 		}
 		devceLevel = wkproto.DeviceLevelSlave // 默认都是slave设备
-	} else if r.s.opts.TokenAuthOn {
+	} else if r.s.opts.Hot().TokenAuthOn {
 		if connectPacket.Token == "" {
 			r.Error("token is empty")
 			r.authResponseConnackAuthFail(connCtx)
@@ -177,12 +179,14 @@ func (r *userReactor) handleAuth(uid string, msg ReactorUserMessage) (wkproto.Re
 			return wkproto.ReasonAuthFail, err
 
 		}
-		if device.Token != connectPacket.Token {
-			r.Error("token verify fail", zap.String("expectToken", device.Token), zap.String("actToken", connectPacket.Token), zap.Any("conn", connCtx))
-			r.authResponseConnackAuthFail(connCtx)
-			return wkproto.ReasonAuthFail, errors.New("token verify fail")
+		reasonCode, err := verifyDeviceToken(device, connectPacket.Token, time.Now())
+		if err != nil {
+			r.Error("token verify fail", zap.Error(err), zap.String("uid", uid), zap.Timep("tokenExpireAt", device.TokenExpireAt), zap.Strings("scopes", device.Scopes), zap.Any("conn", connCtx))
+			r.authResponseConnack(connCtx, reasonCode)
+			return reasonCode, err
 		}
 		devceLevel = wkproto.DeviceLevel(device.DeviceLevel)
+		scopes = device.Scopes
 	} else {
 		devceLevel = wkproto.DeviceLevelSlave // 默认都是slave设备
 	}
@@ -273,7 +277,11 @@ func (r *userReactor) handleAuth(uid string, msg ReactorUserMessage) (wkproto.Re
 	connCtx.aesIV = aesIV
 	connCtx.aesKey = aesKey
 	connCtx.deviceLevel = devceLevel
+	connCtx.scopes = scopes
 	connCtx.protoVersion = lastVersion
+	if r.s.opts.Compression.On { // 负载压缩协商
+		connCtx.compression = wkutil.SelectCompression(msg.Compression, r.s.opts.Compression.Algorithms)
+	}
 	connCtx.isAuth.Store(true)
 
 	if connCtx.isRealConn {
@@ -297,15 +305,34 @@ func (r *userReactor) handleAuth(uid string, msg ReactorUserMessage) (wkproto.Re
 	}
 	connack.HasServerVersion = hasServerVersion
 	r.authResponse(connCtx, connack)
+
+	// 补发进行中的流消息分片（需要在连接回执之后）
+	r.s.streamCatchUp.catchUp(connCtx)
 	// -------------------- user online --------------------
 	// 在线webhook
 	deviceOnlineCount := r.s.userReactor.getConnCountByDeviceFlag(uid, connectPacket.DeviceFlag)
 	totalOnlineCount := r.s.userReactor.getConnCount(uid)
 	r.s.webhook.Online(uid, connectPacket.DeviceFlag, connCtx.connId, deviceOnlineCount, totalOnlineCount)
+	// 在线状态
+	r.s.presence.refresh(uid)
 
 	return wkproto.ReasonSuccess, nil
 }
 
+// verifyDeviceToken 校验连接的token：token一致、没有过期并且拥有建立长连接的授权范围
+func verifyDeviceToken(device wkdb.Device, token string, now time.Time) (wkproto.ReasonCode, error) {
+	if device.Token != token {
+		return wkproto.ReasonAuthFail, errors.New("token verify fail")
+	}
+	if device.TokenExpired(now) {
+		return ReasonTokenExpired, errors.New("token expired")
+	}
+	if !device.HasScope(TokenScopeConnect) {
+		return wkproto.ReasonAuthFail, errors.New("token has no connect scope")
+	}
+	return wkproto.ReasonSuccess, nil
+}
+
 // 获取客户端的aesKey和aesIV
 // dhServerPrivKey  服务端私钥
 func (r *userReactor) getClientAesKeyAndIV(clientKey string, dhServerPrivKey [32]byte) ([]byte, []byte, error) {
@@ -328,7 +355,12 @@ func (r *userReactor) getClientAesKeyAndIV(clientKey string, dhServerPrivKey [32
 
 func (r *userReactor) authResponse(connCtx *connContext, packet *wkproto.ConnackPacket) {
 	if connCtx.isRealConn {
-		_ = connCtx.writeDirectlyPacket(packet)
+		data, err := connCtx.encodeConnack(packet)
+		if err != nil {
+			r.Error("encode connack error", zap.String("uid", connCtx.uid), zap.Error(err))
+			return
+		}
+		_ = connCtx.writeDirectly(data, 0)
 	} else {
 		status, err := r.requestUserAuthResult(connCtx.realNodeId, &UserAuthResult{
 			ReasonCode:   packet.ReasonCode,
@@ -340,6 +372,8 @@ func (r *userReactor) authResponse(connCtx *connContext, packet *wkproto.Connack
 			AesIV:        string(connCtx.aesIV),
 			DeviceLevel:  connCtx.deviceLevel,
 			ProtoVersion: connCtx.protoVersion,
+			Compression:  connCtx.compression,
+			Scopes:       connCtx.scopes,
 		})
 		if err != nil {
 			r.Error("requestUserAuthResult error", zap.String("uid", connCtx.uid), zap.String("deviceId", connCtx.deviceId), zap.Error(err))
@@ -395,6 +429,8 @@ type UserAuthResult struct {
 	AesIV        string
 	DeviceLevel  wkproto.DeviceLevel
 	ProtoVersion uint8
+	Compression  string   // 协商的负载压缩算法
+	Scopes       []string // token的授权范围
 }
 
 func (u *UserAuthResult) Marshal() ([]byte, error) {
@@ -409,6 +445,8 @@ func (u *UserAuthResult) Marshal() ([]byte, error) {
 	encoder.WriteString(u.AesIV)
 	encoder.WriteUint8(uint8(u.DeviceLevel))
 	encoder.WriteUint8(u.ProtoVersion)
+	encoder.WriteString(u.Compression)
+	encoder.WriteString(strings.Join(u.Scopes, ","))
 	return encoder.Bytes(), nil
 }
 
@@ -449,6 +487,24 @@ func (u *UserAuthResult) Unmarshal(data []byte) error {
 	if u.ProtoVersion, err = decoder.Uint8(); err != nil {
 		return err
 	}
+
+	// compression（兼容老版本节点，没有则不解析）
+	if decoder.Len() > 0 {
+		if u.Compression, err = decoder.String(); err != nil {
+			return err
+		}
+	}
+
+	// scopes（兼容老版本节点，没有则不解析）
+	if decoder.Len() > 0 {
+		var scopes string
+		if scopes, err = decoder.String(); err != nil {
+			return err
+		}
+		if scopes != "" {
+			u.Scopes = strings.Split(scopes, ",")
+		}
+	}
 	return nil
 }
 
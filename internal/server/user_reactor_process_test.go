@@ -0,0 +1,69 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyDeviceToken(t *testing.T) {
+	now := time.Now()
+	expireAt := now.Add(-time.Second)
+
+	reasonCode, err := verifyDeviceToken(wkdb.Device{Token: "t1"}, "t1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, wkproto.ReasonSuccess, reasonCode)
+
+	reasonCode, err = verifyDeviceToken(wkdb.Device{Token: "t1"}, "t2", now)
+	assert.Error(t, err)
+	assert.Equal(t, wkproto.ReasonAuthFail, reasonCode)
+
+	reasonCode, err = verifyDeviceToken(wkdb.Device{Token: "t1", TokenExpireAt: &expireAt}, "t1", now)
+	assert.Error(t, err)
+	assert.Equal(t, ReasonTokenExpired, reasonCode)
+
+	// 没有connect授权范围的token不能建立连接
+	reasonCode, err = verifyDeviceToken(wkdb.Device{Token: "t1", Scopes: []string{TokenScopeMessageSend}}, "t1", now)
+	assert.Error(t, err)
+	assert.Equal(t, wkproto.ReasonAuthFail, reasonCode)
+
+	reasonCode, err = verifyDeviceToken(wkdb.Device{Token: "t1", Scopes: []string{TokenScopeConnect}}, "t1", now)
+	assert.NoError(t, err)
+	assert.Equal(t, wkproto.ReasonSuccess, reasonCode)
+}
+
+func TestConnContextHasScope(t *testing.T) {
+	conn := &connContext{}
+	assert.True(t, conn.hasScope(TokenScopeMessageSend))
+
+	// 只有connect授权范围的连接不能发送消息
+	conn.scopes = []string{TokenScopeConnect}
+	assert.False(t, conn.hasScope(TokenScopeMessageSend))
+
+	conn.scopes = []string{TokenScopeConnect, TokenScopeMessageSend}
+	assert.True(t, conn.hasScope(TokenScopeMessageSend))
+}
+
+func TestUserAuthResultScopes(t *testing.T) {
+	result := &UserAuthResult{
+		ReasonCode: wkproto.ReasonSuccess,
+		Uid:        "u1",
+		Scopes:     []string{TokenScopeConnect},
+	}
+	data, err := result.Marshal()
+	assert.NoError(t, err)
+
+	result2 := &UserAuthResult{}
+	err = result2.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{TokenScopeConnect}, result2.Scopes)
+}
+
+func TestCheckTokenScopes(t *testing.T) {
+	assert.NoError(t, checkTokenScopes(nil))
+	assert.NoError(t, checkTokenScopes([]string{TokenScopeConnect, TokenScopeMessageSend}))
+	assert.Error(t, checkTokenScopes([]string{"message:delete"}))
+}
@@ -268,6 +268,7 @@ func (u *userReactorSub) removeConnById(uid string, id int64) {
 		return
 	}
 	uh.removeConnById(id)
+	u.r.s.presence.refresh(uid) // 连接变化，重新计算在线状态
 }
 
 func (u *userReactorSub) removeConnsByNodeId(uid string, nodeId uint64) []*connContext {
@@ -277,7 +278,9 @@ func (u *userReactorSub) removeConnsByNodeId(uid string, nodeId uint64) []*connC
 	if uh == nil {
 		return nil
 	}
-	return uh.removeConnsByNodeId(nodeId)
+	conns := uh.removeConnsByNodeId(nodeId)
+	u.r.s.presence.refresh(uid) // 连接变化，重新计算在线状态
+	return conns
 }
 
 func (u *userReactorSub) removeUserHandler(uid string) {
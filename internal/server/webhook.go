@@ -31,7 +31,9 @@ type webhook struct {
 	eventPool        *ants.Pool
 	httpClient       *http.Client
 	webhookGRPCPool  *grpcpool.Pool // webhook grpc客户端
+	grpcPoolLock     sync.RWMutex
 	stoped           chan struct{}
+	reloadC          chan struct{} // 配置重新加载的通知
 	onlinestatusLock sync.RWMutex
 	onlinestatusList []string
 }
@@ -46,13 +48,8 @@ func newWebhook(s *Server) *webhook {
 	var (
 		webhookGRPCPool *grpcpool.Pool
 	)
-	if s.opts.WebhookGRPCOn() {
-		webhookGRPCPool, err = grpcpool.New(func() (*grpc.ClientConn, error) {
-			return grpc.Dial(s.opts.Webhook.GRPCAddr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithKeepaliveParams(keepalive.ClientParameters{
-				Time:    5 * time.Minute, // send pings every 5 minute if there is no activity
-				Timeout: 2 * time.Second, // wait 1 second for ping ack before considering the connection dead
-			}))
-		}, 2, 20, time.Minute*5) // 初始化2个连接 最多20个连接
+	if s.opts.Hot().WebhookGRPCOn() {
+		webhookGRPCPool, err = newWebhookGRPCPool(s.opts.Hot().Webhook.GRPCAddr)
 		if err != nil {
 			panic(err)
 		}
@@ -65,6 +62,7 @@ func newWebhook(s *Server) *webhook {
 		webhookGRPCPool:  webhookGRPCPool,
 		onlinestatusList: make([]string, 0),
 		stoped:           make(chan struct{}),
+		reloadC:          make(chan struct{}, 1),
 		httpClient: &http.Client{
 			Transport: &http.Transport{
 				DialContext: (&net.Dialer{
@@ -92,6 +90,44 @@ func (w *webhook) Stop() {
 	close(w.stoped)
 }
 
+func newWebhookGRPCPool(addr string) (*grpcpool.Pool, error) {
+	return grpcpool.New(func() (*grpc.ClientConn, error) {
+		return grpc.Dial(addr, grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    5 * time.Minute, // send pings every 5 minute if there is no activity
+			Timeout: 2 * time.Second, // wait 1 second for ping ack before considering the connection dead
+		}))
+	}, 2, 20, time.Minute*5) // 初始化2个连接 最多20个连接
+}
+
+// reload 配置重新加载后调用，grpcAddrChanged表示webhook的grpc地址是否发生变化
+func (w *webhook) reload(grpcAddrChanged bool) error {
+	if grpcAddrChanged {
+		var (
+			pool *grpcpool.Pool
+			err  error
+		)
+		if w.s.opts.Hot().WebhookGRPCOn() {
+			pool, err = newWebhookGRPCPool(w.s.opts.Hot().Webhook.GRPCAddr)
+			if err != nil {
+				return err
+			}
+		}
+		w.grpcPoolLock.Lock()
+		oldPool := w.webhookGRPCPool
+		w.webhookGRPCPool = pool
+		w.grpcPoolLock.Unlock()
+		if oldPool != nil {
+			oldPool.Close()
+		}
+	}
+	// 通知消息推送循环按新的间隔推送
+	select {
+	case w.reloadC <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
 // Online 用户设备上线通知
 func (w *webhook) Online(uid string, deviceFlag wkproto.DeviceFlag, connId int64, deviceOnlineCount int, totalOnlineCount int) {
 	w.onlinestatusLock.Lock()
@@ -114,7 +150,7 @@ func (w *webhook) Offline(uid string, deviceFlag wkproto.DeviceFlag, connId int6
 
 // TriggerEvent 触发事件
 func (w *webhook) TriggerEvent(event *Event) {
-	if !w.s.opts.WebhookOn() { // 没设置webhook直接忽略
+	if !w.s.opts.Hot().WebhookOn() { // 没设置webhook直接忽略
 		return
 	}
 	err := w.eventPool.Submit(func() {
@@ -124,7 +160,7 @@ func (w *webhook) TriggerEvent(event *Event) {
 			return
 		}
 
-		if w.s.opts.WebhookGRPCOn() {
+		if w.s.opts.Hot().WebhookGRPCOn() {
 			err = w.sendWebhookForGRPC(event.Event, jsonData)
 		} else {
 			err = w.sendWebhookForHttp(event.Event, jsonData)
@@ -191,92 +227,115 @@ func (w *webhook) notifyOfflineMsg(msg ReactorChannelMessage, subscribers []stri
 // 通知上层应用 TODO: 此初报错可以做一个邮件报警处理类的东西，
 func (w *webhook) notifyQueueLoop() {
 	errorSleepTime := time.Second * 1 // 发生错误后sleep时间
-	ticker := time.NewTicker(w.s.opts.Webhook.MsgNotifyEventPushInterval)
+	ticker := time.NewTicker(w.s.opts.Hot().Webhook.MsgNotifyEventPushInterval)
 	defer ticker.Stop()
 	errMessageIDMap := make(map[int64]int) // 记录错误的消息ID value为错误次数
-	if w.s.opts.WebhookOn() {
-		for {
-			messages, err := w.s.store.GetMessagesOfNotifyQueue(w.s.opts.Webhook.MsgNotifyEventCountPerPush)
+	for {
+		if !w.s.opts.Hot().WebhookOn() { // 未开启webhook（可通过重新加载配置开启）
+			if !w.waitNotifyTick(ticker) {
+				return
+			}
+			continue
+		}
+		messages, err := w.s.store.GetMessagesOfNotifyQueue(w.s.opts.Hot().Webhook.MsgNotifyEventCountPerPush)
+		if err != nil {
+			w.Error("获取通知队列内的消息失败！", zap.Error(err))
+			time.Sleep(errorSleepTime) // 如果报错就休息下
+			continue
+		}
+		if len(messages) > 0 {
+			messageResps := make([]*MessageResp, 0, len(messages))
+			for _, msg := range messages {
+				resp := &MessageResp{}
+				resp.from(msg, w.s)
+				messageResps = append(messageResps, resp)
+			}
+			messageData, err := json.Marshal(messageResps)
 			if err != nil {
-				w.Error("获取通知队列内的消息失败！", zap.Error(err))
+				w.Error("第三方消息通知的event数据不能json化！", zap.Error(err))
 				time.Sleep(errorSleepTime) // 如果报错就休息下
 				continue
 			}
-			if len(messages) > 0 {
-				messageResps := make([]*MessageResp, 0, len(messages))
-				for _, msg := range messages {
-					resp := &MessageResp{}
-					resp.from(msg, w.s)
-					messageResps = append(messageResps, resp)
-				}
-				messageData, err := json.Marshal(messageResps)
-				if err != nil {
-					w.Error("第三方消息通知的event数据不能json化！", zap.Error(err))
-					time.Sleep(errorSleepTime) // 如果报错就休息下
-					continue
-				}
 
-				if w.s.opts.WebhookGRPCOn() {
-					err = w.sendWebhookForGRPC(EventMsgNotify, messageData)
-				} else {
-					err = w.sendWebhookForHttp(EventMsgNotify, messageData)
+			if w.s.opts.Hot().WebhookGRPCOn() {
+				err = w.sendWebhookForGRPC(EventMsgNotify, messageData)
+			} else {
+				err = w.sendWebhookForHttp(EventMsgNotify, messageData)
+			}
+			if err != nil {
+				w.Error("请求所有消息通知webhook失败！", zap.Error(err))
+				errMessageIDs := make([]int64, 0, len(messages))
+				for _, message := range messages {
+					errCount := errMessageIDMap[message.MessageID]
+					errCount++
+					errMessageIDMap[message.MessageID] = errCount
+					if errCount >= w.s.opts.Hot().Webhook.MsgNotifyEventRetryMaxCount {
+						errMessageIDs = append(errMessageIDs, message.MessageID)
+					}
 				}
-				if err != nil {
-					w.Error("请求所有消息通知webhook失败！", zap.Error(err))
-					errMessageIDs := make([]int64, 0, len(messages))
-					for _, message := range messages {
-						errCount := errMessageIDMap[message.MessageID]
-						errCount++
-						errMessageIDMap[message.MessageID] = errCount
-						if errCount >= w.s.opts.Webhook.MsgNotifyEventRetryMaxCount {
-							errMessageIDs = append(errMessageIDs, message.MessageID)
-						}
+				if len(errMessageIDs) > 0 {
+					w.Error("消息通知失败超过最大次数！", zap.Int64s("messageIDs", errMessageIDs))
+					err = w.s.store.RemoveMessagesOfNotifyQueue(errMessageIDs)
+					if err != nil {
+						w.Warn("从通知队列里移除消息失败！", zap.Error(err), zap.Int64s("messageIDs", errMessageIDs))
 					}
-					if len(errMessageIDs) > 0 {
-						w.Error("消息通知失败超过最大次数！", zap.Int64s("messageIDs", errMessageIDs))
-						err = w.s.store.RemoveMessagesOfNotifyQueue(errMessageIDs)
-						if err != nil {
-							w.Warn("从通知队列里移除消息失败！", zap.Error(err), zap.Int64s("messageIDs", errMessageIDs))
-						}
-						for _, errMessageID := range errMessageIDs {
-							delete(errMessageIDMap, errMessageID)
-						}
+					for _, errMessageID := range errMessageIDs {
+						delete(errMessageIDMap, errMessageID)
 					}
-					time.Sleep(errorSleepTime) // 如果报错就休息下
-					continue
 				}
+				time.Sleep(errorSleepTime) // 如果报错就休息下
+				continue
+			}
 
-				messageIDs := make([]int64, 0, len(messages))
-				for _, message := range messages {
-					messageID := message.MessageID
-					messageIDs = append(messageIDs, messageID)
+			messageIDs := make([]int64, 0, len(messages))
+			for _, message := range messages {
+				messageID := message.MessageID
+				messageIDs = append(messageIDs, messageID)
 
-					delete(errMessageIDMap, messageID)
-				}
-				err = w.s.store.RemoveMessagesOfNotifyQueue(messageIDs)
-				if err != nil {
-					w.Warn("从通知队列里移除消息失败！", zap.Error(err), zap.Int64s("messageIDs", messageIDs), zap.String("Webhook", w.s.opts.Webhook.HTTPAddr))
-					time.Sleep(errorSleepTime) // 如果报错就休息下
-					continue
-				}
+				delete(errMessageIDMap, messageID)
 			}
-
-			select {
-			case <-ticker.C:
-			case <-w.stoped:
-				return
+			err = w.s.store.RemoveMessagesOfNotifyQueue(messageIDs)
+			if err != nil {
+				w.Warn("从通知队列里移除消息失败！", zap.Error(err), zap.Int64s("messageIDs", messageIDs), zap.String("Webhook", w.s.opts.Hot().Webhook.HTTPAddr))
+				time.Sleep(errorSleepTime) // 如果报错就休息下
+				continue
 			}
 		}
+
+		if !w.waitNotifyTick(ticker) {
+			return
+		}
 	}
 }
 
-func (w *webhook) loopOnlineStatus() {
-	if !w.s.opts.WebhookOn() {
-		return
+// 等待下次推送，配置重新加载后按新的间隔推送，返回false表示webhook已停止
+func (w *webhook) waitNotifyTick(ticker *time.Ticker) bool {
+	select {
+	case <-ticker.C:
+	case <-w.reloadC:
+		ticker.Reset(w.s.opts.Hot().Webhook.MsgNotifyEventPushInterval)
+	case <-w.stoped:
+		return false
 	}
+	return true
+}
+
+func (w *webhook) loopOnlineStatus() {
 	opLen := 0    // 最后一次操作在线状态数组的长度
 	errCount := 0 // webhook请求失败重试次数
 	for {
+		if !w.s.opts.Hot().WebhookOn() { // 未开启webhook则丢弃在线状态（可通过重新加载配置开启）
+			w.onlinestatusLock.Lock()
+			w.onlinestatusList = w.onlinestatusList[:0]
+			opLen = 0
+			w.onlinestatusLock.Unlock()
+			select {
+			case <-time.After(time.Second * 2):
+			case <-w.stoped:
+				return
+			}
+			continue
+		}
 		if opLen == 0 {
 			w.onlinestatusLock.Lock()
 			opLen = len(w.onlinestatusList)
@@ -296,7 +355,7 @@ func (w *webhook) loopOnlineStatus() {
 			continue
 		}
 
-		if w.s.opts.WebhookGRPCOn() {
+		if w.s.opts.Hot().WebhookGRPCOn() {
 			err = w.sendWebhookForGRPC(EventOnlineStatus, jsonData)
 		} else {
 			err = w.sendWebhookForHttp(EventOnlineStatus, jsonData)
@@ -304,8 +363,8 @@ func (w *webhook) loopOnlineStatus() {
 		if err != nil {
 			errCount++
 			w.Error("请求在线状态webhook失败！", zap.Error(err))
-			if errCount >= w.s.opts.Webhook.MsgNotifyEventRetryMaxCount {
-				w.Error("请求在线状态webhook失败通知超过最大次数！", zap.Int("MsgNotifyEventRetryMaxCount", w.s.opts.Webhook.MsgNotifyEventRetryMaxCount))
+			if errCount >= w.s.opts.Hot().Webhook.MsgNotifyEventRetryMaxCount {
+				w.Error("请求在线状态webhook失败通知超过最大次数！", zap.Int("MsgNotifyEventRetryMaxCount", w.s.opts.Hot().Webhook.MsgNotifyEventRetryMaxCount))
 
 				w.onlinestatusLock.Lock()
 				w.onlinestatusList = w.onlinestatusList[opLen:]
@@ -328,19 +387,19 @@ func (w *webhook) loopOnlineStatus() {
 }
 
 func (w *webhook) sendWebhookForHttp(event string, data []byte) error {
-	eventURL := fmt.Sprintf("%s?event=%s", w.s.opts.Webhook.HTTPAddr, event)
+	eventURL := fmt.Sprintf("%s?event=%s", w.s.opts.Hot().Webhook.HTTPAddr, event)
 	startTime := time.Now().UnixNano() / 1000 / 1000
 	w.Debug("webhook开始请求", zap.String("eventURL", eventURL))
 	resp, err := w.httpClient.Post(eventURL, "application/json", bytes.NewBuffer(data))
 	w.Debug("webhook请求结束 耗时", zap.Int64("mill", time.Now().UnixNano()/1000/1000-startTime))
 	if err != nil {
-		w.Warn("调用第三方消息通知失败！", zap.String("Webhook", w.s.opts.Webhook.HTTPAddr), zap.Error(err))
+		w.Warn("调用第三方消息通知失败！", zap.String("Webhook", w.s.opts.Hot().Webhook.HTTPAddr), zap.Error(err))
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		w.Warn("第三方消息通知接口返回状态错误！", zap.Int("status", resp.StatusCode), zap.String("Webhook", w.s.opts.Webhook.HTTPAddr))
+		w.Warn("第三方消息通知接口返回状态错误！", zap.Int("status", resp.StatusCode), zap.String("Webhook", w.s.opts.Hot().Webhook.HTTPAddr))
 		return errors.New("第三方消息通知接口返回状态错误！")
 	}
 	return nil
@@ -354,7 +413,13 @@ func (w *webhook) sendWebhookForGRPC(event string, data []byte) error {
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second*2)
 	defer cancel()
-	clientConn, err := w.webhookGRPCPool.Get(ctx)
+	w.grpcPoolLock.RLock()
+	pool := w.webhookGRPCPool
+	w.grpcPoolLock.RUnlock()
+	if pool == nil {
+		return errors.New("webhook grpc未配置！")
+	}
+	clientConn, err := pool.Get(ctx)
 	if err != nil {
 		return err
 	}
@@ -389,6 +454,10 @@ const (
 	EventMsgNotify = "msg.notify"
 	// EventOnlineStatus 用户在线状态
 	EventOnlineStatus = "user.onlinestatus"
+	// EventAuditLog 审计日志
+	EventAuditLog = "audit.log"
+	// EventE2eeKeyChange 用户设备的端到端加密公钥变更
+	EventE2eeKeyChange = "user.e2ee_key_change"
 )
 
 // Event Event
@@ -28,6 +28,7 @@ type AuthConfig struct {
 	SuperToken string // 超级token
 	Kind       Kind   // 鉴权类型
 	Users      []UserConfig
+	Provider   UserProvider // 配置文件以外的用户来源，配置文件中的用户优先
 }
 
 func (a AuthConfig) Auth(username string, password string) error {
@@ -53,23 +54,7 @@ func (a AuthConfig) HasPermission(username string, rs resource.Id, action Action
 	if username == "" {
 		return false
 	}
-	if len(a.Users) == 0 {
-		return false
-	}
-	for _, user := range a.Users {
-		if user.Username == username {
-			for _, permission := range user.Permissions {
-				if permission.Resource == rs || permission.Resource == resource.All {
-					for _, a := range permission.Actions {
-						if a == ActionAll || a == action {
-							return true
-						}
-					}
-				}
-			}
-		}
-	}
-	return false
+	return a.Persmissions(username).HasPermission(rs, action)
 }
 
 func (a AuthConfig) HasPermissionWithContext(ctx *wkhttp.Context, rs resource.Id, action Action) bool {
@@ -77,17 +62,33 @@ func (a AuthConfig) HasPermissionWithContext(ctx *wkhttp.Context, rs resource.Id
 }
 
 func (a AuthConfig) Persmissions(username string) PermissionConfigs {
-	if len(a.Users) == 0 {
-		return nil
-	}
 	for _, user := range a.Users {
 		if user.Username == username {
 			return user.Permissions
 		}
 	}
+	if a.Provider != nil {
+		if permissions, ok := a.Provider.Permissions(username); ok {
+			return permissions
+		}
+	}
 	return nil
 }
 
+// HasUser 用户是否存在（配置文件中或用户来源中）
+func (a AuthConfig) HasUser(username string) bool {
+	for _, user := range a.Users {
+		if user.Username == username {
+			return true
+		}
+	}
+	if a.Provider != nil {
+		_, ok := a.Provider.Permissions(username)
+		return ok
+	}
+	return false
+}
+
 type UserConfig struct {
 	Username    string
 	Password    string
@@ -101,6 +102,32 @@ type PermissionConfig struct {
 
 type PermissionConfigs []PermissionConfig
 
+// HasPermission 是否拥有指定资源的操作权限
+func (p PermissionConfigs) HasPermission(rs resource.Id, action Action) bool {
+	for _, permission := range p {
+		if permission.Resource == rs || permission.Resource == resource.All {
+			for _, a := range permission.Actions {
+				if a == ActionAll || a == action {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Contains 是否包含other的所有权限
+func (p PermissionConfigs) Contains(other PermissionConfigs) bool {
+	for _, permission := range other {
+		for _, a := range permission.Actions {
+			if !p.HasPermission(permission.Resource, a) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (p PermissionConfigs) Format() string {
 	var str string
 	for i, permission := range p {
@@ -5,23 +5,104 @@ type Id string
 // 槽位资源
 var Slot = slot{
 	Migrate: "slotMigrate", // 迁移槽位
+	Repair:  "slotRepair",  // 修复不一致的槽副本
+}
+
+// 槽位查看（槽列表、槽配置、槽内频道）
+var ClusterSlot Id = "clusterslot"
+
+// 节点资源
+var ClusterNode Id = "clusternode"
+
+// 集群资源
+var Cluster = cluster{
+	Info: "clusterInfo", // 集群信息
+	Logs: "clusterLogs", // 节点日志
 }
 
 // 频道资源
 var ClusterChannel = channel{
+	Config:  "clusterchannel",        // 频道分布式配置、副本、状态
 	Migrate: "clusterchannelMigrate", // 迁移频道
 	Start:   "clusterchannelStart",   // 启动频道
 	Stop:    "clusterchannelStop",    // 停止频道
+	Repair:  "clusterchannelRepair",  // 修复不一致的频道副本
+}
+
+// 管理端数据查看资源
+var Data = data{
+	Message:      "dataMessage",      // 消息搜索、消息轨迹
+	Channel:      "dataChannel",      // 频道搜索、订阅者、黑白名单
+	User:         "dataUser",         // 用户、设备搜索
+	Conversation: "dataConversation", // 最近会话搜索
+}
+
+// 管理端资源
+var Manager = manager{
+	User: "managerUser", // 管理端用户
+}
+
+// http api资源
+var Api = api{
+	Message:      "apiMessage",      // 消息（发送、同步、搜索）
+	Channel:      "apiChannel",      // 频道（频道信息、订阅者、黑白名单）
+	Conversation: "apiConversation", // 最近会话
+	User:         "apiUser",         // 用户（在线状态、设备退出）
+	UserToken:    "apiUserToken",    // 用户token（更新、轮换、吊销）
+	Stream:       "apiStream",       // 流消息
+	Route:        "apiRoute",        // 路由
+	System:       "apiSystem",       // 系统（连接、系统变量、系统账号、IP访问控制、分布式等）
+	ApiKey:       "apiKey",          // api key管理
+}
+
+// 审计日志资源
+var Audit = audit{
+	Log: "auditLog", // 审计日志
 }
 
 type slot struct {
 	Migrate Id
+	Repair  Id
+}
+
+type cluster struct {
+	Info Id
+	Logs Id
 }
 
 type channel struct {
+	Config  Id
 	Migrate Id
 	Start   Id
 	Stop    Id
+	Repair  Id
+}
+
+type api struct {
+	Message      Id
+	Channel      Id
+	Conversation Id
+	User         Id
+	UserToken    Id
+	Stream       Id
+	Route        Id
+	System       Id
+	ApiKey       Id
+}
+
+type data struct {
+	Message      Id
+	Channel      Id
+	User         Id
+	Conversation Id
+}
+
+type manager struct {
+	User Id
+}
+
+type audit struct {
+	Log Id
 }
 
 var All Id = "*"
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"fmt"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+)
+
+// Role 管理端用户的角色
+type Role string
+
+const (
+	RoleViewer   Role = "viewer"   // 只读，查看集群和数据
+	RoleOperator Role = "operator" // 运维，在viewer的基础上可以迁移槽和频道、启停频道、修复副本、查看日志和审计日志
+	RoleAdmin    Role = "admin"    // 管理员，拥有所有权限（包括管理端用户的管理）
+)
+
+// 只读角色的权限
+var viewerPermissions = PermissionConfigs{
+	{Resource: resource.ClusterNode, Actions: Actions{ActionRead}},
+	{Resource: resource.ClusterSlot, Actions: Actions{ActionRead}},
+	{Resource: resource.Cluster.Info, Actions: Actions{ActionRead}},
+	{Resource: resource.ClusterChannel.Config, Actions: Actions{ActionRead}},
+	{Resource: resource.Data.Message, Actions: Actions{ActionRead}},
+	{Resource: resource.Data.Channel, Actions: Actions{ActionRead}},
+	{Resource: resource.Data.User, Actions: Actions{ActionRead}},
+	{Resource: resource.Data.Conversation, Actions: Actions{ActionRead}},
+}
+
+// 运维角色的权限
+var operatorPermissions = append(PermissionConfigs{
+	{Resource: resource.Cluster.Logs, Actions: Actions{ActionRead}},
+	{Resource: resource.Audit.Log, Actions: Actions{ActionRead}},
+	{Resource: resource.Slot.Migrate, Actions: Actions{ActionWrite}},
+	{Resource: resource.ClusterChannel.Migrate, Actions: Actions{ActionWrite}},
+	{Resource: resource.ClusterChannel.Start, Actions: Actions{ActionWrite}},
+	{Resource: resource.ClusterChannel.Stop, Actions: Actions{ActionWrite}},
+	{Resource: resource.Slot.Repair, Actions: Actions{ActionWrite}},
+	{Resource: resource.ClusterChannel.Repair, Actions: Actions{ActionWrite}},
+}, viewerPermissions...)
+
+// 管理员角色的权限
+var adminPermissions = PermissionConfigs{
+	{Resource: resource.All, Actions: Actions{ActionAll}},
+}
+
+// ParseRole 解析角色
+func ParseRole(v string) (Role, error) {
+	role := Role(v)
+	if !role.Valid() {
+		return "", fmt.Errorf("invalid role: %s", v)
+	}
+	return role, nil
+}
+
+// Valid 是否是有效的角色
+func (r Role) Valid() bool {
+	switch r {
+	case RoleViewer, RoleOperator, RoleAdmin:
+		return true
+	}
+	return false
+}
+
+// Permissions 角色拥有的权限
+func (r Role) Permissions() PermissionConfigs {
+	switch r {
+	case RoleViewer:
+		return viewerPermissions
+	case RoleOperator:
+		return operatorPermissions
+	case RoleAdmin:
+		return adminPermissions
+	}
+	return nil
+}
+
+// UserProvider 配置文件以外的用户来源（比如存储在集群中的管理端用户）
+type UserProvider interface {
+	// Permissions 获取用户的权限，用户不存在或被禁用时返回false
+	Permissions(username string) (PermissionConfigs, bool)
+}
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
+)
+
+// api key授权范围的资源名称
+var scopeResources = map[string]resource.Id{
+	"message":      resource.Api.Message,
+	"channel":      resource.Api.Channel,
+	"conversation": resource.Api.Conversation,
+	"user":         resource.Api.User,
+	"stream":       resource.Api.Stream,
+	"route":        resource.Api.Route,
+	"system":       resource.Api.System,
+	"apikey":       resource.Api.ApiKey,
+}
+
+// api key授权范围的操作名称
+var scopeActions = map[string]Action{
+	"*":     ActionAll,
+	"read":  ActionRead,
+	"write": ActionWrite,
+	"send":  ActionWrite,
+}
+
+// ParseScope 将api key的授权范围解析为权限配置
+// 支持的格式：
+// *  所有权限
+// read  所有资源的只读权限
+// user:token  用户token的管理权限
+// <资源>:<操作>  例如 message:send channel:write conversation:read，资源见scopeResources，操作见scopeActions
+func ParseScope(scope string) (PermissionConfig, error) {
+	scope = strings.ToLower(strings.TrimSpace(scope))
+	switch scope {
+	case "*":
+		return PermissionConfig{Resource: resource.All, Actions: Actions{ActionAll}}, nil
+	case "read", "readonly", "read-only":
+		return PermissionConfig{Resource: resource.All, Actions: Actions{ActionRead}}, nil
+	case "user:token":
+		return PermissionConfig{Resource: resource.Api.UserToken, Actions: Actions{ActionWrite}}, nil
+	}
+	strs := strings.Split(scope, ":")
+	if len(strs) != 2 {
+		return PermissionConfig{}, fmt.Errorf("invalid scope: %s", scope)
+	}
+	rs, ok := scopeResources[strs[0]]
+	if !ok {
+		return PermissionConfig{}, fmt.Errorf("invalid scope resource: %s", scope)
+	}
+	action, ok := scopeActions[strs[1]]
+	if !ok {
+		return PermissionConfig{}, fmt.Errorf("invalid scope action: %s", scope)
+	}
+	return PermissionConfig{Resource: rs, Actions: Actions{action}}, nil
+}
+
+// ParseScopes 将多个授权范围解析为权限配置
+func ParseScopes(scopes []string) (PermissionConfigs, error) {
+	permissions := make(PermissionConfigs, 0, len(scopes))
+	for _, scope := range scopes {
+		permission, err := ParseScope(scope)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, nil
+}
@@ -1,12 +1,14 @@
 package client
 
 import (
+	"context"
 	"encoding/base64"
 	"errors"
 	"fmt"
 	"io"
 	"math/rand"
 	"net"
+	"net/http"
 	"strconv"
 	"strings"
 	"sync"
@@ -56,8 +58,20 @@ type Client struct {
 	proto *wkproto.WKProto
 	pongs []chan struct{}
 
-	onRecv    OnRecv
-	onSendack OnSendack
+	onRecv     OnRecv
+	onSendack  OnSendack
+	onBackfill OnBackfill
+
+	store      Store        // 本地存储
+	httpClient *http.Client // 请求IM http api
+
+	onConnectStatus  OnConnectStatus
+	eventMu          sync.Mutex
+	events           []ConnectStatusEvent // 待回调的连接状态事件
+	eventDispatching bool
+
+	sendackMu sync.Mutex
+	sendacks  map[uint64]chan *wkproto.SendackPacket // clientSeq -> 等待发送回执的请求
 
 	err error
 
@@ -86,6 +100,12 @@ func New(addr string, opt ...Option) *Client {
 			buf: make([]byte, opts.DefaultBufSize),
 			off: -1,
 		},
+		store:      opts.Store,
+		httpClient: &http.Client{Timeout: opts.APITimeout},
+		sendacks:   make(map[uint64]chan *wkproto.SendackPacket),
+	}
+	if c.store == nil {
+		c.store = NewMemoryStore()
 	}
 
 	return c
@@ -96,6 +116,7 @@ func (c *Client) Connect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	c.emitStatus(ConnectStatusEvent{Status: CONNECTING})
 	_, err := c.createConn()
 	if err != nil {
 		return err
@@ -106,9 +127,12 @@ func (c *Client) Connect() error {
 		c.mu.Unlock()
 		c.close(DISCONNECTED, err)
 		c.mu.Lock()
+	} else {
+		c.emitStatus(ConnectStatusEvent{Status: CONNECTED})
 	}
 
-	if err != nil && c.opts.AutoReconn {
+	// 服务端拒绝连接（认证失败等）时重连也没有意义
+	if err != nil && c.opts.AutoReconn && !c.abortReconnect {
 		c.setup()
 		c.status = RECONNECTING
 		c.writer.switchToPending()
@@ -167,6 +191,11 @@ func (c *Client) close(status Status, err error) {
 	c.status = status
 
 	c.mu.Unlock()
+
+	if status == CLOSED {
+		c.failPendingSendacks()
+	}
+	c.emitStatus(ConnectStatusEvent{Status: status, Err: err})
 }
 
 func (c *Client) isClosed() bool {
@@ -307,9 +336,15 @@ func (c *Client) doReconnect() {
 
 		c.mu.Unlock()
 
+		c.emitStatus(ConnectStatusEvent{Status: CONNECTED})
+
 		// Make sure to flush everything
 		c.Flush()
 
+		if c.opts.APIURL != "" && c.opts.SyncOnReconnect {
+			go c.syncAfterReconnect()
+		}
+
 		return
 	}
 	if c.err == nil {
@@ -423,6 +458,7 @@ func (c *Client) processOpErr(err error) {
 		c.writer.switchToPending()
 		go c.doReconnect()
 		c.mu.Unlock()
+		c.emitStatus(ConnectStatusEvent{Status: RECONNECTING, Err: err})
 		return
 	}
 	c.status = DISCONNECTED
@@ -534,28 +570,59 @@ func (c *Client) handlePacket(frame wkproto.Frame) error {
 		c.handleRecvPacket(frame.(*wkproto.RecvPacket))
 	case wkproto.PONG: // pong
 		c.handlePong()
+	case wkproto.DISCONNECT: // 被服务端踢下线
+		c.handleDisconnectPacket(frame.(*wkproto.DisconnectPacket))
 	}
 	return nil
 }
 
 func (c *Client) handleSendackPacket(packet *wkproto.SendackPacket) {
+	c.sendackMu.Lock()
+	ch := c.sendacks[packet.ClientSeq]
+	delete(c.sendacks, packet.ClientSeq)
+	c.sendackMu.Unlock()
+	if ch != nil {
+		ch <- packet
+	}
 	if c.onSendack != nil {
 		c.onSendack(packet)
 	}
 }
 
+// 处理断开包，被踢后不再重连
+func (c *Client) handleDisconnectPacket(packet *wkproto.DisconnectPacket) {
+	c.Info("被服务端踢下线！", zap.String("reasonCode", packet.ReasonCode.String()), zap.String("reason", packet.Reason))
+	c.mu.Lock()
+	c.abortReconnect = true
+	c.mu.Unlock()
+	c.emitStatus(ConnectStatusEvent{Status: KICKED, ReasonCode: packet.ReasonCode, Reason: packet.Reason})
+	c.close(CLOSED, nil)
+}
+
+// 连接关闭，等待发送回执的请求都返回连接已关闭
+func (c *Client) failPendingSendacks() {
+	c.sendackMu.Lock()
+	for clientSeq, ch := range c.sendacks {
+		close(ch)
+		delete(c.sendacks, clientSeq)
+	}
+	c.sendackMu.Unlock()
+}
+
 // 处理接受包
 func (c *Client) handleRecvPacket(packet *wkproto.RecvPacket) {
 	var err error
 	var payload []byte
-	if c.onRecv != nil {
-		if !packet.Setting.IsSet(wkproto.SettingNoEncrypt) {
-			payload, err = wkutil.AesDecryptPkcs7Base64(packet.Payload, []byte(c.aesKey), []byte(c.salt))
-			if err != nil {
-				c.Panic("解密消息payload失败！", zap.Error(err), zap.String("payload", string(packet.Payload)), zap.String("aesKey", c.aesKey), zap.String("salt", c.salt))
-			}
-			packet.Payload = payload
+	if !packet.Setting.IsSet(wkproto.SettingNoEncrypt) {
+		payload, err = wkutil.AesDecryptPkcs7Base64(packet.Payload, []byte(c.aesKey), []byte(c.salt))
+		if err != nil {
+			c.Panic("解密消息payload失败！", zap.Error(err), zap.String("payload", string(packet.Payload)), zap.String("aesKey", c.aesKey), zap.String("salt", c.salt))
 		}
+		packet.Payload = payload
+	}
+	// 保存到本地存储，消息序号有断层则补齐
+	c.checkGapAndSave(newMessageWithRecv(packet))
+	if c.onRecv != nil {
 		err = c.onRecv(packet)
 	}
 	if err == nil {
@@ -581,7 +648,54 @@ func (c *Client) handlePong() {
 	}
 }
 
+// SendMessage 发送消息（不等待发送回执，回执通过 SetOnSendack 设置的事件返回）
 func (c *Client) SendMessage(channel *Channel, payload []byte, opt ...SendOption) error {
+	packet, err := c.newSendPacket(channel, payload, opt...)
+	if err != nil {
+		return err
+	}
+	c.lastSendMsgTime = time.Now()
+	return c.appendPacket(packet)
+}
+
+// SendMessageWithContext 发送消息并等待发送回执，服务端返回失败时返回 *ReasonError（errors.Is(err, ErrSendFailed)）
+func (c *Client) SendMessageWithContext(ctx context.Context, channel *Channel, payload []byte, opt ...SendOption) (*wkproto.SendackPacket, error) {
+	packet, err := c.newSendPacket(channel, payload, opt...)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *wkproto.SendackPacket, 1)
+	c.sendackMu.Lock()
+	c.sendacks[packet.ClientSeq] = ch
+	c.sendackMu.Unlock()
+
+	c.lastSendMsgTime = time.Now()
+	if err = c.appendPacket(packet); err != nil {
+		c.removeSendack(packet.ClientSeq)
+		return nil, err
+	}
+	select {
+	case sendack, ok := <-ch:
+		if !ok {
+			return nil, ErrConnectionClosed
+		}
+		if sendack.ReasonCode != wkproto.ReasonSuccess {
+			return sendack, &ReasonError{Err: ErrSendFailed, ReasonCode: sendack.ReasonCode}
+		}
+		return sendack, nil
+	case <-ctx.Done():
+		c.removeSendack(packet.ClientSeq)
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Client) removeSendack(clientSeq uint64) {
+	c.sendackMu.Lock()
+	delete(c.sendacks, clientSeq)
+	c.sendackMu.Unlock()
+}
+
+func (c *Client) newSendPacket(channel *Channel, payload []byte, opt ...SendOption) (*wkproto.SendPacket, error) {
 	opts := NewSendOptions()
 	if len(opt) > 0 {
 		for _, op := range opt {
@@ -596,11 +710,14 @@ func (c *Client) SendMessage(channel *Channel, payload []byte, opt ...SendOption
 		newPayload, err = wkutil.AesEncryptPkcs7Base64(payload, []byte(c.aesKey), []byte(c.salt))
 		if err != nil {
 			c.Error("加密消息payload失败！", zap.Error(err), zap.String("aesKey", c.aesKey), zap.String("salt", c.salt))
-			return err
+			return nil, err
 		}
 	} else {
 		setting.Set(wkproto.SettingNoEncrypt)
 	}
+	if opts.StreamNo != "" {
+		setting.Set(wkproto.SettingStream)
+	}
 
 	clientMsgNo := opts.ClientMsgNo
 	if clientMsgNo == "" {
@@ -616,6 +733,7 @@ func (c *Client) SendMessage(channel *Channel, payload []byte, opt ...SendOption
 		Setting:     setting,
 		ClientSeq:   clientSeq,
 		ClientMsgNo: clientMsgNo,
+		StreamNo:    opts.StreamNo,
 		ChannelID:   channel.ChannelID,
 		ChannelType: channel.ChannelType,
 		Payload:     newPayload,
@@ -628,13 +746,13 @@ func (c *Client) SendMessage(channel *Channel, payload []byte, opt ...SendOption
 		actMsgKey, err := wkutil.AesEncryptPkcs7Base64([]byte(signStr), []byte(c.aesKey), []byte(c.salt))
 		if err != nil {
 			c.Error("加密数据失败！", zap.Error(err))
-			return err
+			return nil, err
 		}
 		packet.MsgKey = wkutil.MD5(string(actMsgKey))
 	}
-	c.lastSendMsgTime = time.Now()
-	return c.appendPacket(packet)
+	return packet, nil
 }
+
 func (c *Client) Close() {
 	c.close(CLOSED, nil)
 }
@@ -698,7 +816,9 @@ func (c *Client) sendConnect() error {
 		return errors.New("返回包类型有误！不是连接回执包！")
 	}
 	if connack.ReasonCode != wkproto.ReasonSuccess {
-		return errors.New("连接失败！")
+		c.abortReconnect = true
+		c.emitStatus(ConnectStatusEvent{Status: AUTH_FAILED, ReasonCode: connack.ReasonCode})
+		return &ReasonError{Err: ErrConnectFailed, ReasonCode: connack.ReasonCode}
 	}
 	c.salt = connack.Salt
 
@@ -751,8 +871,7 @@ func (c *Client) appendPacket(packet wkproto.Frame) error {
 }
 
 func (c *Client) createConn() (net.Conn, error) {
-	network, address, _ := parseAddr(c.addr)
-	conn, err := net.DialTimeout(network, address, c.opts.Timeout)
+	conn, err := c.dial()
 	if err != nil {
 		return nil, err
 	}
@@ -2,7 +2,9 @@ package client
 
 import (
 	"errors"
+	"fmt"
 
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
 	"go.uber.org/atomic"
 )
 
@@ -15,6 +17,8 @@ const (
 	CLOSED
 	RECONNECTING
 	CONNECTING
+	KICKED      // 被服务端踢下线（例如同一设备在其他地方登录）
+	AUTH_FAILED // 服务端拒绝连接（例如token错误、用户被封禁）
 )
 
 func (s Status) String() string {
@@ -29,6 +33,10 @@ func (s Status) String() string {
 		return "RECONNECTING"
 	case CONNECTING:
 		return "CONNECTING"
+	case KICKED:
+		return "KICKED"
+	case AUTH_FAILED:
+		return "AUTH_FAILED"
 	}
 	return "unknown status"
 }
@@ -43,8 +51,24 @@ var (
 	ErrBadTimeout       = errors.New("wukongim timeout invalid")
 	ErrConnectionClosed = errors.New("wukongim connection closed")
 	ErrTimeout          = errors.New("wukongim timeout")
+	ErrConnectFailed    = errors.New("wukongim connect failed")
+	ErrSendFailed       = errors.New("wukongim send failed")
 )
 
+// ReasonError 服务端返回的失败原因，可以通过 errors.Is 判断是 ErrConnectFailed 还是 ErrSendFailed
+type ReasonError struct {
+	Err        error
+	ReasonCode wkproto.ReasonCode
+}
+
+func (e *ReasonError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Err.Error(), e.ReasonCode.String())
+}
+
+func (e *ReasonError) Unwrap() error {
+	return e.Err
+}
+
 type Statistics struct {
 	InMsgs     atomic.Uint64
 	OutMsgs    atomic.Uint64
@@ -0,0 +1,61 @@
+package client
+
+import (
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// ConnectStatusEvent 连接状态变化事件
+type ConnectStatusEvent struct {
+	Status     Status             // 当前状态
+	ReasonCode wkproto.ReasonCode // 服务端返回的原因（AUTH_FAILED、KICKED时有值）
+	Reason     string             // 服务端返回的原因描述（KICKED时有值）
+	Err        error              // 导致状态变化的错误（RECONNECTING、DISCONNECTED时可能有值）
+}
+
+// OnConnectStatus 连接状态变化事件
+// 事件在独立的协程里按发生顺序回调，回调里可以调用Client的方法
+type OnConnectStatus func(event ConnectStatusEvent)
+
+// SetOnConnectStatus 设置连接状态变化事件
+func (c *Client) SetOnConnectStatus(onConnectStatus OnConnectStatus) {
+	c.eventMu.Lock()
+	c.onConnectStatus = onConnectStatus
+	c.eventMu.Unlock()
+}
+
+// 触发连接状态事件（不阻塞，可以在持有c.mu时调用）
+func (c *Client) emitStatus(event ConnectStatusEvent) {
+	c.eventMu.Lock()
+	if c.onConnectStatus == nil {
+		c.eventMu.Unlock()
+		return
+	}
+	c.events = append(c.events, event)
+	if c.eventDispatching {
+		c.eventMu.Unlock()
+		return
+	}
+	c.eventDispatching = true
+	c.eventMu.Unlock()
+
+	go c.dispatchEvents()
+}
+
+func (c *Client) dispatchEvents() {
+	for {
+		c.eventMu.Lock()
+		if len(c.events) == 0 {
+			c.eventDispatching = false
+			c.eventMu.Unlock()
+			return
+		}
+		event := c.events[0]
+		c.events = c.events[1:]
+		onConnectStatus := c.onConnectStatus
+		c.eventMu.Unlock()
+
+		if onConnectStatus != nil {
+			onConnectStatus(event)
+		}
+	}
+}
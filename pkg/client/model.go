@@ -0,0 +1,110 @@
+package client
+
+import (
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// PullMode 消息拉取模式
+type PullMode int
+
+const (
+	PullModeDown PullMode = iota // 向下拉取（拉取更旧的消息）
+	PullModeUp                   // 向上拉取（拉取更新的消息）
+)
+
+// MessageHeader 消息头
+type MessageHeader struct {
+	NoPersist int `json:"no_persist"` // 是否不持久化
+	RedDot    int `json:"red_dot"`    // 是否显示红点
+	SyncOnce  int `json:"sync_once"`  // 此消息只被同步或被消费一次
+}
+
+// Message 消息（同步接口返回的消息和实时收到的消息统一为此结构）
+type Message struct {
+	Header      MessageHeader `json:"header"`              // 消息头
+	Setting     uint8         `json:"setting"`             // 设置
+	MessageID   int64         `json:"message_id"`          // 服务端的消息ID(全局唯一)
+	MessageSeq  uint64        `json:"message_seq"`         // 消息序列号（频道内有序递增）
+	ClientMsgNo string        `json:"client_msg_no"`       // 客户端消息唯一编号
+	StreamNo    string        `json:"stream_no,omitempty"` // 流编号
+	FromUID     string        `json:"from_uid"`            // 发送者UID
+	ChannelID   string        `json:"channel_id"`          // 频道ID
+	ChannelType uint8         `json:"channel_type"`        // 频道类型
+	Topic       string        `json:"topic,omitempty"`     // 话题ID
+	Expire      uint32        `json:"expire"`              // 消息过期时间
+	Timestamp   int32         `json:"timestamp"`           // 服务器消息时间戳(10位，到秒)
+	Payload     []byte        `json:"payload"`             // 消息内容（已解密）
+}
+
+// Channel 消息所属频道
+func (m *Message) Channel() *Channel {
+	return NewChannel(m.ChannelID, m.ChannelType)
+}
+
+// 是否是需要存储的消息（只有存储的消息才有消息序号，才能做断层检测）
+func (m *Message) persisted() bool {
+	return m.MessageSeq > 0 && m.Header.NoPersist == 0 && m.Header.SyncOnce == 0
+}
+
+// 从收到的消息包转换（payload需要已解密）
+func newMessageWithRecv(packet *wkproto.RecvPacket) *Message {
+	m := &Message{
+		Setting:     packet.Setting.Uint8(),
+		MessageID:   packet.MessageID,
+		MessageSeq:  uint64(packet.MessageSeq),
+		ClientMsgNo: packet.ClientMsgNo,
+		StreamNo:    packet.StreamNo,
+		FromUID:     packet.FromUID,
+		ChannelID:   packet.ChannelID,
+		ChannelType: packet.ChannelType,
+		Topic:       packet.Topic,
+		Expire:      packet.Expire,
+		Timestamp:   packet.Timestamp,
+		Payload:     packet.Payload,
+	}
+	if packet.NoPersist {
+		m.Header.NoPersist = 1
+	}
+	if packet.RedDot {
+		m.Header.RedDot = 1
+	}
+	if packet.SyncOnce {
+		m.Header.SyncOnce = 1
+	}
+	return m
+}
+
+// Conversation 最近会话
+type Conversation struct {
+	ChannelID       string     `json:"channel_id"`         // 频道ID
+	ChannelType     uint8      `json:"channel_type"`       // 频道类型
+	Unread          int        `json:"unread"`             // 未读消息数量
+	Timestamp       int64      `json:"timestamp"`          // 最后一次会话时间
+	LastMsgSeq      uint64     `json:"last_msg_seq"`       // 最后一条消息序号
+	LastClientMsgNo string     `json:"last_client_msg_no"` // 最后一条消息的客户端编号
+	OffsetMsgSeq    int64      `json:"offset_msg_seq"`     // 偏移位的消息序号
+	ReadedToMsgSeq  uint64     `json:"readed_to_msg_seq"`  // 已读至的消息序号
+	Version         int64      `json:"version"`            // 数据版本
+	Recents         []*Message `json:"recents"`            // 最近N条消息
+}
+
+// Channel 会话所属频道
+func (c *Conversation) Channel() *Channel {
+	return NewChannel(c.ChannelID, c.ChannelType)
+}
+
+// SyncMessagesReq 同步频道消息的请求
+type SyncMessagesReq struct {
+	StartMessageSeq uint64   // 开始消息序号（结果包含）
+	EndMessageSeq   uint64   // 结束消息序号（结果不包含），0表示不限制
+	Limit           int      // 数量限制
+	PullMode        PullMode // 拉取模式
+}
+
+// SyncMessagesResp 同步频道消息的结果
+type SyncMessagesResp struct {
+	StartMessageSeq uint64     `json:"start_message_seq"` // 开始序列号
+	EndMessageSeq   uint64     `json:"end_message_seq"`   // 结束序列号
+	More            int        `json:"more"`              // 是否还有更多 1.是 0.否
+	Messages        []*Message `json:"messages"`          // 消息（按消息序号升序）
+}
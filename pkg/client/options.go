@@ -1,6 +1,7 @@
 package client
 
 import (
+	"crypto/tls"
 	"time"
 
 	wkproto "github.com/WuKongIM/WuKongIMGoProto"
@@ -37,6 +38,17 @@ type Options struct {
 	// ReconnectWait sets the time to backoff after attempting a reconnect
 	// to a server that we were already connected to previously.
 	ReconnectWait time.Duration
+
+	// TLSConfig tls://和wss://连接使用的tls配置，为nil时使用默认配置（校验服务端证书）
+	TLSConfig *tls.Config
+
+	APIURL          string        // IM的http api地址，例如 http://127.0.0.1:5001，设置后才能同步会话和消息
+	APIKey          string        // 请求http api的api key（服务端开启api key时需要）
+	APITimeout      time.Duration // 请求http api的超时时间
+	Store           Store         // 本地存储，默认为内存存储
+	SyncMsgCount    int           // 同步会话时每个会话返回的最近消息数量
+	SyncLimit       int           // 同步消息时每页的数量
+	SyncOnReconnect bool          // 重连成功后是否自动同步会话并补齐断线期间的消息
 }
 
 // NewOptions 创建默认配置
@@ -51,6 +63,10 @@ func NewOptions() *Options {
 		MaxPingCount:     2,
 		ReconnectJitter:  100 * time.Millisecond,
 		ReconnectWait:    2 * time.Second,
+		APITimeout:       10 * time.Second,
+		SyncMsgCount:     1,
+		SyncLimit:        100,
+		SyncOnReconnect:  true,
 	}
 }
 
@@ -89,6 +105,54 @@ func WithAutoReconn(autoReconn bool) Option {
 	}
 }
 
+// WithTLSConfig tls://和wss://连接使用的tls配置
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(opts *Options) error {
+		opts.TLSConfig = tlsConfig
+		return nil
+	}
+}
+
+// WithAPIURL IM的http api地址
+func WithAPIURL(apiURL string) Option {
+	return func(opts *Options) error {
+		opts.APIURL = apiURL
+		return nil
+	}
+}
+
+// WithAPIKey 请求http api的api key
+func WithAPIKey(apiKey string) Option {
+	return func(opts *Options) error {
+		opts.APIKey = apiKey
+		return nil
+	}
+}
+
+// WithStore 本地存储
+func WithStore(store Store) Option {
+	return func(opts *Options) error {
+		opts.Store = store
+		return nil
+	}
+}
+
+// WithSyncLimit 同步消息时每页的数量
+func WithSyncLimit(limit int) Option {
+	return func(opts *Options) error {
+		opts.SyncLimit = limit
+		return nil
+	}
+}
+
+// WithSyncOnReconnect 重连成功后是否自动同步会话并补齐断线期间的消息
+func WithSyncOnReconnect(syncOnReconnect bool) Option {
+	return func(opts *Options) error {
+		opts.SyncOnReconnect = syncOnReconnect
+		return nil
+	}
+}
+
 // SendOptions SendOptions
 type SendOptions struct {
 	NoPersist   bool // 是否不存储 默认 false
@@ -97,6 +161,7 @@ type SendOptions struct {
 	RedDot      bool // 是否显示红点 默认true
 	NoEncrypt   bool // 是否不需要加密
 	ClientMsgNo string
+	StreamNo    string // 流编号（发送流消息分片时设置）
 }
 
 // NewSendOptions NewSendOptions
@@ -159,3 +224,11 @@ func SendOptionWithNoEncrypt(noEncrypt bool) SendOption {
 		return nil
 	}
 }
+
+// SendOptionWithStreamNo 流编号，设置后发送的是此流的消息分片
+func SendOptionWithStreamNo(streamNo string) SendOption {
+	return func(opts *SendOptions) error {
+		opts.StreamNo = streamNo
+		return nil
+	}
+}
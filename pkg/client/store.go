@@ -0,0 +1,112 @@
+package client
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Store 本地存储，保存同步下来的会话和消息
+// 默认为内存存储，需要持久化时可以通过 WithStore 替换为自己的实现（例如sqlite）
+type Store interface {
+	// SaveMessages 保存消息，相同频道相同消息序号的消息覆盖
+	SaveMessages(messages []*Message) error
+	// GetMessages 获取频道内消息序号小于beforeSeq的最近limit条消息，beforeSeq为0表示从最新的消息开始，结果按消息序号升序
+	GetMessages(channel *Channel, beforeSeq uint64, limit int) ([]*Message, error)
+	// GetMaxMessageSeq 获取频道在本地的最大消息序号，没有消息返回0
+	GetMaxMessageSeq(channel *Channel) (uint64, error)
+	// SaveConversations 保存会话，相同频道的会话覆盖
+	SaveConversations(conversations []*Conversation) error
+	// GetConversations 获取所有会话
+	GetConversations() ([]*Conversation, error)
+}
+
+// MemoryStore 内存存储
+type MemoryStore struct {
+	mu            sync.RWMutex
+	messages      map[string][]*Message // 频道key -> 消息（按消息序号升序）
+	conversations map[string]*Conversation
+}
+
+// NewMemoryStore 创建内存存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		messages:      make(map[string][]*Message),
+		conversations: make(map[string]*Conversation),
+	}
+}
+
+func (m *MemoryStore) SaveMessages(messages []*Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, msg := range messages {
+		if msg.MessageSeq == 0 {
+			continue
+		}
+		key := channelKey(msg.ChannelID, msg.ChannelType)
+		msgs := m.messages[key]
+		i := sort.Search(len(msgs), func(i int) bool { return msgs[i].MessageSeq >= msg.MessageSeq })
+		if i < len(msgs) && msgs[i].MessageSeq == msg.MessageSeq {
+			msgs[i] = msg
+			continue
+		}
+		msgs = append(msgs, nil)
+		copy(msgs[i+1:], msgs[i:])
+		msgs[i] = msg
+		m.messages[key] = msgs
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetMessages(channel *Channel, beforeSeq uint64, limit int) ([]*Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	msgs := m.messages[channelKey(channel.ChannelID, channel.ChannelType)]
+	end := len(msgs)
+	if beforeSeq > 0 {
+		end = sort.Search(len(msgs), func(i int) bool { return msgs[i].MessageSeq >= beforeSeq })
+	}
+	start := 0
+	if limit > 0 && end-limit > 0 {
+		start = end - limit
+	}
+	results := make([]*Message, end-start)
+	copy(results, msgs[start:end])
+	return results, nil
+}
+
+func (m *MemoryStore) GetMaxMessageSeq(channel *Channel) (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	msgs := m.messages[channelKey(channel.ChannelID, channel.ChannelType)]
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+	return msgs[len(msgs)-1].MessageSeq, nil
+}
+
+func (m *MemoryStore) SaveConversations(conversations []*Conversation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, conversation := range conversations {
+		m.conversations[channelKey(conversation.ChannelID, conversation.ChannelType)] = conversation
+	}
+	return nil
+}
+
+func (m *MemoryStore) GetConversations() ([]*Conversation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	conversations := make([]*Conversation, 0, len(m.conversations))
+	for _, conversation := range m.conversations {
+		conversations = append(conversations, conversation)
+	}
+	sort.Slice(conversations, func(i, j int) bool {
+		return conversations[i].Timestamp > conversations[j].Timestamp
+	})
+	return conversations, nil
+}
+
+func channelKey(channelID string, channelType uint8) string {
+	return fmt.Sprintf("%s-%d", channelID, channelType)
+}
@@ -0,0 +1,100 @@
+package client
+
+import (
+	"context"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+)
+
+// 流消息（例如AI逐字输出）的发送流程：
+// 1. StreamStart 通过http api开启流，得到流编号（同时会存储一条流消息）
+// 2. SendStreamMessage 通过长连接发送流的消息分片（接收方收到的RecvPacket带StreamNo、StreamSeq）
+// 3. StreamEnd 通过http api结束流
+// 接收方断线期间错过的分片可以通过 GetStreamChunks 补齐
+
+// StreamChunk 流消息分片
+type StreamChunk struct {
+	StreamSeq uint32 `json:"stream_seq"` // 流序号
+	Payload   []byte `json:"payload"`    // 分片内容
+}
+
+// StreamChunks 流消息的分片
+type StreamChunks struct {
+	StreamNo    string         `json:"stream_no"`     // 流编号
+	ChannelID   string         `json:"channel_id"`    // 频道ID
+	ChannelType uint8          `json:"channel_type"`  // 频道类型
+	FromUID     string         `json:"from_uid"`      // 发送者UID
+	ClientMsgNo string         `json:"client_msg_no"` // 客户端消息编号
+	MessageID   int64          `json:"message_id"`    // 流所属的消息ID
+	MessageSeq  int64          `json:"message_seq"`   // 流所属的消息序号
+	End         int            `json:"end"`           // 流是否已结束 1.已结束
+	More        int            `json:"more"`          // 是否还有更多分片
+	Chunks      []*StreamChunk `json:"chunks"`        // 分片（按流序号有序）
+}
+
+// StreamStart 开启消息流，返回流编号
+func (c *Client) StreamStart(channel *Channel, payload []byte, opt ...SendOption) (string, error) {
+	opts := NewSendOptions()
+	for _, op := range opt {
+		_ = op(opts)
+	}
+	var resp struct {
+		StreamNo string `json:"stream_no"`
+	}
+	err := c.requestAPI("/stream/start", map[string]interface{}{
+		"header": MessageHeader{
+			NoPersist: wkutil.BoolToInt(opts.NoPersist),
+			RedDot:    wkutil.BoolToInt(opts.RedDot),
+			SyncOnce:  wkutil.BoolToInt(opts.SyncOnce),
+		},
+		"client_msg_no": opts.ClientMsgNo,
+		"from_uid":      c.opts.UID,
+		"channel_id":    channel.ChannelID,
+		"channel_type":  channel.ChannelType,
+		"payload":       payload,
+	}, &resp)
+	if err != nil {
+		return "", err
+	}
+	return resp.StreamNo, nil
+}
+
+// SendStreamMessage 发送流的消息分片并等待发送回执
+func (c *Client) SendStreamMessage(ctx context.Context, streamNo string, channel *Channel, payload []byte, opt ...SendOption) (*wkproto.SendackPacket, error) {
+	opt = append(opt, SendOptionWithStreamNo(streamNo))
+	return c.SendMessageWithContext(ctx, channel, payload, opt...)
+}
+
+// StreamEnd 结束消息流
+func (c *Client) StreamEnd(streamNo string, channel *Channel) error {
+	var resp map[string]interface{}
+	return c.requestAPI("/stream/end", map[string]interface{}{
+		"stream_no":    streamNo,
+		"from_uid":     c.opts.UID,
+		"channel_id":   channel.ChannelID,
+		"channel_type": channel.ChannelType,
+	}, &resp)
+}
+
+// GetStreamChunks 获取流序号大于startStreamSeq的分片（传入已收到的最大流序号即可补齐）
+func (c *Client) GetStreamChunks(streamNo string, channel *Channel, startStreamSeq uint32, limit int) (*StreamChunks, error) {
+	resp := &StreamChunks{}
+	err := c.requestAPI("/stream/chunks", map[string]interface{}{
+		"login_uid":        c.opts.UID,
+		"stream_no":        streamNo,
+		"channel_id":       channel.ChannelID,
+		"channel_type":     channel.ChannelType,
+		"start_stream_seq": startStreamSeq,
+		"limit":            limit,
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// IsStreamRecv 收到的消息是否是流消息分片
+func IsStreamRecv(recv *wkproto.RecvPacket) bool {
+	return recv.Setting.IsSet(wkproto.SettingStream) && recv.StreamNo != ""
+}
@@ -0,0 +1,280 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// ErrAPIURLNotSet 没有设置http api地址
+var ErrAPIURLNotSet = errors.New("wukongim api url not set")
+
+// OnBackfill 补齐断层消息事件（实时收到的消息序号不连续或者重连后同步到了断线期间的消息）
+type OnBackfill func(channel *Channel, messages []*Message)
+
+// SetOnBackfill 设置补齐断层消息事件
+func (c *Client) SetOnBackfill(onBackfill OnBackfill) {
+	c.onBackfill = onBackfill
+}
+
+// Store 本地存储
+func (c *Client) Store() Store {
+	return c.store
+}
+
+// SyncConversations 增量同步最近会话（按本地会话的最大版本号和每个会话的最后消息序号），结果保存到本地存储
+func (c *Client) SyncConversations() ([]*Conversation, error) {
+	localConversations, err := c.store.GetConversations()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		version     int64
+		lastMsgSeqs = make([]string, 0, len(localConversations))
+	)
+	for _, conversation := range localConversations {
+		if conversation.Version > version {
+			version = conversation.Version
+		}
+		lastMsgSeq, err := c.store.GetMaxMessageSeq(conversation.Channel())
+		if err != nil {
+			return nil, err
+		}
+		lastMsgSeqs = append(lastMsgSeqs, fmt.Sprintf("%s:%d:%d", conversation.ChannelID, conversation.ChannelType, lastMsgSeq))
+	}
+
+	var conversations []*Conversation
+	err = c.requestAPI("/conversation/sync", map[string]interface{}{
+		"uid":           c.opts.UID,
+		"version":       version,
+		"last_msg_seqs": strings.Join(lastMsgSeqs, "|"),
+		"msg_count":     c.opts.SyncMsgCount,
+	}, &conversations)
+	if err != nil {
+		return nil, err
+	}
+	if len(conversations) == 0 {
+		return conversations, nil
+	}
+	if err = c.store.SaveConversations(conversations); err != nil {
+		return nil, err
+	}
+	for _, conversation := range conversations {
+		if len(conversation.Recents) == 0 {
+			continue
+		}
+		if err = c.store.SaveMessages(conversation.Recents); err != nil {
+			return nil, err
+		}
+	}
+	return conversations, nil
+}
+
+// SyncMessages 从服务端分页同步频道消息，结果保存到本地存储
+func (c *Client) SyncMessages(channel *Channel, req SyncMessagesReq) (*SyncMessagesResp, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = c.opts.SyncLimit
+	}
+	resp := &SyncMessagesResp{}
+	err := c.requestAPI("/channel/messagesync", map[string]interface{}{
+		"login_uid":         c.opts.UID,
+		"channel_id":        channel.ChannelID,
+		"channel_type":      channel.ChannelType,
+		"start_message_seq": req.StartMessageSeq,
+		"end_message_seq":   req.EndMessageSeq,
+		"limit":             limit,
+		"pull_mode":         req.PullMode,
+	}, resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Messages) > 0 {
+		if err = c.store.SaveMessages(resp.Messages); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// LoadPrevMessages 分页加载频道内消息序号小于beforeSeq的最近limit条消息（beforeSeq为0表示从最新的消息开始），结果按消息序号升序
+// 本地存储的消息连续时直接返回本地的，否则从服务端拉取
+func (c *Client) LoadPrevMessages(channel *Channel, beforeSeq uint64, limit int) ([]*Message, error) {
+	if limit <= 0 {
+		limit = c.opts.SyncLimit
+	}
+	messages, err := c.store.GetMessages(channel, beforeSeq, limit)
+	if err != nil {
+		return nil, err
+	}
+	if beforeSeq > 0 && messagesContinuous(messages, beforeSeq, limit) {
+		return messages, nil
+	}
+	if c.opts.APIURL == "" { // 没有设置api地址只能返回本地的
+		return messages, nil
+	}
+	var startSeq uint64
+	if beforeSeq > 1 {
+		startSeq = beforeSeq - 1
+	} else if beforeSeq == 1 {
+		return nil, nil
+	}
+	resp, err := c.SyncMessages(channel, SyncMessagesReq{
+		StartMessageSeq: startSeq,
+		Limit:           limit,
+		PullMode:        PullModeDown,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Messages, nil
+}
+
+// BackfillMessages 补齐频道内[startSeq, endSeq)范围的消息（endSeq为0表示补齐到最新），结果保存到本地存储并触发OnBackfill事件
+func (c *Client) BackfillMessages(channel *Channel, startSeq, endSeq uint64) ([]*Message, error) {
+	var results []*Message
+	for {
+		resp, err := c.SyncMessages(channel, SyncMessagesReq{
+			StartMessageSeq: startSeq,
+			EndMessageSeq:   endSeq,
+			PullMode:        PullModeUp,
+		})
+		if err != nil {
+			return results, err
+		}
+		if len(resp.Messages) == 0 {
+			break
+		}
+		results = append(results, resp.Messages...)
+		if c.onBackfill != nil {
+			c.onBackfill(channel, resp.Messages)
+		}
+		lastSeq := resp.Messages[len(resp.Messages)-1].MessageSeq
+		if resp.More == 0 || (endSeq > 0 && lastSeq+1 >= endSeq) {
+			break
+		}
+		startSeq = lastSeq + 1
+	}
+	return results, nil
+}
+
+// 检查实时收到的消息是否和本地存储的消息有断层，有断层则异步补齐
+func (c *Client) checkGapAndSave(msg *Message) {
+	if !msg.persisted() {
+		return
+	}
+	channel := msg.Channel()
+	localMaxSeq, err := c.store.GetMaxMessageSeq(channel)
+	if err != nil {
+		c.Warn("获取本地最大消息序号失败！", zap.Error(err), zap.String("channelID", channel.ChannelID))
+		return
+	}
+	if err = c.store.SaveMessages([]*Message{msg}); err != nil {
+		c.Warn("保存消息失败！", zap.Error(err), zap.String("channelID", channel.ChannelID))
+		return
+	}
+	// 本地没有此频道的消息时不补齐，历史消息由LoadPrevMessages按需加载
+	if localMaxSeq == 0 || msg.MessageSeq <= localMaxSeq+1 || c.opts.APIURL == "" {
+		return
+	}
+	go c.backfill(channel, localMaxSeq+1, msg.MessageSeq)
+}
+
+func (c *Client) backfill(channel *Channel, startSeq, endSeq uint64) {
+	_, err := c.BackfillMessages(channel, startSeq, endSeq)
+	if err != nil {
+		c.Warn("补齐断层消息失败！", zap.Error(err), zap.String("channelID", channel.ChannelID), zap.Uint8("channelType", channel.ChannelType), zap.Uint64("startSeq", startSeq), zap.Uint64("endSeq", endSeq))
+	}
+}
+
+// 重连成功后同步会话，并补齐断线期间本地已有会话的消息
+func (c *Client) syncAfterReconnect() {
+	localConversations, err := c.store.GetConversations()
+	if err != nil {
+		c.Warn("获取本地会话失败！", zap.Error(err))
+		return
+	}
+	// 同步会话前记录本地的最大消息序号（同步会话会保存最近消息）
+	localMaxSeqs := make(map[string]uint64, len(localConversations))
+	for _, conversation := range localConversations {
+		maxSeq, err := c.store.GetMaxMessageSeq(conversation.Channel())
+		if err != nil {
+			c.Warn("获取本地最大消息序号失败！", zap.Error(err), zap.String("channelID", conversation.ChannelID))
+			return
+		}
+		localMaxSeqs[channelKey(conversation.ChannelID, conversation.ChannelType)] = maxSeq
+	}
+
+	conversations, err := c.SyncConversations()
+	if err != nil {
+		c.Warn("同步会话失败！", zap.Error(err))
+		return
+	}
+	for _, conversation := range conversations {
+		localMaxSeq := localMaxSeqs[channelKey(conversation.ChannelID, conversation.ChannelType)]
+		if localMaxSeq == 0 || conversation.LastMsgSeq <= localMaxSeq {
+			continue
+		}
+		c.backfill(conversation.Channel(), localMaxSeq+1, conversation.LastMsgSeq+1)
+	}
+}
+
+// 本地消息是否是紧挨着beforeSeq的连续limit条（或者已经到第一条消息）
+func messagesContinuous(messages []*Message, beforeSeq uint64, limit int) bool {
+	if len(messages) == 0 {
+		return false
+	}
+	if messages[len(messages)-1].MessageSeq != beforeSeq-1 {
+		return false
+	}
+	for i := 1; i < len(messages); i++ {
+		if messages[i].MessageSeq != messages[i-1].MessageSeq+1 {
+			return false
+		}
+	}
+	return len(messages) >= limit || messages[0].MessageSeq == 1
+}
+
+// 请求IM的http api
+func (c *Client) requestAPI(path string, body interface{}, result interface{}) error {
+	if c.opts.APIURL == "" {
+		return ErrAPIURLNotSet
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(c.opts.APIURL, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.opts.APIKey != "" {
+		req.Header.Set("X-Api-Key", c.opts.APIKey)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var errResp struct {
+			Msg string `json:"msg"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Msg != "" {
+			return fmt.Errorf("request %s failed, status: %d msg: %s", path, resp.StatusCode, errResp.Msg)
+		}
+		return fmt.Errorf("request %s failed, status: %d", path, resp.StatusCode)
+	}
+	return json.Unmarshal(respBody, result)
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	store := NewMemoryStore()
+	channel := NewChannel("g1", 2)
+	var messages []*Message
+	for _, seq := range []uint64{3, 1, 2, 5, 4} {
+		messages = append(messages, &Message{ChannelID: "g1", ChannelType: 2, MessageSeq: seq})
+	}
+	err := store.SaveMessages(messages)
+	assert.NoError(t, err)
+
+	maxSeq, err := store.GetMaxMessageSeq(channel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), maxSeq)
+
+	results, err := store.GetMessages(channel, 5, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, uint64(3), results[0].MessageSeq)
+	assert.Equal(t, uint64(4), results[1].MessageSeq)
+
+	results, err = store.GetMessages(channel, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(results))
+}
+
+func TestSyncConversationsAndBackfill(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		lastBody map[string]interface{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "key", r.Header.Get("X-Api-Key"))
+		var body map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		mu.Lock()
+		lastBody = body
+		mu.Unlock()
+		switch r.URL.Path {
+		case "/conversation/sync":
+			_ = json.NewEncoder(w).Encode([]*Conversation{
+				{ChannelID: "g1", ChannelType: 2, LastMsgSeq: 10, Version: 100, Recents: []*Message{{ChannelID: "g1", ChannelType: 2, MessageSeq: 10}}},
+			})
+		case "/channel/messagesync":
+			start := uint64(body["start_message_seq"].(float64))
+			end := uint64(body["end_message_seq"].(float64))
+			resp := &SyncMessagesResp{StartMessageSeq: start, EndMessageSeq: end}
+			for seq := start; seq < end; seq++ {
+				resp.Messages = append(resp.Messages, &Message{ChannelID: "g1", ChannelType: 2, MessageSeq: seq})
+			}
+			_ = json.NewEncoder(w).Encode(resp)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	c := New("tcp://127.0.0.1:0", WithUID("u1"), WithAPIURL(server.URL), WithAPIKey("key"))
+	channel := NewChannel("g1", 2)
+
+	conversations, err := c.SyncConversations()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(conversations))
+	maxSeq, err := c.Store().GetMaxMessageSeq(channel)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), maxSeq)
+
+	// 第二次同步带上本地的版本号和最后消息序号
+	_, err = c.SyncConversations()
+	assert.NoError(t, err)
+	mu.Lock()
+	assert.Equal(t, float64(100), lastBody["version"])
+	assert.Equal(t, "g1:2:10", lastBody["last_msg_seqs"])
+	mu.Unlock()
+
+	// 实时收到序号15的消息，补齐11-14
+	backfilled := make(chan []*Message, 1)
+	c.SetOnBackfill(func(ch *Channel, messages []*Message) {
+		backfilled <- messages
+	})
+	c.checkGapAndSave(&Message{ChannelID: "g1", ChannelType: 2, MessageSeq: 15})
+	select {
+	case messages := <-backfilled:
+		assert.Equal(t, 4, len(messages))
+		assert.Equal(t, uint64(11), messages[0].MessageSeq)
+		assert.Equal(t, uint64(14), messages[3].MessageSeq)
+	case <-time.After(time.Second * 5):
+		t.Fatal("backfill timeout")
+	}
+
+	results, err := c.LoadPrevMessages(channel, 16, 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 6, len(results))
+	assert.Equal(t, uint64(10), results[0].MessageSeq)
+}
@@ -0,0 +1,132 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// 支持的连接地址
+// tcp://127.0.0.1:5100          tcp
+// tls://127.0.0.1:5100          tcp + tls
+// ws://127.0.0.1:5200           websocket
+// wss://im.example.com/ws       websocket + tls
+func (c *Client) dial() (net.Conn, error) {
+	scheme := "tcp"
+	if idx := strings.Index(c.addr, "://"); idx > 0 {
+		scheme = strings.ToLower(c.addr[:idx])
+	}
+	switch scheme {
+	case "ws", "wss":
+		return c.dialWebsocket()
+	case "tls", "ssl":
+		_, address, _ := parseAddr(c.addr)
+		return tls.DialWithDialer(&net.Dialer{Timeout: c.opts.Timeout}, "tcp", address, c.opts.TLSConfig)
+	default:
+		network, address, _ := parseAddr(c.addr)
+		return net.DialTimeout(network, address, c.opts.Timeout)
+	}
+}
+
+func (c *Client) dialWebsocket() (net.Conn, error) {
+	dialer := ws.Dialer{
+		Timeout:   c.opts.Timeout,
+		TLSConfig: c.opts.TLSConfig,
+	}
+	conn, br, _, err := dialer.Dial(context.Background(), c.addr)
+	if err != nil {
+		return nil, err
+	}
+	return newWSConn(conn, br), nil
+}
+
+// wsConn 把websocket连接包装成net.Conn，读写的都是二进制帧的内容，上层按tcp的字节流处理
+type wsConn struct {
+	net.Conn
+	rd         *wsutil.Reader
+	inFrame    bool // 当前帧是否还有数据未读
+	writeMu    sync.Mutex
+	controlBuf []byte
+}
+
+func newWSConn(conn net.Conn, br *bufio.Reader) *wsConn {
+	var src io.Reader = conn
+	if br != nil { // 握手时多读的数据
+		src = br
+	}
+	w := &wsConn{
+		Conn: conn,
+	}
+	w.rd = &wsutil.Reader{
+		Source:    src,
+		State:     ws.StateClientSide,
+		CheckUTF8: false,
+		// 分片消息中间穿插的控制帧
+		OnIntermediate: w.handleControl,
+	}
+	return w
+}
+
+func (w *wsConn) Read(p []byte) (int, error) {
+	for {
+		if w.inFrame {
+			n, err := w.rd.Read(p)
+			if err == io.EOF { // 当前帧已读完
+				w.inFrame = false
+				if n > 0 {
+					return n, nil
+				}
+				continue
+			}
+			return n, err
+		}
+		hdr, err := w.rd.NextFrame()
+		if err != nil {
+			return 0, err
+		}
+		if hdr.OpCode.IsControl() {
+			if err = w.handleControl(hdr, w.rd); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		w.inFrame = true
+	}
+}
+
+// 处理控制帧，ping回pong，close返回EOF
+func (w *wsConn) handleControl(hdr ws.Header, r io.Reader) error {
+	if cap(w.controlBuf) < int(hdr.Length) {
+		w.controlBuf = make([]byte, hdr.Length)
+	}
+	payload := w.controlBuf[:hdr.Length]
+	if _, err := io.ReadFull(r, payload); err != nil && err != io.EOF {
+		return err
+	}
+	switch hdr.OpCode {
+	case ws.OpPing:
+		w.writeMu.Lock()
+		err := wsutil.WriteClientMessage(w.Conn, ws.OpPong, payload)
+		w.writeMu.Unlock()
+		return err
+	case ws.OpClose:
+		return io.EOF
+	}
+	return nil
+}
+
+func (w *wsConn) Write(p []byte) (int, error) {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+	if err := wsutil.WriteClientBinary(w.Conn, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
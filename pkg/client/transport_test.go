@@ -0,0 +1,140 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// 模拟IM的websocket服务，connack返回connReasonCode，收到消息回sendack后把连接踢下线
+func startWSServer(t *testing.T, connReasonCode wkproto.ReasonCode) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	proto := wkproto.New()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				if _, err := ws.Upgrade(conn); err != nil {
+					return
+				}
+				write := func(f wkproto.Frame) {
+					data, _ := proto.EncodeFrame(f, wkproto.LatestVersion)
+					_ = wsutil.WriteServerBinary(conn, data)
+				}
+				for {
+					data, err := wsutil.ReadClientBinary(conn)
+					if err != nil {
+						return
+					}
+					frame, _, err := proto.DecodeFrame(data, wkproto.LatestVersion)
+					if err != nil || frame == nil {
+						continue
+					}
+					switch packet := frame.(type) {
+					case *wkproto.ConnectPacket:
+						_, serverPubKey := wkutil.GetCurve25519KeypPair()
+						write(&wkproto.ConnackPacket{
+							ServerKey:  base64.StdEncoding.EncodeToString(serverPubKey[:]),
+							Salt:       "1234567890123456",
+							ReasonCode: connReasonCode,
+						})
+					case *wkproto.SendPacket:
+						write(&wkproto.SendackPacket{
+							ClientSeq:   packet.ClientSeq,
+							ClientMsgNo: packet.ClientMsgNo,
+							MessageID:   100,
+							MessageSeq:  1,
+							ReasonCode:  wkproto.ReasonSuccess,
+						})
+						write(&wkproto.DisconnectPacket{
+							ReasonCode: wkproto.ReasonConnectKick,
+							Reason:     "login elsewhere",
+						})
+					}
+				}
+			}(conn)
+		}
+	}()
+	return fmt.Sprintf("ws://%s", ln.Addr().String())
+}
+
+func TestWebsocketSendMessageWithContext(t *testing.T) {
+	addr := startWSServer(t, wkproto.ReasonSuccess)
+
+	c := New(addr, WithUID("u1"), WithToken("token"), WithAutoReconn(true))
+	events := make(chan ConnectStatusEvent, 10)
+	c.SetOnConnectStatus(func(event ConnectStatusEvent) {
+		events <- event
+	})
+	err := c.Connect()
+	assert.NoError(t, err)
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	sendack, err := c.SendMessageWithContext(ctx, NewChannel("u2", wkproto.ChannelTypePerson), []byte("hello"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100), sendack.MessageID)
+
+	var statuses []Status
+	timeout := time.After(time.Second * 5)
+	for len(statuses) < 4 {
+		select {
+		case event := <-events:
+			statuses = append(statuses, event.Status)
+			if event.Status == KICKED {
+				assert.Equal(t, wkproto.ReasonConnectKick, event.ReasonCode)
+				assert.Equal(t, "login elsewhere", event.Reason)
+			}
+		case <-timeout:
+			t.Fatalf("wait events timeout: %v", statuses)
+		}
+	}
+	// 被踢后不再重连
+	assert.Equal(t, []Status{CONNECTING, CONNECTED, KICKED, CLOSED}, statuses)
+}
+
+func TestConnectAuthFailed(t *testing.T) {
+	addr := startWSServer(t, wkproto.ReasonAuthFail)
+
+	c := New(addr, WithUID("u1"), WithToken("token"), WithAutoReconn(true))
+	events := make(chan ConnectStatusEvent, 10)
+	c.SetOnConnectStatus(func(event ConnectStatusEvent) {
+		events <- event
+	})
+	err := c.Connect()
+	assert.True(t, errors.Is(err, ErrConnectFailed))
+	var reasonErr *ReasonError
+	assert.True(t, errors.As(err, &reasonErr))
+	assert.Equal(t, wkproto.ReasonAuthFail, reasonErr.ReasonCode)
+
+	timeout := time.After(time.Second * 5)
+	for {
+		select {
+		case event := <-events:
+			if event.Status == AUTH_FAILED {
+				assert.Equal(t, wkproto.ReasonAuthFail, event.ReasonCode)
+				return
+			}
+		case <-timeout:
+			t.Fatal("wait auth failed event timeout")
+		}
+	}
+}
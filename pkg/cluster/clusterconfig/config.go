@@ -53,6 +53,11 @@ func (c *Config) loadConfigFromFile() error {
 	if err != nil {
 		c.Panic("Read cluster config file failed!", zap.Error(err))
 	}
+	if len(data) > 0 && c.opts.EncryptFS != nil {
+		if data, err = c.opts.EncryptFS.Unseal(data); err != nil {
+			c.Panic("Decrypt cluster config failed!", zap.Error(err))
+		}
+	}
 	if len(data) > 0 {
 		c.inited = true
 		if err := wkutil.ReadJSONByByte(data, c.cfg); err != nil {
@@ -437,6 +442,14 @@ func (c *Config) saveConfig() error {
 
 	c.inited = true
 
+	data := []byte(wkutil.ToJSON(c.cfg))
+	if c.opts.EncryptFS != nil {
+		var err error
+		if data, err = c.opts.EncryptFS.Seal(data); err != nil {
+			return err
+		}
+	}
+
 	_, err := c.cfgFile.Seek(0, 0)
 	if err != nil {
 		return err
@@ -445,7 +458,7 @@ func (c *Config) saveConfig() error {
 	if err != nil {
 		return err
 	}
-	_, err = c.cfgFile.Write([]byte(wkutil.ToJSON(c.cfg)))
+	_, err = c.cfgFile.Write(data)
 	if err != nil {
 		return err
 	}
@@ -6,6 +6,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
 )
 
 type Options struct {
@@ -34,6 +35,8 @@ type Options struct {
 	Event struct {
 		OnAppliedConfig func()
 	}
+
+	EncryptFS *encryption.FS // 静态数据加密（配置文件以及配置日志） 为nil表示不加密
 }
 
 func NewOptions(opt ...Option) *Options {
@@ -178,3 +181,9 @@ func WithSeed(seed string) Option {
 		o.Seed = seed
 	}
 }
+
+func WithEncryptFS(fs *encryption.FS) Option {
+	return func(o *Options) {
+		o.EncryptFS = fs
+	}
+}
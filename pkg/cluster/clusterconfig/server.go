@@ -42,7 +42,7 @@ func New(opts *Options) *Server {
 		handlerKey: "config",
 		cfg:        NewConfig(opts),
 		Log:        wklog.NewWKLog(fmt.Sprintf("clusterconfig.server[%d]", opts.NodeId)),
-		storage:    NewPebbleShardLogStorage(path.Join(dataDir, "cfglogdb")),
+		storage:    NewPebbleShardLogStorage(path.Join(dataDir, "cfglogdb"), opts.EncryptFS),
 		initNodes:  opts.InitNodes,
 	}
 	reactorOptions := reactor.NewOptions(
@@ -8,6 +8,7 @@ import (
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/key"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/cockroachdb/pebble"
 	"go.uber.org/zap"
@@ -16,14 +17,16 @@ import (
 type PebbleShardLogStorage struct {
 	db     *pebble.DB
 	path   string
+	fs     *encryption.FS // 静态数据加密 为nil表示不加密
 	wo     *pebble.WriteOptions
 	noSync *pebble.WriteOptions
 	wklog.Log
 }
 
-func NewPebbleShardLogStorage(path string) *PebbleShardLogStorage {
+func NewPebbleShardLogStorage(path string, fs *encryption.FS) *PebbleShardLogStorage {
 	return &PebbleShardLogStorage{
 		path:   path,
+		fs:     fs,
 		wo:     &pebble.WriteOptions{Sync: true},
 		noSync: &pebble.WriteOptions{Sync: false},
 		Log:    wklog.NewWKLog("ConfigPebbleShardLogStorage"),
@@ -32,9 +35,13 @@ func NewPebbleShardLogStorage(path string) *PebbleShardLogStorage {
 
 func (p *PebbleShardLogStorage) Open() error {
 	var err error
-	p.db, err = pebble.Open(p.path, &pebble.Options{
+	opts := &pebble.Options{
 		FormatMajorVersion: pebble.FormatNewest,
-	})
+	}
+	if p.fs != nil {
+		opts.FS = p.fs
+	}
+	p.db, err = pebble.Open(p.path, opts)
 	if err != nil {
 		return err
 	}
@@ -7,7 +7,7 @@ import (
 )
 
 func TestLeaderTermSequence(t *testing.T) {
-	store := NewPebbleShardLogStorage(t.TempDir())
+	store := NewPebbleShardLogStorage(t.TempDir(), nil)
 	defer store.Close()
 	err := store.Open()
 	assert.Nil(t, err)
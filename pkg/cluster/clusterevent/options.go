@@ -6,6 +6,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
 )
 
 type Options struct {
@@ -31,6 +32,7 @@ type Options struct {
 	HeartbeatIntervalTick int           // 心跳间隔tick
 	ElectionIntervalTick  int           // 选举间隔tick
 
+	EncryptFS *encryption.FS // 静态数据加密 为nil表示不加密
 }
 
 func NewOptions(opt ...Option) *Options {
@@ -152,3 +154,9 @@ func WithOnSlotElection(f func(slots []*pb.Slot) error) Option {
 		o.OnSlotElection = f
 	}
 }
+
+func WithEncryptFS(fs *encryption.FS) Option {
+	return func(o *Options) {
+		o.EncryptFS = fs
+	}
+}
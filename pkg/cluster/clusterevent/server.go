@@ -73,6 +73,7 @@ func New(opts *Options) *Server {
 		clusterconfig.WithElectionIntervalTick(opts.ElectionIntervalTick),
 		clusterconfig.WithHeartbeatIntervalTick(opts.HeartbeatIntervalTick),
 		clusterconfig.WithTickInterval(opts.TickInterval),
+		clusterconfig.WithEncryptFS(opts.EncryptFS),
 	))
 	err = s.loadLocalConfig()
 	if err != nil {
@@ -134,6 +135,11 @@ func (s *Server) loadLocalConfig() error {
 	if err != nil {
 		s.Panic("Read cluster config file failed!", zap.Error(err))
 	}
+	if len(data) > 0 && s.opts.EncryptFS != nil {
+		if data, err = s.opts.EncryptFS.Unseal(data); err != nil {
+			s.Panic("Decrypt cluster config failed!", zap.Error(err))
+		}
+	}
 	if len(data) > 0 {
 		if err := wkutil.ReadJSONByByte(data, s.localCfg); err != nil {
 			s.Panic("Unmarshal cluster config failed!", zap.Error(err))
@@ -225,11 +231,19 @@ func (s *Server) GetLogsInReverseOrder(startLogIndex uint64, endLogIndex uint64,
 
 func (s *Server) saveLocalConfig(cfg *pb.Config) error {
 
+	data := []byte(wkutil.ToJSON(cfg))
+	if s.opts.EncryptFS != nil {
+		var err error
+		if data, err = s.opts.EncryptFS.Seal(data); err != nil {
+			return err
+		}
+	}
+
 	err := s.localCfgFile.Truncate(0)
 	if err != nil {
 		return err
 	}
-	if _, err := s.localCfgFile.WriteAt([]byte(wkutil.ToJSON(cfg)), 0); err != nil {
+	if _, err := s.localCfgFile.WriteAt(data, 0); err != nil {
 		return err
 	}
 	return nil
@@ -7,8 +7,6 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/WuKongIM/WuKongIM/pkg/auth"
-	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 	"github.com/WuKongIM/WuKongIM/pkg/network"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
@@ -161,11 +159,6 @@ func (s *Server) channelClusterConfig(c *wkhttp.Context) {
 
 func (s *Server) channelStart(c *wkhttp.Context) {
 
-	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Start, auth.ActionWrite) {
-		c.ResponseStatus(http.StatusUnauthorized)
-		return
-	}
-
 	channelId := c.Param("channel_id")
 	channelType := wkutil.ParseUint8(c.Param("channel_type"))
 
@@ -200,11 +193,6 @@ func (s *Server) channelStart(c *wkhttp.Context) {
 
 func (s *Server) channelStop(c *wkhttp.Context) {
 
-	if !s.opts.Auth.HasPermissionWithContext(c, resource.ClusterChannel.Stop, auth.ActionWrite) {
-		c.ResponseStatus(http.StatusUnauthorized)
-		return
-	}
-
 	channelId := c.Param("channel_id")
 	channelType := wkutil.ParseUint8(c.Param("channel_type"))
 
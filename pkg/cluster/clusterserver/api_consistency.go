@@ -0,0 +1,288 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/WuKongIM/WuKongIM/pkg/network"
+	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"go.uber.org/zap"
+)
+
+// 校验频道副本的一致性
+func (s *Server) channelConsistencyGet(c *wkhttp.Context) {
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+	startSeq := wkutil.ParseUint64(c.Query("start_seq"))
+	endSeq := wkutil.ParseUint64(c.Query("end_seq"))
+
+	cfg, err := s.getChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		s.Error("getChannelClusterConfig error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if cfg.LeaderId == 0 {
+		c.ResponseError(errors.New("channel leader not found"))
+		return
+	}
+	if cfg.LeaderId != s.opts.NodeId {
+		leaderNode := s.clusterEventServer.Node(cfg.LeaderId)
+		if leaderNode == nil {
+			c.ResponseError(errors.New("channel leader node not found"))
+			return
+		}
+		c.Forward(fmt.Sprintf("%s%s", leaderNode.ApiServerAddr, c.Request.URL.Path))
+		return
+	}
+
+	resp, err := s.checkChannelConsistency(channelId, channelType, startSeq, endSeq)
+	if err != nil {
+		s.Error("checkChannelConsistency error", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// 修复频道不一致的副本
+func (s *Server) channelRepair(c *wkhttp.Context) {
+	var req struct {
+		NodeId  uint64 `json:"node_id"`  // 需要修复的副本节点
+		FromSeq uint64 `json:"from_seq"` // 从哪条消息开始重新同步，为0则使用校验出的第一条不一致的消息
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	cfg, err := s.getChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		s.Error("getChannelClusterConfig error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	if cfg.LeaderId == 0 {
+		c.ResponseError(errors.New("channel leader not found"))
+		return
+	}
+	if cfg.LeaderId != s.opts.NodeId {
+		leaderNode := s.clusterEventServer.Node(cfg.LeaderId)
+		if leaderNode == nil {
+			c.ResponseError(errors.New("channel leader node not found"))
+			return
+		}
+		c.ForwardWithBody(fmt.Sprintf("%s%s", leaderNode.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
+
+	if req.NodeId == 0 || req.NodeId == cfg.LeaderId {
+		c.ResponseError(errors.New("node_id must be a follower or learner of the channel"))
+		return
+	}
+	if !wkutil.ArrayContainsUint64(cfg.Replicas, req.NodeId) && !wkutil.ArrayContainsUint64(cfg.Learners, req.NodeId) {
+		c.ResponseError(errors.New("node_id not in replicas"))
+		return
+	}
+
+	headers := c.CopyRequestHeader(c.Request)
+	fromSeq := req.FromSeq
+	if fromSeq == 0 {
+		result, err := s.checkChannelConsistency(channelId, channelType, 0, 0)
+		if err != nil {
+			s.Error("checkChannelConsistency error", zap.Error(err))
+			c.ResponseError(err)
+			return
+		}
+		for _, r := range result.Replicas {
+			if r.ReplicaId == req.NodeId && r.Status == consistencyStatusDivergent {
+				fromSeq = r.DivergentSeq
+			}
+		}
+		if fromSeq == 0 {
+			c.ResponseError(errors.New("replica is not divergent"))
+			return
+		}
+	}
+
+	err = s.requestChannelLocalRepair(req.NodeId, channelId, channelType, fromSeq, headers)
+	if err != nil {
+		s.Error("requestChannelLocalRepair error", zap.Error(err), zap.Uint64("nodeId", req.NodeId))
+		c.ResponseError(err)
+		return
+	}
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id":  req.NodeId,
+		"from_seq": fromSeq,
+	})
+}
+
+// 修复本节点的频道副本
+func (s *Server) channelLocalRepair(c *wkhttp.Context) {
+	var req struct {
+		FromSeq uint64 `json:"from_seq"`
+	}
+	if _, err := BindJSON(&req, c); err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	channelId := c.Param("channel_id")
+	channelType := wkutil.ParseUint8(c.Param("channel_type"))
+
+	err := s.repairChannelReplica(channelId, channelType, req.FromSeq)
+	if err != nil {
+		s.Error("repairChannelReplica error", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+func (s *Server) requestChannelLocalRepair(nodeId uint64, channelId string, channelType uint8, fromSeq uint64, headers map[string]string) error {
+	node := s.clusterEventServer.Node(nodeId)
+	if node == nil {
+		return fmt.Errorf("node not found, nodeId:%d", nodeId)
+	}
+	fullUrl := fmt.Sprintf("%s%s", node.ApiServerAddr, s.formatPath(fmt.Sprintf("/channels/%s/%d/localRepair", channelId, channelType)))
+	resp, err := network.Post(fullUrl, []byte(wkutil.ToJSON(map[string]interface{}{
+		"from_seq": fromSeq,
+	})), headers)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("requestChannelLocalRepair failed, status code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 校验槽副本的一致性
+func (s *Server) slotConsistencyGet(c *wkhttp.Context) {
+	slotId := wkutil.ParseUint32(c.Param("id"))
+
+	st := s.clusterEventServer.Slot(slotId)
+	if st == nil {
+		c.ResponseError(errors.New("slot not found"))
+		return
+	}
+	if st.Leader != s.opts.NodeId {
+		leaderNode := s.clusterEventServer.Node(st.Leader)
+		if leaderNode == nil {
+			c.ResponseError(errors.New("slot leader node not found"))
+			return
+		}
+		c.Forward(fmt.Sprintf("%s%s", leaderNode.ApiServerAddr, c.Request.URL.Path))
+		return
+	}
+
+	results, err := s.checkSlotsConsistency([]uint32{slotId})
+	if err != nil {
+		s.Error("checkSlotsConsistency error", zap.Error(err), zap.Uint32("slotId", slotId))
+		c.ResponseError(err)
+		return
+	}
+	if len(results) == 0 {
+		c.ResponseError(errors.New("slot leader changed"))
+		return
+	}
+	c.JSON(http.StatusOK, results[0])
+}
+
+// 修复槽不一致的副本
+func (s *Server) slotRepair(c *wkhttp.Context) {
+	var req struct {
+		NodeId uint64 `json:"node_id"` // 需要修复的副本节点
+	}
+	bodyBytes, err := BindJSON(&req, c)
+	if err != nil {
+		s.Error("BindJSON error", zap.Error(err))
+		c.ResponseError(err)
+		return
+	}
+	slotId := wkutil.ParseUint32(c.Param("id"))
+
+	st := s.clusterEventServer.Slot(slotId)
+	if st == nil {
+		c.ResponseError(errors.New("slot not found"))
+		return
+	}
+	if st.Leader != s.opts.NodeId {
+		leaderNode := s.clusterEventServer.Node(st.Leader)
+		if leaderNode == nil {
+			c.ResponseError(errors.New("slot leader node not found"))
+			return
+		}
+		c.ForwardWithBody(fmt.Sprintf("%s%s", leaderNode.ApiServerAddr, c.Request.URL.Path), bodyBytes)
+		return
+	}
+
+	if req.NodeId == 0 || req.NodeId == st.Leader {
+		c.ResponseError(errors.New("node_id must be a follower or learner of the slot"))
+		return
+	}
+	if !wkutil.ArrayContainsUint64(st.Replicas, req.NodeId) && !wkutil.ArrayContainsUint64(st.Learners, req.NodeId) {
+		c.ResponseError(errors.New("node_id not in replicas"))
+		return
+	}
+
+	node := s.clusterEventServer.Node(req.NodeId)
+	if node == nil {
+		c.ResponseError(errors.New("node not found"))
+		return
+	}
+	fullUrl := fmt.Sprintf("%s%s", node.ApiServerAddr, s.formatPath(fmt.Sprintf("/slots/%d/localRepair", slotId)))
+	resp, err := network.Post(fullUrl, nil, c.CopyRequestHeader(c.Request))
+	if err != nil {
+		s.Error("request slot localRepair error", zap.Error(err), zap.Uint64("nodeId", req.NodeId))
+		c.ResponseError(err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		c.ResponseError(fmt.Errorf("request slot localRepair failed, status code: %d", resp.StatusCode))
+		return
+	}
+	c.ResponseOK()
+}
+
+// 修复本节点的槽副本
+func (s *Server) slotLocalRepair(c *wkhttp.Context) {
+	slotId := wkutil.ParseUint32(c.Param("id"))
+
+	err := s.repairSlotReplica(slotId)
+	if err != nil {
+		s.Error("repairSlotReplica error", zap.Error(err), zap.Uint32("slotId", slotId))
+		c.ResponseError(err)
+		return
+	}
+	c.ResponseOK()
+}
+
+// 获取节点最近一轮后台一致性校验的结果
+func (s *Server) consistencyGet(c *wkhttp.Context) {
+	nodeId := wkutil.ParseUint64(c.Query("node_id"))
+	if nodeId != 0 && nodeId != s.opts.NodeId {
+		node := s.clusterEventServer.Node(nodeId)
+		if node == nil {
+			c.ResponseError(errors.New("node not found"))
+			return
+		}
+		c.Forward(fmt.Sprintf("%s%s", node.ApiServerAddr, c.Request.URL.Path))
+		return
+	}
+
+	channels, slots, lastCheckAt := s.consistencyChecker.results()
+	c.JSON(http.StatusOK, map[string]interface{}{
+		"node_id":       s.opts.NodeId,
+		"interval":      s.opts.ConsistencyCheckInterval.String(),
+		"last_check_at": lastCheckAt,
+		"channels":      channels,
+		"slots":         slots,
+	})
+}
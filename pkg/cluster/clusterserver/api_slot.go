@@ -10,8 +10,6 @@ import (
 	"strings"
 	"time"
 
-	"github.com/WuKongIM/WuKongIM/pkg/auth"
-	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"go.uber.org/zap"
@@ -144,11 +142,6 @@ func (s *Server) slotMigrate(c *wkhttp.Context) {
 		MigrateTo   uint64 `json:"migrate_to"`   // 迁移的目标节点
 	}
 
-	if !s.opts.Auth.HasPermissionWithContext(c, resource.Slot.Migrate, auth.ActionWrite) {
-		c.ResponseStatus(http.StatusUnauthorized)
-		return
-	}
-
 	bodyBytes, err := BindJSON(&req, c)
 	if err != nil {
 		s.Error("bind json error", zap.Error(err))
@@ -33,6 +33,9 @@ type channel struct {
 
 	learnerToLock sync.Mutex
 
+	readStateMu sync.RWMutex
+	readState   replica.ReadState // 副本读状态的快照（副本只能在reactor协程中访问）
+
 	s *Server
 }
 
@@ -187,8 +190,23 @@ func (c *channel) SetHardState(hd replica.HardState) {
 	// }
 }
 
+// 更新副本读状态的快照
+func (c *channel) updateReadState() {
+	rs := c.rc.ReadState()
+	c.readStateMu.Lock()
+	c.readState = rs
+	c.readStateMu.Unlock()
+}
+
+func (c *channel) getReadState() replica.ReadState {
+	c.readStateMu.RLock()
+	defer c.readStateMu.RUnlock()
+	return c.readState
+}
+
 func (c *channel) Tick() {
 	c.rc.Tick()
+	c.updateReadState()
 
 	// if c.isLeader() {
 	// 	c.sendConfigTick++
@@ -205,7 +223,9 @@ func (c *channel) Tick() {
 }
 
 func (c *channel) Step(m replica.Message) error {
-	return c.rc.Step(m)
+	err := c.rc.Step(m)
+	c.updateReadState()
+	return err
 }
 
 func (c *channel) LeaderId() uint64 {
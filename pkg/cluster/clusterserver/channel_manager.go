@@ -75,6 +75,12 @@ func (c *channelManager) channelCount() int {
 	return c.channelReactor.HandlerLen()
 }
 
+func (c *channelManager) iterate(f func(*channel) bool) {
+	c.channelReactor.IteratorHandler(func(h reactor.IHandler) bool {
+		return f(h.(*channel))
+	})
+}
+
 func (c *channelManager) getWithHandleKey(handleKey string) reactor.IHandler {
 	return c.channelReactor.Handler(handleKey)
 }
@@ -0,0 +1,664 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/lni/goutils/syncutil"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	consistencyMinBucketSize uint64 = 1000  // 消息校验和的最小分段大小
+	consistencyMaxBuckets    uint64 = 10000 // 一次请求最多返回的分段数量
+)
+
+// consistencyChecker 后台定时校验本节点作为领导的槽和频道的副本是否一致
+type consistencyChecker struct {
+	s       *Server
+	stopper *syncutil.Stopper
+	wklog.Log
+
+	mu             sync.RWMutex
+	channelResults map[string]*channelConsistencyResp // 最近一轮不一致的频道
+	slotResults    map[uint32]*slotConsistencyResp    // 最近一轮的槽校验结果
+	lastCheckAt    int64                              // 最近一轮校验的完成时间
+}
+
+func newConsistencyChecker(s *Server) *consistencyChecker {
+	return &consistencyChecker{
+		s:              s,
+		stopper:        syncutil.NewStopper(),
+		Log:            wklog.NewWKLog(fmt.Sprintf("consistencyChecker[%d]", s.opts.NodeId)),
+		channelResults: make(map[string]*channelConsistencyResp),
+		slotResults:    make(map[uint32]*slotConsistencyResp),
+	}
+}
+
+func (c *consistencyChecker) start() {
+	if c.s.opts.ConsistencyCheckInterval <= 0 {
+		return
+	}
+	c.stopper.RunWorker(c.loop)
+}
+
+func (c *consistencyChecker) stop() {
+	c.stopper.Stop()
+}
+
+func (c *consistencyChecker) loop() {
+	tk := time.NewTicker(c.s.opts.ConsistencyCheckInterval)
+	defer tk.Stop()
+	for {
+		select {
+		case <-tk.C:
+			c.checkRound()
+		case <-c.stopper.ShouldStop():
+			return
+		}
+	}
+}
+
+func (c *consistencyChecker) stopped() bool {
+	select {
+	case <-c.stopper.ShouldStop():
+		return true
+	default:
+		return false
+	}
+}
+
+// 校验一轮
+func (c *consistencyChecker) checkRound() {
+	start := time.Now()
+	c.checkSlots()
+	c.checkChannels()
+
+	c.mu.Lock()
+	c.lastCheckAt = time.Now().Unix()
+	c.mu.Unlock()
+	c.Info("consistency check round done", zap.Duration("cost", time.Since(start)))
+}
+
+func (c *consistencyChecker) checkSlots() {
+	slotIds := make([]uint32, 0)
+	c.s.slotManager.iterate(func(st *slot) bool {
+		if st.LeaderId() == c.s.opts.NodeId {
+			slotIds = append(slotIds, st.st.Id)
+		}
+		return true
+	})
+	if len(slotIds) == 0 || c.stopped() {
+		return
+	}
+
+	results, err := c.s.checkSlotsConsistency(slotIds)
+	if err != nil {
+		c.Warn("check slots consistency failed", zap.Error(err))
+		return
+	}
+	trace.GlobalTrace.Metrics.Cluster().ConsistencyCheckCountAdd(trace.ClusterKindSlot, int64(len(results)))
+
+	// 不一致的槽可能是校验期间有新的日志应用，再校验一次
+	divergentIds := make([]uint32, 0)
+	for _, result := range results {
+		if result.divergent() {
+			divergentIds = append(divergentIds, result.SlotId)
+		}
+	}
+	if len(divergentIds) > 0 {
+		rechecks, err := c.s.checkSlotsConsistency(divergentIds)
+		if err != nil {
+			c.Warn("recheck slots consistency failed", zap.Error(err))
+			return
+		}
+		recheckMap := make(map[uint32]*slotConsistencyResp, len(rechecks))
+		for _, result := range rechecks {
+			recheckMap[result.SlotId] = result
+		}
+		for i, result := range results {
+			if recheck := recheckMap[result.SlotId]; recheck != nil {
+				results[i] = recheck
+			}
+		}
+	}
+
+	slotResults := make(map[uint32]*slotConsistencyResp, len(results))
+	for _, result := range results {
+		slotResults[result.SlotId] = result
+		for _, r := range result.Replicas {
+			if r.Status != consistencyStatusDivergent {
+				continue
+			}
+			trace.GlobalTrace.Metrics.Cluster().ConsistencyDivergenceCountAdd(trace.ClusterKindSlot, 1)
+			c.Error("slot replica divergent", zap.Uint32("slotId", result.SlotId), zap.Uint64("replicaId", r.ReplicaId), zap.Strings("divergent", r.Divergent), zap.Uint64("appliedIndex", r.AppliedIndex))
+		}
+	}
+	c.mu.Lock()
+	c.slotResults = slotResults
+	c.mu.Unlock()
+}
+
+func (c *consistencyChecker) checkChannels() {
+	channels := make([]*channelBase, 0)
+	c.s.channelManager.iterate(func(ch *channel) bool {
+		if ch.isLeader() {
+			channels = append(channels, &channelBase{ChannelId: ch.channelId, ChannelType: ch.channelType})
+		}
+		return true
+	})
+
+	window := c.s.opts.ConsistencyCheckWindow
+	channelResults := make(map[string]*channelConsistencyResp)
+	for _, ch := range channels {
+		if c.stopped() {
+			return
+		}
+		lastSeq, _, err := c.s.opts.DB.GetChannelLastMessageSeq(ch.ChannelId, ch.ChannelType)
+		if err != nil {
+			c.Warn("get channel last message seq failed", zap.Error(err), zap.String("channelId", ch.ChannelId), zap.Uint8("channelType", ch.ChannelType))
+			continue
+		}
+		if lastSeq == 0 {
+			continue
+		}
+		var startSeq uint64 = 1
+		if window > 0 && lastSeq > window {
+			startSeq = lastSeq - window + 1
+		}
+		result, err := c.s.checkChannelConsistency(ch.ChannelId, ch.ChannelType, startSeq, lastSeq)
+		if err == nil && result.divergent() {
+			// 不一致的频道可能是校验期间有新的消息同步，再校验一次
+			result, err = c.s.checkChannelConsistency(ch.ChannelId, ch.ChannelType, startSeq, lastSeq)
+		}
+		if err != nil {
+			c.Warn("check channel consistency failed", zap.Error(err), zap.String("channelId", ch.ChannelId), zap.Uint8("channelType", ch.ChannelType))
+			continue
+		}
+		trace.GlobalTrace.Metrics.Cluster().ConsistencyCheckCountAdd(trace.ClusterKindChannel, 1)
+		if !result.divergent() {
+			continue
+		}
+		channelResults[wkutil.ChannelToKey(ch.ChannelId, ch.ChannelType)] = result
+		for _, r := range result.Replicas {
+			if r.Status != consistencyStatusDivergent {
+				continue
+			}
+			trace.GlobalTrace.Metrics.Cluster().ConsistencyDivergenceCountAdd(trace.ClusterKindChannel, 1)
+			c.Error("channel replica divergent", zap.String("channelId", ch.ChannelId), zap.Uint8("channelType", ch.ChannelType), zap.Uint64("replicaId", r.ReplicaId), zap.Uint64("divergentSeq", r.DivergentSeq))
+		}
+	}
+	c.mu.Lock()
+	c.channelResults = channelResults
+	c.mu.Unlock()
+}
+
+// 最近一轮的校验结果
+func (c *consistencyChecker) results() ([]*channelConsistencyResp, []*slotConsistencyResp, int64) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	channels := make([]*channelConsistencyResp, 0, len(c.channelResults))
+	for _, result := range c.channelResults {
+		channels = append(channels, result)
+	}
+	slots := make([]*slotConsistencyResp, 0, len(c.slotResults))
+	for _, result := range c.slotResults {
+		slots = append(slots, result)
+	}
+	return channels, slots, c.lastCheckAt
+}
+
+// 计算消息校验和的分段大小，保证分段数量不超过consistencyMaxBuckets
+func messageChecksumBucketSize(startSeq, endSeq uint64) uint64 {
+	if endSeq < startSeq {
+		return consistencyMinBucketSize
+	}
+	count := endSeq - startSeq + 1
+	size := (count + consistencyMaxBuckets - 1) / consistencyMaxBuckets
+	if size < consistencyMinBucketSize {
+		size = consistencyMinBucketSize
+	}
+	return size
+}
+
+// 获取频道在本节点的消息校验和，bucketSize为0时只返回消息序号范围
+func (s *Server) channelLocalChecksum(channelId string, channelType uint8, startSeq, endSeq, bucketSize uint64) (*channelLocalChecksumResp, error) {
+	firstSeq, err := s.opts.DB.GetChannelFirstLocalMessageSeq(channelId, channelType)
+	if err != nil {
+		return nil, err
+	}
+	lastSeq, _, err := s.opts.DB.GetChannelLastMessageSeq(channelId, channelType)
+	if err != nil {
+		return nil, err
+	}
+	resp := &channelLocalChecksumResp{
+		ReplicaId: s.opts.NodeId,
+		FirstSeq:  firstSeq,
+		LastSeq:   lastSeq,
+	}
+	if bucketSize == 0 {
+		return resp, nil
+	}
+	resp.Buckets, err = s.opts.DB.ChannelMessageChecksums(channelId, channelType, startSeq, endSeq, bucketSize)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// 通过节点间的rpc获取频道在副本节点上的消息校验和（不经过http api，不受api的认证限制）
+func (s *Server) requestChannelLocalChecksum(ctx context.Context, nodeId uint64, channelId string, channelType uint8, startSeq, endSeq, bucketSize uint64) (*channelLocalChecksumResp, error) {
+	if nodeId == s.opts.NodeId {
+		return s.channelLocalChecksum(channelId, channelType, startSeq, endSeq, bucketSize)
+	}
+	node := s.nodeManager.node(nodeId)
+	if node == nil {
+		return nil, ErrNodeNotFound
+	}
+	return node.requestChannelLocalChecksum(ctx, &ChannelLocalChecksumReq{
+		ChannelId:   channelId,
+		ChannelType: channelType,
+		StartSeq:    startSeq,
+		EndSeq:      endSeq,
+		BucketSize:  bucketSize,
+	})
+}
+
+// 校验频道的各个副本的消息是否和领导一致，只能在频道领导节点上调用
+// startSeq和endSeq限制校验的范围，为0表示不限制
+func (s *Server) checkChannelConsistency(channelId string, channelType uint8, startSeq, endSeq uint64) (*channelConsistencyResp, error) {
+	cfg, err := s.getChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.LeaderId != s.opts.NodeId {
+		return nil, fmt.Errorf("not channel leader, leaderId:%d", cfg.LeaderId)
+	}
+
+	leader, err := s.channelLocalChecksum(channelId, channelType, 0, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &channelConsistencyResp{
+		ChannelId:      channelId,
+		ChannelType:    channelType,
+		LeaderId:       s.opts.NodeId,
+		LeaderFirstSeq: leader.FirstSeq,
+		LeaderLastSeq:  leader.LastSeq,
+		CheckedAt:      time.Now().Unix(),
+	}
+
+	replicaIds := make([]uint64, 0, len(cfg.Replicas)+len(cfg.Learners))
+	replicaIds = append(replicaIds, cfg.Replicas...)
+	replicaIds = append(replicaIds, cfg.Learners...)
+
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	requestGroup, _ := errgroup.WithContext(timeoutCtx)
+	for _, replicaId := range replicaIds {
+		if replicaId == s.opts.NodeId {
+			continue
+		}
+		r := &channelReplicaConsistency{
+			ReplicaId:  replicaId,
+			RoleFormat: s.getReplicaRoleFormat(cfg, replicaId),
+		}
+		result.Replicas = append(result.Replicas, r)
+		if !s.NodeIsOnline(replicaId) {
+			r.Status = consistencyStatusUnavailable
+			r.Error = "node offline"
+			continue
+		}
+		requestGroup.Go(func() error {
+			err := s.checkChannelReplica(timeoutCtx, channelId, channelType, r, leader, startSeq, endSeq)
+			if err != nil {
+				s.Warn("checkChannelReplica failed", zap.Error(err), zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("replicaId", r.ReplicaId))
+				r.Status = consistencyStatusUnavailable
+				r.Error = err.Error()
+			}
+			return nil
+		})
+	}
+	_ = requestGroup.Wait()
+	return result, nil
+}
+
+// 比较一个副本和领导的消息，找出第一条不一致的消息
+func (s *Server) checkChannelReplica(ctx context.Context, channelId string, channelType uint8, r *channelReplicaConsistency, leader *channelLocalChecksumResp, startSeq, endSeq uint64) error {
+	replicaResp, err := s.requestChannelLocalChecksum(ctx, r.ReplicaId, channelId, channelType, 0, 0, 0)
+	if err != nil {
+		return err
+	}
+	r.FirstSeq = replicaResp.FirstSeq
+	r.LastSeq = replicaResp.LastSeq
+
+	// 只比较双方都有的消息（冷存储和保留策略清理的消息不比较）
+	start := max(leader.FirstSeq, replicaResp.FirstSeq, startSeq, 1)
+	end := min(leader.LastSeq, replicaResp.LastSeq)
+	if endSeq > 0 {
+		end = min(end, endSeq)
+	}
+	r.StartSeq = start
+	r.EndSeq = end
+
+	status := consistencyStatusConsistent
+	if replicaResp.LastSeq < leader.LastSeq {
+		status = consistencyStatusLagging
+	}
+	if end < start {
+		r.Status = status
+		return nil
+	}
+
+	// 先按大的分段比较，不一致的分段再细分，直到找到不一致的消息
+	bucketSize := messageChecksumBucketSize(start, end)
+	for {
+		leaderBuckets, err := s.opts.DB.ChannelMessageChecksums(channelId, channelType, start, end, bucketSize)
+		if err != nil {
+			return err
+		}
+		replicaChecksum, err := s.requestChannelLocalChecksum(ctx, r.ReplicaId, channelId, channelType, start, end, bucketSize)
+		if err != nil {
+			return err
+		}
+		if len(leaderBuckets) != len(replicaChecksum.Buckets) {
+			return fmt.Errorf("bucket count not match, leader:%d replica:%d", len(leaderBuckets), len(replicaChecksum.Buckets))
+		}
+		var divergent *wkdb.MessageChecksumBucket
+		for i := range leaderBuckets {
+			if leaderBuckets[i] != replicaChecksum.Buckets[i] {
+				divergent = &leaderBuckets[i]
+				break
+			}
+		}
+		if divergent == nil {
+			r.Status = status
+			return nil
+		}
+		if bucketSize == 1 {
+			r.Status = consistencyStatusDivergent
+			r.DivergentSeq = divergent.StartSeq
+			return nil
+		}
+		start, end = divergent.StartSeq, divergent.EndSeq
+		bucketSize = (end - start + consistencyMinBucketSize) / consistencyMinBucketSize
+	}
+}
+
+// 获取本节点的槽数据校验和
+func (s *Server) slotLocalChecksums(slotIds []uint32) ([]*slotLocalChecksumResp, error) {
+	resps := make([]*slotLocalChecksumResp, 0, len(slotIds))
+	for _, slotId := range slotIds {
+		shardNo := SlotIdToKey(slotId)
+		appliedIdx, err := s.opts.SlotLogStorage.AppliedIndex(shardNo)
+		if err != nil {
+			return nil, err
+		}
+		lastIdx, err := s.opts.SlotLogStorage.LastIndex(shardNo)
+		if err != nil {
+			return nil, err
+		}
+		resps = append(resps, &slotLocalChecksumResp{
+			SlotId:       slotId,
+			ReplicaId:    s.opts.NodeId,
+			AppliedIndex: appliedIdx,
+			LastIndex:    lastIdx,
+		})
+	}
+	checksums, err := s.opts.DB.SlotStateChecksums(s.getSlotId, slotIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, resp := range resps {
+		resp.Checksum = checksums[resp.SlotId]
+	}
+	return resps, nil
+}
+
+// 通过节点间的rpc获取槽在副本节点上的数据校验和
+func (s *Server) requestSlotLocalChecksums(ctx context.Context, nodeId uint64, slotIds []uint32) ([]*slotLocalChecksumResp, error) {
+	node := s.nodeManager.node(nodeId)
+	if node == nil {
+		return nil, ErrNodeNotFound
+	}
+	return node.requestSlotLocalChecksums(ctx, &SlotLocalChecksumReq{SlotIds: slotIds})
+}
+
+// 校验槽的各个副本的数据是否和领导一致，只校验本节点是领导的槽
+func (s *Server) checkSlotsConsistency(slotIds []uint32) ([]*slotConsistencyResp, error) {
+	slots := make([]*pb.Slot, 0, len(slotIds))
+	nodeSlotIds := make(map[uint64][]uint32)
+	localSlotIds := make([]uint32, 0, len(slotIds))
+	for _, slotId := range slotIds {
+		st := s.clusterEventServer.Slot(slotId)
+		if st == nil || st.Leader != s.opts.NodeId {
+			continue
+		}
+		slots = append(slots, st)
+		localSlotIds = append(localSlotIds, slotId)
+		for _, replicaId := range append(append([]uint64{}, st.Replicas...), st.Learners...) {
+			if replicaId == s.opts.NodeId {
+				continue
+			}
+			nodeSlotIds[replicaId] = append(nodeSlotIds[replicaId], slotId)
+		}
+	}
+	if len(slots) == 0 {
+		return nil, nil
+	}
+
+	leaderChecksums, err := s.slotLocalChecksums(localSlotIds)
+	if err != nil {
+		return nil, err
+	}
+
+	// 各个节点的校验和 nodeId -> slotId -> checksum
+	var (
+		nodeChecksumsLock sync.Mutex
+		nodeChecksums     = make(map[uint64]map[uint32]*slotLocalChecksumResp)
+		nodeErrs          = make(map[uint64]error)
+	)
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	requestGroup, _ := errgroup.WithContext(timeoutCtx)
+	for nodeId, ids := range nodeSlotIds {
+		if !s.NodeIsOnline(nodeId) {
+			nodeChecksumsLock.Lock()
+			nodeErrs[nodeId] = errors.New("node offline")
+			nodeChecksumsLock.Unlock()
+			continue
+		}
+		requestGroup.Go(func() error {
+			resps, err := s.requestSlotLocalChecksums(timeoutCtx, nodeId, ids)
+			nodeChecksumsLock.Lock()
+			defer nodeChecksumsLock.Unlock()
+			if err != nil {
+				s.Warn("requestSlotLocalChecksums failed", zap.Error(err), zap.Uint64("nodeId", nodeId))
+				nodeErrs[nodeId] = err
+				return nil
+			}
+			checksums := make(map[uint32]*slotLocalChecksumResp, len(resps))
+			for _, resp := range resps {
+				checksums[resp.SlotId] = resp
+			}
+			nodeChecksums[nodeId] = checksums
+			return nil
+		})
+	}
+	_ = requestGroup.Wait()
+
+	results := make([]*slotConsistencyResp, 0, len(slots))
+	for i, st := range slots {
+		leader := leaderChecksums[i]
+		result := &slotConsistencyResp{
+			SlotId:       st.Id,
+			LeaderId:     s.opts.NodeId,
+			AppliedIndex: leader.AppliedIndex,
+			CheckedAt:    time.Now().Unix(),
+		}
+		for _, replicaId := range append(append([]uint64{}, st.Replicas...), st.Learners...) {
+			if replicaId == s.opts.NodeId {
+				continue
+			}
+			r := &slotReplicaConsistency{ReplicaId: replicaId}
+			result.Replicas = append(result.Replicas, r)
+			if err := nodeErrs[replicaId]; err != nil {
+				r.Status = consistencyStatusUnavailable
+				r.Error = err.Error()
+				continue
+			}
+			replica := nodeChecksums[replicaId][st.Id]
+			if replica == nil || replica.Checksum == nil {
+				r.Status = consistencyStatusUnavailable
+				r.Error = "checksum not found"
+				continue
+			}
+			r.AppliedIndex = replica.AppliedIndex
+			r.LastIndex = replica.LastIndex
+			// 应用的日志不同，数据自然不同，不比较
+			if replica.AppliedIndex != leader.AppliedIndex {
+				r.Status = consistencyStatusLagging
+				continue
+			}
+			r.Divergent = slotChecksumDiff(leader.Checksum, replica.Checksum)
+			if len(r.Divergent) > 0 {
+				r.Status = consistencyStatusDivergent
+			} else {
+				r.Status = consistencyStatusConsistent
+			}
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func slotChecksumDiff(leader, replica *wkdb.SlotStateChecksum) []string {
+	var diffs []string
+	if leader.Users != replica.Users {
+		diffs = append(diffs, "users")
+	}
+	if leader.Channels != replica.Channels {
+		diffs = append(diffs, "channels")
+	}
+	if leader.Subscribers != replica.Subscribers {
+		diffs = append(diffs, "subscribers")
+	}
+	if leader.Conversations != replica.Conversations {
+		diffs = append(diffs, "conversations")
+	}
+	return diffs
+}
+
+// 修复本节点上频道的副本：删除fromSeq（包含）之后的消息，然后重新从领导同步
+func (s *Server) repairChannelReplica(channelId string, channelType uint8, fromSeq uint64) error {
+	if fromSeq == 0 {
+		return errors.New("fromSeq must be greater than 0")
+	}
+	cfg, err := s.getChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		return err
+	}
+	if cfg.LeaderId == s.opts.NodeId {
+		return errors.New("can not repair the leader replica")
+	}
+
+	err = s.truncateChannelReplica(channelId, channelType, fromSeq)
+	if err != nil {
+		return err
+	}
+
+	// 重新加载频道，从领导同步删除的消息
+	timeoutCtx, cancel := context.WithTimeout(s.cancelCtx, s.opts.ReqTimeout)
+	defer cancel()
+	_, err = s.loadOrCreateChannel(timeoutCtx, channelId, channelType)
+	if err != nil {
+		return err
+	}
+	trace.GlobalTrace.Metrics.Cluster().ConsistencyRepairCountAdd(trace.ClusterKindChannel, 1)
+	s.Warn("channel replica repaired", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("fromSeq", fromSeq))
+	return nil
+}
+
+func (s *Server) truncateChannelReplica(channelId string, channelType uint8, fromSeq uint64) error {
+	s.channelKeyLock.Lock(channelId)
+	defer s.channelKeyLock.Unlock(channelId)
+
+	// 先停止频道的副本，避免截断的同时还在追加日志
+	if h := s.channelManager.get(channelId, channelType); h != nil {
+		s.channelManager.remove(h.(*channel))
+	}
+
+	shardNo := wkutil.ChannelToKey(channelId, channelType)
+	appliedIdx, err := s.opts.MessageLogStorage.AppliedIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if appliedIdx >= fromSeq {
+		err = s.opts.MessageLogStorage.SetAppliedIndex(shardNo, fromSeq-1)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.opts.MessageLogStorage.TruncateLogTo(shardNo, fromSeq)
+	if err != nil {
+		return err
+	}
+	_, lastTerm, err := s.opts.MessageLogStorage.LastIndexAndTerm(shardNo)
+	if err != nil {
+		return err
+	}
+	return s.opts.MessageLogStorage.DeleteLeaderTermStartIndexGreaterThanTerm(shardNo, lastTerm)
+}
+
+// 修复本节点上槽的副本：删除本地的槽日志，重新从领导同步并应用所有日志
+// 重新应用会覆盖缺失和不一致的数据，但不会删除本地多出的数据
+func (s *Server) repairSlotReplica(slotId uint32) error {
+	st := s.clusterEventServer.Slot(slotId)
+	if st == nil {
+		return errors.New("slot not found")
+	}
+	if st.Leader == s.opts.NodeId {
+		return errors.New("can not repair the leader replica")
+	}
+	if !wkutil.ArrayContainsUint64(st.Replicas, s.opts.NodeId) && !wkutil.ArrayContainsUint64(st.Learners, s.opts.NodeId) {
+		return errors.New("not slot replica")
+	}
+
+	s.slotManager.remove(slotId)
+
+	shardNo := SlotIdToKey(slotId)
+	err := s.opts.SlotLogStorage.SetAppliedIndex(shardNo, 0)
+	if err != nil {
+		return err
+	}
+	lastIdx, err := s.opts.SlotLogStorage.LastIndex(shardNo)
+	if err != nil {
+		return err
+	}
+	if lastIdx > 0 {
+		err = s.opts.SlotLogStorage.TruncateLogTo(shardNo, 1)
+		if err != nil {
+			return err
+		}
+	}
+	err = s.opts.SlotLogStorage.DeleteLeaderTermStartIndexGreaterThanTerm(shardNo, 0)
+	if err != nil {
+		return err
+	}
+
+	s.addSlot(st)
+
+	trace.GlobalTrace.Metrics.Cluster().ConsistencyRepairCountAdd(trace.ClusterKindSlot, 1)
+	s.Warn("slot replica repaired", zap.Uint32("slotId", slotId))
+	return nil
+}
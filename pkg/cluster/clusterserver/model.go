@@ -347,6 +347,101 @@ func (c *ChannelClusterConfigReq) Unmarshal(data []byte) error {
 	return nil
 }
 
+type ChannelReadIndexReq struct {
+	ChannelId   string // 频道id
+	ChannelType uint8  // 频道类型
+}
+
+func (c *ChannelReadIndexReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(c.ChannelId)
+	enc.WriteUint8(c.ChannelType)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelReadIndexReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if c.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type ChannelReadIndexResp struct {
+	Term      uint32 // 领导任期
+	ReadIndex uint64 // 读下标
+}
+
+func (c *ChannelReadIndexResp) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(c.Term)
+	enc.WriteUint64(c.ReadIndex)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelReadIndexResp) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.Term, err = dec.Uint32(); err != nil {
+		return err
+	}
+	if c.ReadIndex, err = dec.Uint64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type ChannelNextMessageSeqReq struct {
+	ChannelId   string // 频道id
+	ChannelType uint8  // 频道类型
+}
+
+func (c *ChannelNextMessageSeqReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(c.ChannelId)
+	enc.WriteUint8(c.ChannelType)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelNextMessageSeqReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if c.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type ChannelNextMessageSeqResp struct {
+	MessageSeq uint64 // 下一条消息的序号
+}
+
+func (c *ChannelNextMessageSeqResp) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint64(c.MessageSeq)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelNextMessageSeqResp) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.MessageSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	return nil
+}
+
 type ChannelProposeReq struct {
 	ChannelId   string        // 频道id
 	ChannelType uint8         // 频道类型
@@ -1770,3 +1865,156 @@ type channelReplicaDetailResp struct {
 	RoleFormat        string `json:"role_format"`          // 角色格式化
 	LastMsgTimeFormat string `json:"last_msg_time_format"` // 最新消息时间格式化
 }
+
+// 副本一致性校验的状态
+const (
+	consistencyStatusConsistent  = "consistent"  // 一致
+	consistencyStatusDivergent   = "divergent"   // 不一致
+	consistencyStatusLagging     = "lagging"     // 落后于领导（同步中，不参与比较）
+	consistencyStatusUnavailable = "unavailable" // 副本不可用（离线或请求失败）
+)
+
+type ChannelLocalChecksumReq struct {
+	ChannelId   string // 频道id
+	ChannelType uint8  // 频道类型
+	StartSeq    uint64 // 开始序号
+	EndSeq      uint64 // 结束序号
+	BucketSize  uint64 // 分段大小，为0时只返回消息序号范围
+}
+
+func (c *ChannelLocalChecksumReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(c.ChannelId)
+	enc.WriteUint8(c.ChannelType)
+	enc.WriteUint64(c.StartSeq)
+	enc.WriteUint64(c.EndSeq)
+	enc.WriteUint64(c.BucketSize)
+	return enc.Bytes(), nil
+}
+
+func (c *ChannelLocalChecksumReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if c.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if c.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if c.StartSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if c.EndSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if c.BucketSize, err = dec.Uint64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+type SlotLocalChecksumReq struct {
+	SlotIds []uint32
+}
+
+func (s *SlotLocalChecksumReq) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(s.SlotIds)))
+	for _, slotId := range s.SlotIds {
+		enc.WriteUint32(slotId)
+	}
+	return enc.Bytes(), nil
+}
+
+func (s *SlotLocalChecksumReq) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	s.SlotIds = make([]uint32, 0, count)
+	for i := uint32(0); i < count; i++ {
+		slotId, err := dec.Uint32()
+		if err != nil {
+			return err
+		}
+		s.SlotIds = append(s.SlotIds, slotId)
+	}
+	return nil
+}
+
+type channelLocalChecksumResp struct {
+	ReplicaId uint64                       `json:"replica_id"` // 副本节点id
+	FirstSeq  uint64                       `json:"first_seq"`  // 本地第一条消息序号（不包含已转冷的消息）
+	LastSeq   uint64                       `json:"last_seq"`   // 本地最新消息序号
+	Buckets   []wkdb.MessageChecksumBucket `json:"buckets"`    // 消息校验和
+}
+
+type channelReplicaConsistency struct {
+	ReplicaId    uint64 `json:"replica_id"`              // 副本节点id
+	RoleFormat   string `json:"role_format"`             // 角色
+	Status       string `json:"status"`                  // 状态
+	FirstSeq     uint64 `json:"first_seq"`               // 本地第一条消息序号
+	LastSeq      uint64 `json:"last_seq"`                // 本地最新消息序号
+	StartSeq     uint64 `json:"start_seq"`               // 校验的开始序号
+	EndSeq       uint64 `json:"end_seq"`                 // 校验的结束序号
+	DivergentSeq uint64 `json:"divergent_seq,omitempty"` // 第一条不一致的消息序号
+	Error        string `json:"error,omitempty"`         // 错误信息
+}
+
+type channelConsistencyResp struct {
+	ChannelId      string                       `json:"channel_id"`
+	ChannelType    uint8                        `json:"channel_type"`
+	LeaderId       uint64                       `json:"leader_id"`        // 领导节点id
+	LeaderFirstSeq uint64                       `json:"leader_first_seq"` // 领导本地第一条消息序号
+	LeaderLastSeq  uint64                       `json:"leader_last_seq"`  // 领导本地最新消息序号
+	CheckedAt      int64                        `json:"checked_at"`       // 校验时间（秒）
+	Replicas       []*channelReplicaConsistency `json:"replicas"`         // 追随者和学习者的校验结果
+}
+
+// 是否有不一致的副本
+func (c *channelConsistencyResp) divergent() bool {
+	for _, r := range c.Replicas {
+		if r.Status == consistencyStatusDivergent {
+			return true
+		}
+	}
+	return false
+}
+
+type slotLocalChecksumResp struct {
+	SlotId       uint32                  `json:"slot_id"`
+	ReplicaId    uint64                  `json:"replica_id"`    // 副本节点id
+	AppliedIndex uint64                  `json:"applied_index"` // 已应用的日志下标
+	LastIndex    uint64                  `json:"last_index"`    // 最新日志下标
+	Checksum     *wkdb.SlotStateChecksum `json:"checksum"`      // 槽数据校验和
+}
+
+type slotReplicaConsistency struct {
+	ReplicaId    uint64   `json:"replica_id"`          // 副本节点id
+	Status       string   `json:"status"`              // 状态
+	AppliedIndex uint64   `json:"applied_index"`       // 已应用的日志下标
+	LastIndex    uint64   `json:"last_index"`          // 最新日志下标
+	Divergent    []string `json:"divergent,omitempty"` // 不一致的数据类型（users, channels, subscribers, conversations）
+	Error        string   `json:"error,omitempty"`     // 错误信息
+}
+
+type slotConsistencyResp struct {
+	SlotId       uint32                    `json:"slot_id"`
+	LeaderId     uint64                    `json:"leader_id"`     // 领导节点id
+	AppliedIndex uint64                    `json:"applied_index"` // 领导已应用的日志下标
+	CheckedAt    int64                     `json:"checked_at"`    // 校验时间（秒）
+	Replicas     []*slotReplicaConsistency `json:"replicas"`      // 追随者和学习者的校验结果
+}
+
+// 是否有不一致的副本
+func (s *slotConsistencyResp) divergent() bool {
+	for _, r := range s.Replicas {
+		if r.Status == consistencyStatusDivergent {
+			return true
+		}
+	}
+	return false
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/client"
 	"github.com/WuKongIM/WuKongIM/pkg/wkserver/proto"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
 	"github.com/lni/goutils/netutil"
 	circuit "github.com/lni/goutils/netutil/rubyist/circuitbreaker"
 	"github.com/lni/goutils/syncutil"
@@ -223,6 +224,51 @@ func (n *node) requestChannelProposeMessage(ctx context.Context, req *ChannelPro
 	return proposeMessageResp, nil
 }
 
+func (n *node) requestChannelNextMessageSeq(ctx context.Context, req *ChannelNextMessageSeqReq) (*ChannelNextMessageSeqResp, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/nextMessageSeq", data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		if len(resp.Body) > 0 {
+			return nil, errors.New(string(resp.Body))
+		}
+		return nil, fmt.Errorf("requestChannelNextMessageSeq is failed, status:%d", resp.Status)
+	}
+	nextMessageSeqResp := &ChannelNextMessageSeqResp{}
+	if err = nextMessageSeqResp.Unmarshal(resp.Body); err != nil {
+		return nil, err
+	}
+	return nextMessageSeqResp, nil
+}
+
+func (n *node) requestChannelReadIndex(ctx context.Context, req *ChannelReadIndexReq) (*ChannelReadIndexResp, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/readIndex", data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		if len(resp.Body) > 0 {
+			return nil, errors.New(string(resp.Body))
+		}
+		return nil, fmt.Errorf("requestChannelReadIndex is failed, status:%d", resp.Status)
+	}
+	readIndexResp := &ChannelReadIndexResp{}
+	err = readIndexResp.Unmarshal(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return readIndexResp, nil
+}
+
 func (n *node) requestSlotLogInfo(ctx context.Context, req *SlotLogInfoReq) (*SlotLogInfoResp, error) {
 	data, err := req.Marshal()
 	if err != nil {
@@ -302,3 +348,47 @@ func (sq *sendQueue) decrease(msg *proto.Message) {
 	sq.count.Dec()
 	sq.rl.Decrease(uint64(size))
 }
+
+func (n *node) requestChannelLocalChecksum(ctx context.Context, req *ChannelLocalChecksumReq) (*channelLocalChecksumResp, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/channel/localChecksum", data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		if len(resp.Body) > 0 {
+			return nil, errors.New(string(resp.Body))
+		}
+		return nil, fmt.Errorf("requestChannelLocalChecksum is failed, status:%d", resp.Status)
+	}
+	checksumResp := &channelLocalChecksumResp{}
+	if err = wkutil.ReadJSONByByte(resp.Body, checksumResp); err != nil {
+		return nil, err
+	}
+	return checksumResp, nil
+}
+
+func (n *node) requestSlotLocalChecksums(ctx context.Context, req *SlotLocalChecksumReq) ([]*slotLocalChecksumResp, error) {
+	data, err := req.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := n.client.RequestWithContext(ctx, "/slot/localChecksums", data)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Status != proto.StatusOK {
+		if len(resp.Body) > 0 {
+			return nil, errors.New(string(resp.Body))
+		}
+		return nil, fmt.Errorf("requestSlotLocalChecksums is failed, status:%d", resp.Status)
+	}
+	var checksumResps []*slotLocalChecksumResp
+	if err = wkutil.ReadJSONByByte(resp.Body, &checksumResps); err != nil {
+		return nil, err
+	}
+	return checksumResps, nil
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterconfig/pb"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"go.uber.org/zap/zapcore"
 )
@@ -84,6 +85,8 @@ type Options struct {
 
 	SlotDbShardNum int // 槽位数据库分片数量
 
+	EncryptFS *encryption.FS // 静态数据加密的文件系统（槽位日志、分布式配置） 为nil表示不加密
+
 	PageSize int
 
 	TickInterval          time.Duration // 分布式tick间隔
@@ -95,6 +98,11 @@ type Options struct {
 
 	PongMaxTick int // 节点超过多少tick没有回应心跳就认为是掉线
 
+	ReadIndexTimeout time.Duration // 追随者一致性读时，获取领导读下标并等待本地日志追上的超时时间
+
+	ConsistencyCheckInterval time.Duration // 后台副本一致性校验的间隔，为0则不开启后台校验
+	ConsistencyCheckWindow   uint64        // 后台校验频道消息时，只校验最新的多少条消息
+
 	Auth auth.AuthConfig
 
 	LokiUrl string // loki url example: http://localhost:3100
@@ -131,6 +139,10 @@ func NewOptions(opt ...Option) *Options {
 		SlotReactorSubCount:    128,
 		PongMaxTick:            30,
 		SlotDbShardNum:         8,
+		ReadIndexTimeout:       500 * time.Millisecond,
+
+		ConsistencyCheckInterval: time.Hour,
+		ConsistencyCheckWindow:   1000,
 
 		LokiJob: "wk",
 	}
@@ -397,6 +409,24 @@ func WithPongMaxTick(tick int) Option {
 	}
 }
 
+func WithReadIndexTimeout(timeout time.Duration) Option {
+	return func(o *Options) {
+		o.ReadIndexTimeout = timeout
+	}
+}
+
+func WithConsistencyCheckInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.ConsistencyCheckInterval = interval
+	}
+}
+
+func WithConsistencyCheckWindow(window uint64) Option {
+	return func(o *Options) {
+		o.ConsistencyCheckWindow = window
+	}
+}
+
 func WithSlotDbShardNum(num int) Option {
 	return func(o *Options) {
 		o.SlotDbShardNum = num
@@ -426,3 +456,9 @@ func WithLokiJob(job string) Option {
 		o.LokiJob = job
 	}
 }
+
+func WithEncryptFS(fs *encryption.FS) Option {
+	return func(o *Options) {
+		o.EncryptFS = fs
+	}
+}
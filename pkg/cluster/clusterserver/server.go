@@ -42,6 +42,7 @@ type Server struct {
 	netServer              *wkserver.Server        // 节点之间通讯的网络服务
 	channelElectionPool    *ants.Pool              // 频道选举的协程池
 	channelElectionManager *channelElectionManager // 频道选举管理者
+	consistencyChecker     *consistencyChecker     // 副本一致性校验
 	channelLoadPool        *ants.Pool              // 加载频道的协程池
 	channelLoadMap         map[string]struct{}     // 频道是否在加载中的map
 	channelLoadMapLock     sync.RWMutex            // 频道是否在加载中的map锁
@@ -74,7 +75,7 @@ func New(opts *Options) *Server {
 	s.channelManager = newChannelManager(s)
 
 	if opts.SlotLogStorage == nil {
-		s.slotStorage = NewPebbleShardLogStorage(path.Join(opts.DataDir, "logdb"), uint32(opts.SlotDbShardNum))
+		s.slotStorage = NewPebbleShardLogStorage(path.Join(opts.DataDir, "logdb"), uint32(opts.SlotDbShardNum), opts.EncryptFS)
 		opts.SlotLogStorage = s.slotStorage
 	}
 
@@ -121,6 +122,7 @@ func New(opts *Options) *Server {
 		clusterevent.WithHeartbeatIntervalTick(opts.HeartbeatIntervalTick),
 		clusterevent.WithTickInterval(opts.TickInterval),
 		clusterevent.WithPongMaxTick(opts.PongMaxTick),
+		clusterevent.WithEncryptFS(opts.EncryptFS),
 	))
 
 	channelElectionPool, err := ants.NewPool(s.opts.ChannelElectionPoolSize, ants.WithNonblocking(false), ants.WithDisablePurge(true), ants.WithPanicHandler(func(err interface{}) {
@@ -148,6 +150,7 @@ func New(opts *Options) *Server {
 		}),
 	)
 	s.channelElectionManager = newChannelElectionManager(s)
+	s.consistencyChecker = newConsistencyChecker(s)
 	s.cancelCtx, s.cancelFnc = context.WithCancel(context.Background())
 	return s
 }
@@ -225,6 +228,9 @@ func (s *Server) Start() error {
 		s.stopper.RunWorker(s.joinLoop)
 	}
 
+	// 副本一致性后台校验
+	s.consistencyChecker.start()
+
 	// 设置监控数据的observer
 	s.setObservers()
 
@@ -250,6 +256,7 @@ func (s *Server) Stop() {
 	s.stopped.Store(true)
 	s.cancelFnc()
 	s.stopper.Stop()
+	s.consistencyChecker.stop()
 	s.nodeManager.stop()
 	s.channelElectionManager.stop()
 	s.netServer.Stop()
@@ -261,6 +268,12 @@ func (s *Server) Stop() {
 
 }
 
+// SetLoki 修改日志查询使用的loki地址和job（重新加载配置时使用）
+func (s *Server) SetLoki(url string, job string) {
+	s.opts.LokiUrl = url
+	s.opts.LokiJob = job
+}
+
 // 提案频道分布式配置
 func (s *Server) ProposeChannelClusterConfig(ctx context.Context, cfg wkdb.ChannelClusterConfig) error {
 	return s.opts.ChannelClusterStorage.Propose(ctx, cfg)
@@ -1,6 +1,10 @@
 package cluster
 
 import (
+	"net/http"
+
+	"github.com/WuKongIM/WuKongIM/pkg/auth"
+	"github.com/WuKongIM/WuKongIM/pkg/auth/resource"
 	"github.com/WuKongIM/WuKongIM/pkg/wkhttp"
 )
 
@@ -8,52 +12,75 @@ func (s *Server) ServerAPI(route *wkhttp.WKHttp, prefix string) {
 	s.apiPrefix = prefix
 
 	// ================== 节点 ==================
-	route.GET(s.formatPath("/nodes"), s.nodesGet)                     // 获取所有节点
-	route.GET(s.formatPath("/node"), s.nodeGet)                       // 获取当前节点信息
-	route.GET(s.formatPath("/simpleNodes"), s.simpleNodesGet)         // 获取简单节点信息
-	route.GET(s.formatPath("/nodes/:id/channels"), s.nodeChannelsGet) // 获取节点的所有频道信息
+	route.GET(s.formatPath("/nodes"), s.permission(resource.ClusterNode, auth.ActionRead), s.nodesGet)                     // 获取所有节点
+	route.GET(s.formatPath("/node"), s.permission(resource.ClusterNode, auth.ActionRead), s.nodeGet)                       // 获取当前节点信息
+	route.GET(s.formatPath("/simpleNodes"), s.permission(resource.ClusterNode, auth.ActionRead), s.simpleNodesGet)         // 获取简单节点信息
+	route.GET(s.formatPath("/nodes/:id/channels"), s.permission(resource.ClusterNode, auth.ActionRead), s.nodeChannelsGet) // 获取节点的所有频道信息
 
 	// ================== slot ==================
 	// route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.channelClusterConfigGet) // 获取频道分布式配置
-	route.GET(s.formatPath("/slots"), s.slotsGet)                        // 获取指定的槽信息
-	route.GET(s.formatPath("/allslot"), s.allSlotsGet)                   // 获取所有槽信息
-	route.GET(s.formatPath("/slots/:id/config"), s.slotClusterConfigGet) // 槽分布式配置
-	route.GET(s.formatPath("/slots/:id/channels"), s.slotChannelsGet)    // 获取某个槽的所有频道信息
-	route.POST(s.formatPath("/slots/:id/migrate"), s.slotMigrate)        // 迁移槽
+	route.GET(s.formatPath("/slots"), s.permission(resource.ClusterSlot, auth.ActionRead), s.slotsGet)                           // 获取指定的槽信息
+	route.GET(s.formatPath("/allslot"), s.permission(resource.ClusterSlot, auth.ActionRead), s.allSlotsGet)                      // 获取所有槽信息
+	route.GET(s.formatPath("/slots/:id/config"), s.permission(resource.ClusterSlot, auth.ActionRead), s.slotClusterConfigGet)    // 槽分布式配置
+	route.GET(s.formatPath("/slots/:id/channels"), s.permission(resource.ClusterSlot, auth.ActionRead), s.slotChannelsGet)       // 获取某个槽的所有频道信息
+	route.POST(s.formatPath("/slots/:id/migrate"), s.permission(resource.Slot.Migrate, auth.ActionWrite), s.slotMigrate)         // 迁移槽
+	route.GET(s.formatPath("/slots/:id/consistency"), s.permission(resource.ClusterSlot, auth.ActionRead), s.slotConsistencyGet) // 校验槽副本的一致性
+	route.POST(s.formatPath("/slots/:id/repair"), s.permission(resource.Slot.Repair, auth.ActionWrite), s.slotRepair)            // 修复槽不一致的副本
+	route.POST(s.formatPath("/slots/:id/localRepair"), s.permission(resource.Slot.Repair, auth.ActionWrite), s.slotLocalRepair)  // 修复本节点的槽副本
 
 	// ================== message ==================
-	route.GET(s.formatPath("/messages"), s.messageSearch) // 搜索消息
+	route.GET(s.formatPath("/messages"), s.permission(resource.Data.Message, auth.ActionRead), s.messageSearch) // 搜索消息
 
 	// ================== channel ==================
-	route.GET(s.formatPath("/channels"), s.channelSearch)                                        // 频道搜索
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/subscribers"), s.subscribersGet) // 获取频道的订阅者列表
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/denylist"), s.denylistGet)       // 获取黑名单列表
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/allowlist"), s.allowlistGet)     // 获取白名单列表
+	route.GET(s.formatPath("/channels"), s.permission(resource.Data.Channel, auth.ActionRead), s.channelSearch)                                        // 频道搜索
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/subscribers"), s.permission(resource.Data.Channel, auth.ActionRead), s.subscribersGet) // 获取频道的订阅者列表
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/denylist"), s.permission(resource.Data.Channel, auth.ActionRead), s.denylistGet)       // 获取黑名单列表
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/allowlist"), s.permission(resource.Data.Channel, auth.ActionRead), s.allowlistGet)     // 获取白名单列表
 
 	// ================== user ==================
-	route.GET(s.formatPath("/users"), s.userSearch)     // 用户搜索
-	route.GET(s.formatPath("/devices"), s.deviceSearch) // 设备搜索
+	route.GET(s.formatPath("/users"), s.permission(resource.Data.User, auth.ActionRead), s.userSearch)     // 用户搜索
+	route.GET(s.formatPath("/devices"), s.permission(resource.Data.User, auth.ActionRead), s.deviceSearch) // 设备搜索
 
 	// ================== conversation ==================
-	route.GET(s.formatPath("/conversations"), s.conversationSearch) // 搜索最近会话消息
+	route.GET(s.formatPath("/conversations"), s.permission(resource.Data.Conversation, auth.ActionRead), s.conversationSearch) // 搜索最近会话消息
 
 	// ================== cluster ==================
 
-	route.GET(s.formatPath("/info"), s.clusterInfoGet) // 获取集群信息
-	route.GET(s.formatPath("/logs"), s.clusterLogs)    // 获取节点日志
+	route.GET(s.formatPath("/info"), s.permission(resource.Cluster.Info, auth.ActionRead), s.clusterInfoGet)        // 获取集群信息
+	route.GET(s.formatPath("/logs"), s.permission(resource.Cluster.Logs, auth.ActionRead), s.clusterLogs)           // 获取节点日志
+	route.GET(s.formatPath("/consistency"), s.permission(resource.Cluster.Info, auth.ActionRead), s.consistencyGet) // 获取节点最近一轮后台一致性校验的结果
 
 	// ================== cluster channel ==================
-	route.POST(s.formatPath("/channels/:channel_id/:channel_type/migrate"), s.channelMigrate)          // 迁移频道
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.channelClusterConfig)      // 获取频道的分布式配置
-	route.POST(s.formatPath("/channels/:channel_id/:channel_type/start"), s.channelStart)              // 开始频道
-	route.POST(s.formatPath("/channels/:channel_id/:channel_type/stop"), s.channelStop)                // 停止频道
-	route.POST(s.formatPath("/channel/status"), s.channelStatus)                                       // 获取频道状态
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/replicas"), s.channelReplicas)         // 获取频道副本信息
-	route.GET(s.formatPath("/channels/:channel_id/:channel_type/localReplica"), s.channelLocalReplica) // 获取频道在本节点的副本信息
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/migrate"), s.permission(resource.ClusterChannel.Migrate, auth.ActionWrite), s.channelMigrate)         // 迁移频道
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/config"), s.permission(resource.ClusterChannel.Config, auth.ActionRead), s.channelClusterConfig)       // 获取频道的分布式配置
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/start"), s.permission(resource.ClusterChannel.Start, auth.ActionWrite), s.channelStart)               // 开始频道
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/stop"), s.permission(resource.ClusterChannel.Stop, auth.ActionWrite), s.channelStop)                  // 停止频道
+	route.POST(s.formatPath("/channel/status"), s.permission(resource.ClusterChannel.Config, auth.ActionRead), s.channelStatus)                                        // 获取频道状态
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/replicas"), s.permission(resource.ClusterChannel.Config, auth.ActionRead), s.channelReplicas)          // 获取频道副本信息
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/localReplica"), s.permission(resource.ClusterChannel.Config, auth.ActionRead), s.channelLocalReplica)  // 获取频道在本节点的副本信息
+	route.GET(s.formatPath("/channels/:channel_id/:channel_type/consistency"), s.permission(resource.ClusterChannel.Config, auth.ActionRead), s.channelConsistencyGet) // 校验频道副本的一致性
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/repair"), s.permission(resource.ClusterChannel.Repair, auth.ActionWrite), s.channelRepair)            // 修复频道不一致的副本
+	route.POST(s.formatPath("/channels/:channel_id/:channel_type/localRepair"), s.permission(resource.ClusterChannel.Repair, auth.ActionWrite), s.channelLocalRepair)  // 修复本节点的频道副本
 
 	// ================== logs ==================
-	route.GET(s.formatPath("/message/trace"), s.messageTrace)                // 获取消息轨迹
-	route.GET(s.formatPath("/message/trace/recvack"), s.messageRecvackTrace) // 获取收到消息回执轨迹
-	route.GET(s.formatPath("/logs/tail"), s.logsTail)                        // tail日志 websocket接口
+	route.GET(s.formatPath("/message/trace"), s.permission(resource.Data.Message, auth.ActionRead), s.messageTrace)                // 获取消息轨迹
+	route.GET(s.formatPath("/message/trace/recvack"), s.permission(resource.Data.Message, auth.ActionRead), s.messageRecvackTrace) // 获取收到消息回执轨迹
+	route.GET(s.formatPath("/logs/tail"), s.permission(resource.Cluster.Logs, auth.ActionRead), s.logsTail)                        // tail日志 websocket接口
+
+}
 
+// permission 接口的权限校验，已由前置中间件校验过权限的请求（例如api key）直接放行
+func (s *Server) permission(rs resource.Id, action auth.Action) wkhttp.HandlerFunc {
+	return func(c *wkhttp.Context) {
+		if c.PermissionChecked() {
+			c.Next()
+			return
+		}
+		if !s.opts.Auth.HasPermissionWithContext(c, rs, action) {
+			c.ResponseStatus(http.StatusUnauthorized)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
 }
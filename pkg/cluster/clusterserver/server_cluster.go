@@ -59,6 +59,96 @@ func (s *Server) LeaderOfChannelForRead(channelId string, channelType uint8) (*p
 	return node, nil
 }
 
+// ReplicasOfChannel 获取频道的领导节点ID和副本节点ID(不激活频道)
+func (s *Server) ReplicasOfChannel(channelId string, channelType uint8) (uint64, []uint64, error) {
+	cfg, err := s.loadOnlyChannelClusterConfig(channelId, channelType)
+	if err != nil {
+		return 0, nil, err
+	}
+	return cfg.LeaderId, cfg.Replicas, nil
+}
+
+// WaitLocalReadableOfChannel 等待本节点的频道副本可以提供线性一致性读
+// 追随者向领导获取读下标，然后等待本地已应用的日志追上读下标，返回false表示本节点不能提供一致性读，需要由领导处理
+func (s *Server) WaitLocalReadableOfChannel(ctx context.Context, channelId string, channelType uint8) (bool, error) {
+	handler := s.channelManager.get(channelId, channelType)
+	if handler == nil { // 频道副本没有在本节点激活
+		return false, nil
+	}
+	ch := handler.(*channel)
+	rs := ch.getReadState()
+	if rs.Role == replica.RoleLeader {
+		return rs.ReadIndexErr == nil, nil
+	}
+	if rs.Leader == 0 || (rs.Role != replica.RoleFollower && rs.Role != replica.RoleLearner) {
+		return false, nil
+	}
+	node := s.nodeManager.node(rs.Leader)
+	if node == nil {
+		return false, ErrNodeNotFound
+	}
+
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.opts.ReadIndexTimeout)
+	defer cancel()
+
+	resp, err := node.requestChannelReadIndex(timeoutCtx, &ChannelReadIndexReq{
+		ChannelId:   channelId,
+		ChannelType: channelType,
+	})
+	if err != nil {
+		return false, err
+	}
+	if resp.Term != rs.Term { // 任期已经变化，领导信息可能已过期
+		return false, nil
+	}
+
+	// 等待本地已应用的日志追上读下标
+	tk := time.NewTicker(time.Millisecond * 10)
+	defer tk.Stop()
+	for {
+		rs = ch.getReadState()
+		if rs.Term != resp.Term {
+			return false, nil
+		}
+		if rs.Readable(resp.ReadIndex) {
+			return true, nil
+		}
+		select {
+		case <-tk.C:
+		case <-timeoutCtx.Done():
+			return false, nil
+		}
+	}
+}
+
+// NextMessageSeqOfChannel 获取频道下一条消息的序号
+// 消息序号就是频道日志的下标，所以由频道领导根据最新的日志下标获取，保证与消息追加的顺序一致
+func (s *Server) NextMessageSeqOfChannel(ctx context.Context, channelId string, channelType uint8) (uint64, error) {
+	ch, err := s.loadOrCreateChannel(ctx, channelId, channelType)
+	if err != nil {
+		return 0, err
+	}
+	if ch.isLeader() {
+		lastIndex, _ := ch.LastLogIndexAndTerm()
+		return lastIndex + 1, nil
+	}
+	node := s.nodeManager.node(ch.leaderId())
+	if node == nil {
+		s.Error("NextMessageSeqOfChannel: leader node not found", zap.Uint64("leaderId", ch.leaderId()))
+		return 0, ErrNodeNotFound
+	}
+	timeoutCtx, cancel := context.WithTimeout(ctx, s.opts.ReqTimeout)
+	defer cancel()
+	resp, err := node.requestChannelNextMessageSeq(timeoutCtx, &ChannelNextMessageSeqReq{
+		ChannelId:   channelId,
+		ChannelType: channelType,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return resp.MessageSeq, nil
+}
+
 func (s *Server) SlotLeaderIdOfChannel(channelId string, channelType uint8) (nodeID uint64, err error) {
 	slotId := s.getSlotId(channelId)
 	slot := s.clusterEventServer.Slot(slotId)
@@ -34,6 +34,17 @@ func (s *Server) setRoutes() {
 
 	// 获取槽日志信息
 	s.netServer.Route("/slot/logInfo", s.handleSlotLogInfo)
+
+	// 获取频道的读下标（追随者一致性读）
+	s.netServer.Route("/channel/readIndex", s.handleChannelReadIndex)
+
+	// 获取频道下一条消息的序号
+	s.netServer.Route("/channel/nextMessageSeq", s.handleChannelNextMessageSeq)
+
+	// 获取频道在本节点的消息校验和（副本一致性校验）
+	s.netServer.Route("/channel/localChecksum", s.handleChannelLocalChecksum)
+	// 获取本节点的槽数据校验和（副本一致性校验）
+	s.netServer.Route("/slot/localChecksums", s.handleSlotLocalChecksums)
 }
 
 func (s *Server) handleChannelLastLogInfo(c *wkserver.Context) {
@@ -382,6 +393,66 @@ func (s *Server) handleChannelLeaderTermStartIndex(c *wkserver.Context) {
 	c.Write(resultBytes)
 }
 
+func (s *Server) handleChannelReadIndex(c *wkserver.Context) {
+	req := &ChannelReadIndexReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ChannelReadIndexReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	handler := s.channelManager.get(req.ChannelId, req.ChannelType)
+	if handler == nil {
+		c.WriteErr(ErrChannelNotFound)
+		return
+	}
+	rs := handler.(*channel).getReadState()
+	if rs.ReadIndexErr != nil { // 不是领导、租约无效或者还没有提交成为领导之前的日志
+		c.WriteErr(rs.ReadIndexErr)
+		return
+	}
+	resp := &ChannelReadIndexResp{
+		Term:      rs.Term,
+		ReadIndex: rs.ReadIndex,
+	}
+	data, err := resp.Marshal()
+	if err != nil {
+		s.Error("marshal ChannelReadIndexResp failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
+func (s *Server) handleChannelNextMessageSeq(c *wkserver.Context) {
+	req := &ChannelNextMessageSeqReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ChannelNextMessageSeqReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	ch, err := s.loadOrCreateChannel(s.cancelCtx, req.ChannelId, req.ChannelType)
+	if err != nil {
+		s.Error("loadOrCreateChannel failed", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+		c.WriteErr(err)
+		return
+	}
+	if !ch.isLeader() {
+		c.WriteErr(ErrNotLeader)
+		return
+	}
+	lastIndex, _ := ch.LastLogIndexAndTerm()
+	resp := &ChannelNextMessageSeqResp{
+		MessageSeq: lastIndex + 1,
+	}
+	data, err := resp.Marshal()
+	if err != nil {
+		s.Error("marshal ChannelNextMessageSeqResp failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.Write(data)
+}
+
 func (s *Server) handleSlotLogInfo(c *wkserver.Context) {
 	req := &SlotLogInfoReq{}
 	if err := req.Unmarshal(c.Body()); err != nil {
@@ -407,3 +478,35 @@ func (s *Server) handleSlotLogInfo(c *wkserver.Context) {
 	}
 	c.Write(data)
 }
+
+func (s *Server) handleChannelLocalChecksum(c *wkserver.Context) {
+	req := &ChannelLocalChecksumReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal ChannelLocalChecksumReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	resp, err := s.channelLocalChecksum(req.ChannelId, req.ChannelType, req.StartSeq, req.EndSeq, req.BucketSize)
+	if err != nil {
+		s.Error("channelLocalChecksum failed", zap.Error(err), zap.String("channelId", req.ChannelId), zap.Uint8("channelType", req.ChannelType))
+		c.WriteErr(err)
+		return
+	}
+	c.Write([]byte(wkutil.ToJSON(resp)))
+}
+
+func (s *Server) handleSlotLocalChecksums(c *wkserver.Context) {
+	req := &SlotLocalChecksumReq{}
+	if err := req.Unmarshal(c.Body()); err != nil {
+		s.Error("unmarshal SlotLocalChecksumReq failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	resps, err := s.slotLocalChecksums(req.SlotIds)
+	if err != nil {
+		s.Error("slotLocalChecksums failed", zap.Error(err))
+		c.WriteErr(err)
+		return
+	}
+	c.Write([]byte(wkutil.ToJSON(resps)))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterserver/key"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
 	"github.com/cockroachdb/pebble"
@@ -23,6 +24,8 @@ type PebbleShardLogStorage struct {
 	batchDbs []*wkdb.BatchDB
 	shardNum uint32 // 分片数量
 	path     string
+	fs       *encryption.FS // 静态数据加密 为nil表示不加密
+	readOnly bool           // 以只读方式打开
 	wo       *pebble.WriteOptions
 	noSync   *pebble.WriteOptions
 	wklog.Log
@@ -31,10 +34,11 @@ type PebbleShardLogStorage struct {
 	stopper syncutil.Stopper
 }
 
-func NewPebbleShardLogStorage(path string, shardNum uint32) *PebbleShardLogStorage {
+func NewPebbleShardLogStorage(path string, shardNum uint32, fs *encryption.FS) *PebbleShardLogStorage {
 	return &PebbleShardLogStorage{
 		shardNum: shardNum,
 		path:     path,
+		fs:       fs,
 		wo: &pebble.WriteOptions{
 			Sync: true,
 		},
@@ -47,6 +51,13 @@ func NewPebbleShardLogStorage(path string, shardNum uint32) *PebbleShardLogStora
 	}
 }
 
+// NewReadOnlyPebbleShardLogStorage 以只读方式打开槽日志存储（离线查看停止节点的数据）
+func NewReadOnlyPebbleShardLogStorage(path string, shardNum uint32, fs *encryption.FS) *PebbleShardLogStorage {
+	p := NewPebbleShardLogStorage(path, shardNum, fs)
+	p.readOnly = true
+	return p
+}
+
 func (p *PebbleShardLogStorage) defaultPebbleOptions() *pebble.Options {
 	blockSize := 32 * 1024
 	sz := 16 * 1024 * 1024
@@ -81,11 +92,17 @@ func (p *PebbleShardLogStorage) defaultPebbleOptions() *pebble.Options {
 
 func (p *PebbleShardLogStorage) Open() error {
 
-	for i := 0; i < 2; i++ {
-		p.stopper.RunWorker(p.appendLoop)
+	if !p.readOnly {
+		for i := 0; i < 2; i++ {
+			p.stopper.RunWorker(p.appendLoop)
+		}
 	}
 
 	opts := p.defaultPebbleOptions()
+	if p.fs != nil {
+		opts.FS = p.fs
+	}
+	opts.ReadOnly = p.readOnly
 	for i := 0; i < int(p.shardNum); i++ {
 		db, err := pebble.Open(fmt.Sprintf("%s/shard%03d", p.path, i), opts)
 		if err != nil {
@@ -557,6 +574,26 @@ func (p *PebbleShardLogStorage) DeleteLeaderTermStartIndexGreaterThanTerm(shardN
 	return batch.Commit(p.wo)
 }
 
+// LeaderTermStartIndexes 获取所有领导任期开始的第一条日志索引（按任期升序）
+func (p *PebbleShardLogStorage) LeaderTermStartIndexes(shardNo string) ([]wkdb.LeaderTermStartIndex, error) {
+	iter := p.shardDB(shardNo).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewLeaderTermStartIndexKey(shardNo, 0),
+		UpperBound: key.NewLeaderTermStartIndexKey(shardNo, math.MaxUint32),
+	})
+	defer iter.Close()
+	indexes := make([]wkdb.LeaderTermStartIndex, 0)
+	for iter.First(); iter.Valid(); iter.Next() {
+		if len(iter.Value()) < 8 {
+			continue
+		}
+		indexes = append(indexes, wkdb.LeaderTermStartIndex{
+			Term:       key.GetTermFromLeaderTermStartIndexKey(iter.Key()),
+			StartIndex: binary.BigEndian.Uint64(iter.Value()),
+		})
+	}
+	return indexes, nil
+}
+
 func (p *PebbleShardLogStorage) saveMaxIndex(shardNo string, index uint64) error {
 
 	batch := p.shardBatchDB(shardNo).NewBatch()
@@ -9,6 +9,7 @@ import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
 	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/sync/errgroup"
 )
@@ -24,7 +25,7 @@ func TestAppend(t *testing.T) {
 	trace.SetGlobalTrace(traceObj)
 
 	dir := t.TempDir()
-	s := NewPebbleShardLogStorage(dir, 8)
+	s := NewPebbleShardLogStorage(dir, 8, nil)
 	defer s.Close()
 
 	err := s.Open()
@@ -68,7 +69,7 @@ func TestLeaderTermSequence(t *testing.T) {
 	trace.SetGlobalTrace(traceObj)
 
 	dir := t.TempDir()
-	s := NewPebbleShardLogStorage(dir, 8)
+	s := NewPebbleShardLogStorage(dir, 8, nil)
 	defer s.Close()
 	err := s.Open()
 	assert.Nil(t, err)
@@ -115,6 +116,15 @@ func TestLeaderTermSequence(t *testing.T) {
 		assert.Equal(t, term10, tm)
 	})
 
+	t.Run("LeaderTermStartIndexes", func(t *testing.T) {
+		indexes, err := s.LeaderTermStartIndexes(shardNo)
+		assert.Nil(t, err)
+		assert.Equal(t, []wkdb.LeaderTermStartIndex{
+			{Term: term1, StartIndex: index1},
+			{Term: term10, StartIndex: index100},
+		}, indexes)
+	})
+
 	t.Run("DeleteLeaderTermStartIndexGreaterThanTerm", func(t *testing.T) {
 		err := s.DeleteLeaderTermStartIndexGreaterThanTerm(shardNo, term1)
 		assert.Nil(t, err)
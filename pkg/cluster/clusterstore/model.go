@@ -2,6 +2,7 @@ package clusterstore
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
@@ -86,6 +87,37 @@ const (
 
 	// 批量添加最近会话
 	CMDAddOrUpdateConversations
+
+	// 添加或更新api key
+	CMDAddOrUpdateApiKey
+	// 移除api key
+	CMDRemoveApiKey
+
+	// 追加审计日志
+	CMDAppendAuditLogs
+
+	// 添加或更新管理端用户
+	CMDAddOrUpdateManagerUser
+	// 移除管理端用户
+	CMDRemoveManagerUser
+
+	// 更新订阅者的角色、禁言等属性
+	CMDUpdateSubscribers
+
+	// 记录订阅者的加入/离开
+	CMDRecordSubscriberHistories
+
+	// 对用户隐藏消息
+	CMDHideMessages
+	// 对用户清空消息
+	CMDClearMessages
+
+	// 保存端到端加密公钥包
+	CMDSaveE2eeKeyBundle
+	// 删除一次性预共享公钥
+	CMDRemoveE2eeOnetimePrekeys
+	// 删除端到端加密公钥包
+	CMDRemoveE2eeKeyBundle
 )
 
 func (c CMDType) Uint16() uint16 {
@@ -164,6 +196,30 @@ func (c CMDType) String() string {
 		return "CMDAddStreams"
 	case CMDAddOrUpdateConversations:
 		return "CMDAddOrUpdateConversations"
+	case CMDAddOrUpdateApiKey:
+		return "CMDAddOrUpdateApiKey"
+	case CMDRemoveApiKey:
+		return "CMDRemoveApiKey"
+	case CMDAppendAuditLogs:
+		return "CMDAppendAuditLogs"
+	case CMDAddOrUpdateManagerUser:
+		return "CMDAddOrUpdateManagerUser"
+	case CMDRemoveManagerUser:
+		return "CMDRemoveManagerUser"
+	case CMDUpdateSubscribers:
+		return "CMDUpdateSubscribers"
+	case CMDRecordSubscriberHistories:
+		return "CMDRecordSubscriberHistories"
+	case CMDHideMessages:
+		return "CMDHideMessages"
+	case CMDClearMessages:
+		return "CMDClearMessages"
+	case CMDSaveE2eeKeyBundle:
+		return "CMDSaveE2eeKeyBundle"
+	case CMDRemoveE2eeOnetimePrekeys:
+		return "CMDRemoveE2eeOnetimePrekeys"
+	case CMDRemoveE2eeKeyBundle:
+		return "CMDRemoveE2eeKeyBundle"
 	default:
 		return fmt.Sprintf("CMDUnknown[%d]", c)
 	}
@@ -192,7 +248,10 @@ func NewCMDWithVersion(cmdType CMDType, data []byte, version CmdVersion) *CMD {
 }
 
 func (c *CMD) Marshal() ([]byte, error) {
-	c.version = 1
+	// 未指定版本的命令默认为1，指定了版本的（如频道信息）需要保留，否则解码时会丢掉高版本字段
+	if c.version == 0 {
+		c.version = 1
+	}
 	enc := wkproto.NewEncoder()
 	defer enc.End()
 	enc.WriteUint16(c.version.Uint16())
@@ -454,6 +513,113 @@ func (c *CMD) CMDContent() (string, error) {
 		}
 		return wkutil.ToJSON(conversations), nil
 
+	case CMDAddOrUpdateApiKey:
+		apiKey, err := c.DecodeCMDAddOrUpdateApiKey()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(apiKey), nil
+
+	case CMDRemoveApiKey:
+		return c.DecodeCMDRemoveApiKey()
+
+	case CMDAppendAuditLogs:
+		logs, err := c.DecodeCMDAppendAuditLogs()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(logs), nil
+
+	case CMDAddOrUpdateManagerUser:
+		user, err := c.DecodeCMDAddOrUpdateManagerUser()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(user), nil
+
+	case CMDRemoveManagerUser:
+		return c.DecodeCMDRemoveManagerUser()
+
+	case CMDUpdateSubscribers:
+		channelId, channelType, members, err := c.DecodeMembers()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"channelId":   channelId,
+			"channelType": channelType,
+			"members":     members,
+		}), nil
+
+	case CMDRecordSubscriberHistories:
+		channelId, channelType, seq, joins, leaves, err := c.DecodeSubscriberHistories()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"channelId":   channelId,
+			"channelType": channelType,
+			"seq":         seq,
+			"joins":       joins,
+			"leaves":      leaves,
+		}), nil
+
+	case CMDHideMessages:
+		channelId, channelType, uid, messages, updatedAt, err := c.DecodeHideMessages()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"channelId":   channelId,
+			"channelType": channelType,
+			"uid":         uid,
+			"messages":    messages,
+			"updatedAt":   updatedAt,
+		}), nil
+
+	case CMDClearMessages:
+		channelId, channelType, uid, clearedToSeq, updatedAt, err := c.DecodeClearMessages()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"channelId":    channelId,
+			"channelType":  channelType,
+			"uid":          uid,
+			"clearedToSeq": clearedToSeq,
+			"updatedAt":    updatedAt,
+		}), nil
+
+	case CMDSaveE2eeKeyBundle:
+		bundle, onetimePrekeys, err := c.DecodeSaveE2eeKeyBundle()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"bundle":         bundle,
+			"onetimePrekeys": onetimePrekeys,
+		}), nil
+
+	case CMDRemoveE2eeOnetimePrekeys:
+		uid, deviceId, keyIds, err := c.DecodeRemoveE2eeOnetimePrekeys()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"uid":      uid,
+			"deviceId": deviceId,
+			"keyIds":   keyIds,
+		}), nil
+
+	case CMDRemoveE2eeKeyBundle:
+		uid, deviceId, err := c.DecodeRemoveE2eeKeyBundle()
+		if err != nil {
+			return "", err
+		}
+		return wkutil.ToJSON(map[string]interface{}{
+			"uid":      uid,
+			"deviceId": deviceId,
+		}), nil
 	}
 
 	return "", nil
@@ -477,6 +643,226 @@ func EncodeMembers(channelId string, channelType uint8, members []wkdb.Member) [
 	return encoder.Bytes()
 }
 
+func EncodeSubscriberHistories(channelId string, channelType uint8, seq uint64, joins []string, leaves []string) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(channelId)
+	encoder.WriteUint8(channelType)
+	encoder.WriteUint64(seq)
+	encoder.WriteUint32(uint32(len(joins)))
+	for _, uid := range joins {
+		encoder.WriteString(uid)
+	}
+	encoder.WriteUint32(uint32(len(leaves)))
+	for _, uid := range leaves {
+		encoder.WriteString(uid)
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeSubscriberHistories() (channelId string, channelType uint8, seq uint64, joins []string, leaves []string, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	if channelId, err = decoder.String(); err != nil {
+		return
+	}
+	if channelType, err = decoder.Uint8(); err != nil {
+		return
+	}
+	if seq, err = decoder.Uint64(); err != nil {
+		return
+	}
+	var count uint32
+	if count, err = decoder.Uint32(); err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		var uid string
+		if uid, err = decoder.String(); err != nil {
+			return
+		}
+		joins = append(joins, uid)
+	}
+	if count, err = decoder.Uint32(); err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		var uid string
+		if uid, err = decoder.String(); err != nil {
+			return
+		}
+		leaves = append(leaves, uid)
+	}
+	return
+}
+
+func EncodeHideMessages(channelId string, channelType uint8, uid string, messages []wkdb.HiddenMessage, updatedAt int64) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(channelId)
+	encoder.WriteUint8(channelType)
+	encoder.WriteString(uid)
+	encoder.WriteInt64(updatedAt)
+	encoder.WriteUint32(uint32(len(messages)))
+	for _, message := range messages {
+		encoder.WriteInt64(message.MessageId)
+		encoder.WriteUint64(message.MessageSeq)
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeHideMessages() (channelId string, channelType uint8, uid string, messages []wkdb.HiddenMessage, updatedAt int64, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	if channelId, err = decoder.String(); err != nil {
+		return
+	}
+	if channelType, err = decoder.Uint8(); err != nil {
+		return
+	}
+	if uid, err = decoder.String(); err != nil {
+		return
+	}
+	if updatedAt, err = decoder.Int64(); err != nil {
+		return
+	}
+	var count uint32
+	if count, err = decoder.Uint32(); err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		var message wkdb.HiddenMessage
+		if message.MessageId, err = decoder.Int64(); err != nil {
+			return
+		}
+		if message.MessageSeq, err = decoder.Uint64(); err != nil {
+			return
+		}
+		messages = append(messages, message)
+	}
+	return
+}
+
+func EncodeClearMessages(channelId string, channelType uint8, uid string, clearedToSeq uint64, updatedAt int64) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(channelId)
+	encoder.WriteUint8(channelType)
+	encoder.WriteString(uid)
+	encoder.WriteUint64(clearedToSeq)
+	encoder.WriteInt64(updatedAt)
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeClearMessages() (channelId string, channelType uint8, uid string, clearedToSeq uint64, updatedAt int64, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	if channelId, err = decoder.String(); err != nil {
+		return
+	}
+	if channelType, err = decoder.Uint8(); err != nil {
+		return
+	}
+	if uid, err = decoder.String(); err != nil {
+		return
+	}
+	if clearedToSeq, err = decoder.Uint64(); err != nil {
+		return
+	}
+	if updatedAt, err = decoder.Int64(); err != nil {
+		return
+	}
+	return
+}
+
+func EncodeSaveE2eeKeyBundle(bundle wkdb.E2eeKeyBundle, onetimePrekeys []wkdb.E2eePrekey) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteBinary(bundle.Encode())
+	encoder.WriteUint32(uint32(len(onetimePrekeys)))
+	for _, prekey := range onetimePrekeys {
+		encoder.WriteUint32(prekey.KeyId)
+		encoder.WriteBinary(prekey.PublicKey)
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeSaveE2eeKeyBundle() (bundle wkdb.E2eeKeyBundle, onetimePrekeys []wkdb.E2eePrekey, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	var bundleBytes []byte
+	if bundleBytes, err = decoder.Binary(); err != nil {
+		return
+	}
+	if err = bundle.Decode(bundleBytes); err != nil {
+		return
+	}
+	var count uint32
+	if count, err = decoder.Uint32(); err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		var prekey wkdb.E2eePrekey
+		if prekey.KeyId, err = decoder.Uint32(); err != nil {
+			return
+		}
+		if prekey.PublicKey, err = decoder.Binary(); err != nil {
+			return
+		}
+		onetimePrekeys = append(onetimePrekeys, prekey)
+	}
+	return
+}
+
+func EncodeRemoveE2eeOnetimePrekeys(uid string, deviceId string, keyIds []uint32) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(uid)
+	encoder.WriteString(deviceId)
+	encoder.WriteUint32(uint32(len(keyIds)))
+	for _, keyId := range keyIds {
+		encoder.WriteUint32(keyId)
+	}
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeRemoveE2eeOnetimePrekeys() (uid string, deviceId string, keyIds []uint32, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	if uid, err = decoder.String(); err != nil {
+		return
+	}
+	if deviceId, err = decoder.String(); err != nil {
+		return
+	}
+	var count uint32
+	if count, err = decoder.Uint32(); err != nil {
+		return
+	}
+	for i := uint32(0); i < count; i++ {
+		var keyId uint32
+		if keyId, err = decoder.Uint32(); err != nil {
+			return
+		}
+		keyIds = append(keyIds, keyId)
+	}
+	return
+}
+
+func EncodeRemoveE2eeKeyBundle(uid string, deviceId string) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(uid)
+	encoder.WriteString(deviceId)
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeRemoveE2eeKeyBundle() (uid string, deviceId string, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	if uid, err = decoder.String(); err != nil {
+		return
+	}
+	if deviceId, err = decoder.String(); err != nil {
+		return
+	}
+	return
+}
+
 func (c *CMD) DecodeChannelUids() (channelId string, channelType uint8, uids []string, err error) {
 	decoder := wkproto.NewDecoder(c.Data)
 	if channelId, err = decoder.String(); err != nil {
@@ -579,6 +965,13 @@ func EncodeCMDUser(u wkdb.User) []byte {
 	} else {
 		enc.WriteUint64(0)
 	}
+
+	if u.LastSeen != nil {
+		enc.WriteUint64(uint64(u.LastSeen.UnixNano()))
+	} else {
+		enc.WriteUint64(0)
+	}
+	enc.WriteUint8(u.LastSeenPrivacy)
 	return enc.Bytes()
 }
 
@@ -609,6 +1002,24 @@ func (c *CMD) DecodeCMDUser() (u wkdb.User, err error) {
 		u.UpdatedAt = &ct
 	}
 
+	// 兼容旧版本的数据（旧版本没有最后在线时间和可见范围）
+	if decoder.Len() == 0 {
+		return
+	}
+
+	var lastSeenUnixNano uint64
+	if lastSeenUnixNano, err = decoder.Uint64(); err != nil {
+		return
+	}
+	if lastSeenUnixNano > 0 {
+		ct := time.Unix(int64(lastSeenUnixNano/1e9), int64(lastSeenUnixNano%1e9))
+		u.LastSeen = &ct
+	}
+
+	if u.LastSeenPrivacy, err = decoder.Uint8(); err != nil {
+		return
+	}
+
 	return
 }
 
@@ -633,6 +1044,13 @@ func EncodeCMDDevice(d wkdb.Device) []byte {
 		enc.WriteUint64(0)
 	}
 
+	if d.TokenExpireAt != nil {
+		enc.WriteUint64(uint64(d.TokenExpireAt.UnixNano()))
+	} else {
+		enc.WriteUint64(0)
+	}
+	enc.WriteString(strings.Join(d.Scopes, ","))
+
 	return enc.Bytes()
 }
 
@@ -675,6 +1093,28 @@ func (c *CMD) DecodeCMDDevice() (d wkdb.Device, err error) {
 		d.UpdatedAt = &ct
 	}
 
+	// 兼容旧版本的数据（旧版本没有token过期时间和授权范围）
+	if decoder.Len() == 0 {
+		return
+	}
+
+	var tokenExpireAtUnixNano uint64
+	if tokenExpireAtUnixNano, err = decoder.Uint64(); err != nil {
+		return
+	}
+	if tokenExpireAtUnixNano > 0 {
+		ct := time.Unix(int64(tokenExpireAtUnixNano/1e9), int64(tokenExpireAtUnixNano%1e9))
+		d.TokenExpireAt = &ct
+	}
+
+	var scopes string
+	if scopes, err = decoder.String(); err != nil {
+		return
+	}
+	if scopes != "" {
+		d.Scopes = strings.Split(scopes, ",")
+	}
+
 	return
 }
 
@@ -789,6 +1229,21 @@ func EncodeChannelInfo(c wkdb.ChannelInfo, version CmdVersion) ([]byte, error) {
 	if version > 0 {
 		enc.WriteString(c.Webhook)
 	}
+	if version > 2 {
+		enc.WriteUint8(uint8(c.SendMode))
+	}
+	if version > 3 {
+		enc.WriteUint8(uint8(c.HistoryVisibility))
+		enc.WriteUint32(c.HistoryVisibleCount)
+	}
+	if version > 4 {
+		enc.WriteUint8(wkutil.BoolToUint8(c.E2ee))
+	}
+	if version > 5 {
+		enc.WriteInt64(c.RetentionMaxAge)
+		enc.WriteInt64(c.RetentionMaxCount)
+		enc.WriteInt64(c.RetentionMaxBytes)
+	}
 	return enc.Bytes(), nil
 }
 
@@ -843,6 +1298,41 @@ func (c *CMD) DecodeChannelInfo() (wkdb.ChannelInfo, error) {
 			return channelInfo, err
 		}
 	}
+	if c.version > 2 {
+		var sendMode uint8
+		if sendMode, err = dec.Uint8(); err != nil {
+			return channelInfo, err
+		}
+		channelInfo.SendMode = wkdb.SendMode(sendMode)
+	}
+	if c.version > 3 {
+		var historyVisibility uint8
+		if historyVisibility, err = dec.Uint8(); err != nil {
+			return channelInfo, err
+		}
+		channelInfo.HistoryVisibility = wkdb.HistoryVisibility(historyVisibility)
+		if channelInfo.HistoryVisibleCount, err = dec.Uint32(); err != nil {
+			return channelInfo, err
+		}
+	}
+	if c.version > 4 {
+		var e2ee uint8
+		if e2ee, err = dec.Uint8(); err != nil {
+			return channelInfo, err
+		}
+		channelInfo.E2ee = wkutil.Uint8ToBool(e2ee)
+	}
+	if c.version > 5 {
+		if channelInfo.RetentionMaxAge, err = dec.Int64(); err != nil {
+			return channelInfo, err
+		}
+		if channelInfo.RetentionMaxCount, err = dec.Int64(); err != nil {
+			return channelInfo, err
+		}
+		if channelInfo.RetentionMaxBytes, err = dec.Int64(); err != nil {
+			return channelInfo, err
+		}
+	}
 
 	return channelInfo, err
 }
@@ -1123,6 +1613,59 @@ func (c *CMD) DecodeCMDSystemUIDs() (uids []string, err error) {
 	return
 }
 
+func EncodeCMDAddOrUpdateApiKey(apiKey wkdb.ApiKey) ([]byte, error) {
+	return apiKey.Marshal()
+}
+
+func (c *CMD) DecodeCMDAddOrUpdateApiKey() (apiKey wkdb.ApiKey, err error) {
+	err = apiKey.Unmarshal(c.Data)
+	return
+}
+
+func EncodeCMDRemoveApiKey(name string) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(name)
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDRemoveApiKey() (name string, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	name, err = decoder.String()
+	return
+}
+
+func EncodeCMDAddOrUpdateManagerUser(user wkdb.ManagerUser) ([]byte, error) {
+	return user.Marshal()
+}
+
+func (c *CMD) DecodeCMDAddOrUpdateManagerUser() (user wkdb.ManagerUser, err error) {
+	err = user.Unmarshal(c.Data)
+	return
+}
+
+func EncodeCMDRemoveManagerUser(username string) []byte {
+	encoder := wkproto.NewEncoder()
+	defer encoder.End()
+	encoder.WriteString(username)
+	return encoder.Bytes()
+}
+
+func (c *CMD) DecodeCMDRemoveManagerUser() (username string, err error) {
+	decoder := wkproto.NewDecoder(c.Data)
+	username, err = decoder.String()
+	return
+}
+
+func EncodeCMDAppendAuditLogs(logs wkdb.AuditLogs) ([]byte, error) {
+	return logs.Marshal()
+}
+
+func (c *CMD) DecodeCMDAppendAuditLogs() (logs wkdb.AuditLogs, err error) {
+	err = logs.Unmarshal(c.Data)
+	return
+}
+
 func EncodeCMDAddStreamMeta(streamMeta *wkdb.StreamMeta) []byte {
 	return streamMeta.Encode()
 }
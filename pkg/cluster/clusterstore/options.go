@@ -2,6 +2,8 @@ package clusterstore
 
 import (
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 )
 
 type Options struct {
@@ -15,9 +17,14 @@ type Options struct {
 	IsCmdChannel func(string) bool // 是否是cmd频道
 
 	Db struct {
-		ShardNum     int // 分片数量
-		MemTableSize int // MemTable大小
+		ShardNum              int            // 分片数量
+		MemTableSize          int            // MemTable大小
+		ColdStore             wkdb.BlobStore // 冷存储 为nil表示不开启
+		ColdSegmentMaxCount   int            // 冷存储每个消息段的最大消息数量
+		ColdSegmentCacheCount int            // 内存中缓存的冷存储消息段数量
 	}
+
+	EncryptFS *encryption.FS // 静态数据加密的文件系统 为nil表示不加密
 }
 
 func NewOptions(nodeID uint64, opts ...Option) *Options {
@@ -33,11 +40,16 @@ func newOptions() *Options {
 	return &Options{
 		SlotCount: 64,
 		Db: struct {
-			ShardNum     int
-			MemTableSize int
+			ShardNum              int
+			MemTableSize          int
+			ColdStore             wkdb.BlobStore
+			ColdSegmentMaxCount   int
+			ColdSegmentCacheCount int
 		}{
-			ShardNum:     8,
-			MemTableSize: 16 * 1024 * 1024,
+			ShardNum:              8,
+			MemTableSize:          16 * 1024 * 1024,
+			ColdSegmentMaxCount:   1000,
+			ColdSegmentCacheCount: 64,
 		},
 	}
 }
@@ -85,3 +97,15 @@ func WithDbMemTableSize(size int) Option {
 		o.Db.MemTableSize = size
 	}
 }
+
+func WithDbColdStore(store wkdb.BlobStore) Option {
+	return func(o *Options) {
+		o.Db.ColdStore = store
+	}
+}
+
+func WithEncryptFS(fs *encryption.FS) Option {
+	return func(o *Options) {
+		o.EncryptFS = fs
+	}
+}
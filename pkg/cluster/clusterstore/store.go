@@ -50,6 +50,10 @@ func NewStore(opts *Options) *Store {
 			wkdb.WithNodeId(opts.NodeID),
 			wkdb.WithMemTableSize(opts.Db.MemTableSize),
 			wkdb.WithSlotCount(int(opts.SlotCount)),
+			wkdb.WithColdStore(opts.Db.ColdStore),
+			wkdb.WithColdSegmentMaxCount(opts.Db.ColdSegmentMaxCount),
+			wkdb.WithColdSegmentCacheCount(opts.Db.ColdSegmentCacheCount),
+			wkdb.WithEncryptFS(opts.EncryptFS),
 		),
 	)
 
@@ -131,6 +135,98 @@ func (s *Store) RemoveSystemUids(uids []string) error {
 	return err
 }
 
+// ApiKeySlotId api key存储的槽位（与系统uid一样默认存储在slot 0上）
+const ApiKeySlotId uint32 = 0
+
+func (s *Store) GetApiKeys() ([]wkdb.ApiKey, error) {
+	return s.wdb.GetApiKeys()
+}
+
+func (s *Store) GetApiKey(name string) (wkdb.ApiKey, error) {
+	return s.wdb.GetApiKey(name)
+}
+
+func (s *Store) AddOrUpdateApiKey(apiKey wkdb.ApiKey) error {
+	data, err := EncodeCMDAddOrUpdateApiKey(apiKey)
+	if err != nil {
+		return err
+	}
+	cmd := NewCMD(CMDAddOrUpdateApiKey, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, ApiKeySlotId, cmdData)
+	return err
+}
+
+func (s *Store) RemoveApiKey(name string) error {
+	cmd := NewCMD(CMDRemoveApiKey, EncodeCMDRemoveApiKey(name))
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, ApiKeySlotId, cmdData)
+	return err
+}
+
+// ManagerUserSlotId 管理端用户存储的槽位（默认存储在slot 0上）
+const ManagerUserSlotId uint32 = 0
+
+func (s *Store) GetManagerUsers() ([]wkdb.ManagerUser, error) {
+	return s.wdb.GetManagerUsers()
+}
+
+func (s *Store) GetManagerUser(username string) (wkdb.ManagerUser, error) {
+	return s.wdb.GetManagerUser(username)
+}
+
+func (s *Store) AddOrUpdateManagerUser(user wkdb.ManagerUser) error {
+	data, err := EncodeCMDAddOrUpdateManagerUser(user)
+	if err != nil {
+		return err
+	}
+	cmd := NewCMD(CMDAddOrUpdateManagerUser, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, ManagerUserSlotId, cmdData)
+	return err
+}
+
+func (s *Store) RemoveManagerUser(username string) error {
+	cmd := NewCMD(CMDRemoveManagerUser, EncodeCMDRemoveManagerUser(username))
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, ManagerUserSlotId, cmdData)
+	return err
+}
+
+// AuditLogSlotId 审计日志存储的槽位（默认存储在slot 0上）
+const AuditLogSlotId uint32 = 0
+
+// AppendAuditLogs 追加审计日志（通过slot 0的分布式日志复制到各个副本）
+func (s *Store) AppendAuditLogs(logs []wkdb.AuditLog) error {
+	data, err := EncodeCMDAppendAuditLogs(logs)
+	if err != nil {
+		return err
+	}
+	cmd := NewCMD(CMDAppendAuditLogs, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, AuditLogSlotId, cmdData)
+	return err
+}
+
+func (s *Store) SearchAuditLogs(req wkdb.AuditLogSearchReq) ([]wkdb.AuditLog, error) {
+	return s.wdb.SearchAuditLogs(req)
+}
+
 func (s *Store) GetIPBlacklist() ([]string, error) {
 	// return s.db.GetIPBlacklist()
 	return nil, nil
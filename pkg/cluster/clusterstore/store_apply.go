@@ -161,6 +161,30 @@ func (s *Store) execCMD(cmd *CMD) error {
 		return s.handleSystemUIDsAdd(cmd)
 	case CMDSystemUIDsRemove: // 移除系统UID
 		return s.handleSystemUIDsRemove(cmd)
+	case CMDAddOrUpdateApiKey: // 添加或更新api key
+		return s.handleAddOrUpdateApiKey(cmd)
+	case CMDRemoveApiKey: // 移除api key
+		return s.handleRemoveApiKey(cmd)
+	case CMDAppendAuditLogs: // 追加审计日志
+		return s.handleAppendAuditLogs(cmd)
+	case CMDAddOrUpdateManagerUser: // 添加或更新管理端用户
+		return s.handleAddOrUpdateManagerUser(cmd)
+	case CMDRemoveManagerUser: // 移除管理端用户
+		return s.handleRemoveManagerUser(cmd)
+	case CMDUpdateSubscribers: // 更新订阅者属性
+		return s.handleUpdateSubscribers(cmd)
+	case CMDRecordSubscriberHistories: // 记录订阅者的加入/离开
+		return s.handleRecordSubscriberHistories(cmd)
+	case CMDHideMessages: // 对用户隐藏消息
+		return s.handleHideMessages(cmd)
+	case CMDClearMessages: // 对用户清空消息
+		return s.handleClearMessages(cmd)
+	case CMDSaveE2eeKeyBundle: // 保存端到端加密公钥包
+		return s.handleSaveE2eeKeyBundle(cmd)
+	case CMDRemoveE2eeOnetimePrekeys: // 删除一次性预共享公钥
+		return s.handleRemoveE2eeOnetimePrekeys(cmd)
+	case CMDRemoveE2eeKeyBundle: // 删除端到端加密公钥包
+		return s.handleRemoveE2eeKeyBundle(cmd)
 	case CMDAddStreamMeta: // 添加流元数据
 		return s.handleAddStreamMeta(cmd)
 	case CMDAddStreams: // 添加流
@@ -179,6 +203,69 @@ func (s *Store) handleAddSubscribers(cmd *CMD) error {
 	return s.wdb.AddSubscribers(channelId, channelType, members)
 }
 
+func (s *Store) handleUpdateSubscribers(cmd *CMD) error {
+	channelId, channelType, members, err := cmd.DecodeMembers()
+	if err != nil {
+		s.Error("decode update subscribers err", zap.Error(err), zap.String("channelID", channelId), zap.Uint8("channelType", channelType))
+		return err
+	}
+	return s.wdb.UpdateSubscribers(channelId, channelType, members)
+}
+
+func (s *Store) handleRecordSubscriberHistories(cmd *CMD) error {
+	channelId, channelType, seq, joins, leaves, err := cmd.DecodeSubscriberHistories()
+	if err != nil {
+		s.Error("decode subscriber histories err", zap.Error(err), zap.String("channelID", channelId), zap.Uint8("channelType", channelType))
+		return err
+	}
+	return s.wdb.RecordSubscriberHistories(channelId, channelType, seq, joins, leaves)
+}
+
+func (s *Store) handleHideMessages(cmd *CMD) error {
+	channelId, channelType, uid, messages, updatedAt, err := cmd.DecodeHideMessages()
+	if err != nil {
+		s.Error("decode hide messages err", zap.Error(err), zap.String("channelID", channelId), zap.Uint8("channelType", channelType))
+		return err
+	}
+	return s.wdb.HideMessages(channelId, channelType, uid, messages, updatedAt)
+}
+
+func (s *Store) handleClearMessages(cmd *CMD) error {
+	channelId, channelType, uid, clearedToSeq, updatedAt, err := cmd.DecodeClearMessages()
+	if err != nil {
+		s.Error("decode clear messages err", zap.Error(err), zap.String("channelID", channelId), zap.Uint8("channelType", channelType))
+		return err
+	}
+	return s.wdb.ClearMessages(channelId, channelType, uid, clearedToSeq, updatedAt)
+}
+
+func (s *Store) handleSaveE2eeKeyBundle(cmd *CMD) error {
+	bundle, onetimePrekeys, err := cmd.DecodeSaveE2eeKeyBundle()
+	if err != nil {
+		s.Error("decode save e2ee key bundle err", zap.Error(err))
+		return err
+	}
+	return s.wdb.SaveE2eeKeyBundle(bundle, onetimePrekeys)
+}
+
+func (s *Store) handleRemoveE2eeOnetimePrekeys(cmd *CMD) error {
+	uid, deviceId, keyIds, err := cmd.DecodeRemoveE2eeOnetimePrekeys()
+	if err != nil {
+		s.Error("decode remove e2ee onetime prekeys err", zap.Error(err))
+		return err
+	}
+	return s.wdb.RemoveE2eeOnetimePrekeys(uid, deviceId, keyIds)
+}
+
+func (s *Store) handleRemoveE2eeKeyBundle(cmd *CMD) error {
+	uid, deviceId, err := cmd.DecodeRemoveE2eeKeyBundle()
+	if err != nil {
+		s.Error("decode remove e2ee key bundle err", zap.Error(err))
+		return err
+	}
+	return s.wdb.RemoveE2eeKeyBundle(uid, deviceId)
+}
+
 func (s *Store) handleRemoveSubscribers(cmd *CMD) error {
 	channelId, channelType, subscribers, err := cmd.DecodeChannelUids()
 	if err != nil {
@@ -396,6 +483,46 @@ func (s *Store) handleSystemUIDsRemove(cmd *CMD) error {
 	return s.wdb.RemoveSystemUids(uids)
 }
 
+func (s *Store) handleAddOrUpdateApiKey(cmd *CMD) error {
+	apiKey, err := cmd.DecodeCMDAddOrUpdateApiKey()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AddOrUpdateApiKey(apiKey)
+}
+
+func (s *Store) handleRemoveApiKey(cmd *CMD) error {
+	name, err := cmd.DecodeCMDRemoveApiKey()
+	if err != nil {
+		return err
+	}
+	return s.wdb.RemoveApiKey(name)
+}
+
+func (s *Store) handleAddOrUpdateManagerUser(cmd *CMD) error {
+	user, err := cmd.DecodeCMDAddOrUpdateManagerUser()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AddOrUpdateManagerUser(user)
+}
+
+func (s *Store) handleRemoveManagerUser(cmd *CMD) error {
+	username, err := cmd.DecodeCMDRemoveManagerUser()
+	if err != nil {
+		return err
+	}
+	return s.wdb.RemoveManagerUser(username)
+}
+
+func (s *Store) handleAppendAuditLogs(cmd *CMD) error {
+	logs, err := cmd.DecodeCMDAppendAuditLogs()
+	if err != nil {
+		return err
+	}
+	return s.wdb.AppendAuditLogs(logs)
+}
+
 func (s *Store) handleAddStreamMeta(cmd *CMD) error {
 	streamMeta, err := cmd.DecodeCMDAddStreamMeta()
 	if err != nil {
@@ -26,6 +26,48 @@ func (s *Store) ExistSubscriber(channelId string, channelType uint8, uid string)
 	return s.wdb.ExistSubscriber(channelId, channelType, uid)
 }
 
+// GetSubscriber 获取单个订阅者
+func (s *Store) GetSubscriber(channelId string, channelType uint8, uid string) (wkdb.Member, error) {
+	return s.wdb.GetSubscriber(channelId, channelType, uid)
+}
+
+// UpdateSubscribers 更新订阅者的角色、禁言、加入来源和自定义属性
+func (s *Store) UpdateSubscribers(channelId string, channelType uint8, members []wkdb.Member) error {
+	if len(members) == 0 {
+		return nil
+	}
+	data := EncodeMembers(channelId, channelType, members)
+	cmd := NewCMD(CMDUpdateSubscribers, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	slotId := s.opts.GetSlotId(channelId)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+// RecordSubscriberHistories 记录订阅者在消息序号seq时的加入/离开（在槽应用日志时合并，重新加入会追加新的范围）
+func (s *Store) RecordSubscriberHistories(channelId string, channelType uint8, seq uint64, joins []string, leaves []string) error {
+	if len(joins) == 0 && len(leaves) == 0 {
+		return nil
+	}
+	data := EncodeSubscriberHistories(channelId, channelType, seq, joins, leaves)
+	cmd := NewCMD(CMDRecordSubscriberHistories, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	slotId := s.opts.GetSlotId(channelId)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+// GetSubscriberHistory 获取订阅者的加入/离开记录
+func (s *Store) GetSubscriberHistory(channelId string, channelType uint8, uid string) (wkdb.SubscriberHistory, error) {
+	return s.wdb.GetSubscriberHistory(channelId, channelType, uid)
+}
+
 // RemoveSubscribers 移除订阅者
 func (s *Store) RemoveSubscribers(channelId string, channelType uint8, subscribers []string) error {
 
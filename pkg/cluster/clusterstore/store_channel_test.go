@@ -1,5 +1,48 @@
 package clusterstore_test
 
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/replica"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyChannelInfoKeepVersion(t *testing.T) {
+	s := newTestStore(t)
+
+	channelInfo := wkdb.ChannelInfo{
+		ChannelId:           "g1",
+		ChannelType:         2,
+		SendMode:            wkdb.SendModeAdmin,
+		HistoryVisibility:   wkdb.HistoryVisibilityLastN,
+		HistoryVisibleCount: 20,
+		E2ee:                true,
+		RetentionMaxAge:     3600,
+		RetentionMaxCount:   100,
+		RetentionMaxBytes:   1024,
+	}
+	data, err := clusterstore.EncodeChannelInfo(channelInfo, clusterstore.CmdVersionChannelInfo)
+	assert.NoError(t, err)
+	cmdData, err := clusterstore.NewCMDWithVersion(clusterstore.CMDAddChannelInfo, data, clusterstore.CmdVersionChannelInfo).Marshal()
+	assert.NoError(t, err)
+
+	// 模拟raft日志应用
+	err = s.OnMetaApply(1, []replica.Log{{Index: 1, Term: 1, Data: cmdData}})
+	assert.NoError(t, err)
+
+	info, err := s.GetChannel("g1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, channelInfo.SendMode, info.SendMode)
+	assert.Equal(t, channelInfo.HistoryVisibility, info.HistoryVisibility)
+	assert.Equal(t, channelInfo.HistoryVisibleCount, info.HistoryVisibleCount)
+	assert.Equal(t, channelInfo.E2ee, info.E2ee)
+	assert.Equal(t, channelInfo.RetentionMaxAge, info.RetentionMaxAge)
+	assert.Equal(t, channelInfo.RetentionMaxCount, info.RetentionMaxCount)
+	assert.Equal(t, channelInfo.RetentionMaxBytes, info.RetentionMaxBytes)
+}
+
 // func TestAddSubscribers(t *testing.T) {
 // 	s1, t1, s2, t2, s3, t3 := newTestClusterServerGroupThree()
 // 	defer s1.Close()
@@ -0,0 +1,67 @@
+package clusterstore
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"go.uber.org/zap"
+)
+
+// SaveE2eeKeyBundle 保存用户设备的端到端加密公钥包，并追加一次性预共享公钥
+func (s *Store) SaveE2eeKeyBundle(bundle wkdb.E2eeKeyBundle, onetimePrekeys []wkdb.E2eePrekey) error {
+	data := EncodeSaveE2eeKeyBundle(bundle, onetimePrekeys)
+	cmd := NewCMD(CMDSaveE2eeKeyBundle, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("marshal cmd failed", zap.Error(err))
+		return err
+	}
+	slotId := s.opts.GetSlotId(bundle.Uid)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+// RemoveE2eeOnetimePrekeys 删除用户设备的一次性预共享公钥
+func (s *Store) RemoveE2eeOnetimePrekeys(uid string, deviceId string, keyIds []uint32) error {
+	if len(keyIds) == 0 {
+		return nil
+	}
+	data := EncodeRemoveE2eeOnetimePrekeys(uid, deviceId, keyIds)
+	cmd := NewCMD(CMDRemoveE2eeOnetimePrekeys, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("marshal cmd failed", zap.Error(err))
+		return err
+	}
+	slotId := s.opts.GetSlotId(uid)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+// RemoveE2eeKeyBundle 删除用户设备的公钥包以及一次性预共享公钥
+func (s *Store) RemoveE2eeKeyBundle(uid string, deviceId string) error {
+	data := EncodeRemoveE2eeKeyBundle(uid, deviceId)
+	cmd := NewCMD(CMDRemoveE2eeKeyBundle, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		s.Error("marshal cmd failed", zap.Error(err))
+		return err
+	}
+	slotId := s.opts.GetSlotId(uid)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+func (s *Store) GetE2eeKeyBundle(uid string, deviceId string) (wkdb.E2eeKeyBundle, error) {
+	return s.wdb.GetE2eeKeyBundle(uid, deviceId)
+}
+
+func (s *Store) GetE2eeKeyBundles(uid string) ([]wkdb.E2eeKeyBundle, error) {
+	return s.wdb.GetE2eeKeyBundles(uid)
+}
+
+func (s *Store) GetE2eeOnetimePrekeys(uid string, deviceId string, limit int) ([]wkdb.E2eePrekey, error) {
+	return s.wdb.GetE2eeOnetimePrekeys(uid, deviceId, limit)
+}
+
+func (s *Store) GetE2eeOnetimePrekeyCount(uid string, deviceId string) (int, error) {
+	return s.wdb.GetE2eeOnetimePrekeyCount(uid, deviceId)
+}
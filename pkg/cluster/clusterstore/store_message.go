@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"strings"
+	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/icluster"
 	"github.com/WuKongIM/WuKongIM/pkg/cluster/reactor"
@@ -410,3 +411,77 @@ func IsUserOwnChannel(channelId string, channelType uint8) bool {
 	}
 	return false
 }
+
+// HideMessages 对用户隐藏频道内的消息（仅对自己删除）
+func (s *Store) HideMessages(channelId string, channelType uint8, uid string, messages []wkdb.HiddenMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	data := EncodeHideMessages(channelId, channelType, uid, messages, time.Now().UnixNano())
+	cmd := NewCMD(CMDHideMessages, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	slotId := s.opts.GetSlotId(channelId)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+// ClearMessages 对用户清空频道内小于等于clearedToSeq的消息
+func (s *Store) ClearMessages(channelId string, channelType uint8, uid string, clearedToSeq uint64) error {
+	data := EncodeClearMessages(channelId, channelType, uid, clearedToSeq, time.Now().UnixNano())
+	cmd := NewCMD(CMDClearMessages, data)
+	cmdData, err := cmd.Marshal()
+	if err != nil {
+		return err
+	}
+	slotId := s.opts.GetSlotId(channelId)
+	_, err = s.opts.Cluster.ProposeDataToSlot(s.ctx, slotId, cmdData)
+	return err
+}
+
+// GetMessageVisibility 获取用户在频道内的消息可见性
+func (s *Store) GetMessageVisibility(channelId string, channelType uint8, uid string) (wkdb.MessageVisibility, error) {
+	return s.wdb.GetMessageVisibility(channelId, channelType, uid)
+}
+
+// GetHiddenMessageIds 获取用户在频道内消息序号范围 [startSeq,endSeq] 内隐藏的消息ID
+func (s *Store) GetHiddenMessageIds(channelId string, channelType uint8, uid string, startSeq, endSeq uint64) ([]int64, error) {
+	return s.wdb.GetHiddenMessageIds(channelId, channelType, uid, startSeq, endSeq)
+}
+
+// IterateMessageChannels 遍历本节点有消息的频道
+func (s *Store) IterateMessageChannels(iterFnc func(channelId string, channelType uint8, lastMsgSeq uint64) bool) error {
+	return s.wdb.IterateMessageChannels(iterFnc)
+}
+
+// GetChannelFirstMessageSeq 获取频道第一条消息的seq
+func (s *Store) GetChannelFirstMessageSeq(channelId string, channelType uint8) (uint64, error) {
+	return s.wdb.GetChannelFirstMessageSeq(channelId, channelType)
+}
+
+// GetMessageSeqBeforeTimestamp 获取消息时间小于timestamp的最后一条消息的seq
+func (s *Store) GetMessageSeqBeforeTimestamp(channelId string, channelType uint8, timestamp int64) (uint64, error) {
+	return s.wdb.GetMessageSeqBeforeTimestamp(channelId, channelType, timestamp)
+}
+
+// GetMessageSeqExceedSize 获取超出保留大小的消息seq
+func (s *Store) GetMessageSeqExceedSize(channelId string, channelType uint8, maxBytes uint64) (uint64, error) {
+	return s.wdb.GetMessageSeqExceedSize(channelId, channelType, maxBytes)
+}
+
+// PurgeMessagesTo 清理频道messageSeq（包含）之前的消息（消息保留策略，每个副本各自清理）
+func (s *Store) PurgeMessagesTo(channelId string, channelType uint8, messageSeq uint64, limit int) (uint64, error) {
+	return s.wdb.PurgeMessagesTo(channelId, channelType, messageSeq, limit)
+}
+
+// OffloadMessagesTo 转存频道messageSeq（包含）之前的消息到冷存储（每个副本各自转存）
+func (s *Store) OffloadMessagesTo(channelId string, channelType uint8, messageSeq uint64) (uint64, error) {
+	return s.wdb.OffloadMessagesTo(channelId, channelType, messageSeq)
+}
+
+// GetMessageColdSegments 获取频道冷存储的消息段
+func (s *Store) GetMessageColdSegments(channelId string, channelType uint8) ([]wkdb.MessageColdSegment, error) {
+	return s.wdb.GetMessageColdSegments(channelId, channelType)
+}
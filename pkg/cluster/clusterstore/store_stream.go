@@ -34,3 +34,7 @@ func (s *Store) AddStreams(channelId string, channelType uint8, streams []*wkdb.
 func (s *Store) GetStreams(streamNo string) ([]*wkdb.Stream, error) {
 	return s.wdb.GetStreams(streamNo)
 }
+
+func (s *Store) GetStreamLastId(streamNo string) (uint64, error) {
+	return s.wdb.GetStreamLastId(streamNo)
+}
@@ -1,5 +1,33 @@
 package clusterstore_test
 
+import (
+	"context"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/cluster/clusterstore"
+	"github.com/WuKongIM/WuKongIM/pkg/trace"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStore(t testing.TB) *clusterstore.Store {
+	traceObj := trace.New(
+		context.Background(),
+		trace.NewOptions(
+			trace.WithServiceName("test"),
+			trace.WithServiceHostName("host"),
+		))
+	trace.SetGlobalTrace(traceObj)
+
+	opts := clusterstore.NewOptions(1)
+	opts.DataDir = t.TempDir()
+	opts.Db.ShardNum = 1
+	s := clusterstore.NewStore(opts)
+	err := s.Open()
+	assert.NoError(t, err)
+	t.Cleanup(s.Close)
+	return s
+}
+
 // func newTestClusterServerGroupThree() (*clusterstore.Store, *testClusterServer, *clusterstore.Store, *testClusterServer, *clusterstore.Store, *testClusterServer) {
 // 	initNodes := map[uint64]string{
 // 		1: "127.0.0.1:10001",
@@ -4,7 +4,11 @@ type CmdVersion uint16
 
 const (
 	// CmdVersionChannelInfo is the version of the command that contains channel info
-	CmdVersionChannelInfo CmdVersion = 2
+	// 版本3增加了发言模式
+	// 版本4增加了历史消息可见性
+	// 版本5增加了端到端加密
+	// 版本6增加了消息保留策略
+	CmdVersionChannelInfo CmdVersion = 6
 )
 
 func (c CmdVersion) Uint16() uint16 {
@@ -21,6 +21,12 @@ type Cluster interface {
 	LeaderOfChannel(ctx context.Context, channelId string, channelType uint8) (nodeInfo *pb.Node, err error)
 	// SlotLeaderIdOfChannel 获取channel的leader节点信息(不激活频道)
 	LeaderOfChannelForRead(channelId string, channelType uint8) (nodeInfo *pb.Node, err error)
+	// ReplicasOfChannel 获取频道的领导节点ID和副本节点ID（不激活频道）
+	ReplicasOfChannel(channelId string, channelType uint8) (leaderId uint64, replicas []uint64, err error)
+	// WaitLocalReadableOfChannel 等待本节点的频道副本可以提供线性一致性读（返回false表示需要由领导处理）
+	WaitLocalReadableOfChannel(ctx context.Context, channelId string, channelType uint8) (bool, error)
+	// NextMessageSeqOfChannel 获取频道下一条消息的序号（由频道领导按日志顺序分配）
+	NextMessageSeqOfChannel(ctx context.Context, channelId string, channelType uint8) (uint64, error)
 	// SlotLeaderIdOfChannel 获取频道所属槽的领导
 	SlotLeaderIdOfChannel(channelId string, channelType uint8) (nodeId uint64, err error)
 	// SlotLeaderOfChannel 获取频道所属槽的领导
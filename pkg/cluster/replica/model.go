@@ -580,9 +580,29 @@ var (
 	ErrProposalDropped              = errors.New("replica proposal dropped")
 	ErrLeaderTermStartIndexNotFound = errors.New("leader term start index not found")
 	ErrCompacted                    = errors.New("log compacted")
+	ErrNotLeader                    = errors.New("replica not leader")
+	ErrLeaseExpired                 = errors.New("replica leader lease expired")
+	ErrReadIndexNotReady            = errors.New("replica read index not ready")
 )
 
 type SyncInfo struct {
 	LastSyncIndex uint64 //最后一次来同步日志的下标（最新日志 + 1）
 	SyncTick      int    // 同步计时器
+	ElapsedTick   int    // 距离最后一次来同步日志过去的tick数（用于计算领导的读租约）
+}
+
+// ReadState 副本的读状态，用于线性一致性读
+type ReadState struct {
+	Role           Role   // 副本角色
+	Leader         uint64 // 领导者id
+	Term           uint32 // 当前任期
+	CommittedIndex uint64 // 已提交的日志下标
+	AppliedIndex   uint64 // 已应用的日志下标
+	ReadIndex      uint64 // 读下标（仅领导有效）
+	ReadIndexErr   error  // 获取读下标的错误，不为nil表示当前不能提供读下标
+}
+
+// Readable 已应用的日志是否追上了读下标
+func (r ReadState) Readable(readIndex uint64) bool {
+	return r.AppliedIndex >= readIndex
 }
@@ -27,6 +27,8 @@ type Options struct {
 
 	RequestTimeoutTick int // 请求超时tick数
 
+	ReadLeaseTick int // 领导的读租约tick数，在这个tick数内有过半的副本来同步过日志，领导才能提供读下标（必须小于ElectionIntervalTick，否则会被调整为ElectionIntervalTick的一半）
+
 	OnConfigChange func(oldCfg, newCfg Config) // 配置变更回调
 }
 
@@ -45,6 +47,7 @@ func NewOptions() *Options {
 		FollowerToLeaderMinLogGap:  100,
 		LearnerToTimeoutTick:       10,
 		RequestTimeoutTick:         10,
+		ReadLeaseTick:              5,
 	}
 }
 
@@ -171,6 +174,12 @@ func WithFollowerToLeaderMinLogGap(gap uint64) Option {
 	}
 }
 
+func WithReadLeaseTick(tick int) Option {
+	return func(o *Options) {
+		o.ReadLeaseTick = tick
+	}
+}
+
 func WithRequestTimeoutTick(tick int) Option {
 	return func(o *Options) {
 		o.RequestTimeoutTick = tick
@@ -31,6 +31,8 @@ type Replica struct {
 	roleTransitioningTimeoutTick int  // 角色转换超时计时器
 
 	replicas []uint64 // 副本节点ID集合（不包含本节点）
+
+	termStartIndex uint64 // 成为领导时的最新日志下标，领导提交到此下标后才能提供读下标
 	// -------------------- 节点状态 --------------------
 	leader uint64 // 领导者id
 	role   Role   // 副本角色
@@ -57,6 +59,13 @@ func New(nodeId uint64, optList ...Option) *Replica {
 		opt(opts)
 	}
 	opts.NodeId = nodeId
+	// 读租约必须严格短于选举间隔，否则旧领导在新领导选出后可能仍认为自己持有租约
+	if opts.ReadLeaseTick >= opts.ElectionIntervalTick {
+		opts.ReadLeaseTick = opts.ElectionIntervalTick / 2
+	}
+	if opts.ReadLeaseTick <= 0 {
+		opts.ReadLeaseTick = 1
+	}
 	if opts.Storage == nil {
 		opts.Storage = NewMemoryStorage()
 	}
@@ -313,6 +322,7 @@ func (r *Replica) initLeaderInfo() {
 			r.lastSyncInfoMap[replica] = &SyncInfo{
 				LastSyncIndex: 0,
 				SyncTick:      0,
+				ElapsedTick:   r.opts.ReadLeaseTick, // 副本同步之前租约无效
 			}
 		}
 		for _, learner := range r.cfg.Learners {
@@ -322,6 +332,7 @@ func (r *Replica) initLeaderInfo() {
 			r.lastSyncInfoMap[learner] = &SyncInfo{
 				LastSyncIndex: 0,
 				SyncTick:      0,
+				ElapsedTick:   r.opts.ReadLeaseTick,
 			}
 		}
 	}
@@ -335,6 +346,7 @@ func (r *Replica) becomeLeader(term uint32) {
 	r.term = term
 	r.leader = r.nodeId
 	r.role = RoleLeader
+	r.termStartIndex = r.replicaLog.lastLogIndex
 
 	r.initLeaderInfo()
 
@@ -465,6 +477,10 @@ func (r *Replica) tickHeartbeat() {
 		return
 	}
 
+	for _, syncInfo := range r.lastSyncInfoMap {
+		syncInfo.ElapsedTick++
+	}
+
 	if r.isRoleTransitioning {
 		r.roleTransitioningTimeoutTick++
 
@@ -577,6 +593,49 @@ func (r *Replica) isSingleNode() bool {
 	return len(r.replicas) == 0
 }
 
+// ReadIndex 获取线性一致性读的读下标（领导节点才能获取）
+// 领导在租约内（过半副本最近来同步过日志）并且已经提交了成为领导前的日志，才返回已提交下标作为读下标
+func (r *Replica) ReadIndex() (uint64, error) {
+	if !r.isLeader() {
+		return 0, ErrNotLeader
+	}
+	if !r.leaseValid() {
+		return 0, ErrLeaseExpired
+	}
+	if r.replicaLog.committedIndex < r.termStartIndex {
+		return 0, ErrReadIndexNotReady
+	}
+	return r.replicaLog.committedIndex, nil
+}
+
+// ReadState 获取副本当前的读状态
+func (r *Replica) ReadState() ReadState {
+	rs := ReadState{
+		Role:           r.role,
+		Leader:         r.leader,
+		Term:           r.term,
+		CommittedIndex: r.replicaLog.committedIndex,
+		AppliedIndex:   r.replicaLog.appliedIndex,
+	}
+	rs.ReadIndex, rs.ReadIndexErr = r.ReadIndex()
+	return rs
+}
+
+// 领导的读租约是否有效
+func (r *Replica) leaseValid() bool {
+	if r.isSingleNode() {
+		return true
+	}
+	count := 1 // 本节点
+	for _, replicaId := range r.replicas {
+		syncInfo := r.lastSyncInfoMap[replicaId]
+		if syncInfo != nil && syncInfo.ElapsedTick < r.opts.ReadLeaseTick {
+			count++
+		}
+	}
+	return count >= r.quorum()
+}
+
 // 获取某个副本的最新日志下标（领导节点才有这个信息）
 func (r *Replica) GetReplicaLastLog(replicaId uint64) uint64 {
 	if replicaId == r.opts.NodeId {
@@ -542,6 +542,7 @@ func (r *Replica) updateReplicSyncInfo(m Message) {
 		// r.Debug("update replic sync info", zap.Uint32("term", r.replicaLog.term), zap.Uint64("from", from), zap.Uint64("lastSyncLogIndex", syncInfo.LastSyncLogIndex))
 	}
 	syncInfo.SyncTick = 0
+	syncInfo.ElapsedTick = 0
 }
 
 func (r *Replica) quorum() int {
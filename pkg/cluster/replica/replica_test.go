@@ -234,6 +234,74 @@ func TestLeaderAndFollowerLogSync(t *testing.T) {
 
 }
 
+// 测试领导的读下标
+func TestLeaderReadIndex(t *testing.T) {
+	leader1 := New(1, WithSyncIntervalTick(1), WithReadLeaseTick(3))
+	initReplica(leader1, Config{
+		Role:     RoleLeader,
+		Term:     1,
+		Replicas: []uint64{1, 2, 3},
+	}, t)
+
+	// 还没有副本来同步过，租约无效
+	_, err := leader1.ReadIndex()
+	assert.Equal(t, ErrLeaseExpired, err)
+
+	err = leader1.Propose([]byte("hello"))
+	assert.NoError(t, err)
+
+	err = leader1.Step(Message{MsgType: MsgSyncReq, Index: 1, From: 2, To: 1, Term: 1})
+	assert.NoError(t, err)
+
+	readIndex, err := leader1.ReadIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), readIndex)
+
+	// 追随者同步到日志后，读下标为已提交下标
+	err = leader1.Step(Message{MsgType: MsgSyncReq, Index: 2, From: 2, To: 1, Term: 1})
+	assert.NoError(t, err)
+
+	readIndex, err = leader1.ReadIndex()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), readIndex)
+	assert.Equal(t, uint64(1), leader1.ReadState().ReadIndex)
+
+	// 超过租约时间没有副本来同步，租约失效
+	for i := 0; i < 3; i++ {
+		leader1.Tick()
+	}
+	_, err = leader1.ReadIndex()
+	assert.Equal(t, ErrLeaseExpired, err)
+	assert.Equal(t, ErrLeaseExpired, leader1.ReadState().ReadIndexErr)
+
+	follower2 := New(2, WithSyncIntervalTick(1))
+	initReplica(follower2, Config{
+		Role:     RoleFollower,
+		Term:     1,
+		Leader:   1,
+		Replicas: []uint64{1, 2, 3},
+	}, t)
+	_, err = follower2.ReadIndex()
+	assert.Equal(t, ErrNotLeader, err)
+
+	rs := follower2.ReadState()
+	assert.Equal(t, uint64(1), rs.Leader)
+	assert.True(t, rs.Readable(0))
+	assert.False(t, rs.Readable(1))
+}
+
+func TestReadLeaseShorterThanElection(t *testing.T) {
+	rc := New(1)
+	assert.Less(t, rc.opts.ReadLeaseTick, rc.opts.ElectionIntervalTick)
+
+	// 租约不小于选举间隔时会被调整
+	rc = New(1, WithElectionIntervalTick(10), WithReadLeaseTick(10))
+	assert.Equal(t, 5, rc.opts.ReadLeaseTick)
+
+	rc = New(1, WithElectionIntervalTick(1), WithReadLeaseTick(3))
+	assert.Equal(t, 1, rc.opts.ReadLeaseTick)
+}
+
 func TestApplyLogs(t *testing.T) {
 	r := New(1, WithSyncIntervalTick(1))
 
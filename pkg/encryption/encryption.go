@@ -0,0 +1,338 @@
+// Package encryption 静态数据加密（encryption at rest）
+//
+// 加密后的文件格式：
+//
+//	header(64): magic(4) + version(1) + keyIdLen(1) + keyId(42) + fileId(16)
+//	version 1（块加密）:  block...: nonce(12) + AES-GCM(明文块) + tag(16)
+//	version 2（流式加密）: AES-CTR(明文)
+//
+// 块加密：明文按 BlockSize 切分成固定大小的块分别用AES-GCM加密（最后一个块可以不满），
+// 块的附加数据为 fileId + 块序号，防止块在文件内或文件之间被调换。
+// 固定大小的块保证可以按偏移量随机读取，未写满的块在Sync时先加密落盘，写满后用新的nonce重新加密覆盖，
+// 覆盖不是原子的，所以只用于写完之后才会被引用的文件（sst、OPTIONS等）。
+//
+// 流式加密：WAL和MANIFEST每次提交都会sync，如果用块加密，宕机时正在覆盖的尾块会损坏，其中已经sync的记录也会丢失。
+// 所以这两类文件用AES-CTR加密（密钥由文件密钥和fileId派生），密文与明文一一对应，追加写只写入新的数据，
+// 不会覆盖已经sync的数据。CTR没有认证标签，数据的完整性由pebble记录自带的校验和保证，写了一半的尾部也由pebble处理。
+//
+// 没有magic的文件视为明文文件，所以已有的数据目录开启加密后仍然可以读取，新写入的文件都会加密，
+// 再通过 EncryptDir 离线重写剩余的明文文件（也用于密钥轮换后淘汰旧密钥）。
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+const (
+	// KeySize AES-256的密钥长度
+	KeySize = 32
+	// BlockSize 每个加密块的明文大小
+	BlockSize = 4096
+
+	headerSize    = 64
+	maxKeyIdLen   = 42
+	fileIdSize    = 16
+	nonceSize     = 12
+	tagSize       = 16
+	physBlockSize = nonceSize + BlockSize + tagSize
+
+	formatVersion       uint8 = 1 // 块加密
+	formatVersionStream uint8 = 2 // 流式加密
+)
+
+var magic = []byte("WKEF")
+
+var (
+	ErrKeyNotFound     = errors.New("encryption key not found")
+	ErrInvalidKey      = errors.New("invalid encryption key")
+	ErrInvalidHeader   = errors.New("invalid encryption header")
+	ErrCorruptedBlock  = errors.New("encrypted block corrupted")
+	ErrWriteAtRequired = errors.New("underlying file does not support WriteAt")
+)
+
+// Key 加密密钥
+type Key struct {
+	Id   string // 密钥id，记录在加密文件的头部，用于解密时查找密钥
+	Data []byte // 密钥数据（AES-256，32字节）
+}
+
+// KeyProvider 密钥提供者
+type KeyProvider interface {
+	// ActiveKey 加密新文件使用的密钥
+	ActiveKey() (Key, error)
+	// Key 获取指定id的密钥（密钥轮换后旧文件仍然使用旧密钥解密，所以旧密钥需要保留）
+	Key(id string) (Key, error)
+}
+
+type header struct {
+	version uint8
+	keyId   string
+	fileId  []byte
+}
+
+func newHeader(keyId string, version uint8) (header, error) {
+	if len(keyId) == 0 || len(keyId) > maxKeyIdLen {
+		return header{}, fmt.Errorf("invalid key id[%s]", keyId)
+	}
+	fileId := make([]byte, fileIdSize)
+	if _, err := rand.Read(fileId); err != nil {
+		return header{}, err
+	}
+	return header{version: version, keyId: keyId, fileId: fileId}, nil
+}
+
+func (h header) encode() []byte {
+	data := make([]byte, headerSize)
+	copy(data, magic)
+	data[4] = h.version
+	data[5] = uint8(len(h.keyId))
+	copy(data[6:], h.keyId)
+	copy(data[headerSize-fileIdSize:], h.fileId)
+	return data
+}
+
+// 是否是加密文件的头部
+func isEncrypted(data []byte) bool {
+	return len(data) >= headerSize && bytes.Equal(data[:len(magic)], magic)
+}
+
+func decodeHeader(data []byte) (header, error) {
+	if !isEncrypted(data) {
+		return header{}, ErrInvalidHeader
+	}
+	version := data[4]
+	if version != formatVersion && version != formatVersionStream {
+		return header{}, fmt.Errorf("unsupported encryption format version[%d]", version)
+	}
+	keyIdLen := int(data[5])
+	if keyIdLen == 0 || keyIdLen > maxKeyIdLen {
+		return header{}, ErrInvalidHeader
+	}
+	fileId := make([]byte, fileIdSize)
+	copy(fileId, data[headerSize-fileIdSize:headerSize])
+	return header{
+		version: version,
+		keyId:   string(data[6 : 6+keyIdLen]),
+		fileId:  fileId,
+	}, nil
+}
+
+// 加密后的文件大小对应的明文大小
+func (h header) plainSize(physSize int64) int64 {
+	if h.version == formatVersionStream {
+		return max(physSize-headerSize, 0)
+	}
+	return plainSize(physSize)
+}
+
+// 块加密的文件大小对应的明文大小
+func plainSize(physSize int64) int64 {
+	if physSize <= headerSize {
+		return 0
+	}
+	rem := physSize - headerSize
+	size := rem / physBlockSize * BlockSize
+	if last := rem % physBlockSize; last > nonceSize+tagSize {
+		size += last - nonceSize - tagSize
+	}
+	return size
+}
+
+// 明文偏移量对应的文件偏移量
+func physOffset(offset int64) int64 {
+	off := headerSize + offset/BlockSize*physBlockSize
+	if inBlock := offset % BlockSize; inBlock > 0 {
+		off += nonceSize + inBlock
+	}
+	return off
+}
+
+func blockAad(fileId []byte, blockIdx int64) []byte {
+	aad := make([]byte, fileIdSize+8)
+	copy(aad, fileId)
+	binary.BigEndian.PutUint64(aad[fileIdSize:], uint64(blockIdx))
+	return aad
+}
+
+// 加密一个块
+func sealBlock(aead cipher.AEAD, fileId []byte, blockIdx int64, plain []byte) ([]byte, error) {
+	out := make([]byte, nonceSize, nonceSize+len(plain)+tagSize)
+	if _, err := rand.Read(out); err != nil {
+		return nil, err
+	}
+	return aead.Seal(out, out[:nonceSize], plain, blockAad(fileId, blockIdx)), nil
+}
+
+// 解密一个块
+func openBlock(aead cipher.AEAD, fileId []byte, blockIdx int64, data []byte) ([]byte, error) {
+	if len(data) <= nonceSize+tagSize {
+		return nil, ErrCorruptedBlock
+	}
+	plain, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], blockAad(fileId, blockIdx))
+	if err != nil {
+		return nil, fmt.Errorf("%w: block[%d]", ErrCorruptedBlock, blockIdx)
+	}
+	return plain, nil
+}
+
+// 缓存每个密钥的AEAD
+type aeadCache struct {
+	provider KeyProvider
+	mu       sync.Mutex
+	aeads    map[string]cipher.AEAD
+	keys     map[string][]byte // 密钥数据，流式加密的文件用来派生文件的密钥
+}
+
+func newAeadCache(provider KeyProvider) *aeadCache {
+	return &aeadCache{
+		provider: provider,
+		aeads:    make(map[string]cipher.AEAD),
+		keys:     make(map[string][]byte),
+	}
+}
+
+func (c *aeadCache) get(keyId string) (cipher.AEAD, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(keyId); err != nil {
+		return nil, err
+	}
+	return c.aeads[keyId], nil
+}
+
+// 流式加密的文件使用的cipher
+func (c *aeadCache) stream(keyId string, fileId []byte) (cipher.Block, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.loadLocked(keyId); err != nil {
+		return nil, err
+	}
+	return newStreamBlock(c.keys[keyId], fileId)
+}
+
+func (c *aeadCache) loadLocked(keyId string) error {
+	if _, ok := c.aeads[keyId]; ok {
+		return nil
+	}
+	key, err := c.provider.Key(keyId)
+	if err != nil {
+		return fmt.Errorf("get key[%s] failed: %w", keyId, err)
+	}
+	aead, err := newAead(key.Data)
+	if err != nil {
+		return err
+	}
+	c.aeads[keyId] = aead
+	c.keys[keyId] = key.Data
+	return nil
+}
+
+// 当前活跃的密钥
+func (c *aeadCache) active() (string, cipher.AEAD, error) {
+	key, err := c.provider.ActiveKey()
+	if err != nil {
+		return "", nil, err
+	}
+	aead, err := c.get(key.Id)
+	if err != nil {
+		return "", nil, err
+	}
+	return key.Id, aead, nil
+}
+
+func newAead(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 用密钥和fileId派生出文件自己的AES密钥，每个文件的CTR密钥流都不同，也不会和块加密的密钥流重合
+func newStreamBlock(key []byte, fileId []byte) (cipher.Block, error) {
+	if len(key) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("wkef-stream"))
+	mac.Write(fileId)
+	return aes.NewCipher(mac.Sum(nil))
+}
+
+// 从明文的offset位置开始对data做CTR加解密（原地）
+func xorKeyStream(block cipher.Block, data []byte, offset int64) {
+	iv := make([]byte, aes.BlockSize)
+	binary.BigEndian.PutUint64(iv[aes.BlockSize-8:], uint64(offset/aes.BlockSize))
+	stream := cipher.NewCTR(block, iv)
+	if skip := offset % aes.BlockSize; skip > 0 {
+		pad := make([]byte, skip)
+		stream.XORKeyStream(pad, pad)
+	}
+	stream.XORKeyStream(data, data)
+}
+
+// Seal 用活跃的密钥加密整段数据（格式与加密文件相同，用于配置文件等整体读写的小文件）
+func (f *FS) Seal(data []byte) ([]byte, error) {
+	keyId, aead, err := f.aeads.active()
+	if err != nil {
+		return nil, err
+	}
+	h, err := newHeader(keyId, formatVersion)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, physOffset(int64(len(data)))+tagSize)
+	out = append(out, h.encode()...)
+	for i := int64(0); len(data) > 0; i++ {
+		n := min(len(data), BlockSize)
+		sealed, err := sealBlock(aead, h.fileId, i, data[:n])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, sealed...)
+		data = data[n:]
+	}
+	return out, nil
+}
+
+// Unseal 解密整段数据，没有加密的数据原样返回（兼容开启加密前写入的文件）
+func (f *FS) Unseal(data []byte) ([]byte, error) {
+	if !isEncrypted(data) {
+		return data, nil
+	}
+	h, err := decodeHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.version != formatVersion {
+		return nil, ErrInvalidHeader
+	}
+	aead, err := f.aeads.get(h.keyId)
+	if err != nil {
+		return nil, err
+	}
+	data = data[headerSize:]
+	out := make([]byte, 0, plainSize(int64(len(data))+headerSize))
+	for i := int64(0); len(data) > 0; i++ {
+		n := min(len(data), physBlockSize)
+		plain, err := openBlock(aead, h.fileId, i, data[:n])
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, plain...)
+		data = data[n:]
+	}
+	return out, nil
+}
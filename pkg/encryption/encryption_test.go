@@ -0,0 +1,334 @@
+package encryption_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
+	"github.com/cockroachdb/pebble"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealUnseal(t *testing.T) {
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(t.TempDir(), "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	for _, size := range []int{0, 1, encryption.BlockSize, encryption.BlockSize*2 + 100} {
+		data := bytes.Repeat([]byte("a"), size)
+		sealed, err := fs.Seal(data)
+		assert.NoError(t, err)
+		assert.False(t, size > 16 && bytes.Contains(sealed, data))
+
+		plain, err := fs.Unseal(sealed)
+		assert.NoError(t, err)
+		assert.Equal(t, size, len(plain))
+		assert.True(t, bytes.Equal(data, plain))
+	}
+
+	// 明文原样返回
+	plain, err := fs.Unseal([]byte(`{"id":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"id":1}`, string(plain))
+
+	// 篡改后解密失败
+	sealed, err := fs.Seal([]byte("hello"))
+	assert.NoError(t, err)
+	sealed[len(sealed)-1] ^= 0xff
+	_, err = fs.Unseal(sealed)
+	assert.ErrorIs(t, err, encryption.ErrCorruptedBlock)
+}
+
+func TestFSWithPebble(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	dbDir := filepath.Join(dir, "db")
+	writePebble(t, fs, dbDir, 0, 1000)
+	// 重新打开（回放wal、读取sst）
+	readPebble(t, fs, dbDir, 0, 1000)
+
+	// 磁盘上没有明文
+	assertNoPlaintext(t, dbDir, []byte("value-00000500"))
+
+	// 没有密钥无法打开
+	otherProvider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "otherkeyfile"), nil)
+	assert.NoError(t, err)
+	_, err = pebble.Open(dbDir, &pebble.Options{FS: encryption.NewFS(vfs.Default, otherProvider)})
+	assert.Error(t, err)
+}
+
+func TestMigrateAndRotate(t *testing.T) {
+	dir := t.TempDir()
+	dbDir := filepath.Join(dir, "db")
+
+	// 开启加密前的明文数据
+	writePebble(t, vfs.Default, dbDir, 0, 500)
+
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	// 明文文件仍然可以读取，新写入的数据加密
+	writePebble(t, fs, dbDir, 500, 1000)
+	readPebble(t, fs, dbDir, 0, 1000)
+
+	// 离线加密剩下的明文文件
+	count, err := fs.EncryptDir(dbDir)
+	assert.NoError(t, err)
+	assert.True(t, count > 0)
+	assertNoPlaintext(t, dbDir, []byte("value-00000100"))
+	readPebble(t, fs, dbDir, 0, 1000)
+
+	// 轮换密钥后旧文件仍然可以读取
+	oldKey, err := provider.ActiveKey()
+	assert.NoError(t, err)
+	newKey, err := provider.Rotate()
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldKey.Id, newKey.Id)
+	readPebble(t, fs, dbDir, 0, 1000)
+
+	// 用新密钥重新加密后删除旧密钥
+	count, err = fs.EncryptDir(dbDir)
+	assert.NoError(t, err)
+	assert.True(t, count > 0)
+	assert.NoError(t, provider.RemoveKey(oldKey.Id))
+
+	reloaded, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{newKey.Id}, reloaded.Keys())
+	readPebble(t, encryption.NewFS(vfs.Default, reloaded), dbDir, 0, 1000)
+
+	// 关闭加密
+	count, err = fs.DecryptDir(dbDir)
+	assert.NoError(t, err)
+	assert.True(t, count > 0)
+	readPebble(t, vfs.Default, dbDir, 0, 1000)
+}
+
+func TestFileKeyProviderWithKMS(t *testing.T) {
+	masterKey, err := encryption.ParseMasterKey("MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=")
+	assert.NoError(t, err)
+	kms, err := encryption.NewLocalKMS(masterKey)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keyfile")
+	provider, err := encryption.NewFileKeyProvider(path, kms)
+	assert.NoError(t, err)
+	key, err := provider.ActiveKey()
+	assert.NoError(t, err)
+
+	// 密钥文件中没有明文密钥
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.False(t, bytes.Contains(data, key.Data))
+
+	reloaded, err := encryption.NewFileKeyProvider(path, kms)
+	assert.NoError(t, err)
+	reloadedKey, err := reloaded.Key(key.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, key.Data, reloadedKey.Data)
+
+	// 没有配置kms或主密钥错误
+	_, err = encryption.NewFileKeyProvider(path, nil)
+	assert.Error(t, err)
+	otherKMS, err := encryption.NewLocalKMS(bytes.Repeat([]byte("k"), encryption.KeySize))
+	assert.NoError(t, err)
+	_, err = encryption.NewFileKeyProvider(path, otherKMS)
+	assert.Error(t, err)
+}
+
+func TestTornTail(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	// 块加密的文件
+	name := filepath.Join(dir, "000001.sst")
+	f, err := fs.Create(name)
+	assert.NoError(t, err)
+	data := bytes.Repeat([]byte("a"), encryption.BlockSize*2+100)
+	_, err = f.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Sync())
+	_, err = f.Write([]byte("bbb"))
+	assert.NoError(t, err)
+	assert.NoError(t, f.Sync())
+	assert.NoError(t, f.Close())
+
+	// 模拟覆盖尾块时宕机
+	corruptAt(t, name, -8)
+
+	rf, err := fs.Open(name)
+	assert.NoError(t, err)
+
+	// 顺序读取到尾块之前结束
+	plain, err := io.ReadAll(rf)
+	assert.NoError(t, err)
+	assert.Equal(t, data[:encryption.BlockSize*2], plain)
+
+	// 随机读取仍然报告损坏
+	_, err = rf.ReadAt(make([]byte, 10), encryption.BlockSize*2)
+	assert.ErrorIs(t, err, encryption.ErrCorruptedBlock)
+	assert.NoError(t, rf.Close())
+
+	// 中间的块损坏不能当作尾部截断
+	corruptAt(t, name, 100)
+	rf, err = fs.Open(name)
+	assert.NoError(t, err)
+	defer rf.Close()
+	_, err = io.ReadAll(rf)
+	assert.ErrorIs(t, err, encryption.ErrCorruptedBlock)
+}
+
+func TestStreamFile(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	name := filepath.Join(dir, "000002.log")
+	f, err := fs.Create(name)
+	assert.NoError(t, err)
+	data := []byte(strings.Repeat("hello wal ", 500))
+	_, err = f.Write(data[:4000])
+	assert.NoError(t, err)
+	assert.NoError(t, f.Sync())
+	synced, err := os.ReadFile(name)
+	assert.NoError(t, err)
+
+	_, err = f.Write(data[4000:])
+	assert.NoError(t, err)
+	assert.NoError(t, f.Sync())
+	assert.NoError(t, f.Close())
+
+	// 已经sync的数据不会被覆盖
+	raw, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	assert.Equal(t, synced, raw[:len(synced)])
+	assertNoPlaintext(t, dir, []byte("hello wal"))
+
+	st, err := fs.Stat(name)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), st.Size())
+
+	rf, err := fs.Open(name)
+	assert.NoError(t, err)
+	defer rf.Close()
+	plain, err := io.ReadAll(rf)
+	assert.NoError(t, err)
+	assert.Equal(t, data, plain)
+
+	buf := make([]byte, 23)
+	_, err = rf.ReadAt(buf, 4001)
+	assert.NoError(t, err)
+	assert.Equal(t, data[4001:4024], buf)
+}
+
+func TestTornTailWithPebble(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	dbDir := filepath.Join(dir, "db")
+	writePebble(t, fs, dbDir, 0, 1000)
+
+	// 最新的WAL尾部写了一半
+	wals, err := filepath.Glob(filepath.Join(dbDir, "*.log"))
+	assert.NoError(t, err)
+	assert.True(t, len(wals) > 0)
+	sort.Strings(wals)
+	corruptAt(t, wals[len(wals)-1], -8)
+
+	// 可以正常打开，flush之前的数据都在
+	readPebble(t, fs, dbDir, 0, 500)
+}
+
+func TestFilesWithKey(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	dbDir := filepath.Join(dir, "db")
+	writePebble(t, fs, dbDir, 0, 100)
+	oldKey, err := provider.ActiveKey()
+	assert.NoError(t, err)
+	files, err := fs.FilesWithKey(dbDir, oldKey.Id)
+	assert.NoError(t, err)
+	assert.True(t, len(files) > 0)
+
+	_, err = provider.Rotate()
+	assert.NoError(t, err)
+	_, err = fs.EncryptDir(dbDir)
+	assert.NoError(t, err)
+	files, err = fs.FilesWithKey(dbDir, oldKey.Id)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+// 破坏文件offset位置开始的8个字节，offset为负数时从文件末尾计算
+func corruptAt(t *testing.T, name string, offset int) {
+	data, err := os.ReadFile(name)
+	assert.NoError(t, err)
+	if offset < 0 {
+		offset += len(data)
+	}
+	for i := offset; i < offset+8; i++ {
+		data[i] ^= 0xff
+	}
+	assert.NoError(t, os.WriteFile(name, data, 0600))
+}
+
+func writePebble(t *testing.T, fs vfs.FS, dir string, start, end int) {
+	db, err := pebble.Open(dir, &pebble.Options{FS: fs})
+	assert.NoError(t, err)
+	for i := start; i < end; i++ {
+		err = db.Set([]byte(fmt.Sprintf("key-%08d", i)), []byte(fmt.Sprintf("value-%08d", i)), pebble.Sync)
+		assert.NoError(t, err)
+		if i == (start+end)/2 {
+			assert.NoError(t, db.Flush())
+		}
+	}
+	assert.NoError(t, db.Close())
+}
+
+func readPebble(t *testing.T, fs vfs.FS, dir string, start, end int) {
+	db, err := pebble.Open(dir, &pebble.Options{FS: fs})
+	assert.NoError(t, err)
+	defer db.Close()
+	for i := start; i < end; i++ {
+		value, closer, err := db.Get([]byte(fmt.Sprintf("key-%08d", i)))
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.Equal(t, fmt.Sprintf("value-%08d", i), string(value))
+		closer.Close()
+	}
+}
+
+func assertNoPlaintext(t *testing.T, dir string, plaintext []byte) {
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		assert.False(t, bytes.Contains(data, plaintext), path)
+		return nil
+	})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,520 @@
+package encryption
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// FS 加密的pebble文件系统，Create创建的文件都会用活跃的密钥加密，Open时根据文件头部的密钥id解密，没有加密的文件按明文读取
+type FS struct {
+	vfs.FS
+	aeads *aeadCache
+}
+
+var _ vfs.FS = (*FS)(nil)
+
+// NewFS 创建加密文件系统 inner为底层文件系统（一般为vfs.Default），需要支持WriteAt
+func NewFS(inner vfs.FS, provider KeyProvider) *FS {
+	return &FS{
+		FS:    inner,
+		aeads: newAeadCache(provider),
+	}
+}
+
+func (f *FS) Create(name string) (vfs.File, error) {
+	file, err := f.FS.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	var ef vfs.File
+	if isStreamFile(name) {
+		ef, err = f.newWritableStreamFile(file)
+	} else {
+		ef, err = f.newWritableFile(file)
+	}
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return ef, nil
+}
+
+// WAL和MANIFEST使用流式加密（见包注释），离线重写时的临时文件按原文件名判断
+func isStreamFile(name string) bool {
+	base := strings.TrimSuffix(filepath.Base(name), rewriteTmpSuffix)
+	return strings.HasSuffix(base, ".log") || strings.HasPrefix(base, "MANIFEST-")
+}
+
+// 写入文件头部
+func (f *FS) writeHeader(file vfs.File, version uint8) (io.WriterAt, header, cipher.AEAD, error) {
+	writerAt, ok := file.(io.WriterAt)
+	if !ok {
+		return nil, header{}, nil, ErrWriteAtRequired
+	}
+	keyId, aead, err := f.aeads.active()
+	if err != nil {
+		return nil, header{}, nil, err
+	}
+	h, err := newHeader(keyId, version)
+	if err != nil {
+		return nil, header{}, nil, err
+	}
+	if _, err = writerAt.WriteAt(h.encode(), 0); err != nil {
+		return nil, header{}, nil, err
+	}
+	return writerAt, h, aead, nil
+}
+
+func (f *FS) newWritableFile(file vfs.File) (*encryptedFile, error) {
+	writerAt, h, aead, err := f.writeHeader(file, formatVersion)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedFile{
+		file:     file,
+		writerAt: writerAt,
+		aead:     aead,
+		fileId:   h.fileId,
+		buf:      make([]byte, 0, BlockSize),
+	}, nil
+}
+
+func (f *FS) newWritableStreamFile(file vfs.File) (*streamFile, error) {
+	writerAt, h, _, err := f.writeHeader(file, formatVersionStream)
+	if err != nil {
+		return nil, err
+	}
+	block, err := f.aeads.stream(h.keyId, h.fileId)
+	if err != nil {
+		return nil, err
+	}
+	return &streamFile{
+		file:     file,
+		writerAt: writerAt,
+		block:    block,
+	}, nil
+}
+
+func (f *FS) Open(name string, opts ...vfs.OpenOption) (vfs.File, error) {
+	file, err := f.FS.Open(name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	h, encrypted, err := readHeader(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	if !encrypted { // 明文文件
+		return file, nil
+	}
+	if h.version == formatVersionStream {
+		block, err := f.aeads.stream(h.keyId, h.fileId)
+		if err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+		return &streamFile{
+			file:     file,
+			block:    block,
+			readOnly: true,
+		}, nil
+	}
+	aead, err := f.aeads.get(h.keyId)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	st, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return &encryptedFile{
+		file:     file,
+		aead:     aead,
+		fileId:   h.fileId,
+		readOnly: true,
+		size:     plainSize(st.Size()),
+	}, nil
+}
+
+// ReuseForWrite 加密文件不能直接覆盖写（块的nonce和文件id需要重新生成），所以删除旧文件后重新创建
+func (f *FS) ReuseForWrite(oldname, newname string) (vfs.File, error) {
+	if err := f.FS.Remove(oldname); err != nil {
+		return nil, err
+	}
+	return f.Create(newname)
+}
+
+// Stat 返回的文件大小为明文大小
+func (f *FS) Stat(name string) (os.FileInfo, error) {
+	st, err := f.FS.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if st.IsDir() || st.Size() < headerSize {
+		return st, nil
+	}
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	h, encrypted, err := readHeader(file)
+	if err != nil {
+		return nil, err
+	}
+	if !encrypted {
+		return st, nil
+	}
+	return &fileInfo{FileInfo: st, size: h.plainSize(st.Size())}, nil
+}
+
+// KeyIdOf 获取文件加密使用的密钥id，明文文件返回空
+func (f *FS) KeyIdOf(name string) (string, error) {
+	file, err := f.FS.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	h, encrypted, err := readHeader(file)
+	if err != nil || !encrypted {
+		return "", err
+	}
+	return h.keyId, nil
+}
+
+func readHeader(file vfs.File) (header, bool, error) {
+	data := make([]byte, headerSize)
+	n, err := file.ReadAt(data, 0)
+	if err != nil && err != io.EOF {
+		return header{}, false, err
+	}
+	if !isEncrypted(data[:n]) {
+		return header{}, false, nil
+	}
+	h, err := decodeHeader(data)
+	if err != nil {
+		return header{}, false, err
+	}
+	return h, true, nil
+}
+
+type fileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+func (f *fileInfo) Size() int64 {
+	return f.size
+}
+
+// 块加密的文件
+type encryptedFile struct {
+	file   vfs.File
+	aead   cipher.AEAD
+	fileId []byte
+
+	mu sync.Mutex
+
+	// 写（只支持追加写）
+	writerAt io.WriterAt
+	blockIdx int64  // 当前块的序号
+	buf      []byte // 当前块的明文（未写满）
+	dirty    bool   // 当前块是否有还没写入文件的数据
+
+	// 读
+	readOnly bool
+	size     int64 // 只读文件的明文大小
+	offset   int64 // Read的位置
+}
+
+var _ vfs.File = (*encryptedFile)(nil)
+
+func (e *encryptedFile) Write(p []byte) (int, error) {
+	if e.readOnly {
+		return 0, os.ErrPermission
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		n := min(len(p), BlockSize-len(e.buf))
+		e.buf = append(e.buf, p[:n]...)
+		p = p[n:]
+		written += n
+		if len(e.buf) < BlockSize {
+			e.dirty = true
+			continue
+		}
+		if err := e.writeBlock(); err != nil {
+			return written, err
+		}
+		e.blockIdx++
+		e.buf = e.buf[:0]
+		e.dirty = false
+	}
+	return written, nil
+}
+
+// 加密当前块写入文件
+func (e *encryptedFile) writeBlock() error {
+	sealed, err := sealBlock(e.aead, e.fileId, e.blockIdx, e.buf)
+	if err != nil {
+		return err
+	}
+	_, err = e.writerAt.WriteAt(sealed, headerSize+e.blockIdx*physBlockSize)
+	return err
+}
+
+// 将未写满的块写入文件
+//
+// 未写满的块每次sync都会在原位置重新加密覆盖，覆盖不是原子的，所以WAL和MANIFEST使用流式加密（见streamFile）
+func (e *encryptedFile) flush() error {
+	if !e.dirty || len(e.buf) == 0 {
+		return nil
+	}
+	if err := e.writeBlock(); err != nil {
+		return err
+	}
+	e.dirty = false
+	return nil
+}
+
+// Read 顺序读取
+//
+// 只有文件最后一个块认证失败时当作写了一半的尾部，返回之前的数据和io.EOF（兼容流式加密之前用块加密写入的WAL和MANIFEST），
+// 其他位置的块认证失败说明文件损坏或者被篡改，返回ErrCorruptedBlock。随机读取（ReadAt）总是返回ErrCorruptedBlock
+func (e *encryptedFile) Read(p []byte) (int, error) {
+	e.mu.Lock()
+	offset := e.offset
+	e.mu.Unlock()
+
+	n, err := e.readAt(p, offset, true)
+
+	e.mu.Lock()
+	e.offset += int64(n)
+	e.mu.Unlock()
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (e *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	return e.readAt(p, off, false)
+}
+
+// tornTail为true时最后一个块认证失败当作文件的结尾
+func (e *encryptedFile) readAt(p []byte, off int64, tornTail bool) (int, error) {
+	if !e.readOnly { // 只读文件没有可变的状态，不需要加锁（pebble会并发读取sst）
+		e.mu.Lock()
+		defer e.mu.Unlock()
+	}
+
+	size := e.plainSizeLocked()
+	if off >= size {
+		return 0, io.EOF
+	}
+	read := 0
+	for len(p) > 0 && off < size {
+		blockIdx := off / BlockSize
+		plain, err := e.readBlock(blockIdx)
+		if err != nil {
+			if tornTail && blockIdx == (size-1)/BlockSize && errors.Is(err, ErrCorruptedBlock) {
+				return read, io.EOF
+			}
+			return read, err
+		}
+		inBlock := int(off % BlockSize)
+		if inBlock >= len(plain) {
+			break
+		}
+		n := copy(p, plain[inBlock:])
+		p = p[n:]
+		off += int64(n)
+		read += n
+	}
+	if len(p) > 0 {
+		return read, io.EOF
+	}
+	return read, nil
+}
+
+func (e *encryptedFile) readBlock(blockIdx int64) ([]byte, error) {
+	if !e.readOnly && blockIdx == e.blockIdx { // 当前正在写的块
+		return e.buf, nil
+	}
+	data := make([]byte, physBlockSize)
+	n, err := e.file.ReadAt(data, headerSize+blockIdx*physBlockSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	return openBlock(e.aead, e.fileId, blockIdx, data[:n])
+}
+
+func (e *encryptedFile) plainSizeLocked() int64 {
+	if e.readOnly {
+		return e.size
+	}
+	return e.blockIdx*BlockSize + int64(len(e.buf))
+}
+
+func (e *encryptedFile) Close() error {
+	e.mu.Lock()
+	err := e.flush()
+	e.mu.Unlock()
+	if cerr := e.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (e *encryptedFile) Preallocate(offset, length int64) error {
+	start := physOffset(offset)
+	return e.file.Preallocate(start, physOffset(offset+length)-start)
+}
+
+func (e *encryptedFile) Stat() (os.FileInfo, error) {
+	st, err := e.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return &fileInfo{FileInfo: st, size: e.plainSizeLocked()}, nil
+}
+
+func (e *encryptedFile) Sync() error {
+	e.mu.Lock()
+	err := e.flush()
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return e.file.Sync()
+}
+
+func (e *encryptedFile) SyncData() error {
+	e.mu.Lock()
+	err := e.flush()
+	e.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return e.file.SyncData()
+}
+
+// SyncTo 未写满的块需要先加密写入，所以总是同步整个文件
+func (e *encryptedFile) SyncTo(length int64) (fullSync bool, err error) {
+	if err = e.SyncData(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (e *encryptedFile) Prefetch(offset int64, length int64) error {
+	start := physOffset(offset)
+	return e.file.Prefetch(start, physOffset(offset+length)-start)
+}
+
+func (e *encryptedFile) Fd() uintptr {
+	return e.file.Fd()
+}
+
+// 流式加密的文件（WAL、MANIFEST）
+//
+// 密文与明文一一对应，文件偏移量 = 头部大小 + 明文偏移量，追加写只写入新的数据，sync不会覆盖之前已经sync的数据
+type streamFile struct {
+	file  vfs.File
+	block cipher.Block
+
+	mu sync.Mutex
+
+	// 写（只支持追加写）
+	writerAt io.WriterAt
+	size     int64 // 已写入的明文大小
+
+	// 读
+	readOnly bool
+	offset   int64 // Read的位置
+}
+
+var _ vfs.File = (*streamFile)(nil)
+
+func (s *streamFile) Write(p []byte) (int, error) {
+	if s.readOnly {
+		return 0, os.ErrPermission
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data := make([]byte, len(p))
+	copy(data, p)
+	xorKeyStream(s.block, data, s.size)
+	n, err := s.writerAt.WriteAt(data, headerSize+s.size)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *streamFile) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n, err := s.ReadAt(p, s.offset)
+	s.offset += int64(n)
+	if err == io.EOF && n > 0 {
+		err = nil
+	}
+	return n, err
+}
+
+func (s *streamFile) ReadAt(p []byte, off int64) (int, error) {
+	n, err := s.file.ReadAt(p, headerSize+off)
+	xorKeyStream(s.block, p[:n], off)
+	return n, err
+}
+
+func (s *streamFile) Close() error {
+	return s.file.Close()
+}
+
+func (s *streamFile) Preallocate(offset, length int64) error {
+	return s.file.Preallocate(headerSize+offset, length)
+}
+
+func (s *streamFile) Stat() (os.FileInfo, error) {
+	st, err := s.file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return &fileInfo{FileInfo: st, size: max(st.Size()-headerSize, 0)}, nil
+}
+
+func (s *streamFile) Sync() error {
+	return s.file.Sync()
+}
+
+func (s *streamFile) SyncData() error {
+	return s.file.SyncData()
+}
+
+func (s *streamFile) SyncTo(length int64) (fullSync bool, err error) {
+	return s.file.SyncTo(headerSize + length)
+}
+
+func (s *streamFile) Prefetch(offset int64, length int64) error {
+	return s.file.Prefetch(headerSize+offset, length)
+}
+
+func (s *streamFile) Fd() uintptr {
+	return s.file.Fd()
+}
@@ -0,0 +1,282 @@
+package encryption
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// KMSClient KMS（密钥管理服务）客户端，用于加密（包装）保存在本地密钥文件中的数据密钥，
+// 可以对接云厂商的KMS或Vault等，密钥文件泄露时没有KMS的权限也无法解密数据
+type KMSClient interface {
+	// Encrypt 加密数据密钥
+	Encrypt(ctx context.Context, plaintext []byte) ([]byte, error)
+	// Decrypt 解密数据密钥
+	Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error)
+}
+
+// 密钥文件
+type keyFile struct {
+	Active  string       `json:"active"`  // 活跃的密钥id
+	Wrapped bool         `json:"wrapped"` // 密钥是否被KMS加密
+	Keys    []keyFileKey `json:"keys"`
+}
+
+type keyFileKey struct {
+	Id        string `json:"id"`
+	Key       string `json:"key"` // base64编码的密钥（Wrapped为true时为KMS加密后的密钥）
+	CreatedAt int64  `json:"created_at"`
+}
+
+// FileKeyProvider 从本地密钥文件获取密钥，密钥文件不存在时自动生成
+//
+// 密钥轮换（Rotate）生成新的密钥并设为活跃，新写入的文件使用新密钥，旧密钥保留在密钥文件中用于解密旧文件。
+type FileKeyProvider struct {
+	path string
+	kms  KMSClient // 为nil表示密钥明文保存
+
+	mu     sync.RWMutex
+	active string
+	keys   map[string]Key
+	file   keyFile
+}
+
+// NewFileKeyProvider 加载密钥文件 kms不为nil时密钥文件中的密钥由kms加密
+func NewFileKeyProvider(path string, kms KMSClient) (*FileKeyProvider, error) {
+	p := &FileKeyProvider{
+		path: path,
+		kms:  kms,
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if _, err = p.Rotate(); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *FileKeyProvider) ActiveKey() (Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok := p.keys[p.active]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+func (p *FileKeyProvider) Key(id string) (Key, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, ok := p.keys[id]
+	if !ok {
+		return Key{}, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// Keys 所有密钥的id（按创建顺序）
+func (p *FileKeyProvider) Keys() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	ids := make([]string, 0, len(p.file.Keys))
+	for _, k := range p.file.Keys {
+		ids = append(ids, k.Id)
+	}
+	return ids
+}
+
+// Reload 重新加载密钥文件（其他进程轮换密钥后）
+func (p *FileKeyProvider) Reload() error {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return err
+	}
+	var kf keyFile
+	if err = json.Unmarshal(data, &kf); err != nil {
+		return fmt.Errorf("parse key file[%s] failed: %w", p.path, err)
+	}
+	if kf.Wrapped && p.kms == nil {
+		return errors.New("key file is wrapped by kms, but kms is not configured")
+	}
+	if !kf.Wrapped && p.kms != nil {
+		return errors.New("kms is configured, but key file is not wrapped")
+	}
+	keys := make(map[string]Key, len(kf.Keys))
+	for _, k := range kf.Keys {
+		data, err := base64.StdEncoding.DecodeString(k.Key)
+		if err != nil {
+			return fmt.Errorf("decode key[%s] failed: %w", k.Id, err)
+		}
+		if kf.Wrapped {
+			data, err = p.kms.Decrypt(context.Background(), data)
+			if err != nil {
+				return fmt.Errorf("kms decrypt key[%s] failed: %w", k.Id, err)
+			}
+		}
+		if len(data) != KeySize {
+			return fmt.Errorf("%w: key[%s]", ErrInvalidKey, k.Id)
+		}
+		keys[k.Id] = Key{Id: k.Id, Data: data}
+	}
+	if _, ok := keys[kf.Active]; !ok {
+		return fmt.Errorf("active key[%s] not found in key file", kf.Active)
+	}
+
+	p.mu.Lock()
+	p.active = kf.Active
+	p.keys = keys
+	p.file = kf
+	p.mu.Unlock()
+	return nil
+}
+
+// Rotate 生成新的密钥并设为活跃的密钥，返回新的密钥
+func (p *FileKeyProvider) Rotate() (Key, error) {
+	data := make([]byte, KeySize)
+	if _, err := rand.Read(data); err != nil {
+		return Key{}, err
+	}
+	key := Key{Id: newKeyId(), Data: data}
+
+	stored := data
+	if p.kms != nil {
+		var err error
+		stored, err = p.kms.Encrypt(context.Background(), data)
+		if err != nil {
+			return Key{}, fmt.Errorf("kms encrypt key failed: %w", err)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	kf := p.file
+	kf.Wrapped = p.kms != nil
+	kf.Active = key.Id
+	kf.Keys = append(append([]keyFileKey(nil), kf.Keys...), keyFileKey{
+		Id:        key.Id,
+		Key:       base64.StdEncoding.EncodeToString(stored),
+		CreatedAt: time.Now().Unix(),
+	})
+	if err := writeKeyFile(p.path, kf); err != nil {
+		return Key{}, err
+	}
+	if p.keys == nil {
+		p.keys = make(map[string]Key)
+	}
+	p.keys[key.Id] = key
+	p.active = key.Id
+	p.file = kf
+	return key, nil
+}
+
+// RemoveKey 从密钥文件删除不再使用的旧密钥（需要先通过EncryptDir将使用此密钥的文件重新加密），不能删除活跃的密钥
+//
+// 这里不会检查数据文件，调用前需要通过FS.FilesWithKey确认没有文件还在使用此密钥
+func (p *FileKeyProvider) RemoveKey(id string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if id == p.active {
+		return errors.New("can not remove active key")
+	}
+	kf := p.file
+	keys := make([]keyFileKey, 0, len(kf.Keys))
+	for _, k := range kf.Keys {
+		if k.Id != id {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == len(kf.Keys) {
+		return ErrKeyNotFound
+	}
+	kf.Keys = keys
+	if err := writeKeyFile(p.path, kf); err != nil {
+		return err
+	}
+	delete(p.keys, id)
+	p.file = kf
+	return nil
+}
+
+// 密钥id 创建时间 + 随机数
+func newKeyId() string {
+	r := make([]byte, 4)
+	_, _ = rand.Read(r)
+	return fmt.Sprintf("%s-%s", time.Now().Format("20060102150405"), hex.EncodeToString(r))
+}
+
+// 先写临时文件再重命名，避免写了一半的密钥文件导致数据无法解密
+func writeKeyFile(path string, kf keyFile) error {
+	data, err := json.MarshalIndent(kf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// LocalKMS 用主密钥加密数据密钥的本地KMS，主密钥与密钥文件分开保存（例如通过环境变量或密钥管理系统注入）
+type LocalKMS struct {
+	masterKey []byte
+}
+
+// NewLocalKMS masterKey为32字节的主密钥
+func NewLocalKMS(masterKey []byte) (*LocalKMS, error) {
+	if len(masterKey) != KeySize {
+		return nil, ErrInvalidKey
+	}
+	return &LocalKMS{masterKey: masterKey}, nil
+}
+
+func (l *LocalKMS) Encrypt(ctx context.Context, plaintext []byte) ([]byte, error) {
+	aead, err := newAead(l.masterKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (l *LocalKMS) Decrypt(ctx context.Context, ciphertext []byte) ([]byte, error) {
+	aead, err := newAead(l.masterKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, ErrInvalidKey
+	}
+	return aead.Open(nil, ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():], nil)
+}
+
+// ParseMasterKey 解析主密钥（base64或hex编码的32字节）
+func ParseMasterKey(s string) ([]byte, error) {
+	if data, err := base64.StdEncoding.DecodeString(s); err == nil && len(data) == KeySize {
+		return data, nil
+	}
+	if data, err := hex.DecodeString(s); err == nil && len(data) == KeySize {
+		return data, nil
+	}
+	return nil, ErrInvalidKey
+}
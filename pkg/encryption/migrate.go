@@ -0,0 +1,143 @@
+package encryption
+
+import (
+	"io"
+	"strings"
+
+	"github.com/cockroachdb/pebble/vfs"
+)
+
+// 重写时使用的临时文件后缀
+const rewriteTmpSuffix = ".encrypt-tmp"
+
+// EncryptDir 离线重写目录（递归）下的文件：明文文件以及不是用活跃密钥加密的文件都用活跃密钥重新加密，返回重写的文件数量
+//
+// 用于已有的数据目录开启加密，以及密钥轮换后淘汰旧密钥，重写期间不能有进程打开目录下的数据库。
+func (f *FS) EncryptDir(dir string) (int, error) {
+	active, err := f.aeads.provider.ActiveKey()
+	if err != nil {
+		return 0, err
+	}
+	return f.rewriteDir(dir, func(name string) (bool, error) {
+		keyId, err := f.KeyIdOf(name)
+		if err != nil {
+			return false, err
+		}
+		return keyId != active.Id, nil
+	}, f.Create)
+}
+
+// DecryptDir 离线将目录（递归）下加密的文件还原为明文，返回重写的文件数量（关闭加密前使用）
+func (f *FS) DecryptDir(dir string) (int, error) {
+	return f.rewriteDir(dir, func(name string) (bool, error) {
+		keyId, err := f.KeyIdOf(name)
+		if err != nil {
+			return false, err
+		}
+		return keyId != "", nil
+	}, f.FS.Create)
+}
+
+func (f *FS) rewriteDir(dir string, needRewrite func(name string) (bool, error), create func(name string) (vfs.File, error)) (int, error) {
+	names, err := f.FS.List(dir)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, n := range names {
+		name := f.FS.PathJoin(dir, n)
+		st, err := f.FS.Stat(name)
+		if err != nil {
+			return count, err
+		}
+		if st.IsDir() {
+			c, err := f.rewriteDir(name, needRewrite, create)
+			count += c
+			if err != nil {
+				return count, err
+			}
+			continue
+		}
+		// 空文件（LOCK、pebble的marker文件等）没有需要加密的内容
+		if st.Size() == 0 || !st.Mode().IsRegular() || strings.HasSuffix(name, rewriteTmpSuffix) {
+			continue
+		}
+		need, err := needRewrite(name)
+		if err != nil {
+			return count, err
+		}
+		if !need {
+			continue
+		}
+		if err = f.rewriteFile(name, create); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// 读取文件（自动解密）写入临时文件后替换原文件
+func (f *FS) rewriteFile(name string, create func(name string) (vfs.File, error)) error {
+	src, err := f.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := name + rewriteTmpSuffix
+	dst, err := create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = f.FS.Remove(tmp)
+		return err
+	}
+	if err = dst.Sync(); err != nil {
+		_ = dst.Close()
+		_ = f.FS.Remove(tmp)
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		_ = f.FS.Remove(tmp)
+		return err
+	}
+	return f.FS.Rename(tmp, name)
+}
+
+// FilesWithKey 目录（递归）下使用指定密钥加密的文件（删除旧密钥前检查）
+func (f *FS) FilesWithKey(dir string, keyId string) ([]string, error) {
+	names, err := f.FS.List(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, n := range names {
+		name := f.FS.PathJoin(dir, n)
+		st, err := f.FS.Stat(name)
+		if err != nil {
+			return files, err
+		}
+		if st.IsDir() {
+			sub, err := f.FilesWithKey(name, keyId)
+			files = append(files, sub...)
+			if err != nil {
+				return files, err
+			}
+			continue
+		}
+		if st.Size() < headerSize || !st.Mode().IsRegular() {
+			continue
+		}
+		id, err := f.KeyIdOf(name)
+		if err != nil {
+			return files, err
+		}
+		if id == keyId {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
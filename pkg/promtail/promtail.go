@@ -1,6 +1,7 @@
 package promtail
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strings"
@@ -25,11 +26,13 @@ type Promtail struct {
 
 func New(opts *Options) *Promtail {
 
-	var clientMetrics = client.NewMetrics(prometheus.DefaultRegisterer)
+	reg := newReplaceRegisterer(prometheus.DefaultRegisterer)
+	var clientMetrics = client.NewMetrics(reg)
 
 	cfg := initPromtailConfig(opts)
+	cfg.ServerConfig.Registerer = reg
 
-	p, err := ptail.New(cfg, nil, clientMetrics, false, ptail.WithLogger(log.NewLogfmtLogger(os.Stdout)))
+	p, err := ptail.New(cfg, nil, clientMetrics, false, ptail.WithLogger(log.NewLogfmtLogger(os.Stdout)), ptail.WithRegisterer(reg))
 	if err != nil {
 		panic(fmt.Errorf("promtail: Failed to create promtail %s", err))
 	}
@@ -66,7 +69,8 @@ func initPromtailConfig(opts *Options) config.Config {
 
 	cfg := config.Config{}
 	// 初始化配置
-	flagext.RegisterFlags(&cfg)
+	// 使用独立的flagset，避免promtail重建时在全局flag上重复定义
+	cfg.RegisterFlags(flag.NewFlagSet("promtail", flag.ContinueOnError))
 	const hostname = "localhost" // 不需要暴露 所以这里使用localhost就行
 	cfg.ServerConfig.HTTPListenAddress = hostname
 	cfg.ServerConfig.ExternalURL = hostname
@@ -0,0 +1,29 @@
+package promtail
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// replaceRegisterer 重复注册时用新的指标替换旧的指标，使promtail可以在进程内重建（例如重新加载配置）
+type replaceRegisterer struct {
+	prometheus.Registerer
+}
+
+func newReplaceRegisterer(reg prometheus.Registerer) *replaceRegisterer {
+	return &replaceRegisterer{Registerer: reg}
+}
+
+func (r *replaceRegisterer) Register(c prometheus.Collector) error {
+	err := r.Registerer.Register(c)
+	if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+		r.Registerer.Unregister(are.ExistingCollector)
+		return r.Registerer.Register(c)
+	}
+	return err
+}
+
+func (r *replaceRegisterer) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
@@ -238,17 +238,57 @@ type IDBMetrics interface {
 	SearchMessagesAdd(v int64)           // 搜索消息
 
 	// 订阅者
-	AddSubscribersAdd(v int64)      // 添加订阅者
-	GetSubscribersAdd(v int64)      // 获取订阅者
-	RemoveSubscribersAdd(v int64)   // 移除订阅者
-	ExistSubscriberAdd(v int64)     // 是否存在订阅者
-	RemoveAllSubscriberAdd(v int64) // 移除所有订阅者
+	AddSubscribersAdd(v int64)            // 添加订阅者
+	GetSubscribersAdd(v int64)            // 获取订阅者
+	RemoveSubscribersAdd(v int64)         // 移除订阅者
+	ExistSubscriberAdd(v int64)           // 是否存在订阅者
+	RemoveAllSubscriberAdd(v int64)       // 移除所有订阅者
+	UpdateSubscribersAdd(v int64)         // 更新订阅者的角色、禁言等属性
+	GetSubscriberAdd(v int64)             // 获取单个订阅者
+	RecordSubscriberHistoriesAdd(v int64) // 记录订阅者的加入/离开
+	GetSubscriberHistoryAdd(v int64)      // 获取订阅者的加入/离开记录
 
 	// 系统账号
 	AddSystemUidsAdd(v int64)    // 添加系统UID
 	RemoveSystemUidsAdd(v int64) // 移除系统UID
 	GetSystemUidsAdd(v int64)    // 获取系统UID
 
+	// api key
+	AddOrUpdateApiKeyAdd(v int64) // 添加或更新api key
+	RemoveApiKeyAdd(v int64)      // 移除api key
+	GetApiKeysAdd(v int64)        // 获取api key
+
+	// 管理端用户
+	AddOrUpdateManagerUserAdd(v int64) // 添加或更新管理端用户
+	RemoveManagerUserAdd(v int64)      // 移除管理端用户
+	GetManagerUsersAdd(v int64)        // 获取管理端用户
+
+	// 消息可见性
+	HideMessagesAdd(v int64)         // 隐藏消息
+	ClearMessagesAdd(v int64)        // 清空消息
+	GetMessageVisibilityAdd(v int64) // 获取消息可见性
+
+	// 端到端加密公钥
+	SaveE2eeKeyBundleAdd(v int64)        // 保存公钥包
+	GetE2eeKeyBundlesAdd(v int64)        // 获取公钥包
+	GetE2eeOnetimePrekeysAdd(v int64)    // 获取一次性预共享公钥
+	RemoveE2eeOnetimePrekeysAdd(v int64) // 删除一次性预共享公钥
+	RemoveE2eeKeyBundleAdd(v int64)      // 删除公钥包
+
+	// 消息保留
+	PurgeMessagesToAdd(v int64) // 按保留策略删除消息
+	PurgedMessagesAdd(v int64)  // 按保留策略删除的消息数量
+
+	// 冷存储
+	OffloadMessagesToAdd(v int64)   // 转存消息到冷存储
+	OffloadedMessagesAdd(v int64)   // 转存到冷存储的消息数量
+	LoadColdSegmentAdd(v int64)     // 从冷存储加载消息段
+	ColdSegmentCacheHitAdd(v int64) // 消息段缓存命中
+
+	// 审计日志
+	AppendAuditLogsAdd(v int64) // 追加审计日志
+	SearchAuditLogsAdd(v int64) // 搜索审计日志
+
 	// 用户
 	GetUserAdd(v int64)    // 获取用户
 	ExistUserAdd(v int64)  // 是否存在用户
@@ -280,6 +320,9 @@ type IAppMetrics interface {
 	// MessageLatencyOb 消息延迟
 	MessageLatencyOb(v int64)
 
+	// IPAccessRuleHitAdd IP访问控制规则命中次数
+	IPAccessRuleHitAdd(listener, action, rule string, v int64)
+
 	// PingBytesAdd ping流量
 	PingBytesAdd(v int64)
 	PingBytes() int64
@@ -486,6 +529,13 @@ type IClusterMetrics interface {
 	// ProposeFailedCountAdd 提案失败的次数
 	ProposeFailedCountAdd(kind ClusterKind, v int64)
 
+	// ConsistencyCheckCountAdd 副本一致性校验次数
+	ConsistencyCheckCountAdd(kind ClusterKind, v int64)
+	// ConsistencyDivergenceCountAdd 发现副本不一致的次数
+	ConsistencyDivergenceCountAdd(kind ClusterKind, v int64)
+	// ConsistencyRepairCountAdd 修复不一致副本的次数
+	ConsistencyRepairCountAdd(kind ClusterKind, v int64)
+
 	// ObserverNodeRequesting 节点请求中的数量
 	ObserverNodeRequesting(f func() int64)
 
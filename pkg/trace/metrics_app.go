@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/metric"
 	"go.uber.org/atomic"
 	"go.uber.org/zap"
@@ -17,6 +18,7 @@ type appMetrics struct {
 	onlineUserCount    atomic.Int64
 	onlineDeviceCount  atomic.Int64
 	messageLatency     metric.Int64Histogram
+	ipAccessRuleHit    metric.Int64Counter
 	pingBytes          atomic.Int64
 	pingCount          atomic.Int64
 	pongBytes          atomic.Int64
@@ -89,6 +91,10 @@ func newAppMetrics(opts *Options) *appMetrics {
 	if err != nil {
 		a.Panic("Failed to create app_message_latency histogram", zap.Error(err))
 	}
+	a.ipAccessRuleHit, err = meter.Int64Counter("app_ip_access_rule_hit_count", metric.WithDescription("The hit count of ip access rules"))
+	if err != nil {
+		a.Panic("Failed to create app_ip_access_rule_hit_count counter", zap.Error(err))
+	}
 	return a
 }
 
@@ -116,6 +122,14 @@ func (a *appMetrics) MessageLatencyOb(v int64) {
 	a.messageLatency.Record(a.ctx, v)
 }
 
+func (a *appMetrics) IPAccessRuleHitAdd(listener, action, rule string, v int64) {
+	a.ipAccessRuleHit.Add(a.ctx, v, metric.WithAttributes(
+		attribute.String("listener", listener),
+		attribute.String("action", action),
+		attribute.String("rule", rule),
+	))
+}
+
 func (a *appMetrics) PingBytesAdd(v int64) {
 	a.pingBytes.Add(v)
 }
@@ -88,6 +88,14 @@ type clusterMetrics struct {
 
 	slotProposeLatency metric.Int64Histogram
 
+	// consistency
+	channelConsistencyCheckCount      atomic.Int64 // 频道副本一致性校验次数
+	channelConsistencyDivergenceCount atomic.Int64 // 频道副本不一致次数
+	channelConsistencyRepairCount     atomic.Int64 // 频道副本修复次数
+	slotConsistencyCheckCount         atomic.Int64 // 槽副本一致性校验次数
+	slotConsistencyDivergenceCount    atomic.Int64 // 槽副本不一致次数
+	slotConsistencyRepairCount        atomic.Int64 // 槽副本修复次数
+
 	// node
 	observerNodeRequesting func() int64 // 节点请求中的数量
 	observerNodeSending    func() int64 // 节点发送中的数量
@@ -248,6 +256,23 @@ func newClusterMetrics(opts *Options) IClusterMetrics {
 		return nil
 	}, channelProposeCount, channelProposeFailedCount, channelProposeLatencyUnder500ms, channelProposeLatencyOver500ms)
 
+	// consistency
+	channelConsistencyCheckCount := NewInt64ObservableCounter("cluster_channel_consistency_check_count")
+	channelConsistencyDivergenceCount := NewInt64ObservableCounter("cluster_channel_consistency_divergence_count")
+	channelConsistencyRepairCount := NewInt64ObservableCounter("cluster_channel_consistency_repair_count")
+	slotConsistencyCheckCount := NewInt64ObservableCounter("cluster_slot_consistency_check_count")
+	slotConsistencyDivergenceCount := NewInt64ObservableCounter("cluster_slot_consistency_divergence_count")
+	slotConsistencyRepairCount := NewInt64ObservableCounter("cluster_slot_consistency_repair_count")
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(channelConsistencyCheckCount, c.channelConsistencyCheckCount.Load())
+		obs.ObserveInt64(channelConsistencyDivergenceCount, c.channelConsistencyDivergenceCount.Load())
+		obs.ObserveInt64(channelConsistencyRepairCount, c.channelConsistencyRepairCount.Load())
+		obs.ObserveInt64(slotConsistencyCheckCount, c.slotConsistencyCheckCount.Load())
+		obs.ObserveInt64(slotConsistencyDivergenceCount, c.slotConsistencyDivergenceCount.Load())
+		obs.ObserveInt64(slotConsistencyRepairCount, c.slotConsistencyRepairCount.Load())
+		return nil
+	}, channelConsistencyCheckCount, channelConsistencyDivergenceCount, channelConsistencyRepairCount, slotConsistencyCheckCount, slotConsistencyDivergenceCount, slotConsistencyRepairCount)
+
 	// node
 	nodeRequestingCount := NewInt64ObservableCounter("cluster_node_requesting_count")
 	nodeSendingCount := NewInt64ObservableCounter("cluster_node_sending_count")
@@ -550,6 +575,33 @@ func (c *clusterMetrics) ProposeFailedCountAdd(kind ClusterKind, v int64) {
 	}
 }
 
+func (c *clusterMetrics) ConsistencyCheckCountAdd(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelConsistencyCheckCount.Add(v)
+	case ClusterKindSlot:
+		c.slotConsistencyCheckCount.Add(v)
+	}
+}
+
+func (c *clusterMetrics) ConsistencyDivergenceCountAdd(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelConsistencyDivergenceCount.Add(v)
+	case ClusterKindSlot:
+		c.slotConsistencyDivergenceCount.Add(v)
+	}
+}
+
+func (c *clusterMetrics) ConsistencyRepairCountAdd(kind ClusterKind, v int64) {
+	switch kind {
+	case ClusterKindChannel:
+		c.channelConsistencyRepairCount.Add(v)
+	case ClusterKindSlot:
+		c.slotConsistencyRepairCount.Add(v)
+	}
+}
+
 func (c *clusterMetrics) ObserverNodeRequesting(f func() int64) {
 	c.observerNodeRequesting = f
 }
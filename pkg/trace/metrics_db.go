@@ -165,17 +165,57 @@ type dbMetrics struct {
 	searchMessages           atomic.Int64
 
 	// 订阅者
-	addSubscribers      atomic.Int64
-	getSubscribers      atomic.Int64
-	removeSubscribers   atomic.Int64
-	existSubscriber     atomic.Int64
-	removeAllSubscriber atomic.Int64
+	addSubscribers            atomic.Int64
+	getSubscribers            atomic.Int64
+	removeSubscribers         atomic.Int64
+	existSubscriber           atomic.Int64
+	removeAllSubscriber       atomic.Int64
+	updateSubscribers         atomic.Int64
+	getSubscriber             atomic.Int64
+	recordSubscriberHistories atomic.Int64
+	getSubscriberHistory      atomic.Int64
 
 	// 系统账号
 	addSystemUids    atomic.Int64
 	removeSystemUids atomic.Int64
 	getSystemUids    atomic.Int64
 
+	// api key
+	addOrUpdateApiKey atomic.Int64
+	removeApiKey      atomic.Int64
+	getApiKeys        atomic.Int64
+
+	// 管理端用户
+	addOrUpdateManagerUser atomic.Int64
+	removeManagerUser      atomic.Int64
+	getManagerUsers        atomic.Int64
+
+	// 消息可见性
+	hideMessages         atomic.Int64
+	clearMessages        atomic.Int64
+	getMessageVisibility atomic.Int64
+
+	// 端到端加密公钥
+	saveE2eeKeyBundle        atomic.Int64
+	getE2eeKeyBundles        atomic.Int64
+	getE2eeOnetimePrekeys    atomic.Int64
+	removeE2eeOnetimePrekeys atomic.Int64
+	removeE2eeKeyBundle      atomic.Int64
+
+	// 消息保留
+	purgeMessagesTo atomic.Int64
+	purgedMessages  atomic.Int64
+
+	// 冷存储
+	offloadMessagesTo   atomic.Int64
+	offloadedMessages   atomic.Int64
+	loadColdSegment     atomic.Int64
+	coldSegmentCacheHit atomic.Int64
+
+	// 审计日志
+	appendAuditLogs atomic.Int64
+	searchAuditLogs atomic.Int64
+
 	// 用户
 	getUser    atomic.Int64
 	existUser  atomic.Int64
@@ -549,6 +589,10 @@ func newDBMetrics(opts *Options) *dbMetrics {
 	removeSubscribers := NewInt64ObservableCounter("db_remove_subscribers_count")
 	existSubscriber := NewInt64ObservableCounter("db_exist_subscriber_count")
 	removeAllSubscriber := NewInt64ObservableCounter("db_remove_all_subscriber_count")
+	updateSubscribers := NewInt64ObservableCounter("db_update_subscribers_count")
+	getSubscriber := NewInt64ObservableCounter("db_get_subscriber_count")
+	recordSubscriberHistories := NewInt64ObservableCounter("db_record_subscriber_histories_count")
+	getSubscriberHistory := NewInt64ObservableCounter("db_get_subscriber_history_count")
 
 	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
 		obs.ObserveInt64(addSubscribers, m.addSubscribers.Load())
@@ -556,8 +600,12 @@ func newDBMetrics(opts *Options) *dbMetrics {
 		obs.ObserveInt64(removeSubscribers, m.removeSubscribers.Load())
 		obs.ObserveInt64(existSubscriber, m.existSubscriber.Load())
 		obs.ObserveInt64(removeAllSubscriber, m.removeAllSubscriber.Load())
+		obs.ObserveInt64(updateSubscribers, m.updateSubscribers.Load())
+		obs.ObserveInt64(getSubscriber, m.getSubscriber.Load())
+		obs.ObserveInt64(recordSubscriberHistories, m.recordSubscriberHistories.Load())
+		obs.ObserveInt64(getSubscriberHistory, m.getSubscriberHistory.Load())
 		return nil
-	}, addSubscribers, getSubscribers, removeSubscribers, existSubscriber, removeAllSubscriber)
+	}, addSubscribers, getSubscribers, removeSubscribers, existSubscriber, removeAllSubscriber, updateSubscribers, getSubscriber, recordSubscriberHistories, getSubscriberHistory)
 
 	// 系统账号
 	addSystemUids := NewInt64ObservableCounter("db_add_system_uids_count")
@@ -571,6 +619,92 @@ func newDBMetrics(opts *Options) *dbMetrics {
 		return nil
 	}, addSystemUids, removeSystemUids, getSystemUids)
 
+	// api key
+	addOrUpdateApiKey := NewInt64ObservableCounter("db_add_or_update_api_key_count")
+	removeApiKey := NewInt64ObservableCounter("db_remove_api_key_count")
+	getApiKeys := NewInt64ObservableCounter("db_get_api_keys_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(addOrUpdateApiKey, m.addOrUpdateApiKey.Load())
+		obs.ObserveInt64(removeApiKey, m.removeApiKey.Load())
+		obs.ObserveInt64(getApiKeys, m.getApiKeys.Load())
+		return nil
+	}, addOrUpdateApiKey, removeApiKey, getApiKeys)
+
+	// 管理端用户
+	addOrUpdateManagerUser := NewInt64ObservableCounter("db_add_or_update_manager_user_count")
+	removeManagerUser := NewInt64ObservableCounter("db_remove_manager_user_count")
+	getManagerUsers := NewInt64ObservableCounter("db_get_manager_users_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(addOrUpdateManagerUser, m.addOrUpdateManagerUser.Load())
+		obs.ObserveInt64(removeManagerUser, m.removeManagerUser.Load())
+		obs.ObserveInt64(getManagerUsers, m.getManagerUsers.Load())
+		return nil
+	}, addOrUpdateManagerUser, removeManagerUser, getManagerUsers)
+
+	// 消息可见性
+	hideMessages := NewInt64ObservableCounter("db_hide_messages_count")
+	clearMessages := NewInt64ObservableCounter("db_clear_messages_count")
+	getMessageVisibility := NewInt64ObservableCounter("db_get_message_visibility_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(hideMessages, m.hideMessages.Load())
+		obs.ObserveInt64(clearMessages, m.clearMessages.Load())
+		obs.ObserveInt64(getMessageVisibility, m.getMessageVisibility.Load())
+		return nil
+	}, hideMessages, clearMessages, getMessageVisibility)
+
+	// 端到端加密公钥
+	saveE2eeKeyBundle := NewInt64ObservableCounter("db_save_e2ee_key_bundle_count")
+	getE2eeKeyBundles := NewInt64ObservableCounter("db_get_e2ee_key_bundles_count")
+	getE2eeOnetimePrekeys := NewInt64ObservableCounter("db_get_e2ee_onetime_prekeys_count")
+	removeE2eeOnetimePrekeys := NewInt64ObservableCounter("db_remove_e2ee_onetime_prekeys_count")
+	removeE2eeKeyBundle := NewInt64ObservableCounter("db_remove_e2ee_key_bundle_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(saveE2eeKeyBundle, m.saveE2eeKeyBundle.Load())
+		obs.ObserveInt64(getE2eeKeyBundles, m.getE2eeKeyBundles.Load())
+		obs.ObserveInt64(getE2eeOnetimePrekeys, m.getE2eeOnetimePrekeys.Load())
+		obs.ObserveInt64(removeE2eeOnetimePrekeys, m.removeE2eeOnetimePrekeys.Load())
+		obs.ObserveInt64(removeE2eeKeyBundle, m.removeE2eeKeyBundle.Load())
+		return nil
+	}, saveE2eeKeyBundle, getE2eeKeyBundles, getE2eeOnetimePrekeys, removeE2eeOnetimePrekeys, removeE2eeKeyBundle)
+
+	// 消息保留
+	purgeMessagesTo := NewInt64ObservableCounter("db_purge_messages_to_count")
+	purgedMessages := NewInt64ObservableCounter("db_purged_messages_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(purgeMessagesTo, m.purgeMessagesTo.Load())
+		obs.ObserveInt64(purgedMessages, m.purgedMessages.Load())
+		return nil
+	}, purgeMessagesTo, purgedMessages)
+
+	// 冷存储
+	offloadMessagesTo := NewInt64ObservableCounter("db_offload_messages_to_count")
+	offloadedMessages := NewInt64ObservableCounter("db_offloaded_messages_count")
+	loadColdSegment := NewInt64ObservableCounter("db_load_cold_segment_count")
+	coldSegmentCacheHit := NewInt64ObservableCounter("db_cold_segment_cache_hit_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(offloadMessagesTo, m.offloadMessagesTo.Load())
+		obs.ObserveInt64(offloadedMessages, m.offloadedMessages.Load())
+		obs.ObserveInt64(loadColdSegment, m.loadColdSegment.Load())
+		obs.ObserveInt64(coldSegmentCacheHit, m.coldSegmentCacheHit.Load())
+		return nil
+	}, offloadMessagesTo, offloadedMessages, loadColdSegment, coldSegmentCacheHit)
+
+	// 审计日志
+	appendAuditLogs := NewInt64ObservableCounter("db_append_audit_logs_count")
+	searchAuditLogs := NewInt64ObservableCounter("db_search_audit_logs_count")
+
+	RegisterCallback(func(ctx context.Context, obs metric.Observer) error {
+		obs.ObserveInt64(appendAuditLogs, m.appendAuditLogs.Load())
+		obs.ObserveInt64(searchAuditLogs, m.searchAuditLogs.Load())
+		return nil
+	}, appendAuditLogs, searchAuditLogs)
+
 	// 用户
 	getUser := NewInt64ObservableCounter("db_get_user_count")
 	existUser := NewInt64ObservableCounter("db_exist_user_count")
@@ -1018,6 +1152,18 @@ func (m *dbMetrics) ExistSubscriberAdd(v int64) {
 func (m *dbMetrics) RemoveAllSubscriberAdd(v int64) {
 	m.removeAllSubscriber.Add(v)
 }
+func (m *dbMetrics) UpdateSubscribersAdd(v int64) {
+	m.updateSubscribers.Add(v)
+}
+func (m *dbMetrics) GetSubscriberAdd(v int64) {
+	m.getSubscriber.Add(v)
+}
+func (m *dbMetrics) RecordSubscriberHistoriesAdd(v int64) {
+	m.recordSubscriberHistories.Add(v)
+}
+func (m *dbMetrics) GetSubscriberHistoryAdd(v int64) {
+	m.getSubscriberHistory.Add(v)
+}
 
 // 系统账号
 func (m *dbMetrics) AddSystemUidsAdd(v int64) {
@@ -1030,6 +1176,86 @@ func (m *dbMetrics) GetSystemUidsAdd(v int64) {
 	m.getSystemUids.Add(v)
 }
 
+// api key
+func (m *dbMetrics) AddOrUpdateApiKeyAdd(v int64) {
+	m.addOrUpdateApiKey.Add(v)
+}
+func (m *dbMetrics) RemoveApiKeyAdd(v int64) {
+	m.removeApiKey.Add(v)
+}
+func (m *dbMetrics) GetApiKeysAdd(v int64) {
+	m.getApiKeys.Add(v)
+}
+
+// 管理端用户
+func (m *dbMetrics) AddOrUpdateManagerUserAdd(v int64) {
+	m.addOrUpdateManagerUser.Add(v)
+}
+func (m *dbMetrics) RemoveManagerUserAdd(v int64) {
+	m.removeManagerUser.Add(v)
+}
+func (m *dbMetrics) GetManagerUsersAdd(v int64) {
+	m.getManagerUsers.Add(v)
+}
+
+// 消息可见性
+func (m *dbMetrics) HideMessagesAdd(v int64) {
+	m.hideMessages.Add(v)
+}
+func (m *dbMetrics) ClearMessagesAdd(v int64) {
+	m.clearMessages.Add(v)
+}
+func (m *dbMetrics) GetMessageVisibilityAdd(v int64) {
+	m.getMessageVisibility.Add(v)
+}
+
+// 端到端加密公钥
+func (m *dbMetrics) SaveE2eeKeyBundleAdd(v int64) {
+	m.saveE2eeKeyBundle.Add(v)
+}
+func (m *dbMetrics) GetE2eeKeyBundlesAdd(v int64) {
+	m.getE2eeKeyBundles.Add(v)
+}
+func (m *dbMetrics) GetE2eeOnetimePrekeysAdd(v int64) {
+	m.getE2eeOnetimePrekeys.Add(v)
+}
+func (m *dbMetrics) RemoveE2eeOnetimePrekeysAdd(v int64) {
+	m.removeE2eeOnetimePrekeys.Add(v)
+}
+func (m *dbMetrics) RemoveE2eeKeyBundleAdd(v int64) {
+	m.removeE2eeKeyBundle.Add(v)
+}
+
+// 消息保留
+func (m *dbMetrics) PurgeMessagesToAdd(v int64) {
+	m.purgeMessagesTo.Add(v)
+}
+func (m *dbMetrics) PurgedMessagesAdd(v int64) {
+	m.purgedMessages.Add(v)
+}
+
+// 冷存储
+func (m *dbMetrics) OffloadMessagesToAdd(v int64) {
+	m.offloadMessagesTo.Add(v)
+}
+func (m *dbMetrics) OffloadedMessagesAdd(v int64) {
+	m.offloadedMessages.Add(v)
+}
+func (m *dbMetrics) LoadColdSegmentAdd(v int64) {
+	m.loadColdSegment.Add(v)
+}
+func (m *dbMetrics) ColdSegmentCacheHitAdd(v int64) {
+	m.coldSegmentCacheHit.Add(v)
+}
+
+// 审计日志
+func (m *dbMetrics) AppendAuditLogsAdd(v int64) {
+	m.appendAuditLogs.Add(v)
+}
+func (m *dbMetrics) SearchAuditLogsAdd(v int64) {
+	m.searchAuditLogs.Add(v)
+}
+
 // 用户
 func (m *dbMetrics) GetUserAdd(v int64) {
 	m.getUser.Add(v)
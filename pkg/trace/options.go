@@ -2,6 +2,7 @@ package trace
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wklog"
@@ -18,6 +19,7 @@ type Options struct {
 	PrometheusApiUrl string
 	ReqTimeout       time.Duration
 
+	prometheusLock   sync.Mutex
 	prometheusClient api.Client // prometheus client
 	prometheusApi    v1.API
 }
@@ -63,24 +65,43 @@ func WithReqTimeout(timeout time.Duration) Option {
 	}
 }
 
-func (o *Options) requestPrometheus(query string, r v1.Range, opt ...v1.Option) (model.Value, error) {
+// setPrometheusApiUrl 修改prometheus地址，下次请求时使用新地址重新创建客户端
+func (o *Options) setPrometheusApiUrl(url string) {
+	o.prometheusLock.Lock()
+	defer o.prometheusLock.Unlock()
+	o.PrometheusApiUrl = url
+	o.prometheusClient = nil
+	o.prometheusApi = nil
+}
 
+func (o *Options) getPrometheusApi() (v1.API, error) {
+	o.prometheusLock.Lock()
+	defer o.prometheusLock.Unlock()
 	if o.prometheusClient == nil {
 		cli, err := api.NewClient(api.Config{
 			Address: o.PrometheusApiUrl,
 		})
 		if err != nil {
-			wklog.Error("create prometheus client failed", zap.Error(err))
 			return nil, err
 		}
 		o.prometheusClient = cli
 		v1api := v1.NewAPI(o.prometheusClient)
 		o.prometheusApi = v1api
 	}
+	return o.prometheusApi, nil
+}
+
+func (o *Options) requestPrometheus(query string, r v1.Range, opt ...v1.Option) (model.Value, error) {
+
+	prometheusApi, err := o.getPrometheusApi()
+	if err != nil {
+		wklog.Error("create prometheus client failed", zap.Error(err))
+		return nil, err
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), o.ReqTimeout)
 	defer cancel()
-	result, warnings, err := o.prometheusApi.QueryRange(ctx, query, r)
+	result, warnings, err := prometheusApi.QueryRange(ctx, query, r)
 	if err != nil {
 		wklog.Error("query prometheus failed", zap.Error(err))
 		return nil, err
@@ -58,6 +58,11 @@ func (t *Trace) Stop() {
 	}
 }
 
+// SetPrometheusApiUrl 修改查询监控数据的prometheus地址（重新加载配置时使用）
+func (t *Trace) SetPrometheusApiUrl(url string) {
+	t.opts.setPrometheusApiUrl(url)
+}
+
 func (t *Trace) Handler() http.Handler {
 	return promhttp.Handler()
 }
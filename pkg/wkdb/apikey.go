@@ -0,0 +1,196 @@
+package wkdb
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddOrUpdateApiKey(apiKey ApiKey) error {
+
+	wk.metrics.AddOrUpdateApiKeyAdd(1)
+
+	apiKey.Id = key.HashWithString(apiKey.Name)
+
+	w := wk.defaultShardDB().NewBatch()
+	defer w.Close()
+	if err := wk.writeApiKey(apiKey, w); err != nil {
+		return err
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) RemoveApiKey(name string) error {
+
+	wk.metrics.RemoveApiKeyAdd(1)
+
+	id := key.HashWithString(name)
+	w := wk.defaultShardDB().NewBatch()
+	defer w.Close()
+	if err := w.DeleteRange(key.NewApiKeyColumnKey(id, key.MinColumnKey), key.NewApiKeyColumnKey(id, key.MaxColumnKey), wk.noSync); err != nil {
+		return err
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) GetApiKey(name string) (ApiKey, error) {
+
+	wk.metrics.GetApiKeysAdd(1)
+
+	id := key.HashWithString(name)
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewApiKeyColumnKey(id, key.MinColumnKey),
+		UpperBound: key.NewApiKeyColumnKey(id, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var apiKey = EmptyApiKey
+	err := wk.iterApiKey(iter, func(a ApiKey) bool {
+		apiKey = a
+		return false
+	})
+	if err != nil {
+		return EmptyApiKey, err
+	}
+	if IsEmptyApiKey(apiKey) {
+		return EmptyApiKey, ErrNotFound
+	}
+	return apiKey, nil
+}
+
+func (wk *wukongDB) GetApiKeys() ([]ApiKey, error) {
+
+	wk.metrics.GetApiKeysAdd(1)
+
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewApiKeyColumnKey(0, key.MinColumnKey),
+		UpperBound: key.NewApiKeyColumnKey(math.MaxUint64, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var apiKeys []ApiKey
+	err := wk.iterApiKey(iter, func(a ApiKey) bool {
+		apiKeys = append(apiKeys, a)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return apiKeys, nil
+}
+
+func (wk *wukongDB) writeApiKey(a ApiKey, w pebble.Writer) error {
+	var err error
+	// name
+	if err = w.Set(key.NewApiKeyColumnKey(a.Id, key.TableApiKey.Column.Name), []byte(a.Name), wk.noSync); err != nil {
+		return err
+	}
+	// keyHash
+	if err = w.Set(key.NewApiKeyColumnKey(a.Id, key.TableApiKey.Column.KeyHash), []byte(a.KeyHash), wk.noSync); err != nil {
+		return err
+	}
+	// scopes
+	if err = w.Set(key.NewApiKeyColumnKey(a.Id, key.TableApiKey.Column.Scopes), []byte(strings.Join(a.Scopes, ",")), wk.noSync); err != nil {
+		return err
+	}
+	// disabled
+	var disabled uint8
+	if a.Disabled {
+		disabled = 1
+	}
+	if err = w.Set(key.NewApiKeyColumnKey(a.Id, key.TableApiKey.Column.Disabled), []byte{disabled}, wk.noSync); err != nil {
+		return err
+	}
+	// expireAt (没有过期时间时也要写入，覆盖掉旧的过期时间)
+	if err = wk.writeApiKeyTime(a.Id, key.TableApiKey.Column.ExpireAt, a.ExpireAt, w); err != nil {
+		return err
+	}
+	// createdAt
+	if a.CreatedAt != nil {
+		if err = wk.writeApiKeyTime(a.Id, key.TableApiKey.Column.CreatedAt, a.CreatedAt, w); err != nil {
+			return err
+		}
+	}
+	// updatedAt
+	if a.UpdatedAt != nil {
+		if err = wk.writeApiKeyTime(a.Id, key.TableApiKey.Column.UpdatedAt, a.UpdatedAt, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wk *wukongDB) writeApiKeyTime(id uint64, columnName [2]byte, t *time.Time, w pebble.Writer) error {
+	var tm uint64
+	if t != nil {
+		tm = uint64(t.UnixNano())
+	}
+	tmBytes := make([]byte, 8)
+	wk.endian.PutUint64(tmBytes, tm)
+	return w.Set(key.NewApiKeyColumnKey(id, columnName), tmBytes, wk.noSync)
+}
+
+func (wk *wukongDB) iterApiKey(iter *pebble.Iterator, iterFnc func(a ApiKey) bool) error {
+	var (
+		preId          uint64
+		preApiKey      ApiKey
+		lastNeedAppend bool = true
+		hasData        bool = false
+	)
+
+	parseTime := func(v []byte) *time.Time {
+		tm := int64(wk.endian.Uint64(v))
+		if tm <= 0 {
+			return nil
+		}
+		t := time.Unix(tm/1e9, tm%1e9)
+		return &t
+	}
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		primaryKey, columnName, err := key.ParseApiKeyColumnKey(iter.Key())
+		if err != nil {
+			return err
+		}
+
+		if preId != primaryKey {
+			if preId != 0 {
+				if !iterFnc(preApiKey) {
+					lastNeedAppend = false
+					break
+				}
+			}
+			preId = primaryKey
+			preApiKey = ApiKey{Id: primaryKey}
+		}
+
+		switch columnName {
+		case key.TableApiKey.Column.Name:
+			preApiKey.Name = string(iter.Value())
+		case key.TableApiKey.Column.KeyHash:
+			preApiKey.KeyHash = string(iter.Value())
+		case key.TableApiKey.Column.Scopes:
+			if len(iter.Value()) > 0 {
+				preApiKey.Scopes = strings.Split(string(iter.Value()), ",")
+			}
+		case key.TableApiKey.Column.Disabled:
+			preApiKey.Disabled = len(iter.Value()) > 0 && iter.Value()[0] == 1
+		case key.TableApiKey.Column.ExpireAt:
+			preApiKey.ExpireAt = parseTime(iter.Value())
+		case key.TableApiKey.Column.CreatedAt:
+			preApiKey.CreatedAt = parseTime(iter.Value())
+		case key.TableApiKey.Column.UpdatedAt:
+			preApiKey.UpdatedAt = parseTime(iter.Value())
+		}
+		lastNeedAppend = true
+		hasData = true
+	}
+
+	if lastNeedAppend && hasData {
+		_ = iterFnc(preApiKey)
+	}
+	return nil
+}
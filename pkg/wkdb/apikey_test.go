@@ -0,0 +1,73 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddOrUpdateAndGetApiKeys(t *testing.T) {
+
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	createdAt := time.Now()
+	expireAt := createdAt.Add(time.Hour)
+	err = d.AddOrUpdateApiKey(wkdb.ApiKey{
+		Name:      "im-service",
+		KeyHash:   "hash1",
+		Scopes:    []string{"message:send", "user:token"},
+		ExpireAt:  &expireAt,
+		CreatedAt: &createdAt,
+		UpdatedAt: &createdAt,
+	})
+	assert.NoError(t, err)
+
+	err = d.AddOrUpdateApiKey(wkdb.ApiKey{
+		Name:    "readonly",
+		KeyHash: "hash2",
+		Scopes:  []string{"read"},
+	})
+	assert.NoError(t, err)
+
+	apiKey, err := d.GetApiKey("im-service")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash1", apiKey.KeyHash)
+	assert.Equal(t, []string{"message:send", "user:token"}, apiKey.Scopes)
+	assert.Equal(t, expireAt.UnixNano(), apiKey.ExpireAt.UnixNano())
+	assert.False(t, apiKey.Disabled)
+
+	// 禁用
+	apiKey.Disabled = true
+	apiKey.ExpireAt = nil
+	err = d.AddOrUpdateApiKey(apiKey)
+	assert.NoError(t, err)
+
+	apiKey, err = d.GetApiKey("im-service")
+	assert.NoError(t, err)
+	assert.True(t, apiKey.Disabled)
+	assert.Nil(t, apiKey.ExpireAt)
+
+	apiKeys, err := d.GetApiKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(apiKeys))
+
+	err = d.RemoveApiKey("im-service")
+	assert.NoError(t, err)
+
+	_, err = d.GetApiKey("im-service")
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	apiKeys, err = d.GetApiKeys()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(apiKeys))
+	assert.Equal(t, "readonly", apiKeys[0].Name)
+}
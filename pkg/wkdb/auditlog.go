@@ -0,0 +1,93 @@
+package wkdb
+
+import (
+	"math"
+	"strings"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+)
+
+// AppendAuditLogs 追加审计日志（审计日志只追加，相同id的日志重复写入会被覆盖，保证重放幂等）
+func (wk *wukongDB) AppendAuditLogs(logs []AuditLog) error {
+
+	wk.metrics.AppendAuditLogsAdd(1)
+
+	batch := wk.defaultShardDB().NewBatch()
+	defer batch.Close()
+	for _, log := range logs {
+		data, err := log.Marshal()
+		if err != nil {
+			return err
+		}
+		if err = batch.Set(key.NewAuditLogKey(log.Id), data, wk.noSync); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(wk.sync)
+}
+
+// SearchAuditLogs 搜索审计日志（按id倒序，即最新的在前）
+func (wk *wukongDB) SearchAuditLogs(req AuditLogSearchReq) ([]AuditLog, error) {
+
+	wk.metrics.SearchAuditLogsAdd(1)
+
+	upperId := uint64(math.MaxUint64)
+	if req.OffsetId > 0 {
+		upperId = req.OffsetId
+	}
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewAuditLogKey(0),
+		UpperBound: key.NewAuditLogKey(upperId),
+	})
+	defer iter.Close()
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	logs := make([]AuditLog, 0, limit)
+	for iter.Last(); iter.Valid(); iter.Prev() {
+		var log AuditLog
+		if err := log.Unmarshal(iter.Value()); err != nil {
+			return nil, err
+		}
+		if log.CreatedAt != nil {
+			if req.EndAt > 0 && log.CreatedAt.UnixNano() > req.EndAt {
+				continue
+			}
+			if req.StartAt > 0 && log.CreatedAt.UnixNano() < req.StartAt {
+				// id按时间递增，后面的日志更早，无需再找
+				break
+			}
+		}
+		if !req.match(log) {
+			continue
+		}
+		logs = append(logs, log)
+		if len(logs) >= limit {
+			break
+		}
+	}
+	return logs, nil
+}
+
+func (r AuditLogSearchReq) match(log AuditLog) bool {
+	if r.Action != "" && log.Action != r.Action && !strings.HasPrefix(log.Action, r.Action+".") {
+		return false
+	}
+	if r.Actor != "" && log.Actor != r.Actor {
+		return false
+	}
+	if r.Result != "" && log.Result != r.Result {
+		return false
+	}
+	if r.Target != "" && !strings.Contains(log.Target, r.Target) {
+		return false
+	}
+	if r.SourceIp != "" && log.SourceIp != r.SourceIp {
+		return false
+	}
+	return true
+}
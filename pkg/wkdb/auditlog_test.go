@@ -0,0 +1,61 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAppendAndSearchAuditLogs(t *testing.T) {
+
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	now := time.Now()
+	t1 := now.Add(-time.Minute * 2)
+	t2 := now.Add(-time.Minute)
+	err = d.AppendAuditLogs([]wkdb.AuditLog{
+		{Id: 1, Action: "channel.delete", Actor: "admin", ActorType: "manager", Target: "channel_id=g1 channel_type=2", Result: "success", Status: 200, CreatedAt: &t1},
+		{Id: 2, Action: "user.device_quit", Actor: "im-service", ActorType: "apiKey", Target: "uid=u1", Result: "failure", Status: 400, Reason: "用户不存在", CreatedAt: &t2},
+		{Id: 3, Action: "channel.blacklist_add", Actor: "admin", ActorType: "manager", Target: "channel_id=g1 channel_type=2 uids=[u2]", Result: "success", Status: 200, CreatedAt: &now},
+	})
+	assert.NoError(t, err)
+
+	// 最新的在前
+	logs, err := d.SearchAuditLogs(wkdb.AuditLogSearchReq{})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(logs))
+	assert.Equal(t, uint64(3), logs[0].Id)
+	assert.Equal(t, "用户不存在", logs[1].Reason)
+	assert.Equal(t, now.UnixNano(), logs[0].CreatedAt.UnixNano())
+
+	// 按操作前缀过滤
+	logs, err = d.SearchAuditLogs(wkdb.AuditLogSearchReq{Action: "channel"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+
+	// 按对象和结果过滤
+	logs, err = d.SearchAuditLogs(wkdb.AuditLogSearchReq{Target: "g1", Result: "success", Actor: "admin"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(logs))
+
+	// 分页
+	logs, err = d.SearchAuditLogs(wkdb.AuditLogSearchReq{OffsetId: 3, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, uint64(2), logs[0].Id)
+
+	// 时间范围
+	logs, err = d.SearchAuditLogs(wkdb.AuditLogSearchReq{StartAt: t2.UnixNano(), EndAt: t2.UnixNano()})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(logs))
+	assert.Equal(t, uint64(2), logs[0].Id)
+}
@@ -0,0 +1,126 @@
+package wkdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore 冷存储的对象存储接口，消息段写入后不会再修改，所以不需要支持追加和部分更新
+type BlobStore interface {
+	// Put 写入对象（相同key覆盖）
+	Put(ctx context.Context, key string, data []byte) error
+	// Get 获取对象，不存在返回ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete 删除对象，不存在不返回错误
+	Delete(ctx context.Context, key string) error
+}
+
+// LocalBlobStore 以本地目录作为冷存储（可以是挂载的大容量磁盘或网络文件系统）
+type LocalBlobStore struct {
+	dir string
+}
+
+func NewLocalBlobStore(dir string) *LocalBlobStore {
+	return &LocalBlobStore{
+		dir: dir,
+	}
+}
+
+func (l *LocalBlobStore) Put(ctx context.Context, key string, data []byte) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	// 先写临时文件再重命名，避免读到写了一半的对象
+	tmp := p + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p)
+}
+
+func (l *LocalBlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	p, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (l *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *LocalBlobStore) path(key string) (string, error) {
+	cleanKey := path.Clean("/" + key)
+	if cleanKey == "/" || strings.Contains(key, "..") {
+		return "", fmt.Errorf("invalid blob key: %s", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(cleanKey)), nil
+}
+
+// S3Client S3兼容的对象存储客户端（aws s3、minio、阿里云oss等），可以用任意sdk实现，测试时可以用内存实现代替
+type S3Client interface {
+	// PutObject 写入对象
+	PutObject(ctx context.Context, bucket string, key string, data []byte) error
+	// GetObject 获取对象，不存在返回ErrNotFound
+	GetObject(ctx context.Context, bucket string, key string) ([]byte, error)
+	// DeleteObject 删除对象
+	DeleteObject(ctx context.Context, bucket string, key string) error
+}
+
+// S3BlobStore 以S3兼容的对象存储作为冷存储
+type S3BlobStore struct {
+	client S3Client
+	bucket string
+	prefix string // 对象key的前缀，多个集群共用一个bucket时区分
+}
+
+func NewS3BlobStore(client S3Client, bucket string, prefix string) *S3BlobStore {
+	return &S3BlobStore{
+		client: client,
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.client.PutObject(ctx, s.bucket, s.objectKey(key), data)
+}
+
+func (s *S3BlobStore) Get(ctx context.Context, key string) ([]byte, error) {
+	return s.client.GetObject(ctx, s.bucket, s.objectKey(key))
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	return s.client.DeleteObject(ctx, s.bucket, s.objectKey(key))
+}
+
+func (s *S3BlobStore) objectKey(key string) string {
+	if s.prefix == "" {
+		return key
+	}
+	return s.prefix + "/" + key
+}
@@ -0,0 +1,75 @@
+package wkdb
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type S3Options struct {
+	Endpoint  string // 服务地址 为空表示aws s3
+	Region    string // 区域
+	AccessKey string
+	SecretKey string
+	PathStyle bool // 是否使用path风格的地址（minio等自建服务一般需要开启）
+}
+
+type awsS3Client struct {
+	client *s3.S3
+}
+
+// NewAwsS3Client 基于aws sdk的S3兼容客户端
+func NewAwsS3Client(opts S3Options) (S3Client, error) {
+	cfg := aws.NewConfig().WithRegion(opts.Region).WithS3ForcePathStyle(opts.PathStyle)
+	if opts.Endpoint != "" {
+		cfg = cfg.WithEndpoint(opts.Endpoint)
+	}
+	if opts.AccessKey != "" {
+		cfg = cfg.WithCredentials(credentials.NewStaticCredentials(opts.AccessKey, opts.SecretKey, ""))
+	}
+	sess, err := session.NewSession(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &awsS3Client{
+		client: s3.New(sess),
+	}, nil
+}
+
+func (a *awsS3Client) PutObject(ctx context.Context, bucket string, key string, data []byte) error {
+	_, err := a.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (a *awsS3Client) GetObject(ctx context.Context, bucket string, key string) ([]byte, error) {
+	resp, err := a.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if aerr, ok := err.(awserr.Error); ok && aerr.Code() == s3.ErrCodeNoSuchKey {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (a *awsS3Client) DeleteObject(ctx context.Context, bucket string, key string) error {
+	_, err := a.client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	return err
+}
@@ -425,6 +425,12 @@ func (wk *wukongDB) DeleteChannel(channelId string, channelType uint8) error {
 		return err
 	}
 
+	// 删除订阅者的加入/离开记录
+	err = batch.DeleteRange(key.NewSubscriberHistoryColumnKey(channelId, channelType, 0, key.MinColumnKey), key.NewSubscriberHistoryColumnKey(channelId, channelType, math.MaxUint64, key.MaxColumnKey), wk.noSync)
+	if err != nil {
+		return err
+	}
+
 	err = wk.IncChannelCount(-1)
 	if err != nil {
 		return err
@@ -536,6 +542,45 @@ func (wk *wukongDB) writeChannelInfo(primaryKey uint64, channelInfo ChannelInfo,
 		return err
 	}
 
+	// sendMode
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.SendMode), []byte{uint8(channelInfo.SendMode)}, wk.noSync); err != nil {
+		return err
+	}
+
+	// historyVisibility
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.HistoryVisibility), []byte{uint8(channelInfo.HistoryVisibility)}, wk.noSync); err != nil {
+		return err
+	}
+
+	// historyVisibleCount
+	historyVisibleCountBytes := make([]byte, 4)
+	wk.endian.PutUint32(historyVisibleCountBytes, channelInfo.HistoryVisibleCount)
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.HistoryVisibleCount), historyVisibleCountBytes, wk.noSync); err != nil {
+		return err
+	}
+
+	// e2ee
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.E2ee), []byte{wkutil.BoolToUint8(channelInfo.E2ee)}, wk.noSync); err != nil {
+		return err
+	}
+
+	// retention
+	retentionMaxAgeBytes := make([]byte, 8)
+	wk.endian.PutUint64(retentionMaxAgeBytes, uint64(channelInfo.RetentionMaxAge))
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.RetentionMaxAge), retentionMaxAgeBytes, wk.noSync); err != nil {
+		return err
+	}
+	retentionMaxCountBytes := make([]byte, 8)
+	wk.endian.PutUint64(retentionMaxCountBytes, uint64(channelInfo.RetentionMaxCount))
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.RetentionMaxCount), retentionMaxCountBytes, wk.noSync); err != nil {
+		return err
+	}
+	retentionMaxBytesBytes := make([]byte, 8)
+	wk.endian.PutUint64(retentionMaxBytesBytes, uint64(channelInfo.RetentionMaxBytes))
+	if err = w.Set(key.NewChannelInfoColumnKey(primaryKey, key.TableChannelInfo.Column.RetentionMaxBytes), retentionMaxBytesBytes, wk.noSync); err != nil {
+		return err
+	}
+
 	// createdAt
 	if channelInfo.CreatedAt != nil {
 		ct := uint64(channelInfo.CreatedAt.UnixNano())
@@ -688,6 +733,20 @@ func (wk *wukongDB) iterChannelInfo(iter *pebble.Iterator, iterFnc func(channelI
 			preChannelInfo.Large = wkutil.Uint8ToBool(iter.Value()[0])
 		case key.TableChannelInfo.Column.Disband:
 			preChannelInfo.Disband = wkutil.Uint8ToBool(iter.Value()[0])
+		case key.TableChannelInfo.Column.SendMode:
+			preChannelInfo.SendMode = SendMode(iter.Value()[0])
+		case key.TableChannelInfo.Column.HistoryVisibility:
+			preChannelInfo.HistoryVisibility = HistoryVisibility(iter.Value()[0])
+		case key.TableChannelInfo.Column.HistoryVisibleCount:
+			preChannelInfo.HistoryVisibleCount = wk.endian.Uint32(iter.Value())
+		case key.TableChannelInfo.Column.E2ee:
+			preChannelInfo.E2ee = wkutil.Uint8ToBool(iter.Value()[0])
+		case key.TableChannelInfo.Column.RetentionMaxAge:
+			preChannelInfo.RetentionMaxAge = int64(wk.endian.Uint64(iter.Value()))
+		case key.TableChannelInfo.Column.RetentionMaxCount:
+			preChannelInfo.RetentionMaxCount = int64(wk.endian.Uint64(iter.Value()))
+		case key.TableChannelInfo.Column.RetentionMaxBytes:
+			preChannelInfo.RetentionMaxBytes = int64(wk.endian.Uint64(iter.Value()))
 		case key.TableChannelInfo.Column.SubscriberCount:
 			preChannelInfo.SubscriberCount = int(wk.endian.Uint32(iter.Value()))
 		case key.TableChannelInfo.Column.AllowlistCount:
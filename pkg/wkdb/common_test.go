@@ -9,6 +9,10 @@ import (
 )
 
 func newTestDB(t testing.TB) wkdb.DB {
+	return newTestDBWithOptions(t)
+}
+
+func newTestDBWithOptions(t testing.TB, opts ...wkdb.Option) wkdb.DB {
 	dr := t.TempDir()
 
 	traceObj := trace.New(
@@ -19,5 +23,6 @@ func newTestDB(t testing.TB) wkdb.DB {
 		))
 	trace.SetGlobalTrace(traceObj)
 
-	return wkdb.NewWukongDB(wkdb.NewOptions(wkdb.WithDir(dr), wkdb.WithShardNum(1)))
+	opts = append([]wkdb.Option{wkdb.WithDir(dr), wkdb.WithShardNum(1)}, opts...)
+	return wkdb.NewWukongDB(wkdb.NewOptions(opts...))
 }
@@ -0,0 +1,276 @@
+package wkdb
+
+import (
+	"encoding/binary"
+	"hash/crc64"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+// Checksum 与顺序无关的校验和（每条数据的crc64之和），用于比较两份数据是否一致
+type Checksum struct {
+	Count uint64 `json:"count"` // 数据条数
+	Sum   uint64 `json:"sum"`   // 校验和
+}
+
+// Add 添加一条数据，fields为数据的各个字段
+func (c *Checksum) Add(fields ...[]byte) {
+	var lenBytes [4]byte
+	h := crc64.New(crc64Table)
+	for _, field := range fields {
+		binary.BigEndian.PutUint32(lenBytes[:], uint32(len(field)))
+		_, _ = h.Write(lenBytes[:])
+		_, _ = h.Write(field)
+	}
+	c.Count++
+	c.Sum += h.Sum64()
+}
+
+// 消息的校验和只包含由副本日志复制的字段（不包含本地的统计类数据）
+func (c *Checksum) addMessage(m Message) {
+	var buf [8 + 8 + 8 + 4 + 1]byte
+	binary.BigEndian.PutUint64(buf[0:], uint64(m.MessageSeq))
+	binary.BigEndian.PutUint64(buf[8:], uint64(m.MessageID))
+	binary.BigEndian.PutUint64(buf[16:], m.Term)
+	binary.BigEndian.PutUint32(buf[24:], uint32(m.Timestamp))
+	buf[28] = m.Setting.Uint8()
+	c.Add(buf[:], []byte(m.ClientMsgNo), []byte(m.FromUID), []byte(m.ChannelID), []byte(m.StreamNo), []byte(m.Topic), m.Payload)
+}
+
+// MessageChecksumBucket 一段连续消息seq的校验和
+type MessageChecksumBucket struct {
+	StartSeq uint64 `json:"start_seq"` // 开始seq（包含）
+	EndSeq   uint64 `json:"end_seq"`   // 结束seq（包含）
+	Checksum
+}
+
+// SlotStateChecksum 槽的状态数据的校验和（只包含由槽日志复制的数据，不包含在线数、消息数等本地统计）
+type SlotStateChecksum struct {
+	Users         Checksum `json:"users"`         // 用户（uid）
+	Channels      Checksum `json:"channels"`      // 频道信息（频道的设置）
+	Subscribers   Checksum `json:"subscribers"`   // 订阅者（频道 + uid以及角色、禁言等设置）
+	Conversations Checksum `json:"conversations"` // 最近会话（uid + 频道）
+}
+
+func (wk *wukongDB) GetChannelFirstLocalMessageSeq(channelId string, channelType uint8) (uint64, error) {
+	return wk.firstHotMessageSeq(channelId, channelType)
+}
+
+func (wk *wukongDB) ChannelMessageChecksums(channelId string, channelType uint8, startSeq, endSeq uint64, bucketSize uint64) ([]MessageChecksumBucket, error) {
+	if startSeq == 0 {
+		startSeq = 1
+	}
+	if bucketSize == 0 || endSeq < startSeq || endSeq == ^uint64(0) {
+		return nil, nil
+	}
+
+	bucketCount := (endSeq-startSeq)/bucketSize + 1
+	buckets := make([]MessageChecksumBucket, bucketCount)
+	for i := range buckets {
+		start := startSeq + uint64(i)*bucketSize
+		end := start + bucketSize - 1
+		if end > endSeq {
+			end = endSeq
+		}
+		buckets[i].StartSeq = start
+		buckets[i].EndSeq = end
+	}
+
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessagePrimaryKey(channelId, channelType, startSeq),
+		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, endSeq+1),
+	})
+	defer iter.Close()
+
+	err := wk.iteratorChannelMessages(iter, 0, func(m Message) bool {
+		seq := uint64(m.MessageSeq)
+		if seq < startSeq || seq > endSeq {
+			return true
+		}
+		buckets[(seq-startSeq)/bucketSize].addMessage(m)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return buckets, nil
+}
+
+func (wk *wukongDB) SlotStateChecksums(slotOf func(v string) uint32, slotIds []uint32) (map[uint32]*SlotStateChecksum, error) {
+	results := make(map[uint32]*SlotStateChecksum)
+	for _, slotId := range slotIds {
+		results[slotId] = &SlotStateChecksum{}
+	}
+	// 获取槽的校验和，不需要的槽返回nil
+	slotChecksum := func(v string) *SlotStateChecksum {
+		slotId := slotOf(v)
+		result := results[slotId]
+		if result == nil && len(slotIds) == 0 {
+			result = &SlotStateChecksum{}
+			results[slotId] = result
+		}
+		return result
+	}
+
+	// 订阅者的key中只有频道的hash，需要通过频道信息、分布式配置、最近会话、消息找到频道id
+	channelIds := make(map[uint64]string)
+
+	for _, db := range wk.dbs {
+		err := wk.checksumUsers(db, slotChecksum)
+		if err != nil {
+			return nil, err
+		}
+		err = wk.checksumChannelInfos(db, slotChecksum, channelIds)
+		if err != nil {
+			return nil, err
+		}
+		err = wk.checksumConversations(db, slotChecksum, channelIds)
+		if err != nil {
+			return nil, err
+		}
+		cfgIter := newTableIter(db, key.TableChannelClusterConfig.Id)
+		err = wk.iteratorChannelClusterConfig(cfgIter, func(cfg ChannelClusterConfig) bool {
+			channelIds[key.ChannelToNum(cfg.ChannelId, cfg.ChannelType)] = cfg.ChannelId
+			return true
+		})
+		_ = cfgIter.Close()
+		if err != nil {
+			return nil, err
+		}
+		_, err = wk.iterateMessageChannelsOfDb(db, func(channelId string, channelType uint8, lastMsgSeq uint64) bool {
+			channelIds[key.ChannelToNum(channelId, channelType)] = channelId
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var unresolved uint64
+	for _, db := range wk.dbs {
+		count, err := wk.checksumSubscribers(db, slotChecksum, channelIds)
+		if err != nil {
+			return nil, err
+		}
+		unresolved += count
+	}
+	if unresolved > 0 {
+		wk.Warn("subscribers of unknown channel are not checksummed", zap.Uint64("count", unresolved))
+	}
+	return results, nil
+}
+
+func (wk *wukongDB) checksumUsers(db *pebble.DB, slotChecksum func(v string) *SlotStateChecksum) error {
+	iter := newTableIter(db, key.TableUser.Id)
+	defer iter.Close()
+	return wk.iteratorUser(iter, func(u User) bool {
+		if u.Uid == "" {
+			return true
+		}
+		if result := slotChecksum(u.Uid); result != nil {
+			result.Users.Add([]byte(u.Uid))
+		}
+		return true
+	})
+}
+
+func (wk *wukongDB) checksumChannelInfos(db *pebble.DB, slotChecksum func(v string) *SlotStateChecksum, channelIds map[uint64]string) error {
+	iter := newTableIter(db, key.TableChannelInfo.Id)
+	defer iter.Close()
+	return wk.iterChannelInfo(iter, func(ch ChannelInfo) bool {
+		if ch.ChannelId == "" {
+			return true
+		}
+		channelIds[key.ChannelToNum(ch.ChannelId, ch.ChannelType)] = ch.ChannelId
+
+		result := slotChecksum(ch.ChannelId)
+		if result == nil {
+			return true
+		}
+		var buf [1 + 1 + 1 + 1 + 1 + 1 + 4 + 1 + 8 + 8 + 8]byte
+		buf[0] = ch.ChannelType
+		buf[1] = boolToByte(ch.Ban)
+		buf[2] = boolToByte(ch.Large)
+		buf[3] = boolToByte(ch.Disband)
+		buf[4] = uint8(ch.SendMode)
+		buf[5] = uint8(ch.HistoryVisibility)
+		binary.BigEndian.PutUint32(buf[6:], ch.HistoryVisibleCount)
+		buf[10] = boolToByte(ch.E2ee)
+		binary.BigEndian.PutUint64(buf[11:], uint64(ch.RetentionMaxAge))
+		binary.BigEndian.PutUint64(buf[19:], uint64(ch.RetentionMaxCount))
+		binary.BigEndian.PutUint64(buf[27:], uint64(ch.RetentionMaxBytes))
+		result.Channels.Add([]byte(ch.ChannelId), buf[:], []byte(ch.Webhook))
+		return true
+	})
+}
+
+func (wk *wukongDB) checksumConversations(db *pebble.DB, slotChecksum func(v string) *SlotStateChecksum, channelIds map[uint64]string) error {
+	iter := newTableIter(db, key.TableConversation.Id)
+	defer iter.Close()
+	return wk.iterateConversation(iter, func(cn Conversation) bool {
+		if cn.Uid == "" {
+			return true
+		}
+		channelIds[key.ChannelToNum(cn.ChannelId, cn.ChannelType)] = cn.ChannelId
+
+		if result := slotChecksum(cn.Uid); result != nil {
+			result.Conversations.Add([]byte(cn.Uid), []byte(cn.ChannelId), []byte{cn.ChannelType, uint8(cn.Type)})
+		}
+		return true
+	})
+}
+
+// 订阅者按行（频道hash + 行id）计算校验和，返回找不到频道的订阅者数量
+func (wk *wukongDB) checksumSubscribers(db *pebble.DB, slotChecksum func(v string) *SlotStateChecksum, channelIds map[uint64]string) (uint64, error) {
+	iter := newTableIter(db, key.TableSubscriber.Id)
+	defer iter.Close()
+
+	var (
+		unresolved uint64
+		preRow     []byte
+		fields     [][]byte
+	)
+	flush := func() {
+		if preRow == nil {
+			return
+		}
+		channelId, ok := channelIds[binary.BigEndian.Uint64(preRow[:8])]
+		if !ok {
+			unresolved++
+			return
+		}
+		if result := slotChecksum(channelId); result != nil {
+			result.Subscribers.Add(append([][]byte{preRow[:8]}, fields...)...)
+		}
+	}
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		k := iter.Key()
+		if len(k) != key.TableSubscriber.Size {
+			continue
+		}
+		row := k[4:20]
+		if preRow == nil || string(row) != string(preRow) {
+			flush()
+			preRow = append([]byte(nil), row...)
+			fields = fields[:0]
+		}
+		switch [2]byte{k[20], k[21]} {
+		case key.TableSubscriber.Column.Uid, key.TableSubscriber.Column.Role, key.TableSubscriber.Column.MuteUntil, key.TableSubscriber.Column.JoinSource, key.TableSubscriber.Column.Attributes:
+			fields = append(fields, []byte{k[20], k[21]}, append([]byte(nil), iter.Value()...))
+		}
+	}
+	flush()
+	return unresolved, iter.Error()
+}
+
+func boolToByte(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
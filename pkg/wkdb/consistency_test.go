@@ -0,0 +1,128 @@
+package wkdb_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelMessageChecksums(t *testing.T) {
+	d1 := newTestDB(t)
+	assert.NoError(t, d1.Open())
+	defer d1.Close()
+	d2 := newTestDBWithOptions(t, wkdb.WithShardNum(2))
+	assert.NoError(t, d2.Open())
+	defer d2.Close()
+
+	channelId := "channel1"
+	var channelType uint8 = 2
+	appendTestMessages(t, d1, channelId, channelType, 25)
+	appendTestMessages(t, d2, channelId, channelType, 25)
+
+	buckets1, err := d1.ChannelMessageChecksums(channelId, channelType, 1, 25, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(buckets1))
+	assert.Equal(t, uint64(21), buckets1[2].StartSeq)
+	assert.Equal(t, uint64(25), buckets1[2].EndSeq)
+	assert.Equal(t, uint64(5), buckets1[2].Count)
+
+	buckets2, err := d2.ChannelMessageChecksums(channelId, channelType, 1, 25, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, buckets1, buckets2)
+
+	// 第15条消息不一致
+	assert.NoError(t, d2.TruncateLogTo(channelId, channelType, 15))
+	assert.NoError(t, d2.AppendMessages(channelId, channelType, []wkdb.Message{{
+		RecvPacket: wkproto.RecvPacket{
+			MessageID:   1014,
+			ClientMsgNo: "clientMsgNo",
+			ChannelID:   channelId,
+			ChannelType: channelType,
+			FromUID:     "uid1",
+			MessageSeq:  15,
+			Timestamp:   114,
+			Payload:     []byte("diverged"),
+		},
+	}}))
+
+	buckets2, err = d2.ChannelMessageChecksums(channelId, channelType, 1, 25, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, buckets1[0], buckets2[0])
+	assert.NotEqual(t, buckets1[1], buckets2[1])
+	assert.Equal(t, uint64(0), buckets2[2].Count)
+
+	seqs1, err := d1.ChannelMessageChecksums(channelId, channelType, 11, 20, 1)
+	assert.NoError(t, err)
+	seqs2, err := d2.ChannelMessageChecksums(channelId, channelType, 11, 20, 1)
+	assert.NoError(t, err)
+	for i := range seqs1 {
+		if seqs1[i] != seqs2[i] {
+			assert.Equal(t, uint64(15), seqs1[i].StartSeq)
+			break
+		}
+	}
+}
+
+func TestSlotStateChecksums(t *testing.T) {
+	var slotCount = 8
+	slotOf := func(v string) uint32 {
+		return wkutil.GetSlotNum(slotCount, v)
+	}
+
+	fill := func(d wkdb.DB) {
+		tn := time.Now()
+		for i := 0; i < 10; i++ {
+			uid := fmt.Sprintf("uid%d", i)
+			channelId := fmt.Sprintf("channel%d", i)
+			assert.NoError(t, d.AddUser(wkdb.User{Uid: uid, CreatedAt: &tn, UpdatedAt: &tn}))
+			_, err := d.AddChannel(wkdb.ChannelInfo{ChannelId: channelId, ChannelType: 2, CreatedAt: &tn, UpdatedAt: &tn})
+			assert.NoError(t, err)
+			assert.NoError(t, d.AddSubscribers(channelId, 2, []wkdb.Member{{Id: d.NextPrimaryKey(), Uid: uid, CreatedAt: &tn, UpdatedAt: &tn}}))
+			assert.NoError(t, d.AddOrUpdateConversations([]wkdb.Conversation{{Id: d.NextPrimaryKey(), Uid: uid, ChannelId: channelId, ChannelType: 2, CreatedAt: &tn, UpdatedAt: &tn}}))
+		}
+	}
+
+	d1 := newTestDB(t)
+	assert.NoError(t, d1.Open())
+	defer d1.Close()
+	d2 := newTestDBWithOptions(t, wkdb.WithShardNum(2))
+	assert.NoError(t, d2.Open())
+	defer d2.Close()
+
+	// 主键不同，数据相同
+	d2.NextPrimaryKey()
+	fill(d1)
+	fill(d2)
+
+	all1, err := d1.SlotStateChecksums(slotOf, nil)
+	assert.NoError(t, err)
+	all2, err := d2.SlotStateChecksums(slotOf, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, all1, all2)
+
+	var users uint64
+	for _, sum := range all1 {
+		users += sum.Users.Count
+	}
+	assert.Equal(t, uint64(10), users)
+
+	// 多出一个订阅者
+	tn := time.Now()
+	assert.NoError(t, d2.AddSubscribers("channel1", 2, []wkdb.Member{{Id: d2.NextPrimaryKey(), Uid: "extra", CreatedAt: &tn, UpdatedAt: &tn}}))
+
+	slotId := slotOf("channel1")
+	sums1, err := d1.SlotStateChecksums(slotOf, []uint32{slotId})
+	assert.NoError(t, err)
+	sums2, err := d2.SlotStateChecksums(slotOf, []uint32{slotId})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(sums2))
+	assert.Equal(t, sums1[slotId].Users, sums2[slotId].Users)
+	assert.Equal(t, sums1[slotId].Channels, sums2[slotId].Channels)
+	assert.NotEqual(t, sums1[slotId].Subscribers, sums2[slotId].Subscribers)
+	assert.Equal(t, sums1[slotId].Subscribers.Count+1, sums2[slotId].Subscribers.Count)
+}
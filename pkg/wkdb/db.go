@@ -29,6 +29,22 @@ type DB interface {
 	SystemUidDB
 	// 流
 	StreamDB
+	// api key
+	ApiKeyDB
+	// 审计日志
+	AuditLogDB
+	// 管理端用户
+	ManagerUserDB
+	// 用户的消息可见性
+	MessageVisibilityDB
+	// 端到端加密公钥
+	E2eeKeyDB
+	// 消息保留（过期消息清理）
+	MessageRetentionDB
+	// 冷存储
+	MessageColdDB
+	// 副本一致性校验
+	ConsistencyDB
 }
 
 type MessageDB interface {
@@ -134,6 +150,18 @@ type ChannelDB interface {
 	// GetSubscribers 获取订阅者
 	GetSubscribers(channelId string, channelType uint8) ([]Member, error)
 
+	// GetSubscriber 获取单个订阅者，不存在返回ErrNotFound
+	GetSubscriber(channelId string, channelType uint8, uid string) (Member, error)
+
+	// UpdateSubscribers 更新订阅者的角色、禁言、加入来源和自定义属性
+	UpdateSubscribers(channelId string, channelType uint8, members []Member) error
+
+	// RecordSubscriberHistories 记录订阅者在消息序号seq时的加入/离开，重新加入时追加新的范围，之前的范围会被保留
+	RecordSubscriberHistories(channelId string, channelType uint8, seq uint64, joins []string, leaves []string) error
+
+	// GetSubscriberHistory 获取订阅者的加入/离开记录，不存在返回ErrNotFound
+	GetSubscriberHistory(channelId string, channelType uint8, uid string) (SubscriberHistory, error)
+
 	// AddOrUpdateChannel  添加或更新channel
 	AddChannel(channelInfo ChannelInfo) (uint64, error)
 	// UpdateChannel 更新channel
@@ -269,6 +297,8 @@ type LeaderTermSequenceDB interface {
 	LeaderLastTermGreaterThan(shardNo string, term uint32) (uint32, error)
 	// DeleteLeaderTermStartIndexGreaterThanTerm 删除比传入的term大的的LeaderTermStartIndex记录
 	DeleteLeaderTermStartIndexGreaterThanTerm(shardNo string, term uint32) error
+	// LeaderTermStartIndexes 获取所有领导任期开始的第一条日志索引（按任期升序）
+	LeaderTermStartIndexes(shardNo string) ([]LeaderTermStartIndex, error)
 }
 
 // type SessionDB interface {
@@ -354,6 +384,92 @@ type SystemUidDB interface {
 	GetSystemUids() ([]string, error)
 }
 
+type ApiKeyDB interface {
+	// AddOrUpdateApiKey 添加或更新api key
+	AddOrUpdateApiKey(apiKey ApiKey) error
+	// RemoveApiKey 移除api key
+	RemoveApiKey(name string) error
+	// GetApiKey 获取指定名称的api key
+	GetApiKey(name string) (ApiKey, error)
+	// GetApiKeys 获取所有api key
+	GetApiKeys() ([]ApiKey, error)
+}
+
+type ManagerUserDB interface {
+	// AddOrUpdateManagerUser 添加或更新管理端用户
+	AddOrUpdateManagerUser(user ManagerUser) error
+	// RemoveManagerUser 移除管理端用户
+	RemoveManagerUser(username string) error
+	// GetManagerUser 获取指定用户名的管理端用户
+	GetManagerUser(username string) (ManagerUser, error)
+	// GetManagerUsers 获取所有管理端用户
+	GetManagerUsers() ([]ManagerUser, error)
+}
+
+type MessageVisibilityDB interface {
+	// HideMessages 对用户隐藏频道内的消息（仅对自己删除）
+	HideMessages(channelId string, channelType uint8, uid string, messages []HiddenMessage, updatedAt int64) error
+	// ClearMessages 对用户清空频道内小于等于clearedToSeq的消息，清空序号只会增大，清空范围内隐藏的消息记录会被删除
+	ClearMessages(channelId string, channelType uint8, uid string, clearedToSeq uint64, updatedAt int64) error
+	// GetMessageVisibility 获取用户在频道内的消息可见性（不包含隐藏的消息），没有数据时返回空的可见性
+	GetMessageVisibility(channelId string, channelType uint8, uid string) (MessageVisibility, error)
+	// GetHiddenMessageIds 获取用户在频道内消息序号范围 [startSeq,endSeq] 内隐藏的消息ID
+	GetHiddenMessageIds(channelId string, channelType uint8, uid string, startSeq, endSeq uint64) ([]int64, error)
+}
+
+type E2eeKeyDB interface {
+	// SaveE2eeKeyBundle 保存用户设备的公钥包，并追加一次性预共享公钥（相同keyId的覆盖）
+	SaveE2eeKeyBundle(bundle E2eeKeyBundle, onetimePrekeys []E2eePrekey) error
+	// GetE2eeKeyBundle 获取用户设备的公钥包，不存在返回ErrNotFound
+	GetE2eeKeyBundle(uid string, deviceId string) (E2eeKeyBundle, error)
+	// GetE2eeKeyBundles 获取用户所有设备的公钥包
+	GetE2eeKeyBundles(uid string) ([]E2eeKeyBundle, error)
+	// GetE2eeOnetimePrekeys 获取用户设备的一次性预共享公钥（按keyId升序），limit为0表示不限制
+	GetE2eeOnetimePrekeys(uid string, deviceId string, limit int) ([]E2eePrekey, error)
+	// GetE2eeOnetimePrekeyCount 获取用户设备剩余的一次性预共享公钥数量
+	GetE2eeOnetimePrekeyCount(uid string, deviceId string) (int, error)
+	// RemoveE2eeOnetimePrekeys 删除用户设备的一次性预共享公钥（已被获取）
+	RemoveE2eeOnetimePrekeys(uid string, deviceId string, keyIds []uint32) error
+	// RemoveE2eeKeyBundle 删除用户设备的公钥包以及一次性预共享公钥
+	RemoveE2eeKeyBundle(uid string, deviceId string) error
+}
+
+type MessageRetentionDB interface {
+	// IterateMessageChannels 遍历本节点有消息的频道 iterFnc返回false停止遍历
+	IterateMessageChannels(iterFnc func(channelId string, channelType uint8, lastMsgSeq uint64) bool) error
+	// GetChannelFirstMessageSeq 获取频道第一条（未被清理的）消息的seq，没有消息返回0
+	GetChannelFirstMessageSeq(channelId string, channelType uint8) (uint64, error)
+	// GetMessageSeqBeforeTimestamp 获取消息时间（秒）小于timestamp的最后一条消息的seq，没有返回0
+	GetMessageSeqBeforeTimestamp(channelId string, channelType uint8, timestamp int64) (uint64, error)
+	// GetMessageSeqExceedSize 从最新的消息往前累计消息内容大小，返回累计超过maxBytes时的消息seq（包含此消息及之前的消息都超出了保留大小），没有返回0
+	GetMessageSeqExceedSize(channelId string, channelType uint8, maxBytes uint64) (uint64, error)
+	// PurgeMessagesTo 清理频道从第一条消息到messageSeq（包含）的消息以及消息索引，limit限制本次最多清理的消息数量（0表示不限制），返回本次清理到的消息seq，没有清理返回0
+	PurgeMessagesTo(channelId string, channelType uint8, messageSeq uint64, limit int) (uint64, error)
+}
+
+type MessageColdDB interface {
+	// OffloadMessagesTo 将频道从第一条本地消息到messageSeq（包含）的消息打包成消息段转存到冷存储（只转存满的消息段，最新的一条消息始终保留在本地），返回本次转存到的消息seq，没有转存返回0
+	OffloadMessagesTo(channelId string, channelType uint8, messageSeq uint64) (uint64, error)
+	// GetMessageColdSegments 获取频道冷存储的消息段（按seq升序）
+	GetMessageColdSegments(channelId string, channelType uint8) ([]MessageColdSegment, error)
+}
+
+type ConsistencyDB interface {
+	// GetChannelFirstLocalMessageSeq 获取频道第一条本地消息（不包含冷存储的消息）的seq，没有返回0
+	GetChannelFirstLocalMessageSeq(channelId string, channelType uint8) (uint64, error)
+	// ChannelMessageChecksums 按bucketSize将频道[startSeq,endSeq]范围内的本地消息分段计算校验和（不包含冷存储的消息），没有消息的分段校验和为空
+	ChannelMessageChecksums(channelId string, channelType uint8, startSeq, endSeq uint64, bucketSize uint64) ([]MessageChecksumBucket, error)
+	// SlotStateChecksums 计算槽的状态数据（用户、频道、订阅者、最近会话）的校验和，slotOf为数据所属的槽，slotIds为空表示计算所有槽
+	SlotStateChecksums(slotOf func(v string) uint32, slotIds []uint32) (map[uint32]*SlotStateChecksum, error)
+}
+
+type AuditLogDB interface {
+	// AppendAuditLogs 追加审计日志
+	AppendAuditLogs(logs []AuditLog) error
+	// SearchAuditLogs 搜索审计日志
+	SearchAuditLogs(req AuditLogSearchReq) ([]AuditLog, error)
+}
+
 type StreamDB interface {
 	// AddStreamMeta 添加流元数据
 	AddStreamMeta(streamMeta *StreamMeta) error
@@ -368,6 +484,9 @@ type StreamDB interface {
 
 	// GetStreams 获取流
 	GetStreams(streamNo string) ([]*Stream, error)
+
+	// GetStreamLastId 获取流最后一个分片的序号，没有分片返回0
+	GetStreamLastId(streamNo string) (uint64, error)
 }
 
 type MessageSearchReq struct {
@@ -384,6 +503,18 @@ type MessageSearchReq struct {
 	ClientMsgNo string // 客户端消息编号
 }
 
+type AuditLogSearchReq struct {
+	Action   string // 操作（支持前缀，比如 channel 匹配 channel.delete）
+	Actor    string // 操作者
+	Target   string // 操作对象（包含匹配）
+	Result   string // 结果 success, failure
+	SourceIp string // 来源ip
+	StartAt  int64  // 开始时间（纳秒）
+	EndAt    int64  // 结束时间（纳秒）
+	OffsetId uint64 // 偏移的日志id（返回比此id更早的日志）
+	Limit    int    // 限制查询数量
+}
+
 type ChannelSearchReq struct {
 	ChannelId          string // 频道id
 	ChannelType        uint8  // 频道类型
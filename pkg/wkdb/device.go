@@ -3,6 +3,7 @@ package wkdb
 import (
 	"math"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
@@ -89,7 +90,7 @@ func (wk *wukongDB) GetDevice(uid string, deviceFlag uint64) (Device, error) {
 		return EmptyDevice, err
 	}
 
-	if device == EmptyDevice {
+	if IsEmptyDevice(device) {
 		return EmptyDevice, ErrNotFound
 	}
 	return device, nil
@@ -397,6 +398,22 @@ func (wk *wukongDB) writeDevice(d Device, w pebble.Writer) error {
 		}
 	}
 
+	// tokenExpireAt (没有过期时间时也要写入，覆盖掉旧的过期时间)
+	var tokenExpireAt uint64
+	if d.TokenExpireAt != nil {
+		tokenExpireAt = uint64(d.TokenExpireAt.UnixNano())
+	}
+	tokenExpireAtBytes := make([]byte, 8)
+	wk.endian.PutUint64(tokenExpireAtBytes, tokenExpireAt)
+	if err = w.Set(key.NewDeviceColumnKey(d.Id, key.TableDevice.Column.TokenExpireAt), tokenExpireAtBytes, wk.noSync); err != nil {
+		return err
+	}
+
+	// scopes
+	if err = w.Set(key.NewDeviceColumnKey(d.Id, key.TableDevice.Column.Scopes), []byte(strings.Join(d.Scopes, ",")), wk.noSync); err != nil {
+		return err
+	}
+
 	// uid index
 	if err = w.Set(key.NewDeviceSecondIndexKey(key.TableDevice.SecondIndex.Uid, key.HashWithString(d.Uid), d.Id), nil, wk.noSync); err != nil {
 		return err
@@ -487,7 +504,16 @@ func (wk *wukongDB) iterDevice(iter *pebble.Iterator, iterFnc func(d Device) boo
 				t := time.Unix(tm/1e9, tm%1e9)
 				preDevice.UpdatedAt = &t
 			}
-
+		case key.TableDevice.Column.TokenExpireAt:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preDevice.TokenExpireAt = &t
+			}
+		case key.TableDevice.Column.Scopes:
+			if len(iter.Value()) > 0 {
+				preDevice.Scopes = strings.Split(string(iter.Value()), ",")
+			}
 		}
 		lastNeedAppend = true
 		hasData = true
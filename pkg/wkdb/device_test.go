@@ -147,3 +147,52 @@ func TestSearchDevices(t *testing.T) {
 	assert.Equal(t, 1, len(us))
 
 }
+
+func TestUpdateDeviceTokenExpireAndScopes(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	expireAt := time.Now().Add(time.Hour)
+	u := wkdb.Device{
+		Id:            1,
+		Uid:           "test",
+		Token:         "token",
+		DeviceFlag:    2,
+		DeviceLevel:   1,
+		TokenExpireAt: &expireAt,
+		Scopes:        []string{"message:send", "message:recv"},
+	}
+
+	err = d.AddDevice(u)
+	assert.NoError(t, err)
+
+	u2, err := d.GetDevice("test", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, expireAt.UnixNano(), u2.TokenExpireAt.UnixNano())
+	assert.Equal(t, u.Scopes, u2.Scopes)
+	assert.False(t, u2.TokenExpired(time.Now()))
+	assert.True(t, u2.TokenExpired(expireAt))
+	assert.True(t, u2.HasScope("message:send"))
+	assert.False(t, u2.HasScope("channel:write"))
+
+	// 更新为永不过期的token时，旧的过期时间和授权范围需要被清除
+	u.Token = "token2"
+	u.TokenExpireAt = nil
+	u.Scopes = nil
+	err = d.UpdateDevice(u)
+	assert.NoError(t, err)
+
+	u3, err := d.GetDevice("test", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "token2", u3.Token)
+	assert.Nil(t, u3.TokenExpireAt)
+	assert.Nil(t, u3.Scopes)
+	assert.False(t, u3.TokenExpired(time.Now()))
+	assert.True(t, u3.HasScope("channel:write"))
+}
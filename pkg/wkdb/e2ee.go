@@ -0,0 +1,199 @@
+package wkdb
+
+import (
+	"math"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) SaveE2eeKeyBundle(bundle E2eeKeyBundle, onetimePrekeys []E2eePrekey) error {
+
+	wk.metrics.SaveE2eeKeyBundleAdd(1)
+
+	batch := wk.shardDB(bundle.Uid).NewBatch()
+	defer batch.Close()
+	if err := batch.Set(key.NewE2eeKeyBundleKey(bundle.Uid, bundle.DeviceId), bundle.Encode(), wk.noSync); err != nil {
+		return err
+	}
+	for _, prekey := range onetimePrekeys {
+		if err := batch.Set(key.NewE2eeOnetimePrekeyKey(bundle.Uid, bundle.DeviceId, prekey.KeyId), prekey.PublicKey, wk.noSync); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(wk.sync)
+}
+
+func (wk *wukongDB) GetE2eeKeyBundle(uid string, deviceId string) (E2eeKeyBundle, error) {
+
+	wk.metrics.GetE2eeKeyBundlesAdd(1)
+
+	valueBytes, closer, err := wk.shardDB(uid).Get(key.NewE2eeKeyBundleKey(uid, deviceId))
+	if closer != nil {
+		defer closer.Close()
+	}
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return EmptyE2eeKeyBundle, ErrNotFound
+		}
+		return EmptyE2eeKeyBundle, err
+	}
+	var bundle E2eeKeyBundle
+	if err = bundle.Decode(valueBytes); err != nil {
+		return EmptyE2eeKeyBundle, err
+	}
+	return bundle, nil
+}
+
+func (wk *wukongDB) GetE2eeKeyBundles(uid string) ([]E2eeKeyBundle, error) {
+
+	wk.metrics.GetE2eeKeyBundlesAdd(1)
+
+	iter := wk.shardDB(uid).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewE2eeKeyBundleLowKey(uid),
+		UpperBound: key.NewE2eeKeyBundleHighKey(uid),
+	})
+	defer iter.Close()
+
+	var bundles []E2eeKeyBundle
+	for iter.First(); iter.Valid(); iter.Next() {
+		var bundle E2eeKeyBundle
+		if err := bundle.Decode(iter.Value()); err != nil {
+			return nil, err
+		}
+		if bundle.Uid != uid { // hash冲突
+			continue
+		}
+		bundles = append(bundles, bundle)
+	}
+	return bundles, nil
+}
+
+func (wk *wukongDB) GetE2eeOnetimePrekeys(uid string, deviceId string, limit int) ([]E2eePrekey, error) {
+
+	wk.metrics.GetE2eeOnetimePrekeysAdd(1)
+
+	iter := wk.shardDB(uid).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewE2eeOnetimePrekeyKey(uid, deviceId, 0),
+		UpperBound: key.NewE2eeOnetimePrekeyKey(uid, deviceId, math.MaxUint32),
+	})
+	defer iter.Close()
+
+	var prekeys []E2eePrekey
+	for iter.First(); iter.Valid(); iter.Next() {
+		keyId, err := key.ParseE2eeOnetimePrekeyKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		publicKey := make([]byte, len(iter.Value()))
+		copy(publicKey, iter.Value())
+		prekeys = append(prekeys, E2eePrekey{KeyId: keyId, PublicKey: publicKey})
+		if limit > 0 && len(prekeys) >= limit {
+			break
+		}
+	}
+	return prekeys, nil
+}
+
+func (wk *wukongDB) GetE2eeOnetimePrekeyCount(uid string, deviceId string) (int, error) {
+
+	wk.metrics.GetE2eeOnetimePrekeysAdd(1)
+
+	iter := wk.shardDB(uid).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewE2eeOnetimePrekeyKey(uid, deviceId, 0),
+		UpperBound: key.NewE2eeOnetimePrekeyKey(uid, deviceId, math.MaxUint32),
+	})
+	defer iter.Close()
+
+	count := 0
+	for iter.First(); iter.Valid(); iter.Next() {
+		count++
+	}
+	return count, nil
+}
+
+func (wk *wukongDB) RemoveE2eeOnetimePrekeys(uid string, deviceId string, keyIds []uint32) error {
+
+	wk.metrics.RemoveE2eeOnetimePrekeysAdd(1)
+
+	batch := wk.shardDB(uid).NewBatch()
+	defer batch.Close()
+	for _, keyId := range keyIds {
+		if err := batch.Delete(key.NewE2eeOnetimePrekeyKey(uid, deviceId, keyId), wk.noSync); err != nil {
+			return err
+		}
+	}
+	return batch.Commit(wk.sync)
+}
+
+func (wk *wukongDB) RemoveE2eeKeyBundle(uid string, deviceId string) error {
+
+	wk.metrics.RemoveE2eeKeyBundleAdd(1)
+
+	batch := wk.shardDB(uid).NewBatch()
+	defer batch.Close()
+	if err := batch.Delete(key.NewE2eeKeyBundleKey(uid, deviceId), wk.noSync); err != nil {
+		return err
+	}
+	if err := batch.DeleteRange(key.NewE2eeOnetimePrekeyKey(uid, deviceId, 0), key.NewE2eeOnetimePrekeyKey(uid, deviceId, math.MaxUint32), wk.noSync); err != nil {
+		return err
+	}
+	return batch.Commit(wk.sync)
+}
+
+func (b *E2eeKeyBundle) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(b.Uid)
+	enc.WriteString(b.DeviceId)
+	enc.WriteUint32(b.RegistrationId)
+	enc.WriteBinary(b.IdentityKey)
+	enc.WriteUint32(b.SignedPrekey.KeyId)
+	enc.WriteBinary(b.SignedPrekey.PublicKey)
+	enc.WriteBinary(b.SignedPrekey.Signature)
+	enc.WriteInt64(b.UpdatedAt)
+	return enc.Bytes()
+}
+
+// Decode 解码，data可能会被复用（例如pebble迭代器的值），所以二进制字段都拷贝一份
+func (b *E2eeKeyBundle) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if b.Uid, err = dec.String(); err != nil {
+		return err
+	}
+	if b.DeviceId, err = dec.String(); err != nil {
+		return err
+	}
+	if b.RegistrationId, err = dec.Uint32(); err != nil {
+		return err
+	}
+	if b.IdentityKey, err = decodeBinaryCopy(dec); err != nil {
+		return err
+	}
+	if b.SignedPrekey.KeyId, err = dec.Uint32(); err != nil {
+		return err
+	}
+	if b.SignedPrekey.PublicKey, err = decodeBinaryCopy(dec); err != nil {
+		return err
+	}
+	if b.SignedPrekey.Signature, err = decodeBinaryCopy(dec); err != nil {
+		return err
+	}
+	if b.UpdatedAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+func decodeBinaryCopy(dec *wkproto.Decoder) ([]byte, error) {
+	data, err := dec.Binary()
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return append([]byte(nil), data...), nil
+}
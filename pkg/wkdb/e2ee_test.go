@@ -0,0 +1,74 @@
+package wkdb_test
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestE2eeKeyBundle(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	bundle := wkdb.E2eeKeyBundle{
+		Uid:            "uid1",
+		DeviceId:       "device1",
+		RegistrationId: 1234,
+		IdentityKey:    []byte("identityKey"),
+		SignedPrekey: wkdb.E2eeSignedPrekey{
+			KeyId:     1,
+			PublicKey: []byte("signedPrekey"),
+			Signature: []byte("signature"),
+		},
+		UpdatedAt: 1,
+	}
+	err = d.SaveE2eeKeyBundle(bundle, []wkdb.E2eePrekey{
+		{KeyId: 3, PublicKey: []byte("prekey3")},
+		{KeyId: 1, PublicKey: []byte("prekey1")},
+		{KeyId: 2, PublicKey: []byte("prekey2")},
+	})
+	assert.NoError(t, err)
+
+	err = d.SaveE2eeKeyBundle(wkdb.E2eeKeyBundle{Uid: "uid1", DeviceId: "device2", IdentityKey: []byte("identityKey2")}, nil)
+	assert.NoError(t, err)
+
+	result, err := d.GetE2eeKeyBundle("uid1", "device1")
+	assert.NoError(t, err)
+	assert.Equal(t, bundle, result)
+
+	_, err = d.GetE2eeKeyBundle("uid2", "device1")
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	bundles, err := d.GetE2eeKeyBundles("uid1")
+	assert.NoError(t, err)
+	assert.Len(t, bundles, 2)
+
+	prekeys, err := d.GetE2eeOnetimePrekeys("uid1", "device1", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []wkdb.E2eePrekey{{KeyId: 1, PublicKey: []byte("prekey1")}, {KeyId: 2, PublicKey: []byte("prekey2")}}, prekeys)
+
+	err = d.RemoveE2eeOnetimePrekeys("uid1", "device1", []uint32{1})
+	assert.NoError(t, err)
+
+	count, err := d.GetE2eeOnetimePrekeyCount("uid1", "device1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	err = d.RemoveE2eeKeyBundle("uid1", "device1")
+	assert.NoError(t, err)
+
+	bundles, err = d.GetE2eeKeyBundles("uid1")
+	assert.NoError(t, err)
+	assert.Len(t, bundles, 1)
+
+	count, err = d.GetE2eeOnetimePrekeyCount("uid1", "device1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
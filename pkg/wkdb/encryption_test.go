@@ -0,0 +1,50 @@
+package wkdb_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/encryption"
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/cockroachdb/pebble/vfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptFS(t *testing.T) {
+	dir := t.TempDir()
+	provider, err := encryption.NewFileKeyProvider(filepath.Join(dir, "keyfile"), nil)
+	assert.NoError(t, err)
+	fs := encryption.NewFS(vfs.Default, provider)
+
+	dataDir := filepath.Join(dir, "data")
+	d := newTestDBWithOptions(t, wkdb.WithDir(dataDir), wkdb.WithEncryptFS(fs))
+	assert.NoError(t, d.Open())
+	appendTestMessages(t, d, "channel", 2, 10)
+	assert.NoError(t, d.Close())
+
+	// 重新打开后可以读取
+	d = newTestDBWithOptions(t, wkdb.WithDir(dataDir), wkdb.WithEncryptFS(fs))
+	assert.NoError(t, d.Open())
+	defer func() {
+		assert.NoError(t, d.Close())
+	}()
+	msgs, err := d.LoadNextRangeMsgs("channel", 2, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(msgs))
+
+	// 磁盘上没有明文的消息内容
+	err = filepath.Walk(dataDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		assert.False(t, bytes.Contains(data, []byte("clientMsgNo")), path)
+		return nil
+	})
+	assert.NoError(t, err)
+}
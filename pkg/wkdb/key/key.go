@@ -118,6 +118,50 @@ func NewChannelLastMessageSeqKey(channelId string, channelType uint8) []byte {
 
 }
 
+// NewChannelMessageSizeKey 频道所有消息内容的累计字节数（不随消息清理减少）
+func NewChannelMessageSizeKey(channelId string, channelType uint8) []byte {
+	key := make([]byte, 12)
+	key[0] = TableMessage.Id[0]
+	key[1] = TableMessage.Id[1]
+	key[2] = dataTypeOther
+	key[3] = 1
+	channelHash := channelToNum(channelId, channelType)
+	binary.BigEndian.PutUint64(key[4:], channelHash)
+	return key
+}
+
+// NewChannelLastMessageSeqLowKey 所有频道最新消息seq的范围（下界）
+func NewChannelLastMessageSeqLowKey() []byte {
+	key := make([]byte, 12)
+	key[0] = TableMessage.Id[0]
+	key[1] = TableMessage.Id[1]
+	key[2] = dataTypeOther
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], 0)
+	return key
+}
+
+// NewChannelLastMessageSeqHighKey 所有频道最新消息seq的范围（上界）
+func NewChannelLastMessageSeqHighKey() []byte {
+	key := make([]byte, 12)
+	key[0] = TableMessage.Id[0]
+	key[1] = TableMessage.Id[1]
+	key[2] = dataTypeOther
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], math.MaxUint64)
+	return key
+}
+
+// ParseChannelLastMessageSeqKey 解析频道最新消息seq的key，返回频道hash
+func ParseChannelLastMessageSeqKey(key []byte) (channelHash uint64, err error) {
+	if len(key) != 12 {
+		err = fmt.Errorf("channelLastMessageSeq: invalid key length, keyLen: %d", len(key))
+		return
+	}
+	channelHash = binary.BigEndian.Uint64(key[4:])
+	return
+}
+
 func ParseMessageColumnKey(key []byte) (messageSeq uint64, columnName [2]byte, err error) {
 	if len(key) != TableMessage.Size {
 		err = fmt.Errorf("message: invalid key length, keyLen: %d", len(key))
@@ -207,6 +251,31 @@ func NewMessageIndexTimestampKey(timestamp uint64, primaryKey [16]byte) []byte {
 
 }
 
+// NewMessageSecondIndexChannelTimestampKey 频道内的消息时间索引
+func NewMessageSecondIndexChannelTimestampKey(channelId string, channelType uint8, timestamp uint64, messageSeq uint64) []byte {
+	key := make([]byte, TableMessage.SecondIndexSize)
+	key[0] = TableMessage.Id[0]
+	key[1] = TableMessage.Id[1]
+	key[2] = dataTypeSecondIndex
+	key[3] = 0
+	key[4] = TableMessage.SecondIndex.ChannelTimestamp[0]
+	key[5] = TableMessage.SecondIndex.ChannelTimestamp[1]
+	binary.BigEndian.PutUint64(key[6:], channelToNum(channelId, channelType))
+	binary.BigEndian.PutUint64(key[14:], timestamp)
+	binary.BigEndian.PutUint64(key[22:], messageSeq)
+	return key
+}
+
+func ParseMessageSecondIndexChannelTimestampKey(key []byte) (timestamp uint64, messageSeq uint64, err error) {
+	if len(key) != TableMessage.SecondIndexSize {
+		err = fmt.Errorf("message: invalid channel timestamp index key length, keyLen: %d", len(key))
+		return
+	}
+	timestamp = binary.BigEndian.Uint64(key[14:])
+	messageSeq = binary.BigEndian.Uint64(key[22:])
+	return
+}
+
 func ParseMessageSecondIndexKey(key []byte) (primaryKey [16]byte, err error) {
 	if len(key) != TableMessage.SecondIndexSize {
 		return [16]byte{}, fmt.Errorf("message: invalid index key length, keyLen: %d", len(key))
@@ -770,6 +839,18 @@ func NewMessageNotifyQueueKey(messageId uint64) []byte {
 	return key
 }
 
+// ---------------------- AuditLog ----------------------
+
+func NewAuditLogKey(id uint64) []byte {
+	key := make([]byte, TableAuditLog.Size)
+	key[0] = TableAuditLog.Id[0]
+	key[1] = TableAuditLog.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], id)
+	return key
+}
+
 // ---------------------- ChannelClusterConfig ----------------------
 
 func NewChannelClusterConfigColumnKey(primaryKey uint64, columnName [2]byte) []byte {
@@ -977,3 +1058,192 @@ func ParseConversationLocalUserKey(key []byte) (uid string, err error) {
 	uid = string(key[12:])
 	return uid, nil
 }
+
+// ---------------------- apiKey ----------------------
+
+func NewApiKeyColumnKey(id uint64, columnName [2]byte) []byte {
+	key := make([]byte, TableApiKey.Size)
+	key[0] = TableApiKey.Id[0]
+	key[1] = TableApiKey.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], id)
+	key[12] = columnName[0]
+	key[13] = columnName[1]
+	return key
+}
+
+func ParseApiKeyColumnKey(key []byte) (id uint64, columnName [2]byte, err error) {
+	if len(key) != TableApiKey.Size {
+		err = fmt.Errorf("apiKey: invalid key length, keyLen: %d", len(key))
+		return
+	}
+	id = binary.BigEndian.Uint64(key[4:])
+	columnName[0] = key[12]
+	columnName[1] = key[13]
+	return
+}
+
+// ---------------------- managerUser ----------------------
+
+func NewManagerUserColumnKey(id uint64, columnName [2]byte) []byte {
+	key := make([]byte, TableManagerUser.Size)
+	key[0] = TableManagerUser.Id[0]
+	key[1] = TableManagerUser.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], id)
+	key[12] = columnName[0]
+	key[13] = columnName[1]
+	return key
+}
+
+func ParseManagerUserColumnKey(key []byte) (id uint64, columnName [2]byte, err error) {
+	if len(key) != TableManagerUser.Size {
+		err = fmt.Errorf("managerUser: invalid key length, keyLen: %d", len(key))
+		return
+	}
+	id = binary.BigEndian.Uint64(key[4:])
+	columnName[0] = key[12]
+	columnName[1] = key[13]
+	return
+}
+
+// ---------------------- subscriberHistory ----------------------
+
+func NewSubscriberHistoryColumnKey(channelId string, channelType uint8, id uint64, columnName [2]byte) []byte {
+	key := make([]byte, TableSubscriberHistory.Size)
+	channelHash := channelToNum(channelId, channelType)
+	key[0] = TableSubscriberHistory.Id[0]
+	key[1] = TableSubscriberHistory.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], channelHash)
+	binary.BigEndian.PutUint64(key[12:], id)
+	key[20] = columnName[0]
+	key[21] = columnName[1]
+	return key
+}
+
+// ---------------------- messageVisibility ----------------------
+
+func NewMessageVisibilityColumnKey(channelId string, channelType uint8, uid string, columnName [2]byte) []byte {
+	key := make([]byte, TableMessageVisibility.Size)
+	channelHash := channelToNum(channelId, channelType)
+	key[0] = TableMessageVisibility.Id[0]
+	key[1] = TableMessageVisibility.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], channelHash)
+	binary.BigEndian.PutUint64(key[12:], HashWithString(uid))
+	key[20] = columnName[0]
+	key[21] = columnName[1]
+	return key
+}
+
+// ---------------------- hiddenMessage ----------------------
+
+func NewHiddenMessageKey(channelId string, channelType uint8, uid string, messageSeq uint64, messageId uint64) []byte {
+	key := make([]byte, TableHiddenMessage.Size)
+	channelHash := channelToNum(channelId, channelType)
+	key[0] = TableHiddenMessage.Id[0]
+	key[1] = TableHiddenMessage.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], channelHash)
+	binary.BigEndian.PutUint64(key[12:], HashWithString(uid))
+	binary.BigEndian.PutUint64(key[20:], messageSeq)
+	binary.BigEndian.PutUint64(key[28:], messageId)
+	return key
+}
+
+func ParseHiddenMessageKey(key []byte) (messageSeq uint64, messageId uint64, err error) {
+	if len(key) != TableHiddenMessage.Size {
+		err = fmt.Errorf("hiddenMessage: invalid key length, keyLen: %d", len(key))
+		return
+	}
+	messageSeq = binary.BigEndian.Uint64(key[20:])
+	messageId = binary.BigEndian.Uint64(key[28:])
+	return
+}
+
+// ---------------------- e2eeKeyBundle ----------------------
+
+func NewE2eeKeyBundleKey(uid string, deviceId string) []byte {
+	key := make([]byte, TableE2eeKeyBundle.Size)
+	key[0] = TableE2eeKeyBundle.Id[0]
+	key[1] = TableE2eeKeyBundle.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], HashWithString(uid))
+	binary.BigEndian.PutUint64(key[12:], HashWithString(deviceId))
+	return key
+}
+
+// NewE2eeKeyBundleLowKey 用户所有设备公钥包的范围
+func NewE2eeKeyBundleLowKey(uid string) []byte {
+	key := make([]byte, TableE2eeKeyBundle.Size)
+	key[0] = TableE2eeKeyBundle.Id[0]
+	key[1] = TableE2eeKeyBundle.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], HashWithString(uid))
+	binary.BigEndian.PutUint64(key[12:], 0)
+	return key
+}
+
+func NewE2eeKeyBundleHighKey(uid string) []byte {
+	key := make([]byte, TableE2eeKeyBundle.Size)
+	key[0] = TableE2eeKeyBundle.Id[0]
+	key[1] = TableE2eeKeyBundle.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], HashWithString(uid))
+	binary.BigEndian.PutUint64(key[12:], math.MaxUint64)
+	return key
+}
+
+// ---------------------- e2eeOnetimePrekey ----------------------
+
+func NewE2eeOnetimePrekeyKey(uid string, deviceId string, keyId uint32) []byte {
+	key := make([]byte, TableE2eeOnetimePrekey.Size)
+	key[0] = TableE2eeOnetimePrekey.Id[0]
+	key[1] = TableE2eeOnetimePrekey.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], HashWithString(uid))
+	binary.BigEndian.PutUint64(key[12:], HashWithString(deviceId))
+	binary.BigEndian.PutUint32(key[20:], keyId)
+	return key
+}
+
+func ParseE2eeOnetimePrekeyKey(key []byte) (keyId uint32, err error) {
+	if len(key) != TableE2eeOnetimePrekey.Size {
+		err = fmt.Errorf("e2eeOnetimePrekey: invalid key length, keyLen: %d", len(key))
+		return
+	}
+	keyId = binary.BigEndian.Uint32(key[20:])
+	return
+}
+
+// ---------------------- messageColdSegment ----------------------
+
+func NewMessageColdSegmentKey(channelId string, channelType uint8, endSeq uint64) []byte {
+	key := make([]byte, TableMessageColdSegment.Size)
+	key[0] = TableMessageColdSegment.Id[0]
+	key[1] = TableMessageColdSegment.Id[1]
+	key[2] = dataTypeTable
+	key[3] = 0
+	binary.BigEndian.PutUint64(key[4:], channelToNum(channelId, channelType))
+	binary.BigEndian.PutUint64(key[12:], endSeq)
+	return key
+}
+
+func ParseMessageColdSegmentKey(key []byte) (endSeq uint64, err error) {
+	if len(key) != TableMessageColdSegment.Size {
+		err = fmt.Errorf("messageColdSegment: invalid key length, keyLen: %d", len(key))
+		return
+	}
+	endSeq = binary.BigEndian.Uint64(key[12:])
+	return
+}
@@ -7,13 +7,21 @@ package key
 // ---------------------
 
 // 数据类型
-var (
+const (
 	dataTypeTable       byte = 0x01 // 表
 	dataTypeIndex       byte = 0x02 // 唯一索引 key结构一般是：  (tableId + dataType + indexName + columnHash) 值一般为primaryKey
 	dataTypeSecondIndex byte = 0x03 // 非唯一二级索引 key结构一般是： (tableId + dataType + uid hash + secondIndexName + columnValue + primaryKey) 值一般为空
 	dataTypeOther       byte = 0x04 // 其他
 )
 
+// 导出的数据类型，供离线工具（例如重新分区）根据key判断数据的类型
+const (
+	DataTypeTable       = dataTypeTable
+	DataTypeIndex       = dataTypeIndex
+	DataTypeSecondIndex = dataTypeSecondIndex
+	DataTypeOther       = dataTypeOther
+)
+
 // ======================== Message ========================
 // ---------------------
 // | tableID  | dataType	| channel hash | messageSeq   | columnKey |
@@ -39,6 +47,8 @@ var TableMessage = struct {
 		FromUid     [2]byte
 		Payload     [2]byte
 		Term        [2]byte
+		// 此消息之前频道所有消息内容的累计字节数（用于按大小保留消息）
+		PayloadOffset [2]byte
 	}
 	Index struct {
 		MessageId [2]byte
@@ -48,6 +58,8 @@ var TableMessage = struct {
 		ClientMsgNo [2]byte
 		Timestamp   [2]byte
 		Channel     [2]byte
+		// 频道内的消息时间索引 key结构： (tableId + dataType + secondIndexName + channel hash + timestamp + messageSeq)
+		ChannelTimestamp [2]byte
 	}
 }{
 	Id:              [2]byte{0x01, 0x01},
@@ -68,6 +80,8 @@ var TableMessage = struct {
 		FromUid     [2]byte
 		Payload     [2]byte
 		Term        [2]byte
+		// 此消息之前频道所有消息内容的累计字节数（用于按大小保留消息）
+		PayloadOffset [2]byte
 	}{
 		Header:      [2]byte{0x01, 0x01},
 		Setting:     [2]byte{0x01, 0x02},
@@ -82,6 +96,8 @@ var TableMessage = struct {
 		FromUid:     [2]byte{0x01, 0x0B},
 		Payload:     [2]byte{0x01, 0x0C},
 		Term:        [2]byte{0x01, 0x0D},
+
+		PayloadOffset: [2]byte{0x01, 0x0E},
 	},
 	Index: struct {
 		MessageId [2]byte
@@ -93,11 +109,15 @@ var TableMessage = struct {
 		ClientMsgNo [2]byte
 		Timestamp   [2]byte
 		Channel     [2]byte
+		// 频道内的消息时间索引 key结构： (tableId + dataType + secondIndexName + channel hash + timestamp + messageSeq)
+		ChannelTimestamp [2]byte
 	}{
 		FromUid:     [2]byte{0x01, 0x01},
 		ClientMsgNo: [2]byte{0x01, 0x02},
 		Timestamp:   [2]byte{0x01, 0x03},
 		Channel:     [2]byte{0x01, 0x04},
+
+		ChannelTimestamp: [2]byte{0x01, 0x05},
 	},
 }
 
@@ -119,6 +139,8 @@ var TableUser = struct {
 		RecvMsgBytes      [2]byte // 接受消息字节数量
 		CreatedAt         [2]byte // 创建时间
 		UpdatedAt         [2]byte // 更新时间
+		LastSeen          [2]byte // 最后在线时间
+		LastSeenPrivacy   [2]byte // 最后在线时间的可见范围
 	}
 	Index struct {
 		Uid [2]byte
@@ -143,6 +165,8 @@ var TableUser = struct {
 		RecvMsgBytes      [2]byte // 接受消息字节数量
 		CreatedAt         [2]byte
 		UpdatedAt         [2]byte
+		LastSeen          [2]byte
+		LastSeenPrivacy   [2]byte
 	}{
 		Uid:               [2]byte{0x02, 0x01},
 		DeviceCount:       [2]byte{0x02, 0x02},
@@ -154,6 +178,8 @@ var TableUser = struct {
 		RecvMsgBytes:      [2]byte{0x02, 0x08},
 		CreatedAt:         [2]byte{0x02, 0x09},
 		UpdatedAt:         [2]byte{0x02, 0x0A},
+		LastSeen:          [2]byte{0x02, 0x0B},
+		LastSeenPrivacy:   [2]byte{0x02, 0x0C},
 	},
 	Index: struct {
 		Uid [2]byte
@@ -177,12 +203,14 @@ var TableDevice = struct {
 	IndexSize       int
 	SecondIndexSize int
 	Column          struct {
-		Uid         [2]byte // 用户uid
-		Token       [2]byte // 设备Token
-		DeviceFlag  [2]byte // 设备标识
-		DeviceLevel [2]byte // 设备等级
-		CreatedAt   [2]byte // 创建时间
-		UpdatedAt   [2]byte // 更新时间
+		Uid           [2]byte // 用户uid
+		Token         [2]byte // 设备Token
+		DeviceFlag    [2]byte // 设备标识
+		DeviceLevel   [2]byte // 设备等级
+		CreatedAt     [2]byte // 创建时间
+		UpdatedAt     [2]byte // 更新时间
+		TokenExpireAt [2]byte // token过期时间
+		Scopes        [2]byte // token的授权范围
 	}
 	SecondIndex struct {
 		Uid         [2]byte
@@ -197,19 +225,23 @@ var TableDevice = struct {
 	IndexSize:       2 + 2 + 2 + 8,     // tableId + dataType + indexName + columnValue
 	SecondIndexSize: 2 + 2 + 2 + 8 + 8, // tableId + dataType + secondIndexName + columnValue + primaryKey
 	Column: struct {
-		Uid         [2]byte
-		Token       [2]byte
-		DeviceFlag  [2]byte
-		DeviceLevel [2]byte
-		CreatedAt   [2]byte
-		UpdatedAt   [2]byte
+		Uid           [2]byte
+		Token         [2]byte
+		DeviceFlag    [2]byte
+		DeviceLevel   [2]byte
+		CreatedAt     [2]byte
+		UpdatedAt     [2]byte
+		TokenExpireAt [2]byte
+		Scopes        [2]byte
 	}{
-		Uid:         [2]byte{0x03, 0x01},
-		Token:       [2]byte{0x03, 0x02},
-		DeviceFlag:  [2]byte{0x03, 0x03},
-		DeviceLevel: [2]byte{0x03, 0x04},
-		CreatedAt:   [2]byte{0x03, 0x05},
-		UpdatedAt:   [2]byte{0x03, 0x06},
+		Uid:           [2]byte{0x03, 0x01},
+		Token:         [2]byte{0x03, 0x02},
+		DeviceFlag:    [2]byte{0x03, 0x03},
+		DeviceLevel:   [2]byte{0x03, 0x04},
+		CreatedAt:     [2]byte{0x03, 0x05},
+		UpdatedAt:     [2]byte{0x03, 0x06},
+		TokenExpireAt: [2]byte{0x03, 0x07},
+		Scopes:        [2]byte{0x03, 0x08},
 	},
 	SecondIndex: struct {
 		Uid         [2]byte
@@ -234,9 +266,13 @@ var TableSubscriber = struct {
 	IndexSize       int
 	SecondIndexSize int
 	Column          struct {
-		Uid       [2]byte
-		CreatedAt [2]byte
-		UpdatedAt [2]byte
+		Uid        [2]byte
+		CreatedAt  [2]byte
+		UpdatedAt  [2]byte
+		Role       [2]byte
+		MuteUntil  [2]byte
+		JoinSource [2]byte
+		Attributes [2]byte
 	}
 	Index struct {
 		Uid [2]byte
@@ -251,13 +287,21 @@ var TableSubscriber = struct {
 	IndexSize:       2 + 2 + 2 + 8 + 8,     // tableId + dataType + indexName + channel hash + columnHash
 	SecondIndexSize: 2 + 2 + 2 + 8 + 8 + 8, // tableId + dataType + secondIndexName + channel hash +  columnValue + primaryKey
 	Column: struct {
-		Uid       [2]byte
-		CreatedAt [2]byte
-		UpdatedAt [2]byte
+		Uid        [2]byte
+		CreatedAt  [2]byte
+		UpdatedAt  [2]byte
+		Role       [2]byte
+		MuteUntil  [2]byte
+		JoinSource [2]byte
+		Attributes [2]byte
 	}{
-		Uid:       [2]byte{0x04, 0x01},
-		CreatedAt: [2]byte{0x04, 0x02},
-		UpdatedAt: [2]byte{0x04, 0x03},
+		Uid:        [2]byte{0x04, 0x01},
+		CreatedAt:  [2]byte{0x04, 0x02},
+		UpdatedAt:  [2]byte{0x04, 0x03},
+		Role:       [2]byte{0x04, 0x04},
+		MuteUntil:  [2]byte{0x04, 0x05},
+		JoinSource: [2]byte{0x04, 0x06},
+		Attributes: [2]byte{0x04, 0x07},
 	},
 	Index: struct {
 		Uid [2]byte
@@ -302,17 +346,24 @@ var TableChannelInfo = struct {
 	IndexSize       int
 	SecondIndexSize int
 	Column          struct {
-		Id              [2]byte
-		ChannelId       [2]byte
-		ChannelType     [2]byte
-		Ban             [2]byte
-		Large           [2]byte
-		Disband         [2]byte
-		SubscriberCount [2]byte // 订阅者数量
-		AllowlistCount  [2]byte // 白名单数量
-		DenylistCount   [2]byte // 黑名单数量
-		CreatedAt       [2]byte
-		UpdatedAt       [2]byte
+		Id                  [2]byte
+		ChannelId           [2]byte
+		ChannelType         [2]byte
+		Ban                 [2]byte
+		Large               [2]byte
+		Disband             [2]byte
+		SubscriberCount     [2]byte // 订阅者数量
+		AllowlistCount      [2]byte // 白名单数量
+		DenylistCount       [2]byte // 黑名单数量
+		CreatedAt           [2]byte
+		UpdatedAt           [2]byte
+		SendMode            [2]byte // 发言模式
+		HistoryVisibility   [2]byte // 历史消息可见性
+		HistoryVisibleCount [2]byte // 加入前可见的历史消息数量
+		E2ee                [2]byte // 是否开启端到端加密
+		RetentionMaxAge     [2]byte // 消息保留的最长时间（秒）
+		RetentionMaxCount   [2]byte // 消息保留的最大数量
+		RetentionMaxBytes   [2]byte // 消息保留的最大字节数
 	}
 	Index struct {
 		Channel [2]byte
@@ -332,29 +383,43 @@ var TableChannelInfo = struct {
 	IndexSize:       2 + 2 + 2 + 8,     // tableId + dataType + indexName  + columnHash
 	SecondIndexSize: 2 + 2 + 2 + 8 + 8, // tableId + dataType + secondIndexName + columnValue + primaryKey
 	Column: struct {
-		Id              [2]byte
-		ChannelId       [2]byte
-		ChannelType     [2]byte
-		Ban             [2]byte
-		Large           [2]byte
-		Disband         [2]byte
-		SubscriberCount [2]byte
-		AllowlistCount  [2]byte
-		DenylistCount   [2]byte
-		CreatedAt       [2]byte
-		UpdatedAt       [2]byte
+		Id                  [2]byte
+		ChannelId           [2]byte
+		ChannelType         [2]byte
+		Ban                 [2]byte
+		Large               [2]byte
+		Disband             [2]byte
+		SubscriberCount     [2]byte
+		AllowlistCount      [2]byte
+		DenylistCount       [2]byte
+		CreatedAt           [2]byte
+		UpdatedAt           [2]byte
+		SendMode            [2]byte
+		HistoryVisibility   [2]byte
+		HistoryVisibleCount [2]byte
+		E2ee                [2]byte
+		RetentionMaxAge     [2]byte
+		RetentionMaxCount   [2]byte
+		RetentionMaxBytes   [2]byte
 	}{
-		Id:              [2]byte{0x06, 0x01},
-		ChannelId:       [2]byte{0x06, 0x02},
-		ChannelType:     [2]byte{0x06, 0x03},
-		Ban:             [2]byte{0x06, 0x04},
-		Large:           [2]byte{0x06, 0x05},
-		Disband:         [2]byte{0x06, 0x06},
-		SubscriberCount: [2]byte{0x06, 0x07},
-		AllowlistCount:  [2]byte{0x06, 0x08},
-		DenylistCount:   [2]byte{0x06, 0x09},
-		CreatedAt:       [2]byte{0x06, 0x0A},
-		UpdatedAt:       [2]byte{0x06, 0x0B},
+		Id:                  [2]byte{0x06, 0x01},
+		ChannelId:           [2]byte{0x06, 0x02},
+		ChannelType:         [2]byte{0x06, 0x03},
+		Ban:                 [2]byte{0x06, 0x04},
+		Large:               [2]byte{0x06, 0x05},
+		Disband:             [2]byte{0x06, 0x06},
+		SubscriberCount:     [2]byte{0x06, 0x07},
+		AllowlistCount:      [2]byte{0x06, 0x08},
+		DenylistCount:       [2]byte{0x06, 0x09},
+		CreatedAt:           [2]byte{0x06, 0x0A},
+		UpdatedAt:           [2]byte{0x06, 0x0B},
+		SendMode:            [2]byte{0x06, 0x0C},
+		HistoryVisibility:   [2]byte{0x06, 0x0D},
+		HistoryVisibleCount: [2]byte{0x06, 0x0E},
+		E2ee:                [2]byte{0x06, 0x0F},
+		RetentionMaxAge:     [2]byte{0x06, 0x10},
+		RetentionMaxCount:   [2]byte{0x06, 0x11},
+		RetentionMaxBytes:   [2]byte{0x06, 0x12},
 	},
 	Index: struct {
 		Channel [2]byte
@@ -768,3 +833,199 @@ var TableConversationLocalUser = struct {
 }{
 	Id: [2]byte{0x13, 0x01},
 }
+
+// ======================== apiKey ========================
+
+var TableApiKey = struct {
+	Id     [2]byte
+	Size   int
+	Column struct {
+		Name      [2]byte // 名称
+		KeyHash   [2]byte // 密钥的hash值
+		Scopes    [2]byte // 授权范围
+		Disabled  [2]byte // 是否禁用
+		ExpireAt  [2]byte // 过期时间
+		CreatedAt [2]byte // 创建时间
+		UpdatedAt [2]byte // 更新时间
+	}
+}{
+	Id:   [2]byte{0x14, 0x01},
+	Size: 2 + 2 + 8 + 2, // tableId + dataType  + primaryKey + columnKey
+	Column: struct {
+		Name      [2]byte
+		KeyHash   [2]byte
+		Scopes    [2]byte
+		Disabled  [2]byte
+		ExpireAt  [2]byte
+		CreatedAt [2]byte
+		UpdatedAt [2]byte
+	}{
+		Name:      [2]byte{0x14, 0x01},
+		KeyHash:   [2]byte{0x14, 0x02},
+		Scopes:    [2]byte{0x14, 0x03},
+		Disabled:  [2]byte{0x14, 0x04},
+		ExpireAt:  [2]byte{0x14, 0x05},
+		CreatedAt: [2]byte{0x14, 0x06},
+		UpdatedAt: [2]byte{0x14, 0x07},
+	},
+}
+
+// ======================== auditLog ========================
+
+var TableAuditLog = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x15, 0x01},
+	Size: 2 + 2 + 8, // tableId + dataType  + auditLogId
+}
+
+// ======================== managerUser ========================
+
+var TableManagerUser = struct {
+	Id     [2]byte
+	Size   int
+	Column struct {
+		Username     [2]byte // 用户名
+		PasswordHash [2]byte // 密码的hash值
+		Role         [2]byte // 角色
+		Disabled     [2]byte // 是否禁用
+		Source       [2]byte // 用户来源
+		CreatedAt    [2]byte // 创建时间
+		UpdatedAt    [2]byte // 更新时间
+	}
+}{
+	Id:   [2]byte{0x16, 0x01},
+	Size: 2 + 2 + 8 + 2, // tableId + dataType  + primaryKey + columnKey
+	Column: struct {
+		Username     [2]byte
+		PasswordHash [2]byte
+		Role         [2]byte
+		Disabled     [2]byte
+		Source       [2]byte
+		CreatedAt    [2]byte
+		UpdatedAt    [2]byte
+	}{
+		Username:     [2]byte{0x16, 0x01},
+		PasswordHash: [2]byte{0x16, 0x02},
+		Role:         [2]byte{0x16, 0x03},
+		Disabled:     [2]byte{0x16, 0x04},
+		Source:       [2]byte{0x16, 0x05},
+		CreatedAt:    [2]byte{0x16, 0x06},
+		UpdatedAt:    [2]byte{0x16, 0x07},
+	},
+}
+
+// ======================== subscriberHistory ========================
+// ---------------------
+// | tableID  | dataType	| channel hash | uid hash   | columnKey |
+// | 2 byte   | 1 byte   	| 8 字节 	   	|  8 字节	   | 2 字节		|
+// ---------------------
+
+var TableSubscriberHistory = struct {
+	Id     [2]byte
+	Size   int
+	Column struct {
+		JoinSeq    [2]byte // 最近一次加入时的消息序号（加入后第一条可见的消息）
+		LeaveSeq   [2]byte // 最近一次离开时的消息序号（离开后第一条不可见的消息）
+		PrevRanges [2]byte // 之前加入并已离开的范围
+	}
+}{
+	Id:   [2]byte{0x17, 0x01},
+	Size: 2 + 2 + 8 + 8 + 2, // tableId + dataType  + channel hash + uid hash + columnKey
+	Column: struct {
+		JoinSeq    [2]byte
+		LeaveSeq   [2]byte
+		PrevRanges [2]byte
+	}{
+		JoinSeq:    [2]byte{0x17, 0x01},
+		LeaveSeq:   [2]byte{0x17, 0x02},
+		PrevRanges: [2]byte{0x17, 0x03},
+	},
+}
+
+// ======================== messageVisibility ========================
+// 用户在频道内的消息可见性（仅对自己生效的删除和清空）
+// ---------------------
+// | tableID  | dataType	| channel hash | uid hash   | columnKey |
+// | 2 byte   | 1 byte   	| 8 字节 	   	|  8 字节	   | 2 字节		|
+// ---------------------
+
+var TableMessageVisibility = struct {
+	Id     [2]byte
+	Size   int
+	Column struct {
+		ClearedToSeq [2]byte // 清空至的消息序号
+		UpdatedAt    [2]byte // 更新时间
+	}
+}{
+	Id:   [2]byte{0x18, 0x01},
+	Size: 2 + 2 + 8 + 8 + 2, // tableId + dataType  + channel hash + uid hash + columnKey
+	Column: struct {
+		ClearedToSeq [2]byte
+		UpdatedAt    [2]byte
+	}{
+		ClearedToSeq: [2]byte{0x18, 0x01},
+		UpdatedAt:    [2]byte{0x18, 0x02},
+	},
+}
+
+// ======================== hiddenMessage ========================
+// 用户隐藏的消息，按消息序号排序，方便按范围查询和清空时删除
+// ---------------------
+// | tableID  | dataType	| channel hash | uid hash   | messageSeq | messageId |
+// | 2 byte   | 1 byte   	| 8 字节 	   	|  8 字节	   | 8 字节		| 8 字节		|
+// ---------------------
+
+var TableHiddenMessage = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x19, 0x01},
+	Size: 2 + 2 + 8 + 8 + 8 + 8, // tableId + dataType  + channel hash + uid hash + messageSeq + messageId
+}
+
+// ======================== e2eeKeyBundle ========================
+// 用户设备的端到端加密公钥包
+// ---------------------
+// | tableID  | dataType	| uid hash   | deviceId hash |
+// | 2 byte   | 1 byte   	|  8 字节	   | 8 字节		   |
+// ---------------------
+
+var TableE2eeKeyBundle = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x1A, 0x01},
+	Size: 2 + 2 + 8 + 8, // tableId + dataType + uid hash + deviceId hash
+}
+
+// ======================== e2eeOnetimePrekey ========================
+// 用户设备的一次性预共享公钥
+// ---------------------
+// | tableID  | dataType	| uid hash   | deviceId hash | keyId  |
+// | 2 byte   | 1 byte   	|  8 字节	   | 8 字节		   | 4 字节	|
+// ---------------------
+
+var TableE2eeOnetimePrekey = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x1B, 0x01},
+	Size: 2 + 2 + 8 + 8 + 4, // tableId + dataType + uid hash + deviceId hash + keyId
+}
+
+// ======================== messageColdSegment ========================
+// 频道已转存到冷存储的消息段（按段的结束seq排序，方便通过seq查找所在的段）
+// ---------------------
+// | tableID  | dataType	| channel hash | endSeq  |
+// | 2 byte   | 1 byte   	|  8 字节	     | 8 字节	 |
+// ---------------------
+
+var TableMessageColdSegment = struct {
+	Id   [2]byte
+	Size int
+}{
+	Id:   [2]byte{0x1C, 0x01},
+	Size: 2 + 2 + 8 + 8, // tableId + dataType + channel hash + endSeq
+}
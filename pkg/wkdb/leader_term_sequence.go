@@ -85,3 +85,25 @@ func (wk *wukongDB) DeleteLeaderTermStartIndexGreaterThanTerm(shardNo string, te
 
 	return wk.shardDB(shardNo).DeleteRange(key.NewLeaderTermSequenceTermKey(shardNo, term+1), key.NewLeaderTermSequenceTermKey(shardNo, math.MaxUint32), wk.sync)
 }
+
+func (wk *wukongDB) LeaderTermStartIndexes(shardNo string) ([]LeaderTermStartIndex, error) {
+
+	iter := wk.shardDB(shardNo).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewLeaderTermSequenceTermKey(shardNo, 0),
+		UpperBound: key.NewLeaderTermSequenceTermKey(shardNo, math.MaxUint32),
+	})
+	defer iter.Close()
+
+	indexes := make([]LeaderTermStartIndex, 0)
+	for iter.First(); iter.Valid(); iter.Next() {
+		term, err := key.ParseLeaderTermSequenceTermKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, LeaderTermStartIndex{
+			Term:       term,
+			StartIndex: wk.endian.Uint64(iter.Value()),
+		})
+	}
+	return indexes, nil
+}
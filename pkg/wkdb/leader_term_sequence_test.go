@@ -3,6 +3,7 @@ package wkdb_test
 import (
 	"testing"
 
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -50,6 +51,15 @@ func TestSetLeaderTermSequence(t *testing.T) {
 		assert.Equal(t, term2, tm)
 	})
 
+	t.Run("LeaderTermStartIndexes", func(t *testing.T) {
+		indexes, err := d.LeaderTermStartIndexes(shardNo)
+		assert.NoError(t, err)
+		assert.Equal(t, []wkdb.LeaderTermStartIndex{
+			{Term: term, StartIndex: index},
+			{Term: term2, StartIndex: index2},
+		}, indexes)
+	})
+
 	t.Run("DeleteLeaderTermStartIndexGreaterThanTerm", func(t *testing.T) {
 		err := d.DeleteLeaderTermStartIndexGreaterThanTerm(shardNo, term)
 		assert.NoError(t, err)
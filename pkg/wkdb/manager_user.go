@@ -0,0 +1,189 @@
+package wkdb
+
+import (
+	"math"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) AddOrUpdateManagerUser(user ManagerUser) error {
+
+	wk.metrics.AddOrUpdateManagerUserAdd(1)
+
+	user.Id = key.HashWithString(user.Username)
+
+	w := wk.defaultShardDB().NewBatch()
+	defer w.Close()
+	if err := wk.writeManagerUser(user, w); err != nil {
+		return err
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) RemoveManagerUser(username string) error {
+
+	wk.metrics.RemoveManagerUserAdd(1)
+
+	id := key.HashWithString(username)
+	w := wk.defaultShardDB().NewBatch()
+	defer w.Close()
+	if err := w.DeleteRange(key.NewManagerUserColumnKey(id, key.MinColumnKey), key.NewManagerUserColumnKey(id, key.MaxColumnKey), wk.noSync); err != nil {
+		return err
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) GetManagerUser(username string) (ManagerUser, error) {
+
+	wk.metrics.GetManagerUsersAdd(1)
+
+	id := key.HashWithString(username)
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewManagerUserColumnKey(id, key.MinColumnKey),
+		UpperBound: key.NewManagerUserColumnKey(id, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var user = EmptyManagerUser
+	err := wk.iterManagerUser(iter, func(u ManagerUser) bool {
+		user = u
+		return false
+	})
+	if err != nil {
+		return EmptyManagerUser, err
+	}
+	if IsEmptyManagerUser(user) {
+		return EmptyManagerUser, ErrNotFound
+	}
+	return user, nil
+}
+
+func (wk *wukongDB) GetManagerUsers() ([]ManagerUser, error) {
+
+	wk.metrics.GetManagerUsersAdd(1)
+
+	iter := wk.defaultShardDB().NewIter(&pebble.IterOptions{
+		LowerBound: key.NewManagerUserColumnKey(0, key.MinColumnKey),
+		UpperBound: key.NewManagerUserColumnKey(math.MaxUint64, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var users []ManagerUser
+	err := wk.iterManagerUser(iter, func(u ManagerUser) bool {
+		users = append(users, u)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (wk *wukongDB) writeManagerUser(u ManagerUser, w pebble.Writer) error {
+	var err error
+	// username
+	if err = w.Set(key.NewManagerUserColumnKey(u.Id, key.TableManagerUser.Column.Username), []byte(u.Username), wk.noSync); err != nil {
+		return err
+	}
+	// passwordHash
+	if err = w.Set(key.NewManagerUserColumnKey(u.Id, key.TableManagerUser.Column.PasswordHash), []byte(u.PasswordHash), wk.noSync); err != nil {
+		return err
+	}
+	// role
+	if err = w.Set(key.NewManagerUserColumnKey(u.Id, key.TableManagerUser.Column.Role), []byte(u.Role), wk.noSync); err != nil {
+		return err
+	}
+	// disabled
+	var disabled uint8
+	if u.Disabled {
+		disabled = 1
+	}
+	if err = w.Set(key.NewManagerUserColumnKey(u.Id, key.TableManagerUser.Column.Disabled), []byte{disabled}, wk.noSync); err != nil {
+		return err
+	}
+	// source
+	if err = w.Set(key.NewManagerUserColumnKey(u.Id, key.TableManagerUser.Column.Source), []byte(u.Source), wk.noSync); err != nil {
+		return err
+	}
+	// createdAt
+	if u.CreatedAt != nil {
+		if err = wk.writeManagerUserTime(u.Id, key.TableManagerUser.Column.CreatedAt, u.CreatedAt, w); err != nil {
+			return err
+		}
+	}
+	// updatedAt
+	if u.UpdatedAt != nil {
+		if err = wk.writeManagerUserTime(u.Id, key.TableManagerUser.Column.UpdatedAt, u.UpdatedAt, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (wk *wukongDB) writeManagerUserTime(id uint64, columnName [2]byte, t *time.Time, w pebble.Writer) error {
+	tmBytes := make([]byte, 8)
+	wk.endian.PutUint64(tmBytes, uint64(t.UnixNano()))
+	return w.Set(key.NewManagerUserColumnKey(id, columnName), tmBytes, wk.noSync)
+}
+
+func (wk *wukongDB) iterManagerUser(iter *pebble.Iterator, iterFnc func(u ManagerUser) bool) error {
+	var (
+		preId          uint64
+		preUser        ManagerUser
+		lastNeedAppend bool = true
+		hasData        bool = false
+	)
+
+	parseTime := func(v []byte) *time.Time {
+		tm := int64(wk.endian.Uint64(v))
+		if tm <= 0 {
+			return nil
+		}
+		t := time.Unix(tm/1e9, tm%1e9)
+		return &t
+	}
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		primaryKey, columnName, err := key.ParseManagerUserColumnKey(iter.Key())
+		if err != nil {
+			return err
+		}
+
+		if preId != primaryKey {
+			if preId != 0 {
+				if !iterFnc(preUser) {
+					lastNeedAppend = false
+					break
+				}
+			}
+			preId = primaryKey
+			preUser = ManagerUser{Id: primaryKey}
+		}
+
+		switch columnName {
+		case key.TableManagerUser.Column.Username:
+			preUser.Username = string(iter.Value())
+		case key.TableManagerUser.Column.PasswordHash:
+			preUser.PasswordHash = string(iter.Value())
+		case key.TableManagerUser.Column.Role:
+			preUser.Role = string(iter.Value())
+		case key.TableManagerUser.Column.Disabled:
+			preUser.Disabled = len(iter.Value()) > 0 && iter.Value()[0] == 1
+		case key.TableManagerUser.Column.Source:
+			preUser.Source = string(iter.Value())
+		case key.TableManagerUser.Column.CreatedAt:
+			preUser.CreatedAt = parseTime(iter.Value())
+		case key.TableManagerUser.Column.UpdatedAt:
+			preUser.UpdatedAt = parseTime(iter.Value())
+		}
+		lastNeedAppend = true
+		hasData = true
+	}
+
+	if lastNeedAppend && hasData {
+		_ = iterFnc(preUser)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package wkdb_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddOrUpdateAndGetManagerUsers(t *testing.T) {
+
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	createdAt := time.Now()
+	err = d.AddOrUpdateManagerUser(wkdb.ManagerUser{
+		Username:     "alice",
+		PasswordHash: "hash1",
+		Role:         "admin",
+		Source:       "local",
+		CreatedAt:    &createdAt,
+		UpdatedAt:    &createdAt,
+	})
+	assert.NoError(t, err)
+
+	err = d.AddOrUpdateManagerUser(wkdb.ManagerUser{
+		Username: "bob",
+		Role:     "viewer",
+		Source:   "oidc",
+	})
+	assert.NoError(t, err)
+
+	user, err := d.GetManagerUser("alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "hash1", user.PasswordHash)
+	assert.Equal(t, "admin", user.Role)
+	assert.Equal(t, createdAt.UnixNano(), user.CreatedAt.UnixNano())
+	assert.False(t, user.Disabled)
+
+	// 禁用并修改角色
+	user.Disabled = true
+	user.Role = "operator"
+	err = d.AddOrUpdateManagerUser(user)
+	assert.NoError(t, err)
+
+	user, err = d.GetManagerUser("alice")
+	assert.NoError(t, err)
+	assert.True(t, user.Disabled)
+	assert.Equal(t, "operator", user.Role)
+
+	users, err := d.GetManagerUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(users))
+
+	err = d.RemoveManagerUser("alice")
+	assert.NoError(t, err)
+
+	_, err = d.GetManagerUser("alice")
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	users, err = d.GetManagerUsers()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(users))
+	assert.Equal(t, "oidc", users[0].Source)
+}
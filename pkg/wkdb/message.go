@@ -34,8 +34,9 @@ func (wk *wukongDB) AppendMessages(channelId string, channelType uint8, msgs []M
 	}
 
 	batch := wk.channelBatchDb(channelId, channelType).NewBatch()
+	sizes := make(channelMessageSizes)
 	for _, msg := range msgs {
-		if err := wk.writeMessage(channelId, channelType, msg, batch); err != nil {
+		if err := wk.writeMessage(channelId, channelType, msg, sizes, batch); err != nil {
 			return err
 		}
 		err := wk.setChannelLastMessageSeq(channelId, channelType, uint64(msg.MessageSeq), batch)
@@ -55,6 +56,7 @@ func (wk *wukongDB) AppendMessages(channelId string, channelType uint8, msgs []M
 
 func (wk *wukongDB) AppendMessagesByLogs(reqs []reactor.AppendLogReq) {
 	batchs := []*Batch{}
+	sizes := make(channelMessageSizes)
 
 	for _, req := range reqs {
 
@@ -84,7 +86,7 @@ func (wk *wukongDB) AppendMessagesByLogs(reqs []reactor.AppendLogReq) {
 			msg.MessageSeq = uint32(log.Index)
 			msg.Term = uint64(log.Term)
 
-			if err := wk.writeMessage(channelId, channelType, msg, batch); err != nil {
+			if err := wk.writeMessage(channelId, channelType, msg, sizes, batch); err != nil {
 				wk.Panic("write message failed", zap.Error(err))
 				return
 			}
@@ -160,11 +162,12 @@ func (wk *wukongDB) AppendMessagesBatch(reqs []AppendMessagesReq) error {
 	}
 
 	batchs := make([]*Batch, 0, len(dbMap))
+	sizes := make(channelMessageSizes)
 
 	for _, req := range reqs {
 		batch := wk.channelBatchDb(req.ChannelId, req.ChannelType).NewBatch()
 		for _, msg := range req.Messages {
-			if err := wk.writeMessage(req.ChannelId, req.ChannelType, msg, batch); err != nil {
+			if err := wk.writeMessage(req.ChannelId, req.ChannelType, msg, sizes, batch); err != nil {
 				return err
 			}
 		}
@@ -325,6 +328,14 @@ func (wk *wukongDB) LoadPrevRangeMsgs(channelId string, channelType uint8, start
 	defer iter.Close()
 
 	msgs := make([]Message, 0)
+	// 冷存储的消息（冷存储的消息总是在本地消息之前）
+	err = wk.iterColdMessages(channelId, channelType, minSeq, maxSeq, func(m Message) bool {
+		msgs = append(msgs, m)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
 	err = wk.iteratorChannelMessages(iter, limit, func(m Message) bool {
 		msgs = append(msgs, m)
 		return true
@@ -365,7 +376,23 @@ func (wk *wukongDB) LoadNextRangeMsgs(channelId string, channelType uint8, start
 
 	msgs := make([]Message, 0)
 
-	err = wk.iteratorChannelMessages(iter, limit, func(m Message) bool {
+	// 冷存储的消息（冷存储的消息总是在本地消息之前）
+	err = wk.iterColdMessages(channelId, channelType, minSeq, maxSeq, func(m Message) bool {
+		msgs = append(msgs, m)
+		return limit == 0 || len(msgs) < limit
+	})
+	if err != nil {
+		return nil, err
+	}
+	hotLimit := limit
+	if limit != 0 {
+		if len(msgs) >= limit {
+			return msgs, nil
+		}
+		hotLimit = limit - len(msgs)
+	}
+
+	err = wk.iteratorChannelMessages(iter, hotLimit, func(m Message) bool {
 		msgs = append(msgs, m)
 		return true
 	})
@@ -396,7 +423,17 @@ func (wk *wukongDB) LoadMsg(channelId string, channelType uint8, seq uint64) (Me
 		return EmptyMessage, err
 	}
 	if IsEmptyMessage(msg) {
-		return EmptyMessage, ErrNotFound
+		// 本地没有，可能已经转存到冷存储
+		err = wk.iterColdMessages(channelId, channelType, seq, seq+1, func(m Message) bool {
+			msg = m
+			return false
+		})
+		if err != nil {
+			return EmptyMessage, err
+		}
+		if IsEmptyMessage(msg) {
+			return EmptyMessage, ErrNotFound
+		}
 	}
 	return msg, nil
 
@@ -451,6 +488,28 @@ func (wk *wukongDB) LoadNextRangeMsgsForSize(channelId string, channelType uint8
 	if endMessageSeq == 0 {
 		maxSeq = math.MaxUint64
 	}
+
+	// 冷存储的消息（冷存储的消息总是在本地消息之前）
+	var (
+		coldMsgs []Message
+		coldSize uint64
+	)
+	err := wk.iterColdMessages(channelId, channelType, minSeq, maxSeq, func(m Message) bool {
+		coldMsgs = append(coldMsgs, m)
+		coldSize += uint64(m.Size())
+		return limitSize == 0 || coldSize < limitSize
+	})
+	if err != nil {
+		return nil, err
+	}
+	hotLimitSize := limitSize
+	if limitSize != 0 {
+		if coldSize >= limitSize {
+			return coldMsgs, nil
+		}
+		hotLimitSize = limitSize - coldSize
+	}
+
 	db := wk.channelDb(channelId, channelType)
 
 	iter := db.NewIter(&pebble.IterOptions{
@@ -458,7 +517,14 @@ func (wk *wukongDB) LoadNextRangeMsgsForSize(channelId string, channelType uint8
 		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, maxSeq),
 	})
 	defer iter.Close()
-	return wk.parseChannelMessagesWithLimitSize(iter, limitSize)
+	msgs, err := wk.parseChannelMessagesWithLimitSize(iter, hotLimitSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(coldMsgs) == 0 {
+		return msgs, nil
+	}
+	return append(coldMsgs, msgs...), nil
 }
 
 func (wk *wukongDB) TruncateLogTo(channelId string, channelType uint8, messageSeq uint64) error {
@@ -490,9 +556,16 @@ func (wk *wukongDB) TruncateLogTo(channelId string, channelType uint8, messageSe
 		}()
 	}
 
+	// 频道消息内容的累计字节数回退到截断的消息之前（升级前写入的消息没有累计字节数，回退为0）
+	payloadOffset, _, err := wk.getMessagePayloadOffset(channelId, channelType, messageSeq)
+	if err != nil {
+		return err
+	}
+
 	db := wk.channelBatchDb(channelId, channelType)
 	batch := db.NewBatch()
 	batch.DeleteRange(key.NewMessagePrimaryKey(channelId, channelType, messageSeq), key.NewMessagePrimaryKey(channelId, channelType, math.MaxUint64))
+	wk.setChannelMessageSize(channelId, channelType, payloadOffset, batch)
 
 	batch.DeleteRange(key.NewMessagePrimaryKey(channelId, channelType, messageSeq), key.NewMessagePrimaryKey(channelId, channelType, math.MaxUint64))
 
@@ -1014,7 +1087,7 @@ func (wk *wukongDB) parseChannelMessagesWithLimitSize(iter *pebble.Iterator, lim
 
 }
 
-func (wk *wukongDB) writeMessage(channelId string, channelType uint8, msg Message, w *Batch) error {
+func (wk *wukongDB) writeMessage(channelId string, channelType uint8, msg Message, sizes channelMessageSizes, w *Batch) error {
 
 	var (
 		messageIdBytes = make([]byte, 8)
@@ -1070,6 +1143,16 @@ func (wk *wukongDB) writeMessage(channelId string, channelType uint8, msg Messag
 	wk.endian.PutUint64(termBytes, msg.Term)
 	w.Set(key.NewMessageColumnKey(channelId, channelType, uint64(msg.MessageSeq), key.TableMessage.Column.Term), termBytes)
 
+	// payloadOffset
+	payloadOffset, err := wk.nextPayloadOffset(sizes, channelId, channelType, len(msg.Payload))
+	if err != nil {
+		return err
+	}
+	payloadOffsetBytes := make([]byte, 8)
+	wk.endian.PutUint64(payloadOffsetBytes, payloadOffset)
+	w.Set(key.NewMessageColumnKey(channelId, channelType, uint64(msg.MessageSeq), key.TableMessage.Column.PayloadOffset), payloadOffsetBytes)
+	wk.setChannelMessageSize(channelId, channelType, payloadOffset+uint64(len(msg.Payload)), w)
+
 	var primaryValue = [16]byte{}
 	wk.endian.PutUint64(primaryValue[:], key.ChannelToNum(channelId, channelType))
 	wk.endian.PutUint64(primaryValue[8:], uint64(msg.MessageSeq))
@@ -1086,5 +1169,8 @@ func (wk *wukongDB) writeMessage(channelId string, channelType uint8, msg Messag
 	// index timestamp
 	w.Set(key.NewMessageIndexTimestampKey(uint64(msg.Timestamp), primaryValue), nil)
 
+	// index channel timestamp
+	w.Set(key.NewMessageSecondIndexChannelTimestampKey(channelId, channelType, uint64(msg.Timestamp), uint64(msg.MessageSeq)), nil)
+
 	return nil
 }
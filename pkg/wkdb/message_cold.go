@@ -0,0 +1,546 @@
+package wkdb
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// 冷存储
+//
+// 频道最早的消息按 ColdSegmentMaxCount 条打包成不可变的消息段，压缩后写入对象存储（BlobStore），
+// 然后在同一个批次里写入消息段的元数据并删除本地的消息以及消息索引，所以冷热数据不会重叠，冷数据总是在热数据之前。
+// 加载消息时如果范围包含冷数据，从对象存储获取消息段（内存中缓存最近使用的消息段）再与热数据合并。
+// 每个副本各自转存，相同消息的消息段内容相同，所以多个副本可以共用同一个对象存储。
+
+var ErrColdStoreNotConfigured = errors.New("cold store not configured")
+
+var coldSegmentMagic = []byte("WKSG")
+
+const coldSegmentVersion uint8 = 1
+
+func (wk *wukongDB) OffloadMessagesTo(channelId string, channelType uint8, messageSeq uint64) (uint64, error) {
+
+	wk.metrics.OffloadMessagesToAdd(1)
+
+	if wk.opts.ColdStore == nil {
+		return 0, ErrColdStoreNotConfigured
+	}
+
+	// 最新的一条消息必须保留在本地（遍历频道以及获取频道信息依赖最新的消息）
+	lastSeq, _, err := wk.GetChannelLastMessageSeq(channelId, channelType)
+	if err != nil {
+		return 0, err
+	}
+	if lastSeq == 0 {
+		return 0, nil
+	}
+	if messageSeq >= lastSeq {
+		messageSeq = lastSeq - 1
+	}
+
+	segmentMaxCount := uint64(wk.opts.ColdSegmentMaxCount)
+	if segmentMaxCount == 0 {
+		segmentMaxCount = 1000
+	}
+
+	var offloadedSeq uint64
+	for {
+		select {
+		case <-wk.cancelCtx.Done():
+			return offloadedSeq, nil
+		default:
+		}
+
+		firstSeq, err := wk.firstHotMessageSeq(channelId, channelType)
+		if err != nil {
+			return offloadedSeq, err
+		}
+		// 只转存满的消息段，剩下的等消息足够了再转存
+		if firstSeq == 0 || firstSeq+segmentMaxCount-1 > messageSeq {
+			break
+		}
+		endSeq, err := wk.offloadSegment(channelId, channelType, firstSeq, firstSeq+segmentMaxCount)
+		if err != nil {
+			return offloadedSeq, err
+		}
+		if endSeq == 0 {
+			break
+		}
+		offloadedSeq = endSeq
+	}
+	return offloadedSeq, nil
+}
+
+// 转存[startSeq,endSeq)范围的本地消息为一个消息段，返回消息段的结束seq
+func (wk *wukongDB) offloadSegment(channelId string, channelType uint8, startSeq, endSeq uint64) (uint64, error) {
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessagePrimaryKey(channelId, channelType, startSeq),
+		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, endSeq),
+	})
+	msgs, err := wk.parseChannelMessagesWithLimitSize(iter, 0)
+	iter.Close()
+	if err != nil {
+		return 0, err
+	}
+	if len(msgs) == 0 {
+		return 0, nil
+	}
+
+	segment := MessageColdSegment{
+		ChannelId:    channelId,
+		ChannelType:  channelType,
+		StartSeq:     uint64(msgs[0].MessageSeq),
+		EndSeq:       uint64(msgs[len(msgs)-1].MessageSeq),
+		Count:        uint32(len(msgs)),
+		MinTimestamp: msgs[0].Timestamp,
+		MaxTimestamp: msgs[0].Timestamp,
+		CreatedAt:    time.Now().UnixNano(),
+	}
+	for _, m := range msgs {
+		segment.PayloadSize += uint64(len(m.Payload))
+		if m.Timestamp < segment.MinTimestamp {
+			segment.MinTimestamp = m.Timestamp
+		}
+		if m.Timestamp > segment.MaxTimestamp {
+			segment.MaxTimestamp = m.Timestamp
+		}
+	}
+	segment.BlobKey = coldSegmentBlobKey(wk.opts.NodeId, channelId, channelType, segment.StartSeq, segment.EndSeq)
+
+	data, err := encodeColdSegment(msgs)
+	if err != nil {
+		return 0, err
+	}
+	if err = wk.opts.ColdStore.Put(wk.cancelCtx, segment.BlobKey, data); err != nil {
+		return 0, err
+	}
+
+	batch := wk.channelBatchDb(channelId, channelType).NewBatch()
+	batch.Set(key.NewMessageColdSegmentKey(channelId, channelType, segment.EndSeq), segment.Encode())
+	wk.deleteHotMessages(batch, channelId, channelType, msgs)
+	if err = batch.CommitWait(); err != nil {
+		return 0, err
+	}
+
+	wk.metrics.OffloadedMessagesAdd(int64(len(msgs)))
+	wk.Debug("offload messages", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("startSeq", segment.StartSeq), zap.Uint64("endSeq", segment.EndSeq), zap.String("blobKey", segment.BlobKey))
+
+	return segment.EndSeq, nil
+}
+
+// 删除本地的消息以及消息索引（msgs为连续的消息）
+func (wk *wukongDB) deleteHotMessages(batch *Batch, channelId string, channelType uint8, msgs []Message) {
+	if len(msgs) == 0 {
+		return
+	}
+	channelNum := key.ChannelToNum(channelId, channelType)
+	for _, m := range msgs {
+		var primaryValue [16]byte
+		wk.endian.PutUint64(primaryValue[:], channelNum)
+		wk.endian.PutUint64(primaryValue[8:], uint64(m.MessageSeq))
+		batch.Delete(key.NewMessageSecondIndexFromUidKey(m.FromUID, primaryValue))
+		batch.Delete(key.NewMessageIndexMessageIdKey(uint64(m.MessageID)))
+		batch.Delete(key.NewMessageSecondIndexClientMsgNoKey(m.ClientMsgNo, primaryValue))
+		batch.Delete(key.NewMessageIndexTimestampKey(uint64(m.Timestamp), primaryValue))
+		batch.Delete(key.NewMessageSecondIndexChannelTimestampKey(channelId, channelType, uint64(m.Timestamp), uint64(m.MessageSeq)))
+	}
+	batch.DeleteRange(key.NewMessagePrimaryKey(channelId, channelType, uint64(msgs[0].MessageSeq)), key.NewMessagePrimaryKey(channelId, channelType, uint64(msgs[len(msgs)-1].MessageSeq)+1))
+}
+
+func (wk *wukongDB) GetMessageColdSegments(channelId string, channelType uint8) ([]MessageColdSegment, error) {
+	var segments []MessageColdSegment
+	err := wk.iterColdSegments(channelId, channelType, 0, func(segment MessageColdSegment) bool {
+		segments = append(segments, segment)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return segments, nil
+}
+
+// 按seq升序遍历结束seq大于等于minEndSeq的消息段
+func (wk *wukongDB) iterColdSegments(channelId string, channelType uint8, minEndSeq uint64, iterFnc func(segment MessageColdSegment) bool) error {
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessageColdSegmentKey(channelId, channelType, minEndSeq),
+		UpperBound: key.NewMessageColdSegmentKey(channelId, channelType, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		var segment MessageColdSegment
+		if err := segment.Decode(iter.Value()); err != nil {
+			return err
+		}
+		if !iterFnc(segment) {
+			break
+		}
+	}
+	return nil
+}
+
+// 按seq升序遍历[minSeq,maxSeq)范围内的冷存储消息
+func (wk *wukongDB) iterColdMessages(channelId string, channelType uint8, minSeq, maxSeq uint64, iterFnc func(m Message) bool) error {
+	var (
+		iterErr error
+		stop    bool
+	)
+	err := wk.iterColdSegments(channelId, channelType, minSeq, func(segment MessageColdSegment) bool {
+		if segment.StartSeq >= maxSeq {
+			return false
+		}
+		msgs, err := wk.loadColdSegment(segment)
+		if err != nil {
+			iterErr = err
+			return false
+		}
+		for _, m := range msgs {
+			seq := uint64(m.MessageSeq)
+			if seq < minSeq {
+				continue
+			}
+			if seq >= maxSeq {
+				return false
+			}
+			if !iterFnc(m) {
+				stop = true
+				return false
+			}
+		}
+		return !stop
+	})
+	if err != nil {
+		return err
+	}
+	return iterErr
+}
+
+// 获取消息段的消息（优先从缓存获取）
+func (wk *wukongDB) loadColdSegment(segment MessageColdSegment) ([]Message, error) {
+	if msgs, ok := wk.coldCache.get(segment.BlobKey); ok {
+		wk.metrics.ColdSegmentCacheHitAdd(1)
+		return msgs, nil
+	}
+	if wk.opts.ColdStore == nil {
+		return nil, ErrColdStoreNotConfigured
+	}
+
+	wk.metrics.LoadColdSegmentAdd(1)
+
+	data, err := wk.opts.ColdStore.Get(wk.cancelCtx, segment.BlobKey)
+	if err != nil {
+		if err == ErrNotFound { // 其他副本按保留策略已经删除了此消息段
+			wk.Warn("cold segment not found", zap.String("blobKey", segment.BlobKey))
+			return nil, nil
+		}
+		return nil, err
+	}
+	msgs, err := decodeColdSegment(data)
+	if err != nil {
+		return nil, fmt.Errorf("decode cold segment[%s] failed: %w", segment.BlobKey, err)
+	}
+	wk.coldCache.add(segment.BlobKey, msgs)
+	return msgs, nil
+}
+
+// 删除结束seq小于等于messageSeq的消息段（消息段只能整体删除），返回删除到的消息seq以及删除的消息数量
+func (wk *wukongDB) purgeColdSegments(channelId string, channelType uint8, messageSeq uint64) (uint64, int, error) {
+	var segments []MessageColdSegment
+	err := wk.iterColdSegments(channelId, channelType, 0, func(segment MessageColdSegment) bool {
+		if segment.EndSeq > messageSeq {
+			return false
+		}
+		segments = append(segments, segment)
+		return true
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(segments) == 0 {
+		return 0, 0, nil
+	}
+	var count int
+	batch := wk.channelBatchDb(channelId, channelType).NewBatch()
+	for _, segment := range segments {
+		if wk.opts.ColdStore != nil {
+			if err = wk.opts.ColdStore.Delete(wk.cancelCtx, segment.BlobKey); err != nil {
+				return 0, 0, err
+			}
+		}
+		wk.coldCache.remove(segment.BlobKey)
+		batch.Delete(key.NewMessageColdSegmentKey(channelId, channelType, segment.EndSeq))
+		count += int(segment.Count)
+	}
+	if err = batch.CommitWait(); err != nil {
+		return 0, 0, err
+	}
+	return segments[len(segments)-1].EndSeq, count, nil
+}
+
+// 第一条冷存储消息的seq，没有返回0
+func (wk *wukongDB) firstColdMessageSeq(channelId string, channelType uint8) (uint64, error) {
+	var firstSeq uint64
+	err := wk.iterColdSegments(channelId, channelType, 0, func(segment MessageColdSegment) bool {
+		firstSeq = segment.StartSeq
+		return false
+	})
+	return firstSeq, err
+}
+
+// 第一条本地消息的seq，没有返回0
+func (wk *wukongDB) firstHotMessageSeq(channelId string, channelType uint8) (uint64, error) {
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessagePrimaryKey(channelId, channelType, 0),
+		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	if !iter.First() {
+		return 0, nil
+	}
+	messageSeq, _, err := key.ParseMessageColumnKey(iter.Key())
+	if err != nil {
+		return 0, err
+	}
+	return messageSeq, nil
+}
+
+// 消息段在对象存储的key
+// 每个副本各自卸载和清理自己的消息段，key中带上节点id，多个节点共用一个存储桶时清理消息段不会删除其他副本仍在引用的消息段
+// （消息段的元数据中记录了完整的key，老版本没有节点id的消息段仍然可以读取）
+func coldSegmentBlobKey(nodeId uint64, channelId string, channelType uint8, startSeq, endSeq uint64) string {
+	return fmt.Sprintf("segments/%d/%d/%s/%020d-%020d.seg", nodeId, channelType, base64.RawURLEncoding.EncodeToString([]byte(channelId)), startSeq, endSeq)
+}
+
+// 消息段格式： magic(4) + version(1) + crc32(4) + zstd(消息数量 + 消息...)
+func encodeColdSegment(msgs []Message) ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(msgs)))
+	for _, m := range msgs {
+		enc.WriteUint8(wkproto.ToFixHeaderUint8(m.RecvPacket.Framer))
+		enc.WriteUint8(m.Setting.Uint8())
+		enc.WriteUint32(m.Expire)
+		enc.WriteInt64(m.MessageID)
+		enc.WriteUint32(m.MessageSeq)
+		enc.WriteString(m.ClientMsgNo)
+		enc.WriteInt32(m.Timestamp)
+		enc.WriteString(m.ChannelID)
+		enc.WriteUint8(m.ChannelType)
+		enc.WriteString(m.Topic)
+		enc.WriteString(m.FromUID)
+		enc.WriteBinary(m.Payload)
+		enc.WriteUint64(m.Term)
+	}
+	compressed, err := wkutil.Compress(wkutil.CompressionZstd, nil, enc.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, 0, len(coldSegmentMagic)+5+len(compressed))
+	data = append(data, coldSegmentMagic...)
+	data = append(data, coldSegmentVersion)
+	data = binary.BigEndian.AppendUint32(data, crc32.ChecksumIEEE(compressed))
+	data = append(data, compressed...)
+	return data, nil
+}
+
+func decodeColdSegment(data []byte) ([]Message, error) {
+	headerLen := len(coldSegmentMagic) + 5
+	if len(data) < headerLen || !bytes.Equal(data[:len(coldSegmentMagic)], coldSegmentMagic) {
+		return nil, errors.New("invalid cold segment")
+	}
+	if data[len(coldSegmentMagic)] != coldSegmentVersion {
+		return nil, fmt.Errorf("unsupported cold segment version: %d", data[len(coldSegmentMagic)])
+	}
+	checksum := binary.BigEndian.Uint32(data[headerLen-4 : headerLen])
+	compressed := data[headerLen:]
+	if crc32.ChecksumIEEE(compressed) != checksum {
+		return nil, errors.New("cold segment checksum mismatch")
+	}
+	body, err := wkutil.Decompress(wkutil.CompressionZstd, compressed)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := wkproto.NewDecoder(body)
+	count, err := dec.Uint32()
+	if err != nil {
+		return nil, err
+	}
+	msgs := make([]Message, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var (
+			m       Message
+			header  uint8
+			setting uint8
+		)
+		if header, err = dec.Uint8(); err != nil {
+			return nil, err
+		}
+		m.Framer = wkproto.FramerFromUint8(header)
+		if setting, err = dec.Uint8(); err != nil {
+			return nil, err
+		}
+		m.Setting = wkproto.Setting(setting)
+		if m.Expire, err = dec.Uint32(); err != nil {
+			return nil, err
+		}
+		if m.MessageID, err = dec.Int64(); err != nil {
+			return nil, err
+		}
+		if m.MessageSeq, err = dec.Uint32(); err != nil {
+			return nil, err
+		}
+		if m.ClientMsgNo, err = dec.String(); err != nil {
+			return nil, err
+		}
+		if m.Timestamp, err = dec.Int32(); err != nil {
+			return nil, err
+		}
+		if m.ChannelID, err = dec.String(); err != nil {
+			return nil, err
+		}
+		if m.ChannelType, err = dec.Uint8(); err != nil {
+			return nil, err
+		}
+		if m.Topic, err = dec.String(); err != nil {
+			return nil, err
+		}
+		if m.FromUID, err = dec.String(); err != nil {
+			return nil, err
+		}
+		if m.Payload, err = decodeBinaryCopy(dec); err != nil {
+			return nil, err
+		}
+		if m.Term, err = dec.Uint64(); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	return msgs, nil
+}
+
+func (s *MessageColdSegment) Encode() []byte {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteString(s.ChannelId)
+	enc.WriteUint8(s.ChannelType)
+	enc.WriteUint64(s.StartSeq)
+	enc.WriteUint64(s.EndSeq)
+	enc.WriteUint32(s.Count)
+	enc.WriteUint64(s.PayloadSize)
+	enc.WriteInt32(s.MinTimestamp)
+	enc.WriteInt32(s.MaxTimestamp)
+	enc.WriteString(s.BlobKey)
+	enc.WriteInt64(s.CreatedAt)
+	return enc.Bytes()
+}
+
+func (s *MessageColdSegment) Decode(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+	if s.ChannelId, err = dec.String(); err != nil {
+		return err
+	}
+	if s.ChannelType, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if s.StartSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if s.EndSeq, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if s.Count, err = dec.Uint32(); err != nil {
+		return err
+	}
+	if s.PayloadSize, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if s.MinTimestamp, err = dec.Int32(); err != nil {
+		return err
+	}
+	if s.MaxTimestamp, err = dec.Int32(); err != nil {
+		return err
+	}
+	if s.BlobKey, err = dec.String(); err != nil {
+		return err
+	}
+	if s.CreatedAt, err = dec.Int64(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// 最近使用的消息段缓存
+type coldSegmentCache struct {
+	mu       sync.Mutex
+	capacity int
+	ll       *list.List
+	items    map[string]*list.Element
+}
+
+type coldSegmentCacheItem struct {
+	blobKey string
+	msgs    []Message
+}
+
+func newColdSegmentCache(capacity int) *coldSegmentCache {
+	return &coldSegmentCache{
+		capacity: capacity,
+		ll:       list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *coldSegmentCache) get(blobKey string) ([]Message, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[blobKey]; ok {
+		c.ll.MoveToFront(e)
+		return e.Value.(*coldSegmentCacheItem).msgs, true
+	}
+	return nil, false
+}
+
+func (c *coldSegmentCache) add(blobKey string, msgs []Message) {
+	if c.capacity <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[blobKey]; ok {
+		c.ll.MoveToFront(e)
+		e.Value.(*coldSegmentCacheItem).msgs = msgs
+		return
+	}
+	c.items[blobKey] = c.ll.PushFront(&coldSegmentCacheItem{blobKey: blobKey, msgs: msgs})
+	for c.ll.Len() > c.capacity {
+		e := c.ll.Back()
+		c.ll.Remove(e)
+		delete(c.items, e.Value.(*coldSegmentCacheItem).blobKey)
+	}
+}
+
+func (c *coldSegmentCache) remove(blobKey string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[blobKey]; ok {
+		c.ll.Remove(e)
+		delete(c.items, blobKey)
+	}
+}
@@ -0,0 +1,275 @@
+package wkdb_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOffloadMessagesTo(t *testing.T) {
+	coldStore := wkdb.NewLocalBlobStore(t.TempDir())
+	d := newTestDBWithOptions(t, wkdb.WithColdStore(coldStore), wkdb.WithColdSegmentMaxCount(10))
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	channelId := "channel"
+	channelType := uint8(2)
+	num := 25
+	appendTestMessages(t, d, channelId, channelType, num)
+
+	// 只转存满的消息段
+	offloadedSeq, err := d.OffloadMessagesTo(channelId, channelType, 24)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), offloadedSeq)
+
+	segments, err := d.GetMessageColdSegments(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(segments))
+	assert.Equal(t, uint64(1), segments[0].StartSeq)
+	assert.Equal(t, uint64(10), segments[0].EndSeq)
+	assert.Equal(t, uint32(10), segments[0].Count)
+	assert.Equal(t, uint64(50), segments[0].PayloadSize)
+	assert.Equal(t, int32(100), segments[0].MinTimestamp)
+	assert.Equal(t, int32(109), segments[0].MaxTimestamp)
+
+	data, err := coldStore.Get(context.Background(), segments[1].BlobKey)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, data)
+
+	// 跨冷热数据加载
+	msgs, err := d.LoadNextRangeMsgs(channelId, channelType, 5, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 21, len(msgs))
+	for i, m := range msgs {
+		assert.Equal(t, uint32(i+5), m.MessageSeq)
+	}
+	assert.Equal(t, int64(1004), msgs[0].MessageID)
+	assert.Equal(t, []byte("hello"), msgs[0].Payload)
+
+	msgs, err = d.LoadNextRangeMsgs(channelId, channelType, 5, 0, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, len(msgs))
+	assert.Equal(t, uint32(14), msgs[9].MessageSeq)
+
+	msgs, err = d.LoadPrevRangeMsgs(channelId, channelType, 22, 0, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(msgs))
+	assert.Equal(t, uint32(18), msgs[0].MessageSeq)
+	assert.Equal(t, uint32(22), msgs[4].MessageSeq)
+
+	msg, err := d.LoadMsg(channelId, channelType, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), msg.MessageSeq)
+	assert.Equal(t, "uid1", msg.FromUID)
+
+	// 消息大小限制在第一条消息后就满足
+	msgs, err = d.LoadNextRangeMsgsForSize(channelId, channelType, 1, 0, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(msgs))
+
+	msgs, err = d.LoadNextRangeMsgsForSize(channelId, channelType, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, num, len(msgs))
+
+	// 冷热数据合并后的清理条件
+	firstSeq, err := d.GetChannelFirstMessageSeq(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), firstSeq)
+
+	seq, err := d.GetMessageSeqBeforeTimestamp(channelId, channelType, 112)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), seq) // 消息段整体计算
+
+	seq, err = d.GetMessageSeqBeforeTimestamp(channelId, channelType, 123)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(23), seq)
+
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 60)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), seq)
+
+	// 消息段只能整体清理
+	purgedSeq, err := d.PurgeMessagesTo(channelId, channelType, 15, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), purgedSeq)
+
+	_, err = coldStore.Get(context.Background(), segments[0].BlobKey)
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	firstSeq, err = d.GetChannelFirstMessageSeq(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(11), firstSeq)
+
+	_, err = d.LoadMsg(channelId, channelType, 3)
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	purgedSeq, err = d.PurgeMessagesTo(channelId, channelType, 22, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(22), purgedSeq)
+
+	segments, err = d.GetMessageColdSegments(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(segments))
+
+	msgs, err = d.LoadNextRangeMsgs(channelId, channelType, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(msgs))
+	assert.Equal(t, uint32(23), msgs[0].MessageSeq)
+}
+
+func TestOffloadMessagesSharedColdStore(t *testing.T) {
+	// 两个副本共用一个冷存储
+	coldStore := wkdb.NewLocalBlobStore(t.TempDir())
+	d1 := newTestDBWithOptions(t, wkdb.WithNodeId(1), wkdb.WithColdStore(coldStore), wkdb.WithColdSegmentMaxCount(10))
+	d2 := newTestDBWithOptions(t, wkdb.WithNodeId(2), wkdb.WithColdStore(coldStore), wkdb.WithColdSegmentMaxCount(10))
+	for _, d := range []wkdb.DB{d1, d2} {
+		err := d.Open()
+		assert.NoError(t, err)
+		defer func(d wkdb.DB) {
+			err := d.Close()
+			assert.NoError(t, err)
+		}(d)
+	}
+
+	channelId := "channel"
+	channelType := uint8(2)
+	appendTestMessages(t, d1, channelId, channelType, 20)
+	appendTestMessages(t, d2, channelId, channelType, 20)
+
+	_, err := d1.OffloadMessagesTo(channelId, channelType, 20)
+	assert.NoError(t, err)
+	_, err = d2.OffloadMessagesTo(channelId, channelType, 20)
+	assert.NoError(t, err)
+
+	segments1, err := d1.GetMessageColdSegments(channelId, channelType)
+	assert.NoError(t, err)
+	segments2, err := d2.GetMessageColdSegments(channelId, channelType)
+	assert.NoError(t, err)
+	assert.NotEqual(t, segments1[0].BlobKey, segments2[0].BlobKey)
+
+	// 一个副本清理消息段不影响另一个副本
+	_, err = d1.PurgeMessagesTo(channelId, channelType, 10, 0)
+	assert.NoError(t, err)
+	_, err = coldStore.Get(context.Background(), segments1[0].BlobKey)
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	msg, err := d2.LoadMsg(channelId, channelType, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), msg.MessageSeq)
+}
+
+func TestOffloadMessagesToS3(t *testing.T) {
+	client := newMemoryS3Client()
+	d := newTestDBWithOptions(t, wkdb.WithColdStore(wkdb.NewS3BlobStore(client, "bucket", "/cluster1/")), wkdb.WithColdSegmentMaxCount(5), wkdb.WithColdSegmentCacheCount(1))
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	channelId := "channel"
+	channelType := uint8(2)
+	appendTestMessages(t, d, channelId, channelType, 12)
+
+	// 最新的一条消息保留在本地
+	offloadedSeq, err := d.OffloadMessagesTo(channelId, channelType, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), offloadedSeq)
+	assert.Equal(t, 2, client.count("bucket", "cluster1/"))
+
+	// 缓存只有一个消息段，交替加载需要从对象存储获取
+	for i := 0; i < 2; i++ {
+		msg, err := d.LoadMsg(channelId, channelType, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(2), msg.MessageSeq)
+		msg, err = d.LoadMsg(channelId, channelType, 7)
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(7), msg.MessageSeq)
+	}
+
+	msgs, err := d.LoadNextRangeMsgs(channelId, channelType, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 12, len(msgs))
+}
+
+func appendTestMessages(t *testing.T, d wkdb.DB, channelId string, channelType uint8, num int) {
+	messages := make([]wkdb.Message, 0, num)
+	for i := 0; i < num; i++ {
+		messages = append(messages, wkdb.Message{
+			RecvPacket: wkproto.RecvPacket{
+				MessageID:   int64(i + 1000),
+				ClientMsgNo: "clientMsgNo",
+				ChannelID:   channelId,
+				ChannelType: channelType,
+				FromUID:     "uid1",
+				MessageSeq:  uint32(i + 1),
+				Timestamp:   int32(i + 100),
+				Payload:     []byte("hello"),
+			},
+		})
+	}
+	err := d.AppendMessages(channelId, channelType, messages)
+	assert.NoError(t, err)
+	err = d.SetChannelLastMessageSeq(channelId, channelType, uint64(num))
+	assert.NoError(t, err)
+}
+
+// 内存实现的S3客户端
+type memoryS3Client struct {
+	objects map[string][]byte
+	mu      sync.Mutex
+}
+
+func newMemoryS3Client() *memoryS3Client {
+	return &memoryS3Client{
+		objects: map[string][]byte{},
+	}
+}
+
+func (m *memoryS3Client) PutObject(ctx context.Context, bucket string, key string, data []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[bucket+"/"+key] = append([]byte(nil), data...)
+	return nil
+}
+
+func (m *memoryS3Client) GetObject(ctx context.Context, bucket string, key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[bucket+"/"+key]
+	if !ok {
+		return nil, wkdb.ErrNotFound
+	}
+	return data, nil
+}
+
+func (m *memoryS3Client) DeleteObject(ctx context.Context, bucket string, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.objects, bucket+"/"+key)
+	return nil
+}
+
+func (m *memoryS3Client) count(bucket string, prefix string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var n int
+	for k := range m.objects {
+		if strings.HasPrefix(k, bucket+"/"+prefix) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,419 @@
+package wkdb
+
+import (
+	"math"
+	"sort"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+func (wk *wukongDB) IterateMessageChannels(iterFnc func(channelId string, channelType uint8, lastMsgSeq uint64) bool) error {
+	for _, db := range wk.dbs {
+		stop, err := wk.iterateMessageChannelsOfDb(db, iterFnc)
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (wk *wukongDB) iterateMessageChannelsOfDb(db *pebble.DB, iterFnc func(channelId string, channelType uint8, lastMsgSeq uint64) bool) (bool, error) {
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewChannelLastMessageSeqLowKey(),
+		UpperBound: key.NewChannelLastMessageSeqHighKey(),
+	})
+	defer iter.Close()
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		channelHash, err := key.ParseChannelLastMessageSeqKey(iter.Key())
+		if err != nil {
+			return false, err
+		}
+		lastMsgSeq := wk.endian.Uint64(iter.Value())
+		if lastMsgSeq == 0 {
+			continue
+		}
+
+		// 通过最新的一条消息获取频道ID（最新的消息已被删除的频道没有需要处理的消息了）
+		var primary [16]byte
+		wk.endian.PutUint64(primary[:], channelHash)
+		wk.endian.PutUint64(primary[8:], lastMsgSeq)
+		channelId, err := wk.getBytes(db, key.NewMessageColumnKeyWithPrimary(primary, key.TableMessage.Column.ChannelId))
+		if err != nil {
+			return false, err
+		}
+		channelType, err := wk.getBytes(db, key.NewMessageColumnKeyWithPrimary(primary, key.TableMessage.Column.ChannelType))
+		if err != nil {
+			return false, err
+		}
+		if len(channelId) == 0 || len(channelType) == 0 {
+			continue
+		}
+		if !iterFnc(string(channelId), channelType[0], lastMsgSeq) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// 获取key的值（复制一份），不存在返回nil
+func (wk *wukongDB) getBytes(db *pebble.DB, k []byte) ([]byte, error) {
+	value, closer, err := db.Get(k)
+	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer closer.Close()
+	result := make([]byte, len(value))
+	copy(result, value)
+	return result, nil
+}
+
+func (wk *wukongDB) GetChannelFirstMessageSeq(channelId string, channelType uint8) (uint64, error) {
+	// 冷存储的消息总是在本地消息之前
+	coldSeq, err := wk.firstColdMessageSeq(channelId, channelType)
+	if err != nil {
+		return 0, err
+	}
+	if coldSeq > 0 {
+		return coldSeq, nil
+	}
+	return wk.firstHotMessageSeq(channelId, channelType)
+}
+
+func (wk *wukongDB) GetMessageSeqBeforeTimestamp(channelId string, channelType uint8, timestamp int64) (uint64, error) {
+	var (
+		resultSeq uint64
+		coldAll   = true // 冷存储的消息是否都早于timestamp
+	)
+	// 冷存储的消息段（消息段记录了最大的消息时间，不需要加载消息段）
+	err := wk.iterColdSegments(channelId, channelType, 0, func(segment MessageColdSegment) bool {
+		if int64(segment.MaxTimestamp) >= timestamp {
+			coldAll = false
+			return false
+		}
+		resultSeq = segment.EndSeq
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !coldAll || timestamp <= 0 {
+		return resultSeq, nil
+	}
+
+	// 通过频道内的时间索引定位早于timestamp的最后一条消息
+	db := wk.channelDb(channelId, channelType)
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessageSecondIndexChannelTimestampKey(channelId, channelType, 0, 0),
+		UpperBound: key.NewMessageSecondIndexChannelTimestampKey(channelId, channelType, uint64(timestamp), 0),
+	})
+	defer iter.Close()
+	for valid := iter.Last(); valid; valid = iter.Prev() {
+		indexTimestamp, messageSeq, err := key.ParseMessageSecondIndexChannelTimestampKey(iter.Key())
+		if err != nil {
+			return 0, err
+		}
+		// 截断日志不会删除索引，需要校验消息仍然存在并且时间一致
+		timestampBytes, err := wk.getBytes(db, key.NewMessageColumnKey(channelId, channelType, messageSeq, key.TableMessage.Column.Timestamp))
+		if err != nil {
+			return 0, err
+		}
+		if len(timestampBytes) != 4 || uint64(wk.endian.Uint32(timestampBytes)) != indexTimestamp {
+			continue
+		}
+		if messageSeq > resultSeq {
+			resultSeq = messageSeq
+		}
+		return resultSeq, nil
+	}
+	if err := iter.Error(); err != nil {
+		return 0, err
+	}
+
+	// 没有索引的消息（升级前写入的消息）按顺序查找，遇到不早于timestamp的消息即停止
+	msgIter := db.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessagePrimaryKey(channelId, channelType, 0),
+		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, math.MaxUint64),
+	})
+	defer msgIter.Close()
+	for msgIter.First(); msgIter.Valid(); msgIter.Next() {
+		messageSeq, columnName, err := key.ParseMessageColumnKey(msgIter.Key())
+		if err != nil {
+			return 0, err
+		}
+		if columnName != key.TableMessage.Column.Timestamp {
+			continue
+		}
+		if int64(wk.endian.Uint32(msgIter.Value())) >= timestamp {
+			break
+		}
+		resultSeq = messageSeq
+	}
+	return resultSeq, nil
+}
+
+func (wk *wukongDB) GetMessageSeqExceedSize(channelId string, channelType uint8, maxBytes uint64) (uint64, error) {
+	channelSize, exist, err := wk.getChannelMessageSize(channelId, channelType)
+	if err != nil {
+		return 0, err
+	}
+	firstSeq, lastSeq, err := wk.hotMessageSeqRange(channelId, channelType)
+	if err != nil {
+		return 0, err
+	}
+
+	var (
+		totalBytes  uint64
+		accountedAt = lastSeq + 1 // 第一条有累计字节数的消息，之前的消息是升级前写入的
+	)
+	if exist && firstSeq > 0 {
+		// 有累计字节数的消息在没有累计字节数的消息之后，二分查找第一条有累计字节数的消息
+		var searchErr error
+		n := int(lastSeq - firstSeq + 1)
+		i := sort.Search(n, func(i int) bool {
+			_, ok, err := wk.getMessagePayloadOffset(channelId, channelType, firstSeq+uint64(i))
+			if err != nil {
+				searchErr = err
+			}
+			return ok
+		})
+		if searchErr != nil {
+			return 0, searchErr
+		}
+		accountedAt = firstSeq + uint64(i)
+	}
+
+	if accountedAt <= lastSeq {
+		accountedOffset, _, err := wk.getMessagePayloadOffset(channelId, channelType, accountedAt)
+		if err != nil {
+			return 0, err
+		}
+		totalBytes = channelSize - accountedOffset
+		if totalBytes > maxBytes {
+			// 此消息及之后的消息内容大小为 channelSize - payloadOffset，随seq递减，二分查找第一条不超出的消息
+			var searchErr error
+			n := int(lastSeq - accountedAt + 1)
+			i := sort.Search(n, func(i int) bool {
+				payloadOffset, _, err := wk.getMessagePayloadOffset(channelId, channelType, accountedAt+uint64(i))
+				if err != nil {
+					searchErr = err
+				}
+				return channelSize-payloadOffset <= maxBytes
+			})
+			if searchErr != nil {
+				return 0, searchErr
+			}
+			return accountedAt + uint64(i) - 1, nil
+		}
+	}
+
+	// 没有累计字节数的消息逐条累计
+	if firstSeq > 0 && accountedAt > firstSeq {
+		iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+			LowerBound: key.NewMessagePrimaryKey(channelId, channelType, firstSeq),
+			UpperBound: key.NewMessagePrimaryKey(channelId, channelType, accountedAt),
+		})
+		defer iter.Close()
+		for iter.Last(); iter.Valid(); iter.Prev() {
+			messageSeq, columnName, err := key.ParseMessageColumnKey(iter.Key())
+			if err != nil {
+				return 0, err
+			}
+			if columnName != key.TableMessage.Column.Payload {
+				continue
+			}
+			totalBytes += uint64(len(iter.Value()))
+			if totalBytes > maxBytes {
+				return messageSeq, nil
+			}
+		}
+	}
+
+	// 冷存储的消息段按消息段整体累计（消息段要么保留要么整体清理）
+	segments, err := wk.GetMessageColdSegments(channelId, channelType)
+	if err != nil {
+		return 0, err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		totalBytes += segments[i].PayloadSize
+		if totalBytes > maxBytes {
+			return segments[i].EndSeq, nil
+		}
+	}
+	return 0, nil
+}
+
+// 本地消息的seq范围，没有消息返回0
+func (wk *wukongDB) hotMessageSeqRange(channelId string, channelType uint8) (uint64, uint64, error) {
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessagePrimaryKey(channelId, channelType, 0),
+		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	if !iter.First() {
+		return 0, 0, iter.Error()
+	}
+	firstSeq, _, err := key.ParseMessageColumnKey(iter.Key())
+	if err != nil {
+		return 0, 0, err
+	}
+	if !iter.Last() {
+		return 0, 0, iter.Error()
+	}
+	lastSeq, _, err := key.ParseMessageColumnKey(iter.Key())
+	if err != nil {
+		return 0, 0, err
+	}
+	return firstSeq, lastSeq, nil
+}
+
+// 批量写入消息时累计频道消息内容的字节数（同一个频道的消息可能在同一批次中写入多次）
+type channelMessageSizes map[uint64]uint64 // channel hash -> 累计字节数
+
+// 获取消息的累计字节数偏移，并累计此消息的内容大小
+func (wk *wukongDB) nextPayloadOffset(sizes channelMessageSizes, channelId string, channelType uint8, payloadSize int) (uint64, error) {
+	channelNum := key.ChannelToNum(channelId, channelType)
+	payloadOffset, ok := sizes[channelNum]
+	if !ok {
+		var err error
+		if payloadOffset, _, err = wk.getChannelMessageSize(channelId, channelType); err != nil {
+			return 0, err
+		}
+	}
+	sizes[channelNum] = payloadOffset + uint64(payloadSize)
+	return payloadOffset, nil
+}
+
+// 频道消息内容的累计字节数，exist为false表示还没有累计过（升级前写入的频道）
+func (wk *wukongDB) getChannelMessageSize(channelId string, channelType uint8) (size uint64, exist bool, err error) {
+	value, err := wk.getBytes(wk.channelDb(channelId, channelType), key.NewChannelMessageSizeKey(channelId, channelType))
+	if err != nil {
+		return 0, false, err
+	}
+	if len(value) != 8 {
+		return 0, false, nil
+	}
+	return wk.endian.Uint64(value), true, nil
+}
+
+func (wk *wukongDB) setChannelMessageSize(channelId string, channelType uint8, size uint64, w *Batch) {
+	data := make([]byte, 8)
+	wk.endian.PutUint64(data, size)
+	w.Set(key.NewChannelMessageSizeKey(channelId, channelType), data)
+}
+
+// 消息之前频道所有消息内容的累计字节数，ok为false表示消息不存在或者没有记录（升级前写入的消息）
+func (wk *wukongDB) getMessagePayloadOffset(channelId string, channelType uint8, messageSeq uint64) (payloadOffset uint64, ok bool, err error) {
+	value, err := wk.getBytes(wk.channelDb(channelId, channelType), key.NewMessageColumnKey(channelId, channelType, messageSeq, key.TableMessage.Column.PayloadOffset))
+	if err != nil {
+		return 0, false, err
+	}
+	if len(value) != 8 {
+		return 0, false, nil
+	}
+	return wk.endian.Uint64(value), true, nil
+}
+
+func (wk *wukongDB) PurgeMessagesTo(channelId string, channelType uint8, messageSeq uint64, limit int) (uint64, error) {
+
+	wk.metrics.PurgeMessagesToAdd(1)
+
+	if messageSeq == 0 {
+		return 0, nil
+	}
+
+	// 先清理冷存储的消息段
+	coldPurgedSeq, coldCount, err := wk.purgeColdSegments(channelId, channelType, messageSeq)
+	if err != nil {
+		return 0, err
+	}
+	if coldCount > 0 {
+		wk.metrics.PurgedMessagesAdd(int64(coldCount))
+	}
+
+	db := wk.channelDb(channelId, channelType)
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessagePrimaryKey(channelId, channelType, 0),
+		UpperBound: key.NewMessagePrimaryKey(channelId, channelType, messageSeq+1),
+	})
+	defer iter.Close()
+
+	var (
+		firstSeq    uint64
+		purgedSeq   uint64
+		count       int
+		channelNum  = key.ChannelToNum(channelId, channelType)
+		batch       = wk.channelBatchDb(channelId, channelType).NewBatch()
+		msg         Message
+		preSeq      uint64
+		deleteIndex = func(seq uint64, m Message) {
+			var primaryValue [16]byte
+			wk.endian.PutUint64(primaryValue[:], channelNum)
+			wk.endian.PutUint64(primaryValue[8:], seq)
+			batch.Delete(key.NewMessageSecondIndexFromUidKey(m.FromUID, primaryValue))
+			batch.Delete(key.NewMessageIndexMessageIdKey(uint64(m.MessageID)))
+			batch.Delete(key.NewMessageSecondIndexClientMsgNoKey(m.ClientMsgNo, primaryValue))
+			batch.Delete(key.NewMessageIndexTimestampKey(uint64(m.Timestamp), primaryValue))
+			batch.Delete(key.NewMessageSecondIndexChannelTimestampKey(channelId, channelType, uint64(m.Timestamp), seq))
+		}
+	)
+
+	for iter.First(); iter.Valid(); iter.Next() {
+		seq, columnName, err := key.ParseMessageColumnKey(iter.Key())
+		if err != nil {
+			return 0, err
+		}
+		if seq != preSeq {
+			if preSeq != 0 {
+				deleteIndex(preSeq, msg)
+				purgedSeq = preSeq
+				count++
+				if limit > 0 && count >= limit {
+					preSeq = 0
+					break
+				}
+			} else {
+				firstSeq = seq
+			}
+			preSeq = seq
+			msg = Message{}
+		}
+		switch columnName {
+		case key.TableMessage.Column.MessageId:
+			msg.MessageID = int64(wk.endian.Uint64(iter.Value()))
+		case key.TableMessage.Column.ClientMsgNo:
+			msg.ClientMsgNo = string(iter.Value())
+		case key.TableMessage.Column.Timestamp:
+			msg.Timestamp = int32(wk.endian.Uint32(iter.Value()))
+		case key.TableMessage.Column.FromUid:
+			msg.FromUID = string(iter.Value())
+		}
+	}
+	if preSeq != 0 { // 最后一条消息
+		deleteIndex(preSeq, msg)
+		purgedSeq = preSeq
+		count++
+	}
+	if count == 0 {
+		return coldPurgedSeq, nil
+	}
+
+	batch.DeleteRange(key.NewMessagePrimaryKey(channelId, channelType, firstSeq), key.NewMessagePrimaryKey(channelId, channelType, purgedSeq+1))
+	if err := batch.CommitWait(); err != nil {
+		return 0, err
+	}
+
+	wk.metrics.PurgedMessagesAdd(int64(count))
+	wk.Debug("purge messages", zap.String("channelId", channelId), zap.Uint8("channelType", channelType), zap.Uint64("firstSeq", firstSeq), zap.Uint64("purgedSeq", purgedSeq), zap.Int("count", count))
+
+	return purgedSeq, nil
+}
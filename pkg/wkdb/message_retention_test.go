@@ -0,0 +1,182 @@
+package wkdb_test
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPurgeMessagesTo(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	channelId := "channel"
+	channelType := uint8(2)
+
+	num := 10
+	messages := make([]wkdb.Message, 0, num)
+	for i := 0; i < num; i++ {
+		messages = append(messages, wkdb.Message{
+			RecvPacket: wkproto.RecvPacket{
+				MessageID:   int64(i + 1000),
+				ClientMsgNo: "clientMsgNo",
+				ChannelID:   channelId,
+				ChannelType: channelType,
+				FromUID:     "uid1",
+				MessageSeq:  uint32(i + 1),
+				Timestamp:   int32(i + 100),
+				Payload:     []byte("hello"),
+			},
+		})
+	}
+	err = d.AppendMessages(channelId, channelType, messages)
+	assert.NoError(t, err)
+	err = d.SetChannelLastMessageSeq(channelId, channelType, uint64(num))
+	assert.NoError(t, err)
+
+	var channels []string
+	err = d.IterateMessageChannels(func(chId string, chType uint8, lastMsgSeq uint64) bool {
+		assert.Equal(t, channelType, chType)
+		assert.Equal(t, uint64(num), lastMsgSeq)
+		channels = append(channels, chId)
+		return true
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{channelId}, channels)
+
+	firstSeq, err := d.GetChannelFirstMessageSeq(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1), firstSeq)
+
+	// 时间小于103的消息为1-3
+	seq, err := d.GetMessageSeqBeforeTimestamp(channelId, channelType, 103)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), seq)
+
+	// 保留最新的2条消息（每条5个字节）
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), seq)
+
+	// 限制每次清理2条
+	purgedSeq, err := d.PurgeMessagesTo(channelId, channelType, 5, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), purgedSeq)
+
+	purgedSeq, err = d.PurgeMessagesTo(channelId, channelType, 5, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), purgedSeq)
+
+	// 已经清理过了
+	purgedSeq, err = d.PurgeMessagesTo(channelId, channelType, 5, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), purgedSeq)
+
+	firstSeq, err = d.GetChannelFirstMessageSeq(channelId, channelType)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(6), firstSeq)
+
+	msgs, err := d.LoadNextRangeMsgs(channelId, channelType, 1, 0, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, num-5, len(msgs))
+	assert.Equal(t, uint32(6), msgs[0].MessageSeq)
+
+	// 消息索引也被清理了
+	_, err = d.GetMessage(1000)
+	assert.Equal(t, wkdb.ErrNotFound, err)
+	m, err := d.GetMessage(1005)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(6), m.MessageSeq)
+}
+
+func TestMessageRetentionAfterTruncate(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	channelId := "channel"
+	channelType := uint8(2)
+
+	newMessages := func(startSeq, count int, timestamp int32, payload string) []wkdb.Message {
+		messages := make([]wkdb.Message, 0, count)
+		for i := 0; i < count; i++ {
+			messages = append(messages, wkdb.Message{
+				RecvPacket: wkproto.RecvPacket{
+					MessageID:   int64(startSeq + i + 1000),
+					ClientMsgNo: "clientMsgNo",
+					ChannelID:   channelId,
+					ChannelType: channelType,
+					FromUID:     "uid1",
+					MessageSeq:  uint32(startSeq + i),
+					Timestamp:   timestamp + int32(i),
+					Payload:     []byte(payload),
+				},
+			})
+		}
+		return messages
+	}
+
+	// 1-10 每条5个字节，时间100-109
+	err = d.AppendMessages(channelId, channelType, newMessages(1, 10, 100, "hello"))
+	assert.NoError(t, err)
+
+	// 截断6之后的消息，重新写入6-10 每条2个字节，时间200-204
+	err = d.TruncateLogTo(channelId, channelType, 6)
+	assert.NoError(t, err)
+	err = d.AppendMessages(channelId, channelType, newMessages(6, 5, 200, "hi"))
+	assert.NoError(t, err)
+
+	// 截断前的时间索引已失效，时间小于150的消息为1-5
+	seq, err := d.GetMessageSeqBeforeTimestamp(channelId, channelType, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), seq)
+
+	seq, err = d.GetMessageSeqBeforeTimestamp(channelId, channelType, 202)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(7), seq)
+
+	seq, err = d.GetMessageSeqBeforeTimestamp(channelId, channelType, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), seq)
+
+	// 6-10共10个字节，保留10个字节时超出的是5
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), seq)
+
+	// 保留5个字节时超出的是8（9、10共4个字节，加上8超出）
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), seq)
+
+	// 保留16个字节时超出的是4（5-10共15个字节，加上4超出）
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4), seq)
+
+	// 清理后累计字节数不变
+	_, err = d.PurgeMessagesTo(channelId, channelType, 4, 0)
+	assert.NoError(t, err)
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(8), seq)
+	seq, err = d.GetMessageSeqExceedSize(channelId, channelType, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), seq)
+	seq, err = d.GetMessageSeqBeforeTimestamp(channelId, channelType, 150)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(5), seq)
+}
@@ -0,0 +1,118 @@
+package wkdb
+
+import (
+	"math"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) HideMessages(channelId string, channelType uint8, uid string, messages []HiddenMessage, updatedAt int64) error {
+
+	wk.metrics.HideMessagesAdd(1)
+
+	visibility, err := wk.GetMessageVisibility(channelId, channelType, uid)
+	if err != nil {
+		return err
+	}
+
+	w := wk.channelDb(channelId, channelType).NewBatch()
+	defer w.Close()
+	for _, message := range messages {
+		if message.MessageSeq <= visibility.ClearedToSeq { // 已经清空的消息不需要再记录
+			continue
+		}
+		if err := w.Set(key.NewHiddenMessageKey(channelId, channelType, uid, message.MessageSeq, uint64(message.MessageId)), nil, wk.noSync); err != nil {
+			return err
+		}
+	}
+	if err := wk.writeMessageVisibilityUpdatedAt(channelId, channelType, uid, updatedAt, w); err != nil {
+		return err
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) ClearMessages(channelId string, channelType uint8, uid string, clearedToSeq uint64, updatedAt int64) error {
+
+	wk.metrics.ClearMessagesAdd(1)
+
+	visibility, err := wk.GetMessageVisibility(channelId, channelType, uid)
+	if err != nil {
+		return err
+	}
+	if clearedToSeq <= visibility.ClearedToSeq {
+		return nil
+	}
+
+	w := wk.channelDb(channelId, channelType).NewBatch()
+	defer w.Close()
+
+	clearedToSeqBytes := make([]byte, 8)
+	wk.endian.PutUint64(clearedToSeqBytes, clearedToSeq)
+	if err = w.Set(key.NewMessageVisibilityColumnKey(channelId, channelType, uid, key.TableMessageVisibility.Column.ClearedToSeq), clearedToSeqBytes, wk.noSync); err != nil {
+		return err
+	}
+	// 清空范围内隐藏的消息已经不可见，删除记录
+	if err = w.DeleteRange(key.NewHiddenMessageKey(channelId, channelType, uid, 0, 0), key.NewHiddenMessageKey(channelId, channelType, uid, clearedToSeq+1, 0), wk.noSync); err != nil {
+		return err
+	}
+	if err = wk.writeMessageVisibilityUpdatedAt(channelId, channelType, uid, updatedAt, w); err != nil {
+		return err
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) GetMessageVisibility(channelId string, channelType uint8, uid string) (MessageVisibility, error) {
+
+	wk.metrics.GetMessageVisibilityAdd(1)
+
+	visibility := MessageVisibility{Uid: uid}
+
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewMessageVisibilityColumnKey(channelId, channelType, uid, key.MinColumnKey),
+		UpperBound: key.NewMessageVisibilityColumnKey(channelId, channelType, uid, key.MaxColumnKey),
+	})
+	defer iter.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		k := iter.Key()
+		columnName := [2]byte{k[len(k)-2], k[len(k)-1]}
+		switch columnName {
+		case key.TableMessageVisibility.Column.ClearedToSeq:
+			visibility.ClearedToSeq = wk.endian.Uint64(iter.Value())
+		case key.TableMessageVisibility.Column.UpdatedAt:
+			visibility.UpdatedAt = int64(wk.endian.Uint64(iter.Value()))
+		}
+	}
+	return visibility, nil
+}
+
+func (wk *wukongDB) GetHiddenMessageIds(channelId string, channelType uint8, uid string, startSeq, endSeq uint64) ([]int64, error) {
+
+	wk.metrics.GetMessageVisibilityAdd(1)
+
+	upperBound := key.NewHiddenMessageKey(channelId, channelType, uid, math.MaxUint64, math.MaxUint64)
+	if endSeq < math.MaxUint64 {
+		upperBound = key.NewHiddenMessageKey(channelId, channelType, uid, endSeq+1, 0)
+	}
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewHiddenMessageKey(channelId, channelType, uid, startSeq, 0),
+		UpperBound: upperBound,
+	})
+	defer iter.Close()
+
+	var messageIds []int64
+	for iter.First(); iter.Valid(); iter.Next() {
+		_, messageId, err := key.ParseHiddenMessageKey(iter.Key())
+		if err != nil {
+			return nil, err
+		}
+		messageIds = append(messageIds, int64(messageId))
+	}
+	return messageIds, nil
+}
+
+func (wk *wukongDB) writeMessageVisibilityUpdatedAt(channelId string, channelType uint8, uid string, updatedAt int64, w pebble.Writer) error {
+	updatedAtBytes := make([]byte, 8)
+	wk.endian.PutUint64(updatedAtBytes, uint64(updatedAt))
+	return w.Set(key.NewMessageVisibilityColumnKey(channelId, channelType, uid, key.TableMessageVisibility.Column.UpdatedAt), updatedAtBytes, wk.noSync)
+}
@@ -0,0 +1,81 @@
+package wkdb_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageVisibility(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	channelId := "channel1"
+	channelType := uint8(2)
+
+	visibility, err := d.GetMessageVisibility(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), visibility.ClearedToSeq)
+	assert.Len(t, visibility.HiddenMessageIds, 0)
+
+	err = d.HideMessages(channelId, channelType, "uid1", []wkdb.HiddenMessage{
+		{MessageId: 100, MessageSeq: 5},
+		{MessageId: 200, MessageSeq: 20},
+		{MessageId: 300, MessageSeq: 30},
+	}, 1)
+	assert.NoError(t, err)
+
+	// 按消息序号范围加载隐藏的消息
+	hiddenMessageIds, err := d.GetHiddenMessageIds(channelId, channelType, "uid1", 6, 20)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{200}, hiddenMessageIds)
+
+	// GetMessageVisibility不加载隐藏的消息
+	visibility, err = d.GetMessageVisibility(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Len(t, visibility.HiddenMessageIds, 0)
+	assert.Equal(t, int64(1), visibility.UpdatedAt)
+
+	err = d.ClearMessages(channelId, channelType, "uid1", 20, 2)
+	assert.NoError(t, err)
+
+	// 清空序号只会增大
+	err = d.ClearMessages(channelId, channelType, "uid1", 5, 3)
+	assert.NoError(t, err)
+
+	visibility, err = d.GetMessageVisibility(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), visibility.ClearedToSeq)
+	assert.Equal(t, int64(2), visibility.UpdatedAt)
+
+	// 清空范围内隐藏的消息记录被删除
+	hiddenMessageIds, err = d.GetHiddenMessageIds(channelId, channelType, "uid1", 0, math.MaxUint64)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{300}, hiddenMessageIds)
+
+	// 已清空的消息不再记录隐藏
+	err = d.HideMessages(channelId, channelType, "uid1", []wkdb.HiddenMessage{{MessageId: 150, MessageSeq: 15}}, 4)
+	assert.NoError(t, err)
+	hiddenMessageIds, err = d.GetHiddenMessageIds(channelId, channelType, "uid1", 0, math.MaxUint64)
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{300}, hiddenMessageIds)
+
+	visibility.HiddenMessageIds = hiddenMessageIds
+	assert.False(t, visibility.Visible(400, 20))
+	assert.False(t, visibility.Visible(300, 30))
+	assert.True(t, visibility.Visible(400, 21))
+
+	// 其他用户不受影响
+	visibility, err = d.GetMessageVisibility(channelId, channelType, "uid2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), visibility.ClearedToSeq)
+	assert.Len(t, visibility.HiddenMessageIds, 0)
+}
@@ -1,6 +1,7 @@
 package wkdb
 
 import (
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
@@ -68,6 +69,20 @@ func (m *Message) Marshal() ([]byte, error) {
 	return enc.Bytes(), nil
 }
 
+// MessageColdSegment 转存到冷存储的消息段
+type MessageColdSegment struct {
+	ChannelId    string `json:"channel_id"`
+	ChannelType  uint8  `json:"channel_type"`
+	StartSeq     uint64 `json:"start_seq"`     // 第一条消息的seq
+	EndSeq       uint64 `json:"end_seq"`       // 最后一条消息的seq（包含）
+	Count        uint32 `json:"count"`         // 消息数量
+	PayloadSize  uint64 `json:"payload_size"`  // 消息内容的总字节数
+	MinTimestamp int32  `json:"min_timestamp"` // 最早的消息时间（秒）
+	MaxTimestamp int32  `json:"max_timestamp"` // 最晚的消息时间（秒）
+	BlobKey      string `json:"blob_key"`      // 对象存储的key
+	CreatedAt    int64  `json:"created_at"`    // 转存时间（纳秒）
+}
+
 var EmptyDevice = Device{}
 
 func IsEmptyDevice(d Device) bool {
@@ -87,6 +102,30 @@ type Device struct {
 	RecvMsgBytes uint64     `json:"recv_msg_bytes,omitempty"` // 接收消息字节数
 	CreatedAt    *time.Time `json:"created_at,omitempty"`     // 创建时间
 	UpdatedAt    *time.Time `json:"updated_at,omitempty"`     // 更新时间
+
+	TokenExpireAt *time.Time `json:"token_expire_at,omitempty"` // token过期时间，为空表示永不过期
+	Scopes        []string   `json:"scopes,omitempty"`          // token的授权范围，为空表示不限制
+}
+
+// TokenExpired token是否已过期
+func (d Device) TokenExpired(now time.Time) bool {
+	if d.TokenExpireAt == nil {
+		return false
+	}
+	return !now.Before(*d.TokenExpireAt)
+}
+
+// HasScope token是否拥有指定的授权范围（未设置授权范围的token拥有所有权限）
+func (d Device) HasScope(scope string) bool {
+	if len(d.Scopes) == 0 {
+		return true
+	}
+	for _, s := range d.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 var EmptyUser = User{}
@@ -107,25 +146,152 @@ type User struct {
 	RecvMsgBytes      uint64     `json:"recv_msg_bytes,omitempty"`      // 接收消息字节数
 	CreatedAt         *time.Time `json:"created_at,omitempty"`          // 创建时间
 	UpdatedAt         *time.Time `json:"updated_at,omitempty"`          // 更新时间
+	LastSeen          *time.Time `json:"last_seen,omitempty"`           // 最后在线时间（最后一个连接断开的时间）
+	LastSeenPrivacy   uint8      `json:"last_seen_privacy,omitempty"`   // 最后在线时间的可见范围，0表示未设置
 }
 
 var EmptyChannelInfo = ChannelInfo{}
 
 type ChannelInfo struct {
-	Id              uint64     `json:"id,omitempty"`               // ID
-	ChannelId       string     `json:"channel_id,omitempty"`       // 频道ID
-	ChannelType     uint8      `json:"channel_type,omitempty"`     // 频道类型
-	Ban             bool       `json:"ban,omitempty"`              // 是否被封
-	Large           bool       `json:"large,omitempty"`            // 是否是超大群
-	Disband         bool       `json:"disband,omitempty"`          // 是否解散
-	SubscriberCount int        `json:"subscriber_count,omitempty"` // 订阅者数量
-	DenylistCount   int        `json:"denylist_count,omitempty"`   // 黑名单数量
-	AllowlistCount  int        `json:"allowlist_count,omitempty"`  // 白名单数量
-	LastMsgSeq      uint64     `json:"last_msg_seq,omitempty"`     // 最新消息序号
-	LastMsgTime     uint64     `json:"last_msg_time,omitempty"`    // 最后一次消息时间
-	Webhook         string     `json:"webhook,omitempty"`          // webhook地址
-	CreatedAt       *time.Time `json:"created_at,omitempty"`       // 创建时间
-	UpdatedAt       *time.Time `json:"updated_at,omitempty"`       // 更新时间
+	Id                  uint64            `json:"id,omitempty"`                    // ID
+	ChannelId           string            `json:"channel_id,omitempty"`            // 频道ID
+	ChannelType         uint8             `json:"channel_type,omitempty"`          // 频道类型
+	Ban                 bool              `json:"ban,omitempty"`                   // 是否被封
+	Large               bool              `json:"large,omitempty"`                 // 是否是超大群
+	Disband             bool              `json:"disband,omitempty"`               // 是否解散
+	SubscriberCount     int               `json:"subscriber_count,omitempty"`      // 订阅者数量
+	DenylistCount       int               `json:"denylist_count,omitempty"`        // 黑名单数量
+	AllowlistCount      int               `json:"allowlist_count,omitempty"`       // 白名单数量
+	LastMsgSeq          uint64            `json:"last_msg_seq,omitempty"`          // 最新消息序号
+	LastMsgTime         uint64            `json:"last_msg_time,omitempty"`         // 最后一次消息时间
+	Webhook             string            `json:"webhook,omitempty"`               // webhook地址
+	SendMode            SendMode          `json:"send_mode,omitempty"`             // 发言模式
+	HistoryVisibility   HistoryVisibility `json:"history_visibility,omitempty"`    // 历史消息可见性
+	HistoryVisibleCount uint32            `json:"history_visible_count,omitempty"` // 历史消息可见性为LastN时，成员可以看到加入前的消息数量
+	E2ee                bool              `json:"e2ee,omitempty"`                  // 是否开启端到端加密（开启后只接受signal加密的消息）
+	RetentionMaxAge     int64             `json:"retention_max_age,omitempty"`     // 消息保留的最长时间（秒） 0.使用全局配置 -1.不限制
+	RetentionMaxCount   int64             `json:"retention_max_count,omitempty"`   // 消息保留的最大数量 0.使用全局配置 -1.不限制
+	RetentionMaxBytes   int64             `json:"retention_max_bytes,omitempty"`   // 消息保留的最大字节数（按payload计算） 0.使用全局配置 -1.不限制
+	CreatedAt           *time.Time        `json:"created_at,omitempty"`            // 创建时间
+	UpdatedAt           *time.Time        `json:"updated_at,omitempty"`            // 更新时间
+}
+
+// SubscriberHistory 订阅者在频道内的加入/离开记录，用于控制历史消息的可见范围
+type SubscriberHistory struct {
+	Uid        string               `json:"uid"`
+	JoinSeq    uint64               `json:"join_seq"`              // 最近一次加入时的消息序号（加入后第一条可见的消息），0表示可见所有历史消息
+	LeaveSeq   uint64               `json:"leave_seq"`             // 最近一次离开时的消息序号（离开后第一条不可见的消息），0表示还未离开
+	PrevRanges []SubscriberSeqRange `json:"prev_ranges,omitempty"` // 之前加入并已离开的范围（按时间顺序）
+}
+
+// SubscriberSeqRange 订阅者在频道内的一段可见范围 [JoinSeq,LeaveSeq)
+type SubscriberSeqRange struct {
+	JoinSeq  uint64 `json:"join_seq"`
+	LeaveSeq uint64 `json:"leave_seq"`
+}
+
+// 最多保留的历史范围数量，超过后丢弃最早的范围
+const subscriberPrevRangesMaxCount = 64
+
+// Join 订阅者重新加入，之前已离开的范围会被保留，ok为false表示订阅者还在频道内，不需要修改
+func (h *SubscriberHistory) Join(seq uint64) bool {
+	if h.LeaveSeq == 0 {
+		return false
+	}
+	h.PrevRanges = append(h.PrevRanges, SubscriberSeqRange{JoinSeq: h.JoinSeq, LeaveSeq: h.LeaveSeq})
+	if len(h.PrevRanges) > subscriberPrevRangesMaxCount {
+		h.PrevRanges = h.PrevRanges[len(h.PrevRanges)-subscriberPrevRangesMaxCount:]
+	}
+	h.JoinSeq = seq
+	h.LeaveSeq = 0
+	return true
+}
+
+// Leave 订阅者离开，ok为false表示订阅者已经离开，不需要修改
+func (h *SubscriberHistory) Leave(seq uint64) bool {
+	if h.LeaveSeq != 0 {
+		return false
+	}
+	h.LeaveSeq = seq
+	return true
+}
+
+// MessageVisibility 用户在频道内的消息可见性（仅对自己生效的删除和清空）
+type MessageVisibility struct {
+	Uid              string  `json:"uid"`
+	ClearedToSeq     uint64  `json:"cleared_to_seq"`     // 清空至的消息序号（包含）
+	HiddenMessageIds []int64 `json:"hidden_message_ids"` // 隐藏的消息ID（只包含按范围加载的部分）
+	UpdatedAt        int64   `json:"updated_at"`         // 最后一次修改时间（纳秒）
+}
+
+// HiddenMessage 用户隐藏的消息
+type HiddenMessage struct {
+	MessageId  int64  `json:"message_id"`
+	MessageSeq uint64 `json:"message_seq"`
+}
+
+// Visible 消息对用户是否可见
+func (m MessageVisibility) Visible(messageId int64, messageSeq uint64) bool {
+	if messageSeq <= m.ClearedToSeq {
+		return false
+	}
+	for _, hiddenId := range m.HiddenMessageIds {
+		if hiddenId == messageId {
+			return false
+		}
+	}
+	return true
+}
+
+// E2eeKeyBundle 用户设备的端到端加密公钥包（signal协议）
+type E2eeKeyBundle struct {
+	Uid            string           `json:"uid"`
+	DeviceId       string           `json:"device_id"`       // 设备ID
+	RegistrationId uint32           `json:"registration_id"` // signal注册ID
+	IdentityKey    []byte           `json:"identity_key"`    // 身份公钥
+	SignedPrekey   E2eeSignedPrekey `json:"signed_prekey"`   // 签名预共享公钥
+	UpdatedAt      int64            `json:"updated_at"`      // 更新时间（纳秒）
+}
+
+var EmptyE2eeKeyBundle = E2eeKeyBundle{}
+
+// E2eeSignedPrekey 签名预共享公钥
+type E2eeSignedPrekey struct {
+	KeyId     uint32 `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+	Signature []byte `json:"signature"` // 身份私钥对公钥的签名
+}
+
+// E2eePrekey 一次性预共享公钥（被获取一次后删除）
+type E2eePrekey struct {
+	KeyId     uint32 `json:"key_id"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// SendMode 频道的发言模式
+type SendMode uint8
+
+const (
+	// SendModeAll 所有订阅者都可以发言
+	SendModeAll SendMode = iota
+	// SendModeAdmin 只有群主和管理员可以发言
+	SendModeAdmin
+)
+
+// HistoryVisibility 频道的历史消息可见性
+type HistoryVisibility uint8
+
+const (
+	// HistoryVisibilityAll 成员可以看到所有历史消息
+	HistoryVisibilityAll HistoryVisibility = iota
+	// HistoryVisibilitySinceJoin 成员只能看到加入之后的消息
+	HistoryVisibilitySinceJoin
+	// HistoryVisibilityLastN 成员只能看到加入前的最后N条消息以及加入之后的消息
+	HistoryVisibilityLastN
+)
+
+func (h HistoryVisibility) Valid() bool {
+	return h <= HistoryVisibilityLastN
 }
 
 func NewChannelInfo(channelId string, channelType uint8) ChannelInfo {
@@ -720,25 +886,71 @@ func ChannelToKey(channelId string, channelType uint8) string {
 	return b.String()
 }
 
+// LeaderTermStartIndex 领导任期开始的第一条日志索引
+type LeaderTermStartIndex struct {
+	Term       uint32 `json:"term"`
+	StartIndex uint64 `json:"start_index"`
+}
+
 type Channel struct {
 	ChannelId   string `json:"channel_id,omitempty"`
 	ChannelType uint8  `json:"channel_type,omitempty"`
 }
 
+// MemberRole 订阅者的角色
+type MemberRole uint8
+
+const (
+	// MemberRoleMember 普通成员
+	MemberRoleMember MemberRole = iota
+	// MemberRoleAdmin 管理员
+	MemberRoleAdmin
+	// MemberRoleOwner 群主
+	MemberRoleOwner
+)
+
+func (r MemberRole) Valid() bool {
+	return r <= MemberRoleOwner
+}
+
+func (r MemberRole) String() string {
+	switch r {
+	case MemberRoleMember:
+		return "member"
+	case MemberRoleAdmin:
+		return "admin"
+	case MemberRoleOwner:
+		return "owner"
+	}
+	return fmt.Sprintf("unknown(%d)", r)
+}
+
+// 成员数据的当前版本，版本1增加了角色、禁言、加入来源和自定义属性
+const memberVersion uint16 = 1
+
 type Member struct {
-	Id        uint64     `json:"id"`
-	Uid       string     `json:"uid"`
-	CreatedAt *time.Time `json:"created_at,omitempty"`
-	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	Id         uint64            `json:"id"`
+	Uid        string            `json:"uid"`
+	Role       MemberRole        `json:"role,omitempty"`        // 角色（仅订阅者有效）
+	MuteUntil  int64             `json:"mute_until,omitempty"`  // 禁言截止时间（单位秒），0表示没有禁言
+	JoinSource string            `json:"join_source,omitempty"` // 加入来源，由业务方自定义，例如 invite、qrcode
+	Attributes map[string]string `json:"attributes,omitempty"`  // 自定义属性，例如群昵称
+	CreatedAt  *time.Time        `json:"created_at,omitempty"`
+	UpdatedAt  *time.Time        `json:"updated_at,omitempty"`
 
 	version uint16 // 数据版本
 }
 
+// IsMuted 在指定时间是否处于禁言中
+func (m Member) IsMuted(now time.Time) bool {
+	return m.MuteUntil > 0 && m.MuteUntil > now.Unix()
+}
+
 func (m *Member) Marshal() ([]byte, error) {
 	enc := wkproto.NewEncoder()
 	defer enc.End()
 
-	enc.WriteUint16(m.version) // 数据版本
+	enc.WriteUint16(memberVersion) // 数据版本
 
 	enc.WriteUint64(m.Id)
 	enc.WriteString(m.Uid)
@@ -752,9 +964,34 @@ func (m *Member) Marshal() ([]byte, error) {
 	} else {
 		enc.WriteUint64(0)
 	}
+
+	// version 1
+	enc.WriteUint8(uint8(m.Role))
+	enc.WriteInt64(m.MuteUntil)
+	enc.WriteString(m.JoinSource)
+	attributes, err := m.marshalAttributes()
+	if err != nil {
+		return nil, err
+	}
+	enc.WriteBinary(attributes)
 	return enc.Bytes(), nil
 }
 
+func (m *Member) marshalAttributes() ([]byte, error) {
+	if len(m.Attributes) == 0 {
+		return nil, nil
+	}
+	return json.Marshal(m.Attributes)
+}
+
+func (m *Member) unmarshalAttributes(data []byte) error {
+	if len(data) == 0 {
+		m.Attributes = nil
+		return nil
+	}
+	return json.Unmarshal(data, &m.Attributes)
+}
+
 func (m *Member) Unmarshal(data []byte) error {
 	dec := wkproto.NewDecoder(data)
 	var err error
@@ -785,5 +1022,325 @@ func (m *Member) Unmarshal(data []byte) error {
 		ct := time.Unix(int64(updatedAt/1e9), int64(updatedAt%1e9))
 		m.UpdatedAt = &ct
 	}
+
+	if m.version < 1 {
+		return nil
+	}
+	var role uint8
+	if role, err = dec.Uint8(); err != nil {
+		return err
+	}
+	m.Role = MemberRole(role)
+	if m.MuteUntil, err = dec.Int64(); err != nil {
+		return err
+	}
+	if m.JoinSource, err = dec.String(); err != nil {
+		return err
+	}
+	var attributes []byte
+	if attributes, err = dec.Binary(); err != nil {
+		return err
+	}
+	return m.unmarshalAttributes(attributes)
+}
+
+// ApiKey http api的访问密钥
+type ApiKey struct {
+	Id        uint64     `json:"id,omitempty"`
+	Name      string     `json:"name,omitempty"`      // 名称（唯一）
+	KeyHash   string     `json:"-"`                   // 密钥的sha256值，明文密钥不做存储
+	Scopes    []string   `json:"scopes,omitempty"`    // 授权范围
+	Disabled  bool       `json:"disabled,omitempty"`  // 是否禁用
+	ExpireAt  *time.Time `json:"expire_at,omitempty"` // 过期时间，为空表示永不过期
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+
+	version uint16 // 数据版本
+}
+
+var EmptyApiKey = ApiKey{}
+
+func IsEmptyApiKey(a ApiKey) bool {
+	return a.Name == ""
+}
+
+// Expired 是否已过期
+func (a ApiKey) Expired(now time.Time) bool {
+	if a.ExpireAt == nil {
+		return false
+	}
+	return !now.Before(*a.ExpireAt)
+}
+
+func (a *ApiKey) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+
+	enc.WriteUint16(a.version) // 数据版本
+
+	enc.WriteUint64(a.Id)
+	enc.WriteString(a.Name)
+	enc.WriteString(a.KeyHash)
+	enc.WriteString(strings.Join(a.Scopes, ","))
+	if a.Disabled {
+		enc.WriteUint8(1)
+	} else {
+		enc.WriteUint8(0)
+	}
+	for _, t := range []*time.Time{a.ExpireAt, a.CreatedAt, a.UpdatedAt} {
+		if t != nil {
+			enc.WriteUint64(uint64(t.UnixNano()))
+		} else {
+			enc.WriteUint64(0)
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (a *ApiKey) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+
+	if a.version, err = dec.Uint16(); err != nil {
+		return err
+	}
+	if a.Id, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if a.Name, err = dec.String(); err != nil {
+		return err
+	}
+	if a.KeyHash, err = dec.String(); err != nil {
+		return err
+	}
+	var scopes string
+	if scopes, err = dec.String(); err != nil {
+		return err
+	}
+	if scopes != "" {
+		a.Scopes = strings.Split(scopes, ",")
+	}
+	var disabled uint8
+	if disabled, err = dec.Uint8(); err != nil {
+		return err
+	}
+	a.Disabled = disabled == 1
+
+	for _, t := range []**time.Time{&a.ExpireAt, &a.CreatedAt, &a.UpdatedAt} {
+		var tm uint64
+		if tm, err = dec.Uint64(); err != nil {
+			return err
+		}
+		if tm > 0 {
+			ct := time.Unix(int64(tm/1e9), int64(tm%1e9))
+			*t = &ct
+		}
+	}
+	return nil
+}
+
+// ManagerUser 管理端用户
+type ManagerUser struct {
+	Id           uint64     `json:"id,omitempty"`
+	Username     string     `json:"username,omitempty"` // 用户名（唯一）
+	PasswordHash string     `json:"-"`                  // 密码的bcrypt值，明文密码不做存储
+	Role         string     `json:"role,omitempty"`     // 角色 viewer, operator, admin
+	Disabled     bool       `json:"disabled,omitempty"` // 是否禁用
+	Source       string     `json:"source,omitempty"`   // 用户来源 local, oidc
+	CreatedAt    *time.Time `json:"created_at,omitempty"`
+	UpdatedAt    *time.Time `json:"updated_at,omitempty"`
+
+	version uint16 // 数据版本
+}
+
+var EmptyManagerUser = ManagerUser{}
+
+func IsEmptyManagerUser(u ManagerUser) bool {
+	return u.Username == ""
+}
+
+func (u *ManagerUser) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+
+	enc.WriteUint16(u.version) // 数据版本
+
+	enc.WriteUint64(u.Id)
+	enc.WriteString(u.Username)
+	enc.WriteString(u.PasswordHash)
+	enc.WriteString(u.Role)
+	if u.Disabled {
+		enc.WriteUint8(1)
+	} else {
+		enc.WriteUint8(0)
+	}
+	enc.WriteString(u.Source)
+	for _, t := range []*time.Time{u.CreatedAt, u.UpdatedAt} {
+		if t != nil {
+			enc.WriteUint64(uint64(t.UnixNano()))
+		} else {
+			enc.WriteUint64(0)
+		}
+	}
+	return enc.Bytes(), nil
+}
+
+func (u *ManagerUser) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+
+	if u.version, err = dec.Uint16(); err != nil {
+		return err
+	}
+	if u.Id, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if u.Username, err = dec.String(); err != nil {
+		return err
+	}
+	if u.PasswordHash, err = dec.String(); err != nil {
+		return err
+	}
+	if u.Role, err = dec.String(); err != nil {
+		return err
+	}
+	var disabled uint8
+	if disabled, err = dec.Uint8(); err != nil {
+		return err
+	}
+	u.Disabled = disabled == 1
+	if u.Source, err = dec.String(); err != nil {
+		return err
+	}
+	for _, t := range []**time.Time{&u.CreatedAt, &u.UpdatedAt} {
+		var tm uint64
+		if tm, err = dec.Uint64(); err != nil {
+			return err
+		}
+		if tm > 0 {
+			ct := time.Unix(int64(tm/1e9), int64(tm%1e9))
+			*t = &ct
+		}
+	}
+	return nil
+}
+
+// AuditLog 审计日志（只追加，不修改）
+type AuditLog struct {
+	Id        uint64     `json:"id,omitempty"`         // 日志id（雪花id，按时间递增）
+	NodeId    uint64     `json:"node_id,omitempty"`    // 执行操作的节点
+	Action    string     `json:"action,omitempty"`     // 操作，比如 channel.delete
+	Actor     string     `json:"actor,omitempty"`      // 操作者（api key名称或管理员用户名）
+	ActorType string     `json:"actor_type,omitempty"` // 操作者类型 apiKey, manager, anonymous
+	SourceIp  string     `json:"source_ip,omitempty"`  // 来源ip
+	Method    string     `json:"method,omitempty"`     // 请求方法
+	Path      string     `json:"path,omitempty"`       // 请求路径
+	Target    string     `json:"target,omitempty"`     // 操作对象
+	Result    string     `json:"result,omitempty"`     // 结果 success, failure
+	Status    int        `json:"status,omitempty"`     // http状态码
+	Reason    string     `json:"reason,omitempty"`     // 失败原因
+	CreatedAt *time.Time `json:"created_at,omitempty"` // 操作时间
+
+	version uint16 // 数据版本
+}
+
+func (a *AuditLog) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+
+	enc.WriteUint16(a.version) // 数据版本
+
+	enc.WriteUint64(a.Id)
+	enc.WriteUint64(a.NodeId)
+	enc.WriteString(a.Action)
+	enc.WriteString(a.Actor)
+	enc.WriteString(a.ActorType)
+	enc.WriteString(a.SourceIp)
+	enc.WriteString(a.Method)
+	enc.WriteString(a.Path)
+	enc.WriteString(a.Target)
+	enc.WriteString(a.Result)
+	enc.WriteUint32(uint32(a.Status))
+	enc.WriteString(a.Reason)
+	if a.CreatedAt != nil {
+		enc.WriteUint64(uint64(a.CreatedAt.UnixNano()))
+	} else {
+		enc.WriteUint64(0)
+	}
+	return enc.Bytes(), nil
+}
+
+func (a *AuditLog) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	var err error
+
+	if a.version, err = dec.Uint16(); err != nil {
+		return err
+	}
+	if a.Id, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if a.NodeId, err = dec.Uint64(); err != nil {
+		return err
+	}
+	for _, s := range []*string{&a.Action, &a.Actor, &a.ActorType, &a.SourceIp, &a.Method, &a.Path, &a.Target, &a.Result} {
+		if *s, err = dec.String(); err != nil {
+			return err
+		}
+	}
+	var status uint32
+	if status, err = dec.Uint32(); err != nil {
+		return err
+	}
+	a.Status = int(status)
+	if a.Reason, err = dec.String(); err != nil {
+		return err
+	}
+	var createdAt uint64
+	if createdAt, err = dec.Uint64(); err != nil {
+		return err
+	}
+	if createdAt > 0 {
+		ct := time.Unix(int64(createdAt/1e9), int64(createdAt%1e9))
+		a.CreatedAt = &ct
+	}
+	return nil
+}
+
+type AuditLogs []AuditLog
+
+func (a AuditLogs) Marshal() ([]byte, error) {
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteUint32(uint32(len(a)))
+	for _, log := range a {
+		data, err := log.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		enc.WriteBinary(data)
+	}
+	return enc.Bytes(), nil
+}
+
+func (a *AuditLogs) Unmarshal(data []byte) error {
+	dec := wkproto.NewDecoder(data)
+	count, err := dec.Uint32()
+	if err != nil {
+		return err
+	}
+	logs := make([]AuditLog, 0, count)
+	for i := 0; i < int(count); i++ {
+		data, err := dec.Binary()
+		if err != nil {
+			return err
+		}
+		var log AuditLog
+		if err = log.Unmarshal(data); err != nil {
+			return err
+		}
+		logs = append(logs, log)
+	}
+	*a = logs
 	return nil
 }
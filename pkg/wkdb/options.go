@@ -1,5 +1,7 @@
 package wkdb
 
+import "github.com/WuKongIM/WuKongIM/pkg/encryption"
+
 type Options struct {
 	NodeId            uint64
 	DataDir           string
@@ -10,6 +12,14 @@ type Options struct {
 	ShardNum     int               // 数据库分区数量，一但设置就不能修改
 	IsCmdChannel func(string) bool // 是否是cmd频道
 	MemTableSize int
+
+	ColdStore             BlobStore // 冷存储 为nil表示不开启冷存储
+	ColdSegmentMaxCount   int       // 每个冷存储消息段的消息数量
+	ColdSegmentCacheCount int       // 内存中缓存的冷存储消息段数量
+
+	EncryptFS *encryption.FS // 静态数据加密的文件系统 为nil表示不加密
+
+	ReadOnly bool // 以只读方式打开（离线查看停止节点的数据）
 }
 
 func NewOptions(opt ...Option) *Options {
@@ -20,6 +30,9 @@ func NewOptions(opt ...Option) *Options {
 		EnableCost:        true,
 		ShardNum:          8,
 		MemTableSize:      16 * 1024 * 1024,
+
+		ColdSegmentMaxCount:   1000,
+		ColdSegmentCacheCount: 64,
 	}
 	for _, f := range opt {
 		f(o)
@@ -76,3 +89,33 @@ func WithMemTableSize(size int) Option {
 		o.MemTableSize = size
 	}
 }
+
+func WithColdStore(store BlobStore) Option {
+	return func(o *Options) {
+		o.ColdStore = store
+	}
+}
+
+func WithColdSegmentMaxCount(count int) Option {
+	return func(o *Options) {
+		o.ColdSegmentMaxCount = count
+	}
+}
+
+func WithColdSegmentCacheCount(count int) Option {
+	return func(o *Options) {
+		o.ColdSegmentCacheCount = count
+	}
+}
+
+func WithEncryptFS(fs *encryption.FS) Option {
+	return func(o *Options) {
+		o.EncryptFS = fs
+	}
+}
+
+func WithReadOnly() Option {
+	return func(o *Options) {
+		o.ReadOnly = true
+	}
+}
@@ -0,0 +1,543 @@
+package wkdb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/WuKongIM/WuKongIM/pkg/wklog"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/cockroachdb/pebble"
+	"go.uber.org/zap"
+)
+
+// 重新分区时每个批次写入的最大字节数
+const reshardBatchSize = 16 * 1024 * 1024
+
+var shardDirRegexp = regexp.MustCompile(`^shard(\d{3})$`)
+
+// ReshardResult 重新分区的结果
+type ReshardResult struct {
+	SrcShardNum    int      // 原分区数量
+	ShardNum       int      // 新分区数量
+	KeyCount       uint64   // key的总数量
+	ShardKeyCounts []uint64 // 新的每个分区的key数量
+	Checksum       uint64   // 所有key-value的校验和（与顺序无关）
+}
+
+// ShardNumOf 获取数据目录下已有的分区数量 没有数据返回0
+func ShardNumOf(dataDir string) (int, error) {
+	entries, err := os.ReadDir(filepath.Join(dataDir, "wukongimdb"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	ids := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		matches := shardDirRegexp.FindStringSubmatch(entry.Name())
+		if !entry.IsDir() || len(matches) != 2 {
+			continue
+		}
+		id, _ := strconv.Atoi(matches[1])
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for i, id := range ids {
+		if i != id {
+			return 0, fmt.Errorf("shard%03d is missing in %s", i, dataDir)
+		}
+	}
+	return len(ids), nil
+}
+
+// Reshard 离线将srcDir下的数据按新的分区数量shardNum重写到dstDir，srcDir不会被修改
+//
+// 每个key按照在线读写时的分区规则（频道数据按频道，用户数据按uid，全局数据在第一个分区）计算新的分区，
+// 写入完成后重新读取dstDir，校验每个分区的key数量和校验和，以及总数量和校验和与srcDir一致。
+// 执行期间节点必须是停止的，opts需要与节点打开数据库时的配置一致（例如加密）。
+func Reshard(srcDir, dstDir string, shardNum int, opts ...Option) (*ReshardResult, error) {
+	if shardNum <= 0 {
+		return nil, fmt.Errorf("invalid shard num: %d", shardNum)
+	}
+	o := NewOptions(opts...)
+
+	srcShardNum, err := ShardNumOf(srcDir)
+	if err != nil {
+		return nil, err
+	}
+	if srcShardNum == 0 {
+		return nil, fmt.Errorf("no shard found in %s", srcDir)
+	}
+	if _, err = os.Stat(filepath.Join(dstDir, "wukongimdb")); err == nil {
+		return nil, fmt.Errorf("%s already has data", dstDir)
+	}
+
+	log := wklog.NewWKLog("reshard")
+
+	srcs := make([]*pebble.DB, 0, srcShardNum)
+	defer func() {
+		for _, db := range srcs {
+			_ = db.Close()
+		}
+	}()
+	for i := 0; i < srcShardNum; i++ {
+		db, err := pebble.Open(shardDir(srcDir, i), reshardPebbleOptions(o, true))
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, db)
+	}
+
+	router := newReshardRouter(srcs, uint32(shardNum))
+	if err = router.load(); err != nil {
+		return nil, err
+	}
+	log.Info("reshard router loaded", zap.Int("names", len(router.names)), zap.Int("users", len(router.userUids)), zap.Int("devices", len(router.deviceUids)), zap.Int("channels", len(router.channelInfos)))
+
+	// 写入新的分区
+	expected := make([]Checksum, shardNum)
+	var srcSum Checksum
+	err = func() error {
+		dsts := make([]*pebble.DB, 0, shardNum)
+		defer func() {
+			for _, db := range dsts {
+				_ = db.Close()
+			}
+		}()
+		for i := 0; i < shardNum; i++ {
+			db, err := pebble.Open(shardDir(dstDir, i), reshardPebbleOptions(o, false))
+			if err != nil {
+				return err
+			}
+			dsts = append(dsts, db)
+		}
+		batches := make([]*pebble.Batch, shardNum)
+		for i, db := range dsts {
+			batches[i] = db.NewBatch()
+		}
+		for i, src := range srcs {
+			iter := src.NewIter(nil)
+			var count uint64
+			for iter.First(); iter.Valid(); iter.Next() {
+				shardId, err := router.route(iter.Key(), iter.Value())
+				if err != nil {
+					_ = iter.Close()
+					return fmt.Errorf("shard%03d key[%x]: %w", i, iter.Key(), err)
+				}
+				batch := batches[shardId]
+				if err = batch.Set(iter.Key(), iter.Value(), nil); err != nil {
+					_ = iter.Close()
+					return err
+				}
+				expected[shardId].Add(iter.Key(), iter.Value())
+				srcSum.Add(iter.Key(), iter.Value())
+				count++
+				if batch.Len() >= reshardBatchSize {
+					if err = batch.Commit(pebble.NoSync); err != nil {
+						_ = iter.Close()
+						return err
+					}
+					batches[shardId] = dsts[shardId].NewBatch()
+				}
+			}
+			if err = iter.Close(); err != nil {
+				return err
+			}
+			log.Info("shard rewritten", zap.Int("shard", i), zap.Uint64("keys", count))
+		}
+		for i, batch := range batches {
+			if err := batch.Commit(pebble.Sync); err != nil {
+				return err
+			}
+			if err := dsts[i].Flush(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验新的分区
+	result := &ReshardResult{
+		SrcShardNum:    srcShardNum,
+		ShardNum:       shardNum,
+		ShardKeyCounts: make([]uint64, shardNum),
+	}
+	var dstSum Checksum
+	for i := 0; i < shardNum; i++ {
+		actual, err := checksumShard(shardDir(dstDir, i), o)
+		if err != nil {
+			return nil, err
+		}
+		if actual != expected[i] {
+			return nil, fmt.Errorf("shard%03d verify failed: expected %d keys checksum %x, actual %d keys checksum %x", i, expected[i].Count, expected[i].Sum, actual.Count, actual.Sum)
+		}
+		result.ShardKeyCounts[i] = actual.Count
+		dstSum.Count += actual.Count
+		dstSum.Sum += actual.Sum
+	}
+	if dstSum != srcSum {
+		return nil, fmt.Errorf("verify failed: source %d keys checksum %x, target %d keys checksum %x", srcSum.Count, srcSum.Sum, dstSum.Count, dstSum.Sum)
+	}
+	result.KeyCount = dstSum.Count
+	result.Checksum = dstSum.Sum
+	return result, nil
+}
+
+func reshardPebbleOptions(o *Options, readOnly bool) *pebble.Options {
+	opts := (&wukongDB{opts: o}).defaultPebbleOptions()
+	opts.ReadOnly = readOnly
+	if o.EncryptFS != nil {
+		opts.FS = o.EncryptFS
+	}
+	return opts
+}
+
+// 计算分区所有key-value的数量和校验和
+func checksumShard(dir string, o *Options) (Checksum, error) {
+	var sum Checksum
+	db, err := pebble.Open(dir, reshardPebbleOptions(o, true))
+	if err != nil {
+		return sum, err
+	}
+	defer db.Close()
+	iter := db.NewIter(nil)
+	for iter.First(); iter.Valid(); iter.Next() {
+		sum.Add(iter.Key(), iter.Value())
+	}
+	return sum, iter.Close()
+}
+
+var errReshardUnresolved = errors.New("can not resolve the shard of key")
+
+// 根据key（以及value）计算数据在新分区中的位置
+//
+// key中只保存了uid等字符串的hash，所以先从原数据中加载hash与字符串、行id与uid的对应关系
+type reshardRouter struct {
+	srcs     []*pebble.DB
+	shardNum uint32
+
+	names        map[uint64]string // HashWithString(s) -> s（uid、频道的分区号）
+	userUids     map[uint64]string // 用户id -> uid
+	deviceUids   map[uint64]string // 设备id -> uid
+	channelInfos map[uint64]uint64 // 频道信息id -> 频道hash
+}
+
+func newReshardRouter(srcs []*pebble.DB, shardNum uint32) *reshardRouter {
+	return &reshardRouter{
+		srcs:         srcs,
+		shardNum:     shardNum,
+		names:        make(map[uint64]string),
+		userUids:     make(map[uint64]string),
+		deviceUids:   make(map[uint64]string),
+		channelInfos: make(map[uint64]uint64),
+	}
+}
+
+func (r *reshardRouter) load() error {
+	for _, db := range r.srcs {
+		if err := r.loadUids(db, key.TableUser.Id, key.TableUser.Size, key.TableUser.Column.Uid, r.userUids); err != nil {
+			return err
+		}
+		if err := r.loadUids(db, key.TableDevice.Id, key.TableDevice.Size, key.TableDevice.Column.Uid, r.deviceUids); err != nil {
+			return err
+		}
+		if err := r.loadUids(db, key.TableConversation.Id, key.TableConversation.Size, key.TableConversation.Column.Uid, nil); err != nil {
+			return err
+		}
+		if err := r.loadE2eeUids(db); err != nil {
+			return err
+		}
+		if err := r.loadChannels(db, key.TableChannelInfo.Id, key.TableChannelInfo.Size, key.TableChannelInfo.Column.ChannelId, key.TableChannelInfo.Column.ChannelType, r.channelInfos); err != nil {
+			return err
+		}
+		if err := r.loadChannels(db, key.TableChannelClusterConfig.Id, key.TableChannelClusterConfig.Size, key.TableChannelClusterConfig.Column.ChannelId, key.TableChannelClusterConfig.Column.ChannelType, nil); err != nil {
+			return err
+		}
+		if err := r.loadMessageChannels(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 表数据的迭代器
+func newTableIter(db *pebble.DB, tableId [2]byte) *pebble.Iterator {
+	return db.NewIter(&pebble.IterOptions{
+		LowerBound: []byte{tableId[0], tableId[1], key.DataTypeTable},
+		UpperBound: []byte{tableId[0], tableId[1], key.DataTypeTable + 1},
+	})
+}
+
+// 加载行的uid列（key结构：tableId + dataType + 行id + columnKey）
+func (r *reshardRouter) loadUids(db *pebble.DB, tableId [2]byte, size int, column [2]byte, rowUids map[uint64]string) error {
+	iter := newTableIter(db, tableId)
+	defer iter.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		k := iter.Key()
+		if len(k) != size || k[size-2] != column[0] || k[size-1] != column[1] {
+			continue
+		}
+		uid := string(iter.Value())
+		r.names[key.HashWithString(uid)] = uid
+		if rowUids != nil {
+			rowUids[binary.BigEndian.Uint64(k[4:])] = uid
+		}
+	}
+	return iter.Error()
+}
+
+func (r *reshardRouter) loadE2eeUids(db *pebble.DB) error {
+	iter := newTableIter(db, key.TableE2eeKeyBundle.Id)
+	defer iter.Close()
+	for iter.First(); iter.Valid(); iter.Next() {
+		var bundle E2eeKeyBundle
+		if err := bundle.Decode(iter.Value()); err != nil {
+			return err
+		}
+		r.names[key.HashWithString(bundle.Uid)] = bundle.Uid
+	}
+	return iter.Error()
+}
+
+// 加载行的频道（key结构：tableId + dataType + 行id + columnKey）
+func (r *reshardRouter) loadChannels(db *pebble.DB, tableId [2]byte, size int, channelIdColumn, channelTypeColumn [2]byte, rowChannels map[uint64]uint64) error {
+	iter := newTableIter(db, tableId)
+	defer iter.Close()
+
+	channelIds := make(map[uint64]string)
+	channelTypes := make(map[uint64]uint8)
+	for iter.First(); iter.Valid(); iter.Next() {
+		k := iter.Key()
+		if len(k) != size || len(iter.Value()) == 0 {
+			continue
+		}
+		id := binary.BigEndian.Uint64(k[4:])
+		column := [2]byte{k[size-2], k[size-1]}
+		switch column {
+		case channelIdColumn:
+			channelIds[id] = string(iter.Value())
+		case channelTypeColumn:
+			channelTypes[id] = iter.Value()[0]
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	for id, channelId := range channelIds {
+		channelType, ok := channelTypes[id]
+		if !ok {
+			continue
+		}
+		r.addChannel(channelId, channelType)
+		if rowChannels != nil {
+			rowChannels[id] = key.ChannelToNum(channelId, channelType)
+		}
+	}
+	return nil
+}
+
+// 加载消息所属的频道（每个频道只读取第一条消息）
+func (r *reshardRouter) loadMessageChannels(db *pebble.DB) error {
+	iter := newTableIter(db, key.TableMessage.Id)
+	defer iter.Close()
+
+	for valid := iter.First(); valid; {
+		k := iter.Key()
+		if len(k) != key.TableMessage.Size {
+			valid = iter.Next()
+			continue
+		}
+		channelHash := binary.BigEndian.Uint64(k[4:])
+		var primary [16]byte
+		copy(primary[:], k[4:20])
+
+		// 同一行的列
+		isColumn := func(column [2]byte) bool {
+			ck := iter.Key()
+			return len(ck) == key.TableMessage.Size && string(ck[4:20]) == string(primary[:]) && ck[20] == column[0] && ck[21] == column[1]
+		}
+		if iter.SeekGE(key.NewMessageColumnKeyWithPrimary(primary, key.TableMessage.Column.ChannelId)) && isColumn(key.TableMessage.Column.ChannelId) {
+			channelId := string(iter.Value())
+			if iter.Next() && isColumn(key.TableMessage.Column.ChannelType) && len(iter.Value()) > 0 {
+				r.addChannel(channelId, iter.Value()[0])
+			}
+		}
+		if channelHash == math.MaxUint64 {
+			break
+		}
+		next := make([]byte, 12)
+		copy(next, k[:4])
+		binary.BigEndian.PutUint64(next[4:], channelHash+1)
+		valid = iter.SeekGE(next)
+	}
+	return iter.Error()
+}
+
+// 频道的分区号（频道的副本日志按分区号保存领导任期）
+func (r *reshardRouter) addChannel(channelId string, channelType uint8) {
+	shardNo := wkutil.ChannelToKey(channelId, channelType)
+	r.names[key.HashWithString(shardNo)] = shardNo
+}
+
+// 与wukongDB.channelDbIndex一致
+func (r *reshardRouter) channelShard(data []byte, offset int) (uint32, error) {
+	if len(data) < offset+8 {
+		return 0, errReshardUnresolved
+	}
+	return uint32(binary.BigEndian.Uint64(data[offset:]) % uint64(r.shardNum)), nil
+}
+
+// 与wukongDB.shardId一致
+func (r *reshardRouter) stringShard(v string) (uint32, error) {
+	if v == "" {
+		return 0, errReshardUnresolved
+	}
+	if r.shardNum == 1 {
+		return 0, nil
+	}
+	h := fnv.New32()
+	h.Write([]byte(v))
+	return h.Sum32() % r.shardNum, nil
+}
+
+// key中保存的字符串hash所在的分区
+func (r *reshardRouter) nameShard(data []byte, offset int) (uint32, error) {
+	if len(data) < offset+8 {
+		return 0, errReshardUnresolved
+	}
+	return r.stringShard(r.names[binary.BigEndian.Uint64(data[offset:])])
+}
+
+// key中保存的行id所在的分区
+func (r *reshardRouter) rowUidShard(rowUids map[uint64]string, data []byte, offset int) (uint32, error) {
+	if len(data) < offset+8 {
+		return 0, errReshardUnresolved
+	}
+	return r.stringShard(rowUids[binary.BigEndian.Uint64(data[offset:])])
+}
+
+func (r *reshardRouter) channelInfoShard(data []byte, offset int) (uint32, error) {
+	if len(data) < offset+8 {
+		return 0, errReshardUnresolved
+	}
+	channelHash, ok := r.channelInfos[binary.BigEndian.Uint64(data[offset:])]
+	if !ok {
+		return 0, errReshardUnresolved
+	}
+	return uint32(channelHash % uint64(r.shardNum)), nil
+}
+
+func (r *reshardRouter) route(k, v []byte) (uint32, error) {
+	if len(k) < 4 {
+		return 0, errReshardUnresolved
+	}
+	tableId := [2]byte{k[0], k[1]}
+	dataType := k[2]
+
+	switch tableId {
+	case key.TableMessage.Id:
+		switch dataType {
+		case key.DataTypeTable, key.DataTypeOther:
+			return r.channelShard(k, 4)
+		case key.DataTypeIndex:
+			if len(k) == key.TableMessage.IndexSize { // messageId索引，值为消息主键
+				return r.channelShard(v, 0)
+			}
+			return r.channelShard(k, 14) // 时间戳索引
+		case key.DataTypeSecondIndex:
+			if len(k) == key.TableMessage.SecondIndexSize && k[4] == key.TableMessage.SecondIndex.ChannelTimestamp[0] && k[5] == key.TableMessage.SecondIndex.ChannelTimestamp[1] {
+				return r.channelShard(k, 6) // 频道内的时间索引
+			}
+			return r.channelShard(k, 14)
+		}
+	case key.TableUser.Id:
+		switch dataType {
+		case key.DataTypeTable:
+			return r.rowUidShard(r.userUids, k, 4)
+		case key.DataTypeSecondIndex:
+			return r.rowUidShard(r.userUids, k, 14)
+		}
+	case key.TableDevice.Id:
+		switch dataType {
+		case key.DataTypeTable:
+			return r.rowUidShard(r.deviceUids, k, 4)
+		case key.DataTypeSecondIndex:
+			return r.rowUidShard(r.deviceUids, k, 14)
+		}
+	case key.TableSubscriber.Id, key.TableDenylist.Id, key.TableAllowlist.Id:
+		switch dataType {
+		case key.DataTypeTable:
+			return r.channelShard(k, 4)
+		case key.DataTypeIndex, key.DataTypeSecondIndex:
+			return r.channelShard(k, 6)
+		}
+	case key.TableChannelInfo.Id:
+		switch dataType {
+		case key.DataTypeTable:
+			return r.channelInfoShard(k, 4)
+		case key.DataTypeIndex:
+			if len(k) == key.TableChannelInfo.IndexSize { // 频道索引，值为频道hash
+				return r.channelShard(k, 6)
+			}
+			return r.channelInfoShard(k, 14) // 二级索引
+		case key.DataTypeSecondIndex:
+			return r.channelInfoShard(k, 14)
+		}
+	case key.TableConversation.Id:
+		switch dataType {
+		case key.DataTypeTable, key.DataTypeSecondIndex:
+			return r.nameShard(k, 4)
+		case key.DataTypeIndex:
+			return r.nameShard(k, 6)
+		}
+	case key.TableLeaderTermSequence.Id, key.TableE2eeOnetimePrekey.Id:
+		return r.nameShard(k, 4)
+	case key.TableChannelCommon.Id,
+		key.TableConversationLocalUser.Id,
+		key.TableSubscriberHistory.Id,
+		key.TableMessageVisibility.Id,
+		key.TableHiddenMessage.Id,
+		key.TableMessageColdSegment.Id:
+		return r.channelShard(k, 4)
+	case key.TableStream.Id:
+		var stream Stream
+		if err := stream.Decode(v); err != nil {
+			return 0, err
+		}
+		return r.stringShard(stream.StreamNo)
+	case key.TableStreamMeta.Id:
+		var streamMeta StreamMeta
+		if err := streamMeta.Decode(v); err != nil {
+			return 0, err
+		}
+		return r.stringShard(streamMeta.StreamNo)
+	case key.TableE2eeKeyBundle.Id:
+		var bundle E2eeKeyBundle
+		if err := bundle.Decode(v); err != nil {
+			return 0, err
+		}
+		return r.stringShard(bundle.Uid)
+	case key.TableMessageNotifyQueue.Id,
+		key.TableChannelClusterConfig.Id,
+		key.TableTotal.Id,
+		key.TableSystemUid.Id,
+		key.TableApiKey.Id,
+		key.TableAuditLog.Id,
+		key.TableManagerUser.Id:
+		return 0, nil // 全局数据在第一个分区
+	}
+	return 0, errReshardUnresolved
+}
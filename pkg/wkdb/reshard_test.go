@@ -0,0 +1,113 @@
+package wkdb_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	"github.com/WuKongIM/WuKongIM/pkg/wkutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReshard(t *testing.T) {
+	srcDir := t.TempDir()
+	dstDir := t.TempDir()
+
+	d := newTestDBWithOptions(t, wkdb.WithDir(srcDir), wkdb.WithShardNum(2))
+	assert.NoError(t, d.Open())
+
+	tn := time.Now()
+	for i := 0; i < 20; i++ {
+		uid := fmt.Sprintf("uid%d", i)
+		channelId := fmt.Sprintf("channel%d", i)
+
+		assert.NoError(t, d.AddUser(wkdb.User{Uid: uid, CreatedAt: &tn, UpdatedAt: &tn}))
+		assert.NoError(t, d.AddDevice(wkdb.Device{Id: d.NextPrimaryKey(), Uid: uid, Token: "token", DeviceFlag: 1, CreatedAt: &tn, UpdatedAt: &tn}))
+		assert.NoError(t, d.AddOrUpdateConversations([]wkdb.Conversation{{Id: d.NextPrimaryKey(), Uid: uid, ChannelId: channelId, ChannelType: 2, CreatedAt: &tn, UpdatedAt: &tn}}))
+		assert.NoError(t, d.SaveE2eeKeyBundle(wkdb.E2eeKeyBundle{Uid: uid, DeviceId: "device", IdentityKey: []byte("identity")}, []wkdb.E2eePrekey{{KeyId: 1, PublicKey: []byte("prekey")}}))
+
+		_, err := d.AddChannel(wkdb.ChannelInfo{ChannelId: channelId, ChannelType: 2, CreatedAt: &tn, UpdatedAt: &tn})
+		assert.NoError(t, err)
+		assert.NoError(t, d.AddSubscribers(channelId, 2, []wkdb.Member{{Id: d.NextPrimaryKey(), Uid: uid, CreatedAt: &tn, UpdatedAt: &tn}}))
+		assert.NoError(t, d.SaveChannelClusterConfig(wkdb.ChannelClusterConfig{ChannelId: channelId, ChannelType: 2, Replicas: []uint64{1}, LeaderId: 1, Term: 1}))
+		assert.NoError(t, d.SetLeaderTermStartIndex(wkutil.ChannelToKey(channelId, 2), 1, 1))
+		assert.NoError(t, d.AddStream(&wkdb.Stream{StreamNo: fmt.Sprintf("stream%d", i), StreamId: 1, Payload: []byte("payload")}))
+		appendTestMessages(t, d, channelId, 2, 10)
+	}
+	assert.NoError(t, d.Close())
+
+	shardNum, err := wkdb.ShardNumOf(srcDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, shardNum)
+
+	// 分区数量与已有数据不一致时不能打开
+	err = newTestDBWithOptions(t, wkdb.WithDir(srcDir), wkdb.WithShardNum(3)).Open()
+	assert.ErrorIs(t, err, wkdb.ErrShardNumMismatch)
+
+	result, err := wkdb.Reshard(srcDir, dstDir, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.SrcShardNum)
+	assert.Equal(t, 3, len(result.ShardKeyCounts))
+	assert.True(t, result.KeyCount > 0)
+	for _, count := range result.ShardKeyCounts {
+		assert.True(t, count > 0)
+	}
+
+	// 目标目录已有数据
+	_, err = wkdb.Reshard(srcDir, dstDir, 3)
+	assert.Error(t, err)
+
+	// 按新的分区数量打开后所有数据都能读取
+	d = newTestDBWithOptions(t, wkdb.WithDir(dstDir), wkdb.WithShardNum(3))
+	assert.NoError(t, d.Open())
+	defer d.Close()
+
+	for i := 0; i < 20; i++ {
+		uid := fmt.Sprintf("uid%d", i)
+		channelId := fmt.Sprintf("channel%d", i)
+
+		user, err := d.GetUser(uid)
+		assert.NoError(t, err)
+		assert.Equal(t, uid, user.Uid)
+
+		device, err := d.GetDevice(uid, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, "token", device.Token)
+
+		conversations, err := d.GetConversations(uid)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(conversations))
+
+		bundle, err := d.GetE2eeKeyBundle(uid, "device")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("identity"), bundle.IdentityKey)
+		prekeys, err := d.GetE2eeOnetimePrekeys(uid, "device", 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(prekeys))
+
+		channel, err := d.GetChannel(channelId, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, channelId, channel.ChannelId)
+
+		members, err := d.GetSubscribers(channelId, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(members))
+
+		cfg, err := d.GetChannelClusterConfig(channelId, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), cfg.LeaderId)
+
+		index, err := d.LeaderTermStartIndex(wkutil.ChannelToKey(channelId, 2), 1)
+		assert.NoError(t, err)
+		assert.Equal(t, uint64(1), index)
+
+		streams, err := d.GetStreams(fmt.Sprintf("stream%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, 1, len(streams))
+
+		messages, err := d.LoadNextRangeMsgs(channelId, 2, 1, 0, 100)
+		assert.NoError(t, err)
+		assert.Equal(t, 10, len(messages))
+	}
+}
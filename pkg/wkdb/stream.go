@@ -23,8 +23,13 @@ func (wk *wukongDB) GetStreamMeta(streamNo string) (*StreamMeta, error) {
 	db := wk.shardDB(streamNo)
 	keyBytes := key.NewStreamMetaKey(streamNo)
 	valueBytes, closer, err := db.Get(keyBytes)
-	defer closer.Close()
+	if closer != nil {
+		defer closer.Close()
+	}
 	if err != nil {
+		if err == pebble.ErrNotFound {
+			return nil, nil
+		}
 		return nil, err
 	}
 
@@ -62,10 +67,7 @@ func (wk *wukongDB) AddStreams(streams []*Stream) error {
 func (wk *wukongDB) GetStreams(streamNo string) ([]*Stream, error) {
 	db := wk.shardDB(streamNo)
 
-	batch := db.NewBatch()
-	defer batch.Close()
-
-	iter := batch.NewIter(&pebble.IterOptions{
+	iter := db.NewIter(&pebble.IterOptions{
 		LowerBound: key.NewStreamIndexKey(streamNo, 0),
 		UpperBound: key.NewStreamIndexKey(streamNo, math.MaxUint64),
 	})
@@ -77,11 +79,27 @@ func (wk *wukongDB) GetStreams(streamNo string) ([]*Stream, error) {
 		if err := stream.Decode(iter.Value()); err != nil {
 			return nil, err
 		}
+		stream.StreamId = wk.endian.Uint64(iter.Key()[len(iter.Key())-8:]) // 流序号在key的最后8位
 		streams = append(streams, stream)
 	}
 	return streams, nil
 }
 
+func (wk *wukongDB) GetStreamLastId(streamNo string) (uint64, error) {
+	db := wk.shardDB(streamNo)
+
+	iter := db.NewIter(&pebble.IterOptions{
+		LowerBound: key.NewStreamIndexKey(streamNo, 0),
+		UpperBound: key.NewStreamIndexKey(streamNo, math.MaxUint64),
+	})
+	defer iter.Close()
+
+	if !iter.Last() {
+		return 0, iter.Error()
+	}
+	return wk.endian.Uint64(iter.Key()[len(iter.Key())-8:]), nil
+}
+
 type StreamMeta struct {
 	version     int16 // 数据版本
 	StreamNo    string
@@ -91,6 +109,8 @@ type StreamMeta struct {
 	ClientMsgNo string
 	MessageId   int64
 	MessageSeq  int64
+	End         uint8 // 流是否已结束 1.已结束
+	EndAt       int64 // 流结束时间（秒）
 }
 
 func (s *StreamMeta) Encode() []byte {
@@ -104,6 +124,8 @@ func (s *StreamMeta) Encode() []byte {
 	enc.WriteString(s.ClientMsgNo)
 	enc.WriteInt64(s.MessageId)
 	enc.WriteInt64(s.MessageSeq)
+	enc.WriteUint8(s.End)
+	enc.WriteInt64(s.EndAt)
 	return enc.Bytes()
 }
 
@@ -134,21 +156,35 @@ func (s *StreamMeta) Decode(data []byte) error {
 	if s.MessageSeq, err = dec.Int64(); err != nil {
 		return err
 	}
+	// 兼容没有结束标记的老数据
+	if dec.Len() == 0 {
+		return nil
+	}
+	if s.End, err = dec.Uint8(); err != nil {
+		return err
+	}
+	if s.EndAt, err = dec.Int64(); err != nil {
+		return err
+	}
 	return nil
 }
 
+// 流分片的数据版本，版本0的数据没有StreamId字段
+const streamDataVersion = 1
+
 type Stream struct {
 	version  int16 // 数据版本
 	StreamNo string
-	StreamId uint64
+	StreamId uint64 // 流内的分片序号（有序递增）
 	Payload  []byte
 }
 
 func (s *Stream) Encode() []byte {
 	enc := wkproto.NewEncoder()
 	defer enc.End()
-	enc.WriteInt16(int(s.version))
+	enc.WriteInt16(streamDataVersion)
 	enc.WriteString(s.StreamNo)
+	enc.WriteUint64(s.StreamId)
 	enc.WriteBytes(s.Payload)
 	return enc.Bytes()
 }
@@ -162,6 +198,11 @@ func (s *Stream) Decode(data []byte) error {
 	if s.StreamNo, err = dec.String(); err != nil {
 		return err
 	}
+	if s.version > 0 { // 老版本的数据没有StreamId（由key中获取）
+		if s.StreamId, err = dec.Uint64(); err != nil {
+			return err
+		}
+	}
 	if s.Payload, err = dec.BinaryAll(); err != nil {
 		return err
 	}
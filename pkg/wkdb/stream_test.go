@@ -0,0 +1,101 @@
+package wkdb_test
+
+import (
+	"testing"
+
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb"
+	wkproto "github.com/WuKongIM/WuKongIMGoProto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAndGetStreamMeta(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	meta, err := d.GetStreamMeta("notexist")
+	assert.NoError(t, err)
+	assert.Nil(t, meta)
+
+	streamMeta := &wkdb.StreamMeta{
+		StreamNo:    "stream1",
+		ChannelId:   "channel1",
+		ChannelType: 2,
+		FromUid:     "u1",
+		ClientMsgNo: "no1",
+		MessageId:   100,
+		MessageSeq:  10,
+		End:         1,
+		EndAt:       1000,
+	}
+	err = d.AddStreamMeta(streamMeta)
+	assert.NoError(t, err)
+
+	meta, err = d.GetStreamMeta("stream1")
+	assert.NoError(t, err)
+	assert.Equal(t, streamMeta, meta)
+}
+
+func TestAddAndGetStreams(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	streams := []*wkdb.Stream{
+		{StreamNo: "stream1", StreamId: 2, Payload: []byte("world")},
+		{StreamNo: "stream1", StreamId: 1, Payload: []byte("hello")},
+		{StreamNo: "stream2", StreamId: 1, Payload: []byte("other")},
+	}
+	err = d.AddStreams(streams)
+	assert.NoError(t, err)
+
+	results, err := d.GetStreams("stream1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(results))
+	assert.Equal(t, uint64(1), results[0].StreamId)
+	assert.Equal(t, []byte("hello"), results[0].Payload)
+	assert.Equal(t, uint64(2), results[1].StreamId)
+	assert.Equal(t, []byte("world"), results[1].Payload)
+
+	lastId, err := d.GetStreamLastId("stream1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(2), lastId)
+
+	lastId, err = d.GetStreamLastId("notexist")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), lastId)
+}
+
+func TestStreamDecodeOldVersion(t *testing.T) {
+	// 版本0的数据没有StreamId
+	enc := wkproto.NewEncoder()
+	defer enc.End()
+	enc.WriteInt16(0)
+	enc.WriteString("stream1")
+	enc.WriteBytes([]byte("hello"))
+
+	stream := &wkdb.Stream{}
+	err := stream.Decode(enc.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, "stream1", stream.StreamNo)
+	assert.Equal(t, uint64(0), stream.StreamId)
+	assert.Equal(t, []byte("hello"), stream.Payload)
+
+	stream = &wkdb.Stream{StreamNo: "stream2", StreamId: 3, Payload: []byte("world")}
+	decoded := &wkdb.Stream{}
+	err = decoded.Decode(stream.Encode())
+	assert.NoError(t, err)
+	assert.Equal(t, "stream2", decoded.StreamNo)
+	assert.Equal(t, uint64(3), decoded.StreamId)
+	assert.Equal(t, []byte("world"), decoded.Payload)
+}
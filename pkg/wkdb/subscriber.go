@@ -118,6 +118,71 @@ func (wk *wukongDB) ExistSubscriber(channelId string, channelType uint8, uid str
 	return true, nil
 }
 
+func (wk *wukongDB) GetSubscriber(channelId string, channelType uint8, uid string) (Member, error) {
+
+	wk.metrics.GetSubscriberAdd(1)
+
+	id := key.HashWithString(uid)
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewSubscriberColumnKey(channelId, channelType, id, key.MinColumnKey),
+		UpperBound: key.NewSubscriberColumnKey(channelId, channelType, id, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var member Member
+	err := wk.iterateSubscriber(iter, func(m Member) bool {
+		member = m
+		return false
+	})
+	if err != nil {
+		return Member{}, err
+	}
+	if member.Uid == "" {
+		return Member{}, ErrNotFound
+	}
+	return member, nil
+}
+
+// UpdateSubscribers 更新订阅者的角色、禁言、加入来源和自定义属性，不存在的订阅者将被忽略
+func (wk *wukongDB) UpdateSubscribers(channelId string, channelType uint8, members []Member) error {
+
+	wk.metrics.UpdateSubscribersAdd(1)
+
+	db := wk.channelDb(channelId, channelType)
+	w := db.NewIndexedBatch()
+	defer w.Close()
+	for _, member := range members {
+		oldMember, err := wk.GetSubscriber(channelId, channelType, member.Uid)
+		if err != nil {
+			if err == ErrNotFound {
+				continue
+			}
+			return err
+		}
+		member.Id = oldMember.Id
+		if err = wk.writeSubscriberProps(channelId, channelType, member, true, w); err != nil {
+			return err
+		}
+		if member.UpdatedAt != nil {
+			// 替换updatedAt及其索引
+			if oldMember.UpdatedAt != nil {
+				if err = w.Delete(key.NewSubscriberSecondIndexKey(channelId, channelType, key.TableSubscriber.SecondIndex.UpdatedAt, uint64(oldMember.UpdatedAt.UnixNano()), oldMember.Id), wk.noSync); err != nil {
+					return err
+				}
+			}
+			updatedAt := make([]byte, 8)
+			wk.endian.PutUint64(updatedAt, uint64(member.UpdatedAt.UnixNano()))
+			if err = w.Set(key.NewSubscriberColumnKey(channelId, channelType, member.Id, key.TableSubscriber.Column.UpdatedAt), updatedAt, wk.noSync); err != nil {
+				return err
+			}
+			if err = w.Set(key.NewSubscriberSecondIndexKey(channelId, channelType, key.TableSubscriber.SecondIndex.UpdatedAt, uint64(member.UpdatedAt.UnixNano()), member.Id), nil, wk.noSync); err != nil {
+				return err
+			}
+		}
+	}
+	return w.Commit(wk.sync)
+}
+
 func (wk *wukongDB) RemoveAllSubscriber(channelId string, channelType uint8) error {
 
 	wk.metrics.RemoveAllSubscriberAdd(1)
@@ -259,6 +324,16 @@ func (wk *wukongDB) iterateSubscriber(iter *pebble.Iterator, iterFnc func(member
 				t := time.Unix(tm/1e9, tm%1e9)
 				preMember.UpdatedAt = &t
 			}
+		case key.TableSubscriber.Column.Role:
+			preMember.Role = MemberRole(iter.Value()[0])
+		case key.TableSubscriber.Column.MuteUntil:
+			preMember.MuteUntil = int64(wk.endian.Uint64(iter.Value()))
+		case key.TableSubscriber.Column.JoinSource:
+			preMember.JoinSource = string(iter.Value())
+		case key.TableSubscriber.Column.Attributes:
+			if err := preMember.unmarshalAttributes(iter.Value()); err != nil {
+				return err
+			}
 		}
 		hasData = true
 	}
@@ -303,6 +378,11 @@ func (wk *wukongDB) writeSubscriber(channelId string, channelType uint8, member
 
 	}
 
+	// 新增订阅者时只写入有值的属性
+	if err = wk.writeSubscriberProps(channelId, channelType, member, false, w); err != nil {
+		return err
+	}
+
 	if member.UpdatedAt != nil {
 		// updatedAt
 		updatedAt := make([]byte, 8)
@@ -320,6 +400,44 @@ func (wk *wukongDB) writeSubscriber(channelId string, channelType uint8, member
 	return nil
 }
 
+// writeSubscriberProps 写入订阅者的角色、禁言、加入来源和自定义属性，overwrite为true时空值将删除对应的列
+func (wk *wukongDB) writeSubscriberProps(channelId string, channelType uint8, member Member, overwrite bool, w pebble.Writer) error {
+	setOrDelete := func(columnName [2]byte, value []byte, empty bool) error {
+		columnKey := key.NewSubscriberColumnKey(channelId, channelType, member.Id, columnName)
+		if empty {
+			if overwrite {
+				return w.Delete(columnKey, wk.noSync)
+			}
+			return nil
+		}
+		return w.Set(columnKey, value, wk.noSync)
+	}
+
+	// role
+	if err := setOrDelete(key.TableSubscriber.Column.Role, []byte{uint8(member.Role)}, member.Role == MemberRoleMember); err != nil {
+		return err
+	}
+
+	// muteUntil
+	muteUntil := make([]byte, 8)
+	wk.endian.PutUint64(muteUntil, uint64(member.MuteUntil))
+	if err := setOrDelete(key.TableSubscriber.Column.MuteUntil, muteUntil, member.MuteUntil <= 0); err != nil {
+		return err
+	}
+
+	// joinSource
+	if err := setOrDelete(key.TableSubscriber.Column.JoinSource, []byte(member.JoinSource), member.JoinSource == ""); err != nil {
+		return err
+	}
+
+	// attributes
+	attributes, err := member.marshalAttributes()
+	if err != nil {
+		return err
+	}
+	return setOrDelete(key.TableSubscriber.Column.Attributes, attributes, len(attributes) == 0)
+}
+
 func (wk *wukongDB) deleteAllSubscriberIndex(channelId string, channelType uint8, w pebble.Writer) error {
 
 	var err error
@@ -0,0 +1,109 @@
+package wkdb
+
+import (
+	"github.com/WuKongIM/WuKongIM/pkg/wkdb/key"
+	"github.com/cockroachdb/pebble"
+)
+
+func (wk *wukongDB) RecordSubscriberHistories(channelId string, channelType uint8, seq uint64, joins []string, leaves []string) error {
+
+	wk.metrics.RecordSubscriberHistoriesAdd(1)
+
+	w := wk.channelDb(channelId, channelType).NewBatch()
+	defer w.Close()
+	for _, uid := range joins {
+		history, err := wk.GetSubscriberHistory(channelId, channelType, uid)
+		if err != nil {
+			if err != ErrNotFound {
+				return err
+			}
+			history = SubscriberHistory{Uid: uid, JoinSeq: seq}
+		} else if !history.Join(seq) {
+			continue
+		}
+		if err = wk.writeSubscriberHistory(channelId, channelType, history, w); err != nil {
+			return err
+		}
+	}
+	for _, uid := range leaves {
+		history, err := wk.GetSubscriberHistory(channelId, channelType, uid)
+		if err != nil {
+			if err != ErrNotFound {
+				return err
+			}
+			// 没有加入记录的订阅者是在记录功能之前加入的，可以看到离开前的所有消息
+			history = SubscriberHistory{Uid: uid}
+		}
+		if !history.Leave(seq) {
+			continue
+		}
+		if err = wk.writeSubscriberHistory(channelId, channelType, history, w); err != nil {
+			return err
+		}
+	}
+	return w.Commit(wk.sync)
+}
+
+func (wk *wukongDB) writeSubscriberHistory(channelId string, channelType uint8, history SubscriberHistory, w pebble.Writer) error {
+	id := key.HashWithString(history.Uid)
+
+	joinSeq := make([]byte, 8)
+	wk.endian.PutUint64(joinSeq, history.JoinSeq)
+	if err := w.Set(key.NewSubscriberHistoryColumnKey(channelId, channelType, id, key.TableSubscriberHistory.Column.JoinSeq), joinSeq, wk.noSync); err != nil {
+		return err
+	}
+
+	leaveSeq := make([]byte, 8)
+	wk.endian.PutUint64(leaveSeq, history.LeaveSeq)
+	if err := w.Set(key.NewSubscriberHistoryColumnKey(channelId, channelType, id, key.TableSubscriberHistory.Column.LeaveSeq), leaveSeq, wk.noSync); err != nil {
+		return err
+	}
+
+	// 之前的范围，每个范围16字节：joinSeq + leaveSeq
+	prevRanges := make([]byte, 16*len(history.PrevRanges))
+	for i, r := range history.PrevRanges {
+		wk.endian.PutUint64(prevRanges[i*16:], r.JoinSeq)
+		wk.endian.PutUint64(prevRanges[i*16+8:], r.LeaveSeq)
+	}
+	return w.Set(key.NewSubscriberHistoryColumnKey(channelId, channelType, id, key.TableSubscriberHistory.Column.PrevRanges), prevRanges, wk.noSync)
+}
+
+func (wk *wukongDB) GetSubscriberHistory(channelId string, channelType uint8, uid string) (SubscriberHistory, error) {
+
+	wk.metrics.GetSubscriberHistoryAdd(1)
+
+	id := key.HashWithString(uid)
+	iter := wk.channelDb(channelId, channelType).NewIter(&pebble.IterOptions{
+		LowerBound: key.NewSubscriberHistoryColumnKey(channelId, channelType, id, key.MinColumnKey),
+		UpperBound: key.NewSubscriberHistoryColumnKey(channelId, channelType, id, key.MaxColumnKey),
+	})
+	defer iter.Close()
+
+	var (
+		history = SubscriberHistory{Uid: uid}
+		exist   bool
+	)
+	for iter.First(); iter.Valid(); iter.Next() {
+		k := iter.Key()
+		columnName := [2]byte{k[len(k)-2], k[len(k)-1]}
+		switch columnName {
+		case key.TableSubscriberHistory.Column.JoinSeq:
+			history.JoinSeq = wk.endian.Uint64(iter.Value())
+		case key.TableSubscriberHistory.Column.LeaveSeq:
+			history.LeaveSeq = wk.endian.Uint64(iter.Value())
+		case key.TableSubscriberHistory.Column.PrevRanges:
+			value := iter.Value()
+			for i := 0; i+16 <= len(value); i += 16 {
+				history.PrevRanges = append(history.PrevRanges, SubscriberSeqRange{
+					JoinSeq:  wk.endian.Uint64(value[i:]),
+					LeaveSeq: wk.endian.Uint64(value[i+8:]),
+				})
+			}
+		}
+		exist = true
+	}
+	if !exist {
+		return SubscriberHistory{}, ErrNotFound
+	}
+	return history, nil
+}
@@ -234,3 +234,169 @@ func TestRemoveAllSubscriber(t *testing.T) {
 
 	assert.Equal(t, 0, len(subscribers2))
 }
+
+func TestUpdateSubscribers(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	createdAt := time.Now()
+	updatedAt := time.Now()
+
+	channelId := "channel1"
+	channelType := uint8(2)
+	err = d.AddSubscribers(channelId, channelType, []wkdb.Member{
+		{
+			Uid:        "uid1",
+			Role:       wkdb.MemberRoleOwner,
+			JoinSource: "invite",
+			CreatedAt:  &createdAt,
+			UpdatedAt:  &updatedAt,
+		},
+		{
+			Uid:       "uid2",
+			CreatedAt: &createdAt,
+			UpdatedAt: &updatedAt,
+		},
+	})
+	assert.NoError(t, err)
+
+	member, err := d.GetSubscriber(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, wkdb.MemberRoleOwner, member.Role)
+	assert.Equal(t, "invite", member.JoinSource)
+
+	muteUntil := time.Now().Add(time.Hour).Unix()
+	updatedAt2 := time.Now().Add(time.Second)
+	err = d.UpdateSubscribers(channelId, channelType, []wkdb.Member{
+		{
+			Uid:        "uid2",
+			Role:       wkdb.MemberRoleAdmin,
+			MuteUntil:  muteUntil,
+			Attributes: map[string]string{"nickname": "n2"},
+			UpdatedAt:  &updatedAt2,
+		},
+		{
+			Uid:  "not_exist",
+			Role: wkdb.MemberRoleAdmin,
+		},
+	})
+	assert.NoError(t, err)
+
+	member, err = d.GetSubscriber(channelId, channelType, "uid2")
+	assert.NoError(t, err)
+	assert.Equal(t, wkdb.MemberRoleAdmin, member.Role)
+	assert.Equal(t, muteUntil, member.MuteUntil)
+	assert.True(t, member.IsMuted(time.Now()))
+	assert.Equal(t, "n2", member.Attributes["nickname"])
+	assert.Equal(t, createdAt.Unix(), member.CreatedAt.Unix())
+	assert.Equal(t, updatedAt2.Unix(), member.UpdatedAt.Unix())
+
+	// 不存在的订阅者不会被添加
+	_, err = d.GetSubscriber(channelId, channelType, "not_exist")
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	// 清空属性
+	err = d.UpdateSubscribers(channelId, channelType, []wkdb.Member{{Uid: "uid2"}})
+	assert.NoError(t, err)
+	member, err = d.GetSubscriber(channelId, channelType, "uid2")
+	assert.NoError(t, err)
+	assert.Equal(t, wkdb.MemberRoleMember, member.Role)
+	assert.Equal(t, int64(0), member.MuteUntil)
+	assert.Nil(t, member.Attributes)
+}
+
+func TestMemberMarshal(t *testing.T) {
+	createdAt := time.Now()
+	m := wkdb.Member{
+		Id:         1,
+		Uid:        "uid1",
+		Role:       wkdb.MemberRoleAdmin,
+		MuteUntil:  100,
+		JoinSource: "qrcode",
+		Attributes: map[string]string{"nickname": "n1"},
+		CreatedAt:  &createdAt,
+	}
+	data, err := m.Marshal()
+	assert.NoError(t, err)
+
+	m2 := wkdb.Member{}
+	err = m2.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, m.Uid, m2.Uid)
+	assert.Equal(t, m.Role, m2.Role)
+	assert.Equal(t, m.MuteUntil, m2.MuteUntil)
+	assert.Equal(t, m.JoinSource, m2.JoinSource)
+	assert.Equal(t, m.Attributes, m2.Attributes)
+	assert.Equal(t, m.CreatedAt.Unix(), m2.CreatedAt.Unix())
+}
+
+func TestSubscriberHistory(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	channelId := "channel1"
+	channelType := uint8(2)
+
+	_, err = d.GetSubscriberHistory(channelId, channelType, "uid1")
+	assert.Equal(t, wkdb.ErrNotFound, err)
+
+	err = d.RecordSubscriberHistories(channelId, channelType, 10, []string{"uid1"}, nil)
+	assert.NoError(t, err)
+	err = d.RecordSubscriberHistories(channelId, channelType, 20, []string{"uid2"}, nil)
+	assert.NoError(t, err)
+
+	history, err := d.GetSubscriberHistory(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), history.JoinSeq)
+	assert.Equal(t, uint64(0), history.LeaveSeq)
+
+	// 还在频道内时重复加入不修改
+	err = d.RecordSubscriberHistories(channelId, channelType, 15, []string{"uid1"}, nil)
+	assert.NoError(t, err)
+	history, err = d.GetSubscriberHistory(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), history.JoinSeq)
+
+	// 离开
+	err = d.RecordSubscriberHistories(channelId, channelType, 30, nil, []string{"uid1"})
+	assert.NoError(t, err)
+
+	history, err = d.GetSubscriberHistory(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, "uid1", history.Uid)
+	assert.Equal(t, uint64(10), history.JoinSeq)
+	assert.Equal(t, uint64(30), history.LeaveSeq)
+
+	// 重新加入，之前的范围保留
+	err = d.RecordSubscriberHistories(channelId, channelType, 40, []string{"uid1"}, nil)
+	assert.NoError(t, err)
+	history, err = d.GetSubscriberHistory(channelId, channelType, "uid1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(40), history.JoinSeq)
+	assert.Equal(t, uint64(0), history.LeaveSeq)
+	assert.Equal(t, []wkdb.SubscriberSeqRange{{JoinSeq: 10, LeaveSeq: 30}}, history.PrevRanges)
+
+	history, err = d.GetSubscriberHistory(channelId, channelType, "uid2")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(20), history.JoinSeq)
+
+	// 没有加入记录的订阅者离开
+	err = d.RecordSubscriberHistories(channelId, channelType, 50, nil, []string{"uid3"})
+	assert.NoError(t, err)
+	history, err = d.GetSubscriberHistory(channelId, channelType, "uid3")
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), history.JoinSeq)
+	assert.Equal(t, uint64(50), history.LeaveSeq)
+}
@@ -326,6 +326,18 @@ func (wk *wukongDB) writeUser(u User, w *Batch) error {
 
 	}
 
+	if u.LastSeen != nil {
+		// lastSeen
+		var lastSeenBytes = make([]byte, 8)
+		wk.endian.PutUint64(lastSeenBytes, uint64(u.LastSeen.UnixNano()))
+		w.Set(key.NewUserColumnKey(u.Id, key.TableUser.Column.LastSeen), lastSeenBytes)
+	}
+
+	if u.LastSeenPrivacy != 0 {
+		// lastSeenPrivacy
+		w.Set(key.NewUserColumnKey(u.Id, key.TableUser.Column.LastSeenPrivacy), []byte{u.LastSeenPrivacy})
+	}
+
 	// write index
 	if err = wk.writeUserIndex(u, w); err != nil {
 		return err
@@ -418,6 +430,16 @@ func (wk *wukongDB) iteratorUser(iter *pebble.Iterator, iterFnc func(u User) boo
 				t := time.Unix(tm/1e9, tm%1e9)
 				preUser.UpdatedAt = &t
 			}
+		case key.TableUser.Column.LastSeen:
+			tm := int64(wk.endian.Uint64(iter.Value()))
+			if tm > 0 {
+				t := time.Unix(tm/1e9, tm%1e9)
+				preUser.LastSeen = &t
+			}
+		case key.TableUser.Column.LastSeenPrivacy:
+			if len(iter.Value()) > 0 {
+				preUser.LastSeenPrivacy = iter.Value()[0]
+			}
 
 		}
 		lastNeedAppend = true
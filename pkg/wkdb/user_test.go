@@ -125,3 +125,45 @@ func TestExistUser(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, exist)
 }
+
+func TestUpdateUserLastSeen(t *testing.T) {
+	d := newTestDB(t)
+	err := d.Open()
+	assert.NoError(t, err)
+
+	defer func() {
+		err := d.Close()
+		assert.NoError(t, err)
+	}()
+
+	tn := time.Now()
+	err = d.AddUser(wkdb.User{
+		Uid:       "test",
+		CreatedAt: &tn,
+		UpdatedAt: &tn,
+	})
+	assert.NoError(t, err)
+
+	// 只更新可见范围
+	err = d.UpdateUser(wkdb.User{
+		Uid:             "test",
+		UpdatedAt:       &tn,
+		LastSeenPrivacy: 2,
+	})
+	assert.NoError(t, err)
+
+	// 只更新最后在线时间，不影响已设置的可见范围
+	lastSeen := tn.Add(time.Minute)
+	err = d.UpdateUser(wkdb.User{
+		Uid:       "test",
+		UpdatedAt: &lastSeen,
+		LastSeen:  &lastSeen,
+	})
+	assert.NoError(t, err)
+
+	u, err := d.GetUser("test")
+	assert.NoError(t, err)
+	assert.Equal(t, tn.Unix(), u.CreatedAt.Unix())
+	assert.Equal(t, lastSeen.UnixNano(), u.LastSeen.UnixNano())
+	assert.Equal(t, uint8(2), u.LastSeenPrivacy)
+}
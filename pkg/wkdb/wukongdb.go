@@ -37,6 +37,8 @@ type wukongDB struct {
 
 	metrics trace.IDBMetrics
 
+	coldCache *coldSegmentCache // 冷存储消息段缓存
+
 	h hash.Hash32
 }
 
@@ -61,8 +63,9 @@ func NewWukongDB(opts *Options) DB {
 		noSync: &pebble.WriteOptions{
 			Sync: false,
 		},
-		Log:    wklog.NewWKLog("wukongDB"),
-		dblock: newDBLock(),
+		Log:       wklog.NewWKLog("wukongDB"),
+		dblock:    newDBLock(),
+		coldCache: newColdSegmentCache(opts.ColdSegmentCacheCount),
 	}
 }
 
@@ -100,12 +103,25 @@ func (wk *wukongDB) defaultPebbleOptions() *pebble.Options {
 
 func (wk *wukongDB) Open() error {
 
+	// 分区数量与已有数据不一致时按新的数量分区会找不到已有的数据，需要先离线重新分区（wk db reshard）
+	existShardNum, err := ShardNumOf(wk.opts.DataDir)
+	if err != nil {
+		return err
+	}
+	if existShardNum > 0 && existShardNum != int(wk.shardNum) {
+		return fmt.Errorf("%w: data dir has %d shards, but shard num is %d, please run reshard first", ErrShardNumMismatch, existShardNum, wk.shardNum)
+	}
+
 	wk.dblock.start()
 
 	opts := wk.defaultPebbleOptions()
+	if wk.opts.EncryptFS != nil {
+		opts.FS = wk.opts.EncryptFS
+	}
+	opts.ReadOnly = wk.opts.ReadOnly
 	for i := 0; i < int(wk.shardNum); i++ {
 
-		db, err := pebble.Open(filepath.Join(wk.opts.DataDir, "wukongimdb", fmt.Sprintf("shard%03d", i)), opts)
+		db, err := pebble.Open(shardDir(wk.opts.DataDir, i), opts)
 		if err != nil {
 			return err
 		}
@@ -123,19 +139,25 @@ func (wk *wukongDB) Open() error {
 
 func (wk *wukongDB) Close() error {
 	wk.cancelFunc()
+	// 先停止批量写入，避免关闭后还有正在提交的batch
+	for _, wkd := range wk.wkdbs {
+		wkd.Stop()
+	}
+
 	for _, db := range wk.dbs {
 		if err := db.Close(); err != nil {
 			wk.Error("close db error", zap.Error(err))
 		}
 	}
-
-	for _, wkd := range wk.wkdbs {
-		wkd.Stop()
-	}
 	wk.dblock.stop()
 	return nil
 }
 
+// 分区的数据目录
+func shardDir(dataDir string, shardId int) string {
+	return filepath.Join(dataDir, "wukongimdb", fmt.Sprintf("shard%03d", shardId))
+}
+
 func (wk *wukongDB) shardDB(v string) *pebble.DB {
 	shardId := wk.shardId(v)
 	return wk.dbs[shardId]
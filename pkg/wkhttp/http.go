@@ -55,6 +55,12 @@ func (l *WKHttp) handlersToGinHandleFuncs(handlers []HandlerFunc) []gin.HandlerF
 	return newHandlers
 }
 
+// ContextKeyForwarded 请求已被转发到其他节点处理的标记
+const ContextKeyForwarded = "wk_forwarded"
+
+// ContextKeyPermissionChecked 请求的权限已由前置中间件校验（例如api key）
+const ContextKeyPermissionChecked = "wk_permission_checked"
+
 type Context struct {
 	*gin.Context
 }
@@ -110,13 +116,21 @@ func (c *Context) ForwardWithBody(url string, body []byte) {
 			queryMap[key] = value[0]
 		}
 	}
+	headers := c.CopyRequestHeader(c.Request)
+	// 追加转发链路，接收节点可通过可信代理配置获取真实的客户端地址
+	if forwardedFor := headers["X-Forwarded-For"]; forwardedFor != "" {
+		headers["X-Forwarded-For"] = forwardedFor + ", " + c.RemoteIP()
+	} else {
+		headers["X-Forwarded-For"] = c.RemoteIP()
+	}
 	req := rest.Request{
 		Method:      rest.Method(strings.ToUpper(c.Request.Method)),
 		BaseURL:     url,
-		Headers:     c.CopyRequestHeader(c.Request),
+		Headers:     headers,
 		Body:        body,
 		QueryParams: queryMap,
 	}
+	c.Set(ContextKeyForwarded, true)
 
 	resp, err := rest.API(req)
 	if err != nil {
@@ -146,6 +160,16 @@ func (c *Context) CopyRequestHeader(request *http.Request) map[string]string {
 	return headerMap
 }
 
+// Forwarded 请求是否已被转发到其他节点处理
+func (c *Context) Forwarded() bool {
+	return c.GetBool(ContextKeyForwarded)
+}
+
+// PermissionChecked 请求的权限是否已由前置中间件校验
+func (c *Context) PermissionChecked() bool {
+	return c.GetBool(ContextKeyPermissionChecked)
+}
+
 func (c *Context) Username() string {
 	return c.GetString("username")
 }
@@ -119,7 +119,12 @@ func Configure(op *Options) {
 
 func Level() zapcore.Level {
 
-	return opts.Level
+	return atom.Level()
+}
+
+// SetLevel 运行时修改日志级别
+func SetLevel(level zapcore.Level) {
+	atom.SetLevel(level)
 }
 
 func newEncoderConfig() zapcore.EncoderConfig {
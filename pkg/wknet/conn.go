@@ -118,6 +118,9 @@ type Conn interface {
 
 type IWSConn interface {
 	WriteServerBinary(data []byte) error
+	WriteServerText(data []byte) error
+	// JSONRPC 是否是JSON-RPC文本协议的连接
+	JSONRPC() bool
 }
 
 type DefaultConn struct {
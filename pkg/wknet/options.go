@@ -34,6 +34,10 @@ type Options struct {
 	SocketSendBuffer int
 	// TCPKeepAlive sets up a duration for (SO_KEEPALIVE) socket option.
 	TCPKeepAlive time.Duration
+	// WSCompression enables websocket permessage-deflate negotiation.
+	WSCompression bool
+	// WSCompressThreshold websocket messages smaller than this size are not compressed.
+	WSCompressThreshold int
 
 	Event struct {
 		OnReadBytes  func(n int) // 读到的字节大小
@@ -49,6 +53,8 @@ func NewOptions() *Options {
 		ReadBufferSize:     1024 * 32,
 		MaxWriteBufferSize: 1024 * 1024 * 50,
 		MaxReadBufferSize:  1024 * 1024 * 50,
+
+		WSCompressThreshold: 512,
 	}
 }
 
@@ -120,6 +126,20 @@ func WithTCPKeepAlive(v time.Duration) Option {
 	}
 }
 
+// WithWSCompression enables websocket permessage-deflate negotiation.
+func WithWSCompression(v bool) Option {
+	return func(opts *Options) {
+		opts.WSCompression = v
+	}
+}
+
+// WithWSCompressThreshold websocket messages smaller than this size are not compressed.
+func WithWSCompressThreshold(v int) Option {
+	return func(opts *Options) {
+		opts.WSCompressThreshold = v
+	}
+}
+
 func WithOnReadBytes(f func(n int)) Option {
 	return func(opts *Options) {
 		opts.Event.OnReadBytes = f
@@ -3,6 +3,7 @@ package wknet
 import (
 	"bufio"
 	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io"
 	"net"
@@ -14,9 +15,18 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
 	"github.com/gobwas/ws/wsutil"
 )
 
+const (
+	WSProtocolJSONRPC = "jsonrpc"  // JSON-RPC文本协议的websocket子协议
+	WSPathJSONRPC     = "/jsonrpc" // JSON-RPC文本协议的url路径（不支持子协议的客户端使用）
+)
+
+// JSON-RPC协议下每条websocket消息写入inboundBuffer前的长度头大小（大端uint32），用于保留websocket消息边界
+const JSONRPCMessageHeaderSize = 4
+
 func CreateWSConn(id int64, connFd NetFd, localAddr, remoteAddr net.Addr, eg *Engine, reactorSub *ReactorSub) (Conn, error) {
 	defaultConn := GetDefaultConn(id, connFd, localAddr, remoteAddr, eg, reactorSub)
 	return NewWSConn(defaultConn), nil
@@ -33,6 +43,8 @@ func CreateWSSConn(id int64, connFd NetFd, localAddr, remoteAddr net.Addr, eg *E
 type WSConn struct {
 	*DefaultConn
 	upgraded         bool
+	jsonRPC          bool          // 是否是JSON-RPC文本协议
+	deflate          bool          // 是否协商了permessage-deflate
 	tmpInboundBuffer InboundBuffer // inboundBuffer InboundBuffer
 }
 
@@ -67,7 +79,18 @@ func (w *WSConn) ReadToInboundBuffer() (int, error) {
 func (w *WSConn) WriteServerBinary(data []byte) error {
 	w.mu.Lock()
 	defer w.mu.Unlock()
-	return wsutil.WriteServerBinary(w.outboundBuffer, data)
+	return writeServerMessage(w.outboundBuffer, ws.OpBinary, data, w.deflate, w.eg.options.WSCompressThreshold)
+}
+
+func (w *WSConn) WriteServerText(data []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return writeServerMessage(w.outboundBuffer, ws.OpText, data, w.deflate, w.eg.options.WSCompressThreshold)
+}
+
+// JSONRPC 连接是否协商为JSON-RPC文本协议
+func (w *WSConn) JSONRPC() bool {
+	return w.jsonRPC
 }
 
 // 解包ws的数据
@@ -94,7 +117,7 @@ func (w *WSConn) unpacketWSData() error {
 				}
 				continue
 			}
-			_, err = w.inboundBuffer.Write(msg.Payload)
+			err = writeWSMessage(w.inboundBuffer, msg.Payload, w.jsonRPC)
 			if err != nil {
 				return err
 			}
@@ -133,7 +156,7 @@ func (w *WSConn) decode() ([]wsutil.Message, error) {
 		tmpReader.Reset(buff)
 		remLen := tmpReader.Len()
 		for tmpReader.Len() > 0 {
-			messages, err = wsutil.ReadClientMessage(tmpReader, messages)
+			messages, err = readClientMessage(tmpReader, messages, w.deflate)
 			if err != nil {
 				w.Warn("read client message error", zap.Error(err))
 				break
@@ -155,7 +178,8 @@ func (w *WSConn) upgrade() error {
 	}
 	tmpReader := bytes.NewReader(buff)
 	tmpWriter := bytes.NewBuffer(nil)
-	_, err = ws.Upgrade(&readWrite{
+	flateExt := newWSFlateExtension(w.eg.options)
+	_, err = newWSUpgrader(&w.jsonRPC, flateExt).Upgrade(&readWrite{
 		Reader: tmpReader,
 		Writer: tmpWriter,
 	})
@@ -196,6 +220,7 @@ func (w *WSConn) upgrade() error {
 	}
 
 	w.DiscardFromTemp(len(buff) - tmpReader.Len())
+	w.deflate = wsFlateAccepted(flateExt)
 	w.upgraded = true
 	return nil
 }
@@ -254,9 +279,81 @@ type readWrite struct {
 	io.Writer
 }
 
+// newWSUpgrader 通过websocket子协议或者url路径协商是否使用JSON-RPC文本协议，flateExt不为nil时协商permessage-deflate
+func newWSUpgrader(jsonRPC *bool, flateExt *wsflate.Extension) ws.Upgrader {
+	u := ws.Upgrader{
+		Protocol: func(protocol []byte) bool {
+			if string(protocol) == WSProtocolJSONRPC {
+				*jsonRPC = true
+				return true
+			}
+			return false
+		},
+		OnRequest: func(uri []byte) error {
+			path := uri
+			if i := bytes.IndexByte(uri, '?'); i >= 0 {
+				path = uri[:i]
+			}
+			if string(path) == WSPathJSONRPC {
+				*jsonRPC = true
+			}
+			return nil
+		},
+	}
+	if flateExt != nil {
+		u.Negotiate = flateExt.Negotiate
+	}
+	return u
+}
+
+// 开启了websocket压缩时创建permessage-deflate扩展（每条消息独立压缩，不保留上下文）
+func newWSFlateExtension(opts *Options) *wsflate.Extension {
+	if !opts.WSCompression {
+		return nil
+	}
+	return &wsflate.Extension{
+		Parameters: wsflate.DefaultParameters,
+	}
+}
+
+func wsFlateAccepted(flateExt *wsflate.Extension) bool {
+	if flateExt == nil {
+		return false
+	}
+	_, accepted := flateExt.Accepted()
+	return accepted
+}
+
+// 将websocket消息写入inboundBuffer，JSON-RPC协议下每条消息前写入长度头以保留消息边界（json内容中可以包含换行）
+func writeWSMessage(inboundBuffer InboundBuffer, payload []byte, jsonRPC bool) error {
+	if jsonRPC {
+		header := make([]byte, JSONRPCMessageHeaderSize)
+		binary.BigEndian.PutUint32(header, uint32(len(payload)))
+		if _, err := inboundBuffer.Write(header); err != nil {
+			return err
+		}
+	}
+	_, err := inboundBuffer.Write(payload)
+	return err
+}
+
+// DecodeJSONRPCMessage 从inboundBuffer的数据中解出一条JSON-RPC的websocket消息，size为0表示数据不完整
+func DecodeJSONRPCMessage(buff []byte) (msg []byte, size int) {
+	if len(buff) < JSONRPCMessageHeaderSize {
+		return nil, 0
+	}
+	msgLen := int(binary.BigEndian.Uint32(buff))
+	if len(buff) < JSONRPCMessageHeaderSize+msgLen {
+		return nil, 0
+	}
+	return buff[JSONRPCMessageHeaderSize : JSONRPCMessageHeaderSize+msgLen], JSONRPCMessageHeaderSize + msgLen
+}
+
 type WSSConn struct {
 	*TLSConn
 	upgraded bool
+	jsonRPC  bool // 是否是JSON-RPC文本协议
+	deflate  bool // 是否协商了permessage-deflate
 
 	wsTmpInboundBuffer InboundBuffer // inboundBuffer InboundBuffer
 }
@@ -340,7 +437,8 @@ func (w *WSSConn) upgrade() error {
 
 	tmpReader := bytes.NewReader(buff)
 	tmpWriter := bytes.NewBuffer(nil)
-	_, err = ws.Upgrade(&readWrite{
+	flateExt := newWSFlateExtension(w.d.eg.options)
+	_, err = newWSUpgrader(&w.jsonRPC, flateExt).Upgrade(&readWrite{
 		Reader: tmpReader,
 		Writer: tmpWriter,
 	})
@@ -358,6 +456,7 @@ func (w *WSSConn) upgrade() error {
 
 	w.discardFromWSTemp(len(buff) - tmpReader.Len())
 
+	w.deflate = wsFlateAccepted(flateExt)
 	w.upgraded = true
 
 	return nil
@@ -386,7 +485,7 @@ func (w *WSSConn) unpacketWSData() error {
 				}
 				continue
 			}
-			_, err = w.d.inboundBuffer.Write(msg.Payload)
+			err = writeWSMessage(w.d.inboundBuffer, msg.Payload, w.jsonRPC)
 			if err != nil {
 				return err
 			}
@@ -404,7 +503,18 @@ func (w *WSSConn) Close() error {
 func (w *WSSConn) WriteServerBinary(data []byte) error {
 	w.d.mu.Lock()
 	defer w.d.mu.Unlock()
-	return wsutil.WriteServerBinary(w.TLSConn, data)
+	return writeServerMessage(w.TLSConn, ws.OpBinary, data, w.deflate, w.d.eg.options.WSCompressThreshold)
+}
+
+func (w *WSSConn) WriteServerText(data []byte) error {
+	w.d.mu.Lock()
+	defer w.d.mu.Unlock()
+	return writeServerMessage(w.TLSConn, ws.OpText, data, w.deflate, w.d.eg.options.WSCompressThreshold)
+}
+
+// JSONRPC 连接是否协商为JSON-RPC文本协议
+func (w *WSSConn) JSONRPC() bool {
+	return w.jsonRPC
 }
 
 func (w *WSSConn) decode() ([]wsutil.Message, error) {
@@ -437,7 +547,7 @@ func (w *WSSConn) decode() ([]wsutil.Message, error) {
 		tmpReader.Reset(buff)
 		remLen := tmpReader.Len()
 		for tmpReader.Len() > 0 {
-			messages, err = wsutil.ReadClientMessage(tmpReader, messages)
+			messages, err = readClientMessage(tmpReader, messages, w.deflate)
 			if err != nil {
 				w.d.Warn("read client message error", zap.Error(err))
 				break
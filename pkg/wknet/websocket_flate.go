@@ -0,0 +1,100 @@
+package wknet
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsflate"
+	"github.com/gobwas/ws/wsutil"
+)
+
+// permessage-deflate的压缩尾部，发送时需要去掉，接收时需要补上（RFC 7692 7.2）
+var (
+	wsFlateTail      = []byte{0x00, 0x00, 0xff, 0xff}
+	wsFlateFinalTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+)
+
+// 压缩消息（每条消息独立压缩，不保留上下文）
+func wsFlateCompress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = fw.Write(data); err != nil {
+		return nil, err
+	}
+	if err = fw.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), wsFlateTail), nil
+}
+
+// 解压消息
+func wsFlateDecompress(data []byte) ([]byte, error) {
+	fr := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(wsFlateFinalTail)))
+	defer fr.Close()
+	return io.ReadAll(fr)
+}
+
+// 读取客户端的一条消息，协商了permessage-deflate时解压被压缩的消息
+func readClientMessage(r io.Reader, m []wsutil.Message, deflate bool) ([]wsutil.Message, error) {
+	if !deflate {
+		return wsutil.ReadClientMessage(r, m)
+	}
+	var state wsflate.MessageState
+	rd := wsutil.Reader{
+		Source:     r,
+		State:      ws.StateServerSide | ws.StateExtended,
+		Extensions: []wsutil.RecvExtension{&state},
+		OnIntermediate: func(hdr ws.Header, src io.Reader) error {
+			bts, err := io.ReadAll(src)
+			if err != nil {
+				return err
+			}
+			m = append(m, wsutil.Message{OpCode: hdr.OpCode, Payload: bts})
+			return nil
+		},
+	}
+	h, err := rd.NextFrame()
+	if err != nil {
+		return m, err
+	}
+	var p []byte
+	if h.Fin {
+		p = make([]byte, h.Length)
+		_, err = io.ReadFull(&rd, p)
+	} else {
+		var buf bytes.Buffer
+		_, err = buf.ReadFrom(&rd)
+		p = buf.Bytes()
+	}
+	if err != nil {
+		return m, err
+	}
+	if state.IsCompressed() {
+		p, err = wsFlateDecompress(p)
+		if err != nil {
+			return m, err
+		}
+	}
+	return append(m, wsutil.Message{OpCode: h.OpCode, Payload: p}), nil
+}
+
+// 写入服务端消息，协商了permessage-deflate且消息大小达到阈值时压缩
+func writeServerMessage(w io.Writer, op ws.OpCode, data []byte, deflate bool, threshold int) error {
+	if !deflate || len(data) < threshold {
+		return wsutil.WriteServerMessage(w, op, data)
+	}
+	compressed, err := wsFlateCompress(data)
+	if err != nil {
+		return err
+	}
+	frame := ws.NewFrame(op, true, compressed)
+	if frame.Header, err = wsflate.SetBit(frame.Header); err != nil {
+		return err
+	}
+	return ws.WriteFrame(w, frame)
+}
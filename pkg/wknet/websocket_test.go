@@ -48,6 +48,91 @@ func TestWebsocket(t *testing.T) {
 
 }
 
+func TestWebsocketJSONRPC(t *testing.T) {
+	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"))
+	e.Start()
+	defer e.Stop()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	e.OnData(func(conn Conn) error {
+		data, err := conn.Peek(-1)
+		assert.NoError(t, err)
+		if len(data) == 0 {
+			return nil
+		}
+		wsConn, ok := conn.(IWSConn)
+		assert.True(t, ok)
+		assert.True(t, wsConn.JSONRPC())
+		msg1, size1 := DecodeJSONRPCMessage(data)
+		if size1 == 0 {
+			return nil
+		}
+		msg2, size2 := DecodeJSONRPCMessage(data[size1:])
+		if size2 == 0 { // 等待两条消息都到达
+			return nil
+		}
+		_, _ = conn.Discard(size1 + size2)
+		assert.Equal(t, "{\n  \"a\": 1\n}", string(msg1))
+		assert.Equal(t, `{"b":2}`, string(msg2))
+		wg.Done()
+		return nil
+	})
+
+	u := url.URL{Scheme: "ws", Host: e.WSRealListenAddr().String(), Path: "/"}
+	dialer := websocket.Dialer{Subprotocols: []string{WSProtocolJSONRPC}}
+	c1, resp, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer c1.Close()
+	assert.Equal(t, WSProtocolJSONRPC, resp.Header.Get("Sec-WebSocket-Protocol"))
+
+	err = c1.WriteMessage(websocket.TextMessage, []byte("{\n  \"a\": 1\n}")) // 格式化的json包含换行
+	assert.NoError(t, err)
+	err = c1.WriteMessage(websocket.TextMessage, []byte(`{"b":2}`))
+	assert.NoError(t, err)
+
+	wg.Wait()
+}
+
+func TestWebsocketCompression(t *testing.T) {
+	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"), WithWSCompression(true), WithWSCompressThreshold(64))
+	e.Start()
+	defer e.Stop()
+
+	msg := bytes.Repeat([]byte("hello wukongim "), 100)
+	e.OnData(func(conn Conn) error {
+		data, err := conn.Peek(-1)
+		assert.NoError(t, err)
+		if len(data) < len(msg) {
+			return nil
+		}
+		_, _ = conn.Discard(len(data))
+		assert.Equal(t, msg, data)
+
+		// 原样返回给客户端
+		wsConn := conn.(IWSConn)
+		err = wsConn.WriteServerBinary(data)
+		assert.NoError(t, err)
+		return conn.WakeWrite()
+	})
+
+	u := url.URL{Scheme: "ws", Host: e.WSRealListenAddr().String(), Path: "/"}
+	dialer := websocket.Dialer{EnableCompression: true}
+	c1, resp, err := dialer.Dial(u.String(), nil)
+	assert.NoError(t, err)
+	defer c1.Close()
+	assert.Contains(t, resp.Header.Get("Sec-WebSocket-Extensions"), "permessage-deflate")
+
+	c1.EnableWriteCompression(true)
+	err = c1.WriteMessage(websocket.BinaryMessage, msg)
+	assert.NoError(t, err)
+
+	_ = c1.SetReadDeadline(time.Now().Add(time.Second * 5))
+	_, data, err := c1.ReadMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, msg, data)
+}
+
 func TestBatchWSConn(t *testing.T) {
 	e := NewEngine(WithWSAddr("ws://0.0.0.0:0"))
 	e.Start()
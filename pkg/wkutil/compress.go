@@ -0,0 +1,75 @@
+package wkutil
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// 负载压缩算法
+const (
+	CompressionZstd   = "zstd"
+	CompressionSnappy = "snappy"
+)
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+// ErrUnsupportedCompression 不支持的压缩算法
+var ErrUnsupportedCompression = errors.New("unsupported compression")
+
+// IsSupportedCompression 是否是支持的压缩算法
+func IsSupportedCompression(compression string) bool {
+	switch compression {
+	case CompressionZstd, CompressionSnappy:
+		return true
+	}
+	return false
+}
+
+// SelectCompression 从客户端请求的压缩算法（按优先级逗号分隔）中选出服务端支持的第一个，没有则返回空
+func SelectCompression(requested string, supported []string) string {
+	if requested == "" || len(supported) == 0 {
+		return ""
+	}
+	for _, c := range strings.Split(requested, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if !IsSupportedCompression(c) {
+			continue
+		}
+		for _, s := range supported {
+			if strings.EqualFold(s, c) {
+				return c
+			}
+		}
+	}
+	return ""
+}
+
+// Compress 使用指定算法压缩数据（结果追加到dst后）
+func Compress(compression string, dst, src []byte) ([]byte, error) {
+	switch compression {
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(src, dst), nil
+	case CompressionSnappy:
+		encoded := snappy.Encode(nil, src)
+		return append(dst, encoded...), nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedCompression, compression)
+}
+
+// Decompress 使用指定算法解压数据
+func Decompress(compression string, src []byte) ([]byte, error) {
+	switch compression {
+	case CompressionZstd:
+		return zstdDecoder.DecodeAll(src, nil)
+	case CompressionSnappy:
+		return snappy.Decode(nil, src)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedCompression, compression)
+}
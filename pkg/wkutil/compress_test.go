@@ -0,0 +1,33 @@
+package wkutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompress(t *testing.T) {
+	data := bytes.Repeat([]byte(`{"type":1,"content":"hello"}`), 50)
+	for _, compression := range []string{CompressionZstd, CompressionSnappy} {
+		compressed, err := Compress(compression, nil, data)
+		assert.NoError(t, err)
+		assert.Less(t, len(compressed), len(data))
+
+		result, err := Decompress(compression, compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, data, result)
+	}
+
+	_, err := Compress("gzip", nil, data)
+	assert.ErrorIs(t, err, ErrUnsupportedCompression)
+}
+
+func TestSelectCompression(t *testing.T) {
+	supported := []string{CompressionZstd, CompressionSnappy}
+	assert.Equal(t, CompressionSnappy, SelectCompression("snappy,zstd", supported))
+	assert.Equal(t, CompressionZstd, SelectCompression("gzip, ZSTD", supported))
+	assert.Equal(t, "", SelectCompression("gzip", supported))
+	assert.Equal(t, "", SelectCompression("zstd", []string{CompressionSnappy}))
+	assert.Equal(t, "", SelectCompression("", supported))
+}